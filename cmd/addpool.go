@@ -299,6 +299,9 @@ func (apc *addPoolCmd) run(cmd *cobra.Command, args []string) error {
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	deploymentSuffix := random.Int31()
 
+	if err = armhelpers.ValidateDeploymentParameters(apc.logger, templateJSON, parametersJSON); err != nil {
+		return err
+	}
 	_, err = apc.client.DeployTemplate(
 		ctx,
 		apc.resourceGroupName,
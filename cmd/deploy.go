@@ -449,6 +449,10 @@ func (dc *deployCmd) run() error {
 
 	deploymentSuffix := dc.random.Int31()
 
+	if err = armhelpers.ValidateDeploymentParameters(log.NewEntry(log.StandardLogger()), templateJSON, parametersJSON); err != nil {
+		return err
+	}
+
 	if res, err := dc.client.DeployTemplate(
 		cx,
 		dc.resourceGroup,
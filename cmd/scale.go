@@ -529,6 +529,9 @@ func (sc *scaleCmd) run(cmd *cobra.Command, args []string) error {
 		sc.logger.Infof("Nodes in pool '%s' before scaling:\n", sc.agentPoolToScale)
 		operations.PrintNodes(sc.nodes)
 	}
+	if err = armhelpers.ValidateDeploymentParameters(sc.logger, templateJSON, parametersJSON); err != nil {
+		return err
+	}
 	_, err = sc.client.DeployTemplate(
 		ctx,
 		sc.resourceGroupName,
@@ -4,10 +4,14 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"os/user"
 	"path/filepath"
 
 	"regexp"
@@ -17,11 +21,13 @@ import (
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/api/common"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/armtest"
 	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
 	"github.com/Azure/aks-engine/pkg/engine"
 	"github.com/Azure/aks-engine/pkg/helpers"
 	"github.com/Azure/aks-engine/pkg/i18n"
 	"github.com/Azure/aks-engine/pkg/operations/kubernetesupgrade"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/blang/semver"
 	"github.com/leonelquinteros/gotext"
@@ -29,14 +35,23 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
-	upgradeName                     = "upgrade"
-	upgradeShortDescription         = "Upgrade an existing AKS Engine-created Kubernetes cluster"
-	upgradeLongDescription          = "Upgrade an existing AKS Engine-created Kubernetes cluster, one node at a time"
-	smalldiskWindowsImageIdentifier = "smalldisk"
-	ctrdWindowsImageIdentifier      = "ctrd"
+	upgradeName                        = "upgrade"
+	upgradeShortDescription            = "Upgrade an existing AKS Engine-created Kubernetes cluster"
+	upgradeLongDescription             = "Upgrade an existing AKS Engine-created Kubernetes cluster, one node at a time"
+	upgradeVerifyName                  = "verify"
+	upgradeVerifyShortDescription      = "Verify that an existing AKS Engine-created Kubernetes cluster can be upgraded"
+	upgradeVerifyLongDescription       = "Run the upgrade preflight checks against an existing AKS Engine-created Kubernetes cluster without changing it"
+	upgradeExportStateName             = "export-state"
+	upgradeExportStateShortDescription = "Export the upgrade state of an existing AKS Engine-created Kubernetes cluster"
+	upgradeExportStateLongDescription  = "Write the api model, the upgrade template and the nodes left to upgrade of an existing AKS Engine-created Kubernetes cluster to a JSON file, e.g. to resume the upgrade from another machine"
+	smalldiskWindowsImageIdentifier    = "smalldisk"
+	ctrdWindowsImageIdentifier         = "ctrd"
+	defaultUpgradeLogMaxSizeBytes      = 100 * 1024 * 1024
 )
 
 type upgradeCmd struct {
@@ -52,10 +67,54 @@ type upgradeCmd struct {
 	kubeconfigPath                           string
 	timeoutInMinutes                         int
 	cordonDrainTimeoutInMinutes              int
+	postDeleteWait                           time.Duration
+	deploymentPollInterval                   time.Duration
+	reuseDeployment                          bool
+	maxDeploymentPolls                       int
+	deploymentMode                           string
+	minFreeCapacityPercent                   float64
+	skipCapacityCheck                        bool
 	force                                    bool
 	controlPlaneOnly                         bool
+	osOnly                                   bool
+	forceImageUpdate                         bool
+	validateOnly                             bool
+	generateARMTemplate                      bool
+	templateOutputDirectory                  string
 	disableClusterInitComponentDuringUpgrade bool
 	upgradeWindowsVHD                        bool
+	pauseCheckFile                           string
+	nodeGroupSize                            int
+	nodeGroupPause                           time.Duration
+	concurrencyFile                          string
+	ignorePodsOnNodes                        string
+	skipPools                                []string
+	consecutiveFailureLimit                  int
+	nodeShutdownTimeout                      time.Duration
+	scaleDownBeforeUpgrade                   bool
+	canaryNode                               bool
+	autoApproveCanary                        bool
+	cloudConfigUpdate                        bool
+	inPlace                                  bool
+	updateVMSSInstances                      bool
+	liveEtcdMemberMigration                  bool
+	linuxSSHPrivateKeyPath                   string
+	noCleanup                                bool
+	emitKubernetesEvents                     bool
+	watchMode                                bool
+	logFile                                  string
+	logMaxSizeBytes                          int64
+	azureDevOps                              bool
+	slackWebhookURL                          string
+	slackChannel                             string
+	slackMentionOnFailure                    string
+	upgradeReport                            bool
+	emitMetricsPrometheus                    bool
+	metricsAddress                           string
+	stateOutputPath                          string
+	recordARMCalls                           bool
+	replayARMCalls                           bool
+	armFixtureDir                            string
 
 	// derived
 	containerService    *api.ContainerService
@@ -66,6 +125,9 @@ type upgradeCmd struct {
 	agentPoolsToUpgrade map[string]bool
 	timeout             *time.Duration
 	cordonDrainTimeout  *time.Duration
+	poolUpgradeConfigs  map[string]kubernetesupgrade.PoolUpgradeConfig
+	armRecorder         *armtest.RecordingClient
+	armReplayer         *armtest.ReplayClient
 }
 
 func newUpgradeCmd() *cobra.Command {
@@ -80,7 +142,48 @@ func newUpgradeCmd() *cobra.Command {
 		RunE:  uc.run,
 	}
 
-	f := upgradeCmd.Flags()
+	uc.addFlags(upgradeCmd.Flags())
+	upgradeCmd.AddCommand(newUpgradeVerifyCmd())
+	upgradeCmd.AddCommand(newUpgradeExportStateCmd())
+
+	return upgradeCmd
+}
+
+func newUpgradeVerifyCmd() *cobra.Command {
+	uc := upgradeCmd{
+		authProvider: &authArgs{},
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   upgradeVerifyName,
+		Short: upgradeVerifyShortDescription,
+		Long:  upgradeVerifyLongDescription,
+		RunE:  uc.verify,
+	}
+	uc.addFlags(verifyCmd.Flags())
+
+	return verifyCmd
+}
+
+func newUpgradeExportStateCmd() *cobra.Command {
+	uc := upgradeCmd{
+		authProvider: &authArgs{},
+	}
+
+	exportStateCmd := &cobra.Command{
+		Use:   upgradeExportStateName,
+		Short: upgradeExportStateShortDescription,
+		Long:  upgradeExportStateLongDescription,
+		RunE:  uc.exportState,
+	}
+	uc.addFlags(exportStateCmd.Flags())
+	exportStateCmd.Flags().StringVarP(&uc.stateOutputPath, "output", "o", "", "path of the upgrade state file to write (required)")
+
+	return exportStateCmd
+}
+
+// addFlags registers the upgrade flags, shared by the upgrade and upgrade verify commands
+func (uc *upgradeCmd) addFlags(f *flag.FlagSet) {
 	f.StringVarP(&uc.location, "location", "l", "", "location the cluster is deployed in (required)")
 	f.StringVarP(&uc.resourceGroupName, "resource-group", "g", "", "the resource group where the cluster is deployed (required)")
 	f.StringVarP(&uc.apiModelPath, "api-model", "m", "", "path to the generated apimodel.json file")
@@ -89,14 +192,55 @@ func newUpgradeCmd() *cobra.Command {
 	f.StringVarP(&uc.kubeconfigPath, "kubeconfig", "b", "", "the path of the kubeconfig file")
 	f.IntVar(&uc.timeoutInMinutes, "vm-timeout", -1, "how long to wait for each vm to be upgraded in minutes")
 	f.IntVar(&uc.cordonDrainTimeoutInMinutes, "cordon-drain-timeout", -1, "how long to wait for each vm to be cordoned in minutes")
+	f.DurationVar(&uc.postDeleteWait, "post-delete-wait", 10*time.Second, "how long to wait after deleting a control plane vm before recreating it, e.g. 30s")
+	f.DurationVar(&uc.deploymentPollInterval, "deployment-poll-interval", 0, "how often to poll the state of the control plane vm deployments, e.g. 1m; by default the ARM client waits for the deployments")
+	f.BoolVar(&uc.reuseDeployment, "reuse-deployment", false, "wait for the running upgrade deployment of a control plane vm, e.g. left by an interrupted upgrade, instead of creating a new deployment")
+	f.IntVar(&uc.maxDeploymentPolls, "max-deployment-polls", 0, "how many times to poll the state of a control plane vm deployment before giving up, 0 means no limit")
+	f.StringVar(&uc.deploymentMode, "deployment-mode", kubernetesupgrade.DefaultDeploymentMode, "ARM deployment mode of the control plane vm deployments, Incremental or Complete. WARNING: Complete mode deletes every resource of the resource group that is not in the upgrade template")
+	f.Float64Var(&uc.minFreeCapacityPercent, "min-free-capacity-percent", 10, "percentage of cpu and memory that must remain free on the other nodes after draining an agent node")
+	f.BoolVar(&uc.skipCapacityCheck, "skip-capacity-check", false, "skip checking that the cluster can absorb the workloads of each agent node before draining it")
 	f.BoolVarP(&uc.force, "force", "f", false, "force upgrading the cluster to desired version. Allows same version upgrades and downgrades.")
 	f.BoolVarP(&uc.controlPlaneOnly, "control-plane-only", "", false, "upgrade control plane VMs only, do not upgrade node pools")
+	f.BoolVar(&uc.osOnly, "os-only", false, "recreate the cluster VMs on the latest OS image without changing the Kubernetes version")
+	f.BoolVar(&uc.forceImageUpdate, "force-image-update", false, "recreate every cluster VM on the latest version of its marketplace OS image, even if its Kubernetes version is unchanged; use with --os-only to keep the current Kubernetes version")
+	f.BoolVar(&uc.validateOnly, "validate-only", false, "only validate that the existing nodes are ready, without upgrading any node or changing the api model")
+	f.BoolVar(&uc.generateARMTemplate, "generate-arm-template", false, "only write the ARM template and parameters the control plane vms would be deployed with, without upgrading any node or changing the api model")
+	f.StringVar(&uc.templateOutputDirectory, "output-dir", "", "directory the --generate-arm-template files are written to, defaults to the upgrade directory next to the api model")
 	f.BoolVarP(&uc.upgradeWindowsVHD, "upgrade-windows-vhd", "", true, "upgrade image reference of the Windows nodes")
+	f.StringVar(&uc.pauseCheckFile, "pause-check-file", "", "pause the upgrade before the next node when this file exists, until it is created again")
+	f.IntVar(&uc.nodeGroupSize, "node-group-size", 0, "upgrade the nodes in groups of this size, pausing after each group for --node-group-pause or until the --pause-check-file is created; 0 upgrades all nodes without pausing")
+	f.DurationVar(&uc.nodeGroupPause, "node-group-pause", 0, "how long to pause after each group of --node-group-size nodes, e.g. 24h; 0 pauses until the --pause-check-file is created")
+	f.StringVar(&uc.concurrencyFile, "concurrency-file", "", "path of a JSON file of per agent pool upgrade settings keyed by pool name, e.g. {\"agentpool1\": {\"validationWorkers\": 3, \"drainTimeout\": \"10m\", \"skipDrain\": false, \"nodeGroupSize\": 5}}; the agent nodes are still upgraded one at a time, validationWorkers is the number of new nodes validated at the same time and of nodes deleted by --scale-down-before-upgrade")
+	f.StringSliceVar(&uc.skipPools, "skip-pools", nil, "comma-separated names of the agent pools to exclude from the upgrade, their nodes keep their current Kubernetes version")
+	f.IntVar(&uc.consecutiveFailureLimit, "consecutive-failure-limit", kubernetesupgrade.DefaultConsecutiveFailureLimit, "how many agent nodes in a row may fail to upgrade before the upgrade stops; below the limit the upgrade goes on with the next node and fails once all nodes are processed, 1 stops at the first failed node")
+	f.DurationVar(&uc.nodeShutdownTimeout, "node-shutdown-timeout", 0, "graceful node shutdown grace period patched into the kube-system/kubelet-config config map while each agent node is upgraded, e.g. 2m; the config map must exist, the running kubelets are not reconfigured; requires Kubernetes 1.21 or later")
+	f.StringVar(&uc.ignorePodsOnNodes, "ignore-pods-on-nodes", "", "skip upgrading the agent nodes running a pod matching this label selector, e.g. storage=local; the skipped nodes must be upgraded manually")
+	f.BoolVar(&uc.scaleDownBeforeUpgrade, "scale-down-before-upgrade", false, "delete the first nodes of each availability set agent pool before its upgrade instead of creating an extra node, and delete the agent nodes without draining them if their pod disruption budgets allow it")
+	f.BoolVar(&uc.canaryNode, "canary-node", false, "upgrade the first node of each pool as a canary, then pause until the operator approves it at the prompt or, without a terminal, removes the upgrade-canary-resume file written next to the api model")
+	f.BoolVar(&uc.autoApproveCanary, "auto-approve-canary", false, "continue the upgrade after each --canary-node without pausing, e.g. in CI")
+	f.BoolVar(&uc.cloudConfigUpdate, "cloud-config-update", false, "regenerate the cloud provider config of the upgraded control plane vms from the api model, e.g. after moving the cluster to another resource group, subscription or virtual network")
+	f.BoolVar(&uc.inPlace, "in-place", false, "upgrade the kubelet of the Linux availability set agent nodes in place over SSH instead of replacing the nodes, for patch release upgrades only")
+	f.BoolVar(&uc.updateVMSSInstances, "update-vmss-instances", false, "upgrade the VMSS agent nodes by updating each VM instance in place to the latest scale set model, instead of adding a new instance and deleting the old one")
+	f.BoolVar(&uc.liveEtcdMemberMigration, "live-etcd-member-migration", false, "remove the etcd member of each control plane vm before deleting the vm and add it back once the upgraded vm has joined, keeping the etcd quorum throughout the upgrade")
+	f.StringVar(&uc.linuxSSHPrivateKeyPath, "linux-ssh-private-key", "", "path to a valid private SSH key to access the cluster's Linux nodes, required by --in-place and --live-etcd-member-migration")
+	f.BoolVar(&uc.noCleanup, "no-cleanup", false, "keep the upgrade deployments, the upgrade directory next to the api model and the orphaned NICs and disks after a successful upgrade")
+	f.BoolVar(&uc.emitKubernetesEvents, "emit-k8s-events", false, "record the upgrade progress as Kubernetes events of the kube-system namespace")
+	f.BoolVar(&uc.watchMode, "watch", false, "show the live upgrade status of each node instead of the upgrade logs, which are written to a temporary file")
+	f.StringVar(&uc.logFile, "log-file", "", "also write the upgrade logs as JSON lines to this file, rotated when it reaches --log-max-size-bytes")
+	f.Int64Var(&uc.logMaxSizeBytes, "log-max-size-bytes", defaultUpgradeLogMaxSizeBytes, "size in bytes the --log-file is rotated at, keeping the last 3 rotated files; 0 never rotates the file")
+	f.BoolVar(&uc.azureDevOps, "azure-devops", false, "write the upgrade progress as Azure DevOps logging commands to stdout, setting the result of the pipeline task")
+	f.StringVar(&uc.slackWebhookURL, "notify-slack", "", "URL of a Slack incoming webhook the upgrade started, node upgraded, failure and completed events are posted to")
+	f.StringVar(&uc.slackChannel, "slack-channel", "", "Slack channel the --notify-slack events are posted to, e.g. #ops; defaults to the channel of the webhook")
+	f.StringVar(&uc.slackMentionOnFailure, "slack-mention-on-failure", "", "mention added to the --notify-slack failure events, e.g. @oncall or @here")
+	f.BoolVar(&uc.emitMetricsPrometheus, "emit-metrics-prometheus", false, "serve the upgrade progress as Prometheus metrics on http://<metrics-address>/metrics while the upgrade runs")
+	f.StringVar(&uc.metricsAddress, "metrics-address", kubernetesupgrade.DefaultMetricsAddress, "address the --emit-metrics-prometheus endpoint listens on")
+	f.BoolVar(&uc.upgradeReport, "upgrade-report", false, "write a Markdown report of the upgrade outcome next to the api model")
+	f.BoolVar(&uc.recordARMCalls, "record-arm-calls", false, "test mode: record the ARM API calls of the upgrade to the --arm-fixture-dir, the fixture holds the ARM responses, which may include secrets")
+	f.BoolVar(&uc.replayARMCalls, "replay-arm-calls", false, "test mode: answer the ARM API calls of the upgrade with the calls recorded in the --arm-fixture-dir instead of calling ARM, without Azure credentials; the Kubernetes API calls still reach the cluster")
+	f.StringVar(&uc.armFixtureDir, "arm-fixture-dir", "", "directory of the ARM API calls recorded by --record-arm-calls and replayed by --replay-arm-calls")
 	addAuthFlags(uc.getAuthArgs(), f)
 
 	_ = f.MarkDeprecated("deployment-dir", "deployment-dir is no longer required for scale or upgrade. Please use --api-model.")
-
-	return upgradeCmd
 }
 
 func (uc *upgradeCmd) validate(cmd *cobra.Command) error {
@@ -128,11 +272,76 @@ func (uc *upgradeCmd) validate(cmd *cobra.Command) error {
 		uc.cordonDrainTimeout = &cordonDrainTimeout
 	}
 
-	if uc.upgradeVersion == "" {
+	if uc.postDeleteWait < 0 {
+		_ = cmd.Usage()
+		return errors.New("--post-delete-wait must not be negative")
+	}
+
+	if uc.deploymentPollInterval < 0 {
+		_ = cmd.Usage()
+		return errors.New("--deployment-poll-interval must not be negative")
+	}
+
+	if uc.maxDeploymentPolls < 0 {
+		_ = cmd.Usage()
+		return errors.New("--max-deployment-polls must not be negative")
+	}
+
+	if uc.deploymentMode != "" && kubernetesupgrade.ValidateDeploymentMode(uc.deploymentMode) != nil {
+		_ = cmd.Usage()
+		return errors.New("--deployment-mode must be Incremental or Complete")
+	}
+
+	if uc.nodeGroupSize < 0 {
+		_ = cmd.Usage()
+		return errors.New("--node-group-size must not be negative")
+	}
+
+	if uc.consecutiveFailureLimit < 0 {
+		_ = cmd.Usage()
+		return errors.New("--consecutive-failure-limit must not be negative")
+	}
+
+	if uc.nodeGroupPause < 0 {
+		_ = cmd.Usage()
+		return errors.New("--node-group-pause must not be negative")
+	}
+
+	if uc.nodeGroupSize > 0 && uc.nodeGroupPause == 0 && uc.pauseCheckFile == "" {
+		_ = cmd.Usage()
+		return errors.New("--node-group-size requires --node-group-pause or --pause-check-file to continue the upgrade after each group")
+	}
+
+	if uc.autoApproveCanary && !uc.canaryNode {
+		_ = cmd.Usage()
+		return errors.New("--auto-approve-canary requires --canary-node")
+	}
+
+	if uc.minFreeCapacityPercent < 0 || uc.minFreeCapacityPercent > 100 {
+		_ = cmd.Usage()
+		return errors.New("--min-free-capacity-percent must be between 0 and 100")
+	}
+
+	if uc.upgradeVersion == "" && !uc.osOnly && !uc.validateOnly {
 		_ = cmd.Usage()
 		return errors.New("--upgrade-version must be specified")
 	}
 
+	if uc.forceImageUpdate && uc.validateOnly {
+		_ = cmd.Usage()
+		return errors.New("ambiguous, please specify only one of --force-image-update and --validate-only")
+	}
+
+	if uc.generateARMTemplate && uc.validateOnly {
+		_ = cmd.Usage()
+		return errors.New("ambiguous, please specify only one of --generate-arm-template and --validate-only")
+	}
+
+	if uc.templateOutputDirectory != "" && !uc.generateARMTemplate {
+		_ = cmd.Usage()
+		return errors.New("--output-dir requires --generate-arm-template")
+	}
+
 	if uc.apiModelPath == "" && uc.deploymentDirectory == "" {
 		_ = cmd.Usage()
 		return errors.New("--api-model must be specified")
@@ -143,6 +352,63 @@ func (uc *upgradeCmd) validate(cmd *cobra.Command) error {
 		return errors.New("ambiguous, please specify only one of --api-model and --deployment-dir")
 	}
 
+	if uc.logMaxSizeBytes < 0 {
+		_ = cmd.Usage()
+		return errors.New("--log-max-size-bytes must not be negative")
+	}
+
+	if uc.recordARMCalls && uc.replayARMCalls {
+		_ = cmd.Usage()
+		return errors.New("ambiguous, please specify only one of --record-arm-calls and --replay-arm-calls")
+	}
+
+	if (uc.recordARMCalls || uc.replayARMCalls) && uc.armFixtureDir == "" {
+		_ = cmd.Usage()
+		return errors.New("--record-arm-calls and --replay-arm-calls require --arm-fixture-dir")
+	}
+
+	if uc.azureDevOps && uc.watchMode {
+		_ = cmd.Usage()
+		return errors.New("ambiguous, please specify only one of --azure-devops and --watch")
+	}
+
+	if uc.slackWebhookURL != "" {
+		if u, err := url.Parse(uc.slackWebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			_ = cmd.Usage()
+			return errors.New("--notify-slack must be an https URL")
+		}
+	} else if uc.slackChannel != "" || uc.slackMentionOnFailure != "" {
+		_ = cmd.Usage()
+		return errors.New("--slack-channel and --slack-mention-on-failure require --notify-slack")
+	}
+
+	if uc.ignorePodsOnNodes != "" {
+		if _, err = labels.Parse(uc.ignorePodsOnNodes); err != nil {
+			_ = cmd.Usage()
+			return errors.Wrap(err, "--ignore-pods-on-nodes must be a valid label selector")
+		}
+	}
+
+	if uc.inPlace {
+		if uc.linuxSSHPrivateKeyPath == "" {
+			_ = cmd.Usage()
+			return errors.New("--in-place requires --linux-ssh-private-key")
+		}
+		if _, err = os.Stat(uc.linuxSSHPrivateKeyPath); os.IsNotExist(err) {
+			return errors.Errorf("specified --linux-ssh-private-key does not exist (%s)", uc.linuxSSHPrivateKeyPath)
+		}
+	}
+
+	if uc.liveEtcdMemberMigration {
+		if uc.linuxSSHPrivateKeyPath == "" {
+			_ = cmd.Usage()
+			return errors.New("--live-etcd-member-migration requires --linux-ssh-private-key")
+		}
+		if _, err = os.Stat(uc.linuxSSHPrivateKeyPath); os.IsNotExist(err) {
+			return errors.Errorf("specified --linux-ssh-private-key does not exist (%s)", uc.linuxSSHPrivateKeyPath)
+		}
+	}
+
 	return nil
 }
 
@@ -227,11 +493,24 @@ func (uc *upgradeCmd) loadCluster() error {
 		}
 	}
 
+	if uc.concurrencyFile != "" {
+		if uc.poolUpgradeConfigs, err = kubernetesupgrade.ParseConcurrencyFile(uc.concurrencyFile); err != nil {
+			return err
+		}
+		if err = kubernetesupgrade.ValidatePoolUpgradeConfigs(uc.poolUpgradeConfigs, uc.containerService.Properties); err != nil {
+			return errors.Wrapf(err, "validating the concurrency file %s", uc.concurrencyFile)
+		}
+	}
+
+	if err = kubernetesupgrade.ValidateSkipPools(uc.skipPools, uc.containerService.Properties); err != nil {
+		return errors.Wrap(err, "validating --skip-pools")
+	}
+
 	if err = uc.getAuthArgs().validateAuthArgs(); err != nil {
 		return err
 	}
 
-	if uc.client, err = uc.getAuthArgs().getClient(); err != nil {
+	if uc.client, err = uc.getARMClient(); err != nil {
 		return errors.Wrap(err, "failed to get client")
 	}
 
@@ -274,13 +553,31 @@ func (uc *upgradeCmd) initialize() error {
 		return errors.New("--location does not match api model location")
 	}
 
+	if uc.validateOnly && uc.upgradeVersion == "" {
+		// no node is upgraded, the nodes are validated at their current version
+		uc.upgradeVersion = uc.containerService.Properties.OrchestratorProfile.OrchestratorVersion
+	}
+
+	if uc.osOnly {
+		currentVersion := uc.containerService.Properties.OrchestratorProfile.OrchestratorVersion
+		if uc.upgradeVersion == "" {
+			uc.upgradeVersion = currentVersion
+		} else if uc.upgradeVersion != currentVersion {
+			return errors.Errorf("--upgrade-version must match the current Kubernetes version %s when --os-only is set", currentVersion)
+		}
+	}
+
 	// Validate semver compatibility
 	_, err := semver.Make(uc.upgradeVersion)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("Invalid --upgrade-version value '%s', not a semver string", uc.upgradeVersion))
 	}
 
-	if !uc.force {
+	if err = kubernetesupgrade.ValidateNodeShutdownGracePeriod(uc.nodeShutdownTimeout, uc.upgradeVersion); err != nil {
+		return errors.Wrap(err, "validating --node-shutdown-timeout")
+	}
+
+	if !uc.force && !uc.osOnly && !uc.validateOnly {
 		err := uc.validateTargetVersion()
 		if err != nil {
 			return errors.Wrap(err, "Invalid upgrade target version. Consider using --force if you really want to proceed")
@@ -308,6 +605,7 @@ func (uc *upgradeCmd) run(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "validating upgrade command")
 	}
 
+	defer uc.finishARMTestMode()
 	err = uc.loadCluster()
 	if err != nil {
 		return errors.Wrap(err, "loading existing cluster")
@@ -319,52 +617,54 @@ func (uc *upgradeCmd) run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	upgradeCluster := kubernetesupgrade.UpgradeCluster{
-		Translator: &i18n.Translator{
-			Locale: uc.locale,
-		},
-		Logger:             log.NewEntry(log.New()),
-		Client:             uc.client,
-		StepTimeout:        uc.timeout,
-		CordonDrainTimeout: uc.cordonDrainTimeout,
+	upgradeCluster := uc.newUpgradeCluster()
+	if uc.azureDevOps {
+		upgradeCluster.Reporters = append(upgradeCluster.Reporters, kubernetesupgrade.NewDevOpsPipelineReporter(os.Stdout))
 	}
-
-	upgradeCluster.ClusterTopology = kubernetesupgrade.ClusterTopology{}
-	upgradeCluster.SubscriptionID = uc.getAuthArgs().SubscriptionID.String()
-	upgradeCluster.ResourceGroup = uc.resourceGroupName
-	upgradeCluster.DataModel = uc.containerService
-	upgradeCluster.NameSuffix = uc.nameSuffix
-	upgradeCluster.AgentPoolsToUpgrade = uc.agentPoolsToUpgrade
-	upgradeCluster.Force = uc.force
-	upgradeCluster.ControlPlaneOnly = uc.controlPlaneOnly
-
-	var kubeConfig string
-	if uc.kubeconfigPath != "" {
-		var path string
-		var content []byte
-		path, err = filepath.Abs(uc.kubeconfigPath)
+	if uc.slackWebhookURL != "" {
+		upgradeCluster.Reporters = append(upgradeCluster.Reporters, kubernetesupgrade.NewSlackNotifier(uc.slackWebhookURL, uc.slackChannel, uc.slackMentionOnFailure))
+	}
+	if uc.emitMetricsPrometheus {
+		stopMetrics, err := uc.serveMetrics(upgradeCluster)
 		if err != nil {
-			return errors.Wrap(err, "reading --kubeconfig")
+			return err
 		}
-		content, err = ioutil.ReadFile(path)
-		if err != nil {
-			return errors.Wrap(err, "reading --kubeconfig")
+		defer stopMetrics()
+	}
+	kubeConfig, err := uc.getKubeConfig()
+	if err != nil {
+		return err
+	}
+
+	var stopWatch func()
+	if uc.watchMode {
+		if stopWatch, err = uc.watch(upgradeCluster); err != nil {
+			return err
 		}
-		kubeConfig = string(content)
-	} else {
-		kubeConfig, err = engine.GenerateKubeConfig(uc.containerService.Properties, uc.location)
+	}
+	if uc.logFile != "" {
+		closeLogFile, err := uc.logToFile(upgradeCluster)
 		if err != nil {
-			return errors.Wrap(err, "generating kubeconfig")
+			if stopWatch != nil {
+				stopWatch()
+			}
+			return err
 		}
+		defer closeLogFile()
 	}
-
-	upgradeCluster.IsVMSSToBeUpgraded = isVMSSNameInAgentPoolsArray
-	upgradeCluster.CurrentVersion = uc.currentVersion
-
-	if err = upgradeCluster.UpgradeCluster(uc.client, kubeConfig, BuildTag); err != nil {
+	err = upgradeCluster.UpgradeCluster(uc.client, kubeConfig, BuildTag)
+	if stopWatch != nil {
+		stopWatch()
+	}
+	if err != nil {
 		return errors.Wrap(err, "upgrading cluster")
 	}
 
+	if uc.validateOnly || uc.generateARMTemplate {
+		// the cluster is unchanged, so is the api model
+		return nil
+	}
+
 	// Save the new apimodel to reflect the cluster's state.
 	// Restore the original cluster-init component enabled value, if it was disabled during upgrade
 	if uc.disableClusterInitComponentDuringUpgrade {
@@ -391,6 +691,311 @@ func (uc *upgradeCmd) run(cmd *cobra.Command, args []string) error {
 	return f.SaveFile(dir, file, b)
 }
 
+func (uc *upgradeCmd) verify(cmd *cobra.Command, args []string) error {
+	err := uc.validate(cmd)
+	if err != nil {
+		return errors.Wrap(err, "validating upgrade verify command")
+	}
+
+	err = uc.loadCluster()
+	if err != nil {
+		return errors.Wrap(err, "loading existing cluster")
+	}
+
+	upgradeCluster := uc.newUpgradeCluster()
+	if upgradeCluster.KubeConfig, err = uc.getKubeConfig(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
+	return upgradeCluster.Verify(ctx)
+}
+
+func (uc *upgradeCmd) exportState(cmd *cobra.Command, args []string) error {
+	if uc.stateOutputPath == "" {
+		_ = cmd.Usage()
+		return errors.New("--output must be specified")
+	}
+	err := uc.validate(cmd)
+	if err != nil {
+		return errors.Wrap(err, "validating upgrade export-state command")
+	}
+
+	err = uc.loadCluster()
+	if err != nil {
+		return errors.Wrap(err, "loading existing cluster")
+	}
+
+	upgradeCluster := uc.newUpgradeCluster()
+	if upgradeCluster.KubeConfig, err = uc.getKubeConfig(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
+	if err = upgradeCluster.ExportState(ctx, BuildTag, uc.stateOutputPath); err != nil {
+		return errors.Wrap(err, "exporting upgrade state")
+	}
+	log.Infof("Upgrade state written to %s", uc.stateOutputPath)
+	return nil
+}
+
+// watch renders the live upgrade status to stdout, the upgrade logs going to a temporary file.
+// The returned function stops the rendering and prints the upgrade summary.
+func (uc *upgradeCmd) watch(upgradeCluster *kubernetesupgrade.UpgradeCluster) (func(), error) {
+	logFile, err := ioutil.TempFile("", "aks-engine-upgrade-*.log")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating upgrade log file")
+	}
+	logger := log.New()
+	logger.Out = logFile
+	upgradeCluster.Logger = log.NewEntry(logger)
+
+	watch := kubernetesupgrade.NewWatchReporter(os.Stdout)
+	upgradeCluster.Reporters = append(upgradeCluster.Reporters, watch)
+	fmt.Printf("Upgrade logs are written to %s\n\n", logFile.Name())
+	watch.Start()
+	return func() {
+		watch.Stop()
+		logFile.Close()
+	}, nil
+}
+
+// serveMetrics serves the upgrade progress as Prometheus metrics on the --metrics-address while the upgrade runs.
+// The returned function stops serving the metrics.
+func (uc *upgradeCmd) serveMetrics(upgradeCluster *kubernetesupgrade.UpgradeCluster) (func(), error) {
+	metrics := kubernetesupgrade.NewMetricsServer(uc.metricsAddress)
+	if err := metrics.Start(); err != nil {
+		return nil, err
+	}
+	upgradeCluster.Reporters = append(upgradeCluster.Reporters, metrics)
+	log.Infof("Serving the upgrade metrics on http://%s/metrics", metrics.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := metrics.Stop(ctx); err != nil {
+			log.Warnf("Error stopping the upgrade metrics server: %v", err)
+		}
+	}, nil
+}
+
+// logToFile adds a hook writing the entries of the upgrade and command loggers as JSON to the --log-file,
+// rotated at --log-max-size-bytes. The returned function removes the hook and closes the file.
+func (uc *upgradeCmd) logToFile(upgradeCluster *kubernetesupgrade.UpgradeCluster) (func(), error) {
+	file, err := helpers.NewRotatingFile(uc.logFile, uc.logMaxSizeBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening --log-file")
+	}
+	hook := &logFileHook{writer: file, formatter: &log.JSONFormatter{}}
+	loggers := []*log.Logger{log.StandardLogger()}
+	if upgradeCluster.Logger != nil && upgradeCluster.Logger.Logger != log.StandardLogger() {
+		loggers = append(loggers, upgradeCluster.Logger.Logger)
+	}
+	previous := make([]log.LevelHooks, len(loggers))
+	for i, logger := range loggers {
+		hooks := make(log.LevelHooks)
+		for level, h := range logger.Hooks {
+			hooks[level] = append([]log.Hook{}, h...)
+		}
+		hooks.Add(hook)
+		previous[i] = logger.ReplaceHooks(hooks)
+	}
+	return func() {
+		for i, logger := range loggers {
+			logger.ReplaceHooks(previous[i])
+		}
+		file.Close()
+	}, nil
+}
+
+// logFileHook writes the log entries formatted by formatter to writer
+type logFileHook struct {
+	writer    io.Writer
+	formatter log.Formatter
+}
+
+func (h *logFileHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *logFileHook) Fire(entry *log.Entry) error {
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(b)
+	return err
+}
+
+// newUpgradeCluster returns the UpgradeCluster configured by the command flags and the loaded cluster
+func (uc *upgradeCmd) newUpgradeCluster() *kubernetesupgrade.UpgradeCluster {
+	upgradeCluster := &kubernetesupgrade.UpgradeCluster{
+		Translator: &i18n.Translator{
+			Locale: uc.locale,
+		},
+		Logger:                          log.NewEntry(log.New()),
+		Client:                          uc.client,
+		StepTimeout:                     uc.timeout,
+		CordonDrainTimeout:              uc.cordonDrainTimeout,
+		PostDeleteWait:                  &uc.postDeleteWait,
+		SkipCapacityCheck:               uc.skipCapacityCheck,
+		MinFreeCapacityPercent:          uc.minFreeCapacityPercent,
+		DeploymentPollInterval:          uc.deploymentPollInterval,
+		ReuseExistingDeployment:         uc.reuseDeployment,
+		GenerateUpgradeReport:           uc.upgradeReport,
+		MaxDeploymentPolls:              uc.maxDeploymentPolls,
+		DeploymentMode:                  uc.deploymentMode,
+		PauseBetweenNodes:               uc.pauseCheckFile != "",
+		PauseCheckFile:                  uc.pauseCheckFile,
+		CleanupAfterUpgrade:             !uc.noCleanup,
+		OutputDirectory:                 filepath.Dir(uc.apiModelPath),
+		NodeGroupSize:                   uc.nodeGroupSize,
+		NodeGroupPauseAfter:             uc.nodeGroupPause,
+		EmitKubernetesEvents:            uc.emitKubernetesEvents,
+		PoolUpgradeConfigs:              uc.poolUpgradeConfigs,
+		InPlaceKubeletUpgrade:           uc.inPlace,
+		UpdateScaleSetInstances:         uc.updateVMSSInstances,
+		IgnoreNodesWithPodLabelSelector: uc.ignorePodsOnNodes,
+		SkipPools:                       uc.skipPools,
+		ConsecutiveFailureLimit:         uc.consecutiveFailureLimit,
+		NodeShutdownGracePeriod:         uc.nodeShutdownTimeout,
+		ScaleDownBeforeUpgrade:          uc.scaleDownBeforeUpgrade,
+		CanaryUpgrade:                   uc.canaryNode,
+		CanaryPauseForConfirmation:      uc.canaryNode && !uc.autoApproveCanary,
+		RegenerateCloudConfig:           uc.cloudConfigUpdate,
+		LiveEtcdMemberMigration:         uc.liveEtcdMemberMigration,
+		SSHPrivateKeyPath:               uc.linuxSSHPrivateKeyPath,
+	}
+
+	upgradeCluster.ClusterTopology = kubernetesupgrade.ClusterTopology{}
+	upgradeCluster.SubscriptionID = uc.getAuthArgs().SubscriptionID.String()
+	upgradeCluster.ResourceGroup = uc.resourceGroupName
+	upgradeCluster.DataModel = uc.containerService
+	upgradeCluster.APIModelVersion = uc.apiVersion
+	upgradeCluster.NameSuffix = uc.nameSuffix
+	upgradeCluster.AgentPoolsToUpgrade = uc.agentPoolsToUpgrade
+	upgradeCluster.Force = uc.force
+	upgradeCluster.ControlPlaneOnly = uc.controlPlaneOnly
+	upgradeCluster.OSOnlyUpgrade = uc.osOnly
+	upgradeCluster.ForceImageUpdate = uc.forceImageUpdate
+	upgradeCluster.ValidateExisting = uc.validateOnly
+	upgradeCluster.GenerateTemplateOnly = uc.generateARMTemplate
+	upgradeCluster.TemplateOutputDirectory = uc.templateOutputDirectory
+	upgradeCluster.Operator = uc.operator()
+	upgradeCluster.IsVMSSToBeUpgraded = isVMSSNameInAgentPoolsArray
+	upgradeCluster.CurrentVersion = uc.currentVersion
+	if upgradeCluster.CanaryPauseForConfirmation && isTerminal(os.Stdin) {
+		upgradeCluster.CanaryConfirm = promptCanaryConfirmation(os.Stdin, os.Stdout)
+	}
+	return upgradeCluster
+}
+
+// isTerminal returns true if f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// promptCanaryConfirmation returns a CanaryConfirm asking the operator on out to approve each canary node,
+// reading the answer from in
+func promptCanaryConfirmation(in io.Reader, out io.Writer) func(ctx context.Context, poolName, nodeName string) (bool, error) {
+	reader := bufio.NewReader(in)
+	return func(ctx context.Context, poolName, nodeName string) (bool, error) {
+		fmt.Fprintf(out, "Canary node %s of pool %s upgraded, continue the upgrade of the pool? [y/N]: ", nodeName, poolName)
+		answer, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || answer == "") {
+			return false, errors.Wrap(err, "reading the canary confirmation")
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+// getARMClient returns the client of the --replay-arm-calls fixture in replay mode, and the client authenticated by the
+// auth flags otherwise, recording its calls with --record-arm-calls
+func (uc *upgradeCmd) getARMClient() (armhelpers.AKSEngineClient, error) {
+	authArgs := uc.getAuthArgs()
+	if uc.replayARMCalls {
+		env, err := azure.EnvironmentFromName(authArgs.RawAzureEnvironment)
+		if err != nil {
+			return nil, err
+		}
+		if uc.armReplayer, err = armtest.NewReplayClientFromFixture(uc.armFixtureDir); err != nil {
+			return nil, err
+		}
+		return armhelpers.NewAzureClientWithSender(env, authArgs.SubscriptionID.String(), uc.armReplayer), nil
+	}
+	client, err := authArgs.getClient()
+	if err != nil {
+		return nil, err
+	}
+	if uc.recordARMCalls {
+		azureClient, ok := client.(*armhelpers.AzureClient)
+		if !ok {
+			return nil, errors.New("--record-arm-calls is not supported on Azure Stack Hub")
+		}
+		uc.armRecorder = armtest.NewRecordingClient(nil)
+		azureClient.SetSender(uc.armRecorder)
+	}
+	return client, nil
+}
+
+// finishARMTestMode saves the ARM calls recorded with --record-arm-calls, and reports the ARM calls
+// not found in the fixture or not replayed with --replay-arm-calls
+func (uc *upgradeCmd) finishARMTestMode() {
+	if uc.armRecorder != nil {
+		if err := uc.armRecorder.Save(uc.armFixtureDir); err != nil {
+			log.Errorf("Error saving the recorded ARM calls: %v", err)
+		} else {
+			log.Infof("Recorded %d ARM calls to %s", len(uc.armRecorder.Interactions()), uc.armFixtureDir)
+		}
+	}
+	if uc.armReplayer != nil {
+		for _, call := range uc.armReplayer.Unexpected() {
+			log.Errorf("No recorded ARM call for %s", call)
+		}
+		if pending := uc.armReplayer.Pending(); len(pending) > 0 {
+			log.Warnf("%d recorded ARM calls were not replayed", len(pending))
+		}
+	}
+}
+
+// getKubeConfig reads the --kubeconfig file, or generates a kubeconfig from the api model if none was given
+func (uc *upgradeCmd) getKubeConfig() (string, error) {
+	if uc.kubeconfigPath == "" {
+		kubeConfig, err := engine.GenerateKubeConfig(uc.containerService.Properties, uc.location)
+		if err != nil {
+			return "", errors.Wrap(err, "generating kubeconfig")
+		}
+		return kubeConfig, nil
+	}
+	path, err := filepath.Abs(uc.kubeconfigPath)
+	if err != nil {
+		return "", errors.Wrap(err, "reading --kubeconfig")
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "reading --kubeconfig")
+	}
+	return string(content), nil
+}
+
+// operator identifies who runs the upgrade: the service principal if one is used, the local user otherwise
+func (uc *upgradeCmd) operator() string {
+	if a := uc.getAuthArgs(); a.rawClientID != "" {
+		return a.rawClientID
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
 // isVMSSNameInAgentPoolsArray is a helper func to filter out any VMSS in the cluster resource group
 // that are not participating in the aks-engine-created Kubernetes cluster
 func isVMSSNameInAgentPoolsArray(vmss string, cs *api.ContainerService) bool {
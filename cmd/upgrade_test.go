@@ -4,13 +4,26 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/aks-engine/pkg/api/common"
 
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/armtest"
+	"github.com/Azure/aks-engine/pkg/operations/kubernetesupgrade"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
@@ -102,6 +115,288 @@ func TestUpgradeCommandShouldBeValidated(t *testing.T) {
 			expectedErr: errors.New("ambiguous, please specify only one of --api-model and --deployment-dir"),
 			name:        "NeedsNonAmbiguous",
 		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				postDeleteWait:      -time.Second,
+			},
+			expectedErr: errors.New("--post-delete-wait must not be negative"),
+			name:        "NeedsNonNegativePostDeleteWait",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:      "test",
+				apiModelPath:           "./not/used",
+				deploymentDirectory:    "",
+				upgradeVersion:         "1.9.0",
+				location:               "southcentralus",
+				deploymentPollInterval: -time.Second,
+			},
+			expectedErr: errors.New("--deployment-poll-interval must not be negative"),
+			name:        "NeedsNonNegativeDeploymentPollInterval",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				maxDeploymentPolls:  -1,
+			},
+			expectedErr: errors.New("--max-deployment-polls must not be negative"),
+			name:        "NeedsNonNegativeMaxDeploymentPolls",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				logMaxSizeBytes:     -1,
+			},
+			expectedErr: errors.New("--log-max-size-bytes must not be negative"),
+			name:        "NeedsNonNegativeLogMaxSizeBytes",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				autoApproveCanary:   true,
+			},
+			expectedErr: errors.New("--auto-approve-canary requires --canary-node"),
+			name:        "AutoApproveCanaryNeedsCanaryNode",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				recordARMCalls:      true,
+				replayARMCalls:      true,
+				armFixtureDir:       "./not/used",
+			},
+			expectedErr: errors.New("ambiguous, please specify only one of --record-arm-calls and --replay-arm-calls"),
+			name:        "RecordAndReplayARMCallsAreExclusive",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				replayARMCalls:      true,
+			},
+			expectedErr: errors.New("--record-arm-calls and --replay-arm-calls require --arm-fixture-dir"),
+			name:        "ReplayARMCallsNeedsFixtureDir",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				deploymentMode:      "Replace",
+			},
+			expectedErr: errors.New("--deployment-mode must be Incremental or Complete"),
+			name:        "NeedsValidDeploymentMode",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				slackWebhookURL:     "http://hooks.slack.com/services/T000/B000/XXXX",
+			},
+			expectedErr: errors.New("--notify-slack must be an https URL"),
+			name:        "NotifySlackNeedsHTTPSURL",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				slackChannel:        "#ops",
+			},
+			expectedErr: errors.New("--slack-channel and --slack-mention-on-failure require --notify-slack"),
+			name:        "SlackChannelNeedsNotifySlack",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				forceImageUpdate:    true,
+				validateOnly:        true,
+			},
+			expectedErr: errors.New("ambiguous, please specify only one of --force-image-update and --validate-only"),
+			name:        "ForceImageUpdateAndValidateOnlyAreExclusive",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				generateARMTemplate: true,
+				validateOnly:        true,
+			},
+			expectedErr: errors.New("ambiguous, please specify only one of --generate-arm-template and --validate-only"),
+			name:        "GenerateARMTemplateAndValidateOnlyAreExclusive",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:       "test",
+				apiModelPath:            "./not/used",
+				deploymentDirectory:     "",
+				upgradeVersion:          "1.9.0",
+				location:                "southcentralus",
+				templateOutputDirectory: "./not/used",
+			},
+			expectedErr: errors.New("--output-dir requires --generate-arm-template"),
+			name:        "OutputDirNeedsGenerateARMTemplate",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				nodeGroupSize:       -1,
+			},
+			expectedErr: errors.New("--node-group-size must not be negative"),
+			name:        "NeedsNonNegativeNodeGroupSize",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:       "test",
+				apiModelPath:            "./not/used",
+				deploymentDirectory:     "",
+				upgradeVersion:          "1.9.0",
+				location:                "southcentralus",
+				consecutiveFailureLimit: -1,
+			},
+			expectedErr: errors.New("--consecutive-failure-limit must not be negative"),
+			name:        "NeedsNonNegativeConsecutiveFailureLimit",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				nodeGroupPause:      -time.Hour,
+			},
+			expectedErr: errors.New("--node-group-pause must not be negative"),
+			name:        "NeedsNonNegativeNodeGroupPause",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				nodeGroupSize:       10,
+			},
+			expectedErr: errors.New("--node-group-size requires --node-group-pause or --pause-check-file to continue the upgrade after each group"),
+			name:        "NeedsNodeGroupContinueSignal",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:      "test",
+				apiModelPath:           "./not/used",
+				deploymentDirectory:    "",
+				upgradeVersion:         "1.9.0",
+				location:               "southcentralus",
+				minFreeCapacityPercent: 101,
+			},
+			expectedErr: errors.New("--min-free-capacity-percent must be between 0 and 100"),
+			name:        "NeedsValidMinFreeCapacityPercent",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				inPlace:             true,
+			},
+			expectedErr: errors.New("--in-place requires --linux-ssh-private-key"),
+			name:        "NeedsSSHPrivateKeyForInPlace",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:       "test",
+				apiModelPath:            "./not/used",
+				deploymentDirectory:     "",
+				upgradeVersion:          "1.9.0",
+				location:                "southcentralus",
+				liveEtcdMemberMigration: true,
+			},
+			expectedErr: errors.New("--live-etcd-member-migration requires --linux-ssh-private-key"),
+			name:        "NeedsSSHPrivateKeyForLiveEtcdMemberMigration",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				ignorePodsOnNodes:   "app in (",
+			},
+			expectedErr: errors.New("--ignore-pods-on-nodes must be a valid label selector: unable to parse requirement: found '', expected: ',', ')' or identifier"),
+			name:        "NeedsValidIgnorePodsOnNodesSelector",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:   "test",
+				apiModelPath:        "./not/used",
+				deploymentDirectory: "",
+				upgradeVersion:      "1.9.0",
+				location:            "southcentralus",
+				azureDevOps:         true,
+				watchMode:           true,
+			},
+			expectedErr: errors.New("ambiguous, please specify only one of --azure-devops and --watch"),
+			name:        "ShouldNotHaveBothAzureDevOpsAndWatch",
+		},
+		{
+			uc: &upgradeCmd{
+				resourceGroupName:      "test",
+				apiModelPath:           "./not/used",
+				deploymentDirectory:    "",
+				upgradeVersion:         "1.9.0",
+				location:               "southcentralus",
+				inPlace:                true,
+				linuxSSHPrivateKeyPath: "./not/there",
+			},
+			expectedErr: errors.New("specified --linux-ssh-private-key does not exist (./not/there)"),
+			name:        "NeedsExistingSSHPrivateKeyForInPlace",
+		},
 		{
 			uc: &upgradeCmd{
 				resourceGroupName:   "test",
@@ -143,6 +438,40 @@ func TestCreateUpgradeCommand(t *testing.T) {
 	g.Expect(command.Flags().Lookup("resource-group")).NotTo(BeNil())
 	g.Expect(command.Flags().Lookup("api-model")).NotTo(BeNil())
 	g.Expect(command.Flags().Lookup("upgrade-version")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("post-delete-wait")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("min-free-capacity-percent")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("deployment-mode")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("skip-capacity-check")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("os-only")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("force-image-update")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("validate-only")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("generate-arm-template")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("output-dir")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("concurrency-file")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("skip-pools")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("node-shutdown-timeout")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("in-place")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("ignore-pods-on-nodes")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("scale-down-before-upgrade")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("canary-node")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("auto-approve-canary")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("cloud-config-update")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("log-file")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("log-max-size-bytes")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("azure-devops")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("notify-slack")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("slack-channel")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("slack-mention-on-failure")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("reuse-deployment")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("upgrade-report")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("emit-metrics-prometheus")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("metrics-address")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("record-arm-calls")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("replay-arm-calls")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("arm-fixture-dir")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("live-etcd-member-migration")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("linux-ssh-private-key")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("no-cleanup")).NotTo(BeNil())
 
 	command.SetArgs([]string{})
 	if err := command.Execute(); err == nil {
@@ -150,6 +479,47 @@ func TestCreateUpgradeCommand(t *testing.T) {
 	}
 }
 
+func TestCreateUpgradeExportStateCommand(t *testing.T) {
+	t.Parallel()
+
+	g := NewGomegaWithT(t)
+	command, _, err := newUpgradeCmd().Find([]string{upgradeExportStateName})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(command.Use).Should(Equal(upgradeExportStateName))
+	g.Expect(command.Short).Should(Equal(upgradeExportStateShortDescription))
+	g.Expect(command.Long).Should(Equal(upgradeExportStateLongDescription))
+	g.Expect(command.Flags().Lookup("output")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("api-model")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("upgrade-version")).NotTo(BeNil())
+
+	err = command.RunE(command, []string{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("--output must be specified"))
+}
+
+func TestCreateUpgradeVerifyCommand(t *testing.T) {
+	t.Parallel()
+
+	g := NewGomegaWithT(t)
+	command, _, err := newUpgradeCmd().Find([]string{upgradeVerifyName})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(command.Use).Should(Equal(upgradeVerifyName))
+	g.Expect(command.Short).Should(Equal(upgradeVerifyShortDescription))
+	g.Expect(command.Long).Should(Equal(upgradeVerifyLongDescription))
+	g.Expect(command.Flags().Lookup("location")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("resource-group")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("api-model")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("upgrade-version")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("kubeconfig")).NotTo(BeNil())
+	g.Expect(command.Flags().Lookup("control-plane-only")).NotTo(BeNil())
+
+	if err := command.RunE(command, []string{}); err == nil {
+		t.Fatalf("expected an error when calling upgrade verify with no arguments")
+	}
+}
+
 func TestUpgradeShouldFailForSameVersion(t *testing.T) {
 	versionToUse := common.RationalizeReleaseAndVersion(api.Kubernetes, "", "", false, false, false)
 	setupValidVersions(map[string]bool{
@@ -298,6 +668,166 @@ func TestUpgradeForceDowngradeShouldSetVersionOnContainerService(t *testing.T) {
 	resetValidVersions()
 }
 
+func TestUpgradeOSOnlyShouldKeepCurrentVersion(t *testing.T) {
+	setupValidVersions(map[string]bool{
+		"1.10.13": false,
+	})
+	g := NewGomegaWithT(t)
+	upgradeCmd := &upgradeCmd{
+		resourceGroupName:           "rg",
+		apiModelPath:                "./not/used",
+		location:                    "centralus",
+		timeoutInMinutes:            60,
+		cordonDrainTimeoutInMinutes: 60,
+		osOnly:                      true,
+
+		client: &armhelpers.MockAKSEngineClient{},
+	}
+
+	containerServiceMock := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
+	containerServiceMock.Location = "centralus"
+	upgradeCmd.containerService = containerServiceMock
+	err := upgradeCmd.initialize()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(upgradeCmd.upgradeVersion).To(Equal("1.10.13"))
+	g.Expect(upgradeCmd.containerService.Properties.OrchestratorProfile.OrchestratorVersion).To(Equal("1.10.13"))
+
+	upgradeCmd.upgradeVersion = "1.10.12"
+	err = upgradeCmd.initialize()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("--upgrade-version must match the current Kubernetes version 1.10.13 when --os-only is set"))
+	resetValidVersions()
+}
+
+func TestUpgradeValidateOnlyShouldDefaultToCurrentVersion(t *testing.T) {
+	setupValidVersions(map[string]bool{
+		"1.10.13": false,
+	})
+	g := NewGomegaWithT(t)
+	upgradeCmd := &upgradeCmd{
+		resourceGroupName:           "rg",
+		apiModelPath:                "./not/used",
+		location:                    "centralus",
+		timeoutInMinutes:            60,
+		cordonDrainTimeoutInMinutes: 60,
+		validateOnly:                true,
+
+		client: &armhelpers.MockAKSEngineClient{},
+	}
+
+	containerServiceMock := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
+	containerServiceMock.Location = "centralus"
+	upgradeCmd.containerService = containerServiceMock
+	err := upgradeCmd.initialize()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(upgradeCmd.upgradeVersion).To(Equal("1.10.13"))
+	g.Expect(upgradeCmd.containerService.Properties.OrchestratorProfile.OrchestratorVersion).To(Equal("1.10.13"))
+	resetValidVersions()
+}
+
+func TestUpgradeLogToFileShouldWriteJSONEntries(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "upgradelog")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	upgradeCmd := &upgradeCmd{
+		logFile:         filepath.Join(dir, "upgrade.log"),
+		logMaxSizeBytes: defaultUpgradeLogMaxSizeBytes,
+	}
+	upgradeCluster := &kubernetesupgrade.UpgradeCluster{Logger: log.NewEntry(log.New())}
+	upgradeCluster.Logger.Logger.Out = ioutil.Discard
+
+	closeLogFile, err := upgradeCmd.logToFile(upgradeCluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	upgradeCluster.Logger.WithField("node", "k8s-master-12345678-0").Info("Upgrading Master VM")
+	closeLogFile()
+	upgradeCluster.Logger.Info("not written once the file is closed")
+
+	b, err := ioutil.ReadFile(upgradeCmd.logFile)
+	g.Expect(err).NotTo(HaveOccurred())
+	var entry map[string]interface{}
+	g.Expect(json.Unmarshal(b, &entry)).To(Succeed())
+	g.Expect(entry).To(HaveKeyWithValue("level", "info"))
+	g.Expect(entry).To(HaveKeyWithValue("msg", "Upgrading Master VM"))
+	g.Expect(entry).To(HaveKeyWithValue("node", "k8s-master-12345678-0"))
+	g.Expect(entry).To(HaveKey("time"))
+	g.Expect(upgradeCluster.Logger.Logger.Hooks).To(BeEmpty())
+}
+
+func TestUpgradeServeMetricsShouldReportUpgradeEvents(t *testing.T) {
+	g := NewGomegaWithT(t)
+	upgradeCmd := &upgradeCmd{emitMetricsPrometheus: true, metricsAddress: "127.0.0.1:0"}
+	upgradeCluster := &kubernetesupgrade.UpgradeCluster{}
+
+	stopMetrics, err := upgradeCmd.serveMetrics(upgradeCluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer stopMetrics()
+	g.Expect(upgradeCluster.Reporters).To(HaveLen(1))
+	metrics := upgradeCluster.Reporters[0].(*kubernetesupgrade.MetricsServer)
+	g.Expect(metrics.Report(kubernetesupgrade.UpgradeEvent{Type: kubernetesupgrade.NodeUpgradedEvent})).To(Succeed())
+
+	resp, err := http.Get("http://" + metrics.Addr() + "/metrics")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(ContainSubstring("nodes_upgraded_total 1"))
+}
+
+func TestPromptCanaryConfirmation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var out bytes.Buffer
+	confirm := promptCanaryConfirmation(strings.NewReader("y\nno\nYes"), &out)
+
+	approved, err := confirm(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	g.Expect(out.String()).To(ContainSubstring("Canary node k8s-agentpool1-12345678-0 of pool agentpool1 upgraded"))
+	approved, err = confirm(context.Background(), "agentpool1", "k8s-agentpool1-12345678-1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(approved).To(BeFalse())
+	approved, err = confirm(context.Background(), "agentpool2", "k8s-agentpool2-12345678-0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	_, err = confirm(context.Background(), "agentpool3", "k8s-agentpool3-12345678-0")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestUpgradeReplayARMCallsShouldServeTheFixture(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "armfixture")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	resourceGroupURL := "https://management.azure.com/subscriptions/cc6b141e-6afc-4786-9bf6-e3b9a5601460/resourcegroups/test?api-version=2018-05-01"
+	g.Expect(armtest.WriteFixture(dir, []armtest.Interaction{
+		{Method: "GET", URL: resourceGroupURL, StatusCode: 200, ResponseBody: `{"name": "test", "location": "southcentralus", "tags": {"owner": "test"}}`},
+		{Method: "PUT", URL: resourceGroupURL, StatusCode: 200, ResponseBody: `{"name": "test", "location": "southcentralus", "tags": {"owner": "test"}}`},
+	})).To(Succeed())
+	authArgs := &authArgs{RawAzureEnvironment: "AzurePublicCloud"}
+	authArgs.SubscriptionID, err = uuid.Parse("cc6b141e-6afc-4786-9bf6-e3b9a5601460")
+	g.Expect(err).NotTo(HaveOccurred())
+	upgradeCmd := &upgradeCmd{
+		authProvider:   authArgs,
+		replayARMCalls: true,
+		armFixtureDir:  dir,
+	}
+
+	client, err := upgradeCmd.getARMClient()
+	g.Expect(err).NotTo(HaveOccurred())
+	group, err := client.EnsureResourceGroup(context.Background(), "test", "southcentralus", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*group.Tags["owner"]).To(Equal("test"))
+	g.Expect(upgradeCmd.armReplayer.Pending()).To(BeEmpty())
+	g.Expect(upgradeCmd.armReplayer.Unexpected()).To(BeEmpty())
+	_, err = client.EnsureResourceGroup(context.Background(), "test", "southcentralus", nil)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(upgradeCmd.armReplayer.Unexpected()).To(HaveLen(2))
+
+	upgradeCmd.armFixtureDir = filepath.Join(dir, "missing")
+	_, err = upgradeCmd.getARMClient()
+	g.Expect(err).To(HaveOccurred())
+}
+
 func TestIsVMSSNameInAgentPoolsArray(t *testing.T) {
 	cases := []struct {
 		vmssName string
@@ -36,6 +36,7 @@ require (
 	github.com/onsi/ginkgo v1.15.0
 	github.com/onsi/gomega v1.10.1
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/satori/go.uuid v1.2.0 // indirect
 	github.com/sirupsen/logrus v1.8.0
 	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337 // indirect
@@ -51,4 +52,5 @@ require (
 	k8s.io/api v0.16.15
 	k8s.io/apimachinery v0.16.15
 	k8s.io/client-go v0.16.15
+	sigs.k8s.io/yaml v1.1.0
 )
@@ -920,19 +920,10 @@ type DefaultCertParams struct {
 	PkiKeySize int
 }
 
-// SetDefaultCerts generates and sets defaults for the container certificateProfile, returns true if certs are generated
-func (cs *ContainerService) SetDefaultCerts(params DefaultCertParams) (bool, []net.IP, error) {
+// GetAPIServerCertificateSANs returns the DNS names and IP addresses aks-engine generates the API server certificate for,
+// in addition to the in-cluster names of the kubernetes service added by helpers.CreatePki
+func (cs *ContainerService) GetAPIServerCertificateSANs() ([]string, []net.IP, error) {
 	p := cs.Properties
-	if p.MasterProfile == nil {
-		return false, nil, nil
-	}
-
-	provided := certsAlreadyPresent(p.CertificateProfile, p.MasterProfile.Count)
-
-	if areAllTrue(provided) {
-		return false, nil, nil
-	}
-
 	var azureProdFQDNs []string
 	for _, location := range cs.GetLocations() {
 		azureProdFQDNs = append(azureProdFQDNs, FormatProdFQDNByLocation(p.MasterProfile.DNSPrefix, location, p.GetCustomCloudName()))
@@ -944,7 +935,7 @@ func (cs *ContainerService) SetDefaultCerts(params DefaultCertParams) (bool, []n
 	localhostIP := net.ParseIP("127.0.0.1").To4()
 
 	if firstMasterIP == nil {
-		return false, nil, errors.Errorf("MasterProfile.FirstConsecutiveStaticIP '%s' is an invalid IP address", p.MasterProfile.FirstConsecutiveStaticIP)
+		return nil, nil, errors.Errorf("MasterProfile.FirstConsecutiveStaticIP '%s' is an invalid IP address", p.MasterProfile.FirstConsecutiveStaticIP)
 	}
 
 	ips := []net.IP{firstMasterIP, localhostIP}
@@ -970,6 +961,44 @@ func (cs *ContainerService) SetDefaultCerts(params DefaultCertParams) (bool, []n
 		binary.BigEndian.PutUint32(ip, newAddr)
 		ips = append(ips, ip)
 	}
+
+	serviceCIDR := p.OrchestratorProfile.KubernetesConfig.ServiceCIDR
+
+	// all validation for dual stack done with primary service cidr as that is considered
+	// the default ip family for cluster.
+	if cs.Properties.FeatureFlags.IsFeatureEnabled("EnableIPv6DualStack") {
+		// split service cidrs
+		serviceCIDRs := strings.Split(serviceCIDR, ",")
+		serviceCIDR = serviceCIDRs[0]
+	}
+
+	cidrFirstIP, err := common.CidrStringFirstIP(serviceCIDR)
+	if err != nil {
+		return nil, nil, err
+	}
+	ips = append(ips, cidrFirstIP)
+
+	return masterExtraFQDNs, ips, nil
+}
+
+// SetDefaultCerts generates and sets defaults for the container certificateProfile, returns true if certs are generated
+func (cs *ContainerService) SetDefaultCerts(params DefaultCertParams) (bool, []net.IP, error) {
+	p := cs.Properties
+	if p.MasterProfile == nil {
+		return false, nil, nil
+	}
+
+	provided := certsAlreadyPresent(p.CertificateProfile, p.MasterProfile.Count)
+
+	if areAllTrue(provided) {
+		return false, nil, nil
+	}
+
+	masterExtraFQDNs, ips, err := cs.GetAPIServerCertificateSANs()
+	if err != nil {
+		return false, ips, err
+	}
+
 	if p.CertificateProfile == nil {
 		p.CertificateProfile = &CertificateProfile{}
 	}
@@ -994,22 +1023,6 @@ func (cs *ContainerService) SetDefaultCerts(params DefaultCertParams) (bool, []n
 		p.CertificateProfile.CaPrivateKey = caPair.PrivateKeyPem
 	}
 
-	serviceCIDR := p.OrchestratorProfile.KubernetesConfig.ServiceCIDR
-
-	// all validation for dual stack done with primary service cidr as that is considered
-	// the default ip family for cluster.
-	if cs.Properties.FeatureFlags.IsFeatureEnabled("EnableIPv6DualStack") {
-		// split service cidrs
-		serviceCIDRs := strings.Split(serviceCIDR, ",")
-		serviceCIDR = serviceCIDRs[0]
-	}
-
-	cidrFirstIP, err := common.CidrStringFirstIP(serviceCIDR)
-	if err != nil {
-		return false, ips, err
-	}
-	ips = append(ips, cidrFirstIP)
-
 	pkiParams := helpers.PkiParams{}
 	pkiParams.CaPair = caPair
 	pkiParams.ClusterDomain = DefaultKubernetesClusterDomain
@@ -4,8 +4,10 @@
 package api
 
 import (
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/http"
@@ -3890,6 +3892,67 @@ func TestSetCertDefaultsVMSS(t *testing.T) {
 	}
 }
 
+func TestGetAPIServerCertificateSANs(t *testing.T) {
+	cs := &ContainerService{
+		Properties: &Properties{
+			ServicePrincipalProfile: &ServicePrincipalProfile{
+				ClientID: "barClientID",
+				Secret:   "bazSecret",
+			},
+			MasterProfile: &MasterProfile{
+				Count:           3,
+				DNSPrefix:       "myprefix1",
+				VMSize:          "Standard_DS2_v2",
+				SubjectAltNames: []string{"api.contoso.com"},
+			},
+			OrchestratorProfile: &OrchestratorProfile{
+				OrchestratorType:    Kubernetes,
+				OrchestratorVersion: "1.10.2",
+				KubernetesConfig: &KubernetesConfig{
+					NetworkPlugin: NetworkPluginAzure,
+				},
+			},
+		},
+	}
+
+	cs.setOrchestratorDefaults(false, false)
+	cs.Properties.setMasterProfileDefaults()
+	if _, _, err := cs.SetDefaultCerts(DefaultCertParams{
+		PkiKeySize: helpers.DefaultPkiKeySize,
+	}); err != nil {
+		t.Fatalf("unexpected error thrown while executing SetDefaultCerts %s", err.Error())
+	}
+
+	fqdns, ips, err := cs.GetAPIServerCertificateSANs()
+	if err != nil {
+		t.Fatalf("unexpected error thrown while executing GetAPIServerCertificateSANs %s", err.Error())
+	}
+
+	// the SANs must be those of the generated API server certificate
+	block, _ := pem.Decode([]byte(cs.Properties.CertificateProfile.APIServerCertificate))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the API server certificate %s", err.Error())
+	}
+	expectedFQDNs := append(fqdns, helpers.KubernetesServiceFQDNs(DefaultKubernetesClusterDomain)...)
+	if !reflect.DeepEqual(cert.DNSNames, expectedFQDNs) {
+		t.Errorf("expected API server certificate DNS names %v, actual %v", expectedFQDNs, cert.DNSNames)
+	}
+	if len(cert.IPAddresses) != len(ips) {
+		t.Fatalf("expected API server certificate IP addresses %v, actual %v", ips, cert.IPAddresses)
+	}
+	for i, ip := range ips {
+		if !ip.Equal(cert.IPAddresses[i]) {
+			t.Errorf("expected API server certificate IP addresses %v, actual %v", ips, cert.IPAddresses)
+		}
+	}
+
+	cs.Properties.MasterProfile.FirstConsecutiveStaticIP = "10.240.255"
+	if _, _, err = cs.GetAPIServerCertificateSANs(); err == nil {
+		t.Error("expected an error for an invalid first consecutive static IP")
+	}
+}
+
 func TestProxyModeDefaults(t *testing.T) {
 	// Test that default is what we expect
 	mockCS := getMockBaseContainerService("1.10.12")
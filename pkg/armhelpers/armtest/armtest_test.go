@@ -0,0 +1,114 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armtest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest/azure"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testSubscriptionID = "cc6b141e-6afc-4786-9bf6-e3b9a5601460"
+	testVMPath         = "/subscriptions/" + testSubscriptionID + "/resourceGroups/TestRg/providers/Microsoft.Compute/virtualMachines/k8s-master-12345678-0"
+)
+
+func newTestEnvironment(url string) azure.Environment {
+	env := azure.PublicCloud
+	env.ResourceManagerEndpoint = url + "/"
+	return env
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "armtest")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		g.Expect(r.URL.Path).To(Equal(testVMPath))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name": "k8s-master-12345678-0", "location": "westus2"}`))
+	}))
+	defer server.Close()
+	env := newTestEnvironment(server.URL)
+
+	recorder := NewRecordingClient(nil)
+	client := armhelpers.NewAzureClientWithSender(env, testSubscriptionID, recorder)
+	vm, err := client.GetVirtualMachine(context.Background(), "TestRg", "k8s-master-12345678-0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*vm.Location).To(Equal("westus2"))
+	g.Expect(recorder.Interactions()).To(HaveLen(1))
+	g.Expect(recorder.Interactions()[0].Method).To(Equal(http.MethodGet))
+	g.Expect(recorder.Interactions()[0].StatusCode).To(Equal(http.StatusOK))
+	g.Expect(recorder.Save(dir)).To(Succeed())
+
+	replayer, err := NewReplayClientFromFixture(dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	client = armhelpers.NewAzureClientWithSender(env, testSubscriptionID, replayer)
+	vm, err = client.GetVirtualMachine(context.Background(), "TestRg", "k8s-master-12345678-0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*vm.Name).To(Equal("k8s-master-12345678-0"))
+	g.Expect(*vm.Location).To(Equal("westus2"))
+	g.Expect(calls).To(Equal(1))
+	g.Expect(replayer.Pending()).To(BeEmpty())
+	g.Expect(replayer.Unexpected()).To(BeEmpty())
+
+	_, err = client.GetVirtualMachine(context.Background(), "TestRg", "k8s-master-12345678-0")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("NoRecordedCall"))
+	g.Expect(replayer.Unexpected()).To(HaveLen(1))
+	g.Expect(calls).To(Equal(1))
+}
+
+func TestReplayServesRepeatedCallsInOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	url := "https://management.azure.com" + testVMPath + "?api-version=2019-12-01"
+	replayer := NewReplayClient([]Interaction{
+		{Method: http.MethodGet, URL: url, StatusCode: http.StatusOK, ResponseBody: "first", ResponseHeaders: http.Header{"Retry-After": []string{"30"}}},
+		{Method: http.MethodDelete, URL: url, StatusCode: http.StatusAccepted},
+		{Method: http.MethodGet, URL: url, StatusCode: http.StatusNotFound, ResponseBody: "second"},
+	})
+
+	for _, expected := range []struct {
+		method     string
+		statusCode int
+		body       string
+	}{
+		{http.MethodGet, http.StatusOK, "first"},
+		{http.MethodGet, http.StatusNotFound, "second"},
+		{http.MethodGet, http.StatusNotImplemented, ""},
+	} {
+		req, err := http.NewRequest(expected.method, url, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		resp, err := replayer.Do(req)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(resp.StatusCode).To(Equal(expected.statusCode))
+		g.Expect(resp.Header.Get("Retry-After")).To(Equal("0"))
+		if expected.body != "" {
+			b, err := ioutil.ReadAll(resp.Body)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(string(b)).To(Equal(expected.body))
+		}
+	}
+	g.Expect(replayer.Pending()).To(HaveLen(1))
+	g.Expect(replayer.Pending()[0].Method).To(Equal(http.MethodDelete))
+	g.Expect(replayer.Unexpected()).To(Equal([]string{http.MethodGet + " " + url}))
+}
+
+func TestReadFixtureMissing(t *testing.T) {
+	g := NewGomegaWithT(t)
+	_, err := ReadFixture("/does/not/exist")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("reading ARM fixture /does/not/exist/" + FixtureFileName))
+}
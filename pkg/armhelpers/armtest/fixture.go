@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+// Package armtest records the ARM API calls of an AzureClient to a JSON fixture and replays them,
+// for deterministic tests of the operations calling ARM without Azure credentials.
+package armtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// FixtureFileName is the file of the fixture directory holding the recorded ARM API calls
+const FixtureFileName = "arm-calls.json"
+
+// Interaction is a recorded ARM API call. The request headers and body are not recorded,
+// as they carry the credentials and the secrets of the deployment parameters.
+type Interaction struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	StatusCode      int         `json:"statusCode"`
+	ResponseHeaders http.Header `json:"responseHeaders,omitempty"`
+	ResponseBody    string      `json:"responseBody,omitempty"`
+}
+
+// ReadFixture reads the interactions recorded in the FixtureFileName file of dir
+func ReadFixture(dir string) ([]Interaction, error) {
+	path := filepath.Join(dir, FixtureFileName)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading ARM fixture %s", path)
+	}
+	var interactions []Interaction
+	if err = json.Unmarshal(b, &interactions); err != nil {
+		return nil, errors.Wrapf(err, "parsing ARM fixture %s", path)
+	}
+	return interactions, nil
+}
+
+// WriteFixture writes the interactions to the FixtureFileName file of dir, creating dir if needed
+func WriteFixture(dir string, interactions []Interaction) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "creating ARM fixture directory %s", dir)
+	}
+	if interactions == nil {
+		interactions = []Interaction{}
+	}
+	b, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "serializing ARM fixture")
+	}
+	path := filepath.Join(dir, FixtureFileName)
+	if err = ioutil.WriteFile(path, b, 0600); err != nil {
+		return errors.Wrapf(err, "writing ARM fixture %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+)
+
+// RecordingClient is an autorest.Sender sending the requests through Sender and recording each call,
+// Save writes the recorded calls as a fixture ReplayClient serves
+type RecordingClient struct {
+	Sender autorest.Sender
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecordingClient returns a RecordingClient sending the requests through sender, the default autorest sender if nil
+func NewRecordingClient(sender autorest.Sender) *RecordingClient {
+	if sender == nil {
+		sender = autorest.CreateSender()
+	}
+	return &RecordingClient{Sender: sender}
+}
+
+// Do sends the request and records the response. Calls failing without a response are not recorded.
+func (c *RecordingClient) Do(r *http.Request) (*http.Response, error) {
+	resp, err := c.Sender.Do(r)
+	if resp == nil {
+		return resp, err
+	}
+	var body []byte
+	if resp.Body != nil {
+		var readErr error
+		body, readErr = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, errors.Wrapf(readErr, "reading the response of %s %s", r.Method, r.URL)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	c.mu.Lock()
+	c.interactions = append(c.interactions, Interaction{
+		Method:          r.Method,
+		URL:             r.URL.String(),
+		StatusCode:      resp.StatusCode,
+		ResponseHeaders: resp.Header.Clone(),
+		ResponseBody:    string(body),
+	})
+	c.mu.Unlock()
+	return resp, err
+}
+
+// Interactions returns the calls recorded so far
+func (c *RecordingClient) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// Save writes the calls recorded so far to the fixture of dir
+func (c *RecordingClient) Save(dir string) error {
+	return WriteFixture(dir, c.Interactions())
+}
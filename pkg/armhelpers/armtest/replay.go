@@ -0,0 +1,111 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// ReplayClient is an autorest.Sender serving the responses recorded by a RecordingClient without calling ARM.
+// Each request is answered by the first interaction of the same method and URL not replayed yet, so that
+// repeated calls, e.g. the polling of a deployment, get the recorded responses in order.
+type ReplayClient struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+	unexpected   []string
+}
+
+// NewReplayClient returns a ReplayClient serving the interactions
+func NewReplayClient(interactions []Interaction) *ReplayClient {
+	return &ReplayClient{
+		interactions: interactions,
+		replayed:     make([]bool, len(interactions)),
+	}
+}
+
+// NewReplayClientFromFixture returns a ReplayClient serving the fixture of dir
+func NewReplayClientFromFixture(dir string) (*ReplayClient, error) {
+	interactions, err := ReadFixture(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayClient(interactions), nil
+}
+
+// Do returns the recorded response of the request. A request no recorded call is left for is answered with
+// a 501 Not Implemented ARM error, which the ARM clients do not retry, and is listed by Unexpected.
+// The Retry-After header of the responses is set to 0 so that the replayed operations do not wait.
+func (c *ReplayClient) Do(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	url := r.URL.String()
+	for i, interaction := range c.interactions {
+		if c.replayed[i] || !strings.EqualFold(interaction.Method, r.Method) || interaction.URL != url {
+			continue
+		}
+		c.replayed[i] = true
+		return newResponse(r, interaction), nil
+	}
+	c.unexpected = append(c.unexpected, r.Method+" "+url)
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]string{
+			"code":    "NoRecordedCall",
+			"message": fmt.Sprintf("no recorded ARM call left for %s %s", r.Method, url),
+		},
+	})
+	return newResponse(r, Interaction{
+		StatusCode:      http.StatusNotImplemented,
+		ResponseHeaders: http.Header{"Content-Type": []string{"application/json"}},
+		ResponseBody:    string(body),
+	}), nil
+}
+
+// newResponse returns the recorded response of interaction to the request
+func newResponse(r *http.Request, interaction Interaction) *http.Response {
+	header := interaction.ResponseHeaders.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(autorest.HeaderRetryAfter, "0")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(interaction.ResponseBody)),
+		ContentLength: int64(len(interaction.ResponseBody)),
+		Request:       r,
+	}
+}
+
+// Unexpected returns the "<method> <url>" of the requests no recorded call was left for
+func (c *ReplayClient) Unexpected() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.unexpected...)
+}
+
+// Pending returns the recorded calls not replayed yet, a replay of the recorded operation is complete if empty
+func (c *ReplayClient) Pending() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var pending []Interaction
+	for i, interaction := range c.interactions {
+		if !c.replayed[i] {
+			pending = append(pending, interaction)
+		}
+	}
+	return pending
+}
@@ -75,6 +75,13 @@ type AzureClient struct {
 	availabilitySetsClient          compute.AvailabilitySetsClient
 	workspacesClient                operationalinsights.WorkspacesClient
 	virtualMachineImagesClient      compute.VirtualMachineImagesClient
+	subnetsClient                   network.SubnetsClient
+	virtualNetworkPeeringsClient    network.VirtualNetworkPeeringsClient
+	dedicatedHostsClient            compute.DedicatedHostsClient
+	dedicatedHostGroupsClient       compute.DedicatedHostGroupsClient
+	proximityPlacementGroupsClient  compute.ProximityPlacementGroupsClient
+	diskEncryptionSetsClient        compute.DiskEncryptionSetsClient
+	usageClient                     compute.UsageClient
 
 	applicationsClient      graphrbac.ApplicationsClient
 	servicePrincipalsClient graphrbac.ServicePrincipalsClient
@@ -352,6 +359,14 @@ func getAksEngineClientID(envName string) string {
 	}
 }
 
+// NewAzureClientWithSender returns an AzureClient sending its unauthenticated requests through sender,
+// e.g. an armtest.ReplayClient serving recorded ARM API calls
+func NewAzureClientWithSender(env azure.Environment, subscriptionID string, sender autorest.Sender) *AzureClient {
+	client := getClient(env, subscriptionID, "", autorest.NullAuthorizer{}, autorest.NullAuthorizer{})
+	client.SetSender(sender)
+	return client
+}
+
 func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthorizer autorest.Authorizer, graphAuthorizer autorest.Authorizer) *AzureClient {
 	c := &AzureClient{
 		environment:    env,
@@ -376,6 +391,13 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 		availabilitySetsClient:          compute.NewAvailabilitySetsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
 		workspacesClient:                operationalinsights.NewWorkspacesClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
 		virtualMachineImagesClient:      compute.NewVirtualMachineImagesClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		subnetsClient:                   network.NewSubnetsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		virtualNetworkPeeringsClient:    network.NewVirtualNetworkPeeringsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		dedicatedHostsClient:            compute.NewDedicatedHostsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		dedicatedHostGroupsClient:       compute.NewDedicatedHostGroupsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		proximityPlacementGroupsClient:  compute.NewProximityPlacementGroupsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		diskEncryptionSetsClient:        compute.NewDiskEncryptionSetsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		usageClient:                     compute.NewUsageClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
 
 		applicationsClient:      graphrbac.NewApplicationsClientWithBaseURI(env.GraphEndpoint, tenantID),
 		servicePrincipalsClient: graphrbac.NewServicePrincipalsClientWithBaseURI(env.GraphEndpoint, tenantID),
@@ -383,6 +405,8 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 
 	c.authorizationClient.Authorizer = armAuthorizer
 	c.availabilitySetsClient.Authorizer = armAuthorizer
+	c.dedicatedHostGroupsClient.Authorizer = armAuthorizer
+	c.dedicatedHostsClient.Authorizer = armAuthorizer
 	c.deploymentOperationsClient.Authorizer = armAuthorizer
 	c.deploymentsClient.Authorizer = armAuthorizer
 	c.disksClient.Authorizer = armAuthorizer
@@ -390,15 +414,20 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 	c.interfacesClient.Authorizer = armAuthorizer
 	c.msiClient.Authorizer = armAuthorizer
 	c.providersClient.Authorizer = armAuthorizer
+	c.proximityPlacementGroupsClient.Authorizer = armAuthorizer
+	c.diskEncryptionSetsClient.Authorizer = armAuthorizer
 	c.resourcesClient.Authorizer = armAuthorizer
 	c.resourceSkusClient.Authorizer = armAuthorizer
 	c.storageAccountsClient.Authorizer = armAuthorizer
+	c.subnetsClient.Authorizer = armAuthorizer
 	c.subscriptionsClient.Authorizer = armAuthorizer
+	c.usageClient.Authorizer = armAuthorizer
 	c.virtualMachineExtensionsClient.Authorizer = armAuthorizer
 	c.virtualMachineImagesClient.Authorizer = armAuthorizer
 	c.virtualMachineScaleSetsClient.Authorizer = armAuthorizer
 	c.virtualMachineScaleSetVMsClient.Authorizer = armAuthorizer
 	c.virtualMachinesClient.Authorizer = armAuthorizer
+	c.virtualNetworkPeeringsClient.Authorizer = armAuthorizer
 	c.workspacesClient.Authorizer = armAuthorizer
 
 	c.applicationsClient.Authorizer = graphAuthorizer
@@ -411,11 +440,17 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 	c.applicationsClient.PollingDuration = DefaultARMOperationTimeout
 	c.authorizationClient.PollingDuration = DefaultARMOperationTimeout
 	c.availabilitySetsClient.PollingDuration = DefaultARMOperationTimeout
+	c.dedicatedHostGroupsClient.PollingDuration = DefaultARMOperationTimeout
+	c.dedicatedHostsClient.PollingDuration = DefaultARMOperationTimeout
 	c.deploymentOperationsClient.PollingDuration = DefaultARMOperationTimeout
 	c.deploymentsClient.PollingDuration = DefaultARMOperationTimeout
 	c.disksClient.PollingDuration = DefaultARMOperationTimeout
 	c.groupsClient.PollingDuration = DefaultARMOperationTimeout
+	c.proximityPlacementGroupsClient.PollingDuration = DefaultARMOperationTimeout
+	c.diskEncryptionSetsClient.PollingDuration = DefaultARMOperationTimeout
+	c.subnetsClient.PollingDuration = DefaultARMOperationTimeout
 	c.subscriptionsClient.PollingDuration = DefaultARMOperationTimeout
+	c.usageClient.PollingDuration = DefaultARMOperationTimeout
 	c.interfacesClient.PollingDuration = DefaultARMOperationTimeout
 	c.msiClient.PollingDuration = DefaultARMOperationTimeout
 	c.providersClient.PollingDuration = DefaultARMOperationTimeout
@@ -430,6 +465,8 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 	c.virtualMachinesClient.PollingDuration = DefaultARMOperationTimeout
 	c.workspacesClient.PollingDuration = DefaultARMOperationTimeout
 
+	c.deploymentsClient.RequestInspector = CorrelationIDDecorator()
+
 	return c
 }
 
@@ -502,6 +539,8 @@ func (az *AzureClient) AddAcceptLanguages(languages []string) {
 	az.applicationsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.authorizationClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.availabilitySetsClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.dedicatedHostGroupsClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.dedicatedHostsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.deploymentOperationsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.deploymentsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.disksClient.Client.RequestInspector = az.addAcceptLanguages()
@@ -509,16 +548,21 @@ func (az *AzureClient) AddAcceptLanguages(languages []string) {
 	az.interfacesClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.msiClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.providersClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.proximityPlacementGroupsClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.diskEncryptionSetsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.resourcesClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.resourceSkusClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.servicePrincipalsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.storageAccountsClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.subnetsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.subscriptionsClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.usageClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.virtualMachineExtensionsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.virtualMachineImagesClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.virtualMachineScaleSetsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.virtualMachineScaleSetVMsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.virtualMachinesClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.virtualNetworkPeeringsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.workspacesClient.Client.RequestInspector = az.addAcceptLanguages()
 }
 
@@ -534,6 +578,7 @@ func (az *AzureClient) addAcceptLanguages() autorest.PrepareDecorator {
 					r.Header.Add("Accept-Language", language)
 				}
 			}
+			SetCorrelationID(r)
 			return r, nil
 		})
 	}
@@ -558,6 +603,7 @@ func (az *AzureClient) setAuxiliaryTokens() autorest.PrepareDecorator {
 					r.Header.Set("x-ms-authorization-auxiliary", fmt.Sprintf("Bearer %s", token))
 				}
 			}
+			SetCorrelationID(r)
 			return r, nil
 		})
 	}
@@ -571,6 +617,8 @@ func (az *AzureClient) AddAuxiliaryTokens(tokens []string) {
 	az.applicationsClient.Client.RequestInspector = requestWithTokens
 	az.authorizationClient.Client.RequestInspector = requestWithTokens
 	az.availabilitySetsClient.Client.RequestInspector = requestWithTokens
+	az.dedicatedHostGroupsClient.Client.RequestInspector = requestWithTokens
+	az.dedicatedHostsClient.Client.RequestInspector = requestWithTokens
 	az.deploymentOperationsClient.Client.RequestInspector = requestWithTokens
 	az.deploymentsClient.Client.RequestInspector = requestWithTokens
 	az.disksClient.Client.RequestInspector = requestWithTokens
@@ -578,14 +626,51 @@ func (az *AzureClient) AddAuxiliaryTokens(tokens []string) {
 	az.interfacesClient.Client.RequestInspector = requestWithTokens
 	az.msiClient.Client.RequestInspector = requestWithTokens
 	az.providersClient.Client.RequestInspector = requestWithTokens
+	az.proximityPlacementGroupsClient.Client.RequestInspector = requestWithTokens
+	az.diskEncryptionSetsClient.Client.RequestInspector = requestWithTokens
 	az.resourcesClient.Client.RequestInspector = requestWithTokens
 	az.resourceSkusClient.Client.RequestInspector = requestWithTokens
 	az.servicePrincipalsClient.Client.RequestInspector = requestWithTokens
 	az.storageAccountsClient.Client.RequestInspector = requestWithTokens
+	az.subnetsClient.Client.RequestInspector = requestWithTokens
 	az.subscriptionsClient.Client.RequestInspector = requestWithTokens
+	az.usageClient.Client.RequestInspector = requestWithTokens
 	az.virtualMachineExtensionsClient.Client.RequestInspector = requestWithTokens
 	az.virtualMachineScaleSetsClient.Client.RequestInspector = requestWithTokens
 	az.virtualMachineScaleSetVMsClient.Client.RequestInspector = requestWithTokens
 	az.virtualMachinesClient.Client.RequestInspector = requestWithTokens
+	az.virtualNetworkPeeringsClient.Client.RequestInspector = requestWithTokens
 	az.workspacesClient.Client.RequestInspector = requestWithTokens
 }
+
+// SetSender sends the requests of the ARM and Graph clients through sender, e.g. an armtest.RecordingClient
+func (az *AzureClient) SetSender(sender autorest.Sender) {
+	az.applicationsClient.Client.Sender = sender
+	az.authorizationClient.Client.Sender = sender
+	az.availabilitySetsClient.Client.Sender = sender
+	az.dedicatedHostGroupsClient.Client.Sender = sender
+	az.dedicatedHostsClient.Client.Sender = sender
+	az.deploymentOperationsClient.Client.Sender = sender
+	az.deploymentsClient.Client.Sender = sender
+	az.disksClient.Client.Sender = sender
+	az.groupsClient.Client.Sender = sender
+	az.interfacesClient.Client.Sender = sender
+	az.msiClient.Client.Sender = sender
+	az.providersClient.Client.Sender = sender
+	az.proximityPlacementGroupsClient.Client.Sender = sender
+	az.diskEncryptionSetsClient.Client.Sender = sender
+	az.resourcesClient.Client.Sender = sender
+	az.resourceSkusClient.Client.Sender = sender
+	az.servicePrincipalsClient.Client.Sender = sender
+	az.storageAccountsClient.Client.Sender = sender
+	az.subnetsClient.Client.Sender = sender
+	az.subscriptionsClient.Client.Sender = sender
+	az.usageClient.Client.Sender = sender
+	az.virtualMachineExtensionsClient.Client.Sender = sender
+	az.virtualMachineImagesClient.Client.Sender = sender
+	az.virtualMachineScaleSetsClient.Client.Sender = sender
+	az.virtualMachineScaleSetVMsClient.Client.Sender = sender
+	az.virtualMachinesClient.Client.Sender = sender
+	az.virtualNetworkPeeringsClient.Client.Sender = sender
+	az.workspacesClient.Client.Sender = sender
+}
@@ -33,3 +33,26 @@ func TestAzureClientAuxToken(t *testing.T) {
 	g.Expect(err).To(BeNil())
 	g.Expect(request.Header.Get("x-ms-authorization-auxiliary")).To(Equal(fmt.Sprintf("Bearer %s", token)))
 }
+
+func TestAzureClientCorrelationID(t *testing.T) {
+	t.Parallel()
+
+	env, err := azure.EnvironmentFromName("AZUREPUBLICCLOUD")
+	g := NewGomegaWithT(t)
+	g.Expect(err).To(BeNil())
+
+	azureClient, err := NewAzureClientWithClientSecretExternalTenant(env, "subID", "d1a3-4ea4", "clientID", "secret")
+	g.Expect(err).To(BeNil())
+	ctx := WithCorrelationID(context.Background(), "d5062e45-6e9f-4fd3-a0a0-6b2c56b15757")
+	request, err := azureClient.deploymentsClient.GetPreparer(ctx, "testRG", "testDeployment")
+	g.Expect(err).To(BeNil())
+	request, err = autorest.Prepare(request, azureClient.deploymentsClient.WithInspection())
+	g.Expect(err).To(BeNil())
+	g.Expect(request.Header.Get(CorrelationIDHeader)).To(Equal("d5062e45-6e9f-4fd3-a0a0-6b2c56b15757"))
+
+	request, err = azureClient.deploymentsClient.GetPreparer(context.Background(), "testRG", "testDeployment")
+	g.Expect(err).To(BeNil())
+	request, err = autorest.Prepare(request, azureClient.deploymentsClient.WithInspection())
+	g.Expect(err).To(BeNil())
+	g.Expect(request.Header.Get(CorrelationIDHeader)).To(BeEmpty())
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Azure/aks-engine/pkg/armhelpers"
 	"github.com/Azure/aks-engine/pkg/engine"
 	"github.com/Azure/aks-engine/pkg/kubernetes"
 	"github.com/Azure/azure-sdk-for-go/services/apimanagement/mgmt/2017-03-01/apimanagement"
@@ -281,6 +282,8 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 	c.applicationsClient.Authorizer = graphAuthorizer
 	c.servicePrincipalsClient.Authorizer = graphAuthorizer
 
+	c.deploymentsClient.RequestInspector = armhelpers.CorrelationIDDecorator()
+
 	return c
 }
 
@@ -397,6 +400,7 @@ func (az *AzureClient) addAcceptLanguages() autorest.PrepareDecorator {
 					r.Header.Add("Accept-Language", language)
 				}
 			}
+			armhelpers.SetCorrelationID(r)
 			return r, nil
 		})
 	}
@@ -421,6 +425,7 @@ func (az *AzureClient) setAuxiliaryTokens() autorest.PrepareDecorator {
 					r.Header.Set("x-ms-authorization-auxiliary", fmt.Sprintf("Bearer %s", token))
 				}
 			}
+			armhelpers.SetCorrelationID(r)
 			return r, nil
 		})
 	}
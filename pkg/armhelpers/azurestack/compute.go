@@ -124,6 +124,41 @@ func (az *AzureClient) DeleteVirtualMachineScaleSetVM(ctx context.Context, resou
 	return err
 }
 
+// DeallocateVirtualMachineScaleSetVM stops a VM in a VMSS and releases its compute resources
+func (az *AzureClient) DeallocateVirtualMachineScaleSetVM(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string) error {
+	future, err := az.virtualMachineScaleSetVMsClient.Deallocate(ctx, resourceGroup, virtualMachineScaleSet, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.virtualMachineScaleSetVMsClient.Client); err != nil {
+		return err
+	}
+
+	_, err = future.Result(az.virtualMachineScaleSetVMsClient)
+	return err
+}
+
+// UpdateVirtualMachineScaleSetVMs upgrades the specified VMs in a VMSS to the latest scale set model
+func (az *AzureClient) UpdateVirtualMachineScaleSetVMs(ctx context.Context, resourceGroup, virtualMachineScaleSet string, instanceIDs azcompute.VirtualMachineScaleSetVMInstanceRequiredIDs) error {
+	ids := compute.VirtualMachineScaleSetVMInstanceRequiredIDs{}
+	err := DeepCopy(&ids, instanceIDs)
+	if err != nil {
+		return fmt.Errorf("fail to convert instance IDs, %v", err)
+	}
+	future, err := az.virtualMachineScaleSetsClient.UpdateInstances(ctx, resourceGroup, virtualMachineScaleSet, ids)
+	if err != nil {
+		return err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.virtualMachineScaleSetsClient.Client); err != nil {
+		return err
+	}
+
+	_, err = future.Result(az.virtualMachineScaleSetsClient)
+	return err
+}
+
 // DeleteVirtualMachineScaleSet deletes an entire VM Scale Set.
 func (az *AzureClient) DeleteVirtualMachineScaleSet(ctx context.Context, resourceGroup, vmssName string) error {
 	future, err := az.virtualMachineScaleSetsClient.Delete(ctx, resourceGroup, vmssName)
@@ -216,3 +251,43 @@ func (az *AzureClient) GetVirtualMachineScaleSetInstancePowerState(ctx context.C
 	// TODO Pass compute.InstanceView once we upgrade azure stack compute's api version
 	return "", errors.Errorf("operation not supported")
 }
+
+// GetVirtualMachineInstanceView retrieves the run-time state of the specified virtual machine, including its disks
+func (az *AzureClient) GetVirtualMachineInstanceView(ctx context.Context, resourceGroup, name string) (azcompute.VirtualMachineInstanceView, error) {
+	return azcompute.VirtualMachineInstanceView{}, errors.Errorf("operation not supported")
+}
+
+// GetProximityPlacementGroup retrieves the specified proximity placement group.
+func (az *AzureClient) GetProximityPlacementGroup(ctx context.Context, resourceGroup, name string) (azcompute.ProximityPlacementGroup, error) {
+	return azcompute.ProximityPlacementGroup{}, errors.Errorf("operation not supported")
+}
+
+// GetDiskEncryptionSet retrieves the specified disk encryption set.
+func (az *AzureClient) GetDiskEncryptionSet(ctx context.Context, resourceGroup, name string) (azcompute.DiskEncryptionSet, error) {
+	return azcompute.DiskEncryptionSet{}, errors.Errorf("operation not supported")
+}
+
+// GetDedicatedHostGroup retrieves the specified dedicated host group.
+func (az *AzureClient) GetDedicatedHostGroup(ctx context.Context, resourceGroup, name string) (azcompute.DedicatedHostGroup, error) {
+	return azcompute.DedicatedHostGroup{}, errors.Errorf("operation not supported")
+}
+
+// GetDedicatedHost retrieves the specified dedicated host, including its available capacity.
+func (az *AzureClient) GetDedicatedHost(ctx context.Context, resourceGroup, hostGroup, name string) (azcompute.DedicatedHost, error) {
+	return azcompute.DedicatedHost{}, errors.Errorf("operation not supported")
+}
+
+// RunVirtualMachineCommand runs a command on the specified virtual machine and waits for its completion.
+func (az *AzureClient) RunVirtualMachineCommand(ctx context.Context, resourceGroup, name string, input azcompute.RunCommandInput) (azcompute.RunCommandResult, error) {
+	return azcompute.RunCommandResult{}, errors.Errorf("operation not supported")
+}
+
+// RunVirtualMachineScaleSetVMCommand runs a command on a VM in a VMSS and waits for its completion.
+func (az *AzureClient) RunVirtualMachineScaleSetVMCommand(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string, input azcompute.RunCommandInput) (azcompute.RunCommandResult, error) {
+	return azcompute.RunCommandResult{}, errors.Errorf("operation not supported")
+}
+
+// ListComputeUsages returns the compute resource usages and limits of the subscription in a location.
+func (az *AzureClient) ListComputeUsages(ctx context.Context, location string) ([]azcompute.Usage, error) {
+	return nil, errors.Errorf("operation not supported")
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 
 	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2017-03-30/compute"
 )
 
 // DeleteManagedDisk deletes a managed disk.
@@ -32,3 +33,18 @@ func (az *AzureClient) ListManagedDisksByResourceGroup(ctx context.Context, reso
 		err: err,
 	}, err
 }
+
+// UpdateManagedDiskTags replaces the tags of a managed disk.
+func (az *AzureClient) UpdateManagedDiskTags(ctx context.Context, resourceGroupName, diskName string, tags map[string]*string) error {
+	future, err := az.disksClient.Update(ctx, resourceGroupName, diskName, compute.DiskUpdate{Tags: tags})
+	if err != nil {
+		return err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.disksClient.Client); err != nil {
+		return err
+	}
+
+	_, err = future.Result(az.disksClient)
+	return err
+}
@@ -6,8 +6,10 @@ package azurestack
 import (
 	"context"
 
+	"github.com/Azure/aks-engine/pkg/armhelpers"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
 )
 
 // EnsureResourceGroup ensures the named resource group exists in the given location.
@@ -50,3 +52,8 @@ func (az *AzureClient) DeleteResourceGroup(ctx context.Context, name string) err
 	_, err = future.Result(az.groupsClient)
 	return err
 }
+
+// ListManagementLocks returns the management locks applying to a resource group.
+func (az *AzureClient) ListManagementLocks(ctx context.Context, resourceGroup string) ([]armhelpers.ManagementLock, error) {
+	return nil, errors.Errorf("operation not supported")
+}
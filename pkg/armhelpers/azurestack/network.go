@@ -5,6 +5,9 @@ package azurestack
 
 import (
 	"context"
+
+	aznetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/pkg/errors"
 )
 
 // DeleteNetworkInterface deletes the specified network interface.
@@ -21,3 +24,18 @@ func (az *AzureClient) DeleteNetworkInterface(ctx context.Context, resourceGroup
 	_, err = future.Result(az.interfacesClient)
 	return err
 }
+
+// ListNetworkInterfaces lists the network interfaces in the specified resource group.
+func (az *AzureClient) ListNetworkInterfaces(ctx context.Context, resourceGroup string) ([]aznetwork.Interface, error) {
+	return nil, errors.Errorf("operation not supported")
+}
+
+// GetSubnet retrieves the specified virtual network subnet, including the IP configurations using it.
+func (az *AzureClient) GetSubnet(ctx context.Context, resourceGroup, vnetName, subnetName string) (aznetwork.Subnet, error) {
+	return aznetwork.Subnet{}, errors.Errorf("operation not supported")
+}
+
+// GetVirtualNetworkPeering retrieves the specified peering of a virtual network.
+func (az *AzureClient) GetVirtualNetworkPeering(ctx context.Context, resourceGroup, vnetName, peeringName string) (aznetwork.VirtualNetworkPeering, error) {
+	return aznetwork.VirtualNetworkPeering{}, errors.Errorf("operation not supported")
+}
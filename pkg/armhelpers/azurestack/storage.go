@@ -9,8 +9,10 @@ import (
 
 	"github.com/Azure/aks-engine/pkg/armhelpers"
 	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2017-10-01/storage"
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-02-01/storage"
 	azStorage "github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
 )
 
 // AzureStorageClient implements the StorageClient interface and wraps the Azure storage client.
@@ -35,6 +37,17 @@ func (az *AzureClient) GetStorageClient(ctx context.Context, resourceGroup, acco
 	}, nil
 }
 
+// ListStorageAccounts lists the storage accounts in the specified resource group.
+func (az *AzureClient) ListStorageAccounts(ctx context.Context, resourceGroup string) ([]mgmtstorage.Account, error) {
+	return nil, errors.Errorf("operation not supported")
+}
+
+// DeleteStorageAccount deletes the specified storage account.
+func (az *AzureClient) DeleteStorageAccount(ctx context.Context, resourceGroup, accountName string) error {
+	_, err := az.storageAccountsClient.Delete(ctx, resourceGroup, accountName)
+	return err
+}
+
 func (az *AzureClient) getStorageKeys(ctx context.Context, resourceGroup, accountName string) ([]storage.AccountKey, error) {
 	storageKeysResult, err := az.storageAccountsClient.ListKeys(ctx, resourceGroup, accountName)
 	if err != nil {
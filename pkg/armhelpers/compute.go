@@ -11,6 +11,18 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ListComputeUsages returns the compute resource usages and limits of the subscription in a location.
+func (az *AzureClient) ListComputeUsages(ctx context.Context, location string) ([]compute.Usage, error) {
+	var usages []compute.Usage
+	for page, err := az.usageClient.List(ctx, location); page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, page.Values()...)
+	}
+	return usages, nil
+}
+
 // ListVirtualMachines returns (the first page of) the machines in the specified resource group.
 func (az *AzureClient) ListVirtualMachines(ctx context.Context, resourceGroup string) (VirtualMachineListResultPage, error) {
 	page, err := az.virtualMachinesClient.List(ctx, resourceGroup)
@@ -94,6 +106,36 @@ func (az *AzureClient) DeleteVirtualMachineScaleSetVM(ctx context.Context, resou
 	return err
 }
 
+// DeallocateVirtualMachineScaleSetVM stops a VM in a VMSS and releases its compute resources
+func (az *AzureClient) DeallocateVirtualMachineScaleSetVM(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string) error {
+	future, err := az.virtualMachineScaleSetVMsClient.Deallocate(ctx, resourceGroup, virtualMachineScaleSet, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.virtualMachineScaleSetVMsClient.Client); err != nil {
+		return err
+	}
+
+	_, err = future.Result(az.virtualMachineScaleSetVMsClient)
+	return err
+}
+
+// UpdateVirtualMachineScaleSetVMs upgrades the specified VMs in a VMSS to the latest scale set model
+func (az *AzureClient) UpdateVirtualMachineScaleSetVMs(ctx context.Context, resourceGroup, virtualMachineScaleSet string, instanceIDs compute.VirtualMachineScaleSetVMInstanceRequiredIDs) error {
+	future, err := az.virtualMachineScaleSetsClient.UpdateInstances(ctx, resourceGroup, virtualMachineScaleSet, instanceIDs)
+	if err != nil {
+		return err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.virtualMachineScaleSetsClient.Client); err != nil {
+		return err
+	}
+
+	_, err = future.Result(az.virtualMachineScaleSetsClient)
+	return err
+}
+
 // DeleteVirtualMachineScaleSet deletes an entire VM Scale Set.
 func (az *AzureClient) DeleteVirtualMachineScaleSet(ctx context.Context, resourceGroup, vmssName string) error {
 	future, err := az.virtualMachineScaleSetsClient.Delete(ctx, resourceGroup, vmssName)
@@ -179,3 +221,56 @@ func (az *AzureClient) GetVirtualMachineScaleSetInstancePowerState(ctx context.C
 	}
 	return "", nil
 }
+
+// GetVirtualMachineInstanceView retrieves the run-time state of the specified virtual machine, including its disks
+func (az *AzureClient) GetVirtualMachineInstanceView(ctx context.Context, resourceGroup, name string) (compute.VirtualMachineInstanceView, error) {
+	return az.virtualMachinesClient.InstanceView(ctx, resourceGroup, name)
+}
+
+// GetProximityPlacementGroup retrieves the specified proximity placement group.
+func (az *AzureClient) GetProximityPlacementGroup(ctx context.Context, resourceGroup, name string) (compute.ProximityPlacementGroup, error) {
+	return az.proximityPlacementGroupsClient.Get(ctx, resourceGroup, name, "")
+}
+
+// GetDiskEncryptionSet retrieves the specified disk encryption set.
+func (az *AzureClient) GetDiskEncryptionSet(ctx context.Context, resourceGroup, name string) (compute.DiskEncryptionSet, error) {
+	return az.diskEncryptionSetsClient.Get(ctx, resourceGroup, name)
+}
+
+// GetDedicatedHostGroup retrieves the specified dedicated host group.
+func (az *AzureClient) GetDedicatedHostGroup(ctx context.Context, resourceGroup, name string) (compute.DedicatedHostGroup, error) {
+	return az.dedicatedHostGroupsClient.Get(ctx, resourceGroup, name)
+}
+
+// GetDedicatedHost retrieves the specified dedicated host, including its available capacity.
+func (az *AzureClient) GetDedicatedHost(ctx context.Context, resourceGroup, hostGroup, name string) (compute.DedicatedHost, error) {
+	return az.dedicatedHostsClient.Get(ctx, resourceGroup, hostGroup, name, compute.InstanceView)
+}
+
+// RunVirtualMachineCommand runs a command on the specified virtual machine and waits for its completion.
+func (az *AzureClient) RunVirtualMachineCommand(ctx context.Context, resourceGroup, name string, input compute.RunCommandInput) (compute.RunCommandResult, error) {
+	future, err := az.virtualMachinesClient.RunCommand(ctx, resourceGroup, name, input)
+	if err != nil {
+		return compute.RunCommandResult{}, err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.virtualMachinesClient.Client); err != nil {
+		return compute.RunCommandResult{}, err
+	}
+
+	return future.Result(az.virtualMachinesClient)
+}
+
+// RunVirtualMachineScaleSetVMCommand runs a command on a VM in a VMSS and waits for its completion.
+func (az *AzureClient) RunVirtualMachineScaleSetVMCommand(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string, input compute.RunCommandInput) (compute.RunCommandResult, error) {
+	future, err := az.virtualMachineScaleSetVMsClient.RunCommand(ctx, resourceGroup, virtualMachineScaleSet, instanceID, input)
+	if err != nil {
+		return compute.RunCommandResult{}, err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.virtualMachineScaleSetVMsClient.Client); err != nil {
+		return compute.RunCommandResult{}, err
+	}
+
+	return future.Result(az.virtualMachineScaleSetVMsClient)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// CorrelationIDHeader is the request header ARM records as the correlationId of the deployments it creates
+const CorrelationIDHeader = "x-ms-correlation-request-id"
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx whose ARM requests carry correlationID in CorrelationIDHeader,
+// so that the deployments they create can be referenced in Azure support cases
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation ID of ctx set by WithCorrelationID, empty if none
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
+
+// SetCorrelationID sets CorrelationIDHeader of the request to the correlation ID of its context, if any
+func SetCorrelationID(r *http.Request) {
+	correlationID := CorrelationID(r.Context())
+	if correlationID == "" {
+		return
+	}
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Set(CorrelationIDHeader, correlationID)
+}
+
+// CorrelationIDDecorator is the request inspector of the clients setting the correlation ID of their requests,
+// the request inspectors adding the accept languages or the auxiliary tokens set it too
+func CorrelationIDDecorator() autorest.PrepareDecorator {
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			r, err := p.Prepare(r)
+			if err != nil {
+				return r, err
+			}
+			SetCorrelationID(r)
+			return r, nil
+		})
+	}
+}
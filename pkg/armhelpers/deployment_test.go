@@ -5,6 +5,7 @@ package armhelpers
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -66,3 +67,19 @@ func TestDeployTemplateSync(t *testing.T) {
 		t.Error("err should not be nil")
 	}
 }
+
+func TestDeploymentOutputValues(t *testing.T) {
+	outputs := map[string]interface{}{
+		"masterIP":   map[string]interface{}{"type": "String", "value": "10.255.255.5"},
+		"agentCount": map[string]interface{}{"type": "Int", "value": float64(3)},
+		"malformed":  "10.255.255.5",
+	}
+	values := DeploymentOutputValues(outputs)
+	expected := map[string]interface{}{"masterIP": "10.255.255.5", "agentCount": float64(3)}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected deployment output values %v, got %v", expected, values)
+	}
+	if values = DeploymentOutputValues(nil); len(values) != 0 {
+		t.Errorf("expected no deployment output values, got %v", values)
+	}
+}
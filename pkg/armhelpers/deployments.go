@@ -14,14 +14,33 @@ import (
 
 // DeployTemplate implements the TemplateDeployer interface for the AzureClient client
 func (az *AzureClient) DeployTemplate(ctx context.Context, resourceGroupName, deploymentName string, template map[string]interface{}, parameters map[string]interface{}) (de resources.DeploymentExtended, err error) {
-	deployment := resources.Deployment{
+	return az.DeployTemplateWithMode(ctx, resourceGroupName, deploymentName, template, parameters, resources.Incremental)
+}
+
+// DeployTemplateWithMode deploys a template with the given deployment mode
+func (az *AzureClient) DeployTemplateWithMode(ctx context.Context, resourceGroupName, deploymentName string, template map[string]interface{}, parameters map[string]interface{}, mode resources.DeploymentMode) (de resources.DeploymentExtended, err error) {
+	return az.createDeployment(ctx, resourceGroupName, deploymentName, resources.Deployment{
 		Properties: &resources.DeploymentProperties{
 			Template:   &template,
 			Parameters: &parameters,
-			Mode:       resources.Incremental,
+			Mode:       mode,
 		},
-	}
+	})
+}
+
+// DeployTemplateLinkWithMode deploys the template at templateURI with the given deployment mode
+func (az *AzureClient) DeployTemplateLinkWithMode(ctx context.Context, resourceGroupName, deploymentName, templateURI string, parameters map[string]interface{}, mode resources.DeploymentMode) (de resources.DeploymentExtended, err error) {
+	return az.createDeployment(ctx, resourceGroupName, deploymentName, resources.Deployment{
+		Properties: &resources.DeploymentProperties{
+			TemplateLink: &resources.TemplateLink{URI: &templateURI},
+			Parameters:   &parameters,
+			Mode:         mode,
+		},
+	})
+}
 
+// createDeployment starts the deployment and waits for it to complete
+func (az *AzureClient) createDeployment(ctx context.Context, resourceGroupName, deploymentName string, deployment resources.Deployment) (de resources.DeploymentExtended, err error) {
 	log.Infof("Starting ARM Deployment %s in resource group %s. This will take some time...", deploymentName, resourceGroupName)
 	future, err := az.deploymentsClient.CreateOrUpdate(ctx, resourceGroupName, deploymentName, deployment)
 	if err != nil {
@@ -45,6 +64,41 @@ func (az *AzureClient) DeployTemplate(ctx context.Context, resourceGroupName, de
 	return de, err
 }
 
+// BeginDeployTemplate starts a template deployment without waiting for it to complete
+func (az *AzureClient) BeginDeployTemplate(ctx context.Context, resourceGroupName, deploymentName string, template map[string]interface{}, parameters map[string]interface{}) error {
+	return az.BeginDeployTemplateWithMode(ctx, resourceGroupName, deploymentName, template, parameters, resources.Incremental)
+}
+
+// BeginDeployTemplateWithMode starts a template deployment with the given deployment mode without waiting for it to complete
+func (az *AzureClient) BeginDeployTemplateWithMode(ctx context.Context, resourceGroupName, deploymentName string, template map[string]interface{}, parameters map[string]interface{}, mode resources.DeploymentMode) error {
+	return az.beginDeployment(ctx, resourceGroupName, deploymentName, resources.Deployment{
+		Properties: &resources.DeploymentProperties{
+			Template:   &template,
+			Parameters: &parameters,
+			Mode:       mode,
+		},
+	})
+}
+
+// BeginDeployTemplateLinkWithMode starts a deployment of the template at templateURI with the given deployment mode
+// without waiting for it to complete
+func (az *AzureClient) BeginDeployTemplateLinkWithMode(ctx context.Context, resourceGroupName, deploymentName, templateURI string, parameters map[string]interface{}, mode resources.DeploymentMode) error {
+	return az.beginDeployment(ctx, resourceGroupName, deploymentName, resources.Deployment{
+		Properties: &resources.DeploymentProperties{
+			TemplateLink: &resources.TemplateLink{URI: &templateURI},
+			Parameters:   &parameters,
+			Mode:         mode,
+		},
+	})
+}
+
+// beginDeployment starts the deployment without waiting for it to complete
+func (az *AzureClient) beginDeployment(ctx context.Context, resourceGroupName, deploymentName string, deployment resources.Deployment) error {
+	log.Infof("Starting ARM Deployment %s in resource group %s", deploymentName, resourceGroupName)
+	_, err := az.deploymentsClient.CreateOrUpdate(ctx, resourceGroupName, deploymentName, deployment)
+	return err
+}
+
 // ValidateTemplate validate the template and parameters
 func (az *AzureClient) ValidateTemplate(
 	ctx context.Context,
@@ -67,7 +121,47 @@ func (az *AzureClient) GetDeployment(ctx context.Context, resourceGroupName, dep
 	return az.deploymentsClient.Get(ctx, resourceGroupName, deploymentName)
 }
 
+// GetDeploymentOutputs returns the values of the outputs of the template deployment keyed by output name
+func (az *AzureClient) GetDeploymentOutputs(ctx context.Context, resourceGroupName, deploymentName string) (map[string]interface{}, error) {
+	deployment, err := az.deploymentsClient.Get(ctx, resourceGroupName, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+	if deployment.Properties == nil {
+		return map[string]interface{}{}, nil
+	}
+	return DeploymentOutputValues(deployment.Properties.Outputs), nil
+}
+
+// DeploymentOutputValues returns the values of the outputs of a deployment, {"name": {"type": "string", "value": ...}},
+// keyed by output name
+func DeploymentOutputValues(outputs interface{}) map[string]interface{} {
+	values := map[string]interface{}{}
+	outputMap, _ := outputs.(map[string]interface{})
+	for name, output := range outputMap {
+		if o, ok := output.(map[string]interface{}); ok {
+			values[name] = o["value"]
+		}
+	}
+	return values
+}
+
 // CheckDeploymentExistence returns if the deployment already exists
 func (az *AzureClient) CheckDeploymentExistence(ctx context.Context, resourceGroupName string, deploymentName string) (result autorest.Response, err error) {
 	return az.deploymentsClient.CheckExistence(ctx, resourceGroupName, deploymentName)
 }
+
+// ListDeployments lists the template deployments of the resource group
+func (az *AzureClient) ListDeployments(ctx context.Context, resourceGroupName string) (DeploymentListResultPage, error) {
+	page, err := az.deploymentsClient.ListByResourceGroup(ctx, resourceGroupName, "", nil)
+	return &page, err
+}
+
+// DeleteDeployment deletes the template deployment, the resources it deployed are kept
+func (az *AzureClient) DeleteDeployment(ctx context.Context, resourceGroupName, deploymentName string) error {
+	future, err := az.deploymentsClient.Delete(ctx, resourceGroupName, deploymentName)
+	if err != nil {
+		return err
+	}
+	return future.WaitForCompletionRef(ctx, az.deploymentsClient.Client)
+}
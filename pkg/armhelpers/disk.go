@@ -5,6 +5,8 @@ package armhelpers
 
 import (
 	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
 )
 
 // DeleteManagedDisk deletes a managed disk.
@@ -27,3 +29,18 @@ func (az *AzureClient) ListManagedDisksByResourceGroup(ctx context.Context, reso
 	page, err := az.disksClient.ListByResourceGroup(ctx, resourceGroupName)
 	return &page, err
 }
+
+// UpdateManagedDiskTags replaces the tags of a managed disk.
+func (az *AzureClient) UpdateManagedDiskTags(ctx context.Context, resourceGroupName, diskName string, tags map[string]*string) error {
+	future, err := az.disksClient.Update(ctx, resourceGroupName, diskName, compute.DiskUpdate{Tags: tags})
+	if err != nil {
+		return err
+	}
+
+	if err = future.WaitForCompletionRef(ctx, az.disksClient.Client); err != nil {
+		return err
+	}
+
+	_, err = future.Result(az.disksClient)
+	return err
+}
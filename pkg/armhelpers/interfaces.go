@@ -11,9 +11,11 @@ import (
 	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2015-07-01/authorization"
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/graphrbac/1.6/graphrbac"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
 	"github.com/Azure/azure-sdk-for-go/services/preview/msi/mgmt/2015-08-31-preview/msi"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2016-06-01/subscriptions"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-02-01/storage"
 
 	azStorage "github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/Azure/go-autorest/autorest"
@@ -63,6 +65,14 @@ type ProviderListResultPage interface {
 	Values() []resources.Provider
 }
 
+// DeploymentListResultPage is an interface for resources.DeploymentListResultPage to aid in mocking
+type DeploymentListResultPage interface {
+	Next() error
+	NotDone() bool
+	Response() resources.DeploymentListResult
+	Values() []resources.DeploymentExtended
+}
+
 // DeploymentOperationsListResultPage is an interface for resources.DeploymentOperationsListResultPage to aid in mocking
 type DeploymentOperationsListResultPage interface {
 	Next() error
@@ -114,18 +124,59 @@ type AKSEngineClient interface {
 	// DeployTemplate can deploy a template into Azure ARM
 	DeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) (resources.DeploymentExtended, error)
 
+	// BeginDeployTemplate starts a template deployment without waiting for it to complete
+	BeginDeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) error
+
+	// DeployTemplateWithMode deploys a template with the given deployment mode; Complete mode deletes
+	// the resources of the resource group that are not in the template
+	DeployTemplateWithMode(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}, mode resources.DeploymentMode) (resources.DeploymentExtended, error)
+
+	// BeginDeployTemplateWithMode starts a template deployment with the given deployment mode without waiting for it to complete
+	BeginDeployTemplateWithMode(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}, mode resources.DeploymentMode) error
+
+	// DeployTemplateLinkWithMode deploys the template at templateURI, e.g. a blob URI with a SAS token,
+	// which unlike an inline template is not subject to the ARM request size limit
+	DeployTemplateLinkWithMode(ctx context.Context, resourceGroup, name, templateURI string, parameters map[string]interface{}, mode resources.DeploymentMode) (resources.DeploymentExtended, error)
+
+	// BeginDeployTemplateLinkWithMode starts a deployment of the template at templateURI without waiting for it to complete
+	BeginDeployTemplateLinkWithMode(ctx context.Context, resourceGroup, name, templateURI string, parameters map[string]interface{}, mode resources.DeploymentMode) error
+
+	// WhatIfDeployTemplate returns the changes the deployment of the template would make, without deploying it
+	WhatIfDeployTemplate(ctx context.Context, resourceGroup, deploymentName string, template, parameters map[string]interface{}) ([]WhatIfChange, error)
+
+	// GetDeployment returns the template deployment
+	GetDeployment(ctx context.Context, resourceGroupName, deploymentName string) (resources.DeploymentExtended, error)
+
+	// GetDeploymentOutputs returns the values of the outputs of the template deployment keyed by output name
+	GetDeploymentOutputs(ctx context.Context, resourceGroupName, deploymentName string) (map[string]interface{}, error)
+
+	// CheckDeploymentExistence returns a 204 response if the deployment exists, 404 otherwise
+	CheckDeploymentExistence(ctx context.Context, resourceGroupName string, deploymentName string) (autorest.Response, error)
+
+	// ListDeployments lists the template deployments of the resource group
+	ListDeployments(ctx context.Context, resourceGroupName string) (DeploymentListResultPage, error)
+
+	// DeleteDeployment deletes the template deployment, the resources it deployed are kept
+	DeleteDeployment(ctx context.Context, resourceGroupName, deploymentName string) error
+
 	// EnsureResourceGroup ensures the specified resource group exists in the specified location
 	EnsureResourceGroup(ctx context.Context, resourceGroup, location string, managedBy *string) (*resources.Group, error)
 
 	// ListLocations returns all the Azure locations to which AKS Engine can deploy
 	ListLocations(ctx context.Context) (*[]subscriptions.Location, error)
 
+	// ListManagementLocks returns the management locks applying to a resource group
+	ListManagementLocks(ctx context.Context, resourceGroup string) ([]ManagementLock, error)
+
 	//
 	// COMPUTE
 
 	// ListResourceSkus lists Microsoft.Compute SKUs available for a subscription
 	ListResourceSkus(ctx context.Context, filter string) (ResourceSkusResultPage, error)
 
+	// ListComputeUsages returns the compute resource usages and limits of the subscription in a location
+	ListComputeUsages(ctx context.Context, location string) ([]compute.Usage, error)
+
 	// ListVirtualMachines lists VM resources
 	ListVirtualMachines(ctx context.Context, resourceGroup string) (VirtualMachineListResultPage, error)
 
@@ -150,6 +201,12 @@ type AKSEngineClient interface {
 	// DeleteVirtualMachineScaleSetVM deletes a VM in a VMSS
 	DeleteVirtualMachineScaleSetVM(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string) error
 
+	// DeallocateVirtualMachineScaleSetVM stops a VM in a VMSS and releases its compute resources
+	DeallocateVirtualMachineScaleSetVM(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string) error
+
+	// UpdateVirtualMachineScaleSetVMs upgrades the specified VMs in a VMSS to the latest scale set model
+	UpdateVirtualMachineScaleSetVMs(ctx context.Context, resourceGroup, virtualMachineScaleSet string, instanceIDs compute.VirtualMachineScaleSetVMInstanceRequiredIDs) error
+
 	// SetVirtualMachineScaleSetCapacity sets the VMSS capacity
 	SetVirtualMachineScaleSetCapacity(ctx context.Context, resourceGroup, virtualMachineScaleSet string, sku compute.Sku, location string) error
 
@@ -166,6 +223,27 @@ type AKSEngineClient interface {
 	// GetVirtualMachineScaleSetInstancePowerState returns the virtual machine's PowerState status code
 	GetVirtualMachineScaleSetInstancePowerState(ctx context.Context, resourceGroup, name, instanceID string) (string, error)
 
+	// GetVirtualMachineInstanceView retrieves the run-time state of the specified virtual machine, including its disks
+	GetVirtualMachineInstanceView(ctx context.Context, resourceGroup, name string) (compute.VirtualMachineInstanceView, error)
+
+	// GetProximityPlacementGroup retrieves the specified proximity placement group.
+	GetProximityPlacementGroup(ctx context.Context, resourceGroup, name string) (compute.ProximityPlacementGroup, error)
+
+	// GetDiskEncryptionSet retrieves the specified disk encryption set.
+	GetDiskEncryptionSet(ctx context.Context, resourceGroup, name string) (compute.DiskEncryptionSet, error)
+
+	// GetDedicatedHostGroup retrieves the specified dedicated host group.
+	GetDedicatedHostGroup(ctx context.Context, resourceGroup, name string) (compute.DedicatedHostGroup, error)
+
+	// GetDedicatedHost retrieves the specified dedicated host, including its available capacity.
+	GetDedicatedHost(ctx context.Context, resourceGroup, hostGroup, name string) (compute.DedicatedHost, error)
+
+	// RunVirtualMachineCommand runs a command on the specified virtual machine and waits for its completion.
+	RunVirtualMachineCommand(ctx context.Context, resourceGroup, name string, input compute.RunCommandInput) (compute.RunCommandResult, error)
+
+	// RunVirtualMachineScaleSetVMCommand runs a command on a VM in a VMSS and waits for its completion.
+	RunVirtualMachineScaleSetVMCommand(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string, input compute.RunCommandInput) (compute.RunCommandResult, error)
+
 	//
 	// STORAGE
 
@@ -173,12 +251,27 @@ type AKSEngineClient interface {
 	// account.
 	GetStorageClient(ctx context.Context, resourceGroup, accountName string) (AKSStorageClient, error)
 
+	// ListStorageAccounts lists the storage accounts in the specified resource group.
+	ListStorageAccounts(ctx context.Context, resourceGroup string) ([]storage.Account, error)
+
+	// DeleteStorageAccount deletes the specified storage account.
+	DeleteStorageAccount(ctx context.Context, resourceGroup, accountName string) error
+
 	//
 	// NETWORK
 
 	// DeleteNetworkInterface deletes the specified network interface.
 	DeleteNetworkInterface(ctx context.Context, resourceGroup, nicName string) error
 
+	// ListNetworkInterfaces lists the network interfaces in the specified resource group.
+	ListNetworkInterfaces(ctx context.Context, resourceGroup string) ([]network.Interface, error)
+
+	// GetSubnet retrieves the specified virtual network subnet, including the IP configurations using it.
+	GetSubnet(ctx context.Context, resourceGroup, vnetName, subnetName string) (network.Subnet, error)
+
+	// GetVirtualNetworkPeering retrieves the specified peering of a virtual network.
+	GetVirtualNetworkPeering(ctx context.Context, resourceGroup, vnetName, peeringName string) (network.VirtualNetworkPeering, error)
+
 	//
 	// GRAPH
 
@@ -203,6 +296,8 @@ type AKSEngineClient interface {
 	// MANAGED DISKS
 	DeleteManagedDisk(ctx context.Context, resourceGroupName string, diskName string) error
 	ListManagedDisksByResourceGroup(ctx context.Context, resourceGroupName string) (result DiskListPage, err error)
+	// UpdateManagedDiskTags replaces the tags of the managed disk
+	UpdateManagedDiskTags(ctx context.Context, resourceGroupName, diskName string, tags map[string]*string) error
 
 	GetKubernetesClient(apiserverURL, kubeConfig string, interval, timeout time.Duration) (kubernetes.Client, error)
 
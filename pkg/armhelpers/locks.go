@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+const managementLocksAPIVersion = "2016-09-01"
+
+// ManagementLock is a Microsoft.Authorization/locks resource
+type ManagementLock struct {
+	ID         string                   `json:"id,omitempty"`
+	Name       string                   `json:"name,omitempty"`
+	Properties ManagementLockProperties `json:"properties,omitempty"`
+}
+
+// ManagementLockProperties are the properties of a management lock
+type ManagementLockProperties struct {
+	// Level is CanNotDelete or ReadOnly
+	Level string `json:"level,omitempty"`
+	Notes string `json:"notes,omitempty"`
+}
+
+type managementLockListResult struct {
+	Value    []ManagementLock `json:"value,omitempty"`
+	NextLink string           `json:"nextLink,omitempty"`
+}
+
+// ListManagementLocks returns the management locks applying to a resource group,
+// including the locks inherited from the subscription and the locks of its resources.
+func (az *AzureClient) ListManagementLocks(ctx context.Context, resourceGroup string) ([]ManagementLock, error) {
+	pathParameters := map[string]interface{}{
+		"resourceGroupName": autorest.Encode("path", resourceGroup),
+		"subscriptionId":    autorest.Encode("path", az.subscriptionID),
+	}
+	queryParameters := map[string]interface{}{
+		"api-version": managementLocksAPIVersion,
+	}
+	req, err := autorest.CreatePreparer(
+		autorest.AsGet(),
+		autorest.WithBaseURL(az.groupsClient.BaseURI),
+		autorest.WithPathParameters("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Authorization/locks", pathParameters),
+		autorest.WithQueryParameters(queryParameters)).Prepare((&http.Request{}).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []ManagementLock
+	for req != nil {
+		resp, err := az.groupsClient.Send(req, azure.DoRetryWithRegistration(az.groupsClient.Client))
+		if err != nil {
+			return nil, err
+		}
+		var result managementLockListResult
+		err = autorest.Respond(
+			resp,
+			az.groupsClient.ByInspecting(),
+			azure.WithErrorUnlessStatusCode(http.StatusOK),
+			autorest.ByUnmarshallingJSON(&result),
+			autorest.ByClosing())
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, result.Value...)
+
+		req = nil
+		if result.NextLink != "" {
+			if req, err = autorest.Prepare((&http.Request{}).WithContext(ctx), autorest.AsGet(), autorest.WithBaseURL(result.NextLink)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return locks, nil
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListManagementLocks(t *testing.T) {
+	mc, err := NewHTTPMockClient()
+	if err != nil {
+		t.Fatalf("failed to create HttpMockClient - %s", err)
+	}
+
+	mc.RegisterLogin()
+	locksPath := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Authorization/locks", mc.SubscriptionID, mc.ResourceGroup)
+	mc.mux.HandleFunc(locksPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api-version") != managementLocksAPIVersion {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = fmt.Fprint(w, `{"value":[{"id":"lock2","name":"readonly","properties":{"level":"ReadOnly"}}]}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"value":[{"id":"lock1","name":"nodelete","properties":{"level":"CanNotDelete","notes":"production"}}],"nextLink":"http://localhost:%d%s?api-version=%s&page=2"}`,
+			mc.server.Port, locksPath, managementLocksAPIVersion)
+	})
+
+	err = mc.Activate()
+	if err != nil {
+		t.Fatalf("failed to activate HttpMockClient - %s", err)
+	}
+	defer mc.DeactivateAndReset()
+
+	env := mc.GetEnvironment()
+	azureClient, err := NewAzureClientWithClientSecret(env, subscriptionID, "clientID", "secret")
+	if err != nil {
+		t.Fatalf("can not get client %s", err)
+	}
+
+	locks, err := azureClient.ListManagementLocks(context.Background(), resourceGroup)
+	if err != nil {
+		t.Fatalf("failed to list management locks - %s", err)
+	}
+	expected := []ManagementLock{
+		{ID: "lock1", Name: "nodelete", Properties: ManagementLockProperties{Level: "CanNotDelete", Notes: "production"}},
+		{ID: "lock2", Name: "readonly", Properties: ManagementLockProperties{Level: "ReadOnly"}},
+	}
+	if diff := cmp.Diff(expected, locks); diff != "" {
+		t.Errorf("unexpected management locks %s", diff)
+	}
+}
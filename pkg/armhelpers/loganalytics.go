@@ -206,6 +206,7 @@ func (az *AzureClient) GetLogAnalyticsWorkspaceInfo(ctx context.Context, workspa
 	if !strings.EqualFold(workspaceSubscriptionID, az.subscriptionID) {
 		az.workspacesClient = oi.NewWorkspacesClientWithBaseURI(az.environment.ResourceManagerEndpoint, workspaceSubscriptionID)
 		az.workspacesClient.Authorizer = az.authorizationClient.Authorizer
+		az.workspacesClient.Sender = az.authorizationClient.Sender
 	}
 
 	resp, err := az.workspacesClient.Get(ctx, workspaceResourceGroup, workspaceName)
@@ -226,6 +227,7 @@ func (az *AzureClient) GetLogAnalyticsWorkspaceInfo(ctx context.Context, workspa
 func (az *AzureClient) AddContainerInsightsSolution(ctx context.Context, workspaceSubscriptionID, workspaceResourceGroup, workspaceName, workspaceLocation string) (result bool, err error) {
 	solutionClient := om.NewSolutionsClientWithBaseURI(az.environment.ResourceManagerEndpoint, workspaceSubscriptionID, "Microsoft.OperationalInsights", "workspaces", workspaceName)
 	solutionClient.Authorizer = az.workspacesClient.Authorizer
+	solutionClient.Sender = az.workspacesClient.Sender
 
 	solutionName := "ContainerInsights(" + workspaceName + ")"
 	workspaceResourceID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.OperationalInsights/workspaces/%s", workspaceSubscriptionID, workspaceResourceGroup, workspaceName)
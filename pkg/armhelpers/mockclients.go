@@ -21,16 +21,21 @@ import (
 	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2015-07-01/authorization"
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/graphrbac/1.6/graphrbac"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
 	"github.com/Azure/azure-sdk-for-go/services/preview/msi/mgmt/2015-08-31-preview/msi"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2016-06-01/subscriptions"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-02-01/storage"
 	azStorage "github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/Azure/go-autorest/autorest"
 	log "github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const (
@@ -51,34 +56,117 @@ func init() {
 
 //MockAKSEngineClient is an implementation of AKSEngineClient where all requests error out
 type MockAKSEngineClient struct {
-	FailDeployTemplate                      bool
-	FailDeployTemplateQuota                 bool
-	FailDeployTemplateConflict              bool
-	FailDeployTemplateWithProperties        bool
-	FailEnsureResourceGroup                 bool
-	FailListVirtualMachines                 bool
-	FailListVirtualMachinesTags             bool
-	FailListVirtualMachineScaleSets         bool
-	FailRestartVirtualMachineScaleSets      bool
-	FailGetVirtualMachine                   bool
-	FailRestartVirtualMachine               bool
-	FailDeleteVirtualMachine                bool
-	FailDeleteVirtualMachineScaleSetVM      bool
-	FailSetVirtualMachineScaleSetCapacity   bool
-	FailListVirtualMachineScaleSetVMs       bool
-	FailGetStorageClient                    bool
-	FailDeleteNetworkInterface              bool
-	FailGetKubernetesClient                 bool
-	FailListProviders                       bool
-	ShouldSupportVMIdentity                 bool
-	FailDeleteRoleAssignment                bool
+	FailDeployTemplate               bool
+	FailGetDeployment                bool
+	FakeGetDeploymentResult          func(name string) resources.DeploymentExtended
+	FailGetDeploymentOutputs         bool
+	FakeDeploymentOutputs            map[string]interface{}
+	FailDeployTemplateQuota          bool
+	FailDeployTemplateConflict       bool
+	FailDeployTemplateWithProperties bool
+	// DeploymentModes records the mode of the deployments started through the WithMode methods
+	DeploymentModes []resources.DeploymentMode
+	// DeploymentCorrelationIDs records the correlation ID of the context of the deployments started through the WithMode methods
+	DeploymentCorrelationIDs []string
+	// TemplateLinkURIs records the template URIs of the deployments started through the TemplateLink methods
+	TemplateLinkURIs []string
+	// FakeDeployments are listed by ListDeployments, DeleteDeployment removes them and records their names in DeletedDeployments
+	FakeDeployments                    []resources.DeploymentExtended
+	DeletedDeployments                 []string
+	FailListDeployments                bool
+	FailDeleteDeployment               bool
+	FailEnsureResourceGroup            bool
+	FailListVirtualMachines            bool
+	FailListVirtualMachinesTags        bool
+	FailListVirtualMachineScaleSets    bool
+	FailRestartVirtualMachineScaleSets bool
+	FailGetVirtualMachine              bool
+	FakeGetVirtualMachineZones         []string
+	FakeGetVirtualMachineIdentity      *compute.VirtualMachineIdentity
+	// FakeGetVirtualMachineOSDisk, if set, replaces the OS disk of the VM returned by GetVirtualMachine
+	FakeGetVirtualMachineOSDisk *compute.OSDisk
+	// FakeGetVirtualMachineDataDisks, if set, replaces the data disks of the VM returned by GetVirtualMachine
+	FakeGetVirtualMachineDataDisks *[]compute.DataDisk
+	// FakeGetVirtualMachineDiagnosticsProfile, if set, replaces the diagnostics profile of the VM returned by GetVirtualMachine
+	FakeGetVirtualMachineDiagnosticsProfile *compute.DiagnosticsProfile
+	FailGetVirtualMachineInstanceView       bool
+	FakeGetVirtualMachineInstanceViewResult func(name string) compute.VirtualMachineInstanceView
+	// UpdatedManagedDiskTags records the tags set through UpdateManagedDiskTags by disk name
+	UpdatedManagedDiskTags                 map[string]map[string]*string
+	FailUpdateManagedDiskTags              bool
+	FailRestartVirtualMachine              bool
+	FailDeleteVirtualMachine               bool
+	FailDeleteVirtualMachineScaleSetVM     bool
+	FailDeallocateVirtualMachineScaleSetVM bool
+	FailUpdateVirtualMachineScaleSetVMs    bool
+	FailSetVirtualMachineScaleSetCapacity  bool
+	FailListVirtualMachineScaleSetVMs      bool
+	FailGetStorageClient                   bool
+	FailDeleteNetworkInterface             bool
+	FailGetKubernetesClient                bool
+	FailListProviders                      bool
+	ShouldSupportVMIdentity                bool
+	FailDeleteRoleAssignment               bool
+	FailCreateRoleAssignment               bool
+	// FakeRoleAssignments records the role assignments created through CreateRoleAssignment,
+	// ListRoleAssignmentsForPrincipal returns those of the principal
+	FakeRoleAssignments                     []authorization.RoleAssignment
 	FailEnsureDefaultLogAnalyticsWorkspace  bool
 	FailAddContainerInsightsSolution        bool
 	FailGetLogAnalyticsWorkspaceInfo        bool
+	FailGetProximityPlacementGroup          bool
+	FailGetDiskEncryptionSet                bool
+	FailListNetworkInterfaces               bool
+	FailListStorageAccounts                 bool
+	FailDeleteStorageAccount                bool
+	FailGetDedicatedHostGroup               bool
+	FailGetDedicatedHost                    bool
+	FailGetSubnet                           bool
+	FailGetVirtualNetworkPeering            bool
+	FailListResourceSkus                    bool
 	MockKubernetesClient                    *MockKubernetesClient
 	FakeListVirtualMachineScaleSetsResult   func() []compute.VirtualMachineScaleSet
 	FakeListVirtualMachineResult            func() []compute.VirtualMachine
 	FakeListVirtualMachineScaleSetVMsResult func() []compute.VirtualMachineScaleSetVM
+	FakeGetProximityPlacementGroupResult    func() compute.ProximityPlacementGroup
+	FakeGetDiskEncryptionSetResult          func() compute.DiskEncryptionSet
+	FailListVirtualMachineImages            bool
+	FakeListVirtualMachineImagesResult      func() []compute.VirtualMachineImageResource
+	FakeListNetworkInterfacesResult         func() []network.Interface
+	// FakeListNetworkInterfacesByResourceGroup, if set, holds the network interfaces listed in each resource group
+	FakeListNetworkInterfacesByResourceGroup map[string][]network.Interface
+	// ResourceGroupCalls records "<method> <resource group>" for the network interface and subnet calls
+	ResourceGroupCalls                 []string
+	FakeListStorageAccountsResult      func() []storage.Account
+	FakeListManagedDisksResult         func() []compute.Disk
+	FakeGetDedicatedHostGroupResult    func() compute.DedicatedHostGroup
+	FakeGetDedicatedHostResult         func(name string) compute.DedicatedHost
+	FakeGetSubnetResult                func() network.Subnet
+	FakeGetVirtualNetworkPeeringResult func() network.VirtualNetworkPeering
+	FakeListResourceSkusResult         func() []compute.ResourceSku
+	FailCheckDeploymentExistence       bool
+	FakeCheckDeploymentExistenceResult func(name string) bool
+	FailRunCommand                     bool
+	FailListComputeUsages              bool
+	FakeListComputeUsagesResult        []compute.Usage
+	FailListManagementLocks            bool
+	FakeListManagementLocksResult      []ManagementLock
+	FailWhatIfDeployTemplate           bool
+	FakeWhatIfDeployTemplateResult     []WhatIfChange
+	// WhatIfDeploymentNames records the names of the deployments previewed by WhatIfDeployTemplate
+	WhatIfDeploymentNames []string
+	// RunCommandTargets records the VM names, or VMSS name/instance ID, commands were run on
+	RunCommandTargets []string
+	// FakeRunCommandOutput is the output message of the commands run, if set
+	FakeRunCommandOutput string
+	// ScaleSetVMOperations records the VMSS VM operations as operation:VMSS name/instance ID
+	ScaleSetVMOperations []string
+	// DeleteVirtualMachineFunc, if set, returns the error of the deletion of each VM
+	DeleteVirtualMachineFunc func(name string) error
+	// DeletedVirtualMachines records the names of the VMs deleted through DeleteVirtualMachine
+	DeletedVirtualMachines []string
+	// DeploymentNames records the names of the deployments of DeployTemplate
+	DeploymentNames []string
 }
 
 //MockStorageClient mock implementation of StorageClient
@@ -89,27 +177,82 @@ type MockStorageClient struct {
 
 //MockKubernetesClient mock implementation of KubernetesClient
 type MockKubernetesClient struct {
-	FailListPods              bool
-	FailListNodes             bool
-	FailListServiceAccounts   bool
-	FailGetNode               bool
-	UpdateNodeFunc            func(*v1.Node) (*v1.Node, error)
-	GetNodeFunc               func(name string) (*v1.Node, error)
-	FailUpdateNode            bool
-	FailDeleteNode            bool
-	FailDeleteServiceAccount  bool
-	FailSupportEviction       bool
-	FailDeletePod             bool
-	FailDeleteClusterRole     bool
-	FailDeleteDaemonSet       bool
-	FailDeleteDeployment      bool
-	FailEvictPod              bool
-	FailWaitForDelete         bool
-	ShouldSupportEviction     bool
-	PodsList                  *v1.PodList
+	FailListPods             bool
+	FailListNodes            bool
+	FailListServiceAccounts  bool
+	FailGetNode              bool
+	UpdateNodeFunc           func(*v1.Node) (*v1.Node, error)
+	GetNodeFunc              func(name string) (*v1.Node, error)
+	FailUpdateNode           bool
+	FailDeleteNode           bool
+	FailDeleteServiceAccount bool
+	FailSupportEviction      bool
+	FailDeletePod            bool
+	FailDeleteClusterRole    bool
+	FailDeleteDaemonSet      bool
+	FailDeleteDeployment     bool
+	FailEvictPod             bool
+	// EvictPodFunc, if set, returns the error of the eviction of each pod
+	EvictPodFunc          func(pod *v1.Pod) error
+	FailWaitForDelete     bool
+	ShouldSupportEviction bool
+	PodsList              *v1.PodList
+	NodesList             *v1.NodeList
+	// GracePeriodSeconds records the grace period of the last pod deletion or eviction
+	GracePeriodSeconds        *int64
 	ServiceAccountList        *v1.ServiceAccountList
 	FailGetDeploymentCount    int
 	FailUpdateDeploymentCount int
+	// Deployments, if set, holds the deployments returned and updated through the mock, keyed by namespace/name
+	Deployments map[string]*appsv1.Deployment
+
+	FailListCustomResourceDefinitions  bool
+	FailUpdateCustomResourceDefinition bool
+	CustomResourceDefinitions          *unstructured.UnstructuredList
+	UpdateCustomResourceDefinitionFunc func(*unstructured.Unstructured) (*unstructured.Unstructured, error)
+
+	FailGetConfigMap    bool
+	FailCreateConfigMap bool
+	FailUpdateConfigMap bool
+	// ConfigMaps holds the config maps created or updated through the mock, keyed by namespace/name
+	ConfigMaps map[string]*v1.ConfigMap
+
+	FailListResourceQuotas  bool
+	FailUpdateResourceQuota bool
+	// ResourceQuotaList holds the resource quotas, updated in place by UpdateResourceQuota
+	ResourceQuotaList *v1.ResourceQuotaList
+
+	FailListLimitRanges  bool
+	FailUpdateLimitRange bool
+	// LimitRangeList holds the limit ranges, updated in place by UpdateLimitRange
+	LimitRangeList *v1.LimitRangeList
+
+	FailListPodDisruptionBudgets bool
+	PodDisruptionBudgetList      *policy.PodDisruptionBudgetList
+
+	FailCreateEvent bool
+	// Events records the events created through the mock
+	Events []v1.Event
+
+	FailGetService bool
+	// Services holds the services returned by GetService, keyed by namespace/name; if nil the
+	// kubernetes service of the default namespace has the cluster IP 10.0.0.1
+	Services map[string]*v1.Service
+
+	FailCreatePod  bool
+	FailGetPod     bool
+	FailGetPodLogs bool
+	// PodPhase is the phase of the created pods returned by GetPod, Succeeded if empty
+	PodPhase v1.PodPhase
+	// PodLogs are the logs returned by GetPodLogs
+	PodLogs string
+	// CreatedPods and DeletedPods record the pods created and deleted through the mock
+	CreatedPods []v1.Pod
+	DeletedPods []v1.Pod
+
+	FailGetNodeProxy bool
+	// NodeProxyStatusCodes are the status codes returned by GetNodeProxy, keyed by path; 200 if not set
+	NodeProxyStatusCodes map[string]int
 }
 
 // MockVirtualMachineListResultPage contains a page of VirtualMachine values.
@@ -188,6 +331,88 @@ func (page MockVirtualMachineScaleSetListResultPage) Values() []compute.VirtualM
 	return *page.Vmsslr.Value
 }
 
+// MockDiskListPage contains a page of Disk values.
+type MockDiskListPage struct {
+	Fn func(compute.DiskList) (compute.DiskList, error)
+	Dl compute.DiskList
+}
+
+// Next advances to the next page of values.  If there was an error making
+// the request the page does not advance and the error is returned.
+func (page *MockDiskListPage) Next() error {
+	return page.NextWithContext(context.Background())
+}
+
+// NextWithContext advances to the next page of values.  If there was an error making
+// the request the page does not advance and the error is returned.
+func (page *MockDiskListPage) NextWithContext(ctx context.Context) (err error) {
+	next, err := page.Fn(page.Dl)
+	if err != nil {
+		return err
+	}
+	page.Dl = next
+	return nil
+}
+
+// NotDone returns true if the page enumeration should be started or is not yet complete.
+func (page MockDiskListPage) NotDone() bool {
+	return !page.Dl.IsEmpty()
+}
+
+// Response returns the raw server response from the last page request.
+func (page MockDiskListPage) Response() compute.DiskList {
+	return page.Dl
+}
+
+// Values returns the slice of values for the current page or nil if there are no values.
+func (page MockDiskListPage) Values() []compute.Disk {
+	if page.Dl.IsEmpty() {
+		return nil
+	}
+	return *page.Dl.Value
+}
+
+// MockResourceSkusResultPage contains a page of ResourceSku values.
+type MockResourceSkusResultPage struct {
+	Fn  func(compute.ResourceSkusResult) (compute.ResourceSkusResult, error)
+	Rsr compute.ResourceSkusResult
+}
+
+// Next advances to the next page of values.  If there was an error making
+// the request the page does not advance and the error is returned.
+func (page *MockResourceSkusResultPage) Next() error {
+	return page.NextWithContext(context.Background())
+}
+
+// NextWithContext advances to the next page of values.  If there was an error making
+// the request the page does not advance and the error is returned.
+func (page *MockResourceSkusResultPage) NextWithContext(ctx context.Context) (err error) {
+	next, err := page.Fn(page.Rsr)
+	if err != nil {
+		return err
+	}
+	page.Rsr = next
+	return nil
+}
+
+// NotDone returns true if the page enumeration should be started or is not yet complete.
+func (page MockResourceSkusResultPage) NotDone() bool {
+	return !page.Rsr.IsEmpty()
+}
+
+// Response returns the raw server response from the last page request.
+func (page MockResourceSkusResultPage) Response() compute.ResourceSkusResult {
+	return page.Rsr
+}
+
+// Values returns the slice of values for the current page or nil if there are no values.
+func (page MockResourceSkusResultPage) Values() []compute.ResourceSku {
+	if page.Rsr.IsEmpty() {
+		return nil
+	}
+	return *page.Rsr.Value
+}
+
 // MockVirtualMachineScaleSetVMListResultPage contains a page of VMSS VirtualMachine values.
 type MockVirtualMachineScaleSetVMListResultPage struct {
 	Fn      func(compute.VirtualMachineScaleSetVMListResult) (compute.VirtualMachineScaleSetVMListResult, error)
@@ -229,6 +454,41 @@ func (page MockVirtualMachineScaleSetVMListResultPage) Values() []compute.Virtua
 	return *page.Vmssvlr.Value
 }
 
+// MockDeploymentListResultPage contains a page of DeploymentExtended values.
+type MockDeploymentListResultPage struct {
+	Fn  func(resources.DeploymentListResult) (resources.DeploymentListResult, error)
+	Dlr resources.DeploymentListResult
+}
+
+// Next advances to the next page of values.  If there was an error making
+// the request the page does not advance and the error is returned.
+func (page *MockDeploymentListResultPage) Next() error {
+	next, err := page.Fn(page.Dlr)
+	if err != nil {
+		return err
+	}
+	page.Dlr = next
+	return nil
+}
+
+// NotDone returns true if the page enumeration should be started or is not yet complete.
+func (page MockDeploymentListResultPage) NotDone() bool {
+	return !page.Dlr.IsEmpty()
+}
+
+// Response returns the raw server response from the last page request.
+func (page MockDeploymentListResultPage) Response() resources.DeploymentListResult {
+	return page.Dlr
+}
+
+// Values returns the slice of values for the current page or nil if there are no values.
+func (page MockDeploymentListResultPage) Values() []resources.DeploymentExtended {
+	if page.Dlr.IsEmpty() {
+		return nil
+	}
+	return *page.Dlr.Value
+}
+
 // MockDeploymentOperationsListResultPage contains a page of DeploymentOperation values.
 type MockDeploymentOperationsListResultPage struct {
 	Fn   func(resources.DeploymentOperationsListResult) (resources.DeploymentOperationsListResult, error)
@@ -326,6 +586,9 @@ func (mkc *MockKubernetesClient) ListNodes() (*v1.NodeList, error) {
 	if mkc.FailListNodes {
 		return nil, errors.New("ListNodes failed")
 	}
+	if mkc.NodesList != nil {
+		return mkc.NodesList, nil
+	}
 	node := &v1.Node{}
 	node.Name = fmt.Sprintf("%s-1234", common.LegacyControlPlaneVMPrefix)
 	node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue})
@@ -379,6 +642,17 @@ func (mkc *MockKubernetesClient) GetNode(name string) (*v1.Node, error) {
 	return node, nil
 }
 
+// GetNodeProxy returns the status code of a GET request for path to the kubelet of the node
+func (mkc *MockKubernetesClient) GetNodeProxy(name, path string) (int, error) {
+	if mkc.FailGetNodeProxy {
+		return 0, errors.New("GetNodeProxy failed")
+	}
+	if statusCode, ok := mkc.NodeProxyStatusCodes[path]; ok {
+		return statusCode, nil
+	}
+	return http.StatusOK, nil
+}
+
 //UpdateNode updates the node in the api server with the passed in info
 func (mkc *MockKubernetesClient) UpdateNode(node *v1.Node) (*v1.Node, error) {
 	if mkc.UpdateNodeFunc != nil {
@@ -442,18 +716,26 @@ func (mkc *MockKubernetesClient) DeleteDeployment(pod *appsv1.Deployment) error
 }
 
 //DeletePod deletes the passed in pod
-func (mkc *MockKubernetesClient) DeletePod(pod *v1.Pod) error {
+func (mkc *MockKubernetesClient) DeletePod(pod *v1.Pod, gracePeriodSeconds *int64) error {
 	if mkc.FailDeletePod {
 		return errors.New("DeletePod failed")
 	}
+	mkc.GracePeriodSeconds = gracePeriodSeconds
+	mkc.DeletedPods = append(mkc.DeletedPods, *pod.DeepCopy())
 	return nil
 }
 
 //EvictPod evicts the passed in pod using the passed in api version
-func (mkc *MockKubernetesClient) EvictPod(pod *v1.Pod, policyGroupVersion string) error {
+func (mkc *MockKubernetesClient) EvictPod(pod *v1.Pod, policyGroupVersion string, gracePeriodSeconds *int64) error {
 	if mkc.FailEvictPod {
 		return errors.New("EvictPod failed")
 	}
+	if mkc.EvictPodFunc != nil {
+		if err := mkc.EvictPodFunc(pod); err != nil {
+			return err
+		}
+	}
+	mkc.GracePeriodSeconds = gracePeriodSeconds
 	return nil
 }
 
@@ -478,6 +760,12 @@ func (mkc *MockKubernetesClient) GetDeployment(namespace, name string) (*appsv1.
 		mkc.FailGetDeploymentCount--
 		return nil, errors.New("GetDeployment failed")
 	}
+	if mkc.Deployments != nil {
+		if d, ok := mkc.Deployments[namespace+"/"+name]; ok {
+			return d.DeepCopy(), nil
+		}
+		return nil, apierrors.NewNotFound(appsv1.Resource("deployments"), name)
+	}
 	var replicas int32 = 1
 	return &appsv1.Deployment{
 		Spec: appsv1.DeploymentSpec{
@@ -492,9 +780,220 @@ func (mkc *MockKubernetesClient) UpdateDeployment(namespace string, deployment *
 		mkc.FailUpdateDeploymentCount--
 		return nil, errors.New("UpdateDeployment failed")
 	}
+	if mkc.Deployments != nil {
+		mkc.Deployments[namespace+"/"+deployment.Name] = deployment.DeepCopy()
+		return deployment, nil
+	}
 	return &appsv1.Deployment{}, nil
 }
 
+// GetConfigMap returns a given config map in a namespace.
+func (mkc *MockKubernetesClient) GetConfigMap(namespace, name string) (*v1.ConfigMap, error) {
+	if mkc.FailGetConfigMap {
+		return nil, errors.New("GetConfigMap failed")
+	}
+	if cm, ok := mkc.ConfigMaps[namespace+"/"+name]; ok {
+		return cm.DeepCopy(), nil
+	}
+	return nil, apierrors.NewNotFound(v1.Resource("configmaps"), name)
+}
+
+// CreateConfigMap creates the passed in config map.
+func (mkc *MockKubernetesClient) CreateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if mkc.FailCreateConfigMap {
+		return nil, errors.New("CreateConfigMap failed")
+	}
+	key := configMap.Namespace + "/" + configMap.Name
+	if _, ok := mkc.ConfigMaps[key]; ok {
+		return nil, apierrors.NewAlreadyExists(v1.Resource("configmaps"), configMap.Name)
+	}
+	if mkc.ConfigMaps == nil {
+		mkc.ConfigMaps = map[string]*v1.ConfigMap{}
+	}
+	mkc.ConfigMaps[key] = configMap.DeepCopy()
+	return configMap, nil
+}
+
+// UpdateConfigMap updates a config map to match the given specification.
+func (mkc *MockKubernetesClient) UpdateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if mkc.FailUpdateConfigMap {
+		return nil, errors.New("UpdateConfigMap failed")
+	}
+	key := configMap.Namespace + "/" + configMap.Name
+	if _, ok := mkc.ConfigMaps[key]; !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("configmaps"), configMap.Name)
+	}
+	mkc.ConfigMaps[key] = configMap.DeepCopy()
+	return configMap, nil
+}
+
+// ListResourceQuotas returns the resource quotas of a namespace, or of all namespaces if namespace is empty.
+func (mkc *MockKubernetesClient) ListResourceQuotas(namespace string) (*v1.ResourceQuotaList, error) {
+	if mkc.FailListResourceQuotas {
+		return nil, errors.New("ListResourceQuotas failed")
+	}
+	list := &v1.ResourceQuotaList{}
+	if mkc.ResourceQuotaList != nil {
+		for _, quota := range mkc.ResourceQuotaList.Items {
+			if namespace == "" || quota.Namespace == namespace {
+				list.Items = append(list.Items, *quota.DeepCopy())
+			}
+		}
+	}
+	return list, nil
+}
+
+// UpdateResourceQuota updates a resource quota to match the given specification.
+func (mkc *MockKubernetesClient) UpdateResourceQuota(quota *v1.ResourceQuota) (*v1.ResourceQuota, error) {
+	if mkc.FailUpdateResourceQuota {
+		return nil, errors.New("UpdateResourceQuota failed")
+	}
+	if mkc.ResourceQuotaList != nil {
+		for i, q := range mkc.ResourceQuotaList.Items {
+			if q.Namespace == quota.Namespace && q.Name == quota.Name {
+				mkc.ResourceQuotaList.Items[i] = *quota.DeepCopy()
+				return quota, nil
+			}
+		}
+	}
+	return nil, apierrors.NewNotFound(v1.Resource("resourcequotas"), quota.Name)
+}
+
+// ListLimitRanges returns the limit ranges of a namespace, or of all namespaces if namespace is empty.
+func (mkc *MockKubernetesClient) ListLimitRanges(namespace string) (*v1.LimitRangeList, error) {
+	if mkc.FailListLimitRanges {
+		return nil, errors.New("ListLimitRanges failed")
+	}
+	list := &v1.LimitRangeList{}
+	if mkc.LimitRangeList != nil {
+		for _, limitRange := range mkc.LimitRangeList.Items {
+			if namespace == "" || limitRange.Namespace == namespace {
+				list.Items = append(list.Items, *limitRange.DeepCopy())
+			}
+		}
+	}
+	return list, nil
+}
+
+// ListPodDisruptionBudgets returns the pod disruption budgets of a namespace, or of all namespaces if namespace is empty.
+func (mkc *MockKubernetesClient) ListPodDisruptionBudgets(namespace string) (*policy.PodDisruptionBudgetList, error) {
+	if mkc.FailListPodDisruptionBudgets {
+		return nil, errors.New("ListPodDisruptionBudgets failed")
+	}
+	list := &policy.PodDisruptionBudgetList{}
+	if mkc.PodDisruptionBudgetList != nil {
+		for _, pdb := range mkc.PodDisruptionBudgetList.Items {
+			if namespace == "" || pdb.Namespace == namespace {
+				list.Items = append(list.Items, *pdb.DeepCopy())
+			}
+		}
+	}
+	return list, nil
+}
+
+// UpdateLimitRange updates a limit range to match the given specification.
+func (mkc *MockKubernetesClient) UpdateLimitRange(limitRange *v1.LimitRange) (*v1.LimitRange, error) {
+	if mkc.FailUpdateLimitRange {
+		return nil, errors.New("UpdateLimitRange failed")
+	}
+	if mkc.LimitRangeList != nil {
+		for i, l := range mkc.LimitRangeList.Items {
+			if l.Namespace == limitRange.Namespace && l.Name == limitRange.Name {
+				mkc.LimitRangeList.Items[i] = *limitRange.DeepCopy()
+				return limitRange, nil
+			}
+		}
+	}
+	return nil, apierrors.NewNotFound(v1.Resource("limitranges"), limitRange.Name)
+}
+
+// CreateEvent records an event in the api server.
+func (mkc *MockKubernetesClient) CreateEvent(event *v1.Event) (*v1.Event, error) {
+	if mkc.FailCreateEvent {
+		return nil, errors.New("CreateEvent failed")
+	}
+	mkc.Events = append(mkc.Events, *event.DeepCopy())
+	return event, nil
+}
+
+// GetService returns a given service in a namespace.
+func (mkc *MockKubernetesClient) GetService(namespace, name string) (*v1.Service, error) {
+	if mkc.FailGetService {
+		return nil, errors.New("GetService failed")
+	}
+	if mkc.Services == nil {
+		if namespace == metav1.NamespaceDefault && name == "kubernetes" {
+			return &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Spec:       v1.ServiceSpec{ClusterIP: "10.0.0.1"},
+			}, nil
+		}
+		return nil, fmt.Errorf("service %s/%s not found", namespace, name)
+	}
+	service, ok := mkc.Services[namespace+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("service %s/%s not found", namespace, name)
+	}
+	return service, nil
+}
+
+// CreatePod creates the passed in pod.
+func (mkc *MockKubernetesClient) CreatePod(pod *v1.Pod) (*v1.Pod, error) {
+	if mkc.FailCreatePod {
+		return nil, errors.New("CreatePod failed")
+	}
+	mkc.CreatedPods = append(mkc.CreatedPods, *pod.DeepCopy())
+	return pod, nil
+}
+
+// GetPod returns a pod created through the mock, in phase PodPhase.
+func (mkc *MockKubernetesClient) GetPod(namespace, name string) (*v1.Pod, error) {
+	if mkc.FailGetPod {
+		return nil, errors.New("GetPod failed")
+	}
+	for _, pod := range mkc.CreatedPods {
+		if pod.Namespace == namespace && pod.Name == name {
+			p := pod.DeepCopy()
+			p.Status.Phase = v1.PodSucceeded
+			if mkc.PodPhase != "" {
+				p.Status.Phase = mkc.PodPhase
+			}
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("pod %s/%s not found", namespace, name)
+}
+
+// GetPodLogs returns PodLogs.
+func (mkc *MockKubernetesClient) GetPodLogs(namespace, name string) (string, error) {
+	if mkc.FailGetPodLogs {
+		return "", errors.New("GetPodLogs failed")
+	}
+	return mkc.PodLogs, nil
+}
+
+// ListCustomResourceDefinitions returns the CustomResourceDefinitions registered in the api server.
+func (mkc *MockKubernetesClient) ListCustomResourceDefinitions() (*unstructured.UnstructuredList, error) {
+	if mkc.FailListCustomResourceDefinitions {
+		return nil, errors.New("ListCustomResourceDefinitions failed")
+	}
+	if mkc.CustomResourceDefinitions != nil {
+		return mkc.CustomResourceDefinitions, nil
+	}
+	return &unstructured.UnstructuredList{}, nil
+}
+
+// UpdateCustomResourceDefinition updates a CustomResourceDefinition to match the given specification.
+func (mkc *MockKubernetesClient) UpdateCustomResourceDefinition(crd *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if mkc.UpdateCustomResourceDefinitionFunc != nil {
+		return mkc.UpdateCustomResourceDefinitionFunc(crd)
+	}
+	if mkc.FailUpdateCustomResourceDefinition {
+		return nil, errors.New("UpdateCustomResourceDefinition failed")
+	}
+	return crd, nil
+}
+
 //DeleteBlob mock
 func (msc *MockStorageClient) DeleteBlob(container, blob string, options *azStorage.DeleteBlobOptions) error {
 	return nil
@@ -522,8 +1021,92 @@ func (mc *MockAKSEngineClient) AddAcceptLanguages(languages []string) {}
 // AddAuxiliaryTokens mock
 func (mc *MockAKSEngineClient) AddAuxiliaryTokens(tokens []string) {}
 
+//CheckDeploymentExistence mock
+func (mc *MockAKSEngineClient) CheckDeploymentExistence(ctx context.Context, resourceGroupName string, deploymentName string) (autorest.Response, error) {
+	if mc.FailCheckDeploymentExistence {
+		return autorest.Response{}, errors.New("CheckDeploymentExistence failed")
+	}
+	statusCode := http.StatusNotFound
+	if mc.FakeCheckDeploymentExistenceResult != nil && mc.FakeCheckDeploymentExistenceResult(deploymentName) {
+		statusCode = http.StatusNoContent
+	}
+	return autorest.Response{Response: &http.Response{StatusCode: statusCode}}, nil
+}
+
+//BeginDeployTemplate mock
+func (mc *MockAKSEngineClient) BeginDeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) error {
+	if mc.FailDeployTemplate {
+		return errors.New("BeginDeployTemplate failed")
+	}
+	return nil
+}
+
+//DeployTemplateWithMode mock
+func (mc *MockAKSEngineClient) DeployTemplateWithMode(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}, mode resources.DeploymentMode) (resources.DeploymentExtended, error) {
+	mc.DeploymentModes = append(mc.DeploymentModes, mode)
+	mc.DeploymentCorrelationIDs = append(mc.DeploymentCorrelationIDs, CorrelationID(ctx))
+	return mc.DeployTemplate(ctx, resourceGroup, name, template, parameters)
+}
+
+//BeginDeployTemplateWithMode mock
+func (mc *MockAKSEngineClient) BeginDeployTemplateWithMode(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}, mode resources.DeploymentMode) error {
+	mc.DeploymentModes = append(mc.DeploymentModes, mode)
+	mc.DeploymentCorrelationIDs = append(mc.DeploymentCorrelationIDs, CorrelationID(ctx))
+	return mc.BeginDeployTemplate(ctx, resourceGroup, name, template, parameters)
+}
+
+//DeployTemplateLinkWithMode mock
+func (mc *MockAKSEngineClient) DeployTemplateLinkWithMode(ctx context.Context, resourceGroup, name, templateURI string, parameters map[string]interface{}, mode resources.DeploymentMode) (resources.DeploymentExtended, error) {
+	mc.TemplateLinkURIs = append(mc.TemplateLinkURIs, templateURI)
+	return mc.DeployTemplateWithMode(ctx, resourceGroup, name, nil, parameters, mode)
+}
+
+//BeginDeployTemplateLinkWithMode mock
+func (mc *MockAKSEngineClient) BeginDeployTemplateLinkWithMode(ctx context.Context, resourceGroup, name, templateURI string, parameters map[string]interface{}, mode resources.DeploymentMode) error {
+	mc.TemplateLinkURIs = append(mc.TemplateLinkURIs, templateURI)
+	return mc.BeginDeployTemplateWithMode(ctx, resourceGroup, name, nil, parameters, mode)
+}
+
+//WhatIfDeployTemplate mock
+func (mc *MockAKSEngineClient) WhatIfDeployTemplate(ctx context.Context, resourceGroup, deploymentName string, template, parameters map[string]interface{}) ([]WhatIfChange, error) {
+	mc.WhatIfDeploymentNames = append(mc.WhatIfDeploymentNames, deploymentName)
+	if mc.FailWhatIfDeployTemplate {
+		return nil, errors.New("WhatIfDeployTemplate failed")
+	}
+	return mc.FakeWhatIfDeployTemplateResult, nil
+}
+
+//GetDeployment mock
+func (mc *MockAKSEngineClient) GetDeployment(ctx context.Context, resourceGroupName, deploymentName string) (resources.DeploymentExtended, error) {
+	if mc.FailGetDeployment {
+		return resources.DeploymentExtended{}, errors.New("GetDeployment failed")
+	}
+	if mc.FakeGetDeploymentResult != nil {
+		return mc.FakeGetDeploymentResult(deploymentName), nil
+	}
+	return resources.DeploymentExtended{
+		Name: to.StringPtr(deploymentName),
+		Properties: &resources.DeploymentPropertiesExtended{
+			ProvisioningState: to.StringPtr("Succeeded"),
+		},
+	}, nil
+}
+
+//GetDeploymentOutputs mock
+func (mc *MockAKSEngineClient) GetDeploymentOutputs(ctx context.Context, resourceGroupName, deploymentName string) (map[string]interface{}, error) {
+	if mc.FailGetDeploymentOutputs {
+		return nil, errors.New("GetDeploymentOutputs failed")
+	}
+	outputs := map[string]interface{}{}
+	for name, value := range mc.FakeDeploymentOutputs {
+		outputs[name] = value
+	}
+	return outputs, nil
+}
+
 //DeployTemplate mock
 func (mc *MockAKSEngineClient) DeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) (de resources.DeploymentExtended, err error) {
+	mc.DeploymentNames = append(mc.DeploymentNames, name)
 	switch {
 	case mc.FailDeployTemplate:
 		return de, errors.New("DeployTemplate failed")
@@ -613,7 +1196,19 @@ func (mc *MockAKSEngineClient) EnsureResourceGroup(ctx context.Context, resource
 
 // ListResourceSkus mock
 func (mc *MockAKSEngineClient) ListResourceSkus(ctx context.Context, filter string) (ResourceSkusResultPage, error) {
-	return nil, nil
+	if mc.FailListResourceSkus {
+		return nil, errors.New("ListResourceSkus failed")
+	}
+	skus := []compute.ResourceSku{}
+	if mc.FakeListResourceSkusResult != nil {
+		skus = mc.FakeListResourceSkusResult()
+	}
+	return &MockResourceSkusResultPage{
+		Fn: func(compute.ResourceSkusResult) (compute.ResourceSkusResult, error) {
+			return compute.ResourceSkusResult{}, nil
+		},
+		Rsr: compute.ResourceSkusResult{Value: &skus},
+	}, nil
 }
 
 //ListVirtualMachines mock
@@ -684,7 +1279,23 @@ func (mc *MockAKSEngineClient) GetVirtualMachine(ctx context.Context, resourceGr
 	if mc.FailGetVirtualMachine {
 		return compute.VirtualMachine{}, errors.New("GetVirtualMachine failed")
 	}
-	return mc.MakeFakeVirtualMachine(DefaultFakeVMName, defaultK8sVersionForFakeVMs), nil
+	vm := mc.MakeFakeVirtualMachine(DefaultFakeVMName, defaultK8sVersionForFakeVMs)
+	if mc.FakeGetVirtualMachineZones != nil {
+		vm.Zones = &mc.FakeGetVirtualMachineZones
+	}
+	if mc.FakeGetVirtualMachineIdentity != nil {
+		vm.Identity = mc.FakeGetVirtualMachineIdentity
+	}
+	if mc.FakeGetVirtualMachineOSDisk != nil {
+		vm.StorageProfile.OsDisk = mc.FakeGetVirtualMachineOSDisk
+	}
+	if mc.FakeGetVirtualMachineDataDisks != nil {
+		vm.StorageProfile.DataDisks = mc.FakeGetVirtualMachineDataDisks
+	}
+	if mc.FakeGetVirtualMachineDiagnosticsProfile != nil {
+		vm.DiagnosticsProfile = mc.FakeGetVirtualMachineDiagnosticsProfile
+	}
+	return vm, nil
 }
 
 // RestartVirtualMachine mock
@@ -810,7 +1421,12 @@ func (mc *MockAKSEngineClient) DeleteVirtualMachine(ctx context.Context, resourc
 	if mc.FailDeleteVirtualMachine {
 		return errors.New("DeleteVirtualMachine failed")
 	}
-
+	if mc.DeleteVirtualMachineFunc != nil {
+		if err := mc.DeleteVirtualMachineFunc(name); err != nil {
+			return err
+		}
+	}
+	mc.DeletedVirtualMachines = append(mc.DeletedVirtualMachines, name)
 	return nil
 }
 
@@ -819,6 +1435,31 @@ func (mc *MockAKSEngineClient) DeleteVirtualMachineScaleSetVM(ctx context.Contex
 	if mc.FailDeleteVirtualMachineScaleSetVM {
 		return errors.New("DeleteVirtualMachineScaleSetVM failed")
 	}
+	mc.ScaleSetVMOperations = append(mc.ScaleSetVMOperations, "delete:"+virtualMachineScaleSet+"/"+instanceID)
+
+	return nil
+}
+
+//DeallocateVirtualMachineScaleSetVM mock
+func (mc *MockAKSEngineClient) DeallocateVirtualMachineScaleSetVM(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string) error {
+	if mc.FailDeallocateVirtualMachineScaleSetVM {
+		return errors.New("DeallocateVirtualMachineScaleSetVM failed")
+	}
+	mc.ScaleSetVMOperations = append(mc.ScaleSetVMOperations, "deallocate:"+virtualMachineScaleSet+"/"+instanceID)
+
+	return nil
+}
+
+//UpdateVirtualMachineScaleSetVMs mock
+func (mc *MockAKSEngineClient) UpdateVirtualMachineScaleSetVMs(ctx context.Context, resourceGroup, virtualMachineScaleSet string, instanceIDs compute.VirtualMachineScaleSetVMInstanceRequiredIDs) error {
+	if mc.FailUpdateVirtualMachineScaleSetVMs {
+		return errors.New("UpdateVirtualMachineScaleSetVMs failed")
+	}
+	if instanceIDs.InstanceIds != nil {
+		for _, id := range *instanceIDs.InstanceIds {
+			mc.ScaleSetVMOperations = append(mc.ScaleSetVMOperations, "update:"+virtualMachineScaleSet+"/"+id)
+		}
+	}
 
 	return nil
 }
@@ -878,6 +1519,7 @@ func (mc *MockAKSEngineClient) GetStorageClient(ctx context.Context, resourceGro
 
 //DeleteNetworkInterface mock
 func (mc *MockAKSEngineClient) DeleteNetworkInterface(ctx context.Context, resourceGroup, nicName string) error {
+	mc.ResourceGroupCalls = append(mc.ResourceGroupCalls, "DeleteNetworkInterface "+resourceGroup)
 	if mc.FailDeleteNetworkInterface {
 		return errors.New("DeleteNetworkInterface failed")
 	}
@@ -885,6 +1527,77 @@ func (mc *MockAKSEngineClient) DeleteNetworkInterface(ctx context.Context, resou
 	return nil
 }
 
+//ListNetworkInterfaces mock
+func (mc *MockAKSEngineClient) ListNetworkInterfaces(ctx context.Context, resourceGroup string) ([]network.Interface, error) {
+	mc.ResourceGroupCalls = append(mc.ResourceGroupCalls, "ListNetworkInterfaces "+resourceGroup)
+	if mc.FailListNetworkInterfaces {
+		return nil, errors.New("ListNetworkInterfaces failed")
+	}
+	if mc.FakeListNetworkInterfacesByResourceGroup != nil {
+		return mc.FakeListNetworkInterfacesByResourceGroup[resourceGroup], nil
+	}
+	if mc.FakeListNetworkInterfacesResult != nil {
+		return mc.FakeListNetworkInterfacesResult(), nil
+	}
+	return []network.Interface{}, nil
+}
+
+//GetSubnet mock
+func (mc *MockAKSEngineClient) GetSubnet(ctx context.Context, resourceGroup, vnetName, subnetName string) (network.Subnet, error) {
+	mc.ResourceGroupCalls = append(mc.ResourceGroupCalls, "GetSubnet "+resourceGroup)
+	if mc.FailGetSubnet {
+		return network.Subnet{}, errors.New("GetSubnet failed")
+	}
+	if mc.FakeGetSubnetResult != nil {
+		return mc.FakeGetSubnetResult(), nil
+	}
+	return network.Subnet{
+		Name: to.StringPtr(subnetName),
+		SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+			AddressPrefix:    to.StringPtr("10.240.0.0/16"),
+			IPConfigurations: &[]network.IPConfiguration{},
+		},
+	}, nil
+}
+
+//GetVirtualNetworkPeering mock
+func (mc *MockAKSEngineClient) GetVirtualNetworkPeering(ctx context.Context, resourceGroup, vnetName, peeringName string) (network.VirtualNetworkPeering, error) {
+	if mc.FailGetVirtualNetworkPeering {
+		return network.VirtualNetworkPeering{}, errors.New("GetVirtualNetworkPeering failed")
+	}
+	if mc.FakeGetVirtualNetworkPeeringResult != nil {
+		return mc.FakeGetVirtualNetworkPeeringResult(), nil
+	}
+	return network.VirtualNetworkPeering{
+		Name: to.StringPtr(peeringName),
+		VirtualNetworkPeeringPropertiesFormat: &network.VirtualNetworkPeeringPropertiesFormat{
+			PeeringState: network.VirtualNetworkPeeringStateConnected,
+			RemoteVirtualNetwork: &network.SubResource{
+				ID: to.StringPtr("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/HubRg/providers/Microsoft.Network/virtualNetworks/hub-vnet"),
+			},
+		},
+	}, nil
+}
+
+//ListStorageAccounts mock
+func (mc *MockAKSEngineClient) ListStorageAccounts(ctx context.Context, resourceGroup string) ([]storage.Account, error) {
+	if mc.FailListStorageAccounts {
+		return nil, errors.New("ListStorageAccounts failed")
+	}
+	if mc.FakeListStorageAccountsResult != nil {
+		return mc.FakeListStorageAccountsResult(), nil
+	}
+	return []storage.Account{}, nil
+}
+
+//DeleteStorageAccount mock
+func (mc *MockAKSEngineClient) DeleteStorageAccount(ctx context.Context, resourceGroup, accountName string) error {
+	if mc.FailDeleteStorageAccount {
+		return errors.New("DeleteStorageAccount failed")
+	}
+	return nil
+}
+
 var validOSDiskResourceName = "https://00k71r4u927seqiagnt0.blob.core.windows.net/osdisk/k8s-agentpool1-12345678-0-osdisk.vhd"
 var validNicResourceName = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/acsK8sTest/providers/Microsoft.Network/networkInterfaces/k8s-agent-12345678-nic-0"
 
@@ -926,7 +1639,23 @@ func (mc *MockAKSEngineClient) CreateUserAssignedID(location string, resourceGro
 
 // CreateRoleAssignment creates a role assignment via the authorization client
 func (mc *MockAKSEngineClient) CreateRoleAssignment(ctx context.Context, scope string, roleAssignmentName string, parameters authorization.RoleAssignmentCreateParameters) (authorization.RoleAssignment, error) {
-	return authorization.RoleAssignment{}, nil
+	if mc.FailCreateRoleAssignment {
+		return authorization.RoleAssignment{}, errors.New("CreateRoleAssignment failed")
+	}
+	id := scope + "/providers/Microsoft.Authorization/roleAssignments/" + roleAssignmentName
+	assignment := authorization.RoleAssignment{
+		ID:   &id,
+		Name: &roleAssignmentName,
+		Properties: &authorization.RoleAssignmentPropertiesWithScope{
+			Scope: &scope,
+		},
+	}
+	if parameters.Properties != nil {
+		assignment.Properties.RoleDefinitionID = parameters.Properties.RoleDefinitionID
+		assignment.Properties.PrincipalID = parameters.Properties.PrincipalID
+	}
+	mc.FakeRoleAssignments = append(mc.FakeRoleAssignments, assignment)
+	return assignment, nil
 }
 
 // CreateRoleAssignmentSimple is a wrapper around RoleAssignmentsClient.Create
@@ -939,9 +1668,30 @@ func (mc *MockAKSEngineClient) DeleteManagedDisk(ctx context.Context, resourceGr
 	return nil
 }
 
+// UpdateManagedDiskTags records the tags in UpdatedManagedDiskTags
+func (mc *MockAKSEngineClient) UpdateManagedDiskTags(ctx context.Context, resourceGroupName, diskName string, tags map[string]*string) error {
+	if mc.FailUpdateManagedDiskTags {
+		return errors.New("UpdateManagedDiskTags failed")
+	}
+	if mc.UpdatedManagedDiskTags == nil {
+		mc.UpdatedManagedDiskTags = map[string]map[string]*string{}
+	}
+	mc.UpdatedManagedDiskTags[diskName] = tags
+	return nil
+}
+
 // ListManagedDisksByResourceGroup is a wrapper around disksClient.ListManagedDisksByResourceGroup
 func (mc *MockAKSEngineClient) ListManagedDisksByResourceGroup(ctx context.Context, resourceGroupName string) (result DiskListPage, err error) {
-	return &compute.DiskListPage{}, nil
+	if mc.FakeListManagedDisksResult == nil {
+		return &compute.DiskListPage{}, nil
+	}
+	disks := mc.FakeListManagedDisksResult()
+	return &MockDiskListPage{
+		Fn: func(lastResults compute.DiskList) (compute.DiskList, error) {
+			return compute.DiskList{}, nil
+		},
+		Dl: compute.DiskList{Value: &disks},
+	}, nil
 }
 
 //GetKubernetesClient mock
@@ -965,6 +1715,36 @@ func (mc *MockAKSEngineClient) ListProviders(ctx context.Context) (ProviderListR
 	return &resources.ProviderListResultPage{}, nil
 }
 
+// ListDeployments returns FakeDeployments
+func (mc *MockAKSEngineClient) ListDeployments(ctx context.Context, resourceGroupName string) (DeploymentListResultPage, error) {
+	if mc.FailListDeployments {
+		return &MockDeploymentListResultPage{}, errors.New("ListDeployments failed")
+	}
+	deployments := append([]resources.DeploymentExtended{}, mc.FakeDeployments...)
+	return &MockDeploymentListResultPage{
+		Fn: func(resources.DeploymentListResult) (resources.DeploymentListResult, error) {
+			return resources.DeploymentListResult{}, nil
+		},
+		Dlr: resources.DeploymentListResult{Value: &deployments},
+	}, nil
+}
+
+// DeleteDeployment removes the deployment from FakeDeployments
+func (mc *MockAKSEngineClient) DeleteDeployment(ctx context.Context, resourceGroupName, deploymentName string) error {
+	if mc.FailDeleteDeployment {
+		return errors.New("DeleteDeployment failed")
+	}
+	mc.DeletedDeployments = append(mc.DeletedDeployments, deploymentName)
+	deployments := mc.FakeDeployments[:0]
+	for _, d := range mc.FakeDeployments {
+		if d.Name == nil || *d.Name != deploymentName {
+			deployments = append(deployments, d)
+		}
+	}
+	mc.FakeDeployments = deployments
+	return nil
+}
+
 // ListDeploymentOperations gets all deployments operations for a deployment.
 func (mc *MockAKSEngineClient) ListDeploymentOperations(ctx context.Context, resourceGroupName string, deploymentName string, top *int32) (result DeploymentOperationsListResultPage, err error) {
 	resp := `{
@@ -1052,8 +1832,16 @@ func (mc *MockAKSEngineClient) ListRoleAssignmentsForPrincipal(ctx context.Conte
 			ID: &assignmentID}
 		roleAssignments = append(roleAssignments, assignment)
 	}
+	for _, assignment := range mc.FakeRoleAssignments {
+		if assignment.Properties != nil && assignment.Properties.PrincipalID != nil && *assignment.Properties.PrincipalID == principalID {
+			roleAssignments = append(roleAssignments, assignment)
+		}
+	}
 
 	return &MockRoleAssignmentListResultPage{
+		Fn: func(authorization.RoleAssignmentListResult) (authorization.RoleAssignmentListResult, error) {
+			return authorization.RoleAssignmentListResult{}, nil
+		},
 		Ralr: authorization.RoleAssignmentListResult{
 			Value: &roleAssignments,
 		},
@@ -1096,3 +1884,156 @@ func (mc *MockAKSEngineClient) GetVirtualMachinePowerState(ctx context.Context,
 func (mc *MockAKSEngineClient) GetVirtualMachineScaleSetInstancePowerState(ctx context.Context, resourceGroup, name, instanceID string) (string, error) {
 	return "", nil
 }
+
+// GetVirtualMachineInstanceView mock
+func (mc *MockAKSEngineClient) GetVirtualMachineInstanceView(ctx context.Context, resourceGroup, name string) (compute.VirtualMachineInstanceView, error) {
+	if mc.FailGetVirtualMachineInstanceView {
+		return compute.VirtualMachineInstanceView{}, errors.New("GetVirtualMachineInstanceView failed")
+	}
+	if mc.FakeGetVirtualMachineInstanceViewResult != nil {
+		return mc.FakeGetVirtualMachineInstanceViewResult(name), nil
+	}
+	return compute.VirtualMachineInstanceView{}, nil
+}
+
+//GetProximityPlacementGroup mock
+func (mc *MockAKSEngineClient) GetProximityPlacementGroup(ctx context.Context, resourceGroup, name string) (compute.ProximityPlacementGroup, error) {
+	if mc.FailGetProximityPlacementGroup {
+		return compute.ProximityPlacementGroup{}, errors.New("GetProximityPlacementGroup failed")
+	}
+	if mc.FakeGetProximityPlacementGroupResult != nil {
+		return mc.FakeGetProximityPlacementGroupResult(), nil
+	}
+	return compute.ProximityPlacementGroup{
+		ID:       to.StringPtr(fmt.Sprintf("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/%s/providers/Microsoft.Compute/proximityPlacementGroups/%s", resourceGroup, name)),
+		Name:     to.StringPtr(name),
+		Location: to.StringPtr("eastus"),
+	}, nil
+}
+
+//GetDiskEncryptionSet mock
+func (mc *MockAKSEngineClient) GetDiskEncryptionSet(ctx context.Context, resourceGroup, name string) (compute.DiskEncryptionSet, error) {
+	if mc.FailGetDiskEncryptionSet {
+		return compute.DiskEncryptionSet{}, errors.New("GetDiskEncryptionSet failed")
+	}
+	if mc.FakeGetDiskEncryptionSetResult != nil {
+		return mc.FakeGetDiskEncryptionSetResult(), nil
+	}
+	return compute.DiskEncryptionSet{
+		ID:       to.StringPtr(fmt.Sprintf("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/%s/providers/Microsoft.Compute/diskEncryptionSets/%s", resourceGroup, name)),
+		Name:     to.StringPtr(name),
+		Location: to.StringPtr("eastus"),
+	}, nil
+}
+
+//GetDedicatedHostGroup mock
+func (mc *MockAKSEngineClient) GetDedicatedHostGroup(ctx context.Context, resourceGroup, name string) (compute.DedicatedHostGroup, error) {
+	if mc.FailGetDedicatedHostGroup {
+		return compute.DedicatedHostGroup{}, errors.New("GetDedicatedHostGroup failed")
+	}
+	if mc.FakeGetDedicatedHostGroupResult != nil {
+		return mc.FakeGetDedicatedHostGroupResult(), nil
+	}
+	id := fmt.Sprintf("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/%s/providers/Microsoft.Compute/hostGroups/%s", resourceGroup, name)
+	return compute.DedicatedHostGroup{
+		ID:       to.StringPtr(id),
+		Name:     to.StringPtr(name),
+		Location: to.StringPtr("eastus"),
+		DedicatedHostGroupProperties: &compute.DedicatedHostGroupProperties{
+			Hosts: &[]compute.SubResourceReadOnly{
+				{ID: to.StringPtr(id + "/hosts/host0")},
+			},
+		},
+	}, nil
+}
+
+//RunVirtualMachineCommand mock
+func (mc *MockAKSEngineClient) RunVirtualMachineCommand(ctx context.Context, resourceGroup, name string, input compute.RunCommandInput) (compute.RunCommandResult, error) {
+	if mc.FailRunCommand {
+		return compute.RunCommandResult{}, errors.New("RunVirtualMachineCommand failed")
+	}
+	mc.RunCommandTargets = append(mc.RunCommandTargets, name)
+	return mc.fakeRunCommandResult(), nil
+}
+
+//RunVirtualMachineScaleSetVMCommand mock
+func (mc *MockAKSEngineClient) RunVirtualMachineScaleSetVMCommand(ctx context.Context, resourceGroup, virtualMachineScaleSet, instanceID string, input compute.RunCommandInput) (compute.RunCommandResult, error) {
+	if mc.FailRunCommand {
+		return compute.RunCommandResult{}, errors.New("RunVirtualMachineScaleSetVMCommand failed")
+	}
+	mc.RunCommandTargets = append(mc.RunCommandTargets, virtualMachineScaleSet+"/"+instanceID)
+	return mc.fakeRunCommandResult(), nil
+}
+
+func (mc *MockAKSEngineClient) fakeRunCommandResult() compute.RunCommandResult {
+	if mc.FakeRunCommandOutput == "" {
+		return compute.RunCommandResult{}
+	}
+	return compute.RunCommandResult{
+		Value: &[]compute.InstanceViewStatus{
+			{Message: to.StringPtr(mc.FakeRunCommandOutput)},
+		},
+	}
+}
+
+//ListComputeUsages mock
+func (mc *MockAKSEngineClient) ListComputeUsages(ctx context.Context, location string) ([]compute.Usage, error) {
+	if mc.FailListComputeUsages {
+		return nil, errors.New("ListComputeUsages failed")
+	}
+	return mc.FakeListComputeUsagesResult, nil
+}
+
+//ListManagementLocks mock
+func (mc *MockAKSEngineClient) ListManagementLocks(ctx context.Context, resourceGroup string) ([]ManagementLock, error) {
+	if mc.FailListManagementLocks {
+		return nil, errors.New("ListManagementLocks failed")
+	}
+	return mc.FakeListManagementLocksResult, nil
+}
+
+//GetDedicatedHost mock
+func (mc *MockAKSEngineClient) GetDedicatedHost(ctx context.Context, resourceGroup, hostGroup, name string) (compute.DedicatedHost, error) {
+	if mc.FailGetDedicatedHost {
+		return compute.DedicatedHost{}, errors.New("GetDedicatedHost failed")
+	}
+	if mc.FakeGetDedicatedHostResult != nil {
+		return mc.FakeGetDedicatedHostResult(name), nil
+	}
+	return compute.DedicatedHost{
+		ID:       to.StringPtr(fmt.Sprintf("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/%s/providers/Microsoft.Compute/hostGroups/%s/hosts/%s", resourceGroup, hostGroup, name)),
+		Name:     to.StringPtr(name),
+		Location: to.StringPtr("eastus"),
+		DedicatedHostProperties: &compute.DedicatedHostProperties{
+			InstanceView: &compute.DedicatedHostInstanceView{
+				AvailableCapacity: &compute.DedicatedHostAvailableCapacity{
+					AllocatableVMs: &[]compute.DedicatedHostAllocatableVM{
+						{VMSize: to.StringPtr("Standard_D2_v2"), Count: to.Float64Ptr(4)},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+//ListVirtualMachineImages mock
+func (mc *MockAKSEngineClient) ListVirtualMachineImages(ctx context.Context, location, publisherName, offer, skus string) (compute.ListVirtualMachineImageResource, error) {
+	if mc.FailListVirtualMachineImages {
+		return compute.ListVirtualMachineImageResource{}, errors.New("ListVirtualMachineImages failed")
+	}
+	images := []compute.VirtualMachineImageResource{
+		{Name: to.StringPtr("2021.01.01"), Location: to.StringPtr(location)},
+	}
+	if mc.FakeListVirtualMachineImagesResult != nil {
+		images = mc.FakeListVirtualMachineImagesResult()
+	}
+	return compute.ListVirtualMachineImageResource{Value: &images}, nil
+}
+
+//GetVirtualMachineImage mock
+func (mc *MockAKSEngineClient) GetVirtualMachineImage(ctx context.Context, location, publisherName, offer, skus, version string) (compute.VirtualMachineImage, error) {
+	return compute.VirtualMachineImage{
+		Name:     to.StringPtr(version),
+		Location: to.StringPtr(location),
+	}, nil
+}
@@ -5,6 +5,8 @@ package armhelpers
 
 import (
 	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
 )
 
 // DeleteNetworkInterface deletes the specified network interface.
@@ -21,3 +23,25 @@ func (az *AzureClient) DeleteNetworkInterface(ctx context.Context, resourceGroup
 	_, err = future.Result(az.interfacesClient)
 	return err
 }
+
+// ListNetworkInterfaces lists the network interfaces in the specified resource group.
+func (az *AzureClient) ListNetworkInterfaces(ctx context.Context, resourceGroup string) ([]network.Interface, error) {
+	var nics []network.Interface
+	for page, err := az.interfacesClient.List(ctx, resourceGroup); page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		nics = append(nics, page.Values()...)
+	}
+	return nics, nil
+}
+
+// GetSubnet retrieves the specified virtual network subnet, including the IP configurations using it.
+func (az *AzureClient) GetSubnet(ctx context.Context, resourceGroup, vnetName, subnetName string) (network.Subnet, error) {
+	return az.subnetsClient.Get(ctx, resourceGroup, vnetName, subnetName, "")
+}
+
+// GetVirtualNetworkPeering retrieves the specified peering of a virtual network.
+func (az *AzureClient) GetVirtualNetworkPeering(ctx context.Context, resourceGroup, vnetName, peeringName string) (network.VirtualNetworkPeering, error) {
+	return az.virtualNetworkPeeringsClient.Get(ctx, resourceGroup, vnetName, peeringName)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ParameterError describes a deployment parameter that does not satisfy the template parameters schema
+type ParameterError struct {
+	Name    string
+	Message string
+}
+
+// Error implements error interface
+func (e ParameterError) Error() string {
+	return fmt.Sprintf("parameter %s: %s", e.Name, e.Message)
+}
+
+// ValidateParameters checks the deployment parameters against the parameters section of the template:
+// parameters without a default value must be set, values must match the declared type and be one of
+// the allowed values if any. Key Vault references are not checked as their value is resolved by ARM.
+func ValidateParameters(templateMap, parametersMap map[string]interface{}) []ParameterError {
+	var errs []ParameterError
+	schema, _ := templateMap["parameters"].(map[string]interface{})
+	for name, definition := range schema {
+		definition, ok := definition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parameter, found := parametersMap[name].(map[string]interface{})
+		if !found {
+			if _, hasDefault := definition["defaultValue"]; !hasDefault {
+				errs = append(errs, ParameterError{Name: name, Message: "required parameter is missing"})
+			}
+			continue
+		}
+		if _, isReference := parameter["reference"]; isReference {
+			continue
+		}
+		value, hasValue := parameter["value"]
+		if !hasValue {
+			errs = append(errs, ParameterError{Name: name, Message: "parameter has no value"})
+			continue
+		}
+		paramType, _ := definition["type"].(string)
+		if !isParameterType(value, paramType) {
+			errs = append(errs, ParameterError{Name: name, Message: fmt.Sprintf("expected a value of type %s, got %T", paramType, value)})
+			continue
+		}
+		if allowed, ok := definition["allowedValues"].([]interface{}); ok && !isAllowedValue(value, allowed) {
+			errs = append(errs, ParameterError{Name: name, Message: fmt.Sprintf("value %v is not one of the allowed values %v", value, allowed)})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Name < errs[j].Name })
+	return errs
+}
+
+// ValidateDeploymentParameters logs all the errors returned by ValidateParameters and fails if there is any
+func ValidateDeploymentParameters(logger *logrus.Entry, templateMap, parametersMap map[string]interface{}) error {
+	errs := ValidateParameters(templateMap, parametersMap)
+	for _, err := range errs {
+		logger.Errorf("Invalid deployment %s", err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("%d deployment parameters do not match the template parameters schema", len(errs))
+	}
+	return nil
+}
+
+func isParameterType(value interface{}, paramType string) bool {
+	switch strings.ToLower(paramType) {
+	case "string", "securestring":
+		_, ok := value.(string)
+		return ok
+	case "int":
+		f, ok := toFloat64(value)
+		return ok && f == math.Trunc(f)
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "object", "secureobject":
+		return value != nil && reflect.TypeOf(value).Kind() == reflect.Map
+	case "array":
+		return value != nil && reflect.TypeOf(value).Kind() == reflect.Slice
+	default:
+		// unknown types are left for ARM to validate
+		return true
+	}
+}
+
+func isAllowedValue(value interface{}, allowed []interface{}) bool {
+	for _, a := range allowed {
+		if s, ok := value.(string); ok {
+			if as, ok := a.(string); ok && strings.EqualFold(s, as) {
+				return true
+			}
+			continue
+		}
+		if f, ok := toFloat64(value); ok {
+			if af, ok := toFloat64(a); ok && f == af {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(value, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// toFloat64 converts the numeric types found in parameter maps, decoded from JSON or built in code
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+const testParametersTemplate = `{
+  "parameters": {
+    "location": {"type": "string"},
+    "masterCount": {"type": "int", "allowedValues": [1, 3, 5]},
+    "osDiskSizeGB": {"type": "int", "defaultValue": 0},
+    "enableAcceleratedNetworking": {"type": "bool"},
+    "servicePrincipalClientSecret": {"type": "securestring"},
+    "agentpool1VMSize": {"type": "string", "allowedValues": ["Standard_D2_v2", "Standard_D4_v2"]},
+    "tags": {"type": "object", "defaultValue": {}},
+    "zones": {"type": "array", "defaultValue": []}
+  }
+}`
+
+func newTestParametersTemplate(t *testing.T) map[string]interface{} {
+	template := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(testParametersTemplate), &template); err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+func TestValidateParameters(t *testing.T) {
+	validParameters := func() map[string]interface{} {
+		return map[string]interface{}{
+			"location":                    map[string]interface{}{"value": "eastus"},
+			"masterCount":                 map[string]interface{}{"value": 3},
+			"enableAcceleratedNetworking": map[string]interface{}{"value": false},
+			"servicePrincipalClientSecret": map[string]interface{}{"reference": map[string]interface{}{
+				"keyVault":   map[string]interface{}{"id": "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv"},
+				"secretName": "spsecret",
+			}},
+			"agentpool1VMSize": map[string]interface{}{"value": "standard_d2_v2"},
+			"tags":             map[string]interface{}{"value": map[string]string{"owner": "me"}},
+			"zones":            map[string]interface{}{"value": []string{"1", "2"}},
+		}
+	}
+
+	cases := []struct {
+		name       string
+		parameters func() map[string]interface{}
+		expected   []ParameterError
+	}{
+		{
+			name:       "valid parameters",
+			parameters: validParameters,
+		},
+		{
+			name: "valid parameters decoded from JSON",
+			parameters: func() map[string]interface{} {
+				p := validParameters()
+				p["masterCount"] = map[string]interface{}{"value": float64(5)}
+				return p
+			},
+		},
+		{
+			name: "missing required parameter",
+			parameters: func() map[string]interface{} {
+				p := validParameters()
+				delete(p, "location")
+				delete(p, "osDiskSizeGB")
+				return p
+			},
+			expected: []ParameterError{{Name: "location", Message: "required parameter is missing"}},
+		},
+		{
+			name: "type mismatches",
+			parameters: func() map[string]interface{} {
+				p := validParameters()
+				p["enableAcceleratedNetworking"] = map[string]interface{}{"value": "false"}
+				p["location"] = map[string]interface{}{"value": 1}
+				p["masterCount"] = map[string]interface{}{"value": 2.5}
+				p["tags"] = map[string]interface{}{"value": "owner=me"}
+				return p
+			},
+			expected: []ParameterError{
+				{Name: "enableAcceleratedNetworking", Message: "expected a value of type bool, got string"},
+				{Name: "location", Message: "expected a value of type string, got int"},
+				{Name: "masterCount", Message: "expected a value of type int, got float64"},
+				{Name: "tags", Message: "expected a value of type object, got string"},
+			},
+		},
+		{
+			name: "values not allowed",
+			parameters: func() map[string]interface{} {
+				p := validParameters()
+				p["masterCount"] = map[string]interface{}{"value": 2}
+				p["agentpool1VMSize"] = map[string]interface{}{"value": "Standard_D8_v3"}
+				return p
+			},
+			expected: []ParameterError{
+				{Name: "agentpool1VMSize", Message: "value Standard_D8_v3 is not one of the allowed values [Standard_D2_v2 Standard_D4_v2]"},
+				{Name: "masterCount", Message: "value 2 is not one of the allowed values [1 3 5]"},
+			},
+		},
+		{
+			name: "parameter without value",
+			parameters: func() map[string]interface{} {
+				p := validParameters()
+				p["location"] = map[string]interface{}{}
+				return p
+			},
+			expected: []ParameterError{{Name: "location", Message: "parameter has no value"}},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			errs := ValidateParameters(newTestParametersTemplate(t), c.parameters())
+			if c.expected == nil {
+				g.Expect(errs).To(BeEmpty())
+			} else {
+				g.Expect(errs).To(Equal(c.expected))
+			}
+		})
+	}
+}
+
+func TestValidateDeploymentParameters(t *testing.T) {
+	g := NewGomegaWithT(t)
+	logger := log.NewEntry(log.New())
+
+	err := ValidateDeploymentParameters(logger, newTestParametersTemplate(t), map[string]interface{}{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("5 deployment parameters do not match the template parameters schema"))
+
+	g.Expect(ValidateDeploymentParameters(logger, map[string]interface{}{}, map[string]interface{}{})).To(Succeed())
+
+	mc := &MockAKSEngineClient{}
+	err = DeployTemplateSync(mc, logger, "rg", "deployment", newTestParametersTemplate(t), map[string]interface{}{})
+	g.Expect(err).To(HaveOccurred())
+}
@@ -34,6 +34,24 @@ func (az *AzureClient) GetStorageClient(ctx context.Context, resourceGroup, acco
 	}, nil
 }
 
+// ListStorageAccounts lists the storage accounts in the specified resource group.
+func (az *AzureClient) ListStorageAccounts(ctx context.Context, resourceGroup string) ([]storage.Account, error) {
+	result, err := az.storageAccountsClient.ListByResourceGroup(ctx, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+	if result.Value == nil {
+		return nil, nil
+	}
+	return *result.Value, nil
+}
+
+// DeleteStorageAccount deletes the specified storage account.
+func (az *AzureClient) DeleteStorageAccount(ctx context.Context, resourceGroup, accountName string) error {
+	_, err := az.storageAccountsClient.Delete(ctx, resourceGroup, accountName)
+	return err
+}
+
 func (az *AzureClient) getStorageKeys(ctx context.Context, resourceGroup, accountName string) ([]storage.AccountKey, error) {
 	storageKeysResult, err := az.storageAccountsClient.ListKeys(ctx, resourceGroup, accountName)
 	if err != nil {
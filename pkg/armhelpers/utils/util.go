@@ -63,6 +63,18 @@ func ResourceName(id string) (string, error) {
 	return name, nil
 }
 
+// ResourceGroupName returns the resource group segment for the specified resource identifier.
+func ResourceGroupName(id string) (string, error) {
+	parts := strings.Split(id, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") && len(parts[i+1]) > 0 {
+			return parts[i+1], nil
+		}
+	}
+
+	return "", errors.Errorf("resource group was missing from identifier")
+}
+
 // SplitBlobURI returns a decomposed blob URI parts: accountName, containerName, blobName.
 func SplitBlobURI(uri string) (string, string, string, error) {
 	parsed, err := url.Parse(uri)
@@ -179,6 +179,27 @@ func Test_ResourceName(t *testing.T) {
 	}
 }
 
+func Test_ResourceGroupName(t *testing.T) {
+	s := "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/acsK8sTest/providers/Microsoft.Compute/proximityPlacementGroups/ppg1"
+	expected := "acsK8sTest"
+	r, err := ResourceGroupName(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r != expected {
+		t.Fatalf("resourceGroupName %s, expected %s", r, expected)
+	}
+}
+
+func Test_ResourceGroupNameInvalid(t *testing.T) {
+	s := "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/providers/Microsoft.Compute/proximityPlacementGroups/ppg1"
+	expectedMsg := "resource group was missing from identifier"
+	_, err := ResourceGroupName(s)
+	if err == nil || err.Error() != expectedMsg {
+		t.Fatalf("expected error with message: %s", expectedMsg)
+	}
+}
+
 func Test_ResourceNameInvalid(t *testing.T) {
 	s := "https://vhdstorage8h8pjybi9hbsl6.blob.core.windows.net/vhds/osdisks/"
 	expectedMsg := "resource name was missing from identifier"
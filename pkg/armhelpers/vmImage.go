@@ -11,9 +11,9 @@ import (
 
 // ListVirtualMachineImages returns the list of images available in the current environment
 func (az *AzureClient) ListVirtualMachineImages(ctx context.Context, location, publisherName, offer, skus string) (compute.ListVirtualMachineImageResource, error) {
-	// random value
+	// random value, the newest versions are listed first
 	top := int32(10)
-	list, err := az.virtualMachineImagesClient.List(ctx, location, publisherName, offer, skus, "", &top, "")
+	list, err := az.virtualMachineImagesClient.List(ctx, location, publisherName, offer, skus, "", &top, "name desc")
 	return list, err
 }
 
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/pkg/errors"
+)
+
+const deploymentWhatIfAPIVersion = "2020-06-01"
+
+// WhatIfChangeType is the type of change a template deployment would make to a resource
+type WhatIfChangeType string
+
+const (
+	// WhatIfCreate means the resource does not exist and would be created
+	WhatIfCreate WhatIfChangeType = "Create"
+	// WhatIfDelete means the resource exists and would be deleted, only in Complete mode
+	WhatIfDelete WhatIfChangeType = "Delete"
+	// WhatIfModify means the resource exists and some of its properties would change
+	WhatIfModify WhatIfChangeType = "Modify"
+	// WhatIfDeploy means the resource exists and would be redeployed, its changes cannot be predicted
+	WhatIfDeploy WhatIfChangeType = "Deploy"
+	// WhatIfNoChange means the resource exists and would be redeployed unchanged
+	WhatIfNoChange WhatIfChangeType = "NoChange"
+	// WhatIfIgnore means the resource exists, is not in the template and would be left untouched
+	WhatIfIgnore WhatIfChangeType = "Ignore"
+)
+
+// WhatIfChange is a change a template deployment would make to a resource
+type WhatIfChange struct {
+	ResourceID string           `json:"resourceId,omitempty"`
+	ChangeType WhatIfChangeType `json:"changeType,omitempty"`
+	// Delta are the property changes of a Modify change
+	Delta []WhatIfPropertyChange `json:"delta,omitempty"`
+}
+
+// WhatIfPropertyChange is the change of a resource property, Array, Create, Delete, Modify or NoEffect
+type WhatIfPropertyChange struct {
+	Path               string `json:"path,omitempty"`
+	PropertyChangeType string `json:"propertyChangeType,omitempty"`
+}
+
+type whatIfOperationResult struct {
+	Status     string `json:"status,omitempty"`
+	Properties struct {
+		Changes []WhatIfChange `json:"changes,omitempty"`
+	} `json:"properties,omitempty"`
+	Error *struct {
+		Code    string `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"error,omitempty"`
+}
+
+// WhatIfDeployTemplate returns the changes the Incremental deployment of the template would make to the resource group,
+// without deploying it.
+func (az *AzureClient) WhatIfDeployTemplate(ctx context.Context, resourceGroup, deploymentName string, template, parameters map[string]interface{}) ([]WhatIfChange, error) {
+	pathParameters := map[string]interface{}{
+		"deploymentName":    autorest.Encode("path", deploymentName),
+		"resourceGroupName": autorest.Encode("path", resourceGroup),
+		"subscriptionId":    autorest.Encode("path", az.subscriptionID),
+	}
+	queryParameters := map[string]interface{}{
+		"api-version": deploymentWhatIfAPIVersion,
+	}
+	body := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"template":   template,
+			"parameters": parameters,
+			"mode":       resources.Incremental,
+		},
+	}
+	req, err := autorest.CreatePreparer(
+		autorest.AsContentType("application/json; charset=utf-8"),
+		autorest.AsPost(),
+		autorest.WithBaseURL(az.deploymentsClient.BaseURI),
+		autorest.WithPathParameters("/subscriptions/{subscriptionId}/resourcegroups/{resourceGroupName}/providers/Microsoft.Resources/deployments/{deploymentName}/whatIf", pathParameters),
+		autorest.WithJSON(body),
+		autorest.WithQueryParameters(queryParameters)).Prepare((&http.Request{}).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := az.deploymentsClient.Send(req, azure.DoRetryWithRegistration(az.deploymentsClient.Client))
+	if err != nil {
+		return nil, err
+	}
+	future, err := azure.NewFutureFromResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err = future.WaitForCompletionRef(ctx, az.deploymentsClient.Client); err != nil {
+		return nil, err
+	}
+	resp, err = future.GetResult(az.deploymentsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var result whatIfOperationResult
+	err = autorest.Respond(
+		resp,
+		az.deploymentsClient.ByInspecting(),
+		azure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(&result),
+		autorest.ByClosing())
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, errors.Errorf("what-if of deployment %s failed: %s: %s", deploymentName, result.Error.Code, result.Error.Message)
+	}
+	return result.Properties.Changes, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armhelpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWhatIfDeployTemplate(t *testing.T) {
+	mc, err := NewHTTPMockClient()
+	if err != nil {
+		t.Fatalf("failed to create HttpMockClient - %s", err)
+	}
+
+	mc.RegisterLogin()
+	whatIfPath := fmt.Sprintf("/subscriptions/%s/resourcegroups/%s/providers/Microsoft.Resources/deployments/%s/whatIf", mc.SubscriptionID, mc.ResourceGroup, deploymentName)
+	resultPath := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Resources/locations/eastus/operationResults/whatif1", mc.SubscriptionID)
+	var body map[string]interface{}
+	mc.mux.HandleFunc(whatIfPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("api-version") != deploymentWhatIfAPIVersion {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Location", fmt.Sprintf("http://localhost:%d%s", mc.server.Port, resultPath))
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	polls := 0
+	mc.mux.HandleFunc(resultPath, func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 1 {
+			w.Header().Set("Location", fmt.Sprintf("http://localhost:%d%s", mc.server.Port, resultPath))
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"status":"Succeeded","properties":{"changes":[`+
+			`{"resourceId":"/vm/k8s-master-0","changeType":"Modify","delta":[{"path":"properties.hardwareProfile.vmSize","propertyChangeType":"Modify"}]},`+
+			`{"resourceId":"/nic/k8s-master-0","changeType":"Create"}]}}`)
+	})
+
+	err = mc.Activate()
+	if err != nil {
+		t.Fatalf("failed to activate HttpMockClient - %s", err)
+	}
+	defer mc.DeactivateAndReset()
+
+	env := mc.GetEnvironment()
+	azureClient, err := NewAzureClientWithClientSecret(env, subscriptionID, "clientID", "secret")
+	if err != nil {
+		t.Fatalf("can not get client %s", err)
+	}
+
+	template := map[string]interface{}{"resources": []interface{}{}}
+	changes, err := azureClient.WhatIfDeployTemplate(context.Background(), resourceGroup, deploymentName, template, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("failed to preview the deployment - %s", err)
+	}
+	expected := []WhatIfChange{
+		{ResourceID: "/vm/k8s-master-0", ChangeType: WhatIfModify, Delta: []WhatIfPropertyChange{{Path: "properties.hardwareProfile.vmSize", PropertyChangeType: "Modify"}}},
+		{ResourceID: "/nic/k8s-master-0", ChangeType: WhatIfCreate},
+	}
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("unexpected what-if changes %s", diff)
+	}
+	properties, _ := body["properties"].(map[string]interface{})
+	if properties["mode"] != "Incremental" || properties["template"] == nil {
+		t.Errorf("unexpected what-if request properties %v", properties)
+	}
+}
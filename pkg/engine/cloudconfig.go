@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// CloudProviderConfig is the Azure cloud provider config, /etc/kubernetes/azure.json, of the cluster nodes
+type CloudProviderConfig struct {
+	Cloud                       string `json:"cloud"`
+	SubscriptionID              string `json:"subscriptionId"`
+	AADClientID                 string `json:"aadClientId"`
+	AADClientSecret             string `json:"aadClientSecret"`
+	ResourceGroup               string `json:"resourceGroup"`
+	Location                    string `json:"location"`
+	VMType                      string `json:"vmType"`
+	SubnetName                  string `json:"subnetName"`
+	SecurityGroupName           string `json:"securityGroupName"`
+	VnetName                    string `json:"vnetName"`
+	VnetResourceGroup           string `json:"vnetResourceGroup"`
+	RouteTableName              string `json:"routeTableName"`
+	PrimaryAvailabilitySetName  string `json:"primaryAvailabilitySetName"`
+	PrimaryScaleSetName         string `json:"primaryScaleSetName"`
+	UseManagedIdentityExtension bool   `json:"useManagedIdentityExtension"`
+	UserAssignedIdentityID      string `json:"userAssignedIdentityID"`
+	UseInstanceMetadata         bool   `json:"useInstanceMetadata"`
+	LoadBalancerSku             string `json:"loadBalancerSku"`
+}
+
+// GenerateCloudProviderConfig returns the JSON cloud provider config of the cluster of cs deployed to resourceGroup
+// of subscriptionID, with the values the provisioning script of the template derives from the same api model
+func GenerateCloudProviderConfig(cs *api.ContainerService, subscriptionID, resourceGroup string) ([]byte, error) {
+	if cs.Properties == nil || cs.Properties.MasterProfile == nil || cs.Properties.OrchestratorProfile == nil ||
+		cs.Properties.OrchestratorProfile.KubernetesConfig == nil {
+		return nil, errors.New("generating the cloud provider config requires a Kubernetes cluster with a master profile")
+	}
+	properties := cs.Properties
+	kubernetesConfig := properties.OrchestratorProfile.KubernetesConfig
+	config := CloudProviderConfig{
+		Cloud:                       helpers.GetTargetEnv(cs.Location, properties.GetCustomCloudName()),
+		SubscriptionID:              subscriptionID,
+		ResourceGroup:               resourceGroup,
+		Location:                    cs.Location,
+		VMType:                      properties.GetVMType(),
+		SubnetName:                  properties.GetSubnetName(),
+		SecurityGroupName:           properties.GetNSGName(),
+		VnetName:                    properties.GetVirtualNetworkName(),
+		VnetResourceGroup:           properties.GetVNetResourceGroupName(),
+		RouteTableName:              properties.GetRouteTableName(),
+		PrimaryAvailabilitySetName:  properties.GetPrimaryAvailabilitySetName(),
+		PrimaryScaleSetName:         properties.GetPrimaryScaleSetName(),
+		UseManagedIdentityExtension: to.Bool(kubernetesConfig.UseManagedIdentity),
+		UserAssignedIdentityID:      kubernetesConfig.UserAssignedID,
+		UseInstanceMetadata:         to.Bool(kubernetesConfig.UseInstanceMetadata),
+		LoadBalancerSku:             kubernetesConfig.LoadBalancerSku,
+	}
+	if config.UseManagedIdentityExtension {
+		config.AADClientID, config.AADClientSecret = "msi", "msi"
+	} else if properties.ServicePrincipalProfile != nil {
+		config.AADClientID = properties.ServicePrincipalProfile.ClientID
+		config.AADClientSecret = properties.ServicePrincipalProfile.Secret
+	}
+	return json.MarshalIndent(config, "", "    ")
+}
@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestGenerateCloudProviderConfig(t *testing.T) {
+	cs := &api.ContainerService{
+		Location: "westus2",
+		Properties: &api.Properties{
+			ServicePrincipalProfile: &api.ServicePrincipalProfile{
+				ClientID: "barClientID",
+				Secret:   "bazSecret",
+			},
+			MasterProfile: &api.MasterProfile{
+				Count:        1,
+				DNSPrefix:    "blueorange",
+				VMSize:       "Standard_D2_v2",
+				VnetSubnetID: "/subscriptions/SUB_ID/resourceGroups/NetworkRG/providers/Microsoft.Network/virtualNetworks/CustomVNet/subnets/MasterSubnet",
+			},
+			OrchestratorProfile: &api.OrchestratorProfile{
+				OrchestratorType: api.Kubernetes,
+				KubernetesConfig: &api.KubernetesConfig{
+					LoadBalancerSku:     api.StandardLoadBalancerSku,
+					UseInstanceMetadata: to.BoolPtr(true),
+				},
+			},
+			AgentPoolProfiles: []*api.AgentPoolProfile{
+				{
+					Name:                "agentpool1",
+					VMSize:              "Standard_D2_v2",
+					Count:               2,
+					AvailabilityProfile: api.VirtualMachineScaleSets,
+				},
+			},
+		},
+	}
+
+	b, err := GenerateCloudProviderConfig(cs, "SUB_ID", "ClusterRG")
+	if err != nil {
+		t.Fatalf("unexpected error generating the cloud provider config: %s", err)
+	}
+	var config CloudProviderConfig
+	if err = json.Unmarshal(b, &config); err != nil {
+		t.Fatalf("unexpected error parsing the cloud provider config: %s", err)
+	}
+	expected := CloudProviderConfig{
+		Cloud:               "AzurePublicCloud",
+		SubscriptionID:      "SUB_ID",
+		AADClientID:         "barClientID",
+		AADClientSecret:     "bazSecret",
+		ResourceGroup:       "ClusterRG",
+		Location:            "westus2",
+		VMType:              api.VMSSVMType,
+		SubnetName:          "MasterSubnet",
+		SecurityGroupName:   cs.Properties.GetNSGName(),
+		VnetName:            "CustomVNet",
+		VnetResourceGroup:   "NetworkRG",
+		RouteTableName:      cs.Properties.GetRouteTableName(),
+		PrimaryScaleSetName: cs.Properties.GetPrimaryScaleSetName(),
+		UseInstanceMetadata: true,
+		LoadBalancerSku:     api.StandardLoadBalancerSku,
+	}
+	if diff := cmp.Diff(expected, config); diff != "" {
+		t.Errorf("unexpected diff in the cloud provider config: %s", diff)
+	}
+
+	cs.Properties.OrchestratorProfile.KubernetesConfig.UseManagedIdentity = to.BoolPtr(true)
+	if b, err = GenerateCloudProviderConfig(cs, "SUB_ID", "ClusterRG"); err != nil {
+		t.Fatalf("unexpected error generating the cloud provider config: %s", err)
+	}
+	config = CloudProviderConfig{}
+	if err = json.Unmarshal(b, &config); err != nil {
+		t.Fatalf("unexpected error parsing the cloud provider config: %s", err)
+	}
+	if config.AADClientID != "msi" || config.AADClientSecret != "msi" || !config.UseManagedIdentityExtension {
+		t.Errorf("expected the managed identity in the cloud provider config, got %+v", config)
+	}
+
+	if _, err = GenerateCloudProviderConfig(&api.ContainerService{Properties: &api.Properties{}}, "SUB_ID", "ClusterRG"); err == nil {
+		t.Errorf("expected an error generating the cloud provider config of a cluster without a master profile")
+	}
+}
@@ -69,19 +69,26 @@ func CreatePkiKeyCertPair(params PkiKeyCertPairParams) (*PkiKeyCertPair, error)
 	return caPair, nil
 }
 
+// KubernetesServiceFQDNs returns the in-cluster DNS names of the kubernetes service the API server certificate is valid for
+func KubernetesServiceFQDNs(clusterDomain string) []string {
+	return []string{
+		"kubernetes",
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		fmt.Sprintf("kubernetes.default.svc.%s", clusterDomain),
+		"kubernetes.kube-system",
+		"kubernetes.kube-system.svc",
+		fmt.Sprintf("kubernetes.kube-system.svc.%s", clusterDomain),
+	}
+}
+
 // CreatePki creates PKI certificates
 func CreatePki(pkiParams PkiParams) (*PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, []*PkiKeyCertPair, error) {
 	start := time.Now()
 	defer func(s time.Time) {
 		log.Debugf("pki: PKI asset creation took %s", time.Since(s))
 	}(start)
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, "kubernetes")
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, "kubernetes.default")
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, "kubernetes.default.svc")
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, fmt.Sprintf("kubernetes.default.svc.%s", pkiParams.ClusterDomain))
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, "kubernetes.kube-system")
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, "kubernetes.kube-system.svc")
-	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, fmt.Sprintf("kubernetes.kube-system.svc.%s", pkiParams.ClusterDomain))
+	pkiParams.ExtraFQDNs = append(pkiParams.ExtraFQDNs, KubernetesServiceFQDNs(pkiParams.ClusterDomain)...)
 
 	var (
 		caCertificate         *x509.Certificate
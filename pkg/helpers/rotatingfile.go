@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRotatingFileBackups is how many rotated files a RotatingFile keeps, named <path>.1 being the most recent
+const DefaultRotatingFileBackups = 3
+
+// RotatingFile is an io.WriteCloser appending to a file that is rotated before a write would make it
+// larger than MaxSizeBytes; the rotated files are renamed <path>.1 to <path>.<Backups>, older ones are removed
+type RotatingFile struct {
+	Path         string
+	MaxSizeBytes int64
+	Backups      int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens path for appending, creating it if needed; a zero maxSizeBytes never rotates the file
+func NewRotatingFile(path string, maxSizeBytes int64) (*RotatingFile, error) {
+	r := &RotatingFile{
+		Path:         path,
+		MaxSizeBytes: maxSizeBytes,
+		Backups:      DefaultRotatingFileBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write writes p to the file, rotating the file first if p would make it larger than MaxSizeBytes.
+// A file is never left empty, so a single write larger than MaxSizeBytes still goes to one file.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.MaxSizeBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSizeBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", r.Path)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "reading the size of %s", r.Path)
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return errors.Wrapf(err, "closing %s", r.Path)
+	}
+	if r.Backups < 1 {
+		if err := os.Remove(r.Path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "removing %s", r.Path)
+		}
+		return r.open()
+	}
+	if err := os.Remove(r.backup(r.Backups)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "removing %s", r.backup(r.Backups))
+	}
+	for i := r.Backups - 1; i > 0; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "renaming %s", r.backup(i))
+		}
+	}
+	if err := os.Rename(r.Path, r.backup(1)); err != nil {
+		return errors.Wrapf(err, "renaming %s", r.Path)
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", r.Path, i)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package helpers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readRotatingFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return string(b)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotatingfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "upgrade.log")
+
+	r, err := NewRotatingFile(path, 10)
+	if err != nil {
+		t.Fatalf("NewRotatingFile returned an error: %v", err)
+	}
+	r.Backups = 2
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dd\n", "eeeeeeeeeeee\n", "ff\n"} {
+		if _, err = r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	// the file is rotated before a write would make it larger than 10 bytes, a larger write gets a file of its own
+	for name, expected := range map[string]string{
+		path:        "ff\n",
+		path + ".1": "eeeeeeeeeeee\n",
+		path + ".2": "cccc\ndd\n",
+	} {
+		if content := readRotatingFile(t, name); content != expected {
+			t.Errorf("expected %s to contain %q, got %q", name, expected, content)
+		}
+	}
+	if _, err = os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected no more than 2 rotated files, found %s.3", path)
+	}
+}
+
+func TestRotatingFileAppendsToExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotatingfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "upgrade.log")
+	if err = ioutil.WriteFile(path, []byte("12345678\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRotatingFile(path, 10)
+	if err != nil {
+		t.Fatalf("NewRotatingFile returned an error: %v", err)
+	}
+	if _, err = r.Write([]byte("x")); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	if _, err = r.Write([]byte("y")); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	r.Close()
+
+	if content := readRotatingFile(t, path+".1"); content != "12345678\nx" {
+		t.Errorf("expected the existing content to count towards the size limit, got %q", content)
+	}
+	if content := readRotatingFile(t, path); content != "y" {
+		t.Errorf("expected %q, got %q", "y", content)
+	}
+
+	r, err = NewRotatingFile(path, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile returned an error: %v", err)
+	}
+	if _, err = r.Write(make([]byte, 100)); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	r.Close()
+	if info, _ := os.Stat(path); info.Size() != 101 {
+		t.Errorf("expected a zero size limit not to rotate the file, got a %d bytes file", info.Size())
+	}
+}
@@ -13,6 +13,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -24,6 +25,9 @@ import (
 const (
 	evictionKind        = "Eviction"
 	evictionSubresource = "pods/eviction"
+
+	crdGroupVersionV1      = "apiextensions.k8s.io/v1"
+	crdGroupVersionV1beta1 = "apiextensions.k8s.io/v1beta1"
 )
 
 // ClientSetClient is a Kubernetes client hooked up to a live api server.
@@ -113,6 +117,17 @@ func (c *ClientSetClient) GetNode(name string) (*v1.Node, error) {
 	return c.clientset.CoreV1().Nodes().Get(name, metav1.GetOptions{})
 }
 
+// GetNodeProxy sends a GET request for path to the kubelet of the node through the api server proxy,
+// and returns the HTTP status code of the response. The error is only set when no response was received.
+func (c *ClientSetClient) GetNodeProxy(name, path string) (int, error) {
+	var statusCode int
+	result := c.clientset.CoreV1().RESTClient().Get().Resource("nodes").Name(name).SubResource("proxy").Suffix(path).Do()
+	if result.StatusCode(&statusCode); statusCode == 0 {
+		return 0, result.Error()
+	}
+	return statusCode, nil
+}
+
 // UpdateNode updates the node in the api server with the passed in info.
 func (c *ClientSetClient) UpdateNode(node *v1.Node) (*v1.Node, error) {
 	return c.clientset.CoreV1().Nodes().Update(node)
@@ -174,9 +189,9 @@ func (c *ClientSetClient) DeleteDeployment(deployment *appsv1.Deployment) error
 	return c.clientset.AppsV1().Deployments(deployment.Namespace).Delete(deployment.Name, &metav1.DeleteOptions{})
 }
 
-// DeletePod deletes the passed in pod.
-func (c *ClientSetClient) DeletePod(pod *v1.Pod) error {
-	return c.clientset.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+// DeletePod deletes the passed in pod, with the pod's own grace period if gracePeriodSeconds is nil.
+func (c *ClientSetClient) DeletePod(pod *v1.Pod, gracePeriodSeconds *int64) error {
+	return c.clientset.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{GracePeriodSeconds: gracePeriodSeconds})
 }
 
 // DeletePods deletes all pods in a namespace that match the option filters.
@@ -189,8 +204,8 @@ func (c *ClientSetClient) DeleteSecret(secret *v1.Secret) error {
 	return c.clientset.CoreV1().Secrets(secret.Namespace).Delete(secret.Name, &metav1.DeleteOptions{})
 }
 
-// EvictPod evicts the passed in pod using the passed in api version.
-func (c *ClientSetClient) EvictPod(pod *v1.Pod, policyGroupVersion string) error {
+// EvictPod evicts the passed in pod using the passed in api version, with the pod's own grace period if gracePeriodSeconds is nil.
+func (c *ClientSetClient) EvictPod(pod *v1.Pod, policyGroupVersion string, gracePeriodSeconds *int64) error {
 	eviction := &policy.Eviction{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: policyGroupVersion,
@@ -201,6 +216,9 @@ func (c *ClientSetClient) EvictPod(pod *v1.Pod, policyGroupVersion string) error
 			Namespace: pod.Namespace,
 		},
 	}
+	if gracePeriodSeconds != nil {
+		eviction.DeleteOptions = &metav1.DeleteOptions{GracePeriodSeconds: gracePeriodSeconds}
+	}
 	return c.clientset.PolicyV1beta1().Evictions(eviction.Namespace).Evict(eviction)
 }
 
@@ -209,6 +227,27 @@ func (c *ClientSetClient) getPod(namespace, name string) (*v1.Pod, error) {
 	return c.clientset.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
 }
 
+// GetPod returns a given pod in a namespace.
+func (c *ClientSetClient) GetPod(namespace, name string) (*v1.Pod, error) {
+	return c.getPod(namespace, name)
+}
+
+// CreatePod creates the passed in pod.
+func (c *ClientSetClient) CreatePod(pod *v1.Pod) (*v1.Pod, error) {
+	return c.clientset.CoreV1().Pods(pod.Namespace).Create(pod)
+}
+
+// GetPodLogs returns the logs of the containers of a given pod in a namespace.
+func (c *ClientSetClient) GetPodLogs(namespace, name string) (string, error) {
+	logs, err := c.clientset.CoreV1().Pods(namespace).GetLogs(name, &v1.PodLogOptions{}).DoRaw()
+	return string(logs), err
+}
+
+// GetService returns a given service in a namespace.
+func (c *ClientSetClient) GetService(namespace, name string) (*v1.Service, error) {
+	return c.clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+}
+
 // WaitForDelete waits until all pods are deleted. Returns all pods not deleted and an error on failure.
 func (c *ClientSetClient) WaitForDelete(logger *log.Entry, pods []v1.Pod, usingEviction bool) ([]v1.Pod, error) {
 	verbStr := "deleted"
@@ -247,7 +286,94 @@ func (c *ClientSetClient) GetDeployment(namespace, name string) (*appsv1.Deploym
 	return c.clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
 }
 
+// GetConfigMap returns a given config map in a namespace.
+func (c *ClientSetClient) GetConfigMap(namespace, name string) (*v1.ConfigMap, error) {
+	return c.clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+}
+
+// CreateConfigMap creates the passed in config map.
+func (c *ClientSetClient) CreateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	return c.clientset.CoreV1().ConfigMaps(configMap.Namespace).Create(configMap)
+}
+
+// UpdateConfigMap updates a config map to match the given specification.
+func (c *ClientSetClient) UpdateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	return c.clientset.CoreV1().ConfigMaps(configMap.Namespace).Update(configMap)
+}
+
+// ListResourceQuotas returns the resource quotas of a namespace, or of all namespaces if namespace is empty.
+func (c *ClientSetClient) ListResourceQuotas(namespace string) (*v1.ResourceQuotaList, error) {
+	return c.clientset.CoreV1().ResourceQuotas(namespace).List(metav1.ListOptions{})
+}
+
+// UpdateResourceQuota updates a resource quota to match the given specification.
+func (c *ClientSetClient) UpdateResourceQuota(quota *v1.ResourceQuota) (*v1.ResourceQuota, error) {
+	return c.clientset.CoreV1().ResourceQuotas(quota.Namespace).Update(quota)
+}
+
+// ListLimitRanges returns the limit ranges of a namespace, or of all namespaces if namespace is empty.
+func (c *ClientSetClient) ListLimitRanges(namespace string) (*v1.LimitRangeList, error) {
+	return c.clientset.CoreV1().LimitRanges(namespace).List(metav1.ListOptions{})
+}
+
+// UpdateLimitRange updates a limit range to match the given specification.
+func (c *ClientSetClient) UpdateLimitRange(limitRange *v1.LimitRange) (*v1.LimitRange, error) {
+	return c.clientset.CoreV1().LimitRanges(limitRange.Namespace).Update(limitRange)
+}
+
+// ListPodDisruptionBudgets returns the pod disruption budgets of a namespace, or of all namespaces if namespace is empty.
+func (c *ClientSetClient) ListPodDisruptionBudgets(namespace string) (*policy.PodDisruptionBudgetList, error) {
+	return c.clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).List(metav1.ListOptions{})
+}
+
+// CreateEvent records an event in the api server.
+func (c *ClientSetClient) CreateEvent(event *v1.Event) (*v1.Event, error) {
+	return c.clientset.CoreV1().Events(event.Namespace).Create(event)
+}
+
 // UpdateDeployment updates a deployment to match the given specification.
 func (c *ClientSetClient) UpdateDeployment(namespace string, deployment *appsv1.Deployment) (*appsv1.Deployment, error) {
 	return c.clientset.AppsV1().Deployments(namespace).Update(deployment)
 }
+
+// ListCustomResourceDefinitions returns the CustomResourceDefinitions registered in the api server.
+// apiextensions.k8s.io/v1beta1 is used when the api server does not serve apiextensions.k8s.io/v1.
+func (c *ClientSetClient) ListCustomResourceDefinitions() (*unstructured.UnstructuredList, error) {
+	data, err := c.clientset.Discovery().RESTClient().Get().AbsPath("/apis", crdGroupVersionV1, "customresourcedefinitions").DoRaw()
+	if apierrors.IsNotFound(err) {
+		data, err = c.clientset.Discovery().RESTClient().Get().AbsPath("/apis", crdGroupVersionV1beta1, "customresourcedefinitions").DoRaw()
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{}
+	if err := list.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateCustomResourceDefinition updates a CustomResourceDefinition to match the given specification.
+func (c *ClientSetClient) UpdateCustomResourceDefinition(crd *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	groupVersion := crd.GetAPIVersion()
+	if groupVersion == "" {
+		groupVersion = crdGroupVersionV1
+	}
+	body, err := crd.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.clientset.Discovery().RESTClient().Put().
+		AbsPath("/apis", groupVersion, "customresourcedefinitions", crd.GetName()).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	updated := &unstructured.Unstructured{}
+	if err := updated.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
@@ -7,8 +7,10 @@ import (
 	log "github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // TODO These interfaces do not follow best practices
@@ -30,8 +32,37 @@ type Client interface {
 	GetDaemonSet(namespace, name string) (*appsv1.DaemonSet, error)
 	// GetDeployment returns a given deployment in a namespace.
 	GetDeployment(namespace, name string) (*appsv1.Deployment, error)
+	// GetConfigMap returns a given config map in a namespace.
+	GetConfigMap(namespace, name string) (*v1.ConfigMap, error)
+	// CreateConfigMap creates the passed in config map.
+	CreateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error)
+	// UpdateConfigMap updates a config map to match the given specification.
+	UpdateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error)
+	// ListResourceQuotas returns the resource quotas of a namespace, or of all namespaces if namespace is empty.
+	ListResourceQuotas(namespace string) (*v1.ResourceQuotaList, error)
+	// UpdateResourceQuota updates a resource quota to match the given specification.
+	UpdateResourceQuota(quota *v1.ResourceQuota) (*v1.ResourceQuota, error)
+	// ListLimitRanges returns the limit ranges of a namespace, or of all namespaces if namespace is empty.
+	ListLimitRanges(namespace string) (*v1.LimitRangeList, error)
+	// UpdateLimitRange updates a limit range to match the given specification.
+	UpdateLimitRange(limitRange *v1.LimitRange) (*v1.LimitRange, error)
+	// ListPodDisruptionBudgets returns the pod disruption budgets of a namespace, or of all namespaces if namespace is empty.
+	ListPodDisruptionBudgets(namespace string) (*policy.PodDisruptionBudgetList, error)
+	// CreateEvent records an event in the api server.
+	CreateEvent(event *v1.Event) (*v1.Event, error)
+	// GetService returns a given service in a namespace.
+	GetService(namespace, name string) (*v1.Service, error)
+	// CreatePod creates the passed in pod.
+	CreatePod(pod *v1.Pod) (*v1.Pod, error)
+	// GetPod returns a given pod in a namespace.
+	GetPod(namespace, name string) (*v1.Pod, error)
+	// GetPodLogs returns the logs of the containers of a given pod in a namespace.
+	GetPodLogs(namespace, name string) (string, error)
 	// GetNode returns details about node with passed in name.
 	GetNode(name string) (*v1.Node, error)
+	// GetNodeProxy sends a GET request for path to the kubelet of the node through the api server proxy,
+	// and returns the HTTP status code of the response.
+	GetNodeProxy(name, path string) (int, error)
 	// UpdateNode updates the node in the api server with the passed in info.
 	UpdateNode(node *v1.Node) (*v1.Node, error)
 	// DeleteNode deregisters node in the api server.
@@ -44,16 +75,20 @@ type Client interface {
 	DeleteDaemonSet(ds *appsv1.DaemonSet) error
 	// DeleteDeployment deletes the passed in Deployment.
 	DeleteDeployment(ds *appsv1.Deployment) error
-	// DeletePod deletes the passed in pod.
-	DeletePod(pod *v1.Pod) error
+	// DeletePod deletes the passed in pod, with the pod's own grace period if gracePeriodSeconds is nil.
+	DeletePod(pod *v1.Pod, gracePeriodSeconds *int64) error
 	// DeleteServiceAccount deletes the passed in service account.
 	DeleteServiceAccount(sa *v1.ServiceAccount) error
-	// EvictPod evicts the passed in pod using the passed in api version.
-	EvictPod(pod *v1.Pod, policyGroupVersion string) error
+	// EvictPod evicts the passed in pod using the passed in api version, with the pod's own grace period if gracePeriodSeconds is nil.
+	EvictPod(pod *v1.Pod, policyGroupVersion string, gracePeriodSeconds *int64) error
 	// WaitForDelete waits until all pods are deleted. Returns all pods not deleted and an error on failure.
 	WaitForDelete(logger *log.Entry, pods []v1.Pod, usingEviction bool) ([]v1.Pod, error)
 	// UpdateDeployment updates a deployment to match the given specification.
 	UpdateDeployment(namespace string, deployment *appsv1.Deployment) (*appsv1.Deployment, error)
+	// ListCustomResourceDefinitions returns the CustomResourceDefinitions registered in the api server.
+	ListCustomResourceDefinitions() (*unstructured.UnstructuredList, error)
+	// UpdateCustomResourceDefinition updates a CustomResourceDefinition to match the given specification.
+	UpdateCustomResourceDefinition(crd *unstructured.Unstructured) (*unstructured.Unstructured, error)
 }
 
 // NodeLister is an interface implemented by Kubernetes clients
@@ -12,8 +12,10 @@ import (
 	logrus "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/apps/v1"
 	v10 "k8s.io/api/core/v1"
+	v1beta1 "k8s.io/api/policy/v1beta1"
 	v11 "k8s.io/api/rbac/v1"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	reflect "reflect"
 )
 
@@ -160,6 +162,21 @@ func (mr *MockClientMockRecorder) GetNode(name interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNode", reflect.TypeOf((*MockClient)(nil).GetNode), name)
 }
 
+// GetNodeProxy mocks base method
+func (m *MockClient) GetNodeProxy(name, path string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNodeProxy", name, path)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNodeProxy indicates an expected call of GetNodeProxy
+func (mr *MockClientMockRecorder) GetNodeProxy(name, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeProxy", reflect.TypeOf((*MockClient)(nil).GetNodeProxy), name, path)
+}
+
 // UpdateNode mocks base method
 func (m *MockClient) UpdateNode(node *v10.Node) (*v10.Node, error) {
 	m.ctrl.T.Helper()
@@ -247,17 +264,17 @@ func (mr *MockClientMockRecorder) DeleteDeployment(ds interface{}) *gomock.Call
 }
 
 // DeletePod mocks base method
-func (m *MockClient) DeletePod(pod *v10.Pod) error {
+func (m *MockClient) DeletePod(pod *v10.Pod, gracePeriodSeconds *int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePod", pod)
+	ret := m.ctrl.Call(m, "DeletePod", pod, gracePeriodSeconds)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePod indicates an expected call of DeletePod
-func (mr *MockClientMockRecorder) DeletePod(pod interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) DeletePod(pod, gracePeriodSeconds interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePod", reflect.TypeOf((*MockClient)(nil).DeletePod), pod)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePod", reflect.TypeOf((*MockClient)(nil).DeletePod), pod, gracePeriodSeconds)
 }
 
 // DeleteServiceAccount mocks base method
@@ -275,17 +292,17 @@ func (mr *MockClientMockRecorder) DeleteServiceAccount(sa interface{}) *gomock.C
 }
 
 // EvictPod mocks base method
-func (m *MockClient) EvictPod(pod *v10.Pod, policyGroupVersion string) error {
+func (m *MockClient) EvictPod(pod *v10.Pod, policyGroupVersion string, gracePeriodSeconds *int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "EvictPod", pod, policyGroupVersion)
+	ret := m.ctrl.Call(m, "EvictPod", pod, policyGroupVersion, gracePeriodSeconds)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // EvictPod indicates an expected call of EvictPod
-func (mr *MockClientMockRecorder) EvictPod(pod, policyGroupVersion interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) EvictPod(pod, policyGroupVersion, gracePeriodSeconds interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EvictPod", reflect.TypeOf((*MockClient)(nil).EvictPod), pod, policyGroupVersion)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EvictPod", reflect.TypeOf((*MockClient)(nil).EvictPod), pod, policyGroupVersion, gracePeriodSeconds)
 }
 
 // WaitForDelete mocks base method
@@ -318,6 +335,231 @@ func (mr *MockClientMockRecorder) UpdateDeployment(namespace, deployment interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeployment", reflect.TypeOf((*MockClient)(nil).UpdateDeployment), namespace, deployment)
 }
 
+// ListCustomResourceDefinitions mocks base method
+func (m *MockClient) ListCustomResourceDefinitions() (*unstructured.UnstructuredList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCustomResourceDefinitions")
+	ret0, _ := ret[0].(*unstructured.UnstructuredList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCustomResourceDefinitions indicates an expected call of ListCustomResourceDefinitions
+func (mr *MockClientMockRecorder) ListCustomResourceDefinitions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCustomResourceDefinitions", reflect.TypeOf((*MockClient)(nil).ListCustomResourceDefinitions))
+}
+
+// UpdateCustomResourceDefinition mocks base method
+func (m *MockClient) UpdateCustomResourceDefinition(crd *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCustomResourceDefinition", crd)
+	ret0, _ := ret[0].(*unstructured.Unstructured)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCustomResourceDefinition indicates an expected call of UpdateCustomResourceDefinition
+func (mr *MockClientMockRecorder) UpdateCustomResourceDefinition(crd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCustomResourceDefinition", reflect.TypeOf((*MockClient)(nil).UpdateCustomResourceDefinition), crd)
+}
+
+// GetConfigMap mocks base method
+func (m *MockClient) GetConfigMap(namespace, name string) (*v10.ConfigMap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConfigMap", namespace, name)
+	ret0, _ := ret[0].(*v10.ConfigMap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConfigMap indicates an expected call of GetConfigMap
+func (mr *MockClientMockRecorder) GetConfigMap(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfigMap", reflect.TypeOf((*MockClient)(nil).GetConfigMap), namespace, name)
+}
+
+// CreateConfigMap mocks base method
+func (m *MockClient) CreateConfigMap(configMap *v10.ConfigMap) (*v10.ConfigMap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateConfigMap", configMap)
+	ret0, _ := ret[0].(*v10.ConfigMap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateConfigMap indicates an expected call of CreateConfigMap
+func (mr *MockClientMockRecorder) CreateConfigMap(configMap interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConfigMap", reflect.TypeOf((*MockClient)(nil).CreateConfigMap), configMap)
+}
+
+// UpdateConfigMap mocks base method
+func (m *MockClient) UpdateConfigMap(configMap *v10.ConfigMap) (*v10.ConfigMap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConfigMap", configMap)
+	ret0, _ := ret[0].(*v10.ConfigMap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConfigMap indicates an expected call of UpdateConfigMap
+func (mr *MockClientMockRecorder) UpdateConfigMap(configMap interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConfigMap", reflect.TypeOf((*MockClient)(nil).UpdateConfigMap), configMap)
+}
+
+// ListResourceQuotas mocks base method
+func (m *MockClient) ListResourceQuotas(namespace string) (*v10.ResourceQuotaList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListResourceQuotas", namespace)
+	ret0, _ := ret[0].(*v10.ResourceQuotaList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListResourceQuotas indicates an expected call of ListResourceQuotas
+func (mr *MockClientMockRecorder) ListResourceQuotas(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListResourceQuotas", reflect.TypeOf((*MockClient)(nil).ListResourceQuotas), namespace)
+}
+
+// UpdateResourceQuota mocks base method
+func (m *MockClient) UpdateResourceQuota(quota *v10.ResourceQuota) (*v10.ResourceQuota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateResourceQuota", quota)
+	ret0, _ := ret[0].(*v10.ResourceQuota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateResourceQuota indicates an expected call of UpdateResourceQuota
+func (mr *MockClientMockRecorder) UpdateResourceQuota(quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateResourceQuota", reflect.TypeOf((*MockClient)(nil).UpdateResourceQuota), quota)
+}
+
+// ListLimitRanges mocks base method
+func (m *MockClient) ListLimitRanges(namespace string) (*v10.LimitRangeList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLimitRanges", namespace)
+	ret0, _ := ret[0].(*v10.LimitRangeList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLimitRanges indicates an expected call of ListLimitRanges
+func (mr *MockClientMockRecorder) ListLimitRanges(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLimitRanges", reflect.TypeOf((*MockClient)(nil).ListLimitRanges), namespace)
+}
+
+// ListPodDisruptionBudgets mocks base method
+func (m *MockClient) ListPodDisruptionBudgets(namespace string) (*v1beta1.PodDisruptionBudgetList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPodDisruptionBudgets", namespace)
+	ret0, _ := ret[0].(*v1beta1.PodDisruptionBudgetList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPodDisruptionBudgets indicates an expected call of ListPodDisruptionBudgets
+func (mr *MockClientMockRecorder) ListPodDisruptionBudgets(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPodDisruptionBudgets", reflect.TypeOf((*MockClient)(nil).ListPodDisruptionBudgets), namespace)
+}
+
+// UpdateLimitRange mocks base method
+func (m *MockClient) UpdateLimitRange(limitRange *v10.LimitRange) (*v10.LimitRange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLimitRange", limitRange)
+	ret0, _ := ret[0].(*v10.LimitRange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLimitRange indicates an expected call of UpdateLimitRange
+func (mr *MockClientMockRecorder) UpdateLimitRange(limitRange interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLimitRange", reflect.TypeOf((*MockClient)(nil).UpdateLimitRange), limitRange)
+}
+
+// CreateEvent mocks base method
+func (m *MockClient) CreateEvent(event *v10.Event) (*v10.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEvent", event)
+	ret0, _ := ret[0].(*v10.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEvent indicates an expected call of CreateEvent
+func (mr *MockClientMockRecorder) CreateEvent(event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEvent", reflect.TypeOf((*MockClient)(nil).CreateEvent), event)
+}
+
+// GetService mocks base method
+func (m *MockClient) GetService(namespace, name string) (*v10.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetService", namespace, name)
+	ret0, _ := ret[0].(*v10.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetService indicates an expected call of GetService
+func (mr *MockClientMockRecorder) GetService(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetService", reflect.TypeOf((*MockClient)(nil).GetService), namespace, name)
+}
+
+// CreatePod mocks base method
+func (m *MockClient) CreatePod(pod *v10.Pod) (*v10.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePod", pod)
+	ret0, _ := ret[0].(*v10.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePod indicates an expected call of CreatePod
+func (mr *MockClientMockRecorder) CreatePod(pod interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePod", reflect.TypeOf((*MockClient)(nil).CreatePod), pod)
+}
+
+// GetPod mocks base method
+func (m *MockClient) GetPod(namespace, name string) (*v10.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPod", namespace, name)
+	ret0, _ := ret[0].(*v10.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPod indicates an expected call of GetPod
+func (mr *MockClientMockRecorder) GetPod(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPod", reflect.TypeOf((*MockClient)(nil).GetPod), namespace, name)
+}
+
+// GetPodLogs mocks base method
+func (m *MockClient) GetPodLogs(namespace, name string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPodLogs", namespace, name)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPodLogs indicates an expected call of GetPodLogs
+func (mr *MockClientMockRecorder) GetPodLogs(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPodLogs", reflect.TypeOf((*MockClient)(nil).GetPodLogs), namespace, name)
+}
+
 // MockNodeLister is a mock of NodeLister interface
 type MockNodeLister struct {
 	ctrl     *gomock.Controller
@@ -27,10 +27,13 @@ const (
 )
 
 type drainOperation struct {
-	client  kubernetes.Client
-	node    *v1.Node
-	logger  *log.Entry
-	timeout time.Duration
+	client             kubernetes.Client
+	node               *v1.Node
+	logger             *log.Entry
+	timeout            time.Duration
+	gracePeriodSeconds *int64
+	// maxEvictionErrors is the number of pod eviction errors logged and ignored before the drain is aborted
+	maxEvictionErrors int
 }
 
 type podFilter func(v1.Pod) bool
@@ -47,6 +50,20 @@ func SafelyDrainNode(az armhelpers.AKSEngineClient, logger *log.Entry, apiserver
 
 // SafelyDrainNodeWithClient safely drains a node so that it can be deleted from the cluster
 func SafelyDrainNodeWithClient(client kubernetes.Client, logger *log.Entry, nodeName string, timeout time.Duration) error {
+	return SafelyDrainNodeWithGracePeriod(client, logger, nodeName, timeout, 0)
+}
+
+// SafelyDrainNodeWithGracePeriod safely drains a node so that it can be deleted from the cluster,
+// giving each evicted pod gracePeriod to terminate. Pods use their own termination grace period
+// if gracePeriod is zero.
+func SafelyDrainNodeWithGracePeriod(client kubernetes.Client, logger *log.Entry, nodeName string, timeout, gracePeriod time.Duration) error {
+	return SafelyDrainNodeWithMaxEvictionErrors(client, logger, nodeName, timeout, gracePeriod, 0)
+}
+
+// SafelyDrainNodeWithMaxEvictionErrors safely drains a node so that it can be deleted from the cluster,
+// like SafelyDrainNodeWithGracePeriod. Up to maxEvictionErrors pods failing to be evicted are logged
+// and left behind, the drain is only aborted when more pods fail.
+func SafelyDrainNodeWithMaxEvictionErrors(client kubernetes.Client, logger *log.Entry, nodeName string, timeout, gracePeriod time.Duration, maxEvictionErrors int) error {
 	nodeName = strings.ToLower(nodeName)
 	//Mark the node unschedulable
 	var node *v1.Node
@@ -72,7 +89,12 @@ func SafelyDrainNodeWithClient(client kubernetes.Client, logger *log.Entry, node
 	logger.Infof("Node %s has been marked unschedulable.", nodeName)
 
 	//Evict pods in node
-	drainOp := &drainOperation{client: client, node: node, logger: logger, timeout: timeout}
+	drainOp := &drainOperation{client: client, node: node, logger: logger, timeout: timeout, maxEvictionErrors: maxEvictionErrors}
+	if gracePeriod > 0 {
+		// round up, a zero grace period would kill the pods immediately
+		gracePeriodSeconds := int64((gracePeriod + time.Second - 1) / time.Second)
+		drainOp.gracePeriodSeconds = &gracePeriodSeconds
+	}
 	return drainOp.deleteOrEvictPodsSimple()
 }
 
@@ -177,7 +199,7 @@ func (o *drainOperation) evictPods(pods []v1.Pod, policyGroupVersion string) err
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	doneCh := make(chan bool, len(pods))
-	errCh := make(chan error, 1)
+	errCh := make(chan error, len(pods))
 
 	for _, pod := range pods {
 		go func(ctx context.Context, pod v1.Pod, doneCh chan bool, errCh chan error) {
@@ -188,7 +210,7 @@ func (o *drainOperation) evictPods(pods []v1.Pod, policyGroupVersion string) err
 				case <-ctx.Done():
 					return
 				default:
-					err = o.client.EvictPod(&pod, policyGroupVersion)
+					err = o.client.EvictPod(&pod, policyGroupVersion, o.gracePeriodSeconds)
 					if err == nil {
 						break doneEviction
 					} else if apierrors.IsNotFound(err) {
@@ -212,14 +234,21 @@ func (o *drainOperation) evictPods(pods []v1.Pod, policyGroupVersion string) err
 		}(ctx, pod, doneCh, errCh)
 	}
 
-	doneCount := 0
+	doneCount, errCount := 0, 0
 	for {
 		select {
 		case err := <-errCh:
-			return err
+			errCount++
+			if errCount > o.maxEvictionErrors {
+				return err
+			}
+			o.logger.Warningf("Ignoring pod eviction error %d of at most %d on node %s: %v", errCount, o.maxEvictionErrors, o.node.Name, err)
+			if doneCount+errCount == len(pods) {
+				return nil
+			}
 		case <-doneCh:
 			doneCount++
-			if doneCount == len(pods) {
+			if doneCount+errCount == len(pods) {
 				return nil
 			}
 		case <-time.After(o.timeout):
@@ -230,7 +259,7 @@ func (o *drainOperation) evictPods(pods []v1.Pod, policyGroupVersion string) err
 
 func (o *drainOperation) deletePods(pods []v1.Pod) error {
 	for _, pod := range pods {
-		err := o.client.DeletePod(&pod)
+		err := o.client.DeletePod(&pod, o.gracePeriodSeconds)
 		if err != nil && !apierrors.IsNotFound(err) {
 			return err
 		}
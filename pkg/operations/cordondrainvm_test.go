@@ -11,6 +11,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -137,4 +138,77 @@ var _ = Describe("Safely Drain node operation tests", func() {
 		Expect(err).ShouldNot(HaveOccurred())
 		Expect(len(pods)).Should(Equal(2))
 	})
+
+	It("Should evict pods with the given grace period", func() {
+		mockClient := &armhelpers.MockKubernetesClient{}
+		mockClient.PodsList = &v1.PodList{Items: []v1.Pod{{}}}
+		mockClient.ShouldSupportEviction = true
+		err := SafelyDrainNodeWithGracePeriod(mockClient, log.NewEntry(log.New()), "node", time.Minute, 90*time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockClient.GracePeriodSeconds).ShouldNot(BeNil())
+		Expect(*mockClient.GracePeriodSeconds).Should(Equal(int64(90)))
+	})
+	It("Should delete pods with their own grace period by default", func() {
+		mockClient := &armhelpers.MockKubernetesClient{}
+		mockClient.PodsList = &v1.PodList{Items: []v1.Pod{{}}}
+		err := SafelyDrainNodeWithGracePeriod(mockClient, log.NewEntry(log.New()), "node", time.Minute, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockClient.GracePeriodSeconds).Should(BeNil())
+	})
+
+	Context("Max eviction errors", func() {
+		var mockClient *armhelpers.MockKubernetesClient
+
+		BeforeEach(func() {
+			mockClient = &armhelpers.MockKubernetesClient{
+				GetNodeFunc: func(name string) (*v1.Node, error) {
+					return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+				},
+			}
+			mockClient.PodsList = &v1.PodList{Items: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod-1"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod-2"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod-3"}},
+			}}
+			mockClient.ShouldSupportEviction = true
+			mockClient.EvictPodFunc = func(pod *v1.Pod) error {
+				if pod.Name == "pod-3" {
+					return errors.New("finalizer did not complete")
+				}
+				return nil
+			}
+		})
+
+		It("Should log the eviction errors below the threshold and complete the drain", func() {
+			logger, hook := logtest.NewNullLogger()
+			err := SafelyDrainNodeWithMaxEvictionErrors(mockClient, log.NewEntry(logger), "k8s-agentpool1-0", time.Minute, 0, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var warnings []string
+			for _, entry := range hook.Entries {
+				if entry.Level == log.WarnLevel {
+					warnings = append(warnings, entry.Message)
+				}
+			}
+			Expect(warnings).Should(ConsistOf(`Ignoring pod eviction error 1 of at most 1 on node k8s-agentpool1-0: error when evicting pod "pod-3": finalizer did not complete`))
+		})
+
+		It("Should abort the drain when the threshold is exceeded", func() {
+			mockClient.EvictPodFunc = func(pod *v1.Pod) error {
+				if pod.Name != "pod-1" {
+					return errors.New("finalizer did not complete")
+				}
+				return nil
+			}
+			err := SafelyDrainNodeWithMaxEvictionErrors(mockClient, log.NewEntry(log.New()), "node", time.Minute, 0, 1)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("finalizer did not complete"))
+		})
+
+		It("Should abort the drain on the first eviction error by default", func() {
+			err := SafelyDrainNodeWithGracePeriod(mockClient, log.NewEntry(log.New()), "node", time.Minute, 0)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(Equal(`error when evicting pod "pod-3": finalizer did not complete`))
+		})
+	})
 })
@@ -0,0 +1,42 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// acceleratedNetworkingCapability is the resource SKU capability set to "True" where a VM size supports Accelerated Networking
+const acceleratedNetworkingCapability = "AcceleratedNetworkingEnabled"
+
+// checkAcceleratedNetworking logs a warning if the master VM size does not support Accelerated Networking
+// in the cluster location, in which case the deployment of the master NICs is expected to fail.
+func (kmn *UpgradeMasterNode) checkAcceleratedNetworking(ctx context.Context) error {
+	location := kmn.UpgradeContainerService.Location
+	vmSize := kmn.UpgradeContainerService.Properties.MasterProfile.VMSize
+	page, err := kmn.Client.ListResourceSkus(ctx, fmt.Sprintf("location eq '%s'", location))
+	if err != nil {
+		return errors.Wrap(err, "listing resource SKUs")
+	}
+	for page != nil && page.NotDone() {
+		for _, sku := range page.Values() {
+			if !strings.EqualFold(to.String(sku.Name), vmSize) || !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") {
+				continue
+			}
+			if sku.Capabilities != nil && hasSkuCapability(*sku.Capabilities, acceleratedNetworkingCapability) {
+				return nil
+			}
+		}
+		if err = page.NextWithContext(ctx); err != nil {
+			return errors.Wrap(err, "listing resource SKUs")
+		}
+	}
+	kmn.logger.Warningf("Accelerated Networking is not supported by VM size %s in location %s", vmSize, location)
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/pkg/errors"
+)
+
+const (
+	vmExtensionResourceType = "Microsoft.Compute/virtualMachines/extensions"
+
+	nvidiaGPUExtensionPublisher   = "Microsoft.HpcCompute"
+	nvidiaGPUExtensionLinuxType   = "NvidiaGpuDriverLinux"
+	nvidiaGPUExtensionWindowsType = "NvidiaGpuDriverWindows"
+)
+
+// DefaultGPUSKUPatternList matches the VM sizes of the Azure N-series GPU families
+var DefaultGPUSKUPatternList = []string{"Standard_NC*", "Standard_ND*", "Standard_NV*"}
+
+// isGPUSKU reports whether vmSize matches one of the shell patterns, ignoring case.
+func isGPUSKU(vmSize string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(vmSize))
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid GPU SKU pattern %q", pattern)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// agentPoolProfile returns the profile of the agent pool with the given name, or nil.
+func (kan *UpgradeAgentNode) agentPoolProfile(poolName string) *api.AgentPoolProfile {
+	for _, profile := range kan.UpgradeContainerService.Properties.AgentPoolProfiles {
+		if profile.Name == poolName {
+			return profile
+		}
+	}
+	return nil
+}
+
+// customizeTemplate applies the UpgradeAgentNode options to the agent pool resources
+// of the upgrade template before it is deployed.
+func (kan *UpgradeAgentNode) customizeTemplate(poolName string) error {
+	if kan.GPUExtensionVersion == "" {
+		return nil
+	}
+	profile := kan.agentPoolProfile(poolName)
+	if profile == nil {
+		return nil
+	}
+	patterns := kan.GPUSKUPatternList
+	if len(patterns) == 0 {
+		patterns = DefaultGPUSKUPatternList
+	}
+	gpu, err := isGPUSKU(profile.VMSize, patterns)
+	if err != nil || !gpu {
+		return err
+	}
+	addResource(kan.TemplateMap, newNvidiaGPUExtension(profile, kan.GPUExtensionVersion))
+	return nil
+}
+
+// newNvidiaGPUExtension returns the ARM resource installing the NVIDIA GPU driver
+// extension on each VM of the agent pool deployed by the upgrade template.
+func newNvidiaGPUExtension(profile *api.AgentPoolProfile, version string) map[string]interface{} {
+	extensionType := nvidiaGPUExtensionLinuxType
+	if profile.IsWindows() {
+		extensionType = nvidiaGPUExtensionWindowsType
+	}
+	return map[string]interface{}{
+		"type":       vmExtensionResourceType,
+		"apiVersion": "[variables('apiVersionCompute')]",
+		"name":       fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')), '/%[2]s')]", profile.Name, extensionType),
+		"location":   "[variables('location')]",
+		"copy": map[string]interface{}{
+			"count": fmt.Sprintf("[sub(variables('%[1]sCount'), variables('%[1]sOffset'))]", profile.Name),
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": []interface{}{
+			fmt.Sprintf("[concat('Microsoft.Compute/virtualMachines/', variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", profile.Name),
+		},
+		"properties": map[string]interface{}{
+			"publisher":               nvidiaGPUExtensionPublisher,
+			"type":                    extensionType,
+			"typeHandlerVersion":      version,
+			"autoUpgradeMinorVersion": true,
+			"settings":                map[string]interface{}{},
+		},
+	}
+}
+
+// addResource appends resourceMap to the template resources, replacing any resource
+// with the same type and name so the template can be customized repeatedly.
+func addResource(templateMap map[string]interface{}, resourceMap map[string]interface{}) {
+	resources, _ := templateMap["resources"].([]interface{})
+	for i, resource := range resources {
+		existing, ok := resource.(map[string]interface{})
+		if ok && existing["type"] == resourceMap["type"] && existing["name"] == resourceMap["name"] {
+			resources[i] = resourceMap
+			return
+		}
+	}
+	templateMap["resources"] = append(resources, resourceMap)
+}
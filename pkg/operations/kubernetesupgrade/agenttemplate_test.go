@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+func newTestUpgradeAgentNode(vmSize string) *UpgradeAgentNode {
+	cs := api.CreateMockContainerService("testcluster", "", 3, 1, false)
+	cs.Properties.AgentPoolProfiles[0].VMSize = vmSize
+	return &UpgradeAgentNode{
+		Translator:              &i18n.Translator{},
+		logger:                  log.NewEntry(log.New()),
+		TemplateMap:             newTestMasterTemplate(),
+		ParametersMap:           map[string]interface{}{"agentpool1Count": map[string]interface{}{"value": 1}},
+		UpgradeContainerService: cs,
+		SubscriptionID:          "DEC923E3-1EF1-4745-9516-37906D56DEC4",
+		ResourceGroup:           "TestRg",
+		Client:                  &armhelpers.MockAKSEngineClient{},
+	}
+}
+
+func gpuExtensions(templateMap map[string]interface{}) []map[string]interface{} {
+	var extensions []map[string]interface{}
+	for _, resource := range templateMap["resources"].([]interface{}) {
+		resourceMap := resource.(map[string]interface{})
+		if resourceMap["type"] == vmExtensionResourceType {
+			extensions = append(extensions, resourceMap)
+		}
+	}
+	return extensions
+}
+
+var _ = Describe("Upgrade agent node template tests", func() {
+	It("Should add the NVIDIA GPU extension for GPU SKUs", func() {
+		kan := newTestUpgradeAgentNode("Standard_NC6s_v3")
+		kan.GPUExtensionVersion = "1.3"
+
+		Expect(kan.CreateNode(context.Background(), "agentpool1", 0)).To(Succeed())
+		Expect(kan.CreateNode(context.Background(), "agentpool1", 1)).To(Succeed())
+
+		extensions := gpuExtensions(kan.TemplateMap)
+		Expect(extensions).To(HaveLen(1))
+		Expect(extensions[0]["name"]).To(Equal("[concat(variables('agentpool1VMNamePrefix'), copyIndex(variables('agentpool1Offset')), '/NvidiaGpuDriverLinux')]"))
+		Expect(resourceProperties(extensions[0])).To(HaveKeyWithValue("publisher", nvidiaGPUExtensionPublisher))
+		Expect(resourceProperties(extensions[0])).To(HaveKeyWithValue("typeHandlerVersion", "1.3"))
+	})
+
+	It("Should use the Windows NVIDIA GPU extension for Windows pools", func() {
+		kan := newTestUpgradeAgentNode("Standard_NV6")
+		kan.UpgradeContainerService.Properties.AgentPoolProfiles[0].OSType = api.Windows
+		kan.GPUExtensionVersion = "1.2"
+
+		Expect(kan.customizeTemplate("agentpool1")).To(Succeed())
+		extensions := gpuExtensions(kan.TemplateMap)
+		Expect(extensions).To(HaveLen(1))
+		Expect(resourceProperties(extensions[0])).To(HaveKeyWithValue("type", nvidiaGPUExtensionWindowsType))
+	})
+
+	It("Should not add the NVIDIA GPU extension for other SKUs or without a version", func() {
+		kan := newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.GPUExtensionVersion = "1.3"
+		Expect(kan.customizeTemplate("agentpool1")).To(Succeed())
+		Expect(gpuExtensions(kan.TemplateMap)).To(BeEmpty())
+
+		kan = newTestUpgradeAgentNode("Standard_NC6")
+		Expect(kan.customizeTemplate("agentpool1")).To(Succeed())
+		Expect(gpuExtensions(kan.TemplateMap)).To(BeEmpty())
+	})
+
+	It("Should match VM sizes against the configured GPU SKU patterns", func() {
+		kan := newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.GPUExtensionVersion = "1.3"
+		kan.GPUSKUPatternList = []string{"standard_d2*"}
+		Expect(kan.customizeTemplate("agentpool1")).To(Succeed())
+		Expect(gpuExtensions(kan.TemplateMap)).To(HaveLen(1))
+
+		kan = newTestUpgradeAgentNode("Standard_NC6")
+		kan.GPUExtensionVersion = "1.3"
+		kan.GPUSKUPatternList = []string{"Standard_NC["}
+		Expect(kan.customizeTemplate("agentpool1")).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,100 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+const applicationSecurityGroupResourceType = "Microsoft.Network/applicationSecurityGroups"
+
+// validateApplicationSecurityGroups ensures the application security groups are application security group resource IDs
+func (kmn *UpgradeMasterNode) validateApplicationSecurityGroups() error {
+	for _, id := range kmn.ApplicationSecurityGroups {
+		if _, err := utils.ResourceGroupName(id); err != nil {
+			return errors.Wrapf(err, "parsing application security group ID %s", id)
+		}
+		if !strings.Contains(strings.ToLower(id), strings.ToLower(applicationSecurityGroupResourceType+"/")) {
+			return errors.Errorf("%s is not the resource ID of an application security group", id)
+		}
+	}
+	return nil
+}
+
+// addApplicationSecurityGroups adds the application security groups to every IP configuration of a master NIC resource.
+// The application security groups the template already sets are kept.
+func (kmn *UpgradeMasterNode) addApplicationSecurityGroups(nic map[string]interface{}) {
+	ipConfigurations, _ := resourceProperties(nic)["ipConfigurations"].([]interface{})
+	for _, ipConfiguration := range ipConfigurations {
+		ipConfigurationMap, ok := ipConfiguration.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		properties := resourceProperties(ipConfigurationMap)
+		groups, _ := properties["applicationSecurityGroups"].([]interface{})
+		existing := map[string]bool{}
+		for _, group := range groups {
+			if groupMap, ok := group.(map[string]interface{}); ok {
+				if id, ok := groupMap["id"].(string); ok {
+					existing[strings.ToLower(id)] = true
+				}
+			}
+		}
+		for _, id := range kmn.ApplicationSecurityGroups {
+			if !existing[strings.ToLower(id)] {
+				groups = append(groups, map[string]interface{}{"id": id})
+			}
+		}
+		properties["applicationSecurityGroups"] = groups
+	}
+}
+
+// VerifyApplicationSecurityGroups checks that the NICs of the master VM vmName were created
+// in the application security groups set on the UpgradeMasterNode.
+func (kmn *UpgradeMasterNode) VerifyApplicationSecurityGroups(ctx context.Context, vmName string) error {
+	nics, err := kmn.Client.ListNetworkInterfaces(ctx, kmn.ResourceGroup)
+	if err != nil {
+		return errors.Wrapf(err, "listing network interfaces in resource group %s", kmn.ResourceGroup)
+	}
+	vmSuffix := strings.ToLower("/virtualMachines/" + vmName)
+	found := false
+	for _, nic := range nics {
+		if nic.InterfacePropertiesFormat == nil || nic.VirtualMachine == nil ||
+			!strings.HasSuffix(strings.ToLower(to.String(nic.VirtualMachine.ID)), vmSuffix) {
+			continue
+		}
+		found = true
+		assigned := map[string]bool{}
+		if nic.IPConfigurations != nil {
+			for _, ipConfiguration := range *nic.IPConfigurations {
+				if ipConfiguration.InterfaceIPConfigurationPropertiesFormat == nil || ipConfiguration.ApplicationSecurityGroups == nil {
+					continue
+				}
+				for _, group := range *ipConfiguration.ApplicationSecurityGroups {
+					assigned[strings.ToLower(to.String(group.ID))] = true
+				}
+			}
+		}
+		var missing []string
+		for _, id := range kmn.ApplicationSecurityGroups {
+			if !assigned[strings.ToLower(id)] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return errors.Errorf("NIC %s of VM %s is missing the application security groups %s", to.String(nic.Name), vmName, strings.Join(missing, ", "))
+		}
+	}
+	if !found {
+		return errors.Errorf("no network interface of VM %s found in resource group %s", vmName, kmn.ResourceGroup)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// GetVMAvailabilityZone returns the availability zone of a VM of the resource group,
+// or an empty string if the VM is not zonal.
+func (kmn *UpgradeMasterNode) GetVMAvailabilityZone(ctx context.Context, vmName string) (string, error) {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting virtual machine %s", vmName)
+	}
+	if vm.Zones == nil || len(*vm.Zones) == 0 {
+		return "", nil
+	}
+	return (*vm.Zones)[0], nil
+}
+
+// validateAvailabilityZone ensures the master VM size supports zone in the cluster location.
+func (kmn *UpgradeMasterNode) validateAvailabilityZone(ctx context.Context, zone string) error {
+	location := kmn.UpgradeContainerService.Location
+	vmSize := kmn.UpgradeContainerService.Properties.MasterProfile.VMSize
+	page, err := kmn.Client.ListResourceSkus(ctx, fmt.Sprintf("location eq '%s'", location))
+	if err != nil {
+		return errors.Wrap(err, "listing resource SKUs")
+	}
+	var zones []string
+	for page != nil && page.NotDone() {
+		for _, sku := range page.Values() {
+			if !strings.EqualFold(to.String(sku.Name), vmSize) || !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") || sku.LocationInfo == nil {
+				continue
+			}
+			for _, info := range *sku.LocationInfo {
+				if strings.EqualFold(to.String(info.Location), location) && info.Zones != nil {
+					zones = append(zones, *info.Zones...)
+				}
+			}
+		}
+		if err = page.NextWithContext(ctx); err != nil {
+			return errors.Wrap(err, "listing resource SKUs")
+		}
+	}
+	for _, z := range zones {
+		if z == zone {
+			return nil
+		}
+	}
+	return errors.Errorf("availability zone %q is not supported by VM size %s in location %s, supported zones: %v", zone, vmSize, location, zones)
+}
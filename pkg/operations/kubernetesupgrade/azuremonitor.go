@@ -0,0 +1,128 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AzureMonitorMetricsNamespace is the custom metrics namespace used for upgrade metrics
+	AzureMonitorMetricsNamespace = "aks-engine/upgrade"
+
+	metricNodeDuration      = "upgrade_node_duration_seconds"
+	metricNodeDrainDuration = "upgrade_node_drain_duration_seconds"
+	metricNodesTotal        = "upgrade_nodes_total"
+)
+
+// Compiler to verify AzureMonitorReporter implements UpgradeReporter
+var _ UpgradeReporter = &AzureMonitorReporter{}
+
+// AzureMonitorReporter emits node upgrade metrics to Azure Monitor custom metrics
+type AzureMonitorReporter struct {
+	// Endpoint is the regional metrics ingestion endpoint, e.g. https://eastus.monitoring.azure.com
+	Endpoint string
+	// ResourceID is the Azure resource the custom metrics are attached to
+	ResourceID string
+	// Namespace is the custom metrics namespace, defaults to AzureMonitorMetricsNamespace
+	Namespace string
+	// Authorizer must issue tokens for the https://monitoring.azure.com/ audience
+	Authorizer autorest.Authorizer
+	HTTPClient *http.Client
+}
+
+// NewAzureMonitorReporter returns an AzureMonitorReporter posting metrics for resourceID
+// to the ingestion endpoint of the given region
+func NewAzureMonitorReporter(location, resourceID string, authorizer autorest.Authorizer) *AzureMonitorReporter {
+	return &AzureMonitorReporter{
+		Endpoint:   fmt.Sprintf("https://%s.monitoring.azure.com", location),
+		ResourceID: resourceID,
+		Namespace:  AzureMonitorMetricsNamespace,
+		Authorizer: authorizer,
+		HTTPClient: &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+type azureMonitorSeries struct {
+	DimValues []string `json:"dimValues"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int      `json:"count"`
+}
+
+type azureMonitorBaseData struct {
+	Metric    string               `json:"metric"`
+	Namespace string               `json:"namespace"`
+	DimNames  []string             `json:"dimNames"`
+	Series    []azureMonitorSeries `json:"series"`
+}
+
+type azureMonitorData struct {
+	BaseData azureMonitorBaseData `json:"baseData"`
+}
+
+type azureMonitorMetric struct {
+	Time string           `json:"time"`
+	Data azureMonitorData `json:"data"`
+}
+
+// Report emits the node duration, drain duration and node count metrics for a NodeUpgraded event.
+// Other event types are ignored.
+func (r *AzureMonitorReporter) Report(event UpgradeEvent) error {
+	if event.Type != NodeUpgradedEvent {
+		return nil
+	}
+	values := []struct {
+		name  string
+		value float64
+	}{
+		{metricNodeDuration, event.Duration.Seconds()},
+		{metricNodeDrainDuration, event.DrainDuration.Seconds()},
+		{metricNodesTotal, 1},
+	}
+	for _, v := range values {
+		if err := r.post(r.newMetric(event, v.name, v.value)); err != nil {
+			return errors.Wrapf(err, "emitting metric %s", v.name)
+		}
+	}
+	return nil
+}
+
+func (r *AzureMonitorReporter) newMetric(event UpgradeEvent, name string, value float64) azureMonitorMetric {
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = AzureMonitorMetricsNamespace
+	}
+	return azureMonitorMetric{
+		Time: event.Time.UTC().Format(time.RFC3339),
+		Data: azureMonitorData{
+			BaseData: azureMonitorBaseData{
+				Metric:    name,
+				Namespace: namespace,
+				DimNames:  []string{"pool"},
+				Series: []azureMonitorSeries{
+					{
+						DimValues: []string{event.PoolName},
+						Min:       value,
+						Max:       value,
+						Sum:       value,
+						Count:     1,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *AzureMonitorReporter) post(metric azureMonitorMetric) error {
+	url := strings.TrimSuffix(r.Endpoint, "/") + "/" + strings.TrimPrefix(r.ResourceID, "/") + "/metrics"
+	return sendJSON(r.HTTPClient, r.Authorizer, http.MethodPost, url, metric)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+const testMetricsResourceID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Compute/virtualMachines/k8s-master-12345678-0"
+
+type fakeReporter struct {
+	events []UpgradeEvent
+	err    error
+}
+
+func (r *fakeReporter) Report(event UpgradeEvent) error {
+	r.events = append(r.events, event)
+	return r.err
+}
+
+var _ = Describe("Azure Monitor reporter tests", func() {
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		paths    []string
+		metrics  []azureMonitorMetric
+		status   int
+		reporter *AzureMonitorReporter
+	)
+
+	BeforeEach(func() {
+		paths = nil
+		metrics = nil
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			body, _ := ioutil.ReadAll(r.Body)
+			var m azureMonitorMetric
+			_ = json.Unmarshal(body, &m)
+			paths = append(paths, r.URL.Path)
+			metrics = append(metrics, m)
+			w.WriteHeader(status)
+		}))
+		reporter = NewAzureMonitorReporter("eastus", testMetricsResourceID, autorest.NullAuthorizer{})
+		reporter.Endpoint = server.URL
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should emit duration, drain duration and node count metrics for a node upgrade", func() {
+		eventTime := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+		err := reporter.Report(UpgradeEvent{
+			Type:          NodeUpgradedEvent,
+			Time:          eventTime,
+			PoolName:      "agentpool1",
+			NodeName:      "k8s-agentpool1-12345678-0",
+			Duration:      90 * time.Second,
+			DrainDuration: 30 * time.Second,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(paths).To(HaveLen(3))
+		for _, p := range paths {
+			Expect(p).To(Equal(testMetricsResourceID + "/metrics"))
+		}
+
+		Expect(metrics[0].Time).To(Equal("2020-06-01T10:00:00Z"))
+		Expect(metrics[0].Data.BaseData.Metric).To(Equal("upgrade_node_duration_seconds"))
+		Expect(metrics[0].Data.BaseData.Namespace).To(Equal(AzureMonitorMetricsNamespace))
+		Expect(metrics[0].Data.BaseData.DimNames).To(Equal([]string{"pool"}))
+		Expect(metrics[0].Data.BaseData.Series).To(Equal([]azureMonitorSeries{
+			{DimValues: []string{"agentpool1"}, Min: 90, Max: 90, Sum: 90, Count: 1},
+		}))
+		Expect(metrics[1].Data.BaseData.Metric).To(Equal("upgrade_node_drain_duration_seconds"))
+		Expect(metrics[1].Data.BaseData.Series[0].Sum).To(Equal(float64(30)))
+		Expect(metrics[2].Data.BaseData.Metric).To(Equal("upgrade_nodes_total"))
+		Expect(metrics[2].Data.BaseData.Series[0].Sum).To(Equal(float64(1)))
+	})
+
+	It("Should ignore events other than node upgrades", func() {
+		err := reporter.Report(UpgradeEvent{Type: "Other"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(BeEmpty())
+	})
+
+	It("Should return an error when Azure Monitor rejects the metric", func() {
+		status = http.StatusForbidden
+		err := reporter.Report(UpgradeEvent{Type: NodeUpgradedEvent, Time: time.Now()})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("upgrade_node_duration_seconds"))
+		Expect(err.Error()).To(ContainSubstring("403"))
+	})
+
+	It("Should not fail the upgrade when a reporter errors", func() {
+		failing := &fakeReporter{err: http.ErrHandlerTimeout}
+		ok := &fakeReporter{}
+		ku := &Upgrader{
+			logger:    log.NewEntry(log.New()),
+			Reporters: []UpgradeReporter{failing, ok},
+		}
+		ku.reportEvent(UpgradeEvent{Type: NodeUpgradedEvent, NodeName: "k8s-master-12345678-0"})
+		Expect(failing.events).To(HaveLen(1))
+		Expect(ok.events).To(HaveLen(1))
+		Expect(ok.events[0].Time.IsZero()).To(BeFalse())
+	})
+})
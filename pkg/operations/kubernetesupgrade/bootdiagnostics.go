@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// validateBootDiagnostics ensures BootDiagnosticsStorageAccountURI is the https blob endpoint of a storage account
+// and is only set along with BootDiagnosticsEnabled
+func (kmn *UpgradeMasterNode) validateBootDiagnostics() error {
+	if kmn.BootDiagnosticsStorageAccountURI == "" {
+		return nil
+	}
+	if !kmn.BootDiagnosticsEnabled {
+		return errors.New("a boot diagnostics storage account URI requires enabling boot diagnostics")
+	}
+	u, err := url.Parse(kmn.BootDiagnosticsStorageAccountURI)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("invalid boot diagnostics storage account URI %s, expected https://<account>.blob.<storage endpoint suffix>/", kmn.BootDiagnosticsStorageAccountURI)
+	}
+	return nil
+}
+
+// setBootDiagnostics enables the boot diagnostics of the master VM resource, stored in
+// BootDiagnosticsStorageAccountURI or in a managed storage account if empty
+func (kmn *UpgradeMasterNode) setBootDiagnostics(vm map[string]interface{}) {
+	bootDiagnostics := map[string]interface{}{
+		"enabled": true,
+	}
+	if kmn.BootDiagnosticsStorageAccountURI != "" {
+		bootDiagnostics["storageUri"] = kmn.BootDiagnosticsStorageAccountURI
+	}
+	resourceProperties(vm)["diagnosticsProfile"] = map[string]interface{}{
+		"bootDiagnostics": bootDiagnostics,
+	}
+}
+
+// VerifyBootDiagnostics fails unless boot diagnostics are enabled on the created master VM,
+// stored in BootDiagnosticsStorageAccountURI if set
+func (kmn *UpgradeMasterNode) VerifyBootDiagnostics(ctx context.Context, vmName string) error {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return errors.Wrapf(err, "getting VM %s", vmName)
+	}
+	if vm.VirtualMachineProperties == nil || vm.DiagnosticsProfile == nil || vm.DiagnosticsProfile.BootDiagnostics == nil ||
+		!to.Bool(vm.DiagnosticsProfile.BootDiagnostics.Enabled) {
+		return errors.Errorf("boot diagnostics are not enabled on VM %s", vmName)
+	}
+	if kmn.BootDiagnosticsStorageAccountURI != "" {
+		storageURI := to.String(vm.DiagnosticsProfile.BootDiagnostics.StorageURI)
+		if !strings.EqualFold(strings.TrimSuffix(storageURI, "/"), strings.TrimSuffix(kmn.BootDiagnosticsStorageAccountURI, "/")) {
+			return errors.Errorf("the boot diagnostics of VM %s are stored in %q, expected %s", vmName, storageURI, kmn.BootDiagnosticsStorageAccountURI)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CanaryResumeFileName is the file of OutputDirectory written when the upgrade pauses after a canary node,
+// unless CanaryResumeFile is set; the upgrade resumes once the file is removed
+const CanaryResumeFileName = "upgrade-canary-resume"
+
+// completeCanary reports CanaryCompletedEvent after the first node of each pool is upgraded if CanaryUpgrade is set.
+// With CanaryPauseForConfirmation, the upgrade then waits for CanaryConfirm to approve the canary, or, without
+// CanaryConfirm, writes the canary resume file and waits until the operator removes it.
+func (ku *Upgrader) completeCanary(ctx context.Context, poolName, nodeName string) error {
+	if !ku.CanaryUpgrade || ku.canaryPools[poolName] {
+		return nil
+	}
+	if ku.canaryPools == nil {
+		ku.canaryPools = map[string]bool{}
+	}
+	ku.canaryPools[poolName] = true
+	ku.reportEvent(UpgradeEvent{
+		Type:     CanaryCompletedEvent,
+		PoolName: poolName,
+		NodeName: nodeName,
+		Message:  fmt.Sprintf("canary node %s of pool %s upgraded", nodeName, poolName),
+	})
+	if !ku.CanaryPauseForConfirmation {
+		ku.logger.Infof("Canary node %s of pool %s upgraded, continuing with the other nodes of the pool", nodeName, poolName)
+		return nil
+	}
+
+	if ku.CanaryConfirm != nil {
+		approved, err := ku.CanaryConfirm(ctx, poolName, nodeName)
+		if err != nil {
+			return errors.Wrapf(err, "confirming the canary upgrade of node %s", nodeName)
+		}
+		if !approved {
+			return errors.Errorf("the canary upgrade of node %s of pool %s was not approved", nodeName, poolName)
+		}
+		ku.logger.Infof("Canary node %s of pool %s approved, continuing with the other nodes of the pool", nodeName, poolName)
+		return nil
+	}
+	return ku.waitForCanaryResumeFile(ctx, poolName, nodeName)
+}
+
+// waitForCanaryResumeFile writes the canary resume file and waits until it is removed
+func (ku *Upgrader) waitForCanaryResumeFile(ctx context.Context, poolName, nodeName string) error {
+	path := ku.CanaryResumeFile
+	if path == "" {
+		if ku.OutputDirectory == "" {
+			return errors.New("pausing after a canary node requires CanaryConfirm, CanaryResumeFile or OutputDirectory")
+		}
+		path = filepath.Join(ku.OutputDirectory, CanaryResumeFileName)
+	}
+	content := fmt.Sprintf("Canary node %s of pool %s upgraded at %s, remove this file to continue the upgrade\n",
+		nodeName, poolName, time.Now().UTC().Format(time.RFC3339))
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		return errors.Wrapf(err, "writing canary resume file %s", path)
+	}
+	ku.logger.Infof("Canary node %s of pool %s upgraded, upgrade paused until %s is removed", nodeName, poolName, path)
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for %s to be removed to resume the upgrade", path)
+		case <-time.After(pauseCheckInterval):
+		}
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			ku.logger.Infof("Upgrade resumed after a %v canary pause", time.Since(start).Round(time.Second))
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "checking canary resume file %s", path)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("Canary upgrade tests", func() {
+	var (
+		u                    *Upgrader
+		reporter             *fakeReporter
+		dir                  string
+		defaultCheckInterval time.Duration
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "canary")
+		Expect(err).NotTo(HaveOccurred())
+		defaultCheckInterval = pauseCheckInterval
+		pauseCheckInterval = 10 * time.Millisecond
+		reporter = &fakeReporter{}
+		u = newTestCRDUpgrader("1.18.8", nil)
+		u.Reporters = []UpgradeReporter{reporter}
+		u.CanaryUpgrade = true
+		u.OutputDirectory = dir
+	})
+
+	AfterEach(func() {
+		pauseCheckInterval = defaultCheckInterval
+		os.RemoveAll(dir)
+	})
+
+	It("Should do nothing unless CanaryUpgrade is set", func() {
+		u.CanaryUpgrade = false
+		Expect(u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")).To(Succeed())
+		Expect(reporter.events).To(BeEmpty())
+	})
+
+	It("Should report the first node of each pool only", func() {
+		Expect(u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")).To(Succeed())
+		Expect(u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-1")).To(Succeed())
+		Expect(u.completeCanary(context.Background(), "agentpool2", "k8s-agentpool2-12345678-0")).To(Succeed())
+
+		Expect(reporter.events).To(HaveLen(2))
+		Expect(reporter.events[0].Type).To(Equal(CanaryCompletedEvent))
+		Expect(reporter.events[0].NodeName).To(Equal("k8s-agentpool1-12345678-0"))
+		Expect(reporter.events[1].PoolName).To(Equal("agentpool2"))
+		Expect(filepath.Join(dir, CanaryResumeFileName)).NotTo(BeAnExistingFile())
+	})
+
+	It("Should continue once CanaryConfirm approves the canary", func() {
+		u.CanaryPauseForConfirmation = true
+		var confirmed []string
+		u.CanaryConfirm = func(ctx context.Context, poolName, nodeName string) (bool, error) {
+			confirmed = append(confirmed, nodeName)
+			return true, nil
+		}
+
+		Expect(u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")).To(Succeed())
+		Expect(u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-1")).To(Succeed())
+		Expect(confirmed).To(Equal([]string{"k8s-agentpool1-12345678-0"}))
+	})
+
+	It("Should return an error when CanaryConfirm rejects the canary", func() {
+		u.CanaryPauseForConfirmation = true
+		u.CanaryConfirm = func(ctx context.Context, poolName, nodeName string) (bool, error) {
+			return false, nil
+		}
+
+		err := u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("was not approved"))
+	})
+
+	It("Should return an error when CanaryConfirm fails", func() {
+		u.CanaryPauseForConfirmation = true
+		u.CanaryConfirm = func(ctx context.Context, poolName, nodeName string) (bool, error) {
+			return false, errors.New("EOF")
+		}
+
+		err := u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("confirming the canary upgrade of node k8s-agentpool1-12345678-0"))
+	})
+
+	It("Should pause until the canary resume file is removed", func() {
+		u.CanaryPauseForConfirmation = true
+		path := filepath.Join(dir, CanaryResumeFileName)
+		done := make(chan error)
+		go func() {
+			done <- u.completeCanary(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")
+		}()
+
+		Eventually(path).Should(BeAnExistingFile())
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		Expect(os.Remove(path)).To(Succeed())
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("Should return an error when the context ends while waiting for the canary resume file", func() {
+		u.CanaryPauseForConfirmation = true
+		u.CanaryResumeFile = filepath.Join(dir, "resume")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		err := u.completeCanary(ctx, "agentpool1", "k8s-agentpool1-12345678-0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("to resume the upgrade"))
+		Expect(u.CanaryResumeFile).To(BeAnExistingFile())
+	})
+
+	It("Should not bound the upgrade phases with a timeout when pausing for the canary", func() {
+		u.CanaryPauseForConfirmation = true
+		ctx, cancel := u.upgradeContext(time.Minute)
+		defer cancel()
+		_, ok := ctx.Deadline()
+		Expect(ok).To(BeFalse())
+	})
+})
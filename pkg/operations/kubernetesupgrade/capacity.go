@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ErrInsufficientCapacity is the cause of the error returned by CapacityPreflightCheck when
+// the remaining nodes cannot take on the workloads of the node to drain
+var ErrInsufficientCapacity = errors.New("insufficient cluster capacity")
+
+// CapacityPreflightCheck verifies that once nodeName is drained, the remaining schedulable nodes
+// still have MinFreeCapacityPercent of their allocatable CPU and memory left after accommodating
+// the requests of all running pods not managed by a DaemonSet.
+func (kan *UpgradeAgentNode) CapacityPreflightCheck(ctx context.Context, nodeName string) error {
+	apiserverURL := kan.UpgradeContainerService.Properties.MasterProfile.FQDN
+	client, err := kan.Client.GetKubernetesClient(apiserverURL, kan.kubeConfig, interval, kan.timeout)
+	if err != nil {
+		return err
+	}
+	return checkCapacity(client, strings.ToLower(nodeName), kan.MinFreeCapacityPercent)
+}
+
+func checkCapacity(client kubernetes.Client, nodeName string, minFreeCapacityPercent float64) error {
+	nodes, err := client.ListNodes()
+	if err != nil {
+		return errors.Wrap(err, "listing nodes")
+	}
+	pods, err := client.ListAllPods()
+	if err != nil {
+		return errors.Wrap(err, "listing pods")
+	}
+
+	// the drained node workloads can only move to ready, schedulable nodes
+	remaining := map[string]bool{}
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.Quantity{},
+		v1.ResourceMemory: resource.Quantity{},
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.Name == nodeName || node.Spec.Unschedulable || !kubernetes.IsNodeReady(node) || hasNoScheduleTaint(node) {
+			continue
+		}
+		remaining[node.Name] = true
+		addResources(allocatable, node.Status.Allocatable)
+	}
+
+	requests := v1.ResourceList{
+		v1.ResourceCPU:    resource.Quantity{},
+		v1.ResourceMemory: resource.Quantity{},
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != v1.PodRunning || isDaemonSetPod(pod) {
+			continue
+		}
+		if pod.Spec.NodeName != nodeName && !remaining[pod.Spec.NodeName] {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			addResources(requests, c.Resources.Requests)
+		}
+	}
+
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		alloc := allocatable[name]
+		req := requests[name]
+		if req.IsZero() {
+			continue
+		}
+		if alloc.IsZero() {
+			return errors.Wrapf(ErrInsufficientCapacity, "no %s left to reschedule the pods of node %s", name, nodeName)
+		}
+		free := float64(alloc.MilliValue()-req.MilliValue()) / float64(alloc.MilliValue()) * 100
+		if free < minFreeCapacityPercent {
+			return errors.Wrapf(ErrInsufficientCapacity, "draining node %s would leave %.1f%% of %s free, at least %.1f%% is required",
+				nodeName, free, name, minFreeCapacityPercent)
+		}
+	}
+	return nil
+}
+
+func addResources(total, list v1.ResourceList) {
+	for name, sum := range total {
+		if q, ok := list[name]; ok {
+			sum.Add(q)
+			total[name] = sum
+		}
+	}
+}
+
+func hasNoScheduleTaint(node *v1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == v1.TaintEffectNoSchedule || taint.Effect == v1.TaintEffectNoExecute {
+			return true
+		}
+	}
+	return false
+}
+
+func isDaemonSetPod(pod *v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCapacityNode(name, cpu, memory string) v1.Node {
+	node := v1.Node{}
+	node.Name = name
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	node.Status.Allocatable = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	return node
+}
+
+func newTestCapacityPod(nodeName, cpu, memory string) v1.Pod {
+	pod := v1.Pod{}
+	pod.Spec.NodeName = nodeName
+	pod.Status.Phase = v1.PodRunning
+	pod.Spec.Containers = []v1.Container{
+		{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(cpu),
+					v1.ResourceMemory: resource.MustParse(memory),
+				},
+			},
+		},
+	}
+	return pod
+}
+
+var _ = Describe("Capacity preflight check tests", func() {
+	var kubeClient *armhelpers.MockKubernetesClient
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			NodesList: &v1.NodeList{Items: []v1.Node{
+				newTestCapacityNode("k8s-agentpool1-12345678-0", "4", "16Gi"),
+				newTestCapacityNode("k8s-agentpool1-12345678-1", "4", "16Gi"),
+			}},
+			PodsList: &v1.PodList{Items: []v1.Pod{
+				newTestCapacityPod("k8s-agentpool1-12345678-0", "1", "4Gi"),
+				newTestCapacityPod("k8s-agentpool1-12345678-1", "1", "4Gi"),
+			}},
+		}
+	})
+
+	It("Should pass when the remaining nodes can take on the drained workloads", func() {
+		Expect(checkCapacity(kubeClient, "k8s-agentpool1-12345678-0", 50)).To(Succeed())
+	})
+
+	It("Should fail when draining the node leaves less than the minimum free capacity", func() {
+		err := checkCapacity(kubeClient, "k8s-agentpool1-12345678-0", 60)
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Cause(err)).To(Equal(ErrInsufficientCapacity))
+		Expect(err.Error()).To(ContainSubstring("would leave 50.0% of cpu free"))
+	})
+
+	It("Should not count unschedulable or tainted nodes as remaining capacity", func() {
+		cordoned := newTestCapacityNode("k8s-agentpool1-12345678-2", "4", "16Gi")
+		cordoned.Spec.Unschedulable = true
+		tainted := newTestCapacityNode("k8s-agentpool1-12345678-3", "4", "16Gi")
+		tainted.Spec.Taints = []v1.Taint{{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule}}
+		kubeClient.NodesList.Items = append(kubeClient.NodesList.Items, cordoned, tainted)
+
+		err := checkCapacity(kubeClient, "k8s-agentpool1-12345678-0", 60)
+		Expect(errors.Cause(err)).To(Equal(ErrInsufficientCapacity))
+	})
+
+	It("Should ignore DaemonSet and completed pods", func() {
+		daemonSetPod := newTestCapacityPod("k8s-agentpool1-12345678-0", "2", "8Gi")
+		daemonSetPod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "kube-proxy"}}
+		completedPod := newTestCapacityPod("k8s-agentpool1-12345678-1", "2", "8Gi")
+		completedPod.Status.Phase = v1.PodSucceeded
+		kubeClient.PodsList.Items = append(kubeClient.PodsList.Items, daemonSetPod, completedPod)
+
+		Expect(checkCapacity(kubeClient, "k8s-agentpool1-12345678-0", 50)).To(Succeed())
+	})
+
+	It("Should fail when no schedulable node remains", func() {
+		kubeClient.NodesList.Items = kubeClient.NodesList.Items[:1]
+
+		err := checkCapacity(kubeClient, "k8s-agentpool1-12345678-0", 0)
+		Expect(errors.Cause(err)).To(Equal(ErrInsufficientCapacity))
+	})
+
+	It("Should return an error when nodes cannot be listed", func() {
+		kubeClient.FailListNodes = true
+
+		err := checkCapacity(kubeClient, "k8s-agentpool1-12345678-0", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Cause(err)).NotTo(Equal(ErrInsufficientCapacity))
+	})
+
+	It("Should check the capacity of the agent node's cluster", func() {
+		kan := &UpgradeAgentNode{
+			Translator:              &i18n.Translator{},
+			logger:                  log.NewEntry(log.New()),
+			UpgradeContainerService: api.CreateMockContainerService("testcluster", "", 3, 2, false),
+			Client:                  &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient},
+			MinFreeCapacityPercent:  60,
+		}
+
+		err := kan.CapacityPreflightCheck(context.Background(), "K8S-AGENTPOOL1-12345678-0")
+		Expect(errors.Cause(err)).To(Equal(ErrInsufficientCapacity))
+	})
+})
@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/pkg/errors"
+)
+
+// apiServerPort is the port the master load balancer exposes the API server on
+const apiServerPort = "443"
+
+// SANMismatchError is returned when the API server certificate presented by an upgraded master
+// is not issued for the subject alternative names aks-engine expects
+type SANMismatchError struct {
+	// Missing lists the expected SANs the certificate does not include
+	Missing []string
+	// Unexpected lists the certificate SANs aks-engine does not expect
+	Unexpected []string
+}
+
+func (e *SANMismatchError) Error() string {
+	return fmt.Sprintf("API server certificate SANs do not match the cluster configuration, missing: %v, unexpected: %v", e.Missing, e.Unexpected)
+}
+
+// ValidateCertificateSANs connects to the API server at masterURL, a host with an optional port, and returns
+// a SANMismatchError unless the certificate it presents is issued for the SANs derived from the api model.
+func (kmn *UpgradeMasterNode) ValidateCertificateSANs(ctx context.Context, masterURL string) error {
+	expected, err := expectedCertificateSANs(kmn.UpgradeContainerService)
+	if err != nil {
+		return err
+	}
+
+	address := masterURL
+	if _, _, err = net.SplitHostPort(masterURL); err != nil {
+		address = net.JoinHostPort(masterURL, apiServerPort)
+	}
+	dialer := &tls.Dialer{
+		// only the SANs are inspected here, the certificate chain is verified by the Kubernetes client
+		Config: &tls.Config{InsecureSkipVerify: true},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "connecting to the API server at %s", address)
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.Errorf("the API server at %s presented no certificate", address)
+	}
+
+	presented := map[string]bool{}
+	for _, name := range certs[0].DNSNames {
+		presented[strings.ToLower(name)] = true
+	}
+	for _, ip := range certs[0].IPAddresses {
+		presented[ip.String()] = true
+	}
+
+	mismatch := &SANMismatchError{}
+	for san := range expected {
+		if !presented[san] {
+			mismatch.Missing = append(mismatch.Missing, san)
+		}
+	}
+	for san := range presented {
+		if !expected[san] {
+			mismatch.Unexpected = append(mismatch.Unexpected, san)
+		}
+	}
+	if len(mismatch.Missing) == 0 && len(mismatch.Unexpected) == 0 {
+		return nil
+	}
+	sort.Strings(mismatch.Missing)
+	sort.Strings(mismatch.Unexpected)
+	return mismatch
+}
+
+// expectedCertificateSANs returns the DNS names, in lower case, and IP addresses the API server certificate is generated for
+func expectedCertificateSANs(cs *api.ContainerService) (map[string]bool, error) {
+	fqdns, ips, err := cs.GetAPIServerCertificateSANs()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the expected API server certificate SANs")
+	}
+	sans := map[string]bool{}
+	for _, name := range append(fqdns, helpers.KubernetesServiceFQDNs(api.DefaultKubernetesClusterDomain)...) {
+		sans[strings.ToLower(name)] = true
+	}
+	for _, ip := range ips {
+		sans[ip.String()] = true
+	}
+	return sans, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newTestAPIServer returns a TLS server presenting a certificate issued for sans
+func newTestAPIServer(sans []string) *httptest.Server {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "apiserver"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	return server
+}
+
+var _ = Describe("API server certificate SANs tests", func() {
+	// the certificate is valid for the master FQDN of every Azure location
+	expectedSANs := []string{
+		"localhost",
+		"kubernetes",
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		"kubernetes.default.svc.cluster.local",
+		"kubernetes.kube-system",
+		"kubernetes.kube-system.svc",
+		"kubernetes.kube-system.svc.cluster.local",
+		"10.240.255.5",
+		"127.0.0.1",
+		"10.240.255.15",
+		"10.240.255.6",
+		"10.240.255.7",
+		"10.0.0.1",
+	}
+	for _, location := range helpers.GetAzureLocations() {
+		expectedSANs = append(expectedSANs, api.FormatProdFQDNByLocation("testmaster", location, ""))
+	}
+	var kmn *UpgradeMasterNode
+
+	BeforeEach(func() {
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.UpgradeContainerService.Properties.MasterProfile.FirstConsecutiveStaticIP = "10.240.255.5"
+	})
+
+	It("Should accept a certificate issued for the expected SANs", func() {
+		server := newTestAPIServer(expectedSANs)
+		defer server.Close()
+
+		Expect(kmn.ValidateCertificateSANs(context.Background(), strings.TrimPrefix(server.URL, "https://"))).To(Succeed())
+	})
+
+	It("Should list the missing and unexpected SANs", func() {
+		sans := append([]string{"rogue.example.com", "10.240.255.8"}, expectedSANs[1:len(expectedSANs)-1]...)
+		server := newTestAPIServer(sans)
+		defer server.Close()
+
+		err := kmn.ValidateCertificateSANs(context.Background(), strings.TrimPrefix(server.URL, "https://"))
+		Expect(err).To(HaveOccurred())
+		mismatch, ok := err.(*SANMismatchError)
+		Expect(ok).To(BeTrue())
+		Expect(mismatch.Missing).To(Equal([]string{"localhost", "testmaster.westus3.cloudapp.azure.com"}))
+		Expect(mismatch.Unexpected).To(Equal([]string{"10.240.255.8", "rogue.example.com"}))
+		Expect(err.Error()).To(Equal("API server certificate SANs do not match the cluster configuration, " +
+			"missing: [localhost testmaster.westus3.cloudapp.azure.com], unexpected: [10.240.255.8 rogue.example.com]"))
+	})
+
+	It("Should fail when the API server cannot be reached", func() {
+		server := newTestAPIServer(expectedSANs)
+		address := strings.TrimPrefix(server.URL, "https://")
+		server.Close()
+
+		err := kmn.ValidateCertificateSANs(context.Background(), address)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("connecting to the API server at " + address))
+	})
+
+	It("Should fail when the expected SANs cannot be derived from the api model", func() {
+		kmn.UpgradeContainerService.Properties.MasterProfile.FirstConsecutiveStaticIP = "10.240.255"
+
+		err := kmn.ValidateCertificateSANs(context.Background(), "testmaster.eastus.cloudapp.azure.com")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("getting the expected API server certificate SANs"))
+	})
+})
@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultConsecutiveFailureLimit is how many agent nodes in a row may fail to upgrade by default
+const DefaultConsecutiveFailureLimit = 3
+
+// NodeFailure is an agent node that failed to upgrade
+type NodeFailure struct {
+	NodeName string
+	Err      error
+}
+
+// NodeFailuresError lists the agent nodes that failed to upgrade. Consecutive is set to the
+// number of failures in a row that stopped the upgrade, zero if all agent nodes were processed.
+type NodeFailuresError struct {
+	Failures    []NodeFailure
+	Consecutive int
+}
+
+func (e *NodeFailuresError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.NodeName, f.Err))
+	}
+	if e.Consecutive > 0 {
+		return fmt.Sprintf("upgrade stopped after %d nodes failed to upgrade in a row, failed nodes: %s",
+			e.Consecutive, strings.Join(failures, "; "))
+	}
+	return fmt.Sprintf("%d nodes failed to upgrade: %s", len(e.Failures), strings.Join(failures, "; "))
+}
+
+// nodeUpgradeFailed records an agent node that failed to upgrade. It returns a NodeFailuresError
+// stopping the upgrade once ConsecutiveFailureLimit nodes in a row failed, nil to go on with the next node.
+// The error of the node is returned as is if it is the only one that failed.
+func (ku *Upgrader) nodeUpgradeFailed(nodeName string, err error) error {
+	limit := ku.ConsecutiveFailureLimit
+	if limit <= 0 {
+		limit = DefaultConsecutiveFailureLimit
+	}
+	ku.failedNodes = append(ku.failedNodes, NodeFailure{NodeName: nodeName, Err: err})
+	ku.reportEvent(UpgradeEvent{
+		Type:     NodeUpgradeFailedEvent,
+		NodeName: nodeName,
+		Message:  err.Error(),
+	})
+	ku.consecutiveFailures++
+	if ku.consecutiveFailures >= limit {
+		if len(ku.failedNodes) == 1 {
+			return err
+		}
+		return &NodeFailuresError{Failures: ku.failedNodes, Consecutive: ku.consecutiveFailures}
+	}
+	ku.logger.Errorf("Failed to upgrade node %s, %d of %d failures in a row before the upgrade stops: %v",
+		nodeName, ku.consecutiveFailures, limit, err)
+	return nil
+}
+
+// nodeUpgradeSucceeded resets the count of agent nodes that failed to upgrade in a row
+func (ku *Upgrader) nodeUpgradeSucceeded() {
+	ku.consecutiveFailures = 0
+}
+
+// nodeFailuresError returns a NodeFailuresError if agent nodes failed to upgrade,
+// or the error of the node if a single one failed
+func (ku *Upgrader) nodeFailuresError() error {
+	switch len(ku.failedNodes) {
+	case 0:
+		return nil
+	case 1:
+		return ku.failedNodes[0].Err
+	}
+	return &NodeFailuresError{Failures: ku.failedNodes}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Consecutive node failures tests", func() {
+	var u *Upgrader
+
+	BeforeEach(func() {
+		u = newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+	})
+
+	It("Should stop the upgrade after the default limit of failures in a row", func() {
+		Expect(u.nodeUpgradeFailed("node0", errors.New("boom"))).To(Succeed())
+		Expect(u.nodeUpgradeFailed("node1", errors.New("boom"))).To(Succeed())
+
+		err := u.nodeUpgradeFailed("node2", errors.New("boom"))
+		Expect(err).To(MatchError("upgrade stopped after 3 nodes failed to upgrade in a row, failed nodes: node0: boom; node1: boom; node2: boom"))
+		failuresErr, ok := err.(*NodeFailuresError)
+		Expect(ok).To(BeTrue())
+		Expect(failuresErr.Consecutive).To(Equal(DefaultConsecutiveFailureLimit))
+	})
+
+	It("Should reset the count of failures in a row when a node is upgraded", func() {
+		u.ConsecutiveFailureLimit = 2
+		Expect(u.nodeUpgradeFailed("node0", errors.New("boom"))).To(Succeed())
+		u.nodeUpgradeSucceeded()
+		Expect(u.nodeUpgradeFailed("node2", errors.New("boom"))).To(Succeed())
+
+		err := u.nodeUpgradeFailed("node3", errors.New("boom"))
+		Expect(err).To(MatchError("upgrade stopped after 2 nodes failed to upgrade in a row, failed nodes: node0: boom; node2: boom; node3: boom"))
+	})
+
+	It("Should report the failed nodes once all nodes were processed", func() {
+		Expect(u.nodeFailuresError()).To(Succeed())
+
+		Expect(u.nodeUpgradeFailed("node0", errors.New("boom"))).To(Succeed())
+		u.nodeUpgradeSucceeded()
+		Expect(u.nodeFailuresError()).To(MatchError("boom"))
+
+		Expect(u.nodeUpgradeFailed("node2", errors.New("bang"))).To(Succeed())
+		u.nodeUpgradeSucceeded()
+		Expect(u.nodeFailuresError()).To(MatchError("2 nodes failed to upgrade: node0: boom; node2: bang"))
+	})
+
+	It("Should return the error of the node when the upgrade stops at the first failure", func() {
+		u.ConsecutiveFailureLimit = 1
+		Expect(u.nodeUpgradeFailed("node0", errors.New("boom"))).To(MatchError("boom"))
+	})
+
+	It("Should stop upgrading a VMSS after the limit of failures in a row", func() {
+		u.SkipCapacityCheck = true
+		u.DataModel = api.CreateMockContainerService("testcluster", "1.18.8", 1, 1, false)
+		u.ClusterTopology.ResourceGroup = "TestRg"
+		u.ClusterTopology.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{newTestScaleSet("k8s-agentpool1-12345678-vmss", false, 5)}
+		u.ClusterTopology.AgentPools = map[string]*AgentPoolTopology{"agentpool1": {Name: to.StringPtr("agentpool1")}}
+		mockClient := u.Client.(*armhelpers.MockAKSEngineClient)
+		mockClient.FailSetVirtualMachineScaleSetCapacity = true
+		mockClient.FakeListVirtualMachineScaleSetVMsResult = func() []compute.VirtualMachineScaleSetVM {
+			return []compute.VirtualMachineScaleSetVM{}
+		}
+
+		err := u.upgradeAgentScaleSets(context.Background())
+		Expect(err).To(HaveOccurred())
+		failuresErr, ok := err.(*NodeFailuresError)
+		Expect(ok).To(BeTrue())
+		Expect(failuresErr.Failures).To(HaveLen(3))
+		Expect(failuresErr.Failures[2].NodeName).To(Equal("k8s-agentpool1-12345678-vmss000002"))
+		Expect(failuresErr.Failures[2].Err).To(MatchError(ContainSubstring("SetVirtualMachineScaleSetCapacity failed")))
+	})
+
+	Context("Availability set agent pools", func() {
+		var (
+			mockClient *armhelpers.MockAKSEngineClient
+			vmNames    []string
+		)
+
+		// poolVMs returns the indexes of the agentpool1 VMs once upgraded, counting the VMs deleted and deployed through the mock
+		poolVMs := func() []int {
+			vms := map[int]int{0: 1, 1: 1, 2: 1}
+			for _, name := range mockClient.DeletedVirtualMachines {
+				index, err := utils.GetVMNameIndex(compute.Linux, name)
+				Expect(err).NotTo(HaveOccurred())
+				vms[index]--
+			}
+			deployment := regexp.MustCompile(`^k8s-upgrade-agentpool1-(\d+)-`)
+			for _, name := range mockClient.DeploymentNames {
+				index, err := strconv.Atoi(deployment.FindStringSubmatch(name)[1])
+				Expect(err).NotTo(HaveOccurred())
+				vms[index]++
+			}
+			var indexes []int
+			for index, count := range vms {
+				Expect(count).To(BeNumerically("<=", 1))
+				if count == 1 {
+					indexes = append(indexes, index)
+				}
+			}
+			sort.Ints(indexes)
+			return indexes
+		}
+
+		failDeletion := func(vmName string) {
+			mockClient.DeleteVirtualMachineFunc = func(name string) error {
+				if name == vmName {
+					return errors.New("DeleteVirtualMachine failed")
+				}
+				return nil
+			}
+		}
+
+		BeforeEach(func() {
+			u.SkipCapacityCheck = true
+			u.DataModel = api.CreateMockContainerService("testcluster", "1.18.8", 1, 3, false)
+			u.ClusterTopology.ResourceGroup = "TestRg"
+			mockClient = u.Client.(*armhelpers.MockAKSEngineClient)
+			vmNames = nil
+			var agentVMs []compute.VirtualMachine
+			for i := 0; i < 4; i++ {
+				name, err := utils.GetK8sVMName(u.DataModel.Properties, u.DataModel.Properties.AgentPoolProfiles[0], i)
+				Expect(err).NotTo(HaveOccurred())
+				vmNames = append(vmNames, name)
+				if i < 3 {
+					vm := mockClient.MakeFakeVirtualMachine(name, "Kubernetes:1.17.17")
+					vm.StorageProfile.OsDisk.OsType = compute.Linux
+					agentVMs = append(agentVMs, vm)
+				}
+			}
+			u.ClusterTopology.AgentPools = map[string]*AgentPoolTopology{
+				"agentpool1": {
+					Identifier:       to.StringPtr("agentpool1"),
+					Name:             to.StringPtr("agentpool1"),
+					AgentVMs:         &agentVMs,
+					UpgradedAgentVMs: &[]compute.VirtualMachine{},
+				},
+			}
+		})
+
+		It("Should leave the pool at its size when a node fails to upgrade before its VM is deleted", func() {
+			failDeletion(vmNames[1])
+
+			Expect(u.upgradeAgentPools(context.Background())).To(Succeed())
+			Expect(u.failedNodes).To(HaveLen(1))
+			Expect(u.failedNodes[0].NodeName).To(Equal(vmNames[1]))
+			Expect(mockClient.DeletedVirtualMachines).To(Equal([]string{vmNames[0], vmNames[2]}))
+			Expect(poolVMs()).To(Equal([]int{0, 1, 3}))
+		})
+
+		It("Should delete the extra node when the last node fails to upgrade before its VM is deleted", func() {
+			failDeletion(vmNames[2])
+
+			Expect(u.upgradeAgentPools(context.Background())).To(Succeed())
+			Expect(u.failedNodes).To(HaveLen(1))
+			Expect(mockClient.DeletedVirtualMachines).To(Equal([]string{vmNames[0], vmNames[1], vmNames[3]}))
+			Expect(poolVMs()).To(Equal([]int{0, 1, 2}))
+		})
+
+		It("Should create again the VM deleted by a failed node upgrade the extra node could not replace", func() {
+			deployments := func(vmName string) int {
+				index, err := utils.GetVMNameIndex(compute.Linux, vmName)
+				Expect(err).NotTo(HaveOccurred())
+				count := 0
+				for _, name := range mockClient.DeploymentNames {
+					if strings.HasPrefix(name, fmt.Sprintf("k8s-upgrade-agentpool1-%d-", index)) {
+						count++
+					}
+				}
+				return count
+			}
+			mockClient.MockKubernetesClient = &armhelpers.MockKubernetesClient{
+				GetNodeFunc: func(name string) (*v1.Node, error) {
+					// the nodes 0 and 1 are not ready once first created again, their validation deletes their VM
+					if (name == vmNames[0] || name == vmNames[1]) && deployments(name) == 1 {
+						return nil, errors.New("GetNode failed")
+					}
+					return &v1.Node{
+						ObjectMeta: metav1.ObjectMeta{Name: name},
+						Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}},
+					}, nil
+				},
+			}
+			stepTimeout := 100 * time.Millisecond
+			u.stepTimeout = &stepTimeout
+
+			Expect(u.upgradeAgentPools(context.Background())).To(Succeed())
+			Expect(u.failedNodes).To(HaveLen(2))
+			Expect(poolVMs()).To(Equal([]int{0, 2, 3}))
+		})
+	})
+})
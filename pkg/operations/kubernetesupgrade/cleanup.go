@@ -0,0 +1,84 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+const (
+	// upgradeDeploymentPrefix starts the name of the ARM deployments created by the upgrade
+	upgradeDeploymentPrefix = "k8s-upgrade-"
+	// UpgradeOutputDirectoryName is the sub-directory of the cluster output directory Cleanup removes
+	UpgradeOutputDirectoryName = "upgrade"
+)
+
+// Cleanup removes the artifacts left by the upgrade: the ARM deployments named k8s-upgrade-*, except
+// ExistingDeploymentName and the deployments still running, the upgrade sub-directory of OutputDirectory
+// if set, and the orphaned NICs and managed disks of the cluster. The deployed resources are kept.
+func (kmn *UpgradeMasterNode) Cleanup(ctx context.Context) error {
+	var deployments []string
+	page, err := kmn.Client.ListDeployments(ctx, kmn.ResourceGroup)
+	if err != nil {
+		return errors.Wrap(err, "listing deployments")
+	}
+	for ; page.NotDone(); err = page.Next() {
+		if err != nil {
+			return errors.Wrap(err, "listing deployments")
+		}
+		for _, d := range page.Values() {
+			name := to.String(d.Name)
+			if !strings.HasPrefix(name, upgradeDeploymentPrefix) || name == kmn.ExistingDeploymentName {
+				continue
+			}
+			if d.Properties != nil && isDeploymentRunning(to.String(d.Properties.ProvisioningState)) {
+				kmn.logger.Infof("Keeping deployment %s in provisioning state %s", name, *d.Properties.ProvisioningState)
+				continue
+			}
+			deployments = append(deployments, name)
+		}
+	}
+	for _, name := range deployments {
+		kmn.logger.Infof("Deleting deployment %s", name)
+		if err := kmn.Client.DeleteDeployment(ctx, kmn.ResourceGroup, name); err != nil {
+			return errors.Wrapf(err, "deleting deployment %s", name)
+		}
+	}
+
+	if kmn.OutputDirectory != "" {
+		dir := filepath.Join(kmn.OutputDirectory, UpgradeOutputDirectoryName)
+		kmn.logger.Infof("Removing upgrade output directory %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return errors.Wrapf(err, "removing upgrade output directory %s", dir)
+		}
+	}
+
+	orphaned, err := kmn.FindOrphanedResources(ctx)
+	if err != nil {
+		return err
+	}
+	// orphaned storage accounts may still hold data, only NICs and disks are removed
+	var resources []OrphanedResource
+	for _, resource := range orphaned {
+		if resource.Type == nicResourceType || resource.Type == diskResourceType {
+			resources = append(resources, resource)
+		}
+	}
+	return kmn.CleanupOrphaned(ctx, resources)
+}
+
+// isDeploymentRunning returns true unless the deployment provisioning state is terminal
+func isDeploymentRunning(provisioningState string) bool {
+	switch strings.ToLower(provisioningState) {
+	case "succeeded", "failed", "canceled", "":
+		return false
+	}
+	return true
+}
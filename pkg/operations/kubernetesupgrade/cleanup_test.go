@@ -0,0 +1,105 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade cleanup tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kmn        *UpgradeMasterNode
+	)
+
+	deployment := func(name, provisioningState string) resources.DeploymentExtended {
+		return resources.DeploymentExtended{
+			Name:       to.StringPtr(name),
+			Properties: &resources.DeploymentPropertiesExtended{ProvisioningState: to.StringPtr(provisioningState)},
+		}
+	}
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		mockClient.FakeDeployments = []resources.DeploymentExtended{
+			deployment("k8s-upgrade-master-0", "Succeeded"),
+			deployment("k8s-upgrade-master-1", "Failed"),
+			deployment("k8s-upgrade-master-2", "Running"),
+			deployment("k8s-upgrade-existing", "Succeeded"),
+			deployment("azuredeploy", "Succeeded"),
+		}
+		kmn.ExistingDeploymentName = "k8s-upgrade-existing"
+	})
+
+	It("Should delete the finished upgrade deployments only", func() {
+		Expect(kmn.Cleanup(context.Background())).To(Succeed())
+
+		Expect(mockClient.DeletedDeployments).To(ConsistOf("k8s-upgrade-master-0", "k8s-upgrade-master-1"))
+		Expect(mockClient.FakeDeployments).To(HaveLen(3))
+	})
+
+	It("Should remove the upgrade output directory", func() {
+		dir, err := ioutil.TempDir("", "cleanup")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		upgradeDir := filepath.Join(dir, UpgradeOutputDirectoryName)
+		Expect(os.MkdirAll(upgradeDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(upgradeDir, "azuredeploy.json"), []byte("{}"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "apimodel.json"), []byte("{}"), 0644)).To(Succeed())
+		kmn.OutputDirectory = dir
+
+		Expect(kmn.Cleanup(context.Background())).To(Succeed())
+
+		_, err = os.Stat(upgradeDir)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(filepath.Join(dir, "apimodel.json"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should delete the orphaned NICs", func() {
+		clusterID := kmn.UpgradeContainerService.Properties.GetClusterID()
+		mockClient.FakeListNetworkInterfacesResult = func() []network.Interface {
+			return []network.Interface{
+				{
+					ID:                        to.StringPtr("nic-1"),
+					Name:                      to.StringPtr("k8s-master-" + clusterID + "-nic-1"),
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{},
+				},
+			}
+		}
+
+		mockClient.FailDeleteNetworkInterface = true
+
+		err := kmn.Cleanup(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("failed to delete 1 of 1 orphaned resources"))
+	})
+
+	It("Should fail when the deployments cannot be listed", func() {
+		mockClient.FailListDeployments = true
+
+		err := kmn.Cleanup(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("listing deployments"))
+	})
+
+	It("Should fail when a deployment cannot be deleted", func() {
+		mockClient.FailDeleteDeployment = true
+
+		err := kmn.Cleanup(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("deleting deployment k8s-upgrade-master-0"))
+	})
+})
@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/pkg/errors"
+)
+
+const (
+	// cloudProviderConfigParameter is the template parameter holding the base64-encoded regenerated cloud provider config
+	cloudProviderConfigParameter = "cloudProviderConfig"
+	// masterProvisionParametersVariable is the template variable of the provisioning script parameters of the masters
+	masterProvisionParametersVariable = "provisionScriptParametersMaster"
+)
+
+// injectCloudProviderConfig regenerates the cloud provider config of the cluster from the api model, resource group
+// and subscription of the upgrade, and passes it base64-encoded as the CLOUD_PROVIDER_CONFIG parameter of the
+// provisioning script of the upgraded masters through the cloudProviderConfig template parameter
+func (ku *Upgrader) injectCloudProviderConfig(templateMap, parametersMap map[string]interface{}) error {
+	config, err := engine.GenerateCloudProviderConfig(ku.ClusterTopology.DataModel, ku.ClusterTopology.SubscriptionID, ku.ClusterTopology.ResourceGroup)
+	if err != nil {
+		return err
+	}
+	variables, _ := templateMap["variables"].(map[string]interface{})
+	provisionParameters, _ := variables[masterProvisionParametersVariable].(string)
+	if !strings.HasPrefix(provisionParameters, "[concat(") || !strings.HasSuffix(provisionParameters, ")]") {
+		return errors.Errorf("template variable %s is not a concat expression", masterProvisionParametersVariable)
+	}
+	variables[masterProvisionParametersVariable] = strings.TrimSuffix(provisionParameters, ")]") +
+		",' CLOUD_PROVIDER_CONFIG=',parameters('" + cloudProviderConfigParameter + "'))]"
+
+	templateParameters, ok := templateMap["parameters"].(map[string]interface{})
+	if !ok {
+		templateParameters = map[string]interface{}{}
+		templateMap["parameters"] = templateParameters
+	}
+	templateParameters[cloudProviderConfigParameter] = map[string]interface{}{
+		"type": "securestring",
+		"metadata": map[string]interface{}{
+			"description": "The base64-encoded cloud provider config of the upgraded master nodes",
+		},
+	}
+	parametersMap[cloudProviderConfigParameter] = map[string]interface{}{
+		"value": base64.StdEncoding.EncodeToString(config),
+	}
+	ku.logger.Infof("Regenerated the cloud provider config of resource group %s", ku.ClusterTopology.ResourceGroup)
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/engine"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cloud provider config tests", func() {
+	It("Should pass the regenerated cloud provider config to the provisioning script of the masters", func() {
+		u := newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+		u.ClusterTopology.SubscriptionID = "cc6b141e-6afc-4786-9bf6-e3b9a5601460"
+		u.ClusterTopology.ResourceGroup = "MovedRG"
+		u.DataModel.Properties.MasterProfile.VnetSubnetID = "/subscriptions/cc6b141e-6afc-4786-9bf6-e3b9a5601460/resourceGroups/NetworkRG/providers/Microsoft.Network/virtualNetworks/NewVNet/subnets/MasterSubnet"
+		u.DataModel.Properties.MasterProfile.FirstConsecutiveStaticIP = "10.239.255.239"
+		templateMap, parametersMap, err := u.generateUpgradeTemplate(context.Background(), u.DataModel, TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(u.injectCloudProviderConfig(templateMap, parametersMap)).To(Succeed())
+
+		Expect(templateMap["parameters"]).To(HaveKeyWithValue(cloudProviderConfigParameter, HaveKeyWithValue("type", "securestring")))
+		provisionParameters := templateMap["variables"].(map[string]interface{})[masterProvisionParametersVariable]
+		Expect(provisionParameters).To(HaveSuffix(",' CLOUD_PROVIDER_CONFIG=',parameters('cloudProviderConfig'))]"))
+		encoded := parametersMap[cloudProviderConfigParameter].(map[string]interface{})["value"].(string)
+		b, err := base64.StdEncoding.DecodeString(encoded)
+		Expect(err).NotTo(HaveOccurred())
+		var config engine.CloudProviderConfig
+		Expect(json.Unmarshal(b, &config)).To(Succeed())
+		Expect(config.SubscriptionID).To(Equal("cc6b141e-6afc-4786-9bf6-e3b9a5601460"))
+		Expect(config.ResourceGroup).To(Equal("MovedRG"))
+		Expect(config.VnetName).To(Equal("NewVNet"))
+		Expect(config.VnetResourceGroup).To(Equal("NetworkRG"))
+		Expect(config.SubnetName).To(Equal("MasterSubnet"))
+	})
+
+	It("Should fail if the template has no master provisioning script parameters", func() {
+		u := newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+		templateMap := map[string]interface{}{"variables": map[string]interface{}{}}
+		err := u.injectCloudProviderConfig(templateMap, map[string]interface{}{})
+		Expect(err).To(MatchError("template variable provisionScriptParametersMaster is not a concat expression"))
+	})
+})
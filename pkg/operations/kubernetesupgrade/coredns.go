@@ -0,0 +1,124 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultCoreDNSCheckImage is the image of the pod looking up the kubernetes service when CoreDNSCheckImage is empty
+	DefaultCoreDNSCheckImage = "busybox:1.31.1"
+	// coreDNSCheckTimeout is how long VerifyCoreDNSResolution waits for the lookup pod to complete
+	coreDNSCheckTimeout = 5 * time.Minute
+)
+
+// coreDNSCheckInterval is how often VerifyCoreDNSResolution polls the lookup pod
+var coreDNSCheckInterval = 5 * time.Second
+
+// VerifyCoreDNSResolution runs a temporary pod looking up kubernetes.default.svc.cluster.local through the cluster DNS,
+// and returns an error unless the name resolves to the cluster IP of the kubernetes service.
+func (ku *Upgrader) VerifyCoreDNSResolution(ctx context.Context) error {
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting Kubernetes client")
+	}
+	service, err := client.GetService(metav1.NamespaceDefault, "kubernetes")
+	if err != nil {
+		return errors.Wrap(err, "getting the kubernetes service")
+	}
+	serviceName := "kubernetes.default.svc." + api.DefaultKubernetesClusterDomain
+
+	image := ku.CoreDNSCheckImage
+	if image == "" {
+		image = DefaultCoreDNSCheckImage
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceSystem,
+			Name:      fmt.Sprintf("aks-engine-dns-check-%d", time.Now().Unix()),
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			NodeSelector:  map[string]string{v1.LabelOSStable: "linux"},
+			Containers: []v1.Container{{
+				Name:    "nslookup",
+				Image:   image,
+				Command: []string{"nslookup", serviceName},
+			}},
+		},
+	}
+	if _, err = client.CreatePod(pod); err != nil {
+		return errors.Wrapf(err, "creating pod %s/%s", pod.Namespace, pod.Name)
+	}
+	defer func() {
+		if err := client.DeletePod(pod, nil); err != nil {
+			ku.logger.Warningf("Failed to delete pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, coreDNSCheckTimeout)
+	defer cancel()
+	for {
+		p, err := client.GetPod(pod.Namespace, pod.Name)
+		if err != nil {
+			ku.logger.Infof("DNS check pod %s/%s status error: %v", pod.Namespace, pod.Name, err)
+		} else if p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("DNS check pod %s/%s did not complete within %v", pod.Namespace, pod.Name, coreDNSCheckTimeout)
+		case <-time.After(coreDNSCheckInterval):
+		}
+	}
+
+	logs, err := client.GetPodLogs(pod.Namespace, pod.Name)
+	if err != nil {
+		return errors.Wrapf(err, "getting the logs of pod %s/%s", pod.Namespace, pod.Name)
+	}
+	addresses := nslookupAddresses(logs, serviceName)
+	for _, address := range addresses {
+		if address == service.Spec.ClusterIP {
+			ku.logger.Infof("CoreDNS resolved %s to the kubernetes service cluster IP %s", serviceName, address)
+			return nil
+		}
+	}
+	if len(addresses) == 0 {
+		return errors.Errorf("CoreDNS could not resolve %s: %s", serviceName, strings.TrimSpace(logs))
+	}
+	return errors.Errorf("CoreDNS resolved %s to %v, expected the kubernetes service cluster IP %s", serviceName, addresses, service.Spec.ClusterIP)
+}
+
+// nslookupAddresses returns the addresses nslookup resolved name to. It parses both the output of recent
+// busybox versions, an "Address: <ip>" line after "Name: <name>", and of older ones, "Address 1: <ip> <name>".
+func nslookupAddresses(output, name string) []string {
+	var addresses []string
+	answer := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch {
+		case fields[0] == "Name:":
+			answer = strings.TrimSuffix(fields[1], ".") == name
+		case answer && fields[0] == "Address:":
+			addresses = append(addresses, fields[1])
+		case answer && fields[0] == "Address" && len(fields) > 2:
+			addresses = append(addresses, fields[2])
+		}
+	}
+	return addresses
+}
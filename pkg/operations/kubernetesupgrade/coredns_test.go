@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+const testNslookupOutput = `Server:		10.0.0.10
+Address:	10.0.0.10:53
+
+Name:	kubernetes.default.svc.cluster.local
+Address: 10.0.0.1
+`
+
+var _ = Describe("CoreDNS resolution tests", func() {
+	var (
+		u          *Upgrader
+		kubeClient *armhelpers.MockKubernetesClient
+		interval   time.Duration
+	)
+
+	BeforeEach(func() {
+		interval = coreDNSCheckInterval
+		coreDNSCheckInterval = time.Millisecond
+		kubeClient = &armhelpers.MockKubernetesClient{PodLogs: testNslookupOutput}
+		u = newTestCRDUpgrader("1.18.8", kubeClient)
+	})
+
+	AfterEach(func() {
+		coreDNSCheckInterval = interval
+	})
+
+	It("Should succeed when the kubernetes service name resolves to its cluster IP", func() {
+		Expect(u.VerifyCoreDNSResolution(context.Background())).To(Succeed())
+
+		Expect(kubeClient.CreatedPods).To(HaveLen(1))
+		pod := kubeClient.CreatedPods[0]
+		Expect(pod.Namespace).To(Equal("kube-system"))
+		Expect(pod.Spec.RestartPolicy).To(Equal(v1.RestartPolicyNever))
+		Expect(pod.Spec.Containers[0].Image).To(Equal(DefaultCoreDNSCheckImage))
+		Expect(pod.Spec.Containers[0].Command).To(Equal([]string{"nslookup", "kubernetes.default.svc.cluster.local"}))
+		Expect(kubeClient.DeletedPods).To(HaveLen(1))
+		Expect(kubeClient.DeletedPods[0].Name).To(Equal(pod.Name))
+	})
+
+	It("Should fail when the name resolves to another IP", func() {
+		kubeClient.PodLogs = "Server:    10.0.0.10\nAddress 1: 10.0.0.10\n\nName:      kubernetes.default.svc.cluster.local\nAddress 1: 10.0.0.99 kubernetes.default.svc.cluster.local\n"
+
+		err := u.VerifyCoreDNSResolution(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("CoreDNS resolved kubernetes.default.svc.cluster.local to [10.0.0.99], expected the kubernetes service cluster IP 10.0.0.1"))
+		Expect(kubeClient.DeletedPods).To(HaveLen(1))
+	})
+
+	It("Should fail when the lookup pod fails", func() {
+		kubeClient.PodPhase = v1.PodFailed
+		kubeClient.PodLogs = "Server:    10.0.0.10\nAddress:  10.0.0.10:53\n\n** server can't find kubernetes.default.svc.cluster.local: NXDOMAIN\n"
+
+		err := u.VerifyCoreDNSResolution(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("CoreDNS could not resolve kubernetes.default.svc.cluster.local"))
+		Expect(kubeClient.DeletedPods).To(HaveLen(1))
+	})
+
+	It("Should use CoreDNSCheckImage and stop when the context is done", func() {
+		u.CoreDNSCheckImage = "mcr.microsoft.com/busybox:latest"
+		kubeClient.PodPhase = v1.PodRunning
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := u.VerifyCoreDNSResolution(ctx)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("did not complete"))
+		Expect(kubeClient.CreatedPods[0].Spec.Containers[0].Image).To(Equal("mcr.microsoft.com/busybox:latest"))
+		Expect(kubeClient.DeletedPods).To(HaveLen(1))
+	})
+
+	It("Should fail when the kubernetes service cannot be read", func() {
+		kubeClient.FailGetService = true
+
+		Expect(u.VerifyCoreDNSResolution(context.Background())).NotTo(Succeed())
+		Expect(kubeClient.CreatedPods).To(BeEmpty())
+	})
+})
@@ -0,0 +1,128 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// crdMigration describes how to move a CustomResourceDefinition off a version
+// that is no longer usable from a given Kubernetes version onwards
+type crdMigration struct {
+	// MinKubernetesVersion is the first Kubernetes version that requires the migration
+	MinKubernetesVersion string
+	// TargetVersion is the version promoted to storage version
+	TargetVersion string
+}
+
+// crdMigrations is the bundled CRD migration map, keyed by CRD group/version
+var crdMigrations = map[string]crdMigration{
+	// the v1beta1 snapshot controller shipped with Kubernetes 1.17 no longer serves v1alpha1
+	"snapshot.storage.k8s.io/v1alpha1": {MinKubernetesVersion: "1.17.0", TargetVersion: "v1beta1"},
+}
+
+// UpgradeCRDs migrates the CustomResourceDefinitions in the cluster whose stored versions
+// require a schema migration for the target Kubernetes version.
+// It is meant to run once all master nodes are upgraded.
+func (ku *Upgrader) UpgradeCRDs(ctx context.Context) error {
+	targetVersion := ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion
+
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting Kubernetes client")
+	}
+	crds, err := client.ListCustomResourceDefinitions()
+	if err != nil {
+		return errors.Wrap(err, "listing CustomResourceDefinitions")
+	}
+
+	for i := range crds.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		crd := &crds.Items[i]
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		storedVersions, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+		for _, storedVersion := range storedVersions {
+			migration, ok := crdMigrations[group+"/"+storedVersion]
+			if !ok || !common.IsKubernetesVersionGe(targetVersion, migration.MinKubernetesVersion) {
+				continue
+			}
+			changed, err := migrateCRD(crd, storedVersion, migration.TargetVersion)
+			if err != nil {
+				return errors.Wrapf(err, "migrating CustomResourceDefinition %s", crd.GetName())
+			}
+			if !changed {
+				continue
+			}
+			if _, err := client.UpdateCustomResourceDefinition(crd); err != nil {
+				return errors.Wrapf(err, "updating CustomResourceDefinition %s", crd.GetName())
+			}
+			ku.logger.Infof("Migrated CustomResourceDefinition %s from %s to %s", crd.GetName(), storedVersion, migration.TargetVersion)
+			break
+		}
+	}
+	return nil
+}
+
+// migrateCRD makes toVersion the served storage version of the CRD, copying the
+// schema of fromVersion when the CRD does not declare toVersion yet.
+// fromVersion stays served so objects persisted with it remain readable.
+// It returns false when toVersion is already the storage version.
+func migrateCRD(crd *unstructured.Unstructured, fromVersion, toVersion string) (bool, error) {
+	versions, found, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		versions = []interface{}{
+			map[string]interface{}{"name": fromVersion, "served": true, "storage": true},
+		}
+	}
+
+	var from, to map[string]interface{}
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			return false, errors.Errorf("unexpected spec.versions entry %v", v)
+		}
+		switch version["name"] {
+		case fromVersion:
+			from = version
+		case toVersion:
+			to = version
+		}
+	}
+	if to != nil && to["storage"] == true {
+		return false, nil
+	}
+	if to == nil {
+		if from == nil {
+			return false, errors.Errorf("version %s not found in spec.versions", fromVersion)
+		}
+		to = runtime.DeepCopyJSONValue(from).(map[string]interface{})
+		to["name"] = toVersion
+		versions = append(versions, to)
+	}
+	for _, v := range versions {
+		v.(map[string]interface{})["storage"] = false
+	}
+	to["served"] = true
+	to["storage"] = true
+
+	if err := unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"); err != nil {
+		return false, err
+	}
+	if _, found, _ := unstructured.NestedString(crd.Object, "spec", "version"); found {
+		if err := unstructured.SetNestedField(crd.Object, toVersion, "spec", "version"); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
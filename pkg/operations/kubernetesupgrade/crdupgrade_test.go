@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestCRD(name, group string, storedVersions ...string) unstructured.Unstructured {
+	stored := make([]interface{}, len(storedVersions))
+	versions := make([]interface{}, len(storedVersions))
+	for i, v := range storedVersions {
+		stored[i] = v
+		versions[i] = map[string]interface{}{
+			"name":    v,
+			"served":  true,
+			"storage": i == len(storedVersions)-1,
+			"schema": map[string]interface{}{
+				"openAPIV3Schema": map[string]interface{}{"type": "object"},
+			},
+		}
+	}
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"group":    group,
+			"versions": versions,
+		},
+		"status": map[string]interface{}{
+			"storedVersions": stored,
+		},
+	}}
+}
+
+func newTestCRDUpgrader(k8sVersion string, kubeClient *armhelpers.MockKubernetesClient) *Upgrader {
+	cs := api.CreateMockContainerService("testcluster", k8sVersion, 1, 1, false)
+	u := &Upgrader{}
+	u.Init(&i18n.Translator{}, log.NewEntry(log.New()), ClusterTopology{DataModel: cs}, &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}, "", nil, nil, TestAKSEngineVersion, false)
+	return u
+}
+
+var _ = Describe("CRD upgrade tests", func() {
+	var (
+		kubeClient *armhelpers.MockKubernetesClient
+		updated    []*unstructured.Unstructured
+	)
+
+	BeforeEach(func() {
+		updated = nil
+		kubeClient = &armhelpers.MockKubernetesClient{
+			CustomResourceDefinitions: &unstructured.UnstructuredList{
+				Items: []unstructured.Unstructured{
+					newTestCRD("volumesnapshots.snapshot.storage.k8s.io", "snapshot.storage.k8s.io", "v1alpha1"),
+					newTestCRD("widgets.example.com", "example.com", "v1"),
+				},
+			},
+			UpdateCustomResourceDefinitionFunc: func(crd *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				updated = append(updated, crd)
+				return crd, nil
+			},
+		}
+	})
+
+	It("Should migrate CRDs whose stored version requires it for the target version", func() {
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		Expect(u.UpgradeCRDs(context.Background())).To(Succeed())
+
+		Expect(updated).To(HaveLen(1))
+		Expect(updated[0].GetName()).To(Equal("volumesnapshots.snapshot.storage.k8s.io"))
+		versions, _, _ := unstructured.NestedSlice(updated[0].Object, "spec", "versions")
+		Expect(versions).To(HaveLen(2))
+		Expect(versions[0]).To(HaveKeyWithValue("name", "v1alpha1"))
+		Expect(versions[0]).To(HaveKeyWithValue("served", true))
+		Expect(versions[0]).To(HaveKeyWithValue("storage", false))
+		Expect(versions[1]).To(HaveKeyWithValue("name", "v1beta1"))
+		Expect(versions[1]).To(HaveKeyWithValue("served", true))
+		Expect(versions[1]).To(HaveKeyWithValue("storage", true))
+		Expect(versions[1]).To(HaveKey("schema"))
+	})
+
+	It("Should not migrate CRDs when the target version does not require it", func() {
+		u := newTestCRDUpgrader("1.16.15", kubeClient)
+		Expect(u.UpgradeCRDs(context.Background())).To(Succeed())
+		Expect(updated).To(BeEmpty())
+	})
+
+	It("Should not update CRDs that were already migrated", func() {
+		kubeClient.CustomResourceDefinitions.Items = []unstructured.Unstructured{
+			newTestCRD("volumesnapshots.snapshot.storage.k8s.io", "snapshot.storage.k8s.io", "v1alpha1", "v1beta1"),
+		}
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		Expect(u.UpgradeCRDs(context.Background())).To(Succeed())
+		Expect(updated).To(BeEmpty())
+	})
+
+	It("Should return an error when CRDs cannot be listed", func() {
+		kubeClient.FailListCustomResourceDefinitions = true
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		Expect(u.UpgradeCRDs(context.Background())).NotTo(Succeed())
+	})
+
+	It("Should return an error when a CRD cannot be updated", func() {
+		kubeClient.UpdateCustomResourceDefinitionFunc = nil
+		kubeClient.FailUpdateCustomResourceDefinition = true
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		err := u.UpgradeCRDs(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("volumesnapshots.snapshot.storage.k8s.io"))
+	})
+
+	It("Should set spec.version on CRDs without spec.versions", func() {
+		crd := unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"group":   "snapshot.storage.k8s.io",
+				"version": "v1alpha1",
+			},
+		}}
+		changed, err := migrateCRD(&crd, "v1alpha1", "v1beta1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		version, _, _ := unstructured.NestedString(crd.Object, "spec", "version")
+		Expect(version).To(Equal("v1beta1"))
+		versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		Expect(versions).To(HaveLen(2))
+	})
+})
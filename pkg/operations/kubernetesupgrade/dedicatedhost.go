@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// selectDedicatedHost validates that the dedicated host group exists in the cluster region and returns
+// the ID of its host able to allocate the most master VMs. It fails if no host has room for one more VM.
+func (kmn *UpgradeMasterNode) selectDedicatedHost(ctx context.Context) (string, error) {
+	name, err := utils.ResourceName(kmn.DedicatedHostGroupID)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing dedicated host group ID %s", kmn.DedicatedHostGroupID)
+	}
+	resourceGroup, err := utils.ResourceGroupName(kmn.DedicatedHostGroupID)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing dedicated host group ID %s", kmn.DedicatedHostGroupID)
+	}
+	hostGroup, err := kmn.Client.GetDedicatedHostGroup(ctx, resourceGroup, name)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting dedicated host group %s", kmn.DedicatedHostGroupID)
+	}
+	location := helpers.NormalizeAzureRegion(kmn.UpgradeContainerService.Location)
+	hostGroupLocation := helpers.NormalizeAzureRegion(to.String(hostGroup.Location))
+	if hostGroupLocation != location {
+		return "", errors.Errorf("dedicated host group %s is in region %s, expected %s", name, hostGroupLocation, location)
+	}
+
+	vmSize := kmn.UpgradeContainerService.Properties.MasterProfile.VMSize
+	var selected string
+	var maxCount float64
+	if hostGroup.DedicatedHostGroupProperties != nil && hostGroup.Hosts != nil {
+		for _, host := range *hostGroup.Hosts {
+			hostName, err := utils.ResourceName(to.String(host.ID))
+			if err != nil {
+				return "", errors.Wrapf(err, "parsing dedicated host ID %s", to.String(host.ID))
+			}
+			dedicatedHost, err := kmn.Client.GetDedicatedHost(ctx, resourceGroup, name, hostName)
+			if err != nil {
+				return "", errors.Wrapf(err, "getting dedicated host %s", to.String(host.ID))
+			}
+			if dedicatedHost.DedicatedHostProperties == nil || dedicatedHost.InstanceView == nil ||
+				dedicatedHost.InstanceView.AvailableCapacity == nil || dedicatedHost.InstanceView.AvailableCapacity.AllocatableVMs == nil {
+				continue
+			}
+			for _, allocatable := range *dedicatedHost.InstanceView.AvailableCapacity.AllocatableVMs {
+				if strings.EqualFold(to.String(allocatable.VMSize), vmSize) && to.Float64(allocatable.Count) > maxCount {
+					selected = to.String(host.ID)
+					maxCount = to.Float64(allocatable.Count)
+				}
+			}
+		}
+	}
+	if maxCount < 1 {
+		return "", errors.Errorf("dedicated host group %s has no capacity left for a %s VM", name, vmSize)
+	}
+	return selected, nil
+}
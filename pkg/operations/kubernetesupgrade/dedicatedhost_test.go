@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testDedicatedHostGroupID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/hostrg/providers/Microsoft.Compute/hostGroups/masters"
+
+func newTestDedicatedHost(name string, allocatable map[string]float64) compute.DedicatedHost {
+	vms := []compute.DedicatedHostAllocatableVM{}
+	for size, count := range allocatable {
+		vms = append(vms, compute.DedicatedHostAllocatableVM{VMSize: to.StringPtr(size), Count: to.Float64Ptr(count)})
+	}
+	return compute.DedicatedHost{
+		Name: to.StringPtr(name),
+		DedicatedHostProperties: &compute.DedicatedHostProperties{
+			InstanceView: &compute.DedicatedHostInstanceView{
+				AvailableCapacity: &compute.DedicatedHostAvailableCapacity{AllocatableVMs: &vms},
+			},
+		},
+	}
+}
+
+var _ = Describe("Dedicated host tests", func() {
+	var mockClient *armhelpers.MockAKSEngineClient
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{
+			FakeGetDedicatedHostGroupResult: func() compute.DedicatedHostGroup {
+				return compute.DedicatedHostGroup{
+					Location: to.StringPtr("East US"),
+					DedicatedHostGroupProperties: &compute.DedicatedHostGroupProperties{
+						Hosts: &[]compute.SubResourceReadOnly{
+							{ID: to.StringPtr(testDedicatedHostGroupID + "/hosts/host0")},
+							{ID: to.StringPtr(testDedicatedHostGroupID + "/hosts/host1")},
+						},
+					},
+				}
+			},
+			FakeGetDedicatedHostResult: func(name string) compute.DedicatedHost {
+				if name == "host0" {
+					return newTestDedicatedHost(name, map[string]float64{"Standard_D2_v2": 1, "Standard_D4_v2": 8})
+				}
+				return newTestDedicatedHost(name, map[string]float64{"standard_d2_v2": 3})
+			},
+		}
+	})
+
+	It("Should place the master VM on the dedicated host with the most capacity", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DedicatedHostGroupID = testDedicatedHostGroupID
+
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		vms := masterResources(kmn.TemplateMap, vmResourceType)
+		Expect(vms).To(HaveLen(1))
+		Expect(resourceProperties(vms[0])["host"]).To(Equal(map[string]interface{}{
+			"id": testDedicatedHostGroupID + "/hosts/host1",
+		}))
+	})
+
+	It("Should leave the template untouched without a dedicated host group", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		vms := masterResources(kmn.TemplateMap, vmResourceType)
+		Expect(resourceProperties(vms[0])).NotTo(HaveKey("host"))
+	})
+
+	It("Should fail preflight when the host group is in another region", func() {
+		mockClient.FakeGetDedicatedHostGroupResult = func() compute.DedicatedHostGroup {
+			return compute.DedicatedHostGroup{Location: to.StringPtr("westus2")}
+		}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DedicatedHostGroupID = testDedicatedHostGroupID
+
+		err := kmn.Preflight(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("dedicated host group masters is in region westus2, expected eastus"))
+	})
+
+	It("Should fail preflight when no host has capacity for the master VM size", func() {
+		mockClient.FakeGetDedicatedHostResult = func(name string) compute.DedicatedHost {
+			return newTestDedicatedHost(name, map[string]float64{"Standard_D2_v2": 0, "Standard_D4_v2": 8})
+		}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DedicatedHostGroupID = testDedicatedHostGroupID
+
+		err := kmn.Preflight(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("dedicated host group masters has no capacity left for a Standard_D2_v2 VM"))
+	})
+
+	It("Should fail preflight when the host group does not exist", func() {
+		mockClient.FailGetDedicatedHostGroup = true
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DedicatedHostGroupID = testDedicatedHostGroupID
+
+		Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+	})
+
+	It("Should fail preflight for a malformed host group ID", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DedicatedHostGroupID = "masters"
+
+		Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// addExplicitDependencies merges ExplicitDependencies into the dependsOn arrays of the template resources
+// and ensures the resulting resource graph has no cycle
+func (kmn *UpgradeMasterNode) addExplicitDependencies() error {
+	byName := map[string]map[string]interface{}{}
+	for _, resource := range templateResources(kmn.TemplateMap) {
+		name, _ := resource["name"].(string)
+		byName[name] = resource
+	}
+	for _, name := range sortedKeys(kmn.ExplicitDependencies) {
+		resource, ok := byName[name]
+		if !ok {
+			return errors.Errorf("resource %s of the explicit dependencies is not in the upgrade template", name)
+		}
+		dependsOn, _ := resource["dependsOn"].([]interface{})
+		existing := map[string]bool{}
+		for _, d := range dependsOn {
+			if s, ok := d.(string); ok {
+				existing[s] = true
+			}
+		}
+		for _, dependency := range kmn.ExplicitDependencies[name] {
+			if dependency == name {
+				return errors.Errorf("resource %s cannot depend on itself", name)
+			}
+			if !existing[dependency] {
+				existing[dependency] = true
+				dependsOn = append(dependsOn, dependency)
+			}
+		}
+		resource["dependsOn"] = dependsOn
+	}
+	return ValidateDependencyCycles(templateDependencies(kmn.TemplateMap))
+}
+
+// templateDependencies returns the dependencies between the resources of the template keyed by resource name.
+// A dependsOn entry refers to a resource of the template by its name or by its type and name,
+// e.g. Microsoft.Network/networkInterfaces/<name>; the entries referring to other resources are left out.
+func templateDependencies(templateMap map[string]interface{}) map[string][]string {
+	resources := templateResources(templateMap)
+	refs := map[string]string{}
+	for _, resource := range resources {
+		name, _ := resource["name"].(string)
+		t, _ := resource["type"].(string)
+		refs[name] = name
+		refs[t+"/"+name] = name
+		if isTemplateExpression(name) {
+			// [concat(...)] names are referred to as [concat('<type>/', ...)]
+			refs["[concat('"+t+"/', "+strings.TrimPrefix(strings.TrimSuffix(name, ")]"), "[concat(")+")]"] = name
+		}
+	}
+	dependencies := map[string][]string{}
+	for _, resource := range resources {
+		name, _ := resource["name"].(string)
+		dependencies[name] = nil
+		dependsOn, _ := resource["dependsOn"].([]interface{})
+		for _, d := range dependsOn {
+			s, _ := d.(string)
+			if ref, ok := refs[s]; ok {
+				dependencies[name] = append(dependencies[name], ref)
+			}
+		}
+	}
+	return dependencies
+}
+
+// ValidateDependencyCycles returns an error describing the first circular dependency found between the
+// resources of dependencies, which maps a resource name to the names of the resources it depends on
+func ValidateDependencyCycles(dependencies map[string][]string) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+				}
+			}
+			return errors.Errorf("circular dependency between template resources: %s", strings.Join(append(path[start:], name), " -> "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range sortedKeys(dependencies) {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateResources returns the resources of the template
+func templateResources(templateMap map[string]interface{}) []map[string]interface{} {
+	var resources []map[string]interface{}
+	list, _ := templateMap["resources"].([]interface{})
+	for _, resource := range list {
+		if resourceMap, ok := resource.(map[string]interface{}); ok {
+			resources = append(resources, resourceMap)
+		}
+	}
+	return resources
+}
+
+func isTemplateExpression(s string) bool {
+	return strings.HasPrefix(s, "[concat(") && strings.HasSuffix(s, ")]")
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
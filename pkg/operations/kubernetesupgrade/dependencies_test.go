@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Template dependencies tests", func() {
+	const (
+		masterVMName  = "[concat(variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')))]"
+		masterNICName = "[concat(variables('masterVMNamePrefix'), 'nic-', copyIndex(variables('masterOffset')))]"
+		masterNICRef  = "[concat('Microsoft.Network/networkInterfaces/', variables('masterVMNamePrefix'), 'nic-', copyIndex(variables('masterOffset')))]"
+	)
+
+	It("Should detect circular dependencies", func() {
+		Expect(ValidateDependencyCycles(map[string][]string{
+			"vm":  {"nic"},
+			"nic": {"nsg", "vnet"},
+			"nsg": nil,
+		})).To(Succeed())
+
+		Expect(ValidateDependencyCycles(map[string][]string{
+			"vm":        {"nic"},
+			"nic":       {"extension"},
+			"extension": {"vm"},
+		})).To(MatchError("circular dependency between template resources: extension -> vm -> nic -> extension"))
+
+		Expect(ValidateDependencyCycles(map[string][]string{"vm": {"vm"}})).To(MatchError("circular dependency between template resources: vm -> vm"))
+	})
+
+	It("Should resolve the dependencies between the template resources", func() {
+		template := newTestMasterTemplate()
+		vm := masterResources(template, vmResourceType)[0]
+		vm["dependsOn"] = []interface{}{masterNICRef, "[resourceId('Microsoft.Network/virtualNetworks', 'cluster-vnet')]"}
+
+		dependencies := templateDependencies(template)
+		Expect(dependencies[masterVMName]).To(Equal([]string{masterNICName}))
+		Expect(dependencies[masterNICName]).To(BeEmpty())
+	})
+
+	It("Should merge the explicit dependencies into dependsOn before the deployment", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+		vm["dependsOn"] = []interface{}{masterNICRef}
+		kmn.ExplicitDependencies = map[string][]string{
+			masterVMName: {masterNICRef, "[resourceId('Microsoft.Compute/availabilitySets', 'master-availabilityset')]"},
+		}
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(vm["dependsOn"]).To(Equal([]interface{}{
+			masterNICRef,
+			"[resourceId('Microsoft.Compute/availabilitySets', 'master-availabilityset')]",
+		}))
+	})
+
+	It("Should fail on unknown resources and circular explicit dependencies", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.ExplicitDependencies = map[string][]string{"master-extension": {masterVMName}}
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(MatchError("resource master-extension of the explicit dependencies is not in the upgrade template"))
+
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.ExplicitDependencies = map[string][]string{masterVMName: {masterVMName}}
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(MatchError("resource " + masterVMName + " cannot depend on itself"))
+
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		masterResources(kmn.TemplateMap, vmResourceType)[0]["dependsOn"] = []interface{}{masterNICRef}
+		kmn.ExplicitDependencies = map[string][]string{masterNICName: {masterVMName}}
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(MatchError("circular dependency between template resources: " + masterNICName + " -> " + masterVMName + " -> " + masterNICName))
+	})
+})
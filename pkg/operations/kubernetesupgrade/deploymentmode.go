@@ -0,0 +1,32 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/pkg/errors"
+)
+
+// DefaultDeploymentMode is the ARM deployment mode of the master VM deployments when DeploymentMode is empty
+const DefaultDeploymentMode = string(resources.Incremental)
+
+// ValidateDeploymentMode ensures the ARM deployment mode is Incremental or Complete
+func ValidateDeploymentMode(mode string) error {
+	for _, m := range resources.PossibleDeploymentModeValues() {
+		if strings.EqualFold(mode, string(m)) {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid deployment mode %q, expected %s or %s", mode, resources.Incremental, resources.Complete)
+}
+
+// deploymentMode returns the ARM deployment mode of the master VM deployments
+func (kmn *UpgradeMasterNode) deploymentMode() resources.DeploymentMode {
+	if strings.EqualFold(kmn.DeploymentMode, string(resources.Complete)) {
+		return resources.Complete
+	}
+	return resources.Incremental
+}
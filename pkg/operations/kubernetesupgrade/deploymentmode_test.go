@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+var _ = Describe("Deployment mode tests", func() {
+	It("Should deploy the master VMs in Incremental mode by default", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{}
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(mockClient.DeploymentModes).To(Equal([]resources.DeploymentMode{resources.Incremental}))
+	})
+
+	It("Should deploy the master VMs in Complete mode with a warning", func() {
+		logger, hook := logtest.NewNullLogger()
+		mockClient := &armhelpers.MockAKSEngineClient{}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.logger = log.NewEntry(logger)
+		kmn.DeploymentMode = "complete"
+		kmn.ExistingDeploymentName = "cluster-masters"
+
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(mockClient.DeploymentModes).To(Equal([]resources.DeploymentMode{resources.Complete}))
+
+		var warnings []string
+		for _, entry := range hook.Entries {
+			if entry.Level == log.WarnLevel {
+				warnings = append(warnings, entry.Message)
+			}
+		}
+		Expect(warnings).To(ContainElement("Deploying cluster-masters in Complete mode, ARM deletes the resources of resource group TestRg that are not in the template"))
+	})
+
+	It("Should use the deployment mode when polling the deployment", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DeploymentMode = "Complete"
+		kmn.DeploymentPollInterval = time.Millisecond
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(mockClient.DeploymentModes).To(Equal([]resources.DeploymentMode{resources.Complete}))
+	})
+
+	It("Should reject an unknown deployment mode", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DeploymentMode = "Replace"
+
+		Expect(kmn.Preflight(context.Background())).To(MatchError(`invalid deployment mode "Replace", expected Incremental or Complete`))
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+		Expect(mockClient.DeploymentModes).To(BeEmpty())
+	})
+})
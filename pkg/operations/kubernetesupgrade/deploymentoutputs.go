@@ -0,0 +1,84 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OutputMismatchError is returned when the outputs of a master VM deployment do not match ExpectedOutputs
+type OutputMismatchError struct {
+	// DeploymentName is the name of the verified deployment
+	DeploymentName string
+	// Missing lists the expected outputs the deployment does not have
+	Missing []string
+	// Mismatched maps the expected outputs of another value to the value of the deployment
+	Mismatched map[string]string
+}
+
+func (e *OutputMismatchError) Error() string {
+	return fmt.Sprintf("outputs of deployment %s do not match the expected outputs, missing: %v, mismatched: %v", e.DeploymentName, e.Missing, e.Mismatched)
+}
+
+// isOutputPattern reports whether the expected output value is a regular expression, i.e. enclosed in slashes
+func isOutputPattern(expected string) bool {
+	return len(expected) > 1 && strings.HasPrefix(expected, "/") && strings.HasSuffix(expected, "/")
+}
+
+// outputPattern returns the regular expression of an expected output value enclosed in slashes, matching the whole value
+func outputPattern(expected string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expected[1:len(expected)-1] + ")$")
+}
+
+// validateExpectedOutputs ensures the regular expressions of ExpectedOutputs compile
+func (kmn *UpgradeMasterNode) validateExpectedOutputs() error {
+	for name, expected := range kmn.ExpectedOutputs {
+		if !isOutputPattern(expected) {
+			continue
+		}
+		if _, err := outputPattern(expected); err != nil {
+			return errors.Wrapf(err, "parsing the expected value of deployment output %s", name)
+		}
+	}
+	return nil
+}
+
+// VerifyDeploymentOutputs returns an OutputMismatchError unless each of ExpectedOutputs is an output of the
+// deployment with the expected value, or a value matching it if enclosed in slashes, e.g. /10\.239\.255\.\d+/
+func (kmn *UpgradeMasterNode) VerifyDeploymentOutputs(ctx context.Context, deploymentName string) error {
+	outputs, err := kmn.Client.GetDeploymentOutputs(ctx, kmn.ResourceGroup, deploymentName)
+	if err != nil {
+		return errors.Wrapf(err, "getting the outputs of deployment %s", deploymentName)
+	}
+	mismatch := &OutputMismatchError{DeploymentName: deploymentName, Mismatched: map[string]string{}}
+	for name, expected := range kmn.ExpectedOutputs {
+		value, ok := outputs[name]
+		if !ok {
+			mismatch.Missing = append(mismatch.Missing, name)
+			continue
+		}
+		actual := fmt.Sprint(value)
+		if actual == expected {
+			continue
+		}
+		if isOutputPattern(expected) {
+			if pattern, err := outputPattern(expected); err == nil && pattern.MatchString(actual) {
+				continue
+			}
+		}
+		mismatch.Mismatched[name] = actual
+	}
+	if len(mismatch.Missing) > 0 || len(mismatch.Mismatched) > 0 {
+		sort.Strings(mismatch.Missing)
+		return mismatch
+	}
+	kmn.logger.Infof("Outputs of deployment %s match the expected outputs", deploymentName)
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// defaultDeploymentPollInterval is the deployment poll interval used when only MaxDeploymentPolls is set
+const defaultDeploymentPollInterval = 30 * time.Second
+
+// DeploymentPollingTimeoutError is returned when an ARM deployment did not complete
+// within MaxDeploymentPolls polls. The deployment may still be running.
+type DeploymentPollingTimeoutError struct {
+	DeploymentName string
+	ResourceGroup  string
+	Polls          int
+}
+
+// Error implements error interface
+func (e *DeploymentPollingTimeoutError) Error() string {
+	return fmt.Sprintf("deployment %s in resource group %s did not complete after %d polls, "+
+		"check its status in the Azure portal or with 'az deployment group show -g %s -n %s'",
+		e.DeploymentName, e.ResourceGroup, e.Polls, e.ResourceGroup, e.DeploymentName)
+}
+
+// deployTemplate deploys the upgrade template. When neither DeploymentPollInterval nor MaxDeploymentPolls
+// is set it waits for the deployment with the ARM client defaults, otherwise it polls the deployment state.
+func (kmn *UpgradeMasterNode) deployTemplate(ctx context.Context, deploymentName string) error {
+	mode := kmn.deploymentMode()
+	if mode == resources.Complete {
+		kmn.logger.Warningf("Deploying %s in Complete mode, ARM deletes the resources of resource group %s that are not in the template", deploymentName, kmn.ResourceGroup)
+	}
+	var templateURI string
+	if kmn.TemplateBlobURI != "" {
+		uri, err := kmn.uploadTemplate(ctx, deploymentName)
+		if err != nil {
+			return err
+		}
+		templateURI = uri
+	}
+	if kmn.DeploymentPollInterval <= 0 && kmn.MaxDeploymentPolls <= 0 {
+		if templateURI != "" {
+			_, err := kmn.Client.DeployTemplateLinkWithMode(ctx, kmn.ResourceGroup, deploymentName, templateURI, kmn.ParametersMap, mode)
+			return err
+		}
+		_, err := kmn.Client.DeployTemplateWithMode(ctx, kmn.ResourceGroup, deploymentName, kmn.TemplateMap, kmn.ParametersMap, mode)
+		return err
+	}
+	var err error
+	if templateURI != "" {
+		err = kmn.Client.BeginDeployTemplateLinkWithMode(ctx, kmn.ResourceGroup, deploymentName, templateURI, kmn.ParametersMap, mode)
+	} else {
+		err = kmn.Client.BeginDeployTemplateWithMode(ctx, kmn.ResourceGroup, deploymentName, kmn.TemplateMap, kmn.ParametersMap, mode)
+	}
+	if err != nil {
+		return err
+	}
+	return kmn.waitForDeployment(ctx, deploymentName)
+}
+
+// waitForDeployment polls the deployment every DeploymentPollInterval until it completes,
+// giving up after MaxDeploymentPolls polls if set.
+func (kmn *UpgradeMasterNode) waitForDeployment(ctx context.Context, deploymentName string) error {
+	interval := kmn.DeploymentPollInterval
+	if interval <= 0 {
+		interval = defaultDeploymentPollInterval
+	}
+	for polls := 1; kmn.MaxDeploymentPolls <= 0 || polls <= kmn.MaxDeploymentPolls; polls++ {
+		deployment, err := kmn.Client.GetDeployment(ctx, kmn.ResourceGroup, deploymentName)
+		if err != nil {
+			return errors.Wrapf(err, "getting deployment %s", deploymentName)
+		}
+		state := ""
+		if deployment.Properties != nil {
+			state = to.String(deployment.Properties.ProvisioningState)
+		}
+		switch state {
+		case "Succeeded":
+			kmn.logger.Infof("Finished ARM Deployment (%s). Succeeded", deploymentName)
+			return nil
+		case "Failed", "Canceled":
+			return errors.Errorf("deployment %s in resource group %s finished with provisioning state %s", deploymentName, kmn.ResourceGroup, state)
+		}
+		kmn.logger.Debugf("Deployment %s provisioning state is %s (poll %d)", deploymentName, state, polls)
+		if kmn.MaxDeploymentPolls > 0 && polls == kmn.MaxDeploymentPolls {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for deployment %s", deploymentName)
+		case <-time.After(interval):
+		}
+	}
+	return &DeploymentPollingTimeoutError{DeploymentName: deploymentName, ResourceGroup: kmn.ResourceGroup, Polls: kmn.MaxDeploymentPolls}
+}
+
+// waitForRunningDeployment waits for the running upgrade deployment of the master VM with index masterNo
+// when ReuseExistingDeployment is set and returns its name, empty if there is none to reuse.
+// The deployments named k8s-upgrade-master-<masterNo>-* or ExistingDeploymentName match, the latest wins.
+func (kmn *UpgradeMasterNode) waitForRunningDeployment(ctx context.Context, masterNo int) (string, error) {
+	if !kmn.ReuseExistingDeployment {
+		return "", nil
+	}
+	prefix := fmt.Sprintf("%smaster-%d-", upgradeDeploymentPrefix, masterNo)
+	var running *resources.DeploymentExtended
+	page, err := kmn.Client.ListDeployments(ctx, kmn.ResourceGroup)
+	if err != nil {
+		return "", errors.Wrap(err, "listing deployments")
+	}
+	for ; page.NotDone(); err = page.Next() {
+		if err != nil {
+			return "", errors.Wrap(err, "listing deployments")
+		}
+		for _, d := range page.Values() {
+			d := d
+			name := to.String(d.Name)
+			if !strings.HasPrefix(name, prefix) && (kmn.ExistingDeploymentName == "" || name != kmn.ExistingDeploymentName) {
+				continue
+			}
+			if d.Properties == nil || !isDeploymentRunning(to.String(d.Properties.ProvisioningState)) {
+				continue
+			}
+			if running == nil || deploymentTimestamp(&d).After(deploymentTimestamp(running)) {
+				running = &d
+			}
+		}
+	}
+	if running == nil {
+		kmn.logger.Infof("No running deployment of master VM with index %d to reuse, creating a new one", masterNo)
+		return "", nil
+	}
+	name := to.String(running.Name)
+	kmn.logger.Infof("Reusing deployment %s of master VM with index %d in provisioning state %s", name, masterNo, to.String(running.Properties.ProvisioningState))
+	if err := kmn.waitForDeployment(ctx, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func deploymentTimestamp(d *resources.DeploymentExtended) time.Time {
+	if d.Properties == nil || d.Properties.Timestamp == nil {
+		return time.Time{}
+	}
+	return d.Properties.Timestamp.Time
+}
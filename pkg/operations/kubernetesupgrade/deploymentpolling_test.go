@@ -0,0 +1,170 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deployment polling tests", func() {
+	var polls int
+
+	deploymentInState := func(states ...string) func(string) resources.DeploymentExtended {
+		return func(name string) resources.DeploymentExtended {
+			state := states[len(states)-1]
+			if polls < len(states) {
+				state = states[polls]
+			}
+			polls++
+			return resources.DeploymentExtended{
+				Name:       to.StringPtr(name),
+				Properties: &resources.DeploymentPropertiesExtended{ProvisioningState: to.StringPtr(state)},
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		polls = 0
+	})
+
+	It("Should wait with the ARM client defaults without polling options", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{FakeGetDeploymentResult: deploymentInState("Running")}
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(polls).To(Equal(0))
+	})
+
+	It("Should poll the deployment until it succeeds", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{FakeGetDeploymentResult: deploymentInState("Accepted", "Running", "Succeeded")}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.DeploymentPollInterval = time.Millisecond
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(polls).To(Equal(3))
+		Expect(kmn.deploymentNames).To(HaveLen(1))
+	})
+
+	It("Should return a DeploymentPollingTimeoutError after MaxDeploymentPolls", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{FakeGetDeploymentResult: deploymentInState("Running")}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.ExistingDeploymentName = "cluster-masters"
+		kmn.DeploymentPollInterval = time.Millisecond
+		kmn.MaxDeploymentPolls = 3
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		timeoutErr, ok := err.(*DeploymentPollingTimeoutError)
+		Expect(ok).To(BeTrue())
+		Expect(timeoutErr.DeploymentName).To(Equal("cluster-masters"))
+		Expect(err.Error()).To(HavePrefix("deployment cluster-masters in resource group TestRg did not complete after 3 polls"))
+		Expect(polls).To(Equal(3))
+		Expect(kmn.deploymentNames).To(BeEmpty())
+	})
+
+	It("Should fail when the deployment fails", func() {
+		mockClient := &armhelpers.MockAKSEngineClient{FakeGetDeploymentResult: deploymentInState("Running", "Failed")}
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.ExistingDeploymentName = "cluster-masters"
+		kmn.DeploymentPollInterval = time.Millisecond
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("deployment cluster-masters in resource group TestRg finished with provisioning state Failed"))
+	})
+
+	It("Should fail when the deployment state cannot be read", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailGetDeployment: true})
+		kmn.ExistingDeploymentName = "cluster-masters"
+		kmn.MaxDeploymentPolls = 1
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("getting deployment cluster-masters: GetDeployment failed"))
+	})
+
+	Context("ReuseExistingDeployment", func() {
+		deployment := func(name, state string, age time.Duration) resources.DeploymentExtended {
+			return resources.DeploymentExtended{
+				Name: to.StringPtr(name),
+				Properties: &resources.DeploymentPropertiesExtended{
+					ProvisioningState: to.StringPtr(state),
+					Timestamp:         &date.Time{Time: time.Now().Add(-age)},
+				},
+			}
+		}
+
+		It("Should wait for the latest running deployment of the master VM instead of creating one", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{
+				FakeGetDeploymentResult: deploymentInState("Running", "Succeeded"),
+				FakeDeployments: []resources.DeploymentExtended{
+					deployment("k8s-upgrade-master-1-20-10-01T12.00.00-42", "Running", time.Hour),
+					deployment("k8s-upgrade-master-1-20-10-01T13.00.00-43", "Running", time.Minute),
+					deployment("k8s-upgrade-master-1-20-10-01T14.00.00-44", "Failed", 0),
+					deployment("k8s-upgrade-master-10-20-10-01T14.00.00-45", "Running", 0),
+				},
+			}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ReuseExistingDeployment = true
+			kmn.DeploymentPollInterval = time.Millisecond
+
+			Expect(kmn.CreateNode(context.Background(), "master", 1)).To(Succeed())
+			Expect(polls).To(Equal(2))
+			Expect(mockClient.DeploymentCorrelationIDs).To(BeEmpty())
+			Expect(kmn.deploymentNames).To(Equal([]string{"k8s-upgrade-master-1-20-10-01T13.00.00-43"}))
+		})
+
+		It("Should reuse the running ExistingDeploymentName deployment", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{
+				FakeDeployments: []resources.DeploymentExtended{deployment("cluster-masters", "Accepted", time.Minute)},
+			}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ReuseExistingDeployment = true
+			kmn.ExistingDeploymentName = "cluster-masters"
+			kmn.DeploymentPollInterval = time.Millisecond
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(mockClient.DeploymentCorrelationIDs).To(BeEmpty())
+			Expect(kmn.deploymentNames).To(Equal([]string{"cluster-masters"}))
+		})
+
+		It("Should create a new deployment when none is running", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{
+				FakeDeployments: []resources.DeploymentExtended{deployment("k8s-upgrade-master-0-20-10-01T12.00.00-42", "Succeeded", time.Hour)},
+			}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ReuseExistingDeployment = true
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(mockClient.DeploymentCorrelationIDs).To(HaveLen(1))
+			Expect(kmn.deploymentNames[0]).NotTo(Equal("k8s-upgrade-master-0-20-10-01T12.00.00-42"))
+		})
+
+		It("Should fail when the reused deployment fails or the deployments cannot be listed", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{
+				FakeGetDeploymentResult: deploymentInState("Running", "Canceled"),
+				FakeDeployments:         []resources.DeploymentExtended{deployment("k8s-upgrade-master-0-20-10-01T12.00.00-42", "Running", time.Hour)},
+			}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ReuseExistingDeployment = true
+			kmn.DeploymentPollInterval = time.Millisecond
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(MatchError("deployment k8s-upgrade-master-0-20-10-01T12.00.00-42 in resource group TestRg finished with provisioning state Canceled"))
+
+			mockClient.FailListDeployments = true
+			err = kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(MatchError("listing deployments: ListDeployments failed"))
+			Expect(mockClient.DeploymentCorrelationIDs).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Compiler to verify DevOpsPipelineReporter implements UpgradeReporter
+var _ UpgradeReporter = &DevOpsPipelineReporter{}
+
+// DevOpsPipelineReporter writes the upgrade events as Azure DevOps logging commands, so the upgrade
+// progress shows in the pipeline log and the result of the upgrade sets the status of the pipeline task.
+// See https://docs.microsoft.com/azure/devops/pipelines/scripts/logging-commands
+type DevOpsPipelineReporter struct {
+	Out io.Writer
+
+	mu sync.Mutex
+}
+
+// NewDevOpsPipelineReporter returns a DevOpsPipelineReporter writing to out
+func NewDevOpsPipelineReporter(out io.Writer) *DevOpsPipelineReporter {
+	return &DevOpsPipelineReporter{Out: out}
+}
+
+// Report writes the logging commands of the event: a section per upgrade and upgraded node, a command
+// per node step, an error per failure and the task result once the upgrade completed or failed
+func (r *DevOpsPipelineReporter) Report(event UpgradeEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	switch event.Type {
+	case UpgradeStartedEvent:
+		lines = append(lines, "##[section]"+event.Message)
+	case NodeDrainingEvent, NodeDeletingEvent, NodeCreatingEvent, NodeValidatingEvent:
+		lines = append(lines, "##[command]"+kubernetesEventMessage(event))
+	case NodeUpgradedEvent:
+		lines = append(lines, "##[section]"+kubernetesEventMessage(event))
+	case NodeSkippedEvent:
+		lines = append(lines, devOpsCommand("task.logissue", "type=warning", fmt.Sprintf("Skipped node %s of pool %s, it must be upgraded manually", event.NodeName, event.PoolName)))
+	case NodeUpgradeFailedEvent:
+		message := fmt.Sprintf("Failed to upgrade node %s of pool %s: %s", event.NodeName, event.PoolName, event.Message)
+		lines = append(lines, "##[error]"+escapeDevOpsData(message), devOpsCommand("task.logissue", "type=error", message))
+	case UpgradeCompletedEvent:
+		result := "Succeeded"
+		if len(event.SkippedNodes) > 0 {
+			result = "SucceededWithIssues"
+		}
+		lines = append(lines, "##[section]"+event.Message, devOpsCommand("task.complete", "result="+result, event.Message))
+	case UpgradeFailedEvent:
+		lines = append(lines, "##[error]"+escapeDevOpsData(event.Message), devOpsCommand("task.complete", "result=Failed", "Upgrade failed"))
+	default:
+		return nil
+	}
+	_, err := fmt.Fprintln(r.Out, strings.Join(lines, "\n"))
+	return err
+}
+
+// devOpsCommand formats a ##vso logging command
+func devOpsCommand(command, properties, message string) string {
+	return fmt.Sprintf("##vso[%s %s;]%s", command, properties, escapeDevOpsData(message))
+}
+
+// escapeDevOpsData keeps a multi-line message on the line of its logging command
+func escapeDevOpsData(message string) string {
+	return strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A").Replace(message)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Azure DevOps pipeline reporter tests", func() {
+	var (
+		out      *bytes.Buffer
+		reporter *DevOpsPipelineReporter
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		reporter = NewDevOpsPipelineReporter(out)
+	})
+
+	report := func(events ...UpgradeEvent) {
+		for _, event := range events {
+			Expect(reporter.Report(event)).To(Succeed())
+		}
+	}
+
+	It("Should write the progress of a successful upgrade", func() {
+		report(
+			UpgradeEvent{Type: UpgradeStartedEvent, Message: "Upgrading cluster from Kubernetes 1.18.8 to 1.18.10"},
+			UpgradeEvent{Type: NodeDrainingEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"},
+			UpgradeEvent{Type: NodeCreatingEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"},
+			UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0", Duration: 5 * time.Minute},
+			UpgradeEvent{Type: UpgradeCompletedEvent, Message: "Upgraded cluster to Kubernetes 1.18.10"},
+		)
+		Expect(out.String()).To(Equal("" +
+			"##[section]Upgrading cluster from Kubernetes 1.18.8 to 1.18.10\n" +
+			"##[command]Draining node k8s-agentpool1-0 of pool agentpool1\n" +
+			"##[command]Creating node k8s-agentpool1-0 of pool agentpool1\n" +
+			"##[section]Upgraded node k8s-agentpool1-0 of pool agentpool1 in 5m0s\n" +
+			"##[section]Upgraded cluster to Kubernetes 1.18.10\n" +
+			"##vso[task.complete result=Succeeded;]Upgraded cluster to Kubernetes 1.18.10\n"))
+	})
+
+	It("Should set the task result to succeeded with issues when nodes were skipped", func() {
+		report(
+			UpgradeEvent{Type: NodeSkippedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"},
+			UpgradeEvent{Type: UpgradeCompletedEvent, Message: "Upgraded cluster to Kubernetes 1.18.10", SkippedNodes: []string{"k8s-agentpool1-0"}},
+		)
+		Expect(out.String()).To(Equal("" +
+			"##vso[task.logissue type=warning;]Skipped node k8s-agentpool1-0 of pool agentpool1, it must be upgraded manually\n" +
+			"##[section]Upgraded cluster to Kubernetes 1.18.10\n" +
+			"##vso[task.complete result=SucceededWithIssues;]Upgraded cluster to Kubernetes 1.18.10\n"))
+	})
+
+	It("Should write the errors and fail the task when the upgrade failed", func() {
+		report(
+			UpgradeEvent{Type: NodeUpgradeFailedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0", Message: "drain timed out\n100% of the pods left"},
+			UpgradeEvent{Type: UpgradeFailedEvent, Message: "upgrading agent pool agentpool1"},
+		)
+		Expect(out.String()).To(Equal("" +
+			"##[error]Failed to upgrade node k8s-agentpool1-0 of pool agentpool1: drain timed out%0A100%AZP25 of the pods left\n" +
+			"##vso[task.logissue type=error;]Failed to upgrade node k8s-agentpool1-0 of pool agentpool1: drain timed out%0A100%AZP25 of the pods left\n" +
+			"##[error]upgrading agent pool agentpool1\n" +
+			"##vso[task.complete result=Failed;]Upgrade failed\n"))
+	})
+})
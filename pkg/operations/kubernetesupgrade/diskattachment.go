@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/pkg/errors"
+)
+
+// diskAttachedStatus is the instance view status code of an attached disk
+const diskAttachedStatus = "ProvisioningState/succeeded"
+
+var diskAttachmentCheckInterval = 10 * time.Second
+
+// WaitForDiskAttachment polls the instance view of the VM of nodeName until expectedDiskCount of its data disks
+// are attached, for up to DiskAttachmentTimeout.
+func (kan *UpgradeAgentNode) WaitForDiskAttachment(ctx context.Context, nodeName string, expectedDiskCount int) error {
+	ctx, cancel := context.WithTimeout(ctx, kan.DiskAttachmentTimeout)
+	defer cancel()
+	for {
+		attached, err := kan.attachedDataDisks(ctx, nodeName)
+		if err != nil {
+			kan.logger.Infof("Error getting the data disks of node %s: %v", nodeName, err)
+		} else if attached >= expectedDiskCount {
+			kan.logger.Infof("%d data disks of node %s are attached", attached, nodeName)
+			return nil
+		} else {
+			kan.logger.Infof("%d of %d data disks of node %s are attached", attached, expectedDiskCount, nodeName)
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return errors.Wrapf(err, "getting the data disks of node %s", nodeName)
+			}
+			return errors.Errorf("%d of %d data disks of node %s were attached within %v", attached, expectedDiskCount, nodeName, kan.DiskAttachmentTimeout)
+		case <-time.After(diskAttachmentCheckInterval):
+		}
+	}
+}
+
+// attachedDataDisks returns how many data disks of the VM model have an attached disk in the VM instance view
+func (kan *UpgradeAgentNode) attachedDataDisks(ctx context.Context, vmName string) (int, error) {
+	vm, err := kan.Client.GetVirtualMachine(ctx, kan.ResourceGroup, vmName)
+	if err != nil {
+		return 0, err
+	}
+	dataDisks := map[string]bool{}
+	if vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.DataDisks != nil {
+		for _, disk := range *vm.StorageProfile.DataDisks {
+			if disk.Name != nil {
+				dataDisks[strings.ToLower(*disk.Name)] = true
+			}
+		}
+	}
+	instanceView, err := kan.Client.GetVirtualMachineInstanceView(ctx, kan.ResourceGroup, vmName)
+	if err != nil {
+		return 0, err
+	}
+	attached := 0
+	if instanceView.Disks != nil {
+		for _, disk := range *instanceView.Disks {
+			if disk.Name == nil || !dataDisks[strings.ToLower(*disk.Name)] || disk.Statuses == nil {
+				continue
+			}
+			for _, status := range *disk.Statuses {
+				if status.Code != nil && strings.EqualFold(*status.Code, diskAttachedStatus) {
+					attached++
+					break
+				}
+			}
+		}
+	}
+	return attached, nil
+}
+
+// waitForDiskAttachment waits for the data disks of a new agent node of a pool with data disks to be attached
+// if DiskAttachmentTimeout is set
+func (ku *Upgrader) waitForDiskAttachment(ctx context.Context, upgradeAgentNode *UpgradeAgentNode, agentPoolProfile *api.AgentPoolProfile, vmName string) error {
+	if upgradeAgentNode.DiskAttachmentTimeout <= 0 || agentPoolProfile == nil || len(agentPoolProfile.DiskSizesGB) == 0 {
+		return nil
+	}
+	if err := upgradeAgentNode.WaitForDiskAttachment(ctx, vmName, len(agentPoolProfile.DiskSizesGB)); err != nil {
+		ku.logger.Errorf("Error waiting for the data disks of agent VM %s: %v", vmName, err)
+		return err
+	}
+	return nil
+}
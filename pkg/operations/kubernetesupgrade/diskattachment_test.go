@@ -0,0 +1,128 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Disk attachment tests", func() {
+	const vmName = "k8s-agentpool1-12345678-0"
+	var (
+		mockClient           *armhelpers.MockAKSEngineClient
+		kan                  *UpgradeAgentNode
+		attachedDisks        []string
+		instanceViewCalls    int
+		defaultCheckInterval time.Duration
+	)
+
+	diskInstanceView := func(name, code string) compute.DiskInstanceView {
+		return compute.DiskInstanceView{
+			Name:     to.StringPtr(name),
+			Statuses: &[]compute.InstanceViewStatus{{Code: to.StringPtr(code)}},
+		}
+	}
+
+	BeforeEach(func() {
+		defaultCheckInterval = diskAttachmentCheckInterval
+		diskAttachmentCheckInterval = 10 * time.Millisecond
+		attachedDisks = []string{"datadisk0", "datadisk1"}
+		instanceViewCalls = 0
+		mockClient = &armhelpers.MockAKSEngineClient{
+			FakeGetVirtualMachineDataDisks: &[]compute.DataDisk{
+				{Name: to.StringPtr("datadisk0")},
+				{Name: to.StringPtr("DataDisk1")},
+			},
+		}
+		mockClient.FakeGetVirtualMachineInstanceViewResult = func(name string) compute.VirtualMachineInstanceView {
+			instanceViewCalls++
+			disks := []compute.DiskInstanceView{diskInstanceView("osdisk", diskAttachedStatus)}
+			for _, disk := range attachedDisks {
+				disks = append(disks, diskInstanceView(disk, diskAttachedStatus))
+			}
+			return compute.VirtualMachineInstanceView{Disks: &disks}
+		}
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.Client = mockClient
+		kan.DiskAttachmentTimeout = time.Second
+	})
+
+	AfterEach(func() {
+		diskAttachmentCheckInterval = defaultCheckInterval
+	})
+
+	It("Should succeed once the data disks are attached", func() {
+		Expect(kan.WaitForDiskAttachment(context.Background(), vmName, 2)).To(Succeed())
+		Expect(instanceViewCalls).To(Equal(1))
+	})
+
+	It("Should poll until the data disks are attached", func() {
+		attachedDisks = nil
+		mockClient.FakeGetVirtualMachineInstanceViewResult = func(name string) compute.VirtualMachineInstanceView {
+			instanceViewCalls++
+			disks := []compute.DiskInstanceView{
+				diskInstanceView("osdisk", diskAttachedStatus),
+				diskInstanceView("datadisk0", diskAttachedStatus),
+				diskInstanceView("datadisk1", "ProvisioningState/updating"),
+			}
+			if instanceViewCalls > 2 {
+				disks[2] = diskInstanceView("datadisk1", diskAttachedStatus)
+			}
+			return compute.VirtualMachineInstanceView{Disks: &disks}
+		}
+
+		Expect(kan.WaitForDiskAttachment(context.Background(), vmName, 2)).To(Succeed())
+		Expect(instanceViewCalls).To(Equal(3))
+	})
+
+	It("Should fail when the data disks are not attached within the timeout", func() {
+		attachedDisks = []string{"datadisk0"}
+		kan.DiskAttachmentTimeout = 50 * time.Millisecond
+
+		err := kan.WaitForDiskAttachment(context.Background(), vmName, 2)
+		Expect(err).To(MatchError("1 of 2 data disks of node " + vmName + " were attached within 50ms"))
+	})
+
+	It("Should not count the disks that are not data disks of the VM", func() {
+		attachedDisks = []string{"datadisk0", "otherdisk"}
+		kan.DiskAttachmentTimeout = 50 * time.Millisecond
+
+		Expect(kan.WaitForDiskAttachment(context.Background(), vmName, 2)).NotTo(Succeed())
+	})
+
+	It("Should return the instance view error on timeout", func() {
+		mockClient.FailGetVirtualMachineInstanceView = true
+		kan.DiskAttachmentTimeout = 50 * time.Millisecond
+
+		err := kan.WaitForDiskAttachment(context.Background(), vmName, 2)
+		Expect(err).To(MatchError("getting the data disks of node " + vmName + ": GetVirtualMachineInstanceView failed"))
+	})
+
+	It("Should only wait for the new nodes of the pools with data disks", func() {
+		u := &Upgrader{}
+		u.Init(kan.Translator, kan.logger, ClusterTopology{}, mockClient, "", nil, nil, TestAKSEngineVersion, false)
+		pool := &api.AgentPoolProfile{Name: "agentpool1"}
+		attachedDisks = nil
+
+		Expect(u.waitForDiskAttachment(context.Background(), kan, pool, vmName)).To(Succeed())
+		Expect(instanceViewCalls).To(BeZero())
+
+		pool.DiskSizesGB = []int{128, 128}
+		kan.DiskAttachmentTimeout = 0
+		Expect(u.waitForDiskAttachment(context.Background(), kan, pool, vmName)).To(Succeed())
+		Expect(instanceViewCalls).To(BeZero())
+
+		kan.DiskAttachmentTimeout = 50 * time.Millisecond
+		Expect(u.waitForDiskAttachment(context.Background(), kan, pool, vmName)).NotTo(Succeed())
+		Expect(instanceViewCalls).NotTo(BeZero())
+	})
+})
@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// validateDiskEncryptionSet ensures the disk encryption set exists and lives in the same region as the cluster
+func (kmn *UpgradeMasterNode) validateDiskEncryptionSet(ctx context.Context) error {
+	if kmn.DiskEncryptionSetID == "" {
+		return nil
+	}
+	name, err := utils.ResourceName(kmn.DiskEncryptionSetID)
+	if err != nil {
+		return errors.Wrapf(err, "parsing disk encryption set ID %s", kmn.DiskEncryptionSetID)
+	}
+	resourceGroup, err := utils.ResourceGroupName(kmn.DiskEncryptionSetID)
+	if err != nil {
+		return errors.Wrapf(err, "parsing disk encryption set ID %s", kmn.DiskEncryptionSetID)
+	}
+	des, err := kmn.Client.GetDiskEncryptionSet(ctx, resourceGroup, name)
+	if err != nil {
+		return errors.Wrapf(err, "getting disk encryption set %s", kmn.DiskEncryptionSetID)
+	}
+	location := helpers.NormalizeAzureRegion(kmn.UpgradeContainerService.Location)
+	desLocation := helpers.NormalizeAzureRegion(to.String(des.Location))
+	if desLocation != location {
+		return errors.Errorf("disk encryption set %s is in region %s, expected %s", name, desLocation, location)
+	}
+	return nil
+}
+
+// setDiskEncryptionSet encrypts the managed OS disk of the master VM resource with DiskEncryptionSetID
+func (kmn *UpgradeMasterNode) setDiskEncryptionSet(vm map[string]interface{}) {
+	osDisk := osDiskProperties(vm)
+	managedDisk, ok := osDisk["managedDisk"].(map[string]interface{})
+	if !ok {
+		managedDisk = map[string]interface{}{}
+		osDisk["managedDisk"] = managedDisk
+	}
+	managedDisk["diskEncryptionSet"] = map[string]interface{}{
+		"id": kmn.DiskEncryptionSetID,
+	}
+}
+
+// VerifyDiskEncryptionSet fails unless the OS disk of the created master VM is encrypted with DiskEncryptionSetID
+func (kmn *UpgradeMasterNode) VerifyDiskEncryptionSet(ctx context.Context, vmName string) error {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return errors.Wrapf(err, "getting VM %s", vmName)
+	}
+	var desID string
+	if vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.OsDisk != nil &&
+		vm.StorageProfile.OsDisk.ManagedDisk != nil && vm.StorageProfile.OsDisk.ManagedDisk.DiskEncryptionSet != nil {
+		desID = to.String(vm.StorageProfile.OsDisk.ManagedDisk.DiskEncryptionSet.ID)
+	}
+	if !strings.EqualFold(desID, kmn.DiskEncryptionSetID) {
+		return errors.Errorf("the OS disk of VM %s is encrypted with disk encryption set %q, expected %s", vmName, desID, kmn.DiskEncryptionSetID)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDNSPropagationTimeout is how long WaitForDNSResolution waits for the hostname of a master VM
+	// to resolve when DNSPropagationTimeout is zero
+	DefaultDNSPropagationTimeout = 10 * time.Minute
+	// DefaultDNSQueryTimeout is the timeout of each DNS query of WaitForDNSResolution when DNSQueryTimeout is zero
+	DefaultDNSQueryTimeout = 5 * time.Second
+)
+
+// validateDNSPropagation ensures the DNS resolver address and timeouts of WaitForDNSPropagation are valid
+func (kmn *UpgradeMasterNode) validateDNSPropagation() error {
+	if kmn.DNSPropagationTimeout < 0 || kmn.DNSQueryTimeout < 0 {
+		return errors.New("the DNS propagation and DNS query timeouts must not be negative")
+	}
+	if kmn.DNSResolverAddress != "" {
+		if _, _, err := net.SplitHostPort(kmn.DNSResolverAddress); err != nil {
+			return errors.Wrapf(err, "invalid DNS resolver address %s, expected <host>:<port>", kmn.DNSResolverAddress)
+		}
+	}
+	return nil
+}
+
+// resolver returns the resolver querying DNSResolverAddress, or the system resolver if empty
+func (kmn *UpgradeMasterNode) resolver() *net.Resolver {
+	if kmn.DNSResolverAddress == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, kmn.DNSResolverAddress)
+		},
+	}
+}
+
+// WaitForDNSResolution polls DNS until the hostname of the master VM resolves, each query timing out after
+// DNSQueryTimeout, and fails if it does not resolve within DNSPropagationTimeout
+func (kmn *UpgradeMasterNode) WaitForDNSResolution(ctx context.Context, vmName string) error {
+	hostname := strings.ToLower(vmName)
+	timeout := kmn.DNSPropagationTimeout
+	if timeout == 0 {
+		timeout = DefaultDNSPropagationTimeout
+	}
+	queryTimeout := kmn.DNSQueryTimeout
+	if queryTimeout == 0 {
+		queryTimeout = DefaultDNSQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resolver := kmn.resolver()
+	for {
+		queryCtx, cancelQuery := context.WithTimeout(ctx, queryTimeout)
+		addrs, err := resolver.LookupHost(queryCtx, hostname)
+		cancelQuery()
+		if err == nil && len(addrs) > 0 {
+			kmn.logger.Infof("Master VM hostname %s resolves to %s", hostname, strings.Join(addrs, ", "))
+			return nil
+		}
+		kmn.logger.Infof("Master VM hostname %s does not resolve yet: %v", hostname, err)
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("hostname %s of master VM %s did not resolve within %v", hostname, vmName, timeout)
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDNSServer answers the A queries of the hosts it knows with their IPv4 address, whatever the search domain
+// appended to them, the other queries with no answer, and counts the A queries it received for each host
+type fakeDNSServer struct {
+	conn    net.PacketConn
+	mu      sync.Mutex
+	hosts   map[string]net.IP
+	queries map[string]int
+	// silent drops the queries, to make them time out
+	silent bool
+}
+
+func newFakeDNSServer() *fakeDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	s := &fakeDNSServer{conn: conn, hosts: map[string]net.IP{}, queries: map[string]int{}}
+	go s.serve()
+	return s
+}
+
+func (s *fakeDNSServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := s.answer(buf[:n]); response != nil {
+			_, _ = s.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *fakeDNSServer) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	// the question starts after the 12 bytes header with the labels of the name
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		length := int(query[i])
+		if i+1+length > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+length]))
+		i += 1 + length
+	}
+	if i+5 > len(query) {
+		return nil
+	}
+	question := query[12 : i+5]
+	qtype := binary.BigEndian.Uint16(query[i+1 : i+3])
+	if len(labels) == 0 {
+		return nil
+	}
+	host := strings.ToLower(labels[0])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ip net.IP
+	if qtype == 1 {
+		s.queries[host]++
+		ip = s.hosts[host]
+	}
+	if s.silent {
+		return nil
+	}
+
+	response := make([]byte, 12, 512)
+	copy(response, query[:2])
+	binary.BigEndian.PutUint16(response[2:], 0x8180)
+	binary.BigEndian.PutUint16(response[4:], 1)
+	response = append(response, question...)
+	if ip != nil {
+		binary.BigEndian.PutUint16(response[6:], 1)
+		// name pointer to the question, type A, class IN, TTL 60, 4 bytes of data
+		response = append(response, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		response = append(response, ip.To4()...)
+	}
+	return response
+}
+
+func (s *fakeDNSServer) setHost(name string, ip net.IP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hosts[name] = ip
+}
+
+func (s *fakeDNSServer) queryCount(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[name]
+}
+
+func (s *fakeDNSServer) setSilent(silent bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silent = silent
+}
+
+var _ = Describe("DNS propagation tests", func() {
+	var (
+		server *fakeDNSServer
+		kmn    *UpgradeMasterNode
+		vmName string
+	)
+
+	BeforeEach(func() {
+		server = newFakeDNSServer()
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.WaitForDNSPropagation = true
+		kmn.DNSResolverAddress = server.conn.LocalAddr().String()
+		kmn.DNSPropagationTimeout = 500 * time.Millisecond
+		kmn.DNSQueryTimeout = 100 * time.Millisecond
+		vmName = kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + "0"
+	})
+
+	AfterEach(func() {
+		server.conn.Close()
+	})
+
+	It("Should return once the hostname of the master VM resolves through the resolver address", func() {
+		server.setHost(vmName, net.ParseIP("10.255.255.5"))
+
+		Expect(kmn.WaitForDNSResolution(context.Background(), strings.ToUpper(vmName))).To(Succeed())
+		Expect(server.queryCount(vmName)).To(BeNumerically(">", 0))
+	})
+
+	It("Should fail when the hostname does not resolve within DNSPropagationTimeout", func() {
+		start := time.Now()
+		err := kmn.WaitForDNSResolution(context.Background(), vmName)
+		Expect(err).To(MatchError("hostname " + vmName + " of master VM " + vmName + " did not resolve within 500ms"))
+		Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+	})
+
+	It("Should time out the queries the resolver does not answer", func() {
+		server.setSilent(true)
+		kmn.DNSPropagationTimeout = 2500 * time.Millisecond
+		kmn.DNSQueryTimeout = 50 * time.Millisecond
+
+		start := time.Now()
+		Expect(kmn.WaitForDNSResolution(context.Background(), vmName)).NotTo(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", 4*time.Second))
+		// a query is attempted after each poll interval instead of waiting for the timeout of the first one
+		Expect(server.queryCount(vmName)).To(BeNumerically(">=", 3))
+	})
+
+	It("Should validate the resolver address and timeouts", func() {
+		Expect(kmn.validateDNSPropagation()).To(Succeed())
+
+		kmn.DNSResolverAddress = "10.0.0.10"
+		Expect(kmn.validateDNSPropagation()).To(MatchError(ContainSubstring("invalid DNS resolver address 10.0.0.10, expected <host>:<port>")))
+
+		kmn.DNSResolverAddress = ""
+		kmn.DNSQueryTimeout = -time.Second
+		Expect(kmn.validateDNSPropagation()).To(MatchError("the DNS propagation and DNS query timeouts must not be negative"))
+	})
+})
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+)
+
+const (
+	etcdSnapshotPath    = "/tmp/etcd-snapshot.db"
+	etcdDataArchivePath = "/tmp/etcd-data.tar.gz"
+	etcdDataDirectory   = "/var/lib/etcddisk"
+
+	etcdctlCommand = "sudo ETCDCTL_API=3 etcdctl" +
+		" --cacert=/etc/kubernetes/certs/ca.crt --cert=/etc/kubernetes/certs/etcdclient.crt --key=/etc/kubernetes/certs/etcdclient.key"
+	etcdSnapshotScript = etcdctlCommand + " --endpoints=https://127.0.0.1:2379" +
+		" snapshot save " + etcdSnapshotPath + " && sudo chmod 644 " + etcdSnapshotPath
+	etcdDataDirectoryScript = "sudo tar -czf " + etcdDataArchivePath + " -C " + etcdDataDirectory + " . && sudo chmod 644 " + etcdDataArchivePath
+)
+
+// DetachAndSaveEtcdData uploads a snapshot of the etcd member of the master VM to EtcdBackupContainerURL
+// before the VM is deleted. With FallbackToDataDirectoryBackup, an archive of the etcd data directory is
+// uploaded as well when etcd data is on the OS disk, the VM having no data disk.
+// The blobs are named <vmName>/etcd-snapshot-<time>.db and <vmName>/etcd-data-<time>.tar.gz.
+func (kmn *UpgradeMasterNode) DetachAndSaveEtcdData(ctx context.Context, vmName string) error {
+	if kmn.EtcdBackupContainerURL == nil {
+		return nil
+	}
+	if kmn.SSHPrivateKeyPath == "" {
+		return errors.Errorf("an SSH private key is required to back up the etcd data of master VM %s", vmName)
+	}
+	dir, err := ioutil.TempDir("", "etcdbackup")
+	if err != nil {
+		return errors.Wrap(err, "creating etcd backup directory")
+	}
+	defer os.RemoveAll(dir)
+
+	host := kmn.etcdBackupHost(vmName)
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	kmn.logger.Infof("Saving an etcd snapshot of master VM %s", vmName)
+	if err = kmn.saveEtcdFile(ctx, host, etcdSnapshotScript, etcdSnapshotPath, dir, path.Join(vmName, "etcd-snapshot-"+timestamp+".db")); err != nil {
+		return err
+	}
+	if !kmn.FallbackToDataDirectoryBackup {
+		return nil
+	}
+
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return errors.Wrapf(err, "getting master VM %s", vmName)
+	}
+	if vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.DataDisks != nil && len(*vm.StorageProfile.DataDisks) > 0 {
+		return nil
+	}
+	kmn.logger.Infof("Saving the etcd data directory of master VM %s, which has no data disk", vmName)
+	return kmn.saveEtcdFile(ctx, host, etcdDataDirectoryScript, etcdDataArchivePath, dir, path.Join(vmName, "etcd-data-"+timestamp+".tar.gz"))
+}
+
+// etcdBackupHost returns the SSH host of the master VM, reached through the master FQDN
+func (kmn *UpgradeMasterNode) etcdBackupHost(vmName string) *ssh.RemoteHost {
+	authConfig := &ssh.AuthConfig{
+		User:           kmn.UpgradeContainerService.Properties.LinuxProfile.AdminUsername,
+		PrivateKeyPath: kmn.SSHPrivateKeyPath,
+	}
+	return &ssh.RemoteHost{
+		URI:             vmName,
+		Port:            22,
+		OperatingSystem: api.Linux,
+		AuthConfig:      authConfig,
+		Jumpbox: &ssh.JumpBox{
+			URI:             kmn.UpgradeContainerService.Properties.MasterProfile.FQDN,
+			Port:            22,
+			OperatingSystem: api.Linux,
+			AuthConfig:      authConfig,
+		},
+	}
+}
+
+// saveEtcdFile runs script on the host to create remotePath, downloads it to localDir and uploads it as blobName
+func (kmn *UpgradeMasterNode) saveEtcdFile(ctx context.Context, host *ssh.RemoteHost, script, remotePath, localDir, blobName string) error {
+	executeRemote, copyFromRemote := ssh.ExecuteRemote, ssh.CopyFromRemote
+	if kmn.executeRemote != nil {
+		executeRemote = kmn.executeRemote
+	}
+	if kmn.copyFromRemote != nil {
+		copyFromRemote = kmn.copyFromRemote
+	}
+
+	if out, err := executeRemote(ctx, host, script); err != nil {
+		return errors.Wrapf(err, "creating %s on master VM %s: %s", remotePath, host.URI, out)
+	}
+	localPath := filepath.Join(localDir, path.Base(remotePath))
+	if out, err := copyFromRemote(ctx, host, ssh.NewRemoteFile(remotePath, "", "", nil), localPath); err != nil {
+		return errors.Wrapf(err, "downloading %s from master VM %s: %s", remotePath, host.URI, out)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", localPath)
+	}
+	defer f.Close()
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{})
+	blob := azblob.NewContainerURL(*kmn.EtcdBackupContainerURL, p).NewBlockBlobURL(blobName)
+	if _, err = azblob.UploadFileToBlockBlob(ctx, f, blob, azblob.UploadToBlockBlobOptions{}); err != nil {
+		return errors.Wrapf(err, "uploading %s of master VM %s to blob %s", remotePath, host.URI, blobName)
+	}
+	kmn.logger.Infof("Uploaded %s of master VM %s to blob %s", remotePath, host.URI, blobName)
+	return nil
+}
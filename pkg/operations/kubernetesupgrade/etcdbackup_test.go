@@ -0,0 +1,138 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Etcd backup tests", func() {
+	var (
+		kmn        *UpgradeMasterNode
+		mockClient *armhelpers.MockAKSEngineClient
+		server     *httptest.Server
+		scripts    []string
+		hosts      []string
+		uploaded   map[string]string
+		vmName     = "k8s-master-12345678-0"
+	)
+
+	BeforeEach(func() {
+		scripts, hosts = nil, nil
+		uploaded = map[string]string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			uploaded[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		}))
+		containerURL, err := url.Parse(server.URL + "/etcd?sv=2019-02-02&sig=secret")
+		Expect(err).NotTo(HaveOccurred())
+
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		kmn.EtcdBackupContainerURL = containerURL
+		kmn.SSHPrivateKeyPath = "/home/azureuser/.ssh/id_rsa"
+		kmn.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			scripts = append(scripts, script)
+			hosts = append(hosts, host.URI+" via "+host.Jumpbox.URI)
+			return "", nil
+		}
+		kmn.copyFromRemote = func(ctx context.Context, host *ssh.RemoteHost, file *ssh.RemoteFile, destinationPath string) (string, error) {
+			return "", ioutil.WriteFile(destinationPath, []byte("content of "+file.Path), 0644)
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	uploadedBlob := func(prefix string) string {
+		for name, content := range uploaded {
+			if strings.HasPrefix(name, prefix) {
+				return content
+			}
+		}
+		return ""
+	}
+
+	It("Should not back up etcd without a container URL", func() {
+		kmn.EtcdBackupContainerURL = nil
+
+		Expect(kmn.DetachAndSaveEtcdData(context.Background(), vmName)).To(Succeed())
+		Expect(scripts).To(BeEmpty())
+	})
+
+	It("Should require an SSH private key", func() {
+		kmn.SSHPrivateKeyPath = ""
+
+		err := kmn.DetachAndSaveEtcdData(context.Background(), vmName)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("an SSH private key is required"))
+	})
+
+	It("Should upload an etcd snapshot of the master VM", func() {
+		Expect(kmn.DetachAndSaveEtcdData(context.Background(), vmName)).To(Succeed())
+
+		Expect(scripts).To(HaveLen(1))
+		Expect(scripts[0]).To(ContainSubstring("etcdctl"))
+		Expect(scripts[0]).To(ContainSubstring("snapshot save /tmp/etcd-snapshot.db"))
+		Expect(hosts[0]).To(HaveSuffix(" via " + kmn.UpgradeContainerService.Properties.MasterProfile.FQDN))
+		Expect(uploaded).To(HaveLen(1))
+		Expect(uploadedBlob("/etcd/" + vmName + "/etcd-snapshot-")).To(Equal("content of /tmp/etcd-snapshot.db"))
+	})
+
+	It("Should also upload the etcd data directory of a master VM without a data disk", func() {
+		kmn.FallbackToDataDirectoryBackup = true
+
+		Expect(kmn.DetachAndSaveEtcdData(context.Background(), vmName)).To(Succeed())
+
+		Expect(scripts).To(HaveLen(2))
+		Expect(scripts[1]).To(ContainSubstring("tar -czf /tmp/etcd-data.tar.gz -C /var/lib/etcddisk ."))
+		Expect(uploaded).To(HaveLen(2))
+		Expect(uploadedBlob("/etcd/" + vmName + "/etcd-data-")).To(Equal("content of /tmp/etcd-data.tar.gz"))
+	})
+
+	It("Should only upload the snapshot of a master VM with a data disk", func() {
+		kmn.FallbackToDataDirectoryBackup = true
+		kmn.Client = &dataDiskClient{MockAKSEngineClient: mockClient}
+
+		Expect(kmn.DetachAndSaveEtcdData(context.Background(), vmName)).To(Succeed())
+		Expect(scripts).To(HaveLen(1))
+		Expect(uploaded).To(HaveLen(1))
+	})
+
+	It("Should fail when the snapshot cannot be taken", func() {
+		kmn.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			return "Error: context deadline exceeded", errors.New("executing script")
+		}
+
+		err := kmn.DetachAndSaveEtcdData(context.Background(), vmName)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("creating /tmp/etcd-snapshot.db on master VM " + vmName + ": Error: context deadline exceeded"))
+		Expect(uploaded).To(BeEmpty())
+	})
+})
+
+// dataDiskClient returns master VMs with an etcd data disk
+type dataDiskClient struct {
+	*armhelpers.MockAKSEngineClient
+}
+
+func (c *dataDiskClient) GetVirtualMachine(ctx context.Context, resourceGroup, name string) (compute.VirtualMachine, error) {
+	vm, err := c.MockAKSEngineClient.GetVirtualMachine(ctx, resourceGroup, name)
+	vm.StorageProfile.DataDisks = &[]compute.DataDisk{{Name: &name}}
+	return vm, err
+}
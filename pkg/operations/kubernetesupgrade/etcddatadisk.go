@@ -0,0 +1,176 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxManagedDiskSizeGB is the size of the largest managed data disk
+	maxManagedDiskSizeGB = 32767
+	// masterEtcdDataDiskSizeGBVariable is the template variable holding the size of the etcd data disk
+	masterEtcdDataDiskSizeGBVariable = "masterEtcdDataDiskSizeGB"
+	// etcdDataDiskName is the name of the etcd data disk of each master VM, as an ARM template expression
+	etcdDataDiskName = "concat(variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')), '-etcddatadisk')"
+
+	// etcdDataDiskMountScript waits for the lun0 data disk, formats it unless it already has a file system,
+	// mounts it on the etcd data directory and adds it to /etc/fstab
+	etcdDataDiskMountScript = `set -e
+disk=/dev/disk/azure/scsi1/lun0 dir=` + etcdDataDirectory + `
+for i in $(seq 1 60); do [ -e "$disk" ] && break; sleep 5; done
+[ -e "$disk" ] || { echo "$disk not found"; exit 1; }
+sudo blkid "$disk" >/dev/null || sudo mkfs.ext4 -F "$disk"
+sudo mkdir -p "$dir"
+mountpoint -q "$dir" || sudo mount "$disk" "$dir"
+grep -q " $dir " /etc/fstab || echo "$disk $dir ext4 defaults,nofail 0 2" | sudo tee -a /etc/fstab >/dev/null`
+)
+
+// etcdDataDiskSizeGB returns EtcdDataDiskSizeGB, the etcd disk size of the api model if zero
+func (kmn *UpgradeMasterNode) etcdDataDiskSizeGB() int {
+	if kmn.EtcdDataDiskSizeGB != 0 {
+		return kmn.EtcdDataDiskSizeGB
+	}
+	if kc := kmn.UpgradeContainerService.Properties.OrchestratorProfile.KubernetesConfig; kc != nil {
+		if size, err := strconv.Atoi(kc.EtcdDiskSizeGB); err == nil && size > 0 {
+			return size
+		}
+	}
+	size, _ := strconv.Atoi(api.DefaultEtcdDiskSize)
+	return size
+}
+
+// validateEtcdDataDisk ensures the etcd data disk size is valid and the disk can be mounted over SSH
+func (kmn *UpgradeMasterNode) validateEtcdDataDisk() error {
+	if !kmn.AttachEtcdDataDisk {
+		if kmn.EtcdDataDiskSizeGB != 0 {
+			return errors.New("an etcd data disk size requires attaching an etcd data disk")
+		}
+		return nil
+	}
+	if kmn.EtcdDataDiskSizeGB < 0 || kmn.EtcdDataDiskSizeGB > maxManagedDiskSizeGB {
+		return errors.Errorf("invalid etcd data disk size %d GB, expected 1 to %d GB", kmn.EtcdDataDiskSizeGB, maxManagedDiskSizeGB)
+	}
+	if kmn.SSHPrivateKeyPath == "" {
+		return errors.New("an SSH private key is required to mount the etcd data disk of the master VMs")
+	}
+	return nil
+}
+
+// addEtcdDataDisk adds a managed disk resource for each master VM to the template, unless already added,
+// and attaches it to the master VM resources as their lun0 data disk in place of the template one
+func (kmn *UpgradeMasterNode) addEtcdDataDisk() {
+	kmn.TemplateMap["variables"].(map[string]interface{})[masterEtcdDataDiskSizeGBVariable] = kmn.etcdDataDiskSizeGB()
+	var disk map[string]interface{}
+	for _, d := range masterResources(kmn.TemplateMap, diskResourceType) {
+		if d["name"] == "["+etcdDataDiskName+"]" {
+			disk = d
+		}
+	}
+	for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+		if disk == nil {
+			disk = newEtcdDataDiskResource()
+			kmn.TemplateMap["resources"] = append(kmn.TemplateMap["resources"].([]interface{}), disk)
+			dependsOn, _ := vm["dependsOn"].([]interface{})
+			vm["dependsOn"] = append(dependsOn, "[concat('"+diskResourceType+"/', "+etcdDataDiskName[len("concat("):]+"]")
+			setEtcdDataDisk(vm)
+		}
+		if location, ok := vm["location"]; ok {
+			disk["location"] = location
+		}
+		// zonal VMs can only attach disks of their zone, which changes with TargetAvailabilityZone
+		if zones, ok := vm["zones"]; ok {
+			disk["zones"] = zones
+		} else {
+			delete(disk, "zones")
+		}
+		if kmn.OSDiskStorageAccountType != "" {
+			disk["sku"] = map[string]interface{}{
+				"name": "[variables('" + masterOSDiskStorageAccountTypeVariable + "')]",
+			}
+		}
+	}
+}
+
+// newEtcdDataDiskResource returns the managed disk resource of the etcd data disks of the master VMs
+func newEtcdDataDiskResource() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       diskResourceType,
+		"apiVersion": "[variables('apiVersionCompute')]",
+		"name":       "[" + etcdDataDiskName + "]",
+		"copy": map[string]interface{}{
+			"count": "[sub(variables('masterCount'), variables('masterOffset'))]",
+			"name":  "etcdDataDiskLoopNode",
+		},
+		"properties": map[string]interface{}{
+			"creationData": map[string]interface{}{
+				"createOption": "Empty",
+			},
+			"diskSizeGB": "[variables('" + masterEtcdDataDiskSizeGBVariable + "')]",
+		},
+	}
+}
+
+// setEtcdDataDisk attaches the etcd data disk to the master VM resource as its lun0 data disk
+func setEtcdDataDisk(vm map[string]interface{}) {
+	properties := resourceProperties(vm)
+	storageProfile, ok := properties["storageProfile"].(map[string]interface{})
+	if !ok {
+		storageProfile = map[string]interface{}{}
+		properties["storageProfile"] = storageProfile
+	}
+	dataDisk := map[string]interface{}{
+		"lun":          0,
+		"name":         "[" + etcdDataDiskName + "]",
+		"createOption": "Attach",
+		"managedDisk": map[string]interface{}{
+			"id": "[resourceId('" + diskResourceType + "', " + etcdDataDiskName + ")]",
+		},
+	}
+	dataDisks := []interface{}{dataDisk}
+	existing, _ := storageProfile["dataDisks"].([]interface{})
+	for _, d := range existing {
+		if m, ok := d.(map[string]interface{}); ok && isLun0(m["lun"]) {
+			continue
+		}
+		dataDisks = append(dataDisks, d)
+	}
+	storageProfile["dataDisks"] = dataDisks
+}
+
+// isLun0 reports whether the lun of a template data disk is 0, as a JSON number or an int
+func isLun0(lun interface{}) bool {
+	switch l := lun.(type) {
+	case float64:
+		return l == 0
+	case int:
+		return l == 0
+	case int32:
+		return l == 0
+	}
+	return false
+}
+
+// MountEtcdDataDisk formats the etcd data disk of the master VM unless it already has a file system
+// and mounts it on the etcd data directory over SSH
+func (kmn *UpgradeMasterNode) MountEtcdDataDisk(ctx context.Context, vmName string) error {
+	if kmn.SSHPrivateKeyPath == "" {
+		return errors.Errorf("an SSH private key is required to mount the etcd data disk of master VM %s", vmName)
+	}
+	executeRemote := ssh.ExecuteRemote
+	if kmn.executeRemote != nil {
+		executeRemote = kmn.executeRemote
+	}
+	kmn.logger.Infof("Mounting the etcd data disk of master VM %s on %s", vmName, etcdDataDirectory)
+	script := "bash -c " + shellCommand([]string{etcdDataDiskMountScript})
+	if out, err := executeRemote(ctx, kmn.etcdBackupHost(vmName), script); err != nil {
+		return errors.Wrapf(err, "mounting the etcd data disk of master VM %s: %s", vmName, out)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Etcd data disk tests", func() {
+	var (
+		kmn     *UpgradeMasterNode
+		scripts []string
+		hosts   []string
+	)
+
+	BeforeEach(func() {
+		scripts, hosts = nil, nil
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.AttachEtcdDataDisk = true
+		kmn.SSHPrivateKeyPath = "/home/azureuser/.ssh/id_rsa"
+		kmn.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			scripts = append(scripts, script)
+			hosts = append(hosts, host.URI+" via "+host.Jumpbox.URI)
+			return "", nil
+		}
+	})
+
+	It("Should attach a new etcd data disk to master VM resources only", func() {
+		vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+		resourceProperties(vm)["storageProfile"] = map[string]interface{}{
+			"dataDisks": []interface{}{
+				map[string]interface{}{"lun": float64(0), "name": "etcddisk", "createOption": "attach"},
+				map[string]interface{}{"lun": float64(1), "name": "datadisk1", "createOption": "Empty"},
+			},
+		}
+		vm["zones"] = []interface{}{"2"}
+		kmn.EtcdDataDiskSizeGB = 512
+
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(kmn.TemplateMap["variables"]).To(HaveKeyWithValue("masterEtcdDataDiskSizeGB", 512))
+		disks := masterResources(kmn.TemplateMap, diskResourceType)
+		Expect(disks).To(HaveLen(1))
+		Expect(disks[0]["name"]).To(Equal("[concat(variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')), '-etcddatadisk')]"))
+		Expect(disks[0]["zones"]).To(Equal([]interface{}{"2"}))
+		Expect(resourceProperties(disks[0])["diskSizeGB"]).To(Equal("[variables('masterEtcdDataDiskSizeGB')]"))
+		Expect(disks[0]).NotTo(HaveKey("sku"))
+
+		Expect(vm["dependsOn"]).To(ConsistOf("[concat('Microsoft.Compute/disks/', variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')), '-etcddatadisk')]"))
+		dataDisks := resourceProperties(vm)["storageProfile"].(map[string]interface{})["dataDisks"].([]interface{})
+		Expect(dataDisks).To(HaveLen(2))
+		Expect(dataDisks[0]).To(Equal(map[string]interface{}{
+			"lun":          0,
+			"name":         "[concat(variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')), '-etcddatadisk')]",
+			"createOption": "Attach",
+			"managedDisk": map[string]interface{}{
+				"id": "[resourceId('Microsoft.Compute/disks', concat(variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')), '-etcddatadisk'))]",
+			},
+		}))
+		Expect(dataDisks[1]).To(HaveKeyWithValue("name", "datadisk1"))
+		Expect(ValidateDependencyCycles(templateDependencies(kmn.TemplateMap))).To(Succeed())
+
+		agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+		Expect(resourceProperties(agent)).NotTo(HaveKey("storageProfile"))
+	})
+
+	It("Should add the etcd data disk once and follow the zone of the next master VM", func() {
+		vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+		vm["zones"] = []interface{}{"1"}
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		vm["zones"] = []interface{}{"2"}
+		Expect(kmn.CreateNode(context.Background(), "master", 1)).To(Succeed())
+
+		disks := masterResources(kmn.TemplateMap, diskResourceType)
+		Expect(disks).To(HaveLen(1))
+		Expect(disks[0]["zones"]).To(Equal([]interface{}{"2"}))
+		Expect(vm["dependsOn"]).To(HaveLen(1))
+	})
+
+	It("Should use the etcd disk size of the api model by default", func() {
+		kmn.UpgradeContainerService.Properties.OrchestratorProfile.KubernetesConfig.EtcdDiskSizeGB = "128"
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(kmn.TemplateMap["variables"]).To(HaveKeyWithValue("masterEtcdDataDiskSizeGB", 128))
+	})
+
+	It("Should create the etcd data disk with the OS disk storage account type", func() {
+		kmn.OSDiskStorageAccountType = "StandardSSD_LRS"
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		disks := masterResources(kmn.TemplateMap, diskResourceType)
+		Expect(disks[0]["sku"]).To(Equal(map[string]interface{}{"name": "[variables('masterOSDiskStorageAccountType')]"}))
+	})
+
+	It("Should mount the etcd data disk of the new master VM over SSH", func() {
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(scripts).To(HaveLen(1))
+		Expect(scripts[0]).To(HavePrefix("bash -c "))
+		Expect(scripts[0]).To(ContainSubstring("disk=/dev/disk/azure/scsi1/lun0 dir=/var/lib/etcddisk"))
+		Expect(scripts[0]).To(ContainSubstring("mkfs.ext4"))
+		vmName := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + "0"
+		Expect(hosts).To(ConsistOf(vmName + " via " + kmn.UpgradeContainerService.Properties.MasterProfile.FQDN))
+	})
+
+	It("Should fail when the etcd data disk cannot be mounted", func() {
+		kmn.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			return "/dev/disk/azure/scsi1/lun0 not found", errors.New("executing script")
+		}
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		vmName := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + "0"
+		Expect(err.Error()).To(ContainSubstring("mounting the etcd data disk of master VM " + vmName + ": /dev/disk/azure/scsi1/lun0 not found"))
+	})
+
+	It("Should leave the template untouched when not set", func() {
+		kmn.AttachEtcdDataDisk = false
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(masterResources(kmn.TemplateMap, diskResourceType)).To(BeEmpty())
+		Expect(kmn.TemplateMap["variables"]).NotTo(HaveKey("masterEtcdDataDiskSizeGB"))
+		Expect(scripts).To(BeEmpty())
+	})
+
+	It("Should fail preflight without an SSH private key", func() {
+		kmn.SSHPrivateKeyPath = ""
+
+		Expect(kmn.Preflight(context.Background())).To(MatchError("an SSH private key is required to mount the etcd data disk of the master VMs"))
+	})
+
+	It("Should fail preflight with an invalid disk size", func() {
+		kmn.EtcdDataDiskSizeGB = 40000
+
+		Expect(kmn.Preflight(context.Background())).To(MatchError("invalid etcd data disk size 40000 GB, expected 1 to 32767 GB"))
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+	})
+
+	It("Should fail preflight with a disk size but no etcd data disk", func() {
+		kmn.AttachEtcdDataDisk = false
+		kmn.EtcdDataDiskSizeGB = 512
+
+		Expect(kmn.Preflight(context.Background())).To(MatchError("an etcd data disk size requires attaching an etcd data disk"))
+	})
+})
@@ -0,0 +1,184 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	"github.com/pkg/errors"
+)
+
+// etcdMember is a member of the etcd cluster as listed by etcdctl member list -w json
+type etcdMember struct {
+	ID         uint64   `json:"ID"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+}
+
+// started reports whether the member has joined the cluster, members added but not started yet have no name
+func (m etcdMember) started() bool {
+	return m.Name != ""
+}
+
+// removedEtcdMember is an etcd member removed by RemoveEtcdMember and the client URLs of the members left
+type removedEtcdMember struct {
+	peerURLs  []string
+	endpoints []string
+}
+
+// RemoveEtcdMember removes the etcd member of the master VM from the etcd cluster before the VM is deleted,
+// once the other started members still make a quorum of the MasterProfile.Count members.
+// The member is recorded for AddEtcdMember to add it back once the upgraded VM has joined the cluster.
+func (kmn *UpgradeMasterNode) RemoveEtcdMember(ctx context.Context, vmName string) error {
+	if !kmn.LiveEtcdMemberMigration {
+		return nil
+	}
+	if kmn.SSHPrivateKeyPath == "" {
+		return errors.Errorf("an SSH private key is required to migrate the etcd member of master VM %s", vmName)
+	}
+	members, err := kmn.listEtcdMembers(ctx, vmName, "")
+	if err != nil {
+		return err
+	}
+	var member *etcdMember
+	var remaining []etcdMember
+	for i := range members {
+		if members[i].Name == vmName {
+			member = &members[i]
+		} else {
+			remaining = append(remaining, members[i])
+		}
+	}
+	if member == nil {
+		kmn.logger.Infof("Master VM %s is not a member of the etcd cluster, skipping its removal", vmName)
+		return nil
+	}
+	if err = kmn.checkEtcdQuorum(remaining, "removing the etcd member of master VM "+vmName); err != nil {
+		return err
+	}
+
+	kmn.logger.Infof("Removing the etcd member %x of master VM %s", member.ID, vmName)
+	if _, err = kmn.etcdctl(ctx, vmName, "", "member remove "+strconv.FormatUint(member.ID, 16)); err != nil {
+		return err
+	}
+	if kmn.removedEtcdMembers == nil {
+		kmn.removedEtcdMembers = map[string]removedEtcdMember{}
+	}
+	kmn.removedEtcdMembers[vmName] = removedEtcdMember{
+		peerURLs:  member.PeerURLs,
+		endpoints: clientURLs(remaining),
+	}
+	return nil
+}
+
+// AddEtcdMember adds the etcd member of the upgraded master VM back to the etcd cluster after the VM joins,
+// with the peer URLs of the member RemoveEtcdMember removed, once the started members make a quorum
+// of the MasterProfile.Count members
+func (kmn *UpgradeMasterNode) AddEtcdMember(ctx context.Context, vmName string) error {
+	if !kmn.LiveEtcdMemberMigration {
+		return nil
+	}
+	removed, ok := kmn.removedEtcdMembers[vmName]
+	if !ok {
+		kmn.logger.Infof("The etcd member of master VM %s was not removed by this upgrade, skipping its addition", vmName)
+		return nil
+	}
+	endpoints := strings.Join(removed.endpoints, ",")
+	members, err := kmn.listEtcdMembers(ctx, vmName, endpoints)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Name == vmName || urlsOverlap(m.PeerURLs, removed.peerURLs) {
+			kmn.logger.Infof("Master VM %s is already a member of the etcd cluster", vmName)
+			delete(kmn.removedEtcdMembers, vmName)
+			return nil
+		}
+	}
+	if err = kmn.checkEtcdQuorum(members, "adding the etcd member of master VM "+vmName); err != nil {
+		return err
+	}
+
+	kmn.logger.Infof("Adding the etcd member of master VM %s with peer URLs %s", vmName, strings.Join(removed.peerURLs, ","))
+	if _, err = kmn.etcdctl(ctx, vmName, endpoints, "member add "+vmName+" --peer-urls="+strings.Join(removed.peerURLs, ",")); err != nil {
+		return err
+	}
+	delete(kmn.removedEtcdMembers, vmName)
+
+	members, err = kmn.listEtcdMembers(ctx, vmName, endpoints)
+	if err != nil {
+		return err
+	}
+	return kmn.checkEtcdQuorum(members, "adding the etcd member of master VM "+vmName)
+}
+
+// checkEtcdQuorum ensures at least (N+1)/2 of members are started, N being the expected master count
+func (kmn *UpgradeMasterNode) checkEtcdQuorum(members []etcdMember, step string) error {
+	expected := kmn.UpgradeContainerService.Properties.MasterProfile.Count
+	started := 0
+	for _, m := range members {
+		if m.started() {
+			started++
+		}
+	}
+	if quorum := (expected + 1) / 2; started < quorum {
+		return errors.Errorf("%s would leave %d started etcd members, less than the quorum of %d of %d members", step, started, quorum, expected)
+	}
+	return nil
+}
+
+// listEtcdMembers lists the members of the etcd cluster from the master VM through endpoints, its local member if empty
+func (kmn *UpgradeMasterNode) listEtcdMembers(ctx context.Context, vmName, endpoints string) ([]etcdMember, error) {
+	out, err := kmn.etcdctl(ctx, vmName, endpoints, "member list -w json")
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Members []etcdMember `json:"members"`
+	}
+	if err = json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, errors.Wrapf(err, "parsing the etcd members listed on master VM %s", vmName)
+	}
+	return list.Members, nil
+}
+
+// etcdctl runs an etcdctl command on the master VM through endpoints, its local member if empty
+func (kmn *UpgradeMasterNode) etcdctl(ctx context.Context, vmName, endpoints, command string) (string, error) {
+	if endpoints == "" {
+		endpoints = "https://127.0.0.1:2379"
+	}
+	executeRemote := ssh.ExecuteRemote
+	if kmn.executeRemote != nil {
+		executeRemote = kmn.executeRemote
+	}
+	out, err := executeRemote(ctx, kmn.etcdBackupHost(vmName), etcdctlCommand+" --endpoints="+endpoints+" "+command)
+	if err != nil {
+		return "", errors.Wrapf(err, "running etcdctl %s on master VM %s: %s", command, vmName, out)
+	}
+	return out, nil
+}
+
+func clientURLs(members []etcdMember) []string {
+	var urls []string
+	for _, m := range members {
+		urls = append(urls, m.ClientURLs...)
+	}
+	return urls
+}
+
+func urlsOverlap(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Etcd member migration tests", func() {
+	var (
+		kmn     *UpgradeMasterNode
+		members []etcdMember
+		scripts []string
+		vmName  = "k8s-master-12345678-0"
+	)
+
+	member := func(id uint64, index string) etcdMember {
+		return etcdMember{
+			ID:         id,
+			Name:       "k8s-master-12345678-" + index,
+			PeerURLs:   []string{"https://10.255.255.1" + index + ":2380"},
+			ClientURLs: []string{"https://10.255.255.1" + index + ":2379"},
+		}
+	}
+
+	BeforeEach(func() {
+		scripts = nil
+		members = []etcdMember{member(0xa1, "0"), member(0xb2, "1"), member(0xc3, "2")}
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.LiveEtcdMemberMigration = true
+		kmn.SSHPrivateKeyPath = "/home/azureuser/.ssh/id_rsa"
+		kmn.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			scripts = append(scripts, host.URI+": "+strings.TrimPrefix(script, etcdctlCommand+" "))
+			switch {
+			case strings.HasSuffix(script, "member list -w json"):
+				out, err := json.Marshal(map[string]interface{}{"members": members})
+				return string(out), err
+			case strings.HasSuffix(script, "member remove a1"):
+				members = members[1:]
+			case strings.Contains(script, "member add "):
+				// the added member has no name until it starts
+				members = append(members, etcdMember{ID: 0xd4, PeerURLs: []string{"https://10.255.255.10:2380"}})
+			}
+			return "", nil
+		}
+	})
+
+	It("Should not migrate the etcd member unless enabled", func() {
+		kmn.LiveEtcdMemberMigration = false
+
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(Succeed())
+		Expect(kmn.AddEtcdMember(context.Background(), vmName)).To(Succeed())
+		Expect(scripts).To(BeEmpty())
+	})
+
+	It("Should remove the etcd member before the VM is deleted and add it back after the VM joins", func() {
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(Succeed())
+		Expect(members).To(HaveLen(2))
+		Expect(kmn.AddEtcdMember(context.Background(), vmName)).To(Succeed())
+
+		Expect(scripts).To(Equal([]string{
+			vmName + ": --endpoints=https://127.0.0.1:2379 member list -w json",
+			vmName + ": --endpoints=https://127.0.0.1:2379 member remove a1",
+			vmName + ": --endpoints=https://10.255.255.11:2379,https://10.255.255.12:2379 member list -w json",
+			vmName + ": --endpoints=https://10.255.255.11:2379,https://10.255.255.12:2379 member add " + vmName + " --peer-urls=https://10.255.255.10:2380",
+			vmName + ": --endpoints=https://10.255.255.11:2379,https://10.255.255.12:2379 member list -w json",
+		}))
+		Expect(kmn.removedEtcdMembers).To(BeEmpty())
+	})
+
+	It("Should not add the etcd member again", func() {
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(Succeed())
+		members = append(members, etcdMember{ID: 0xd4, PeerURLs: []string{"https://10.255.255.10:2380"}})
+		scripts = nil
+
+		Expect(kmn.AddEtcdMember(context.Background(), vmName)).To(Succeed())
+		Expect(scripts).To(HaveLen(1))
+	})
+
+	It("Should skip the master VMs that are not etcd members", func() {
+		members = members[1:]
+
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(Succeed())
+		Expect(kmn.AddEtcdMember(context.Background(), vmName)).To(Succeed())
+		Expect(scripts).To(HaveLen(1))
+	})
+
+	It("Should not remove the etcd member if the remaining members would lose the quorum", func() {
+		members[1].Name = ""
+
+		err := kmn.RemoveEtcdMember(context.Background(), vmName)
+		Expect(err).To(MatchError("removing the etcd member of master VM " + vmName + " would leave 1 started etcd members, less than the quorum of 2 of 3 members"))
+		Expect(members).To(HaveLen(3))
+	})
+
+	It("Should not add the etcd member if the started members do not make a quorum", func() {
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(Succeed())
+		members[0].Name = ""
+
+		err := kmn.AddEtcdMember(context.Background(), vmName)
+		Expect(err).To(MatchError("adding the etcd member of master VM " + vmName + " would leave 1 started etcd members, less than the quorum of 2 of 3 members"))
+		Expect(members).To(HaveLen(2))
+	})
+
+	It("Should fail on etcdctl errors", func() {
+		kmn.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			return "context deadline exceeded", errors.New("exit status 1")
+		}
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(MatchError("running etcdctl member list -w json on master VM " + vmName + ": context deadline exceeded: exit status 1"))
+
+		kmn.SSHPrivateKeyPath = ""
+		Expect(kmn.RemoveEtcdMember(context.Background(), vmName)).To(MatchError("an SSH private key is required to migrate the etcd member of master VM " + vmName))
+	})
+})
@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"time"
+)
+
+// UpgradeEventType identifies the kind of step an UpgradeEvent describes
+type UpgradeEventType string
+
+const (
+	// NodeUpgradedEvent is reported after a node completed its drain, delete and create cycle
+	NodeUpgradedEvent UpgradeEventType = "NodeUpgraded"
+	// NodeDrainingEvent, NodeDeletingEvent, NodeCreatingEvent and NodeValidatingEvent are reported
+	// when a node enters the corresponding step of its upgrade
+	NodeDrainingEvent   UpgradeEventType = "NodeDraining"
+	NodeDeletingEvent   UpgradeEventType = "NodeDeleting"
+	NodeCreatingEvent   UpgradeEventType = "NodeCreating"
+	NodeValidatingEvent UpgradeEventType = "NodeValidating"
+	// NodeUpgradeFailedEvent is reported when an agent node failed to upgrade
+	NodeUpgradeFailedEvent UpgradeEventType = "NodeUpgradeFailed"
+	// NodeSkippedEvent is reported when an agent node is left out of the upgrade, to be upgraded manually
+	NodeSkippedEvent UpgradeEventType = "NodeSkipped"
+	// CanaryCompletedEvent is reported with CanaryUpgrade once the first node of a pool is upgraded
+	CanaryCompletedEvent UpgradeEventType = "CanaryCompleted"
+	// UpgradeStartedEvent is reported when the upgrade operation starts
+	UpgradeStartedEvent UpgradeEventType = "UpgradeStarted"
+	// UpgradeCompletedEvent is reported when the upgrade operation succeeded
+	UpgradeCompletedEvent UpgradeEventType = "UpgradeCompleted"
+	// UpgradeFailedEvent is reported when the upgrade operation failed
+	UpgradeFailedEvent UpgradeEventType = "UpgradeFailed"
+)
+
+// UpgradeEvent describes a completed step of the upgrade operation
+type UpgradeEvent struct {
+	Type     UpgradeEventType
+	Time     time.Time
+	PoolName string
+	NodeName string
+	// Duration is the wall time of the whole drain, delete and create cycle
+	Duration time.Duration
+	// DrainDuration is the part of Duration spent cordoning and draining the node
+	DrainDuration time.Duration
+	// Message describes the event, e.g. the error of a failed node
+	Message string
+	// Nodes lists the nodes to upgrade, set on UpgradeStartedEvent
+	Nodes []string
+	// SkippedPools lists the agent pools excluded from the upgrade, set on UpgradeStartedEvent
+	SkippedPools []string
+	// SkippedNodes lists the nodes left out of the upgrade, set on UpgradeCompletedEvent
+	SkippedNodes []string
+}
+
+// UpgradeReporter publishes upgrade events to an external system
+type UpgradeReporter interface {
+	Report(event UpgradeEvent) error
+}
+
+// reportEvent hands the event to every configured reporter.
+// Reporting is best effort and never fails the upgrade.
+func (ku *Upgrader) reportEvent(event UpgradeEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	for _, reporter := range ku.Reporters {
+		if err := reporter.Report(event); err != nil {
+			ku.logger.Warningf("Error reporting upgrade event %s for node %s: %v", event.Type, event.NodeName, err)
+		}
+	}
+}
+
+// reportNodePhase reports that a node entered a step of its upgrade
+func (ku *Upgrader) reportNodePhase(eventType UpgradeEventType, poolName, nodeName string) {
+	ku.reportEvent(UpgradeEvent{
+		Type:     eventType,
+		PoolName: poolName,
+		NodeName: nodeName,
+	})
+}
+
+// nodesToUpgrade returns the names of the master and agent nodes the upgrade replaces
+func (ku *Upgrader) nodesToUpgrade() []string {
+	var nodes []string
+	if ku.ClusterTopology.MasterVMs != nil {
+		for _, vm := range *ku.ClusterTopology.MasterVMs {
+			nodes = append(nodes, *vm.Name)
+		}
+	}
+	if ku.ControlPlaneOnly {
+		return nodes
+	}
+	for _, vmss := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
+		for _, vm := range vmss.VMsToUpgrade {
+			nodes = append(nodes, vm.Name)
+		}
+	}
+	for _, pool := range ku.ClusterTopology.AgentPools {
+		if pool.AgentVMs == nil {
+			continue
+		}
+		for _, vm := range *pool.AgentVMs {
+			nodes = append(nodes, *vm.Name)
+		}
+	}
+	return nodes
+}
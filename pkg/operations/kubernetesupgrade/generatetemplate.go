@@ -0,0 +1,82 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/pkg/errors"
+)
+
+// WriteTemplate applies the UpgradeMasterNode options to the upgrade template, as CreateNode does before deploying
+// the master VM with index masterNo, and writes the template and its parameters file to dir as DefaultTemplateFileName
+// and DefaultParametersFileName instead of deploying them. The other master VMs are deployed with the same template,
+// only the masterOffset and masterCount variables differ.
+func (kmn *UpgradeMasterNode) WriteTemplate(ctx context.Context, masterNo int, dir string) error {
+	if err := kmn.prepareTemplate(ctx, masterNo); err != nil {
+		return err
+	}
+	templateJSON, err := helpers.JSONMarshal(kmn.TemplateMap, false)
+	if err != nil {
+		return errors.Wrap(err, "marshaling the upgrade template")
+	}
+	template, err := transform.PrettyPrintArmTemplate(string(templateJSON))
+	if err != nil {
+		return errors.Wrap(err, "pretty-printing the upgrade template")
+	}
+	parametersJSON, err := helpers.JSONMarshal(kmn.ParametersMap, false)
+	if err != nil {
+		return errors.Wrap(err, "marshaling the upgrade template parameters")
+	}
+	parameters, err := transform.BuildAzureParametersFile(string(parametersJSON))
+	if err != nil {
+		return errors.Wrap(err, "pretty-printing the upgrade template parameters")
+	}
+
+	f := &helpers.FileSaver{Translator: kmn.Translator}
+	if err = f.SaveFileString(dir, DefaultTemplateFileName, template); err != nil {
+		return err
+	}
+	if err = f.SaveFileString(dir, DefaultParametersFileName, parameters); err != nil {
+		return err
+	}
+	kmn.logger.Infof("Wrote the upgrade template and parameters of the master VMs to %s", dir)
+	return nil
+}
+
+// templateOutputDirectory returns TemplateOutputDirectory, the UpgradeOutputDirectoryName sub-directory
+// of OutputDirectory if empty
+func (ku *Upgrader) templateOutputDirectory() string {
+	if ku.TemplateOutputDirectory != "" {
+		return ku.TemplateOutputDirectory
+	}
+	if ku.OutputDirectory == "" {
+		return ""
+	}
+	return filepath.Join(ku.OutputDirectory, UpgradeOutputDirectoryName)
+}
+
+// GenerateTemplate runs the template generation steps and preflight checks of the master upgrade and writes
+// the ARM template and parameters the first master VM would be deployed with to TemplateOutputDirectory,
+// without deploying them or changing any node
+func (ku *Upgrader) GenerateTemplate(ctx context.Context) error {
+	if ku.ClusterTopology.DataModel.Properties.MasterProfile == nil {
+		return errors.New("generating the upgrade template requires a master profile")
+	}
+	dir := ku.templateOutputDirectory()
+	if dir == "" {
+		return errors.New("generating the upgrade template requires TemplateOutputDirectory or OutputDirectory")
+	}
+	upgradeMasterNode, err := ku.newUpgradeMasterNode(ctx)
+	if err != nil {
+		return err
+	}
+	if err = upgradeMasterNode.Preflight(ctx); err != nil {
+		return ku.Translator.Errorf("master upgrade preflight check failed: %s", err.Error())
+	}
+	return upgradeMasterNode.WriteTemplate(ctx, 0, dir)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Generate upgrade template tests", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "upgradetemplate")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		os.RemoveAll("./translations")
+	})
+
+	It("Should write the customized template and parameters of a master VM", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailDeployTemplate: true})
+		kmn.ParametersMap = map[string]interface{}{"location": map[string]interface{}{"value": "eastus"}}
+		kmn.VMAPIVersion = "2020-06-01"
+
+		Expect(kmn.WriteTemplate(context.Background(), 1, dir)).To(Succeed())
+
+		templateMap, parametersMap, err := (&LocalFileTemplateSource{Dir: dir}).Load(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templateMap["variables"]).To(HaveKeyWithValue("masterOffset", 1.0))
+		Expect(templateMap["variables"]).To(HaveKeyWithValue("masterCount", 2.0))
+		Expect(masterResources(templateMap, vmResourceType)[0]["apiVersion"]).To(Equal("2020-06-01"))
+		Expect(parametersMap).To(Equal(map[string]interface{}{"location": map[string]interface{}{"value": "eastus"}}))
+
+		parametersJSON, err := ioutil.ReadFile(filepath.Join(dir, DefaultParametersFileName))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(parametersJSON)).To(ContainSubstring(`"contentVersion": "1.0.0.0"`))
+		Expect(kmn.deploymentNames).To(BeEmpty())
+	})
+
+	It("Should not write the template when an option is invalid", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.VMAPIVersion = "latest"
+
+		Expect(kmn.WriteTemplate(context.Background(), 1, dir)).NotTo(Succeed())
+		_, err := os.Stat(filepath.Join(dir, DefaultTemplateFileName))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("Should write the upgrade template to the upgrade directory without upgrading any node", func() {
+		reporter := &fakeReporter{}
+		mockClient := armhelpers.MockAKSEngineClient{
+			FailDeployTemplate:       true,
+			FailDeleteVirtualMachine: true,
+		}
+		uc := UpgradeCluster{
+			Translator:           &i18n.Translator{},
+			Logger:               log.NewEntry(log.New()),
+			Reporters:            []UpgradeReporter{reporter},
+			GenerateTemplateOnly: true,
+			OutputDirectory:      dir,
+		}
+		uc.Client = &mockClient
+		uc.ClusterTopology = ClusterTopology{}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = api.CreateMockContainerService("testcluster", "", 1, 1, false)
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+
+		Expect(uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(filepath.Join(dir, UpgradeOutputDirectoryName, DefaultTemplateFileName)).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, UpgradeOutputDirectoryName, DefaultParametersFileName)).To(BeAnExistingFile())
+		Expect(reporter.events).To(BeEmpty())
+	})
+
+	It("Should write the upgrade template to TemplateOutputDirectory", func() {
+		u := &Upgrader{TemplateOutputDirectory: dir, OutputDirectory: "_output/testcluster"}
+		Expect(u.templateOutputDirectory()).To(Equal(dir))
+		u.TemplateOutputDirectory = ""
+		Expect(u.templateOutputDirectory()).To(Equal(filepath.Join("_output/testcluster", UpgradeOutputDirectoryName)))
+		u.OutputDirectory = ""
+		Expect(u.templateOutputDirectory()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// upgradeHookTimeout bounds the execution of each upgrade hook
+	upgradeHookTimeout = time.Minute * 30
+
+	// Environment variables passed to the upgrade hooks
+	hookEnvClusterName    = "AKS_ENGINE_CLUSTER_NAME"
+	hookEnvResourceGroup  = "AKS_ENGINE_RESOURCE_GROUP"
+	hookEnvSubscriptionID = "AKS_ENGINE_SUBSCRIPTION_ID"
+	hookEnvLocation       = "AKS_ENGINE_LOCATION"
+	hookEnvCurrentVersion = "AKS_ENGINE_CURRENT_VERSION"
+	hookEnvTargetVersion  = "AKS_ENGINE_TARGET_VERSION"
+)
+
+// runUpgradeHook executes the hook command, if any, with the upgrade context in its environment.
+// The error holds the hook output when the command exits with a non-zero status.
+func (ku *Upgrader) runUpgradeHook(name string, hook []string) error {
+	if len(hook) == 0 {
+		return nil
+	}
+	ku.logger.Infof("Running %s hook: %s", name, strings.Join(hook, " "))
+	ctx, cancel := context.WithTimeout(context.Background(), upgradeHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook[0], hook[1:]...)
+	cmd.Env = append(os.Environ(), ku.upgradeHookEnv()...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s hook %s failed: %s", name, hook[0], strings.TrimSpace(string(out)))
+	}
+	ku.logger.Infof("%s hook output: %s", name, strings.TrimSpace(string(out)))
+	return nil
+}
+
+// upgradeHookEnv returns the environment variables describing the upgrade to the hooks.
+func (ku *Upgrader) upgradeHookEnv() []string {
+	clusterName := ku.DataModel.Name
+	if ku.DataModel.Properties.MasterProfile != nil && ku.DataModel.Properties.MasterProfile.DNSPrefix != "" {
+		clusterName = ku.DataModel.Properties.MasterProfile.DNSPrefix
+	}
+	return []string{
+		hookEnvClusterName + "=" + clusterName,
+		hookEnvResourceGroup + "=" + ku.ClusterTopology.ResourceGroup,
+		hookEnvSubscriptionID + "=" + ku.ClusterTopology.SubscriptionID,
+		hookEnvLocation + "=" + ku.DataModel.Location,
+		hookEnvCurrentVersion + "=" + ku.CurrentVersion,
+		hookEnvTargetVersion + "=" + ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion,
+	}
+}
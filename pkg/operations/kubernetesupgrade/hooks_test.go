@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade hook tests", func() {
+	var u *Upgrader
+
+	BeforeEach(func() {
+		u = newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+		u.ClusterTopology.ResourceGroup = "TestRg"
+		u.CurrentVersion = "1.17.11"
+	})
+
+	It("Should pass the upgrade context to the hook environment", func() {
+		dir, err := ioutil.TempDir("", "upgradehook")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		out := filepath.Join(dir, "env")
+
+		hook := []string{"sh", "-c", "echo $AKS_ENGINE_RESOURCE_GROUP $AKS_ENGINE_CURRENT_VERSION $AKS_ENGINE_TARGET_VERSION > " + out}
+		Expect(u.runUpgradeHook("post-upgrade", hook)).To(Succeed())
+
+		env, err := ioutil.ReadFile(out)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(env)).To(Equal("TestRg 1.17.11 1.18.8\n"))
+	})
+
+	It("Should return the hook output when the hook fails", func() {
+		err := u.runUpgradeHook("pre-upgrade", []string{"sh", "-c", "echo certificates not rotated; exit 3"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pre-upgrade hook sh failed: certificates not rotated"))
+	})
+
+	It("Should do nothing without a hook", func() {
+		Expect(u.runUpgradeHook("pre-upgrade", nil)).To(Succeed())
+	})
+
+	It("Should abort the upgrade when the pre-upgrade hook fails", func() {
+		u.PreUpgradeHook = []string{"false"}
+		err := u.RunUpgrade()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pre-upgrade hook false failed"))
+	})
+})
@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/pkg/errors"
+)
+
+const userAssignedIdentityResourceType = "Microsoft.ManagedIdentity/userAssignedIdentities"
+
+// hasIdentity returns true if the new master VMs are assigned a managed identity
+func (kmn *UpgradeMasterNode) hasIdentity() bool {
+	return kmn.SystemAssignedIdentity || len(kmn.UserAssignedIdentities) > 0
+}
+
+// validateIdentities ensures the user-assigned identities are user-assigned identity resource IDs
+func (kmn *UpgradeMasterNode) validateIdentities() error {
+	for _, id := range kmn.UserAssignedIdentities {
+		if _, err := utils.ResourceGroupName(id); err != nil {
+			return errors.Wrapf(err, "parsing user-assigned identity ID %s", id)
+		}
+		if !strings.Contains(strings.ToLower(id), strings.ToLower(userAssignedIdentityResourceType+"/")) {
+			return errors.Errorf("%s is not the resource ID of a user-assigned identity", id)
+		}
+	}
+	return nil
+}
+
+// addIdentity adds the identities to the identity block of a master VM resource.
+// The identities the template already assigns, e.g. the cluster managed identity, are kept.
+func (kmn *UpgradeMasterNode) addIdentity(vm map[string]interface{}) {
+	identity, ok := vm["identity"].(map[string]interface{})
+	if !ok {
+		identity = map[string]interface{}{}
+		vm["identity"] = identity
+	}
+	current, _ := identity["type"].(string)
+	systemAssigned := kmn.SystemAssignedIdentity || strings.Contains(current, string(compute.ResourceIdentityTypeSystemAssigned))
+	userAssigned := len(kmn.UserAssignedIdentities) > 0 || strings.Contains(current, string(compute.ResourceIdentityTypeUserAssigned))
+	switch {
+	case systemAssigned && userAssigned:
+		identity["type"] = string(compute.ResourceIdentityTypeSystemAssignedUserAssigned)
+	case userAssigned:
+		identity["type"] = string(compute.ResourceIdentityTypeUserAssigned)
+	default:
+		identity["type"] = string(compute.ResourceIdentityTypeSystemAssigned)
+	}
+	if len(kmn.UserAssignedIdentities) == 0 {
+		return
+	}
+	userAssignedIdentities, ok := identity["userAssignedIdentities"].(map[string]interface{})
+	if !ok {
+		userAssignedIdentities = map[string]interface{}{}
+		identity["userAssignedIdentities"] = userAssignedIdentities
+	}
+	for _, id := range kmn.UserAssignedIdentities {
+		userAssignedIdentities[id] = map[string]interface{}{}
+	}
+}
+
+// VerifyIdentity checks that the master VM vmName was created with the identities set on the UpgradeMasterNode.
+func (kmn *UpgradeMasterNode) VerifyIdentity(ctx context.Context, vmName string) error {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return errors.Wrapf(err, "getting VM %s", vmName)
+	}
+	identity := vm.Identity
+	if identity == nil {
+		identity = &compute.VirtualMachineIdentity{}
+	}
+	if kmn.SystemAssignedIdentity && !strings.Contains(string(identity.Type), string(compute.ResourceIdentityTypeSystemAssigned)) {
+		return errors.Errorf("VM %s has no system-assigned identity, identity type is %q", vmName, identity.Type)
+	}
+	assigned := map[string]bool{}
+	for id := range identity.UserAssignedIdentities {
+		assigned[strings.ToLower(id)] = true
+	}
+	var missing []string
+	for _, id := range kmn.UserAssignedIdentities {
+		if !assigned[strings.ToLower(id)] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("VM %s is missing the user-assigned identities %s", vmName, strings.Join(missing, ", "))
+	}
+	return nil
+}
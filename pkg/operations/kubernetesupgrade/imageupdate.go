@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// osImageVersionParameterSuffix is the suffix of the template parameters holding the OS image version of the master
+// VMs, osImageVersion, and of the Linux VMs of each agent pool, <pool name>osImageVersion
+const osImageVersionParameterSuffix = "osImageVersion"
+
+// GetLatestVMImage returns the latest version of the publisher:offer:sku marketplace VM image
+// available in the location of the cluster
+func (ku *Upgrader) GetLatestVMImage(ctx context.Context, publisher, offer, sku string) (string, error) {
+	fetcher, ok := ku.Client.(armhelpers.VMImageFetcher)
+	if !ok {
+		return "", errors.New("the ARM client cannot list VM images")
+	}
+	location := ku.ClusterTopology.DataModel.Location
+	list, err := fetcher.ListVirtualMachineImages(ctx, location, publisher, offer, sku)
+	if err != nil {
+		return "", errors.Wrapf(err, "listing the versions of VM image %s:%s:%s", publisher, offer, sku)
+	}
+	var latest string
+	if list.Value != nil {
+		for _, image := range *list.Value {
+			if version := to.String(image.Name); latest == "" || compareImageVersions(version, latest) > 0 {
+				latest = version
+			}
+		}
+	}
+	if latest == "" {
+		return "", errors.Errorf("no version of VM image %s:%s:%s found in %s", publisher, offer, sku, location)
+	}
+	return latest, nil
+}
+
+// updateOSImageVersions sets the OS image version parameters of the marketplace images of the upgrade template
+// to the latest version of their image, custom images are left untouched
+func (ku *Upgrader) updateOSImageVersions(ctx context.Context, parametersMap map[string]interface{}) error {
+	var names []string
+	for name := range parametersMap {
+		if strings.HasSuffix(name, osImageVersionParameterSuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prefix := strings.TrimSuffix(name, osImageVersionParameterSuffix)
+		if parameterString(parametersMap, prefix+"osImageName") != "" {
+			continue
+		}
+		publisher := parameterString(parametersMap, prefix+"osImagePublisher")
+		offer := parameterString(parametersMap, prefix+"osImageOffer")
+		sku := parameterString(parametersMap, prefix+"osImageSKU")
+		if publisher == "" || offer == "" || sku == "" {
+			continue
+		}
+		image := publisher + ":" + offer + ":" + sku
+		version, ok := ku.latestImageVersions[image]
+		if !ok {
+			var err error
+			if version, err = ku.GetLatestVMImage(ctx, publisher, offer, sku); err != nil {
+				return err
+			}
+			if ku.latestImageVersions == nil {
+				ku.latestImageVersions = map[string]string{}
+			}
+			ku.latestImageVersions[image] = version
+		}
+		if current := parameterString(parametersMap, name); current != version {
+			ku.logger.Infof("Updating VM image %s from version %s to the latest version %s", image, current, version)
+		}
+		parametersMap[name] = map[string]interface{}{
+			"value": version,
+		}
+	}
+	return nil
+}
+
+// parameterString returns the string value of a template parameter, empty if unset
+func parameterString(parametersMap map[string]interface{}, name string) string {
+	parameter, _ := parametersMap[name].(map[string]interface{})
+	value, _ := parameter["value"].(string)
+	return value
+}
+
+// compareImageVersions compares two VM image versions of dot-separated numbers, such as 2021.04.14,
+// returning a negative number, zero or a positive number when a is older, equal to or newer than b
+func compareImageVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		aNumber, aErr := strconv.ParseUint(aPart, 10, 64)
+		bNumber, bErr := strconv.ParseUint(bPart, 10, 64)
+		switch {
+		case aErr == nil && bErr == nil && aNumber != bNumber:
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && aPart != bPart:
+			return strings.Compare(aPart, bPart)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Force image update tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		u          *Upgrader
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{
+			FakeListVirtualMachineImagesResult: func() []compute.VirtualMachineImageResource {
+				return []compute.VirtualMachineImageResource{
+					{Name: to.StringPtr("2021.09.20")},
+					{Name: to.StringPtr("2021.10.05")},
+					{Name: to.StringPtr("2021.9.30")},
+				}
+			},
+		}
+		cs := api.CreateMockContainerService("testcluster", "", 1, 1, false)
+		u = &Upgrader{}
+		u.Init(&i18n.Translator{}, log.NewEntry(log.New()), ClusterTopology{DataModel: cs}, mockClient, "", nil, nil, TestAKSEngineVersion, false)
+	})
+
+	It("Should return the latest version of the VM image", func() {
+		version, err := u.GetLatestVMImage(context.Background(), "microsoft-aks", "aks", "aks-engine-ubuntu-1804-202007")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal("2021.10.05"))
+	})
+
+	It("Should fail when the VM image has no version", func() {
+		mockClient.FakeListVirtualMachineImagesResult = func() []compute.VirtualMachineImageResource {
+			return nil
+		}
+		_, err := u.GetLatestVMImage(context.Background(), "microsoft-aks", "aks", "unknown")
+		Expect(err).To(MatchError("no version of VM image microsoft-aks:aks:unknown found in " + u.ClusterTopology.DataModel.Location))
+	})
+
+	It("Should fail when the VM images cannot be listed", func() {
+		mockClient.FailListVirtualMachineImages = true
+		_, err := u.GetLatestVMImage(context.Background(), "microsoft-aks", "aks", "aks-engine-ubuntu-1804-202007")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("listing the versions of VM image microsoft-aks:aks:aks-engine-ubuntu-1804-202007"))
+	})
+
+	It("Should update the OS image version parameters of marketplace images only", func() {
+		image := func(version string) map[string]interface{} {
+			return map[string]interface{}{
+				"osImagePublisher": map[string]interface{}{"value": "microsoft-aks"},
+				"osImageOffer":     map[string]interface{}{"value": "aks"},
+				"osImageSKU":       map[string]interface{}{"value": "aks-engine-ubuntu-1804-202007"},
+				"osImageVersion":   map[string]interface{}{"value": version},
+			}
+		}
+		parametersMap := image("2021.01.01")
+		for name, value := range image("2021.01.01") {
+			parametersMap["agentpool1"+name] = value
+		}
+		for name, value := range image("2021.01.01") {
+			parametersMap["agentpool2"+name] = value
+		}
+		parametersMap["agentpool2osImageName"] = map[string]interface{}{"value": "customimage"}
+
+		Expect(u.updateOSImageVersions(context.Background(), parametersMap)).To(Succeed())
+		Expect(parametersMap["osImageVersion"]).To(Equal(map[string]interface{}{"value": "2021.10.05"}))
+		Expect(parametersMap["agentpool1osImageVersion"]).To(Equal(map[string]interface{}{"value": "2021.10.05"}))
+		Expect(parametersMap["agentpool2osImageVersion"]).To(Equal(map[string]interface{}{"value": "2021.01.01"}))
+		Expect(u.latestImageVersions).To(Equal(map[string]string{"microsoft-aks:aks:aks-engine-ubuntu-1804-202007": "2021.10.05"}))
+	})
+
+	It("Should update the OS image version of the generated upgrade template", func() {
+		u.ForceImageUpdate = true
+		_, parametersMap, err := u.generateUpgradeTemplate(context.Background(), u.ClusterTopology.DataModel, TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parametersMap["osImageVersion"]).To(Equal(map[string]interface{}{"value": "2021.10.05"}))
+	})
+
+	It("Should compare VM image versions", func() {
+		Expect(compareImageVersions("2021.10.05", "2021.9.30")).To(BeNumerically(">", 0))
+		Expect(compareImageVersions("17763.1879.2104091832", "17763.2114.2108051522")).To(BeNumerically("<", 0))
+		Expect(compareImageVersions("1.0.0", "1.0.0")).To(Equal(0))
+		Expect(compareImageVersions("1.0", "1.0.1")).To(BeNumerically("<", 0))
+	})
+})
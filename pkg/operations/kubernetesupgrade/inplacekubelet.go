@@ -0,0 +1,131 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// kubeNodeBinariesPath is the path of the Kubernetes node binaries archive below KubeBinariesSASURLBase,
+// as downloaded by the node provisioning scripts
+const kubeNodeBinariesPath = "v%s/binaries/kubernetes-node-linux-amd64.tar.gz"
+
+// kubeletReplaceScript downloads the Kubernetes node binaries archive from the URL of its first argument,
+// then stops kubelet, replaces its binary and restarts it
+const kubeletReplaceScript = `set -e
+url=$1 archive=/tmp/kubernetes-node-linux-amd64.tar.gz bin=/usr/local/bin
+if [ -x /opt/bin/kubelet ]; then bin=/opt/bin; fi
+curl -fsSL --retry 5 --retry-delay 5 -o "$archive" "$url"
+sudo tar -xzf "$archive" --strip-components=3 -C /tmp kubernetes/node/bin/kubelet
+sudo systemctl stop kubelet
+sudo install -m 0755 /tmp/kubelet "$bin/kubelet"
+sudo systemctl start kubelet
+sudo rm -f "$archive" /tmp/kubelet`
+
+// kubeBinaryURL returns the URL of the Kubernetes node binaries archive of the target version,
+// the custom kube binary URL of the api model if set
+func (kan *UpgradeAgentNode) kubeBinaryURL() string {
+	orchestratorProfile := kan.UpgradeContainerService.Properties.OrchestratorProfile
+	if kc := orchestratorProfile.KubernetesConfig; kc != nil && kc.CustomKubeBinaryURL != "" {
+		return kc.CustomKubeBinaryURL
+	}
+	base := kan.UpgradeContainerService.GetCloudSpecConfig().KubernetesSpecConfig.KubeBinariesSASURLBase
+	return base + fmt.Sprintf(kubeNodeBinariesPath, orchestratorProfile.OrchestratorVersion)
+}
+
+// UpgradeKubeletInPlace replaces the kubelet binary of the agent node by the one of the target version over SSH,
+// restarts kubelet and validates the node is ready and runs the target kubelet version.
+// Unlike Validate after CreateNode, a node not ready in time is not deleted.
+func (kan *UpgradeAgentNode) UpgradeKubeletInPlace(ctx context.Context, vmName string) error {
+	if kan.SSHPrivateKeyPath == "" {
+		return errors.Errorf("an SSH private key is required to upgrade the kubelet of agent VM %s in place", vmName)
+	}
+	executeRemote := ssh.ExecuteRemote
+	if kan.executeRemote != nil {
+		executeRemote = kan.executeRemote
+	}
+
+	url := kan.kubeBinaryURL()
+	kan.logger.Infof("Replacing the kubelet of agent VM %s with the one of %s", vmName, url)
+	script := "bash -c " + shellCommand([]string{kubeletReplaceScript}) + " kubelet-upgrade " + shellCommand([]string{url})
+	if out, err := executeRemote(ctx, kan.sshHost(vmName), script); err != nil {
+		return errors.Wrapf(err, "replacing the kubelet of agent VM %s: %s", vmName, out)
+	}
+
+	node := *kan
+	node.validateOnly = true
+	if err := node.Validate(&vmName); err != nil {
+		return err
+	}
+	return kan.verifyKubeletVersion(vmName)
+}
+
+// verifyKubeletVersion ensures the agent node reports the kubelet version of the target orchestrator version
+func (kan *UpgradeAgentNode) verifyKubeletVersion(vmName string) error {
+	client, err := kan.Client.GetKubernetesClient(kan.UpgradeContainerService.Properties.MasterProfile.FQDN, kan.kubeConfig, interval, kan.timeout)
+	if err != nil {
+		return err
+	}
+	nodeName := strings.ToLower(vmName)
+	node, err := client.GetNode(nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "getting node %s", nodeName)
+	}
+	expected := "v" + kan.UpgradeContainerService.Properties.OrchestratorProfile.OrchestratorVersion
+	if node.Status.NodeInfo.KubeletVersion != expected {
+		return errors.Errorf("node %s runs kubelet %s after the in-place upgrade, expected %s", nodeName, node.Status.NodeInfo.KubeletVersion, expected)
+	}
+	return nil
+}
+
+// sshHost returns the SSH host of the agent VM, reached through the master FQDN
+func (kan *UpgradeAgentNode) sshHost(vmName string) *ssh.RemoteHost {
+	authConfig := &ssh.AuthConfig{
+		User:           kan.UpgradeContainerService.Properties.LinuxProfile.AdminUsername,
+		PrivateKeyPath: kan.SSHPrivateKeyPath,
+	}
+	return &ssh.RemoteHost{
+		URI:             strings.ToLower(vmName),
+		Port:            22,
+		OperatingSystem: api.Linux,
+		AuthConfig:      authConfig,
+		Jumpbox: &ssh.JumpBox{
+			URI:             kan.UpgradeContainerService.Properties.MasterProfile.FQDN,
+			Port:            22,
+			OperatingSystem: api.Linux,
+			AuthConfig:      authConfig,
+		},
+	}
+}
+
+// validateInPlaceKubeletUpgrade ensures the kubelet of the agent nodes can be upgraded in place,
+// i.e. from a patch release to another of the same minor version
+func (ku *Upgrader) validateInPlaceKubeletUpgrade() error {
+	if !ku.InPlaceKubeletUpgrade {
+		return nil
+	}
+	if ku.SSHPrivateKeyPath == "" {
+		return errors.New("an SSH private key is required to upgrade the kubelet of the agent nodes in place")
+	}
+	target := ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion
+	current, err := semver.ParseTolerant(ku.CurrentVersion)
+	if err != nil {
+		return errors.Wrapf(err, "parsing the current version %s", ku.CurrentVersion)
+	}
+	desired, err := semver.ParseTolerant(target)
+	if err != nil {
+		return errors.Wrapf(err, "parsing the target version %s", target)
+	}
+	if current.Major != desired.Major || current.Minor != desired.Minor {
+		return errors.Errorf("the kubelet can only be upgraded in place to a patch release of the same minor version, not from %s to %s", ku.CurrentVersion, target)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("In-place kubelet upgrade tests", func() {
+	const vmName = "k8s-agentpool1-12345678-0"
+	var (
+		kan            *UpgradeAgentNode
+		kubeletVersion string
+		hosts          []*ssh.RemoteHost
+		scripts        []string
+	)
+
+	BeforeEach(func() {
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.UpgradeContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.18.8"
+		kan.InPlaceKubeletUpgrade = true
+		kan.SSHPrivateKeyPath = "/home/azureuser/.ssh/id_rsa"
+		kan.timeout = time.Second
+		kubeletVersion = "v1.18.8"
+		hosts, scripts = nil, nil
+		kan.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			hosts = append(hosts, host)
+			scripts = append(scripts, script)
+			return "", nil
+		}
+		kan.Client = &armhelpers.MockAKSEngineClient{
+			MockKubernetesClient: &armhelpers.MockKubernetesClient{
+				GetNodeFunc: func(name string) (*v1.Node, error) {
+					node := &v1.Node{}
+					node.Name = name
+					node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+					node.Status.NodeInfo.KubeletVersion = kubeletVersion
+					return node, nil
+				},
+			},
+		}
+	})
+
+	It("Should replace the kubelet through the master FQDN", func() {
+		Expect(kan.UpgradeKubeletInPlace(context.Background(), vmName)).To(Succeed())
+		Expect(hosts).To(HaveLen(1))
+		Expect(hosts[0].URI).To(Equal(vmName))
+		Expect(hosts[0].AuthConfig.User).To(Equal(kan.UpgradeContainerService.Properties.LinuxProfile.AdminUsername))
+		Expect(hosts[0].AuthConfig.PrivateKeyPath).To(Equal("/home/azureuser/.ssh/id_rsa"))
+		Expect(hosts[0].Jumpbox.URI).To(Equal(kan.UpgradeContainerService.Properties.MasterProfile.FQDN))
+		Expect(scripts[0]).To(ContainSubstring("sudo systemctl stop kubelet"))
+		Expect(scripts[0]).To(HaveSuffix(" kubelet-upgrade 'https://kubernetesartifacts.azureedge.net/kubernetes/v1.18.8/binaries/kubernetes-node-linux-amd64.tar.gz'"))
+	})
+
+	It("Should download the custom kube binary URL if set", func() {
+		kan.UpgradeContainerService.Properties.OrchestratorProfile.KubernetesConfig.CustomKubeBinaryURL = "https://example.com/kubernetes-node.tar.gz"
+		Expect(kan.UpgradeKubeletInPlace(context.Background(), vmName)).To(Succeed())
+		Expect(scripts[0]).To(HaveSuffix(" kubelet-upgrade 'https://example.com/kubernetes-node.tar.gz'"))
+	})
+
+	It("Should fail when the kubelet cannot be replaced", func() {
+		kan.executeRemote = func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error) {
+			return "curl: (22) 404", errors.New("Process exited with status 22")
+		}
+		err := kan.UpgradeKubeletInPlace(context.Background(), vmName)
+		Expect(err).To(MatchError("replacing the kubelet of agent VM " + vmName + ": curl: (22) 404: Process exited with status 22"))
+	})
+
+	It("Should fail when the node runs another kubelet version", func() {
+		kubeletVersion = "v1.18.6"
+		err := kan.UpgradeKubeletInPlace(context.Background(), vmName)
+		Expect(err).To(MatchError("node " + vmName + " runs kubelet v1.18.6 after the in-place upgrade, expected v1.18.8"))
+	})
+
+	It("Should require an SSH private key", func() {
+		kan.SSHPrivateKeyPath = ""
+		Expect(kan.UpgradeKubeletInPlace(context.Background(), vmName)).NotTo(Succeed())
+		Expect(scripts).To(BeEmpty())
+	})
+
+	It("Should only allow patch release upgrades", func() {
+		u := &Upgrader{InPlaceKubeletUpgrade: true, SSHPrivateKeyPath: kan.SSHPrivateKeyPath}
+		u.DataModel = kan.UpgradeContainerService
+		u.CurrentVersion = "1.18.6"
+		Expect(u.validateInPlaceKubeletUpgrade()).To(Succeed())
+
+		u.CurrentVersion = "1.17.11"
+		Expect(u.validateInPlaceKubeletUpgrade()).To(MatchError("the kubelet can only be upgraded in place to a patch release of the same minor version, not from 1.17.11 to 1.18.8"))
+
+		u.SSHPrivateKeyPath = ""
+		Expect(u.validateInPlaceKubeletUpgrade()).NotTo(Succeed())
+
+		u.InPlaceKubeletUpgrade = false
+		Expect(u.validateInPlaceKubeletUpgrade()).To(Succeed())
+	})
+})
@@ -0,0 +1,91 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KubernetesEventObjectName is the name of the synthetic node the upgrade events are recorded on
+	KubernetesEventObjectName = "aks-engine-upgrade"
+	kubernetesEventComponent  = "aks-engine"
+)
+
+// KubernetesEventRecorder records the upgrade events as Kubernetes events of the kube-system namespace,
+// so the upgrade progress shows in `kubectl get events -n kube-system`
+type KubernetesEventRecorder struct {
+	Client kubernetes.Client
+}
+
+// Report creates a Kubernetes event for the upgrade event, a Warning event if a node or the upgrade failed
+func (r *KubernetesEventRecorder) Report(event UpgradeEvent) error {
+	eventType := v1.EventTypeNormal
+	if event.Type == NodeUpgradeFailedEvent || event.Type == UpgradeFailedEvent {
+		eventType = v1.EventTypeWarning
+	}
+	timestamp := metav1.NewTime(event.Time)
+	k8sEvent := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// same naming scheme as the client-go event recorder
+			Name:      fmt.Sprintf("%s.%x", KubernetesEventObjectName, event.Time.UnixNano()),
+			Namespace: metav1.NamespaceSystem,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:      "Node",
+			Name:      KubernetesEventObjectName,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Reason:         string(event.Type),
+		Message:        kubernetesEventMessage(event),
+		Source:         v1.EventSource{Component: kubernetesEventComponent},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+		Type:           eventType,
+	}
+	if _, err := r.Client.CreateEvent(k8sEvent); err != nil {
+		return errors.Wrapf(err, "creating event %s", k8sEvent.Name)
+	}
+	return nil
+}
+
+func kubernetesEventMessage(event UpgradeEvent) string {
+	switch event.Type {
+	case NodeUpgradedEvent:
+		return fmt.Sprintf("Upgraded node %s of pool %s in %v", event.NodeName, event.PoolName, event.Duration.Round(time.Second))
+	case NodeDrainingEvent:
+		return fmt.Sprintf("Draining node %s of pool %s", event.NodeName, event.PoolName)
+	case NodeDeletingEvent:
+		return fmt.Sprintf("Deleting node %s of pool %s", event.NodeName, event.PoolName)
+	case NodeCreatingEvent:
+		return fmt.Sprintf("Creating node %s of pool %s", event.NodeName, event.PoolName)
+	case NodeValidatingEvent:
+		return fmt.Sprintf("Validating node %s of pool %s", event.NodeName, event.PoolName)
+	}
+	if event.NodeName != "" {
+		return fmt.Sprintf("Node %s: %s", event.NodeName, event.Message)
+	}
+	return event.Message
+}
+
+// addKubernetesEventRecorder adds a KubernetesEventRecorder to the reporters when EmitKubernetesEvents is set.
+// The events are best effort, the upgrade goes on without them if no Kubernetes client can be created.
+func (ku *Upgrader) addKubernetesEventRecorder() {
+	if !ku.EmitKubernetesEvents {
+		return
+	}
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		ku.logger.Warningf("Error getting a Kubernetes client, upgrade events will not be recorded in the cluster: %v", err)
+		return
+	}
+	ku.Reporters = append(ku.Reporters, &KubernetesEventRecorder{Client: client})
+}
@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Kubernetes event recorder tests", func() {
+	var (
+		kubeClient *armhelpers.MockKubernetesClient
+		recorder   *KubernetesEventRecorder
+		eventTime  = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{}
+		recorder = &KubernetesEventRecorder{Client: kubeClient}
+	})
+
+	It("Should record a Normal event on the upgrade object for an upgraded node", func() {
+		Expect(recorder.Report(UpgradeEvent{
+			Type:     NodeUpgradedEvent,
+			Time:     eventTime,
+			PoolName: "agentpool1",
+			NodeName: "k8s-agentpool1-12345678-0",
+			Duration: 3*time.Minute + 200*time.Millisecond,
+		})).To(Succeed())
+
+		Expect(kubeClient.Events).To(HaveLen(1))
+		event := kubeClient.Events[0]
+		Expect(event.Namespace).To(Equal("kube-system"))
+		Expect(event.InvolvedObject.Kind).To(Equal("Node"))
+		Expect(event.InvolvedObject.Name).To(Equal(KubernetesEventObjectName))
+		Expect(event.Type).To(Equal(v1.EventTypeNormal))
+		Expect(event.Reason).To(Equal("NodeUpgraded"))
+		Expect(event.Message).To(Equal("Upgraded node k8s-agentpool1-12345678-0 of pool agentpool1 in 3m0s"))
+		Expect(event.Source.Component).To(Equal("aks-engine"))
+		Expect(event.FirstTimestamp.Time).To(Equal(eventTime))
+	})
+
+	It("Should record a Warning event for a node that failed to upgrade", func() {
+		Expect(recorder.Report(UpgradeEvent{
+			Type:     NodeUpgradeFailedEvent,
+			Time:     eventTime,
+			NodeName: "k8s-agentpool1-12345678-1",
+			Message:  "DeleteVirtualMachine failed",
+		})).To(Succeed())
+
+		Expect(kubeClient.Events).To(HaveLen(1))
+		Expect(kubeClient.Events[0].Type).To(Equal(v1.EventTypeWarning))
+		Expect(kubeClient.Events[0].Message).To(Equal("Node k8s-agentpool1-12345678-1: DeleteVirtualMachine failed"))
+	})
+
+	It("Should fail when the event cannot be created", func() {
+		kubeClient.FailCreateEvent = true
+
+		err := recorder.Report(UpgradeEvent{Type: UpgradeStartedEvent, Time: eventTime})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("CreateEvent failed"))
+	})
+
+	It("Should add the recorder to the reporters only when EmitKubernetesEvents is set", func() {
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		u.addKubernetesEventRecorder()
+		Expect(u.Reporters).To(BeEmpty())
+
+		u.EmitKubernetesEvents = true
+		u.addKubernetesEventRecorder()
+		Expect(u.Reporters).To(HaveLen(1))
+		Expect(u.Reporters[0]).To(BeAssignableToTypeOf(&KubernetesEventRecorder{}))
+	})
+
+	It("Should go on without the recorder when no Kubernetes client can be created", func() {
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		u.Client.(*armhelpers.MockAKSEngineClient).FailGetKubernetesClient = true
+		u.EmitKubernetesEvents = true
+
+		u.addKubernetesEventRecorder()
+		Expect(u.Reporters).To(BeEmpty())
+	})
+
+	It("Should report the nodes that failed to upgrade", func() {
+		reporter := &fakeReporter{}
+		u := newTestCRDUpgrader("1.18.8", kubeClient)
+		u.Reporters = []UpgradeReporter{reporter}
+
+		Expect(u.nodeUpgradeFailed("k8s-agentpool1-12345678-1", errors.New("node not ready"))).To(Succeed())
+		Expect(reporter.events).To(HaveLen(1))
+		Expect(reporter.events[0].Type).To(Equal(NodeUpgradeFailedEvent))
+		Expect(reporter.events[0].NodeName).To(Equal("k8s-agentpool1-12345678-1"))
+		Expect(reporter.events[0].Message).To(Equal("node not ready"))
+	})
+})
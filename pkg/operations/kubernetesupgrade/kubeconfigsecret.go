@@ -0,0 +1,103 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// kubeconfigContentType is the content type of the Key Vault secret holding the kubeconfig
+const kubeconfigContentType = "application/x-yaml"
+
+// SecretStore reads and writes secrets of a secret store
+type SecretStore interface {
+	SecretResolver
+	// SetSecret sets the value of the secret at secretURL, creating the secret if it does not exist
+	SetSecret(ctx context.Context, secretURL, value, contentType string) error
+}
+
+// Compiler to verify AzureKeyVaultSecretResolver implements SecretStore
+var _ SecretStore = &AzureKeyVaultSecretResolver{}
+
+type keyVaultSecretValue struct {
+	Value       string `json:"value"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// SetSecret adds a version of the Key Vault secret at secretURL holding value, Key Vault creates the secret
+// if it does not exist
+func (r *AzureKeyVaultSecretResolver) SetSecret(ctx context.Context, secretURL, value, contentType string) error {
+	u, err := url.Parse(secretURL)
+	if err != nil {
+		return errors.Wrapf(err, "parsing secret URL %s", secretURL)
+	}
+	query := u.Query()
+	query.Set("api-version", keyVaultAPIVersion)
+	u.RawQuery = query.Encode()
+	if err := sendJSON(r.HTTPClient, r.Authorizer, http.MethodPut, u.String(), keyVaultSecretValue{Value: value, ContentType: contentType}); err != nil {
+		return errors.Wrapf(err, "setting secret %s", secretURL)
+	}
+	return nil
+}
+
+// validateKubeconfigSecret ensures KeyVaultID is the URL of a Key Vault secret without version that SecretStore can write
+func (kmn *UpgradeMasterNode) validateKubeconfigSecret() error {
+	if !kmn.UpdateKubeconfigInKeyVault {
+		return nil
+	}
+	if kmn.SecretStore == nil {
+		return errors.New("a secret store is required to update the kubeconfig in Key Vault")
+	}
+	if !isSecretURL(kmn.KeyVaultID) {
+		return errors.Errorf("%q is not the URL of a Key Vault secret, e.g. https://myvault.vault.azure.net/secrets/kubeconfig", kmn.KeyVaultID)
+	}
+	u, _ := url.Parse(kmn.KeyVaultID)
+	if strings.Count(strings.Trim(u.Path, "/"), "/") != 1 {
+		return errors.Errorf("the Key Vault secret URL %s must not include a secret version", kmn.KeyVaultID)
+	}
+	return nil
+}
+
+// UpdateKubeconfigSecret stores the kubeconfig of the upgrade as YAML in the Key Vault secret KeyVaultID once
+// it is verified against the API server of the upgraded masters. The secret is created if it does not exist,
+// and left as is if it already holds the kubeconfig.
+func (kmn *UpgradeMasterNode) UpdateKubeconfigSecret(ctx context.Context) error {
+	if err := kmn.validateKubeconfigSecret(); err != nil {
+		return err
+	}
+	client, err := kmn.Client.GetKubernetesClient(kmn.masterURL(), kmn.kubeConfig, interval, kmn.timeout)
+	if err != nil {
+		return errors.Wrap(err, "connecting to the API server with the kubeconfig")
+	}
+	if _, err = client.ListNodes(); err != nil {
+		return errors.Wrap(err, "verifying the kubeconfig against the API server")
+	}
+	kubeconfig, err := yaml.JSONToYAML([]byte(kmn.kubeConfig))
+	if err != nil {
+		return errors.Wrap(err, "serializing the kubeconfig to YAML")
+	}
+
+	current, err := kmn.SecretStore.GetSecret(ctx, kmn.KeyVaultID)
+	switch {
+	case errors.Cause(err) == ErrSecretNotFound:
+		kmn.logger.Infof("Creating Key Vault secret %s holding the kubeconfig", kmn.KeyVaultID)
+	case err != nil:
+		return errors.Wrap(err, "reading the kubeconfig stored in Key Vault")
+	case current == string(kubeconfig):
+		kmn.logger.Infof("Key Vault secret %s already holds the kubeconfig", kmn.KeyVaultID)
+		return nil
+	default:
+		kmn.logger.Infof("Updating the kubeconfig stored in Key Vault secret %s", kmn.KeyVaultID)
+	}
+	if err = kmn.SecretStore.SetSecret(ctx, kmn.KeyVaultID, string(kubeconfig), kubeconfigContentType); err != nil {
+		return errors.Wrap(err, "storing the kubeconfig in Key Vault")
+	}
+	return nil
+}
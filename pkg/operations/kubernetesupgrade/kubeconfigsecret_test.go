@@ -0,0 +1,143 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const (
+	testKubeconfigSecretURL = "https://myvault.vault.azure.net/secrets/kubeconfig"
+	testKubeconfig          = `{"apiVersion":"v1","kind":"Config","current-context":"mycluster"}`
+	testKubeconfigYAML      = "apiVersion: v1\ncurrent-context: mycluster\nkind: Config\n"
+)
+
+type fakeSecretStore struct {
+	fakeSecretResolver
+	setSecretURL string
+	setValue     string
+	contentType  string
+	setErr       error
+}
+
+func (s *fakeSecretStore) SetSecret(ctx context.Context, secretURL, value, contentType string) error {
+	s.setSecretURL, s.setValue, s.contentType = secretURL, value, contentType
+	return s.setErr
+}
+
+var _ = Describe("Kubeconfig secret tests", func() {
+	var (
+		kmn    *UpgradeMasterNode
+		client *armhelpers.MockAKSEngineClient
+		store  *fakeSecretStore
+	)
+
+	BeforeEach(func() {
+		client = &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+		kmn = newTestUpgradeMasterNode(client)
+		kmn.kubeConfig = testKubeconfig
+		store = &fakeSecretStore{fakeSecretResolver: fakeSecretResolver{value: "outdated"}}
+		kmn.UpdateKubeconfigInKeyVault = true
+		kmn.KeyVaultID = testKubeconfigSecretURL
+		kmn.SecretStore = store
+	})
+
+	It("Should update the secret with the kubeconfig as YAML", func() {
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(kmn.UpdateKubeconfigSecret(context.Background())).To(Succeed())
+
+		Expect(store.secretURL).To(Equal(testKubeconfigSecretURL))
+		Expect(store.setSecretURL).To(Equal(testKubeconfigSecretURL))
+		Expect(store.setValue).To(Equal(testKubeconfigYAML))
+		Expect(store.contentType).To(Equal(kubeconfigContentType))
+	})
+
+	It("Should create the secret if it does not exist", func() {
+		store.err = errors.Wrapf(ErrSecretNotFound, "getting secret %s", testKubeconfigSecretURL)
+
+		Expect(kmn.UpdateKubeconfigSecret(context.Background())).To(Succeed())
+		Expect(store.setValue).To(Equal(testKubeconfigYAML))
+	})
+
+	It("Should not write the secret if it already holds the kubeconfig", func() {
+		store.value = testKubeconfigYAML
+
+		Expect(kmn.UpdateKubeconfigSecret(context.Background())).To(Succeed())
+		Expect(store.setSecretURL).To(BeEmpty())
+	})
+
+	It("Should fail if the secret cannot be read or written", func() {
+		store.err = errors.New("forbidden")
+		Expect(kmn.UpdateKubeconfigSecret(context.Background())).To(MatchError("reading the kubeconfig stored in Key Vault: forbidden"))
+		Expect(store.setSecretURL).To(BeEmpty())
+
+		store.err = nil
+		store.setErr = errors.New("forbidden")
+		Expect(kmn.UpdateKubeconfigSecret(context.Background())).To(MatchError("storing the kubeconfig in Key Vault: forbidden"))
+	})
+
+	It("Should not store a kubeconfig the API server rejects", func() {
+		client.MockKubernetesClient.FailListNodes = true
+		err := kmn.UpdateKubeconfigSecret(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("verifying the kubeconfig against the API server"))
+
+		client.FailGetKubernetesClient = true
+		err = kmn.UpdateKubeconfigSecret(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("connecting to the API server with the kubeconfig"))
+		Expect(store.secretURL).To(BeEmpty())
+	})
+
+	It("Should fail preflight for an invalid Key Vault secret", func() {
+		kmn.KeyVaultID = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/myvault"
+		Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring("is not the URL of a Key Vault secret")))
+
+		kmn.KeyVaultID = testKubeconfigSecretURL + "/0123"
+		Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring("must not include a secret version")))
+
+		kmn.KeyVaultID = testKubeconfigSecretURL
+		kmn.SecretStore = nil
+		Expect(kmn.Preflight(context.Background())).To(MatchError("a secret store is required to update the kubeconfig in Key Vault"))
+	})
+
+	It("Should set the secret through the Key Vault REST API", func() {
+		var method, path, query string
+		var body keyVaultSecretValue
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path, query = r.Method, r.URL.Path, r.URL.RawQuery
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			_, _ = w.Write([]byte(`{"value": "` + body.Value + `"}`))
+		}))
+		defer server.Close()
+
+		resolver := NewAzureKeyVaultSecretResolver(autorest.NullAuthorizer{})
+		Expect(resolver.SetSecret(context.Background(), server.URL+"/secrets/kubeconfig", testKubeconfigYAML, kubeconfigContentType)).To(Succeed())
+		Expect(method).To(Equal(http.MethodPut))
+		Expect(path).To(Equal("/secrets/kubeconfig"))
+		Expect(query).To(Equal("api-version=" + keyVaultAPIVersion))
+		Expect(body).To(Equal(keyVaultSecretValue{Value: testKubeconfigYAML, ContentType: kubeconfigContentType}))
+	})
+
+	It("Should return ErrSecretNotFound for a missing secret", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": "SecretNotFound"}}`))
+		}))
+		defer server.Close()
+
+		resolver := NewAzureKeyVaultSecretResolver(autorest.NullAuthorizer{})
+		_, err := resolver.GetSecret(context.Background(), server.URL+"/secrets/kubeconfig")
+		Expect(errors.Cause(err)).To(Equal(ErrSecretNotFound))
+	})
+})
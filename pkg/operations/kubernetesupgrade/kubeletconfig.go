@@ -0,0 +1,168 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// KubeletConfigMapName is the kube-system config map holding the KubeletConfiguration of the cluster nodes
+	KubeletConfigMapName = "kubelet-config"
+	kubeletConfigMapKey  = "kubelet"
+)
+
+var (
+	vmssProviderIDRegex = regexp.MustCompile(`(?i)/resourceGroups/([^/]+)/providers/Microsoft.Compute/virtualMachineScaleSets/([^/]+)/virtualMachines/([^/]+)$`)
+	vmProviderIDRegex   = regexp.MustCompile(`(?i)/resourceGroups/([^/]+)/providers/Microsoft.Compute/virtualMachines/([^/]+)$`)
+)
+
+// ApplyKubeletConfig merges patch into the KubeletConfiguration stored in the kubelet-config config map
+// of the kube-system namespace, then restarts kubelet on each node, one at a time, waiting for the node
+// to be ready again before moving to the next one. Nested objects of patch are merged recursively and
+// null values remove the corresponding fields, as in a JSON merge patch.
+func (ku *Upgrader) ApplyKubeletConfig(ctx context.Context, patch map[string]interface{}) error {
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	if _, err = patchKubeletConfigMap(client, patch); err != nil {
+		return err
+	}
+	ku.logger.Infof("Updated config map %s/%s", metav1.NamespaceSystem, KubeletConfigMapName)
+
+	nodes, err := client.ListNodes()
+	if err != nil {
+		return errors.Wrap(err, "listing nodes")
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		ku.logger.Infof("Restarting kubelet on node %s", node.Name)
+		if err = ku.restartKubelet(ctx, node); err != nil {
+			return errors.Wrapf(err, "restarting kubelet on node %s", node.Name)
+		}
+		if err = ku.waitForNodeReady(client, node.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchKubeletConfigMap merges patch into the KubeletConfiguration of the kubelet-config config map, and returns
+// the patch restoring the previous values of the top-level fields of patch, null for the fields that were not set
+func patchKubeletConfigMap(client kubernetes.Client, patch map[string]interface{}) (map[string]interface{}, error) {
+	cm, config, err := getKubeletConfigMap(client)
+	if err != nil {
+		return nil, err
+	}
+	// unmarshaled again as merging modifies the nested objects of config
+	previous := map[string]interface{}{}
+	_ = yaml.Unmarshal([]byte(cm.Data[kubeletConfigMapKey]), &previous)
+	restore := map[string]interface{}{}
+	for key := range patch {
+		restore[key] = previous[key]
+	}
+	value, err := yaml.Marshal(mergeKubeletConfig(config, patch))
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling kubelet configuration")
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[kubeletConfigMapKey] = string(value)
+	if _, err = client.UpdateConfigMap(cm); err != nil {
+		return nil, errors.Wrapf(err, "updating config map %s/%s", metav1.NamespaceSystem, KubeletConfigMapName)
+	}
+	return restore, nil
+}
+
+// getKubeletConfigMap returns the kubelet-config config map and the KubeletConfiguration it holds
+func getKubeletConfigMap(client kubernetes.Client) (*v1.ConfigMap, map[string]interface{}, error) {
+	cm, err := client.GetConfigMap(metav1.NamespaceSystem, KubeletConfigMapName)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "getting config map %s/%s", metav1.NamespaceSystem, KubeletConfigMapName)
+	}
+	config := map[string]interface{}{}
+	if err = yaml.Unmarshal([]byte(cm.Data[kubeletConfigMapKey]), &config); err != nil {
+		return nil, nil, errors.Wrapf(err, "parsing the %s key of config map %s/%s", kubeletConfigMapKey, metav1.NamespaceSystem, KubeletConfigMapName)
+	}
+	return cm, config, nil
+}
+
+// mergeKubeletConfig applies patch to config following the JSON merge patch rules
+func mergeKubeletConfig(config, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		if value == nil {
+			delete(config, key)
+			continue
+		}
+		patchObject, isObject := value.(map[string]interface{})
+		configObject, wasObject := config[key].(map[string]interface{})
+		if isObject && wasObject {
+			config[key] = mergeKubeletConfig(configObject, patchObject)
+		} else if isObject {
+			config[key] = mergeKubeletConfig(map[string]interface{}{}, patchObject)
+		} else {
+			config[key] = value
+		}
+	}
+	return config
+}
+
+// restartKubelet restarts the kubelet service through the VM run command API,
+// the node VM being identified from its provider ID
+func (ku *Upgrader) restartKubelet(ctx context.Context, node *v1.Node) error {
+	input := compute.RunCommandInput{
+		CommandID: to.StringPtr("RunShellScript"),
+		Script:    &[]string{"systemctl restart kubelet"},
+	}
+	if node.Status.NodeInfo.OperatingSystem == "windows" {
+		input = compute.RunCommandInput{
+			CommandID: to.StringPtr("RunPowerShellScript"),
+			Script:    &[]string{"Restart-Service kubelet"},
+		}
+	}
+	if m := vmssProviderIDRegex.FindStringSubmatch(node.Spec.ProviderID); m != nil {
+		_, err := ku.Client.RunVirtualMachineScaleSetVMCommand(ctx, m[1], m[2], m[3], input)
+		return err
+	}
+	if m := vmProviderIDRegex.FindStringSubmatch(node.Spec.ProviderID); m != nil {
+		_, err := ku.Client.RunVirtualMachineCommand(ctx, m[1], m[2], input)
+		return err
+	}
+	return errors.Errorf("unexpected provider ID %q", node.Spec.ProviderID)
+}
+
+func (ku *Upgrader) waitForNodeReady(client kubernetes.Client, nodeName string) error {
+	timeout := defaultTimeout
+	if ku.stepTimeout != nil {
+		timeout = *ku.stepTimeout
+	}
+	retryTimer := time.NewTimer(time.Millisecond)
+	timeoutTimer := time.NewTimer(timeout)
+	for {
+		select {
+		case <-timeoutTimer.C:
+			retryTimer.Stop()
+			return errors.Errorf("node %s was not ready within %v after restarting kubelet", nodeName, timeout)
+		case <-retryTimer.C:
+			node, err := client.GetNode(nodeName)
+			if err == nil && kubernetes.IsNodeReady(node) {
+				timeoutTimer.Stop()
+				return nil
+			}
+			retryTimer.Reset(retry)
+		}
+	}
+}
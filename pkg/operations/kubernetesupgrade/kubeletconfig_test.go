@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const testKubeletConfig = `apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+evictionHard:
+  memory.available: 750Mi
+  nodefs.available: 10%
+maxPods: 30
+serializeImagePulls: true
+`
+
+func newTestProviderNode(name, providerID string) v1.Node {
+	node := v1.Node{}
+	node.Name = name
+	node.Spec.ProviderID = providerID
+	return node
+}
+
+var _ = Describe("Kubelet configuration tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kubeClient *armhelpers.MockKubernetesClient
+		u          *Upgrader
+	)
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			ConfigMaps: map[string]*v1.ConfigMap{
+				"kube-system/" + KubeletConfigMapName: {
+					ObjectMeta: metav1.ObjectMeta{Name: KubeletConfigMapName, Namespace: "kube-system"},
+					Data:       map[string]string{"kubelet": testKubeletConfig},
+				},
+			},
+			NodesList: &v1.NodeList{Items: []v1.Node{
+				newTestProviderNode("k8s-master-12345678-0", "azure:///subscriptions/sub/resourceGroups/testrg/providers/Microsoft.Compute/virtualMachines/k8s-master-12345678-0"),
+				newTestProviderNode("k8s-agentpool1-12345678-vmss000002", "azure:///subscriptions/sub/resourceGroups/testrg/providers/Microsoft.Compute/virtualMachineScaleSets/k8s-agentpool1-12345678-vmss/virtualMachines/2"),
+			}},
+		}
+		mockClient = &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}
+		stepTimeout := time.Second
+		u = &Upgrader{}
+		u.Init(&i18n.Translator{}, log.NewEntry(log.New()), ClusterTopology{DataModel: newTestCRDUpgrader("1.24.0", kubeClient).DataModel},
+			mockClient, "", &stepTimeout, nil, TestAKSEngineVersion, false)
+	})
+
+	It("Should merge the patch into the kubelet configuration and restart each kubelet", func() {
+		patch := map[string]interface{}{
+			"evictionHard":        map[string]interface{}{"memory.available": "1Gi"},
+			"maxPods":             110,
+			"serializeImagePulls": nil,
+			"featureGates":        map[string]interface{}{"RotateKubeletServerCertificate": true},
+		}
+		Expect(u.ApplyKubeletConfig(context.Background(), patch)).To(Succeed())
+
+		config := map[string]interface{}{}
+		Expect(yaml.Unmarshal([]byte(kubeClient.ConfigMaps["kube-system/"+KubeletConfigMapName].Data["kubelet"]), &config)).To(Succeed())
+		Expect(config).To(Equal(map[string]interface{}{
+			"apiVersion":   "kubelet.config.k8s.io/v1beta1",
+			"kind":         "KubeletConfiguration",
+			"evictionHard": map[string]interface{}{"memory.available": "1Gi", "nodefs.available": "10%"},
+			"maxPods":      float64(110),
+			"featureGates": map[string]interface{}{"RotateKubeletServerCertificate": true},
+		}))
+		Expect(mockClient.RunCommandTargets).To(Equal([]string{"k8s-master-12345678-0", "k8s-agentpool1-12345678-vmss/2"}))
+	})
+
+	It("Should fail when the kubelet-config config map does not exist", func() {
+		kubeClient.ConfigMaps = nil
+
+		err := u.ApplyKubeletConfig(context.Background(), map[string]interface{}{"maxPods": 110})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("getting config map kube-system/kubelet-config"))
+		Expect(mockClient.RunCommandTargets).To(BeEmpty())
+	})
+
+	It("Should stop at the first node whose kubelet cannot be restarted", func() {
+		kubeClient.NodesList.Items[0].Spec.ProviderID = ""
+
+		err := u.ApplyKubeletConfig(context.Background(), map[string]interface{}{"maxPods": 110})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("restarting kubelet on node k8s-master-12345678-0"))
+		Expect(mockClient.RunCommandTargets).To(BeEmpty())
+	})
+
+	It("Should fail when a node is not ready after restarting kubelet", func() {
+		kubeClient.FailGetNode = true
+
+		err := u.ApplyKubeletConfig(context.Background(), map[string]interface{}{"maxPods": 110})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("node k8s-master-12345678-0 was not ready within 1s after restarting kubelet"))
+	})
+})
@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// kubeletHealthEndpoints are the kubelet endpoints VerifyKubeletHealth expects to return 200 OK
+var kubeletHealthEndpoints = []string{"/healthz", "/livez", "/readyz"}
+
+// VerifyKubeletHealth checks that the health endpoints of the kubelet of the node return 200 OK through the
+// api server proxy, e.g. once Validate reports the node ready. The endpoints returning another status code are logged.
+func (kan *UpgradeAgentNode) VerifyKubeletHealth(ctx context.Context, nodeName string) error {
+	client, err := kan.Client.GetKubernetesClient(kan.UpgradeContainerService.Properties.MasterProfile.FQDN, kan.kubeConfig, interval, kan.timeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	node, err := client.GetNode(nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "getting node %s", nodeName)
+	}
+	internalIP := nodeInternalIP(node)
+	if internalIP == "" {
+		return errors.Errorf("node %s has no internal IP", nodeName)
+	}
+
+	var unhealthy []string
+	for _, endpoint := range kubeletHealthEndpoints {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		statusCode, err := client.GetNodeProxy(nodeName, endpoint)
+		if err != nil {
+			return errors.Wrapf(err, "getting kubelet endpoint %s of node %s", endpoint, nodeName)
+		}
+		if statusCode != http.StatusOK {
+			kan.logger.Warnf("Kubelet endpoint %s of node %s (%s) returned status code %d", endpoint, nodeName, internalIP, statusCode)
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	if len(unhealthy) > 0 {
+		return errors.Errorf("kubelet endpoints %s of node %s did not return 200 OK", strings.Join(unhealthy, ", "), nodeName)
+	}
+	kan.logger.Infof("Kubelet health endpoints of node %s (%s) are healthy", nodeName, internalIP)
+	return nil
+}
+
+// nodeInternalIP returns the InternalIP address of the node, empty if it has none
+func nodeInternalIP(node *v1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Kubelet health tests", func() {
+	var (
+		kubeClient *armhelpers.MockKubernetesClient
+		kan        *UpgradeAgentNode
+	)
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			GetNodeFunc: func(name string) (*v1.Node, error) {
+				node := &v1.Node{}
+				node.Name = name
+				node.Status.Addresses = []v1.NodeAddress{
+					{Type: v1.NodeHostName, Address: name},
+					{Type: v1.NodeInternalIP, Address: "10.240.0.4"},
+				}
+				return node, nil
+			},
+		}
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.Client = &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}
+	})
+
+	It("Should succeed when every kubelet health endpoint returns 200 OK", func() {
+		Expect(kan.VerifyKubeletHealth(context.Background(), "k8s-agentpool1-12345678-0")).To(Succeed())
+	})
+
+	It("Should fail when kubelet health endpoints return another status code", func() {
+		kubeClient.NodeProxyStatusCodes = map[string]int{
+			"/livez":  http.StatusNotFound,
+			"/readyz": http.StatusInternalServerError,
+		}
+
+		err := kan.VerifyKubeletHealth(context.Background(), "k8s-agentpool1-12345678-0")
+		Expect(err).To(MatchError("kubelet endpoints /livez, /readyz of node k8s-agentpool1-12345678-0 did not return 200 OK"))
+	})
+
+	It("Should fail when the kubelet cannot be reached through the api server proxy", func() {
+		kubeClient.FailGetNodeProxy = true
+
+		err := kan.VerifyKubeletHealth(context.Background(), "k8s-agentpool1-12345678-0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("getting kubelet endpoint /healthz of node k8s-agentpool1-12345678-0"))
+	})
+
+	It("Should fail when the node has no internal IP", func() {
+		kubeClient.GetNodeFunc = nil
+
+		Expect(kan.VerifyKubeletHealth(context.Background(), "k8s-agentpool1-12345678-0")).To(MatchError("node k8s-agentpool1-12345678-0 has no internal IP"))
+	})
+})
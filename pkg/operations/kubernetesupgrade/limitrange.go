@@ -0,0 +1,93 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// SyncLimitRanges checks, once the agent pools were upgraded, whether the default CPU or memory requests
+// and limits of any namespace LimitRange exceed the capacity of a node of the smallest VM size an agent pool
+// was moved to, and logs a warning for each. If AutoAdjustLimitRanges is set, these defaults are scaled
+// by the ratio between the vCPUs and memory of the new and the previous VM size of that agent pool.
+func (ku *Upgrader) SyncLimitRanges(ctx context.Context) error {
+	var (
+		vmSize, previousVMSize string
+		current, previous      vmSizeResources
+	)
+	for _, app := range ku.ClusterTopology.DataModel.Properties.AgentPoolProfiles {
+		previousPoolVMSize := ku.previousVMSize(app.Name)
+		if previousPoolVMSize == "" || app.VMSize == "" || strings.EqualFold(previousPoolVMSize, app.VMSize) {
+			continue
+		}
+		poolCurrent, err := ku.vmSizeResources(ctx, app.VMSize)
+		if err != nil {
+			return err
+		}
+		if vmSize != "" && poolCurrent.vCPUs >= current.vCPUs && poolCurrent.memoryGB >= current.memoryGB {
+			continue
+		}
+		poolPrevious, err := ku.vmSizeResources(ctx, previousPoolVMSize)
+		if err != nil {
+			return err
+		}
+		vmSize, previousVMSize = app.VMSize, previousPoolVMSize
+		current, previous = poolCurrent, poolPrevious
+	}
+	if vmSize == "" {
+		return nil
+	}
+	capacities := v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewMilliQuantity(int64(current.vCPUs*1000), resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(int64(current.memoryGB*(1<<30)), resource.BinarySI),
+	}
+	scaleFactors := map[v1.ResourceName]float64{
+		v1.ResourceCPU:    current.vCPUs / previous.vCPUs,
+		v1.ResourceMemory: current.memoryGB / previous.memoryGB,
+	}
+	ku.logger.Infof("Agent pool VM size changed from %s to %s, checking limit ranges", previousVMSize, vmSize)
+
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	limitRanges, err := client.ListLimitRanges("")
+	if err != nil {
+		return errors.Wrap(err, "listing limit ranges")
+	}
+	for i := range limitRanges.Items {
+		limitRange := &limitRanges.Items[i]
+		overCommitted := false
+		for j := range limitRange.Spec.Limits {
+			limit := &limitRange.Spec.Limits[j]
+			for kind, defaults := range map[string]v1.ResourceList{"default": limit.Default, "default request": limit.DefaultRequest} {
+				for name, capacity := range capacities {
+					value, ok := defaults[name]
+					if !ok || value.Cmp(capacity) <= 0 {
+						continue
+					}
+					ku.logger.Warnf("Limit range %s/%s %s %s %s %s exceeds the %s node %s capacity %s",
+						limitRange.Namespace, limitRange.Name, limit.Type, kind, name, value.String(), vmSize, name, capacity.String())
+					overCommitted = true
+					if ku.AutoAdjustLimitRanges {
+						defaults[name] = scaleQuantity(name, value, scaleFactors[name])
+					}
+				}
+			}
+		}
+		if !overCommitted || !ku.AutoAdjustLimitRanges {
+			continue
+		}
+		ku.logger.Infof("Adjusting limit range %s/%s to the %s VM size", limitRange.Namespace, limitRange.Name, vmSize)
+		if _, err = client.UpdateLimitRange(limitRange); err != nil {
+			return errors.Wrapf(err, "updating limit range %s/%s", limitRange.Namespace, limitRange.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newTestLimitRange(namespace, cpu, memory string) v1.LimitRange {
+	limitRange := v1.LimitRange{}
+	limitRange.Name = "defaults"
+	limitRange.Namespace = namespace
+	limitRange.Spec.Limits = []v1.LimitRangeItem{{
+		Type: v1.LimitTypeContainer,
+		Default: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		},
+		DefaultRequest: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("100m"),
+			v1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+	}}
+	return limitRange
+}
+
+var _ = Describe("Limit range sync tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kubeClient *armhelpers.MockKubernetesClient
+		u          *Upgrader
+	)
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			LimitRangeList: &v1.LimitRangeList{Items: []v1.LimitRange{
+				newTestLimitRange("team-a", "4", "16Gi"),
+				newTestLimitRange("team-b", "1", "2Gi"),
+			}},
+		}
+		mockClient = &armhelpers.MockAKSEngineClient{
+			MockKubernetesClient: kubeClient,
+			FakeListResourceSkusResult: func() []compute.ResourceSku {
+				return []compute.ResourceSku{
+					newTestResourceSku("Standard_D4_v2", "8", "28"),
+					newTestResourceSku("Standard_D2_v2", "2", "7"),
+				}
+			},
+		}
+		cs := newTestCRDUpgrader("1.18.8", kubeClient).DataModel
+		u = &Upgrader{}
+		u.Init(&i18n.Translator{}, log.NewEntry(log.New()), ClusterTopology{
+			DataModel: cs,
+			Location:  "westus2",
+			AgentPools: map[string]*AgentPoolTopology{
+				"agentpool1": {
+					Name: to.StringPtr("agentpool1"),
+					AgentVMs: &[]compute.VirtualMachine{{
+						Name: to.StringPtr("k8s-agentpool1-12345678-0"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							HardwareProfile: &compute.HardwareProfile{VMSize: compute.VirtualMachineSizeTypesStandardD4V2},
+						},
+					}},
+				},
+			},
+		}, mockClient, "", nil, nil, TestAKSEngineVersion, false)
+	})
+
+	It("Should only warn about the defaults exceeding the node capacity by default", func() {
+		Expect(u.SyncLimitRanges(context.Background())).To(Succeed())
+
+		defaults := kubeClient.LimitRangeList.Items[0].Spec.Limits[0].Default
+		Expect(quotaHard(defaults, v1.ResourceCPU)).To(Equal("4"))
+		Expect(quotaHard(defaults, v1.ResourceMemory)).To(Equal("16Gi"))
+	})
+
+	It("Should scale the defaults exceeding the node capacity when AutoAdjustLimitRanges is set", func() {
+		u.AutoAdjustLimitRanges = true
+		Expect(u.SyncLimitRanges(context.Background())).To(Succeed())
+
+		limit := kubeClient.LimitRangeList.Items[0].Spec.Limits[0]
+		Expect(quotaHard(limit.Default, v1.ResourceCPU)).To(Equal("1"))
+		Expect(quotaHard(limit.Default, v1.ResourceMemory)).To(Equal("4Gi"))
+		Expect(quotaHard(limit.DefaultRequest, v1.ResourceCPU)).To(Equal("100m"))
+		Expect(quotaHard(limit.DefaultRequest, v1.ResourceMemory)).To(Equal("128Mi"))
+		limit = kubeClient.LimitRangeList.Items[1].Spec.Limits[0]
+		Expect(quotaHard(limit.Default, v1.ResourceCPU)).To(Equal("1"))
+		Expect(quotaHard(limit.Default, v1.ResourceMemory)).To(Equal("2Gi"))
+	})
+
+	It("Should do nothing when no VM size changed", func() {
+		u.AutoAdjustLimitRanges = true
+		u.DataModel.Properties.AgentPoolProfiles[0].VMSize = "Standard_D4_v2"
+		mockClient.FailListResourceSkus = true
+		kubeClient.FailListLimitRanges = true
+
+		Expect(u.SyncLimitRanges(context.Background())).To(Succeed())
+	})
+
+	It("Should return an error when the limit ranges cannot be listed", func() {
+		kubeClient.FailListLimitRanges = true
+
+		err := u.SyncLimitRanges(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("listing limit ranges"))
+	})
+
+	It("Should return an error when a limit range cannot be updated", func() {
+		u.AutoAdjustLimitRanges = true
+		kubeClient.FailUpdateLimitRange = true
+
+		err := u.SyncLimitRanges(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("updating limit range team-a/defaults"))
+	})
+})
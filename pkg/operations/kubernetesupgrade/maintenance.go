@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+)
+
+const maintenanceAPIVersion = "2020-04-01"
+
+// MaintenanceClient associates Azure maintenance configurations with virtual machines
+type MaintenanceClient interface {
+	// CreateConfigurationAssignment assigns the maintenance configuration to the virtual machine
+	CreateConfigurationAssignment(ctx context.Context, resourceGroup, vmName, location, maintenanceConfigurationID string) error
+}
+
+// Compiler to verify AzureMaintenanceClient implements MaintenanceClient
+var _ MaintenanceClient = &AzureMaintenanceClient{}
+
+// AzureMaintenanceClient is a MaintenanceClient backed by the Azure Maintenance REST API
+type AzureMaintenanceClient struct {
+	// BaseURI is the Azure Resource Manager endpoint, e.g. https://management.azure.com
+	BaseURI        string
+	SubscriptionID string
+	Authorizer     autorest.Authorizer
+	HTTPClient     *http.Client
+}
+
+// NewAzureMaintenanceClient returns an AzureMaintenanceClient for the given ARM endpoint and subscription
+func NewAzureMaintenanceClient(baseURI, subscriptionID string, authorizer autorest.Authorizer) *AzureMaintenanceClient {
+	return &AzureMaintenanceClient{
+		BaseURI:        baseURI,
+		SubscriptionID: subscriptionID,
+		Authorizer:     authorizer,
+		HTTPClient:     &http.Client{Timeout: time.Minute},
+	}
+}
+
+type configurationAssignmentProperties struct {
+	MaintenanceConfigurationID string `json:"maintenanceConfigurationId"`
+	ResourceID                 string `json:"resourceId,omitempty"`
+}
+
+type configurationAssignment struct {
+	Location   string                            `json:"location"`
+	Properties configurationAssignmentProperties `json:"properties"`
+}
+
+// CreateConfigurationAssignment assigns the maintenance configuration to the virtual machine.
+// The assignment is named after the maintenance configuration.
+func (c *AzureMaintenanceClient) CreateConfigurationAssignment(ctx context.Context, resourceGroup, vmName, location, maintenanceConfigurationID string) error {
+	name, err := utils.ResourceName(maintenanceConfigurationID)
+	if err != nil {
+		return errors.Wrapf(err, "parsing maintenance configuration ID %s", maintenanceConfigurationID)
+	}
+	vmID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s", c.SubscriptionID, resourceGroup, vmName)
+	url := fmt.Sprintf("%s%s/providers/Microsoft.Maintenance/configurationAssignments/%s?api-version=%s",
+		strings.TrimSuffix(c.BaseURI, "/"), vmID, name, maintenanceAPIVersion)
+	return sendJSON(c.HTTPClient, c.Authorizer, http.MethodPut, url, configurationAssignment{
+		Location: location,
+		Properties: configurationAssignmentProperties{
+			MaintenanceConfigurationID: maintenanceConfigurationID,
+			ResourceID:                 vmID,
+		},
+	})
+}
+
+// AssignMaintenanceConfiguration associates the master VM with the maintenance configuration.
+func (kmn *UpgradeMasterNode) AssignMaintenanceConfiguration(ctx context.Context, vmName, maintenanceConfigurationID string) error {
+	if kmn.MaintenanceClient == nil {
+		return errors.New("no maintenance client configured")
+	}
+	kmn.logger.Infof("Assigning maintenance configuration %s to VM %s", maintenanceConfigurationID, vmName)
+	if err := kmn.MaintenanceClient.CreateConfigurationAssignment(ctx, kmn.ResourceGroup, vmName, kmn.UpgradeContainerService.Location, maintenanceConfigurationID); err != nil {
+		return errors.Wrapf(err, "assigning maintenance configuration to VM %s", vmName)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const testMaintenanceConfigurationID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/maintenancerg/providers/Microsoft.Maintenance/maintenanceConfigurations/weekends"
+
+type maintenanceAssignment struct {
+	resourceGroup, vmName, location, maintenanceConfigurationID string
+}
+
+type fakeMaintenanceClient struct {
+	assignments []maintenanceAssignment
+	err         error
+}
+
+func (c *fakeMaintenanceClient) CreateConfigurationAssignment(ctx context.Context, resourceGroup, vmName, location, maintenanceConfigurationID string) error {
+	c.assignments = append(c.assignments, maintenanceAssignment{resourceGroup, vmName, location, maintenanceConfigurationID})
+	return c.err
+}
+
+var _ = Describe("Maintenance configuration tests", func() {
+	It("Should assign the maintenance configuration after creating a master node", func() {
+		maintenanceClient := &fakeMaintenanceClient{}
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.MaintenanceConfigurationID = testMaintenanceConfigurationID
+		kmn.MaintenanceClient = maintenanceClient
+
+		Expect(kmn.CreateNode(context.Background(), "master", 2)).To(Succeed())
+		Expect(maintenanceClient.assignments).To(Equal([]maintenanceAssignment{
+			{
+				resourceGroup:              "TestRg",
+				vmName:                     kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + "2",
+				location:                   "eastus",
+				maintenanceConfigurationID: testMaintenanceConfigurationID,
+			},
+		}))
+	})
+
+	It("Should not assign a maintenance configuration when the deployment fails", func() {
+		maintenanceClient := &fakeMaintenanceClient{}
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailDeployTemplate: true})
+		kmn.MaintenanceConfigurationID = testMaintenanceConfigurationID
+		kmn.MaintenanceClient = maintenanceClient
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+		Expect(maintenanceClient.assignments).To(BeEmpty())
+	})
+
+	It("Should return an error when the assignment fails", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.MaintenanceConfigurationID = testMaintenanceConfigurationID
+		kmn.MaintenanceClient = &fakeMaintenanceClient{err: errors.New("assignment failed")}
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("assignment failed"))
+	})
+
+	It("Should fail preflight without a maintenance client", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.MaintenanceConfigurationID = testMaintenanceConfigurationID
+
+		Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+	})
+
+	It("Should send the configuration assignment to the Maintenance REST API", func() {
+		var method, path, apiVersion string
+		var body configurationAssignment
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+			path = r.URL.Path
+			apiVersion = r.URL.Query().Get("api-version")
+			data, _ := ioutil.ReadAll(r.Body)
+			_ = json.Unmarshal(data, &body)
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+
+		client := NewAzureMaintenanceClient(server.URL, "DEC923E3-1EF1-4745-9516-37906D56DEC4", autorest.NullAuthorizer{})
+		err := client.CreateConfigurationAssignment(context.Background(), "TestRg", "k8s-master-12345678-0", "eastus", testMaintenanceConfigurationID)
+		Expect(err).NotTo(HaveOccurred())
+
+		vmID := "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Compute/virtualMachines/k8s-master-12345678-0"
+		Expect(method).To(Equal(http.MethodPut))
+		Expect(path).To(Equal(vmID + "/providers/Microsoft.Maintenance/configurationAssignments/weekends"))
+		Expect(apiVersion).To(Equal(maintenanceAPIVersion))
+		Expect(body).To(Equal(configurationAssignment{
+			Location: "eastus",
+			Properties: configurationAssignmentProperties{
+				MaintenanceConfigurationID: testMaintenanceConfigurationID,
+				ResourceID:                 vmID,
+			},
+		}))
+	})
+
+	It("Should return an error when the Maintenance REST API rejects the assignment", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		client := NewAzureMaintenanceClient(server.URL, "DEC923E3-1EF1-4745-9516-37906D56DEC4", autorest.NullAuthorizer{})
+		err := client.CreateConfigurationAssignment(context.Background(), "TestRg", "k8s-master-12345678-0", "eastus", testMaintenanceConfigurationID)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("404"))
+	})
+})
@@ -0,0 +1,64 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VerifyMasterCount returns an error if the number of master VMs found in the resource group
+// does not match MasterProfile.Count, as the master indexes computed during the upgrade would
+// then be wrong. It is not part of Preflight because the upgrade recreates the master VMs that
+// a previous failed upgrade deleted.
+func (kmn *UpgradeMasterNode) VerifyMasterCount(ctx context.Context) error {
+	p := kmn.UpgradeContainerService.Properties
+	if p.MasterProfile == nil {
+		return nil
+	}
+	masterVMName := regexp.MustCompile(`^` + regexp.QuoteMeta(strings.ToLower(p.GetMasterVMPrefix())) + `(\d+)$`)
+
+	found := map[int]string{}
+	for page, err := kmn.Client.ListVirtualMachines(ctx, kmn.ResourceGroup); page.NotDone(); err = page.Next() {
+		if err != nil {
+			return errors.Wrapf(err, "listing virtual machines in resource group %s", kmn.ResourceGroup)
+		}
+		for _, vm := range page.Values() {
+			if vm.Name == nil {
+				continue
+			}
+			if m := masterVMName.FindStringSubmatch(strings.ToLower(*vm.Name)); m != nil {
+				index, _ := strconv.Atoi(m[1])
+				found[index] = *vm.Name
+			}
+		}
+	}
+	var names, missing []string
+	for i := 0; i < p.MasterProfile.Count; i++ {
+		if _, ok := found[i]; !ok {
+			missing = append(missing, p.GetMasterVMPrefix()+strconv.Itoa(i))
+		}
+	}
+	if len(missing) == 0 && len(found) == p.MasterProfile.Count {
+		return nil
+	}
+	for _, name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(missing) > 0 {
+		return errors.Errorf("found %d master VMs %v in resource group %s but masterProfile.count is %d, missing %v: "+
+			"a previous upgrade probably failed after deleting a master VM, run 'aks-engine upgrade' "+
+			"with the same API model and target version to recreate the missing master VMs",
+			len(found), names, kmn.ResourceGroup, p.MasterProfile.Count, missing)
+	}
+	return errors.Errorf("found %d master VMs %v in resource group %s but masterProfile.count is %d: "+
+		"set masterProfile.count to the number of masters of the cluster, or delete the master VMs that do not belong to it",
+		len(found), names, kmn.ResourceGroup, p.MasterProfile.Count)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Master count verification tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kmn        *UpgradeMasterNode
+		vmNames    []string
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+			var vms []compute.VirtualMachine
+			for _, name := range vmNames {
+				vms = append(vms, mockClient.MakeFakeVirtualMachine(name, "1.18.8"))
+			}
+			return vms
+		}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		prefix := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix()
+		vmNames = []string{prefix + "0", prefix + "1", prefix + "2", "k8s-agentpool1-12345678-0"}
+	})
+
+	It("Should succeed when all the master VMs are running", func() {
+		Expect(kmn.VerifyMasterCount(context.Background())).To(Succeed())
+	})
+
+	It("Should report the missing master VMs", func() {
+		prefix := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix()
+		vmNames = []string{prefix + "0", prefix + "2"}
+
+		err := kmn.VerifyMasterCount(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("found 2 master VMs [%s0 %s2] in resource group TestRg but masterProfile.count is 3, missing [%s1]", prefix, prefix, prefix))
+		Expect(err.Error()).To(ContainSubstring("recreate the missing master VMs"))
+	})
+
+	It("Should fail when a master index is out of range", func() {
+		prefix := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix()
+		vmNames = []string{prefix + "0", prefix + "1", prefix + "3"}
+
+		err := kmn.VerifyMasterCount(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("missing [%s2]", prefix))
+	})
+
+	It("Should fail when there are more master VMs than expected", func() {
+		prefix := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix()
+		vmNames = append(vmNames, prefix+"3")
+
+		err := kmn.VerifyMasterCount(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("found 4 master VMs"))
+		Expect(err.Error()).To(ContainSubstring("delete the master VMs that do not belong to it"))
+	})
+
+	It("Should return an error when the VMs cannot be listed", func() {
+		mockClient.FailListVirtualMachines = true
+
+		Expect(kmn.VerifyMasterCount(context.Background())).NotTo(Succeed())
+	})
+})
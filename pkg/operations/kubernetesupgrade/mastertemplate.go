@@ -0,0 +1,237 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	vmResourceType  = "Microsoft.Compute/virtualMachines"
+	nicResourceType = "Microsoft.Network/networkInterfaces"
+
+	masterVMNamePrefixVariable = "variables('masterVMNamePrefix')"
+
+	// masterCloudInitScriptVariable is the template variable holding UpgradeMasterNode.CloudInitScript
+	masterCloudInitScriptVariable = "masterCloudInitScript"
+	// masterReplacementSubnetIDVariable is the template variable holding UpgradeMasterNode.ReplacementSubnetID
+	masterReplacementSubnetIDVariable = "masterReplacementSubnetID"
+)
+
+var armAPIVersionRegexp = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}(-preview)?$`)
+
+// ValidateVMAPIVersion checks that version is a well-formed ARM API version,
+// i.e. YYYY-MM-DD or YYYY-MM-DD-preview.
+func ValidateVMAPIVersion(version string) error {
+	if !armAPIVersionRegexp.MatchString(version) {
+		return errors.Errorf("invalid VM API version %q, expected YYYY-MM-DD or YYYY-MM-DD-preview", version)
+	}
+	return nil
+}
+
+// ValidateCloudInitScript checks that script is base64-encoded and decodes to
+// a cloud-config document or a shell script, i.e. starts with #cloud-config or #!.
+func ValidateCloudInitScript(script string) error {
+	decoded, err := base64.StdEncoding.DecodeString(script)
+	if err != nil {
+		return errors.Wrap(err, "decoding base64 cloud-init script")
+	}
+	if !strings.HasPrefix(string(decoded), "#cloud-config") && !strings.HasPrefix(string(decoded), "#!") {
+		return errors.New("invalid cloud-init script, expected a document starting with #cloud-config or #!")
+	}
+	return nil
+}
+
+// masterResources returns the resources of the given type that belong to the master pool
+// in the upgrade template.
+func masterResources(templateMap map[string]interface{}, resourceType string) []map[string]interface{} {
+	var masters []map[string]interface{}
+	resources, ok := templateMap["resources"].([]interface{})
+	if !ok {
+		return masters
+	}
+	for _, resource := range resources {
+		resourceMap, ok := resource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t, _ := resourceMap["type"].(string)
+		name, _ := resourceMap["name"].(string)
+		if t == resourceType && strings.Contains(name, masterVMNamePrefixVariable) {
+			masters = append(masters, resourceMap)
+		}
+	}
+	return masters
+}
+
+// resourceProperties returns the properties map of an ARM resource, creating it if missing.
+func resourceProperties(resourceMap map[string]interface{}) map[string]interface{} {
+	properties, ok := resourceMap["properties"].(map[string]interface{})
+	if !ok {
+		properties = map[string]interface{}{}
+		resourceMap["properties"] = properties
+	}
+	return properties
+}
+
+// customizeTemplate applies the UpgradeMasterNode options to the master resources
+// of the upgrade template before it is deployed.
+func (kmn *UpgradeMasterNode) customizeTemplate() error {
+	if kmn.DeploymentMode != "" {
+		if err := ValidateDeploymentMode(kmn.DeploymentMode); err != nil {
+			return err
+		}
+	}
+	if kmn.VMAPIVersion != "" {
+		if err := ValidateVMAPIVersion(kmn.VMAPIVersion); err != nil {
+			return err
+		}
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			vm["apiVersion"] = kmn.VMAPIVersion
+		}
+	}
+	if kmn.dedicatedHostID != "" {
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			resourceProperties(vm)["host"] = map[string]interface{}{
+				"id": kmn.dedicatedHostID,
+			}
+		}
+	}
+	if kmn.CloudInitScript != "" {
+		if err := ValidateCloudInitScript(kmn.CloudInitScript); err != nil {
+			return err
+		}
+		kmn.TemplateMap["variables"].(map[string]interface{})[masterCloudInitScriptVariable] = kmn.CloudInitScript
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			properties := resourceProperties(vm)
+			osProfile, ok := properties["osProfile"].(map[string]interface{})
+			if !ok {
+				osProfile = map[string]interface{}{}
+				properties["osProfile"] = osProfile
+			}
+			osProfile["customData"] = "[variables('" + masterCloudInitScriptVariable + "')]"
+		}
+	}
+	if kmn.OSProfile != nil {
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.setOSProfile(vm)
+		}
+	}
+	if kmn.TargetAvailabilityZone != "" {
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			vm["zones"] = []interface{}{kmn.TargetAvailabilityZone}
+		}
+	}
+	if kmn.UltraDiskEnabled {
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			properties := resourceProperties(vm)
+			capabilities, ok := properties["additionalCapabilities"].(map[string]interface{})
+			if !ok {
+				capabilities = map[string]interface{}{}
+				properties["additionalCapabilities"] = capabilities
+			}
+			capabilities["ultraSSDEnabled"] = true
+		}
+	}
+	if kmn.OSDiskStorageAccountType != "" {
+		if err := ValidateOSDiskStorageAccountType(kmn.OSDiskStorageAccountType); err != nil {
+			return err
+		}
+		kmn.TemplateMap["variables"].(map[string]interface{})[masterOSDiskStorageAccountTypeVariable] = kmn.OSDiskStorageAccountType
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.setOSDiskStorageAccountType(vm)
+		}
+	}
+	if kmn.OSDiskCachingMode != "" {
+		if err := ValidateOSDiskCachingMode(kmn.OSDiskCachingMode); err != nil {
+			return err
+		}
+		kmn.TemplateMap["variables"].(map[string]interface{})[masterOSDiskCachingModeVariable] = kmn.OSDiskCachingMode
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.setOSDiskCachingMode(vm)
+		}
+	}
+	if kmn.AttachEtcdDataDisk {
+		if err := kmn.validateEtcdDataDisk(); err != nil {
+			return err
+		}
+		kmn.addEtcdDataDisk()
+	}
+	if kmn.DiskEncryptionSetID != "" {
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.setDiskEncryptionSet(vm)
+		}
+	}
+	if kmn.BootDiagnosticsEnabled {
+		if err := kmn.validateBootDiagnostics(); err != nil {
+			return err
+		}
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.setBootDiagnostics(vm)
+		}
+	}
+	if kmn.VMPriority != "" || kmn.EvictionPolicy != "" {
+		if err := ValidateVMPriority(kmn.VMPriority, kmn.EvictionPolicy); err != nil {
+			return err
+		}
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.setVMPriority(vm)
+		}
+	}
+	if kmn.AcceleratedNetworking {
+		for _, nic := range masterResources(kmn.TemplateMap, nicResourceType) {
+			resourceProperties(nic)["enableAcceleratedNetworking"] = true
+		}
+	}
+	if len(kmn.ApplicationSecurityGroups) > 0 {
+		if err := kmn.validateApplicationSecurityGroups(); err != nil {
+			return err
+		}
+		for _, nic := range masterResources(kmn.TemplateMap, nicResourceType) {
+			kmn.addApplicationSecurityGroups(nic)
+		}
+	}
+	if kmn.ReplacementSubnetID != "" {
+		kmn.TemplateMap["variables"].(map[string]interface{})[masterReplacementSubnetIDVariable] = kmn.ReplacementSubnetID
+		for _, nic := range masterResources(kmn.TemplateMap, nicResourceType) {
+			ipConfigurations, _ := resourceProperties(nic)["ipConfigurations"].([]interface{})
+			for _, ipConfiguration := range ipConfigurations {
+				if ipConfigurationMap, ok := ipConfiguration.(map[string]interface{}); ok {
+					resourceProperties(ipConfigurationMap)["subnet"] = map[string]interface{}{
+						"id": "[variables('" + masterReplacementSubnetIDVariable + "')]",
+					}
+				}
+			}
+		}
+	}
+	if kmn.peeredVNet != "" {
+		kmn.setPeeredVNet()
+	}
+	if kmn.hasIdentity() {
+		if err := kmn.validateIdentities(); err != nil {
+			return err
+		}
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			kmn.addIdentity(vm)
+		}
+	}
+	if len(kmn.ResourceTags) > 0 {
+		kmn.addResourceTags()
+	}
+	if kmn.ProximityPlacementGroupID != "" {
+		for _, vm := range masterResources(kmn.TemplateMap, vmResourceType) {
+			resourceProperties(vm)["proximityPlacementGroup"] = map[string]interface{}{
+				"id": kmn.ProximityPlacementGroupID,
+			}
+		}
+	}
+	if len(kmn.ExplicitDependencies) > 0 {
+		return kmn.addExplicitDependencies()
+	}
+	return nil
+}
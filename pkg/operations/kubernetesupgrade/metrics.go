@@ -0,0 +1,132 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultMetricsAddress is the address the MetricsServer listens on unless another is given
+const DefaultMetricsAddress = ":9090"
+
+// Compiler to verify MetricsServer implements UpgradeReporter
+var _ UpgradeReporter = &MetricsServer{}
+
+// MetricsServer is an UpgradeReporter serving the upgrade progress as Prometheus metrics on /metrics
+// while the upgrade runs: the nodes_upgraded_total and nodes_failed_total counters, the
+// upgrade_duration_seconds the upgrade operations took and the upgrade_in_progress gauge
+type MetricsServer struct {
+	Address string
+
+	registry        *prometheus.Registry
+	nodesUpgraded   prometheus.Counter
+	nodesFailed     prometheus.Counter
+	upgradeDuration prometheus.Counter
+	inProgress      prometheus.Gauge
+
+	mu        sync.Mutex
+	startTime time.Time
+	server    *http.Server
+	listener  net.Listener
+}
+
+// NewMetricsServer returns a MetricsServer listening on address, DefaultMetricsAddress if empty, once started
+func NewMetricsServer(address string) *MetricsServer {
+	if address == "" {
+		address = DefaultMetricsAddress
+	}
+	s := &MetricsServer{
+		Address:  address,
+		registry: prometheus.NewRegistry(),
+		nodesUpgraded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nodes_upgraded_total",
+			Help: "Number of nodes upgraded.",
+		}),
+		nodesFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nodes_failed_total",
+			Help: "Number of nodes that failed to upgrade.",
+		}),
+		upgradeDuration: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "upgrade_duration_seconds",
+			Help: "Seconds the completed and failed upgrade operations took.",
+		}),
+		inProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "upgrade_in_progress",
+			Help: "1 while an upgrade operation is running, 0 otherwise.",
+		}),
+	}
+	s.registry.MustRegister(s.nodesUpgraded, s.nodesFailed, s.upgradeDuration, s.inProgress)
+	return s
+}
+
+// Start listens on Address and serves the metrics in a background goroutine until Stop
+func (s *MetricsServer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	listener, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return errors.Wrapf(err, "listening on %s for the Prometheus metrics", s.Address)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux}
+	s.listener, s.server = listener, server
+	go func() {
+		// Serve returns http.ErrServerClosed once stopped
+		_ = server.Serve(listener)
+	}()
+	return nil
+}
+
+// Addr returns the address the metrics are served on once started, e.g. the port picked for ":0"
+func (s *MetricsServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return s.Address
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop stops serving the metrics
+func (s *MetricsServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Shutdown(ctx)
+	s.server, s.listener = nil, nil
+	return err
+}
+
+// Report updates the metrics with the event
+func (s *MetricsServer) Report(event UpgradeEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch event.Type {
+	case UpgradeStartedEvent:
+		s.startTime = event.Time
+		s.inProgress.Set(1)
+	case NodeUpgradedEvent:
+		s.nodesUpgraded.Inc()
+	case NodeUpgradeFailedEvent:
+		s.nodesFailed.Inc()
+	case UpgradeCompletedEvent, UpgradeFailedEvent:
+		if !s.startTime.IsZero() && event.Time.After(s.startTime) {
+			s.upgradeDuration.Add(event.Time.Sub(s.startTime).Seconds())
+		}
+		s.startTime = time.Time{}
+		s.inProgress.Set(0)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Metrics server tests", func() {
+	var (
+		start  time.Time
+		server *MetricsServer
+	)
+
+	report := func(event UpgradeEvent, after time.Duration) {
+		event.Time = start.Add(after)
+		Expect(server.Report(event)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		start = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+		server = NewMetricsServer("127.0.0.1:0")
+	})
+
+	It("Should count the upgraded and failed nodes and the upgrade duration", func() {
+		Expect(testutil.ToFloat64(server.inProgress)).To(Equal(0.0))
+
+		report(UpgradeEvent{Type: UpgradeStartedEvent}, 0)
+		Expect(testutil.ToFloat64(server.inProgress)).To(Equal(1.0))
+		report(UpgradeEvent{Type: NodeDrainingEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-12345678-0"}, time.Minute)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "master", NodeName: "k8s-master-12345678-0"}, 5*time.Minute)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-12345678-0"}, 8*time.Minute)
+		report(UpgradeEvent{Type: NodeUpgradeFailedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-12345678-1"}, 9*time.Minute)
+		report(UpgradeEvent{Type: UpgradeFailedEvent}, 10*time.Minute)
+
+		Expect(testutil.ToFloat64(server.nodesUpgraded)).To(Equal(2.0))
+		Expect(testutil.ToFloat64(server.nodesFailed)).To(Equal(1.0))
+		Expect(testutil.ToFloat64(server.upgradeDuration)).To(Equal(600.0))
+		Expect(testutil.ToFloat64(server.inProgress)).To(Equal(0.0))
+
+		report(UpgradeEvent{Type: UpgradeStartedEvent}, 20*time.Minute)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-12345678-1"}, 23*time.Minute)
+		report(UpgradeEvent{Type: UpgradeCompletedEvent}, 25*time.Minute)
+		Expect(testutil.ToFloat64(server.nodesUpgraded)).To(Equal(3.0))
+		Expect(testutil.ToFloat64(server.upgradeDuration)).To(Equal(900.0))
+	})
+
+	It("Should serve the metrics over HTTP until stopped", func() {
+		Expect(server.Start()).To(Succeed())
+		defer func() { _ = server.Stop(context.Background()) }()
+		report(UpgradeEvent{Type: UpgradeStartedEvent}, 0)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "master", NodeName: "k8s-master-12345678-0"}, time.Minute)
+
+		resp, err := http.Get("http://" + server.Addr() + "/metrics")
+		Expect(err).NotTo(HaveOccurred())
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring("nodes_upgraded_total 1\n"))
+		Expect(string(body)).To(ContainSubstring("nodes_failed_total 0\n"))
+		Expect(string(body)).To(ContainSubstring("upgrade_in_progress 1\n"))
+
+		addr := server.Addr()
+		Expect(server.Stop(context.Background())).To(Succeed())
+		_, err = http.Get("http://" + addr + "/metrics")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should fail to start on an address in use", func() {
+		Expect(server.Start()).To(Succeed())
+		defer func() { _ = server.Stop(context.Background()) }()
+
+		err := NewMetricsServer(server.Addr()).Start()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("listening on " + server.Addr() + " for the Prometheus metrics"))
+	})
+})
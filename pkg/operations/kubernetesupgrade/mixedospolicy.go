@@ -0,0 +1,150 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// MixedOSConcurrencyPolicy sets the order in which the Linux and Windows agent nodes of a cluster are upgraded
+type MixedOSConcurrencyPolicy string
+
+const (
+	// LinearLinuxFirst upgrades all the Linux agent nodes before the Windows ones
+	LinearLinuxFirst MixedOSConcurrencyPolicy = "LinearLinuxFirst"
+	// LinearWindowsFirst upgrades all the Windows agent nodes before the Linux ones
+	LinearWindowsFirst MixedOSConcurrencyPolicy = "LinearWindowsFirst"
+	// Interleaved alternates between a Linux and a Windows agent node, starting with Linux
+	Interleaved MixedOSConcurrencyPolicy = "Interleaved"
+)
+
+// Validate returns an error if the policy is not empty nor one of the known policies
+func (p MixedOSConcurrencyPolicy) Validate() error {
+	switch p {
+	case "", LinearLinuxFirst, LinearWindowsFirst, Interleaved:
+		return nil
+	default:
+		return errors.Errorf("unknown mixed OS concurrency policy %q, expected one of %s, %s or %s", p, LinearLinuxFirst, LinearWindowsFirst, Interleaved)
+	}
+}
+
+// scaleSetVMToUpgrade is a VMSS instance to upgrade, first and last flag the first
+// and last instances of the VMSS in the upgrade order
+type scaleSetVMToUpgrade struct {
+	vmss  *AgentPoolScaleSet
+	vm    AgentPoolScaleSetVM
+	first bool
+	last  bool
+}
+
+// scaleSetVMUpgradeOrder returns the VMSS instances to upgrade in the order set by MixedOSConcurrencyPolicy.
+// Without policy, the instances are upgraded one VMSS after the other.
+func (ku *Upgrader) scaleSetVMUpgradeOrder() []scaleSetVMToUpgrade {
+	var linux, windows []scaleSetVMToUpgrade
+	var all []scaleSetVMToUpgrade
+	for i := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
+		vmss := &ku.ClusterTopology.AgentPoolScaleSetsToUpgrade[i]
+		for j, vm := range vmss.VMsToUpgrade {
+			node := scaleSetVMToUpgrade{
+				vmss:  vmss,
+				vm:    vm,
+				first: j == 0,
+				last:  j == len(vmss.VMsToUpgrade)-1,
+			}
+			all = append(all, node)
+			if vmss.IsWindows {
+				windows = append(windows, node)
+			} else {
+				linux = append(linux, node)
+			}
+		}
+	}
+
+	switch ku.MixedOSConcurrencyPolicy {
+	case LinearLinuxFirst:
+		return append(linux, windows...)
+	case LinearWindowsFirst:
+		return append(windows, linux...)
+	case Interleaved:
+		var nodes []scaleSetVMToUpgrade
+		for _, i := range interleavedOrder(len(linux), len(windows)) {
+			if i.windows {
+				nodes = append(nodes, windows[i.index])
+			} else {
+				nodes = append(nodes, linux[i.index])
+			}
+		}
+		return nodes
+	default:
+		return all
+	}
+}
+
+// agentPoolUpgradeOrder returns the availability set agent pools in the order set by MixedOSConcurrencyPolicy.
+// The nodes of an availability set pool are upgraded together, so Interleaved alternates between
+// a Linux and a Windows pool. Pools of the same OS are sorted by name.
+func (ku *Upgrader) agentPoolUpgradeOrder() []*AgentPoolTopology {
+	names := make([]string, 0, len(ku.ClusterTopology.AgentPools))
+	for name := range ku.ClusterTopology.AgentPools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	isWindows := map[string]bool{}
+	for _, app := range ku.ClusterTopology.DataModel.Properties.AgentPoolProfiles {
+		isWindows[app.Name] = app.IsWindows()
+	}
+	var linux, windows []*AgentPoolTopology
+	var all []*AgentPoolTopology
+	for _, name := range names {
+		pool := ku.ClusterTopology.AgentPools[name]
+		all = append(all, pool)
+		if pool.Name != nil && isWindows[*pool.Name] {
+			windows = append(windows, pool)
+		} else {
+			linux = append(linux, pool)
+		}
+	}
+
+	switch ku.MixedOSConcurrencyPolicy {
+	case LinearLinuxFirst:
+		return append(linux, windows...)
+	case LinearWindowsFirst:
+		return append(windows, linux...)
+	case Interleaved:
+		var pools []*AgentPoolTopology
+		for _, i := range interleavedOrder(len(linux), len(windows)) {
+			if i.windows {
+				pools = append(pools, windows[i.index])
+			} else {
+				pools = append(pools, linux[i.index])
+			}
+		}
+		return pools
+	default:
+		return all
+	}
+}
+
+type osIndex struct {
+	windows bool
+	index   int
+}
+
+// interleavedOrder alternates between the indexes of linuxCount Linux and windowsCount Windows
+// items, starting with Linux, then appends the remaining items of the larger list
+func interleavedOrder(linuxCount, windowsCount int) []osIndex {
+	order := make([]osIndex, 0, linuxCount+windowsCount)
+	for i := 0; i < linuxCount || i < windowsCount; i++ {
+		if i < linuxCount {
+			order = append(order, osIndex{windows: false, index: i})
+		}
+		if i < windowsCount {
+			order = append(order, osIndex{windows: true, index: i})
+		}
+	}
+	return order
+}
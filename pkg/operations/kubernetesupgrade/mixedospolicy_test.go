@@ -0,0 +1,165 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newTestScaleSet(name string, isWindows bool, count int) AgentPoolScaleSet {
+	vmss := AgentPoolScaleSet{
+		Name:      name,
+		Sku:       compute.Sku{Name: to.StringPtr("Standard_D2_v2"), Capacity: to.Int64Ptr(int64(count))},
+		Location:  "westus2",
+		IsWindows: isWindows,
+	}
+	for i := 0; i < count; i++ {
+		vmss.VMsToUpgrade = append(vmss.VMsToUpgrade, AgentPoolScaleSetVM{
+			Name:       fmt.Sprintf("%s%06d", name, i),
+			InstanceID: fmt.Sprintf("%d", i),
+		})
+	}
+	return vmss
+}
+
+func scaleSetVMNames(nodes []scaleSetVMToUpgrade) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.vm.Name)
+	}
+	return names
+}
+
+func agentPoolNames(pools []*AgentPoolTopology) []string {
+	var names []string
+	for _, pool := range pools {
+		names = append(names, *pool.Name)
+	}
+	return names
+}
+
+var _ = Describe("Mixed OS concurrency policy tests", func() {
+	var u *Upgrader
+
+	BeforeEach(func() {
+		u = newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+		u.ClusterTopology.ResourceGroup = "TestRg"
+		u.ClusterTopology.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{
+			newTestScaleSet("k8s-linux1-12345678-vmss", false, 2),
+			newTestScaleSet("akswin1", true, 3),
+			newTestScaleSet("k8s-linux2-12345678-vmss", false, 1),
+		}
+
+		u.DataModel.Properties.AgentPoolProfiles = []*api.AgentPoolProfile{
+			{Name: "linux1", Count: 1},
+			{Name: "win1", Count: 1, OSType: api.Windows},
+			{Name: "win2", Count: 1, OSType: api.Windows},
+			{Name: "linux2", Count: 1},
+			{Name: "linux3", Count: 1},
+		}
+		u.ClusterTopology.AgentPools = map[string]*AgentPoolTopology{}
+		for _, app := range u.DataModel.Properties.AgentPoolProfiles {
+			u.ClusterTopology.AgentPools[app.Name] = &AgentPoolTopology{Name: to.StringPtr(app.Name)}
+		}
+	})
+
+	It("Should validate the policy", func() {
+		for _, policy := range []MixedOSConcurrencyPolicy{"", LinearLinuxFirst, LinearWindowsFirst, Interleaved} {
+			Expect(policy.Validate()).To(Succeed())
+		}
+		err := MixedOSConcurrencyPolicy("WindowsOnly").Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`unknown mixed OS concurrency policy "WindowsOnly"`))
+	})
+
+	It("Should upgrade one VMSS after the other without policy", func() {
+		Expect(scaleSetVMNames(u.scaleSetVMUpgradeOrder())).To(Equal([]string{
+			"k8s-linux1-12345678-vmss000000", "k8s-linux1-12345678-vmss000001",
+			"akswin1000000", "akswin1000001", "akswin1000002",
+			"k8s-linux2-12345678-vmss000000",
+		}))
+		Expect(agentPoolNames(u.agentPoolUpgradeOrder())).To(Equal([]string{"linux1", "linux2", "linux3", "win1", "win2"}))
+	})
+
+	It("Should upgrade the Linux nodes first with LinearLinuxFirst", func() {
+		u.MixedOSConcurrencyPolicy = LinearLinuxFirst
+
+		Expect(scaleSetVMNames(u.scaleSetVMUpgradeOrder())).To(Equal([]string{
+			"k8s-linux1-12345678-vmss000000", "k8s-linux1-12345678-vmss000001",
+			"k8s-linux2-12345678-vmss000000",
+			"akswin1000000", "akswin1000001", "akswin1000002",
+		}))
+		Expect(agentPoolNames(u.agentPoolUpgradeOrder())).To(Equal([]string{"linux1", "linux2", "linux3", "win1", "win2"}))
+	})
+
+	It("Should upgrade the Windows nodes first with LinearWindowsFirst", func() {
+		u.MixedOSConcurrencyPolicy = LinearWindowsFirst
+
+		Expect(scaleSetVMNames(u.scaleSetVMUpgradeOrder())).To(Equal([]string{
+			"akswin1000000", "akswin1000001", "akswin1000002",
+			"k8s-linux1-12345678-vmss000000", "k8s-linux1-12345678-vmss000001",
+			"k8s-linux2-12345678-vmss000000",
+		}))
+		Expect(agentPoolNames(u.agentPoolUpgradeOrder())).To(Equal([]string{"win1", "win2", "linux1", "linux2", "linux3"}))
+	})
+
+	It("Should alternate Linux and Windows nodes with Interleaved", func() {
+		u.MixedOSConcurrencyPolicy = Interleaved
+
+		nodes := u.scaleSetVMUpgradeOrder()
+		Expect(scaleSetVMNames(nodes)).To(Equal([]string{
+			"k8s-linux1-12345678-vmss000000", "akswin1000000",
+			"k8s-linux1-12345678-vmss000001", "akswin1000001",
+			"k8s-linux2-12345678-vmss000000", "akswin1000002",
+		}))
+		Expect(agentPoolNames(u.agentPoolUpgradeOrder())).To(Equal([]string{"linux1", "win1", "linux2", "win2", "linux3"}))
+
+		// the capacity of a VMSS is increased before its first node, the VMSS is complete after its last one
+		for _, node := range nodes {
+			Expect(node.first).To(Equal(node.vm.InstanceID == "0"))
+			Expect(node.last).To(Equal(node.vm.InstanceID == fmt.Sprintf("%d", len(node.vmss.VMsToUpgrade)-1)))
+		}
+	})
+
+	It("Should upgrade the VMSS nodes in the policy order", func() {
+		reporter := &fakeReporter{}
+		u.Reporters = []UpgradeReporter{reporter}
+		u.SkipCapacityCheck = true
+		u.MixedOSConcurrencyPolicy = Interleaved
+		// the upgrade template of the VMSS is generated from the default single pool model
+		u.DataModel = api.CreateMockContainerService("testcluster", "1.18.8", 1, 1, false)
+		mockClient := u.Client.(*armhelpers.MockAKSEngineClient)
+		mockClient.FakeListVirtualMachineScaleSetVMsResult = func() []compute.VirtualMachineScaleSetVM {
+			return []compute.VirtualMachineScaleSetVM{{InstanceID: to.StringPtr("9"), VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
+				OsProfile: &compute.OSProfile{ComputerName: to.StringPtr("newnode")},
+			}}}
+		}
+
+		Expect(u.upgradeAgentScaleSets(context.Background())).To(Succeed())
+
+		var upgraded []string
+		var pools []string
+		for _, event := range reporter.events {
+			if event.Type == NodeUpgradedEvent {
+				upgraded = append(upgraded, event.NodeName)
+				pools = append(pools, event.PoolName)
+			}
+		}
+		Expect(upgraded).To(Equal([]string{
+			"k8s-linux1-12345678-vmss000000", "akswin1000000",
+			"k8s-linux1-12345678-vmss000001", "akswin1000001",
+			"k8s-linux2-12345678-vmss000000", "akswin1000002",
+		}))
+		Expect(pools[1]).To(Equal("win1"))
+		Expect(*u.ClusterTopology.AgentPoolScaleSetsToUpgrade[1].Sku.Capacity).To(Equal(int64(4)))
+	})
+})
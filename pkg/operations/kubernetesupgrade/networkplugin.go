@@ -0,0 +1,58 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/pkg/errors"
+)
+
+// networkPluginCompatibilityMatrix lists the Kubernetes versions that cannot be reached with
+// the network plugin version deployed by previous releases.
+// It is kept as JSON so the matrix can be maintained without touching the code;
+// go:embed is not available with the Go version this module targets.
+const networkPluginCompatibilityMatrix = `[
+	{
+		"networkPlugin": "azure",
+		"kubernetesVersion": "1.22.0",
+		"message": "Azure CNI 1.x is not compatible with Kubernetes 1.22 and later, update the Azure CNI plugin before upgrading"
+	}
+]`
+
+// networkPluginCompatibility is an entry of networkPluginCompatibilityMatrix
+type networkPluginCompatibility struct {
+	NetworkPlugin string `json:"networkPlugin"`
+	// KubernetesVersion is the first Kubernetes version the plugin must be updated for
+	KubernetesVersion string `json:"kubernetesVersion"`
+	Message           string `json:"message"`
+}
+
+// NetworkPluginCompatibilityCheck returns an error when upgrading from fromK8sVersion to toK8sVersion
+// crosses a Kubernetes version the network plugin is known to be incompatible with.
+// An empty fromK8sVersion is treated as older than any version in the matrix.
+func NetworkPluginCompatibilityCheck(fromK8sVersion, toK8sVersion string, networkPlugin string) error {
+	if networkPlugin == "" {
+		networkPlugin = api.DefaultNetworkPlugin
+	}
+	var matrix []networkPluginCompatibility
+	if err := json.Unmarshal([]byte(networkPluginCompatibilityMatrix), &matrix); err != nil {
+		return errors.Wrap(err, "parsing network plugin compatibility matrix")
+	}
+	for _, entry := range matrix {
+		if entry.NetworkPlugin != networkPlugin {
+			continue
+		}
+		if fromK8sVersion != "" && common.IsKubernetesVersionGe(fromK8sVersion, entry.KubernetesVersion) {
+			continue
+		}
+		if common.IsKubernetesVersionGe(toK8sVersion, entry.KubernetesVersion) {
+			return errors.Errorf("networkPlugin %s is not compatible with an upgrade from Kubernetes %s to %s: %s",
+				networkPlugin, fromK8sVersion, toK8sVersion, entry.Message)
+		}
+	}
+	return nil
+}
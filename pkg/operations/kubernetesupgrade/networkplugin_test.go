@@ -0,0 +1,39 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Network plugin compatibility tests", func() {
+	It("Should fail when an upgrade crosses an incompatible Kubernetes version", func() {
+		err := NetworkPluginCompatibilityCheck("1.21.2", "1.22.1", "azure")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("networkPlugin azure is not compatible with an upgrade from Kubernetes 1.21.2 to 1.22.1"))
+		Expect(err.Error()).To(ContainSubstring("Azure CNI"))
+	})
+
+	It("Should fail when the current version is unknown and the target is incompatible", func() {
+		Expect(NetworkPluginCompatibilityCheck("", "1.22.1", "azure")).NotTo(Succeed())
+	})
+
+	It("Should succeed when the cluster already runs a compatible version", func() {
+		Expect(NetworkPluginCompatibilityCheck("1.22.1", "1.23.0", "azure")).To(Succeed())
+	})
+
+	It("Should succeed when the target version is below the incompatible version", func() {
+		Expect(NetworkPluginCompatibilityCheck("1.18.8", "1.19.1", "azure")).To(Succeed())
+	})
+
+	It("Should succeed for network plugins without known incompatibilities", func() {
+		Expect(NetworkPluginCompatibilityCheck("1.21.2", "1.22.1", "kubenet")).To(Succeed())
+		Expect(NetworkPluginCompatibilityCheck("1.21.2", "1.22.1", "")).To(Succeed())
+	})
+
+	It("Should ship a valid compatibility matrix", func() {
+		Expect(NetworkPluginCompatibilityCheck("1.0.0", "1.0.1", "azure")).To(Succeed())
+	})
+})
@@ -0,0 +1,136 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/pkg/errors"
+)
+
+// sortNodesForGroups sorts the master VMs and the VMs of each scale set by node index, and the scale sets
+// by pool name, so that the node groups of NodeGroupSize are the same each time the upgrade runs.
+// The availability set agent pools are already upgraded by pool name and node index.
+func (ku *Upgrader) sortNodesForGroups() {
+	if ku.ClusterTopology.MasterVMs != nil {
+		sortVMsByIndex(*ku.ClusterTopology.MasterVMs)
+	}
+	scaleSets := ku.ClusterTopology.AgentPoolScaleSetsToUpgrade
+	sort.SliceStable(scaleSets, func(i, j int) bool {
+		return scaleSets[i].poolName() < scaleSets[j].poolName()
+	})
+	for _, vmss := range scaleSets {
+		vms := vmss.VMsToUpgrade
+		sort.SliceStable(vms, func(i, j int) bool {
+			return instanceIndex(vms[i].InstanceID) < instanceIndex(vms[j].InstanceID)
+		})
+	}
+}
+
+// waitForNodeGroup pauses the upgrade before nodeName if NodeGroupSize nodes were upgraded since the last pause,
+// for NodeGroupPauseAfter or until PauseCheckFile is created, whichever comes first.
+// The nodes of a pool with its own NodeGroupSize are counted apart from the other nodes of the cluster.
+func (ku *Upgrader) waitForNodeGroup(ctx context.Context, poolName, nodeName string) error {
+	size := ku.nodeGroupSize(poolName)
+	if size <= 0 {
+		return nil
+	}
+	var started int
+	if ku.poolUpgradeConfig(poolName).NodeGroupSize > 0 {
+		if ku.poolNodeGroupNodes == nil {
+			ku.poolNodeGroupNodes = map[string]int{}
+		}
+		started = ku.poolNodeGroupNodes[poolName]
+		ku.poolNodeGroupNodes[poolName]++
+	} else {
+		started = ku.nodeGroupNodes
+		ku.nodeGroupNodes++
+	}
+	if started == 0 || started%size != 0 {
+		return nil
+	}
+	group := started / size
+	if ku.PauseCheckFile != "" {
+		// a pause check file left from an earlier pause must not end this one
+		if _, err := consumePauseCheckFile(ku.PauseCheckFile); err != nil {
+			return err
+		}
+	}
+	switch {
+	case ku.NodeGroupPauseAfter > 0 && ku.PauseCheckFile != "":
+		ku.logger.Infof("Node group %d upgraded, pausing for %v before node %s, create %s to continue earlier", group, ku.NodeGroupPauseAfter, nodeName, ku.PauseCheckFile)
+	case ku.NodeGroupPauseAfter > 0:
+		ku.logger.Infof("Node group %d upgraded, pausing for %v before node %s", group, ku.NodeGroupPauseAfter, nodeName)
+	default:
+		ku.logger.Infof("Node group %d upgraded, pausing before node %s, create %s to continue", group, nodeName, ku.PauseCheckFile)
+	}
+
+	start := time.Now()
+	var deadline <-chan time.Time
+	if ku.NodeGroupPauseAfter > 0 {
+		timer := time.NewTimer(ku.NodeGroupPauseAfter)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting to upgrade node group %d", group+1)
+		case <-deadline:
+			ku.logger.Infof("Starting node group %d after a %v pause", group+1, time.Since(start).Round(time.Second))
+			return nil
+		case <-time.After(pauseCheckInterval):
+		}
+		if ku.PauseCheckFile == "" {
+			continue
+		}
+		resumed, err := consumePauseCheckFile(ku.PauseCheckFile)
+		if err != nil {
+			return err
+		}
+		if resumed {
+			ku.logger.Infof("Starting node group %d after a %v pause", group+1, time.Since(start).Round(time.Second))
+			return nil
+		}
+	}
+}
+
+// sortedVMIndexes returns the node indexes of vms in increasing order
+func sortedVMIndexes(vms map[int]*vmInfo) []int {
+	indexes := make([]int, 0, len(vms))
+	for i := range vms {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func sortVMsByIndex(vms []compute.VirtualMachine) {
+	index := func(vm compute.VirtualMachine) int {
+		var osType compute.OperatingSystemTypes
+		if vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.OsDisk != nil {
+			osType = vm.StorageProfile.OsDisk.OsType
+		}
+		i, _ := utils.GetVMNameIndex(osType, *vm.Name)
+		return i
+	}
+	sort.SliceStable(vms, func(i, j int) bool {
+		return index(vms[i]) < index(vms[j])
+	})
+}
+
+// instanceIndex returns the numeric scale set VM instance ID, instance IDs that are not numbers sort last
+func instanceIndex(instanceID string) int {
+	i, err := strconv.Atoi(instanceID)
+	if err != nil {
+		return math.MaxInt32
+	}
+	return i
+}
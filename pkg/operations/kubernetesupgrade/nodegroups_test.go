@@ -0,0 +1,132 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Node group tests", func() {
+	var (
+		u                    *Upgrader
+		dir                  string
+		defaultCheckInterval time.Duration
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "nodegroups")
+		Expect(err).NotTo(HaveOccurred())
+		defaultCheckInterval = pauseCheckInterval
+		pauseCheckInterval = 10 * time.Millisecond
+		u = newTestCRDUpgrader("1.18.8", nil)
+		u.NodeGroupSize = 2
+		u.NodeGroupPauseAfter = time.Hour
+		u.PauseCheckFile = filepath.Join(dir, "continue")
+	})
+
+	AfterEach(func() {
+		pauseCheckInterval = defaultCheckInterval
+		os.RemoveAll(dir)
+	})
+
+	// waitForNodes calls waitForNodeGroup for n nodes and returns the indexes of the nodes the upgrade paused before,
+	// the pauses ending with the pause check file
+	waitForNodes := func(n int) []int {
+		var paused []int
+		for i := 0; i < n; i++ {
+			done := make(chan error)
+			go func(i int) {
+				done <- u.waitForNodeGroup(context.Background(), "agentpool1", fmt.Sprintf("k8s-agentpool1-12345678-%d", i))
+			}(i)
+			select {
+			case err := <-done:
+				Expect(err).NotTo(HaveOccurred())
+			case <-time.After(50 * time.Millisecond):
+				paused = append(paused, i)
+				Expect(ioutil.WriteFile(u.PauseCheckFile, nil, 0644)).To(Succeed())
+				Eventually(done).Should(Receive(BeNil()))
+			}
+		}
+		return paused
+	}
+
+	It("Should pause after each group of NodeGroupSize nodes", func() {
+		Expect(waitForNodes(5)).To(Equal([]int{2, 4}))
+		Expect(u.PauseCheckFile).NotTo(BeAnExistingFile())
+	})
+
+	It("Should not pause unless NodeGroupSize is set", func() {
+		u.NodeGroupSize = 0
+		Expect(waitForNodes(5)).To(BeEmpty())
+	})
+
+	It("Should count the nodes of a pool with its own NodeGroupSize apart", func() {
+		u.NodeGroupSize = 0
+		u.NodeGroupPauseAfter = 0
+		u.PoolUpgradeConfigs = map[string]PoolUpgradeConfig{"agentpool1": {NodeGroupSize: 3}}
+		Expect(u.hasNodeGroups()).To(BeTrue())
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool2", "k8s-agentpool2-12345678-0")).To(Succeed())
+
+		Expect(waitForNodes(7)).To(Equal([]int{3, 6}))
+	})
+
+	It("Should continue after NodeGroupPauseAfter", func() {
+		u.NodeGroupPauseAfter = 20 * time.Millisecond
+		u.PauseCheckFile = ""
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")).To(Succeed())
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-1")).To(Succeed())
+
+		start := time.Now()
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-2")).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+	})
+
+	It("Should not continue on a pause check file created before the pause", func() {
+		u.NodeGroupPauseAfter = 100 * time.Millisecond
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")).To(Succeed())
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-1")).To(Succeed())
+		Expect(ioutil.WriteFile(u.PauseCheckFile, nil, 0644)).To(Succeed())
+
+		start := time.Now()
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-2")).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+	})
+
+	It("Should stop pausing when the context is done", func() {
+		u.NodeGroupSize = 1
+		Expect(u.waitForNodeGroup(context.Background(), "agentpool1", "k8s-agentpool1-12345678-0")).To(Succeed())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := u.waitForNodeGroup(ctx, "agentpool1", "k8s-agentpool1-12345678-1")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("waiting to upgrade node group 2: context canceled"))
+	})
+
+	It("Should sort the nodes by pool name and node index", func() {
+		u.MasterVMs = &[]compute.VirtualMachine{
+			newTestStateVM("k8s-master-12345678-2", compute.Linux),
+			newTestStateVM("k8s-master-12345678-0", compute.Linux),
+			newTestStateVM("k8s-master-12345678-1", compute.Linux),
+		}
+		u.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{
+			{Name: "k8s-pool2-12345678-vmss", VMsToUpgrade: []AgentPoolScaleSetVM{{Name: "b", InstanceID: "10"}, {Name: "a", InstanceID: "9"}}},
+			{Name: "k8s-pool1-12345678-vmss", VMsToUpgrade: []AgentPoolScaleSetVM{{Name: "c", InstanceID: "1"}}},
+		}
+
+		u.sortNodesForGroups()
+		Expect(u.nodesToUpgrade()).To(Equal([]string{"k8s-master-12345678-0", "k8s-master-12345678-1", "k8s-master-12345678-2", "c", "a", "b"}))
+		Expect(sortedVMIndexes(map[int]*vmInfo{3: {}, 0: {}, 12: {}, 1: {}})).To(Equal([]int{0, 1, 3, 12}))
+	})
+})
@@ -0,0 +1,213 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// zoneLabel and legacyZoneLabel hold the availability zone of a node
+	zoneLabel       = "topology.kubernetes.io/zone"
+	legacyZoneLabel = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// NodeOrderingStrategy sets the order in which the nodes of an agent pool are upgraded.
+// Upgrader.NodeOrderingStrategy orders the nodes of each pool before the upgrade of the pool starts.
+type NodeOrderingStrategy interface {
+	// OrderNodes returns the names of the given agent nodes in upgrade order
+	OrderNodes(client kubernetes.Client, nodeNames []string) ([]string, error)
+}
+
+// OrderByPodCount upgrades the nodes running the fewest pods first, as they are the fastest to drain
+type OrderByPodCount struct{}
+
+// OrderNodes implements NodeOrderingStrategy
+func (OrderByPodCount) OrderNodes(client kubernetes.Client, nodeNames []string) ([]string, error) {
+	pods, err := client.ListAllPods()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods")
+	}
+	podCounts := map[string]int{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		podCounts[pod.Spec.NodeName]++
+	}
+	return orderNodes(nodeNames, func(a, b string) bool {
+		return podCounts[a] < podCounts[b]
+	}), nil
+}
+
+// OrderByNodeAge upgrades the oldest nodes first, the nodes not registered with the API server last
+type OrderByNodeAge struct{}
+
+// OrderNodes implements NodeOrderingStrategy
+func (OrderByNodeAge) OrderNodes(client kubernetes.Client, nodeNames []string) ([]string, error) {
+	nodes, err := listNodesByName(client)
+	if err != nil {
+		return nil, err
+	}
+	created := func(name string) time.Time {
+		if node, ok := nodes[name]; ok {
+			return node.CreationTimestamp.Time
+		}
+		return time.Time{}
+	}
+	return orderNodes(nodeNames, func(a, b string) bool {
+		createdA, createdB := created(a), created(b)
+		if createdA.IsZero() || createdB.IsZero() {
+			return !createdA.IsZero() && createdB.IsZero()
+		}
+		return createdA.Before(createdB)
+	}), nil
+}
+
+// OrderByZone upgrades the nodes one availability zone after the other in zone name order,
+// the nodes without zone last
+type OrderByZone struct{}
+
+// OrderNodes implements NodeOrderingStrategy
+func (OrderByZone) OrderNodes(client kubernetes.Client, nodeNames []string) ([]string, error) {
+	nodes, err := listNodesByName(client)
+	if err != nil {
+		return nil, err
+	}
+	zone := func(name string) string {
+		node, ok := nodes[name]
+		if !ok {
+			return ""
+		}
+		if z := node.Labels[zoneLabel]; z != "" {
+			return z
+		}
+		return node.Labels[legacyZoneLabel]
+	}
+	return orderNodes(nodeNames, func(a, b string) bool {
+		zoneA, zoneB := zone(a), zone(b)
+		if zoneA == "" || zoneB == "" {
+			return zoneA != "" && zoneB == ""
+		}
+		return zoneA < zoneB
+	}), nil
+}
+
+// orderNodes returns a copy of nodeNames stably sorted by less, which compares lowercase node names
+func orderNodes(nodeNames []string, less func(a, b string) bool) []string {
+	ordered := append([]string(nil), nodeNames...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return less(strings.ToLower(ordered[i]), strings.ToLower(ordered[j]))
+	})
+	return ordered
+}
+
+func listNodesByName(client kubernetes.Client) (map[string]*v1.Node, error) {
+	nodeList, err := client.ListNodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing nodes")
+	}
+	nodes := make(map[string]*v1.Node, len(nodeList.Items))
+	for i := range nodeList.Items {
+		nodes[nodeList.Items[i].Name] = &nodeList.Items[i]
+	}
+	return nodes, nil
+}
+
+// orderPoolNodes returns the nodes of the agent pool in the order set by NodeOrderingStrategy.
+// The nodes are returned as is without strategy, or if the strategy fails or does not return each node once.
+func (ku *Upgrader) orderPoolNodes(client kubernetes.Client, poolName string, nodeNames []string) []string {
+	if ku.NodeOrderingStrategy == nil || len(nodeNames) < 2 {
+		return nodeNames
+	}
+	ordered, err := ku.NodeOrderingStrategy.OrderNodes(client, nodeNames)
+	if err != nil {
+		ku.logger.Warnf("Failed to order the nodes of agent pool %s, upgrading them in node index order: %v", poolName, err)
+		return nodeNames
+	}
+	if !sameNodes(ordered, nodeNames) {
+		ku.logger.Warnf("The node order of agent pool %s does not match its nodes, upgrading them in node index order", poolName)
+		return nodeNames
+	}
+	ku.logger.Infof("Upgrading the nodes of agent pool %s in order %s", poolName, strings.Join(ordered, ", "))
+	return ordered
+}
+
+// sameNodes reports whether ordered holds each node of nodeNames once
+func sameNodes(ordered, nodeNames []string) bool {
+	if len(ordered) != len(nodeNames) {
+		return false
+	}
+	remaining := map[string]bool{}
+	for _, name := range nodeNames {
+		remaining[name] = true
+	}
+	for _, name := range ordered {
+		if !remaining[name] {
+			return false
+		}
+		delete(remaining, name)
+	}
+	return true
+}
+
+// agentVMUpgradeOrder returns the node indexes of the availability set agent pool VMs in upgrade order
+func (ku *Upgrader) agentVMUpgradeOrder(client kubernetes.Client, poolName string, vms map[int]*vmInfo) []int {
+	indexes := sortedVMIndexes(vms)
+	if ku.NodeOrderingStrategy == nil {
+		return indexes
+	}
+	var names []string
+	byName := map[string]int{}
+	for _, index := range indexes {
+		if vms[index].status != vmStatusNotUpgraded {
+			continue
+		}
+		names = append(names, vms[index].name)
+		byName[vms[index].name] = index
+	}
+	ordered := make([]int, 0, len(indexes))
+	for _, name := range ku.orderPoolNodes(client, poolName, names) {
+		ordered = append(ordered, byName[name])
+	}
+	// the other VMs are skipped by the upgrade
+	for _, index := range indexes {
+		if vms[index].status != vmStatusNotUpgraded {
+			ordered = append(ordered, index)
+		}
+	}
+	return ordered
+}
+
+// orderScaleSetVMs sorts the VMs to upgrade of each scale set in the order set by NodeOrderingStrategy
+func (ku *Upgrader) orderScaleSetVMs() {
+	if ku.NodeOrderingStrategy == nil || len(ku.ClusterTopology.AgentPoolScaleSetsToUpgrade) == 0 {
+		return
+	}
+	client, err := ku.getKubernetesClient(10 * time.Second)
+	if err != nil {
+		ku.logger.Warnf("Failed to get a Kubernetes client to order the scale set nodes, upgrading them in instance order: %v", err)
+		return
+	}
+	for i := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
+		vmss := &ku.ClusterTopology.AgentPoolScaleSetsToUpgrade[i]
+		names := make([]string, len(vmss.VMsToUpgrade))
+		byName := map[string]AgentPoolScaleSetVM{}
+		for j, vm := range vmss.VMsToUpgrade {
+			names[j] = vm.Name
+			byName[vm.Name] = vm
+		}
+		ordered := ku.orderPoolNodes(client, vmss.poolName(), names)
+		for j, name := range ordered {
+			vmss.VMsToUpgrade[j] = byName[name]
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reverseOrder is a NodeOrderingStrategy upgrading the nodes in reverse order
+type reverseOrder struct {
+	err error
+}
+
+func (s reverseOrder) OrderNodes(client kubernetes.Client, nodeNames []string) ([]string, error) {
+	var ordered []string
+	for i := len(nodeNames) - 1; i >= 0; i-- {
+		ordered = append(ordered, nodeNames[i])
+	}
+	return ordered, s.err
+}
+
+var _ = Describe("Node ordering tests", func() {
+	var (
+		k8sClient *armhelpers.MockKubernetesClient
+		nodeNames []string
+	)
+
+	newNode := func(name string, age time.Duration, labels map[string]string) v1.Node {
+		node := v1.Node{}
+		node.Name = name
+		node.Labels = labels
+		node.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+		return node
+	}
+
+	newPod := func(nodeName string, phase v1.PodPhase) v1.Pod {
+		pod := v1.Pod{}
+		pod.Spec.NodeName = nodeName
+		pod.Status.Phase = phase
+		return pod
+	}
+
+	BeforeEach(func() {
+		nodeNames = []string{"k8s-agentpool1-12345678-0", "K8S-AGENTPOOL1-12345678-1", "k8s-agentpool1-12345678-2"}
+		k8sClient = &armhelpers.MockKubernetesClient{
+			NodesList: &v1.NodeList{Items: []v1.Node{
+				newNode("k8s-agentpool1-12345678-0", time.Hour, map[string]string{zoneLabel: "westus2-2"}),
+				newNode("k8s-agentpool1-12345678-1", 3*time.Hour, map[string]string{legacyZoneLabel: "westus2-1"}),
+			}},
+			PodsList: &v1.PodList{Items: []v1.Pod{
+				newPod("k8s-agentpool1-12345678-0", v1.PodRunning),
+				newPod("k8s-agentpool1-12345678-0", v1.PodPending),
+				newPod("k8s-agentpool1-12345678-1", v1.PodRunning),
+				newPod("k8s-agentpool1-12345678-2", v1.PodSucceeded),
+			}},
+		}
+	})
+
+	It("Should order the nodes by running pod count", func() {
+		ordered, err := OrderByPodCount{}.OrderNodes(k8sClient, nodeNames)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ordered).To(Equal([]string{"k8s-agentpool1-12345678-2", "K8S-AGENTPOOL1-12345678-1", "k8s-agentpool1-12345678-0"}))
+	})
+
+	It("Should order the nodes by age, the unregistered nodes last", func() {
+		ordered, err := OrderByNodeAge{}.OrderNodes(k8sClient, nodeNames)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ordered).To(Equal([]string{"K8S-AGENTPOOL1-12345678-1", "k8s-agentpool1-12345678-0", "k8s-agentpool1-12345678-2"}))
+	})
+
+	It("Should order the nodes by zone, the nodes without zone last", func() {
+		ordered, err := OrderByZone{}.OrderNodes(k8sClient, nodeNames)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ordered).To(Equal([]string{"K8S-AGENTPOOL1-12345678-1", "k8s-agentpool1-12345678-0", "k8s-agentpool1-12345678-2"}))
+	})
+
+	It("Should return the errors listing the cluster", func() {
+		k8sClient.FailListPods = true
+		_, err := OrderByPodCount{}.OrderNodes(k8sClient, nodeNames)
+		Expect(err).To(MatchError("listing pods: ListAllPods failed"))
+
+		k8sClient.FailListNodes = true
+		_, err = OrderByZone{}.OrderNodes(k8sClient, nodeNames)
+		Expect(err).To(MatchError("listing nodes: ListNodes failed"))
+	})
+
+	Context("Upgrader", func() {
+		var u *Upgrader
+
+		BeforeEach(func() {
+			u = newTestCRDUpgrader("1.18.8", k8sClient)
+		})
+
+		It("Should upgrade the availability set VMs in index order without strategy", func() {
+			vms := map[int]*vmInfo{
+				2: {"k8s-agentpool1-12345678-2", vmStatusNotUpgraded},
+				0: {"k8s-agentpool1-12345678-0", vmStatusNotUpgraded},
+				1: {"k8s-agentpool1-12345678-1", vmStatusNotUpgraded},
+			}
+			Expect(u.agentVMUpgradeOrder(k8sClient, "agentpool1", vms)).To(Equal([]int{0, 1, 2}))
+		})
+
+		It("Should order the availability set VMs to upgrade with the strategy", func() {
+			u.NodeOrderingStrategy = OrderByPodCount{}
+			vms := map[int]*vmInfo{
+				0: {"k8s-agentpool1-12345678-0", vmStatusNotUpgraded},
+				1: {"k8s-agentpool1-12345678-1", vmStatusNotUpgraded},
+				2: {"k8s-agentpool1-12345678-2", vmStatusNotUpgraded},
+				3: {"k8s-agentpool1-12345678-3", vmStatusUpgraded},
+			}
+			Expect(u.agentVMUpgradeOrder(k8sClient, "agentpool1", vms)).To(Equal([]int{2, 1, 0, 3}))
+		})
+
+		It("Should fall back to index order when the strategy fails", func() {
+			u.NodeOrderingStrategy = reverseOrder{err: errors.New("no order")}
+			Expect(u.orderPoolNodes(k8sClient, "agentpool1", nodeNames)).To(Equal(nodeNames))
+
+			u.NodeOrderingStrategy = OrderByPodCount{}
+			k8sClient.FailListPods = true
+			Expect(u.orderPoolNodes(k8sClient, "agentpool1", nodeNames)).To(Equal(nodeNames))
+		})
+
+		It("Should fall back to index order when the strategy does not return each node once", func() {
+			u.NodeOrderingStrategy = reverseOrder{}
+			Expect(u.orderPoolNodes(k8sClient, "agentpool1", nodeNames)).To(Equal([]string{"k8s-agentpool1-12345678-2", "K8S-AGENTPOOL1-12345678-1", "k8s-agentpool1-12345678-0"}))
+			Expect(sameNodes([]string{"a", "a"}, []string{"a", "b"})).To(BeFalse())
+			Expect(sameNodes([]string{"a"}, []string{"a", "b"})).To(BeFalse())
+			Expect(sameNodes([]string{"b", "a"}, []string{"a", "b"})).To(BeTrue())
+		})
+
+		It("Should order the VMs of each scale set", func() {
+			u.NodeOrderingStrategy = reverseOrder{}
+			u.ClusterTopology.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{
+				newTestScaleSet("k8s-linux1-12345678-vmss", false, 3),
+				newTestScaleSet("k8s-linux2-12345678-vmss", false, 1),
+			}
+			u.orderScaleSetVMs()
+			Expect(scaleSetVMNames(u.scaleSetVMUpgradeOrder())).To(Equal([]string{
+				"k8s-linux1-12345678-vmss000002",
+				"k8s-linux1-12345678-vmss000001",
+				"k8s-linux1-12345678-vmss000000",
+				"k8s-linux2-12345678-vmss000000",
+			}))
+			Expect(u.ClusterTopology.AgentPoolScaleSetsToUpgrade[0].VMsToUpgrade[0].InstanceID).To(Equal("2"))
+		})
+	})
+})
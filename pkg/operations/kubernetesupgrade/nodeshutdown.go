@@ -0,0 +1,82 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// shutdownGracePeriodField is the KubeletConfiguration field of the graceful node shutdown grace period
+	shutdownGracePeriodField = "shutdownGracePeriod"
+	// minNodeShutdownVersion is the first Kubernetes version with graceful node shutdown enabled by default
+	minNodeShutdownVersion = "1.21.0"
+)
+
+// ValidateNodeShutdownGracePeriod ensures the graceful node shutdown grace period is not negative and
+// the Kubernetes version the cluster is upgraded to supports graceful node shutdown.
+func ValidateNodeShutdownGracePeriod(gracePeriod time.Duration, upgradeVersion string) error {
+	if gracePeriod < 0 {
+		return errors.Errorf("the node shutdown grace period %v must not be negative", gracePeriod)
+	}
+	if gracePeriod > 0 && !common.IsKubernetesVersionGe(upgradeVersion, minNodeShutdownVersion) {
+		return errors.Errorf("graceful node shutdown requires Kubernetes %s or later, the cluster is upgraded to %s", minNodeShutdownVersion, upgradeVersion)
+	}
+	return nil
+}
+
+// validateNodeShutdownGracePeriod ensures the kubelet-config config map the node shutdown grace period is set in
+// exists and holds a KubeletConfiguration, aks-engine does not create it
+func (ku *Upgrader) validateNodeShutdownGracePeriod() error {
+	if ku.NodeShutdownGracePeriod <= 0 {
+		return nil
+	}
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	cm, _, err := getKubeletConfigMap(client)
+	if err == nil && cm.Data[kubeletConfigMapKey] == "" {
+		err = errors.Errorf("config map %s/%s has no %s key", metav1.NamespaceSystem, KubeletConfigMapName, kubeletConfigMapKey)
+	}
+	if err != nil {
+		return errors.Wrap(err, "the node shutdown grace period requires the KubeletConfiguration of the nodes in a config map")
+	}
+	return nil
+}
+
+// withNodeShutdownGracePeriod sets the graceful node shutdown grace period of the kubelet-config config map
+// to NodeShutdownGracePeriod while the node is drained and upgraded by upgradeNode, and restores the previous
+// value afterwards, whether the node upgrade succeeded or not
+func (ku *Upgrader) withNodeShutdownGracePeriod(nodeName string, upgradeNode func() error) error {
+	if ku.NodeShutdownGracePeriod <= 0 {
+		return upgradeNode()
+	}
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	gracePeriod := ku.NodeShutdownGracePeriod.String()
+	restore, err := patchKubeletConfigMap(client, map[string]interface{}{shutdownGracePeriodField: gracePeriod})
+	if err != nil {
+		return errors.Wrapf(err, "setting the node shutdown grace period before upgrading node %s", nodeName)
+	}
+	ku.logger.Infof("Set %s of config map %s/%s to %s to upgrade node %s", shutdownGracePeriodField, metav1.NamespaceSystem, KubeletConfigMapName, gracePeriod, nodeName)
+
+	upgradeErr := upgradeNode()
+	if _, err = patchKubeletConfigMap(client, restore); err != nil {
+		err = errors.Wrapf(err, "restoring the node shutdown grace period after upgrading node %s", nodeName)
+		if upgradeErr != nil {
+			ku.logger.Error(err)
+			return upgradeErr
+		}
+		return err
+	}
+	ku.logger.Infof("Restored %s of config map %s/%s after upgrading node %s", shutdownGracePeriodField, metav1.NamespaceSystem, KubeletConfigMapName, nodeName)
+	return upgradeErr
+}
@@ -0,0 +1,128 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Node shutdown grace period tests", func() {
+	var (
+		kubeClient *armhelpers.MockKubernetesClient
+		u          *Upgrader
+	)
+
+	kubeletConfig := func() map[string]interface{} {
+		config := map[string]interface{}{}
+		Expect(yaml.Unmarshal([]byte(kubeClient.ConfigMaps["kube-system/"+KubeletConfigMapName].Data["kubelet"]), &config)).To(Succeed())
+		return config
+	}
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			ConfigMaps: map[string]*v1.ConfigMap{
+				"kube-system/" + KubeletConfigMapName: {
+					ObjectMeta: metav1.ObjectMeta{Name: KubeletConfigMapName, Namespace: "kube-system"},
+					Data:       map[string]string{"kubelet": testKubeletConfig},
+				},
+			},
+		}
+		stepTimeout := time.Second
+		u = &Upgrader{}
+		u.Init(&i18n.Translator{}, log.NewEntry(log.New()), ClusterTopology{DataModel: newTestCRDUpgrader("1.24.0", kubeClient).DataModel},
+			&armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}, "", &stepTimeout, nil, TestAKSEngineVersion, false)
+		u.NodeShutdownGracePeriod = 2 * time.Minute
+	})
+
+	It("Should set the grace period while the node is upgraded and remove it afterwards", func() {
+		var during map[string]interface{}
+		Expect(u.withNodeShutdownGracePeriod("k8s-agentpool1-12345678-0", func() error {
+			during = kubeletConfig()
+			return nil
+		})).To(Succeed())
+
+		Expect(during).To(HaveKeyWithValue("shutdownGracePeriod", "2m0s"))
+		Expect(during).To(HaveKeyWithValue("maxPods", float64(30)))
+		Expect(kubeletConfig()).NotTo(HaveKey("shutdownGracePeriod"))
+		Expect(kubeletConfig()).To(HaveKeyWithValue("evictionHard", map[string]interface{}{"memory.available": "750Mi", "nodefs.available": "10%"}))
+	})
+
+	It("Should restore the previous grace period", func() {
+		kubeClient.ConfigMaps["kube-system/"+KubeletConfigMapName].Data["kubelet"] = testKubeletConfig + "shutdownGracePeriod: 30s\n"
+
+		var during map[string]interface{}
+		Expect(u.withNodeShutdownGracePeriod("k8s-agentpool1-12345678-0", func() error {
+			during = kubeletConfig()
+			return nil
+		})).To(Succeed())
+
+		Expect(during).To(HaveKeyWithValue("shutdownGracePeriod", "2m0s"))
+		Expect(kubeletConfig()).To(HaveKeyWithValue("shutdownGracePeriod", "30s"))
+	})
+
+	It("Should restore the grace period when the node upgrade fails", func() {
+		err := u.withNodeShutdownGracePeriod("k8s-agentpool1-12345678-0", func() error {
+			return errors.New("drain failed")
+		})
+
+		Expect(err).To(MatchError("drain failed"))
+		Expect(kubeletConfig()).NotTo(HaveKey("shutdownGracePeriod"))
+	})
+
+	It("Should not upgrade the node when the grace period cannot be set", func() {
+		kubeClient.FailUpdateConfigMap = true
+		upgraded := false
+
+		err := u.withNodeShutdownGracePeriod("k8s-agentpool1-12345678-0", func() error {
+			upgraded = true
+			return nil
+		})
+		Expect(err).To(MatchError("setting the node shutdown grace period before upgrading node k8s-agentpool1-12345678-0: updating config map kube-system/kubelet-config: UpdateConfigMap failed"))
+		Expect(upgraded).To(BeFalse())
+	})
+
+	It("Should leave the config map untouched without a grace period", func() {
+		u.NodeShutdownGracePeriod = 0
+		kubeClient.FailGetConfigMap = true
+
+		Expect(u.withNodeShutdownGracePeriod("k8s-agentpool1-12345678-0", func() error { return nil })).To(Succeed())
+		Expect(kubeClient.ConfigMaps["kube-system/"+KubeletConfigMapName].Data["kubelet"]).To(Equal(testKubeletConfig))
+	})
+
+	It("Should check the kubelet-config config map before upgrading any node", func() {
+		Expect(u.validateNodeShutdownGracePeriod()).To(Succeed())
+
+		kubeClient.ConfigMaps["kube-system/"+KubeletConfigMapName].Data["kubelet"] = "maxPods: [30"
+		Expect(u.validateNodeShutdownGracePeriod()).To(MatchError(ContainSubstring(
+			"the node shutdown grace period requires the KubeletConfiguration of the nodes in a config map: parsing the kubelet key of config map kube-system/kubelet-config")))
+
+		delete(kubeClient.ConfigMaps["kube-system/"+KubeletConfigMapName].Data, "kubelet")
+		Expect(u.validateNodeShutdownGracePeriod()).To(MatchError(
+			"the node shutdown grace period requires the KubeletConfiguration of the nodes in a config map: config map kube-system/kubelet-config has no kubelet key"))
+
+		delete(kubeClient.ConfigMaps, "kube-system/"+KubeletConfigMapName)
+		Expect(u.RunUpgrade()).To(MatchError(
+			`the node shutdown grace period requires the KubeletConfiguration of the nodes in a config map: getting config map kube-system/kubelet-config: configmaps "kubelet-config" not found`))
+
+		u.NodeShutdownGracePeriod = 0
+		Expect(u.validateNodeShutdownGracePeriod()).To(Succeed())
+	})
+
+	It("Should validate the grace period against the upgrade version", func() {
+		Expect(ValidateNodeShutdownGracePeriod(0, "1.20.15")).To(Succeed())
+		Expect(ValidateNodeShutdownGracePeriod(time.Minute, "1.21.2")).To(Succeed())
+		Expect(ValidateNodeShutdownGracePeriod(time.Minute, "1.20.15")).To(MatchError("graceful node shutdown requires Kubernetes 1.21.0 or later, the cluster is upgraded to 1.20.15"))
+		Expect(ValidateNodeShutdownGracePeriod(-time.Minute, "1.21.2")).To(MatchError("the node shutdown grace period -1m0s must not be negative"))
+	})
+})
@@ -0,0 +1,199 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+const (
+	diskResourceType           = "Microsoft.Compute/disks"
+	storageAccountResourceType = "Microsoft.Storage/storageAccounts"
+
+	// storageAccountPrefixLength is the number of characters ARM templates prepend
+	// to the base name of the cluster storage accounts
+	storageAccountPrefixLength = 2
+)
+
+// OrphanedResource is an Azure resource of the cluster that no virtual machine uses anymore
+type OrphanedResource struct {
+	// Type is the ARM resource type, e.g. Microsoft.Network/networkInterfaces
+	Type string
+	Name string
+	ID   string
+	// ResourceGroup is the resource group of the resource when it is not the cluster resource group
+	ResourceGroup string
+}
+
+// FindOrphanedResources returns the NICs, managed disks and storage accounts of the cluster which
+// are not attached to any of the virtual machines in the resource group, for instance because a
+// previous upgrade failed half way. NICs and disks belong to the cluster when their name contains
+// the cluster ID; storage accounts when they share the naming scheme of a storage account in use.
+// Resources attached to scale set instances are never reported.
+func (kmn *UpgradeMasterNode) FindOrphanedResources(ctx context.Context) ([]OrphanedResource, error) {
+	clusterID := kmn.UpgradeContainerService.Properties.GetClusterID()
+	vmIDs := map[string]bool{}
+	accountsInUse := map[string]bool{}
+
+	for page, err := kmn.Client.ListVirtualMachines(ctx, kmn.ResourceGroup); page.NotDone(); err = page.Next() {
+		if err != nil {
+			return nil, errors.Wrap(err, "listing virtual machines")
+		}
+		for _, vm := range page.Values() {
+			vmIDs[strings.ToLower(to.String(vm.ID))] = true
+			for _, uri := range vhdURIs(vm) {
+				if account := storageAccountName(uri); account != "" {
+					accountsInUse[account] = true
+				}
+			}
+		}
+	}
+
+	var orphaned []OrphanedResource
+	nicResourceGroups := []string{kmn.ResourceGroup}
+	if vnetResourceGroup := kmn.vnetResourceGroup(); !strings.EqualFold(vnetResourceGroup, kmn.ResourceGroup) {
+		// NICs created along with the virtual network of split deployments live in its resource group
+		nicResourceGroups = append(nicResourceGroups, vnetResourceGroup)
+	}
+	for _, resourceGroup := range nicResourceGroups {
+		nics, err := kmn.Client.ListNetworkInterfaces(ctx, resourceGroup)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing network interfaces in resource group %s", resourceGroup)
+		}
+		for _, nic := range nics {
+			if !strings.Contains(to.String(nic.Name), clusterID) {
+				continue
+			}
+			var attachedTo string
+			if nic.InterfacePropertiesFormat != nil && nic.VirtualMachine != nil {
+				attachedTo = to.String(nic.VirtualMachine.ID)
+			}
+			if isOrphaned(attachedTo, vmIDs) {
+				resource := OrphanedResource{Type: nicResourceType, Name: to.String(nic.Name), ID: to.String(nic.ID)}
+				if resourceGroup != kmn.ResourceGroup {
+					resource.ResourceGroup = resourceGroup
+				}
+				orphaned = append(orphaned, resource)
+			}
+		}
+	}
+
+	for page, err := kmn.Client.ListManagedDisksByResourceGroup(ctx, kmn.ResourceGroup); page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return nil, errors.Wrap(err, "listing managed disks")
+		}
+		for _, disk := range page.Values() {
+			if !strings.Contains(to.String(disk.Name), clusterID) {
+				continue
+			}
+			if isOrphaned(to.String(disk.ManagedBy), vmIDs) {
+				orphaned = append(orphaned, OrphanedResource{Type: diskResourceType, Name: to.String(disk.Name), ID: to.String(disk.ID)})
+			}
+		}
+	}
+
+	if len(accountsInUse) > 0 {
+		accounts, err := kmn.Client.ListStorageAccounts(ctx, kmn.ResourceGroup)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing storage accounts")
+		}
+		baseNames := map[string]bool{}
+		for name := range accountsInUse {
+			baseNames[storageAccountBaseName(name)] = true
+		}
+		for _, account := range accounts {
+			name := strings.ToLower(to.String(account.Name))
+			if !accountsInUse[name] && baseNames[storageAccountBaseName(name)] {
+				orphaned = append(orphaned, OrphanedResource{Type: storageAccountResourceType, Name: to.String(account.Name), ID: to.String(account.ID)})
+			}
+		}
+	}
+	return orphaned, nil
+}
+
+// CleanupOrphaned deletes the given resources, as returned by FindOrphanedResources.
+// All resources are attempted even if some of them fail to be deleted.
+func (kmn *UpgradeMasterNode) CleanupOrphaned(ctx context.Context, resources []OrphanedResource) error {
+	var failed int
+	for _, resource := range resources {
+		kmn.logger.Infof("Deleting orphaned resource %s", resource.ID)
+		resourceGroup := kmn.ResourceGroup
+		if resource.ResourceGroup != "" {
+			resourceGroup = resource.ResourceGroup
+		}
+		if err := deleteOrphanedResource(ctx, kmn.Client, resourceGroup, resource); err != nil {
+			kmn.logger.Errorf("Error deleting orphaned resource %s: %v", resource.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("failed to delete %d of %d orphaned resources", failed, len(resources))
+	}
+	return nil
+}
+
+func deleteOrphanedResource(ctx context.Context, client armhelpers.AKSEngineClient, resourceGroup string, resource OrphanedResource) error {
+	switch resource.Type {
+	case nicResourceType:
+		return client.DeleteNetworkInterface(ctx, resourceGroup, resource.Name)
+	case diskResourceType:
+		return client.DeleteManagedDisk(ctx, resourceGroup, resource.Name)
+	case storageAccountResourceType:
+		return client.DeleteStorageAccount(ctx, resourceGroup, resource.Name)
+	default:
+		return errors.Errorf("unsupported resource type %s", resource.Type)
+	}
+}
+
+// isOrphaned reports whether a resource attached to attachedTo is unused. Resources
+// managed by scale set instances are never considered orphaned.
+func isOrphaned(attachedTo string, vmIDs map[string]bool) bool {
+	id := strings.ToLower(attachedTo)
+	if strings.Contains(id, "/virtualmachinescalesets/") {
+		return false
+	}
+	return id == "" || !vmIDs[id]
+}
+
+// vhdURIs returns the URIs of the unmanaged disks of the virtual machine.
+func vhdURIs(vm compute.VirtualMachine) []string {
+	var uris []string
+	if vm.VirtualMachineProperties == nil || vm.StorageProfile == nil {
+		return uris
+	}
+	if osDisk := vm.StorageProfile.OsDisk; osDisk != nil && osDisk.Vhd != nil {
+		uris = append(uris, to.String(osDisk.Vhd.URI))
+	}
+	if vm.StorageProfile.DataDisks != nil {
+		for _, disk := range *vm.StorageProfile.DataDisks {
+			if disk.Vhd != nil {
+				uris = append(uris, to.String(disk.Vhd.URI))
+			}
+		}
+	}
+	return uris
+}
+
+// storageAccountName returns the lower case storage account name of a blob URI.
+func storageAccountName(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(strings.Split(u.Host, ".")[0])
+}
+
+func storageAccountBaseName(name string) string {
+	if len(name) <= storageAccountPrefixLength {
+		return name
+	}
+	return name[storageAccountPrefixLength:]
+}
@@ -0,0 +1,143 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-02-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testVMIDPrefix = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Compute/virtualMachines/"
+
+var _ = Describe("Orphaned resources tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kmn        *UpgradeMasterNode
+		clusterID  string
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		clusterID = kmn.UpgradeContainerService.Properties.GetClusterID()
+		master := "k8s-master-" + clusterID + "-0"
+
+		mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+			return []compute.VirtualMachine{
+				{
+					ID:   to.StringPtr(testVMIDPrefix + master),
+					Name: to.StringPtr(master),
+					VirtualMachineProperties: &compute.VirtualMachineProperties{
+						StorageProfile: &compute.StorageProfile{
+							OsDisk: &compute.OSDisk{
+								Vhd: &compute.VirtualHardDisk{URI: to.StringPtr("https://00k71r4u927seqimstr0.blob.core.windows.net/osdisk/" + master + "-osdisk.vhd")},
+							},
+						},
+					},
+				},
+			}
+		}
+		mockClient.FakeListNetworkInterfacesResult = func() []network.Interface {
+			return []network.Interface{
+				{
+					ID:   to.StringPtr("nic-0"),
+					Name: to.StringPtr("k8s-master-" + clusterID + "-nic-0"),
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+						VirtualMachine: &network.SubResource{ID: to.StringPtr(testVMIDPrefix + master)},
+					},
+				},
+				{
+					ID:                        to.StringPtr("nic-1"),
+					Name:                      to.StringPtr("k8s-master-" + clusterID + "-nic-1"),
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{},
+				},
+				{
+					ID:   to.StringPtr("nic-2"),
+					Name: to.StringPtr("k8s-master-" + clusterID + "-nic-2"),
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+						VirtualMachine: &network.SubResource{ID: to.StringPtr(testVMIDPrefix + "k8s-master-" + clusterID + "-2")},
+					},
+				},
+				{
+					ID:   to.StringPtr("jumpbox-nic"),
+					Name: to.StringPtr("jumpbox-nic"),
+				},
+			}
+		}
+		mockClient.FakeListManagedDisksResult = func() []compute.Disk {
+			return []compute.Disk{
+				{ID: to.StringPtr("disk-0"), Name: to.StringPtr(master + "-etcddisk"), ManagedBy: to.StringPtr(testVMIDPrefix + master)},
+				{ID: to.StringPtr("disk-1"), Name: to.StringPtr("k8s-master-" + clusterID + "-1-etcddisk")},
+				{ID: to.StringPtr("disk-2"), Name: to.StringPtr("k8s-agentpool1-" + clusterID + "-vmss_0_OsDisk"), ManagedBy: to.StringPtr("/subscriptions/x/resourceGroups/TestRg/providers/Microsoft.Compute/virtualMachineScaleSets/k8s-agentpool1-" + clusterID + "-vmss/virtualMachines/0")},
+			}
+		}
+		mockClient.FakeListStorageAccountsResult = func() []storage.Account {
+			return []storage.Account{
+				{ID: to.StringPtr("account-0"), Name: to.StringPtr("00k71r4u927seqimstr0")},
+				{ID: to.StringPtr("account-1"), Name: to.StringPtr("6ck71r4u927seqimstr0")},
+				{ID: to.StringPtr("account-2"), Name: to.StringPtr("diagnostics")},
+			}
+		}
+	})
+
+	It("Should find the resources not attached to any VM", func() {
+		orphaned, err := kmn.FindOrphanedResources(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphaned).To(ConsistOf(
+			OrphanedResource{Type: nicResourceType, Name: "k8s-master-" + clusterID + "-nic-1", ID: "nic-1"},
+			OrphanedResource{Type: nicResourceType, Name: "k8s-master-" + clusterID + "-nic-2", ID: "nic-2"},
+			OrphanedResource{Type: diskResourceType, Name: "k8s-master-" + clusterID + "-1-etcddisk", ID: "disk-1"},
+			OrphanedResource{Type: storageAccountResourceType, Name: "6ck71r4u927seqimstr0", ID: "account-1"},
+		))
+	})
+
+	It("Should return an error when network interfaces cannot be listed", func() {
+		mockClient.FailListNetworkInterfaces = true
+		_, err := kmn.FindOrphanedResources(context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should find and delete the orphaned NICs of the VNet resource group there", func() {
+		kmn.VNetResourceGroup = "vnetrg"
+		mockClient.FakeListNetworkInterfacesByResourceGroup = map[string][]network.Interface{
+			"vnetrg": {
+				{ID: to.StringPtr("nic-3"), Name: to.StringPtr("k8s-master-" + clusterID + "-nic-3")},
+			},
+		}
+
+		orphaned, err := kmn.FindOrphanedResources(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphaned).To(ContainElement(OrphanedResource{Type: nicResourceType, Name: "k8s-master-" + clusterID + "-nic-3", ID: "nic-3", ResourceGroup: "vnetrg"}))
+
+		Expect(kmn.CleanupOrphaned(context.Background(), orphaned)).To(Succeed())
+		Expect(mockClient.ResourceGroupCalls).To(Equal([]string{
+			"ListNetworkInterfaces TestRg",
+			"ListNetworkInterfaces vnetrg",
+			"DeleteNetworkInterface vnetrg",
+		}))
+	})
+
+	It("Should delete all orphaned resources", func() {
+		orphaned, err := kmn.FindOrphanedResources(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kmn.CleanupOrphaned(context.Background(), orphaned)).To(Succeed())
+	})
+
+	It("Should keep deleting when a resource cannot be deleted", func() {
+		mockClient.FailDeleteNetworkInterface = true
+		orphaned, err := kmn.FindOrphanedResources(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = kmn.CleanupOrphaned(context.Background(), orphaned)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("failed to delete 2 of 4 orphaned resources"))
+	})
+})
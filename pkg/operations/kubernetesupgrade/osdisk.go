@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+const (
+	// premiumIOCapability is the resource SKU capability set to "True" where a VM size supports premium storage
+	premiumIOCapability = "PremiumIO"
+	// masterOSDiskStorageAccountTypeVariable is the template variable holding UpgradeMasterNode.OSDiskStorageAccountType
+	masterOSDiskStorageAccountTypeVariable = "masterOSDiskStorageAccountType"
+	// masterOSDiskCachingModeVariable is the template variable holding UpgradeMasterNode.OSDiskCachingMode
+	masterOSDiskCachingModeVariable = "masterOSDiskCachingMode"
+)
+
+// ValidateOSDiskStorageAccountType checks that storageAccountType is a managed disk type supported for OS disks,
+// i.e. Premium_LRS, StandardSSD_LRS or Standard_LRS.
+func ValidateOSDiskStorageAccountType(storageAccountType string) error {
+	switch compute.StorageAccountTypes(storageAccountType) {
+	case compute.StorageAccountTypesPremiumLRS, compute.StorageAccountTypesStandardSSDLRS, compute.StorageAccountTypesStandardLRS:
+		return nil
+	}
+	return errors.Errorf("invalid OS disk storage account type %q, expected %s, %s or %s", storageAccountType,
+		compute.StorageAccountTypesPremiumLRS, compute.StorageAccountTypesStandardSSDLRS, compute.StorageAccountTypesStandardLRS)
+}
+
+// validateOSDiskStorageAccountType ensures the master VM size supports the OSDiskStorageAccountType disks
+// in the cluster location, Premium_LRS requiring premium storage support
+func (kmn *UpgradeMasterNode) validateOSDiskStorageAccountType(ctx context.Context) error {
+	if err := ValidateOSDiskStorageAccountType(kmn.OSDiskStorageAccountType); err != nil {
+		return err
+	}
+	if compute.StorageAccountTypes(kmn.OSDiskStorageAccountType) != compute.StorageAccountTypesPremiumLRS {
+		return nil
+	}
+	location := kmn.UpgradeContainerService.Location
+	vmSize := kmn.UpgradeContainerService.Properties.MasterProfile.VMSize
+	page, err := kmn.Client.ListResourceSkus(ctx, fmt.Sprintf("location eq '%s'", location))
+	if err != nil {
+		return errors.Wrap(err, "listing resource SKUs")
+	}
+	for page != nil && page.NotDone() {
+		for _, sku := range page.Values() {
+			if !strings.EqualFold(to.String(sku.Name), vmSize) || !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") {
+				continue
+			}
+			if sku.Capabilities != nil && hasSkuCapability(*sku.Capabilities, premiumIOCapability) {
+				return nil
+			}
+		}
+		if err = page.NextWithContext(ctx); err != nil {
+			return errors.Wrap(err, "listing resource SKUs")
+		}
+	}
+	return errors.Errorf("OS disk storage account type %s is not supported by VM size %s in location %s", kmn.OSDiskStorageAccountType, vmSize, location)
+}
+
+// ValidateOSDiskCachingMode checks that cachingMode is an OS disk caching mode, i.e. None, ReadOnly or ReadWrite.
+func ValidateOSDiskCachingMode(cachingMode string) error {
+	switch compute.CachingTypes(cachingMode) {
+	case compute.CachingTypesNone, compute.CachingTypesReadOnly, compute.CachingTypesReadWrite:
+		return nil
+	}
+	return errors.Errorf("invalid OS disk caching mode %q, expected %s, %s or %s", cachingMode,
+		compute.CachingTypesNone, compute.CachingTypesReadOnly, compute.CachingTypesReadWrite)
+}
+
+// setOSDiskStorageAccountType sets the managed disk storage account type of the OS disk of the master VM
+// resource to the OSDiskStorageAccountType template variable
+func (kmn *UpgradeMasterNode) setOSDiskStorageAccountType(vm map[string]interface{}) {
+	osDisk := osDiskProperties(vm)
+	managedDisk, ok := osDisk["managedDisk"].(map[string]interface{})
+	if !ok {
+		managedDisk = map[string]interface{}{}
+		osDisk["managedDisk"] = managedDisk
+	}
+	managedDisk["storageAccountType"] = "[variables('" + masterOSDiskStorageAccountTypeVariable + "')]"
+}
+
+// setOSDiskCachingMode sets the caching of the OS disk of the master VM resource to the OSDiskCachingMode template variable
+func (kmn *UpgradeMasterNode) setOSDiskCachingMode(vm map[string]interface{}) {
+	osDiskProperties(vm)["caching"] = "[variables('" + masterOSDiskCachingModeVariable + "')]"
+}
+
+// osDiskProperties returns the storageProfile.osDisk map of a VM resource, creating it if missing
+func osDiskProperties(vm map[string]interface{}) map[string]interface{} {
+	properties := resourceProperties(vm)
+	storageProfile, ok := properties["storageProfile"].(map[string]interface{})
+	if !ok {
+		storageProfile = map[string]interface{}{}
+		properties["storageProfile"] = storageProfile
+	}
+	osDisk, ok := storageProfile["osDisk"].(map[string]interface{})
+	if !ok {
+		osDisk = map[string]interface{}{}
+		storageProfile["osDisk"] = osDisk
+	}
+	return osDisk
+}
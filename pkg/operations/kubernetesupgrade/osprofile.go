@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const keyVaultAPIVersion = "7.1"
+
+// OSProfileConfig overrides the osProfile of the new master VMs
+type OSProfileConfig struct {
+	// AdminUsername replaces the admin username of the VMs, and the home directory of their SSH public keys
+	AdminUsername string
+	// DisablePasswordAuthentication disables password authentication over SSH
+	DisablePasswordAuthentication bool
+	// SSHPublicKey replaces the SSH public keys of the admin user. Either an OpenSSH public key, or the URL of the
+	// Key Vault secret holding it, e.g. https://myvault.vault.azure.net/secrets/ssh-key, read through SecretResolver
+	SSHPublicKey string
+}
+
+// ErrSecretNotFound is the cause of the error of SecretResolver.GetSecret for a secret that does not exist
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretResolver reads secrets from a secret store
+type SecretResolver interface {
+	// GetSecret returns the value of the secret at secretURL
+	GetSecret(ctx context.Context, secretURL string) (string, error)
+}
+
+// Compiler to verify AzureKeyVaultSecretResolver implements SecretResolver
+var _ SecretResolver = &AzureKeyVaultSecretResolver{}
+
+// AzureKeyVaultSecretResolver is a SecretResolver backed by the Azure Key Vault REST API
+type AzureKeyVaultSecretResolver struct {
+	// Authorizer authorizes the requests to the Key Vault resource, e.g. https://vault.azure.net
+	Authorizer autorest.Authorizer
+	HTTPClient *http.Client
+}
+
+// NewAzureKeyVaultSecretResolver returns an AzureKeyVaultSecretResolver using authorizer
+func NewAzureKeyVaultSecretResolver(authorizer autorest.Authorizer) *AzureKeyVaultSecretResolver {
+	return &AzureKeyVaultSecretResolver{
+		Authorizer: authorizer,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+type keyVaultSecret struct {
+	Value string `json:"value"`
+}
+
+// GetSecret returns the value of the Key Vault secret at secretURL, its latest version unless secretURL includes one
+func (r *AzureKeyVaultSecretResolver) GetSecret(ctx context.Context, secretURL string) (string, error) {
+	u, err := url.Parse(secretURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing secret URL %s", secretURL)
+	}
+	query := u.Query()
+	query.Set("api-version", keyVaultAPIVersion)
+	u.RawQuery = query.Encode()
+	var secret keyVaultSecret
+	if err := doJSON(r.HTTPClient, r.Authorizer, http.MethodGet, u.String(), nil, &secret); err != nil {
+		if isStatusNotFound(err) {
+			return "", errors.Wrapf(ErrSecretNotFound, "getting secret %s", secretURL)
+		}
+		return "", errors.Wrapf(err, "getting secret %s", secretURL)
+	}
+	return secret.Value, nil
+}
+
+// isSecretURL reports whether value is the https URL of a Key Vault secret rather than an SSH public key
+func isSecretURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme == "https" && strings.HasPrefix(u.Path, "/secrets/")
+}
+
+// validateOSProfile ensures the OSProfile SSH public key can be read and parsed
+func (kmn *UpgradeMasterNode) validateOSProfile() error {
+	if kmn.OSProfile == nil || kmn.OSProfile.SSHPublicKey == "" {
+		return nil
+	}
+	if isSecretURL(kmn.OSProfile.SSHPublicKey) {
+		if kmn.SecretResolver == nil {
+			return errors.New("a secret resolver is required to read the SSH public key from a secret")
+		}
+		return nil
+	}
+	return validateSSHPublicKey(kmn.OSProfile.SSHPublicKey)
+}
+
+func validateSSHPublicKey(key string) error {
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+		return errors.Wrap(err, "parsing the SSH public key of the OS profile")
+	}
+	return nil
+}
+
+// resolveSSHPublicKey sets sshPublicKey to the OSProfile SSH public key, read through SecretResolver if it is a secret URL
+func (kmn *UpgradeMasterNode) resolveSSHPublicKey(ctx context.Context) error {
+	if err := kmn.validateOSProfile(); err != nil {
+		return err
+	}
+	key := kmn.OSProfile.SSHPublicKey
+	if isSecretURL(key) {
+		secret, err := kmn.SecretResolver.GetSecret(ctx, key)
+		if err != nil {
+			return errors.Wrap(err, "reading the SSH public key of the OS profile")
+		}
+		key = strings.TrimSpace(secret)
+		if err := validateSSHPublicKey(key); err != nil {
+			return errors.Wrapf(err, "secret %s", kmn.OSProfile.SSHPublicKey)
+		}
+	}
+	kmn.sshPublicKey = strings.TrimSpace(key)
+	return nil
+}
+
+// setOSProfile applies OSProfile to the osProfile of the master VM resource
+func (kmn *UpgradeMasterNode) setOSProfile(vm map[string]interface{}) {
+	properties := resourceProperties(vm)
+	osProfile, ok := properties["osProfile"].(map[string]interface{})
+	if !ok {
+		osProfile = map[string]interface{}{}
+		properties["osProfile"] = osProfile
+	}
+	linuxConfiguration, ok := osProfile["linuxConfiguration"].(map[string]interface{})
+	if !ok {
+		linuxConfiguration = map[string]interface{}{}
+		osProfile["linuxConfiguration"] = linuxConfiguration
+	}
+	if kmn.OSProfile.DisablePasswordAuthentication {
+		linuxConfiguration["disablePasswordAuthentication"] = true
+	}
+
+	keyPath := "[variables('sshKeyPath')]"
+	if kmn.OSProfile.AdminUsername != "" {
+		osProfile["adminUsername"] = kmn.OSProfile.AdminUsername
+		keyPath = "/home/" + kmn.OSProfile.AdminUsername + "/.ssh/authorized_keys"
+	}
+	sshConfiguration, ok := linuxConfiguration["ssh"].(map[string]interface{})
+	if kmn.sshPublicKey != "" {
+		linuxConfiguration["ssh"] = map[string]interface{}{
+			"publicKeys": []interface{}{
+				map[string]interface{}{
+					"path":    keyPath,
+					"keyData": kmn.sshPublicKey,
+				},
+			},
+		}
+	} else if ok && kmn.OSProfile.AdminUsername != "" {
+		// the keys of the template are authorized for the template admin user
+		publicKeys, _ := sshConfiguration["publicKeys"].([]interface{})
+		for _, publicKey := range publicKeys {
+			if publicKeyMap, ok := publicKey.(map[string]interface{}); ok {
+				publicKeyMap["path"] = keyPath
+			}
+		}
+	}
+}
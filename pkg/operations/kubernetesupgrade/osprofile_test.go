@@ -0,0 +1,146 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const (
+	testSSHPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAThPYTD5KNd2IDx0EPN0qpofM5sy0DMUYYUSfRDYjor"
+	testSecretURL    = "https://myvault.vault.azure.net/secrets/ssh-key"
+)
+
+type fakeSecretResolver struct {
+	secretURL string
+	value     string
+	err       error
+}
+
+func (r *fakeSecretResolver) GetSecret(ctx context.Context, secretURL string) (string, error) {
+	r.secretURL = secretURL
+	return r.value, r.err
+}
+
+func testOSProfile(kmn *UpgradeMasterNode) map[string]interface{} {
+	return resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])["osProfile"].(map[string]interface{})
+}
+
+var _ = Describe("OS profile tests", func() {
+	var kmn *UpgradeMasterNode
+
+	BeforeEach(func() {
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+	})
+
+	It("Should set the OS profile of the master VM resources only", func() {
+		kmn.OSProfile = &OSProfileConfig{
+			AdminUsername:                 "compliant",
+			DisablePasswordAuthentication: true,
+			SSHPublicKey:                  testSSHPublicKey + "\n",
+		}
+
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(testOSProfile(kmn)).To(Equal(map[string]interface{}{
+			"adminUsername": "compliant",
+			"linuxConfiguration": map[string]interface{}{
+				"disablePasswordAuthentication": true,
+				"ssh": map[string]interface{}{
+					"publicKeys": []interface{}{
+						map[string]interface{}{
+							"path":    "/home/compliant/.ssh/authorized_keys",
+							"keyData": testSSHPublicKey,
+						},
+					},
+				},
+			},
+		}))
+		agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+		Expect(resourceProperties(agent)).NotTo(HaveKey("osProfile"))
+	})
+
+	It("Should move the SSH public keys of the template to the home directory of the admin user", func() {
+		kmn.OSProfile = &OSProfileConfig{AdminUsername: "compliant"}
+		resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])["osProfile"] = map[string]interface{}{
+			"adminUsername": "[parameters('linuxAdminUsername')]",
+			"linuxConfiguration": map[string]interface{}{
+				"ssh": map[string]interface{}{
+					"publicKeys": []interface{}{
+						map[string]interface{}{"path": "[variables('sshKeyPath')]", "keyData": testSSHPublicKey},
+					},
+				},
+			},
+		}
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		osProfile := testOSProfile(kmn)
+		Expect(osProfile["adminUsername"]).To(Equal("compliant"))
+		publicKeys := osProfile["linuxConfiguration"].(map[string]interface{})["ssh"].(map[string]interface{})["publicKeys"].([]interface{})
+		Expect(publicKeys).To(ConsistOf(map[string]interface{}{"path": "/home/compliant/.ssh/authorized_keys", "keyData": testSSHPublicKey}))
+	})
+
+	It("Should read the SSH public key from a secret", func() {
+		resolver := &fakeSecretResolver{value: testSSHPublicKey}
+		kmn.OSProfile = &OSProfileConfig{SSHPublicKey: testSecretURL}
+		kmn.SecretResolver = resolver
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(resolver.secretURL).To(Equal(testSecretURL))
+		linuxConfiguration := testOSProfile(kmn)["linuxConfiguration"].(map[string]interface{})
+		Expect(linuxConfiguration["ssh"]).To(Equal(map[string]interface{}{
+			"publicKeys": []interface{}{
+				map[string]interface{}{"path": "[variables('sshKeyPath')]", "keyData": testSSHPublicKey},
+			},
+		}))
+	})
+
+	It("Should not deploy when the SSH public key cannot be read", func() {
+		kmn.OSProfile = &OSProfileConfig{SSHPublicKey: testSecretURL}
+		kmn.SecretResolver = &fakeSecretResolver{err: errors.New("forbidden")}
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("reading the SSH public key of the OS profile"))
+		Expect(kmn.deploymentNames).To(BeEmpty())
+
+		kmn.SecretResolver = &fakeSecretResolver{value: "not a key"}
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(MatchError(ContainSubstring("secret " + testSecretURL)))
+	})
+
+	It("Should fail preflight for an invalid OS profile", func() {
+		kmn.OSProfile = &OSProfileConfig{SSHPublicKey: "not a key"}
+		Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring("parsing the SSH public key of the OS profile")))
+
+		kmn.OSProfile.SSHPublicKey = testSecretURL
+		Expect(kmn.Preflight(context.Background())).To(MatchError("a secret resolver is required to read the SSH public key from a secret"))
+	})
+
+	It("Should get the secret from the Key Vault REST API", func() {
+		var path, query string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, query = r.URL.Path, r.URL.RawQuery
+			_, _ = w.Write([]byte(`{"value": "` + testSSHPublicKey + `", "id": "` + testSecretURL + `/0123"}`))
+		}))
+		defer server.Close()
+
+		resolver := NewAzureKeyVaultSecretResolver(autorest.NullAuthorizer{})
+		value, err := resolver.GetSecret(context.Background(), server.URL+"/secrets/ssh-key/0123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(testSSHPublicKey))
+		Expect(path).To(Equal("/secrets/ssh-key/0123"))
+		Expect(query).To(Equal("api-version=" + keyVaultAPIVersion))
+	})
+})
@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pauseCheckInterval is how often a paused upgrade checks whether PauseCheckFile was created again
+var pauseCheckInterval = 5 * time.Second
+
+// waitIfPaused pauses the upgrade before nodeName is upgraded if PauseBetweenNodes is set and PauseCheckFile exists.
+// The file is removed when the upgrade pauses, and the upgrade resumes once it is created again, e.g. with touch.
+func (ku *Upgrader) waitIfPaused(ctx context.Context, nodeName string) error {
+	if !ku.PauseBetweenNodes || ku.PauseCheckFile == "" {
+		return nil
+	}
+	paused, err := consumePauseCheckFile(ku.PauseCheckFile)
+	if err != nil || !paused {
+		return err
+	}
+	ku.logger.Infof("Upgrade paused before node %s, create %s to resume it", nodeName, ku.PauseCheckFile)
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for %s to resume the upgrade", ku.PauseCheckFile)
+		case <-time.After(pauseCheckInterval):
+		}
+		resumed, err := consumePauseCheckFile(ku.PauseCheckFile)
+		if err != nil {
+			return err
+		}
+		if resumed {
+			ku.logger.Infof("Upgrade resumed after a %v pause", time.Since(start).Round(time.Second))
+			return nil
+		}
+	}
+}
+
+// consumePauseCheckFile removes the file at path and returns true if it existed
+func consumePauseCheckFile(path string) (bool, error) {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "removing pause check file %s", path)
+	}
+	return true, nil
+}
+
+// upgradeContext returns the context bounding an upgrade phase. As a paused upgrade may wait for an
+// unbounded time, the phase timeout does not apply when PauseBetweenNodes, NodeGroupSize or
+// CanaryPauseForConfirmation is set.
+func (ku *Upgrader) upgradeContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if ku.PauseBetweenNodes || ku.NodeGroupSize > 0 || (ku.CanaryUpgrade && ku.CanaryPauseForConfirmation) {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
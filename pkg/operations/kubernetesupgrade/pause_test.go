@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade pause tests", func() {
+	var (
+		u                    *Upgrader
+		dir                  string
+		defaultCheckInterval time.Duration
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "pause")
+		Expect(err).NotTo(HaveOccurred())
+		defaultCheckInterval = pauseCheckInterval
+		pauseCheckInterval = 10 * time.Millisecond
+		u = newTestCRDUpgrader("1.18.8", nil)
+		u.PauseBetweenNodes = true
+		u.PauseCheckFile = filepath.Join(dir, "pause")
+	})
+
+	AfterEach(func() {
+		pauseCheckInterval = defaultCheckInterval
+		os.RemoveAll(dir)
+	})
+
+	It("Should not pause when the pause check file does not exist", func() {
+		Expect(u.waitIfPaused(context.Background(), "k8s-master-12345678-0")).To(Succeed())
+	})
+
+	It("Should not pause unless PauseBetweenNodes is set", func() {
+		u.PauseBetweenNodes = false
+		Expect(ioutil.WriteFile(u.PauseCheckFile, nil, 0644)).To(Succeed())
+
+		Expect(u.waitIfPaused(context.Background(), "k8s-master-12345678-0")).To(Succeed())
+		Expect(u.PauseCheckFile).To(BeAnExistingFile())
+	})
+
+	It("Should pause until the pause check file is created again", func() {
+		Expect(ioutil.WriteFile(u.PauseCheckFile, nil, 0644)).To(Succeed())
+		done := make(chan error)
+		go func() {
+			done <- u.waitIfPaused(context.Background(), "k8s-master-12345678-0")
+		}()
+
+		Eventually(u.PauseCheckFile).ShouldNot(BeAnExistingFile())
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		Expect(ioutil.WriteFile(u.PauseCheckFile, nil, 0644)).To(Succeed())
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(u.PauseCheckFile).NotTo(BeAnExistingFile())
+	})
+
+	It("Should return an error when the context ends while paused", func() {
+		Expect(ioutil.WriteFile(u.PauseCheckFile, nil, 0644)).To(Succeed())
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		err := u.waitIfPaused(ctx, "k8s-master-12345678-0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("to resume the upgrade"))
+	})
+
+	It("Should not bound the upgrade phases with a timeout when PauseBetweenNodes is set", func() {
+		ctx, cancel := u.upgradeContext(time.Minute)
+		defer cancel()
+		_, ok := ctx.Deadline()
+		Expect(ok).To(BeFalse())
+
+		u.PauseBetweenNodes = false
+		ctx, cancel = u.upgradeContext(time.Minute)
+		defer cancel()
+		_, ok = ctx.Deadline()
+		Expect(ok).To(BeTrue())
+	})
+})
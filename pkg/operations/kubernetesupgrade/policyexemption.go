@@ -0,0 +1,152 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+)
+
+const (
+	policyAPIVersion = "2020-07-01-preview"
+	// policyExemptionPrefix starts the name of the policy exemptions created by the upgrade
+	policyExemptionPrefix = "aks-engine-upgrade-"
+	// DefaultPolicyExemptionExpiresIn is how long a policy exemption lasts when ExpiresIn is zero
+	DefaultPolicyExemptionExpiresIn = time.Hour
+)
+
+// PolicyExemption exempts the resource group of the cluster from a policy assignment
+// while each master VM is deployed, e.g. a policy denying VMs without some tag value
+type PolicyExemption struct {
+	// PolicyAssignmentID is the resource ID of the policy assignment
+	PolicyAssignmentID string
+	// ExpiresIn bounds the lifetime of the exemption in case it cannot be removed,
+	// DefaultPolicyExemptionExpiresIn if zero
+	ExpiresIn time.Duration
+}
+
+// PolicyExemptionClient manages Azure Policy exemptions
+type PolicyExemptionClient interface {
+	// CreatePolicyExemption exempts scope from the policy assignment until expiresOn
+	CreatePolicyExemption(ctx context.Context, scope, name, policyAssignmentID string, expiresOn time.Time) error
+	// DeletePolicyExemption removes the policy exemption of scope
+	DeletePolicyExemption(ctx context.Context, scope, name string) error
+}
+
+// Compiler to verify AzurePolicyExemptionClient implements PolicyExemptionClient
+var _ PolicyExemptionClient = &AzurePolicyExemptionClient{}
+
+// AzurePolicyExemptionClient is a PolicyExemptionClient backed by the Azure Policy REST API
+type AzurePolicyExemptionClient struct {
+	// BaseURI is the Azure Resource Manager endpoint, e.g. https://management.azure.com
+	BaseURI    string
+	Authorizer autorest.Authorizer
+	HTTPClient *http.Client
+}
+
+// NewAzurePolicyExemptionClient returns an AzurePolicyExemptionClient for the given ARM endpoint
+func NewAzurePolicyExemptionClient(baseURI string, authorizer autorest.Authorizer) *AzurePolicyExemptionClient {
+	return &AzurePolicyExemptionClient{
+		BaseURI:    baseURI,
+		Authorizer: authorizer,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+type policyExemptionProperties struct {
+	PolicyAssignmentID string `json:"policyAssignmentId"`
+	ExemptionCategory  string `json:"exemptionCategory"`
+	ExpiresOn          string `json:"expiresOn,omitempty"`
+	Description        string `json:"description,omitempty"`
+}
+
+type policyExemption struct {
+	Properties policyExemptionProperties `json:"properties"`
+}
+
+func (c *AzurePolicyExemptionClient) exemptionURL(scope, name string) string {
+	return fmt.Sprintf("%s%s/providers/Microsoft.Authorization/policyExemptions/%s?api-version=%s",
+		strings.TrimSuffix(c.BaseURI, "/"), scope, name, policyAPIVersion)
+}
+
+// CreatePolicyExemption exempts scope from the policy assignment until expiresOn
+func (c *AzurePolicyExemptionClient) CreatePolicyExemption(ctx context.Context, scope, name, policyAssignmentID string, expiresOn time.Time) error {
+	return sendJSON(c.HTTPClient, c.Authorizer, http.MethodPut, c.exemptionURL(scope, name), policyExemption{
+		Properties: policyExemptionProperties{
+			PolicyAssignmentID: policyAssignmentID,
+			ExemptionCategory:  "Waiver",
+			ExpiresOn:          expiresOn.UTC().Format(time.RFC3339),
+			Description:        "Temporary exemption created by aks-engine upgrade",
+		},
+	})
+}
+
+// DeletePolicyExemption removes the policy exemption of scope
+func (c *AzurePolicyExemptionClient) DeletePolicyExemption(ctx context.Context, scope, name string) error {
+	return sendJSON(c.HTTPClient, c.Authorizer, http.MethodDelete, c.exemptionURL(scope, name), nil)
+}
+
+// validatePolicyExemptions ensures PolicyExemptions can be created
+func (kmn *UpgradeMasterNode) validatePolicyExemptions() error {
+	if len(kmn.PolicyExemptions) == 0 {
+		return nil
+	}
+	if kmn.PolicyExemptionClient == nil {
+		return errors.New("a policy exemption client is required to create policy exemptions")
+	}
+	for _, exemption := range kmn.PolicyExemptions {
+		if _, err := utils.ResourceName(exemption.PolicyAssignmentID); err != nil {
+			return errors.Wrapf(err, "parsing policy assignment ID %s", exemption.PolicyAssignmentID)
+		}
+		if exemption.ExpiresIn < 0 {
+			return errors.Errorf("expiry of the policy exemption for policy assignment %s must not be negative", exemption.PolicyAssignmentID)
+		}
+	}
+	return nil
+}
+
+// createPolicyExemptions exempts the resource group of the cluster from the policy assignments of PolicyExemptions
+// and returns the names of the exemptions created. If one cannot be created, those already created are removed.
+func (kmn *UpgradeMasterNode) createPolicyExemptions(ctx context.Context) ([]string, error) {
+	var names []string
+	for _, exemption := range kmn.PolicyExemptions {
+		assignmentName, err := utils.ResourceName(exemption.PolicyAssignmentID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing policy assignment ID %s", exemption.PolicyAssignmentID)
+		}
+		expiresIn := exemption.ExpiresIn
+		if expiresIn == 0 {
+			expiresIn = DefaultPolicyExemptionExpiresIn
+		}
+		name := policyExemptionPrefix + assignmentName
+		kmn.logger.Infof("Creating policy exemption %s for policy assignment %s, expiring in %v", name, exemption.PolicyAssignmentID, expiresIn)
+		if err := kmn.PolicyExemptionClient.CreatePolicyExemption(ctx, kmn.policyExemptionScope(), name, exemption.PolicyAssignmentID, time.Now().Add(expiresIn)); err != nil {
+			kmn.deletePolicyExemptions(ctx, names)
+			return nil, errors.Wrapf(err, "creating policy exemption for policy assignment %s, aborting the upgrade", exemption.PolicyAssignmentID)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// deletePolicyExemptions removes the policy exemptions, only warning on failure since they expire anyway
+func (kmn *UpgradeMasterNode) deletePolicyExemptions(ctx context.Context, names []string) {
+	for _, name := range names {
+		kmn.logger.Infof("Deleting policy exemption %s", name)
+		if err := kmn.PolicyExemptionClient.DeletePolicyExemption(ctx, kmn.policyExemptionScope(), name); err != nil {
+			kmn.logger.Warningf("Failed to delete policy exemption %s, it will expire: %v", name, err)
+		}
+	}
+}
+
+func (kmn *UpgradeMasterNode) policyExemptionScope() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", kmn.SubscriptionID, kmn.ResourceGroup)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const testPolicyAssignmentID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/providers/Microsoft.Authorization/policyAssignments/require-tags"
+
+type fakePolicyExemptionClient struct {
+	// calls records "create <name>" and "delete <name>" in order
+	calls     []string
+	expiresOn time.Time
+	createErr error
+	deleteErr error
+}
+
+func (c *fakePolicyExemptionClient) CreatePolicyExemption(ctx context.Context, scope, name, policyAssignmentID string, expiresOn time.Time) error {
+	c.calls = append(c.calls, "create "+name)
+	c.expiresOn = expiresOn
+	return c.createErr
+}
+
+func (c *fakePolicyExemptionClient) DeletePolicyExemption(ctx context.Context, scope, name string) error {
+	c.calls = append(c.calls, "delete "+name)
+	return c.deleteErr
+}
+
+var _ = Describe("Policy exemption tests", func() {
+	var (
+		mockClient      *armhelpers.MockAKSEngineClient
+		exemptionClient *fakePolicyExemptionClient
+		kmn             *UpgradeMasterNode
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		exemptionClient = &fakePolicyExemptionClient{}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		kmn.PolicyExemptions = []PolicyExemption{{PolicyAssignmentID: testPolicyAssignmentID}}
+		kmn.PolicyExemptionClient = exemptionClient
+	})
+
+	It("Should exempt the resource group while the master VM is deployed", func() {
+		start := time.Now()
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(exemptionClient.calls).To(Equal([]string{"create aks-engine-upgrade-require-tags", "delete aks-engine-upgrade-require-tags"}))
+		Expect(exemptionClient.expiresOn).To(BeTemporally(">=", start.Add(DefaultPolicyExemptionExpiresIn)))
+	})
+
+	It("Should remove the exemption when the deployment fails", func() {
+		mockClient.FailDeployTemplate = true
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+		Expect(exemptionClient.calls).To(Equal([]string{"create aks-engine-upgrade-require-tags", "delete aks-engine-upgrade-require-tags"}))
+	})
+
+	It("Should abort the upgrade when an exemption cannot be created", func() {
+		exemptionClient.createErr = errors.New("forbidden")
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("creating policy exemption for policy assignment " + testPolicyAssignmentID + ", aborting the upgrade: forbidden"))
+		Expect(exemptionClient.calls).To(Equal([]string{"create aks-engine-upgrade-require-tags"}))
+	})
+
+	It("Should not fail when an exemption cannot be removed", func() {
+		exemptionClient.deleteErr = errors.New("not found")
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+	})
+
+	It("Should fail preflight without a policy exemption client", func() {
+		kmn.PolicyExemptionClient = nil
+
+		Expect(kmn.Preflight(context.Background())).To(MatchError("a policy exemption client is required to create policy exemptions"))
+	})
+
+	It("Should send the policy exemption to the Azure Policy REST API", func() {
+		var methods, paths []string
+		var body policyExemption
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			paths = append(paths, r.URL.Path+"?"+r.URL.RawQuery)
+			if r.Method == http.MethodPut {
+				data, _ := ioutil.ReadAll(r.Body)
+				_ = json.Unmarshal(data, &body)
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := NewAzurePolicyExemptionClient(server.URL, autorest.NullAuthorizer{})
+		scope := "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg"
+		expiresOn := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+		Expect(client.CreatePolicyExemption(context.Background(), scope, "aks-engine-upgrade-require-tags", testPolicyAssignmentID, expiresOn)).To(Succeed())
+		Expect(client.DeletePolicyExemption(context.Background(), scope, "aks-engine-upgrade-require-tags")).To(Succeed())
+
+		path := scope + "/providers/Microsoft.Authorization/policyExemptions/aks-engine-upgrade-require-tags?api-version=" + policyAPIVersion
+		Expect(methods).To(Equal([]string{http.MethodPut, http.MethodDelete}))
+		Expect(paths).To(Equal([]string{path, path}))
+		Expect(body.Properties.PolicyAssignmentID).To(Equal(testPolicyAssignmentID))
+		Expect(body.Properties.ExemptionCategory).To(Equal("Waiver"))
+		Expect(body.Properties.ExpiresOn).To(Equal("2020-10-01T12:00:00Z"))
+	})
+})
@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/pkg/errors"
+)
+
+// PoolUpgradeConfig holds the upgrade settings of a single agent pool
+type PoolUpgradeConfig struct {
+	// DrainGracePeriod is the termination grace period given to the pods evicted from the pool nodes,
+	// e.g. several minutes for databases but 30 seconds for stateless front-ends.
+	// Upgrader.DrainGracePeriod applies if zero.
+	DrainGracePeriod time.Duration
+	// ValidationWorkers is the number of new pool nodes validated at the same time, and of pool nodes deleted
+	// by ScaleDownBeforeUpgrade; the pool nodes are still upgraded one at a time.
+	// Upgrader.ValidationWorkers applies if zero.
+	ValidationWorkers int
+	// DrainTimeout is how long to wait for each pool node to be cordoned and drained.
+	// The upgrade cordon drain timeout applies if zero.
+	DrainTimeout time.Duration
+	// SkipDrain deletes the pool nodes without draining them, e.g. for pools running batch jobs only
+	SkipDrain bool
+	// NodeGroupSize stages the upgrade of the pool nodes in their own groups of NodeGroupSize nodes.
+	// Upgrader.NodeGroupSize applies if zero.
+	NodeGroupSize int
+}
+
+// poolUpgradeConfigJSON is the format of a PoolUpgradeConfig in a concurrency file, durations being strings such as 5m
+type poolUpgradeConfigJSON struct {
+	DrainGracePeriod  string `json:"drainGracePeriod,omitempty"`
+	ValidationWorkers int    `json:"validationWorkers,omitempty"`
+	DrainTimeout      string `json:"drainTimeout,omitempty"`
+	SkipDrain         bool   `json:"skipDrain,omitempty"`
+	NodeGroupSize     int    `json:"nodeGroupSize,omitempty"`
+}
+
+// ParseConcurrencyFile reads the upgrade settings of the agent pools from the JSON file at path,
+// an object keyed by pool name, e.g.
+//
+//	{"agentpool1": {"validationWorkers": 3, "drainTimeout": "10m", "skipDrain": false, "nodeGroupSize": 5}}
+func ParseConcurrencyFile(path string) (map[string]PoolUpgradeConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the concurrency file")
+	}
+	var pools map[string]poolUpgradeConfigJSON
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&pools); err != nil {
+		return nil, errors.Wrapf(err, "parsing the concurrency file %s", path)
+	}
+
+	configs := make(map[string]PoolUpgradeConfig, len(pools))
+	for name, pool := range pools {
+		config := PoolUpgradeConfig{
+			ValidationWorkers: pool.ValidationWorkers,
+			SkipDrain:         pool.SkipDrain,
+			NodeGroupSize:     pool.NodeGroupSize,
+		}
+		if config.DrainGracePeriod, err = parsePoolDuration(pool.DrainGracePeriod); err != nil {
+			return nil, errors.Wrapf(err, "parsing the drainGracePeriod of pool %s", name)
+		}
+		if config.DrainTimeout, err = parsePoolDuration(pool.DrainTimeout); err != nil {
+			return nil, errors.Wrapf(err, "parsing the drainTimeout of pool %s", name)
+		}
+		if config.ValidationWorkers < 0 {
+			return nil, errors.Errorf("the validationWorkers of pool %s must not be negative", name)
+		}
+		if config.NodeGroupSize < 0 {
+			return nil, errors.Errorf("the nodeGroupSize of pool %s must not be negative", name)
+		}
+		configs[name] = config
+	}
+	return configs, nil
+}
+
+func parsePoolDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.Errorf("duration %s must not be negative", value)
+	}
+	return d, nil
+}
+
+// ValidatePoolUpgradeConfigs ensures every pool of configs is an agent pool of the cluster definition
+func ValidatePoolUpgradeConfigs(configs map[string]PoolUpgradeConfig, properties *api.Properties) error {
+	pools := map[string]bool{}
+	for _, pool := range properties.AgentPoolProfiles {
+		pools[pool.Name] = true
+	}
+	var unknown []string
+	for name := range configs {
+		if !pools[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.Errorf("agent pools %s of the upgrade settings are not in the cluster definition", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// poolUpgradeConfig returns the upgrade settings of the agent pool, or the zero value if the pool has none.
+func (ku *Upgrader) poolUpgradeConfig(poolName string) PoolUpgradeConfig {
+	return ku.PoolUpgradeConfigs[poolName]
+}
+
+// drainGracePeriod returns the grace period of the pods evicted from the agent pool nodes.
+// Zero means the pods use their own termination grace period.
+func (ku *Upgrader) drainGracePeriod(poolName string) time.Duration {
+	if gracePeriod := ku.poolUpgradeConfig(poolName).DrainGracePeriod; gracePeriod > 0 {
+		return gracePeriod
+	}
+	return ku.DrainGracePeriod
+}
+
+// poolCordonDrainTimeout returns how long to wait for the agent pool nodes to be cordoned and drained
+func (ku *Upgrader) poolCordonDrainTimeout(poolName string) time.Duration {
+	if timeout := ku.poolUpgradeConfig(poolName).DrainTimeout; timeout > 0 {
+		return timeout
+	}
+	if ku.cordonDrainTimeout == nil {
+		return defaultCordonDrainTimeout
+	}
+	return *ku.cordonDrainTimeout
+}
+
+// validationWorkers returns the number of new agent pool nodes validated at the same time
+func (ku *Upgrader) validationWorkers(poolName string) int {
+	if workers := ku.poolUpgradeConfig(poolName).ValidationWorkers; workers > 0 {
+		return workers
+	}
+	return ku.ValidationWorkers
+}
+
+// nodeGroupSize returns the size of the node groups of the agent pool, zero if the pool is not upgraded in groups
+func (ku *Upgrader) nodeGroupSize(poolName string) int {
+	if size := ku.poolUpgradeConfig(poolName).NodeGroupSize; size > 0 {
+		return size
+	}
+	return ku.NodeGroupSize
+}
+
+// hasNodeGroups reports whether the upgrade of the cluster or of any agent pool is staged in node groups
+func (ku *Upgrader) hasNodeGroups() bool {
+	if ku.NodeGroupSize > 0 {
+		return true
+	}
+	for _, config := range ku.PoolUpgradeConfigs {
+		if config.NodeGroupSize > 0 {
+			return true
+		}
+	}
+	return false
+}
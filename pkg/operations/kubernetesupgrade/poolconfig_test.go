@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Pool upgrade config tests", func() {
+	It("Should prefer the pool drain grace period over the global one", func() {
+		u := &Upgrader{
+			DrainGracePeriod: 30 * time.Second,
+			PoolUpgradeConfigs: map[string]PoolUpgradeConfig{
+				"database": {DrainGracePeriod: 5 * time.Minute},
+				"frontend": {},
+			},
+		}
+		Expect(u.drainGracePeriod("database")).To(Equal(5 * time.Minute))
+		Expect(u.drainGracePeriod("frontend")).To(Equal(30 * time.Second))
+		Expect(u.drainGracePeriod("agentpool1")).To(Equal(30 * time.Second))
+		Expect((&Upgrader{}).drainGracePeriod("agentpool1")).To(BeZero())
+	})
+
+	It("Should evict the pods of a drained agent node with the pool drain grace period", func() {
+		kubeClient := &armhelpers.MockKubernetesClient{
+			PodsList:              &v1.PodList{Items: []v1.Pod{{}}},
+			ShouldSupportEviction: true,
+		}
+		kan := newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.Client = &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}
+		kan.cordonDrainTimeout = time.Minute
+		kan.drainGracePeriod = 5 * time.Minute
+
+		vmName := "k8s-agentpool1-12345678-0"
+		Expect(kan.DeleteNode(&vmName, true)).To(Succeed())
+		Expect(kubeClient.GracePeriodSeconds).NotTo(BeNil())
+		Expect(*kubeClient.GracePeriodSeconds).To(Equal(int64(300)))
+	})
+})
+
+var _ = Describe("Concurrency file tests", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "concurrencyfile")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	writeConcurrencyFile := func(content string) string {
+		path := filepath.Join(dir, "concurrency.json")
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	It("Should parse the upgrade settings of each pool", func() {
+		path := writeConcurrencyFile(`{
+			"agentpool1": {"validationWorkers": 3, "drainTimeout": "10m", "nodeGroupSize": 5, "drainGracePeriod": "90s"},
+			"batch": {"skipDrain": true}
+		}`)
+
+		configs, err := ParseConcurrencyFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configs).To(Equal(map[string]PoolUpgradeConfig{
+			"agentpool1": {ValidationWorkers: 3, DrainTimeout: 10 * time.Minute, NodeGroupSize: 5, DrainGracePeriod: 90 * time.Second},
+			"batch":      {SkipDrain: true},
+		}))
+	})
+
+	It("Should fail for invalid upgrade settings", func() {
+		for content, message := range map[string]string{
+			`{"agentpool1": {"maxParalel": 3}}`:         `unknown field "maxParalel"`,
+			`{"agentpool1": {"drainTimeout": "10"}}`:    "parsing the drainTimeout of pool agentpool1",
+			`{"agentpool1": {"drainTimeout": "-1m"}}`:   "duration -1m must not be negative",
+			`{"agentpool1": {"validationWorkers": -1}}`: "the validationWorkers of pool agentpool1 must not be negative",
+			`{"agentpool1": {"nodeGroupSize": -2}}`:     "the nodeGroupSize of pool agentpool1 must not be negative",
+			`["agentpool1"]`:                            "parsing the concurrency file",
+		} {
+			_, err := ParseConcurrencyFile(writeConcurrencyFile(content))
+			Expect(err).To(MatchError(ContainSubstring(message)), content)
+		}
+		_, err := ParseConcurrencyFile(filepath.Join(dir, "missing.json"))
+		Expect(err).To(MatchError(ContainSubstring("reading the concurrency file")))
+	})
+
+	It("Should only accept the agent pools of the cluster definition", func() {
+		cs := api.CreateMockContainerService("testcluster", "1.18.8", 1, 1, false)
+		Expect(ValidatePoolUpgradeConfigs(map[string]PoolUpgradeConfig{"agentpool1": {ValidationWorkers: 2}}, cs.Properties)).To(Succeed())
+		Expect(ValidatePoolUpgradeConfigs(nil, cs.Properties)).To(Succeed())
+
+		err := ValidatePoolUpgradeConfigs(map[string]PoolUpgradeConfig{"agentpool1": {}, "pool2": {}, "batch": {}}, cs.Properties)
+		Expect(err).To(MatchError("agent pools batch, pool2 of the upgrade settings are not in the cluster definition"))
+	})
+
+	It("Should prefer the pool settings over the global ones", func() {
+		cordonDrainTimeout := 5 * time.Minute
+		u := &Upgrader{
+			cordonDrainTimeout: &cordonDrainTimeout,
+			ValidationWorkers:  2,
+			NodeGroupSize:      4,
+			PoolUpgradeConfigs: map[string]PoolUpgradeConfig{
+				"agentpool1": {DrainTimeout: time.Minute, ValidationWorkers: 6, NodeGroupSize: 1},
+			},
+		}
+		Expect(u.poolCordonDrainTimeout("agentpool1")).To(Equal(time.Minute))
+		Expect(u.poolCordonDrainTimeout("agentpool2")).To(Equal(5 * time.Minute))
+		Expect((&Upgrader{}).poolCordonDrainTimeout("agentpool2")).To(Equal(defaultCordonDrainTimeout))
+		Expect(u.validationWorkers("agentpool1")).To(Equal(6))
+		Expect(u.validationWorkers("agentpool2")).To(Equal(2))
+		Expect(u.nodeGroupSize("agentpool1")).To(Equal(1))
+		Expect(u.nodeGroupSize("agentpool2")).To(Equal(4))
+	})
+})
@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultAbortOnPreDrainHookFailure determines whether a node upgrade stops when its pre-drain hook fails
+	DefaultAbortOnPreDrainHookFailure = true
+
+	// preDrainHookExitCodePrefix marks the line of the run command output holding the hook exit code,
+	// the run command API reporting success whatever the exit code of the script
+	preDrainHookExitCodePrefix = "AKS_ENGINE_PRE_DRAIN_HOOK_EXIT_CODE="
+)
+
+// abortOnPreDrainHookFailure returns AbortOnPreDrainHookFailure, DefaultAbortOnPreDrainHookFailure if unset
+func (kan *UpgradeAgentNode) abortOnPreDrainHookFailure() bool {
+	if kan.AbortOnPreDrainHookFailure == nil {
+		return DefaultAbortOnPreDrainHookFailure
+	}
+	return *kan.AbortOnPreDrainHookFailure
+}
+
+// runPreDrainHook runs PreDrainHook, if any, on the node VM through the run command API.
+// The error holds the hook output when the command exits with a non-zero status.
+func (kan *UpgradeAgentNode) runPreDrainHook(client kubernetes.Client, vmName string) error {
+	if len(kan.PreDrainHook) == 0 {
+		return nil
+	}
+	nodeName := strings.ToLower(vmName)
+	node, err := client.GetNode(nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "getting node %s", nodeName)
+	}
+
+	input := compute.RunCommandInput{
+		CommandID: to.StringPtr("RunShellScript"),
+		Script:    &[]string{shellCommand(kan.PreDrainHook) + "; echo " + preDrainHookExitCodePrefix + "$?"},
+	}
+	if node.Status.NodeInfo.OperatingSystem == "windows" {
+		input = compute.RunCommandInput{
+			CommandID: to.StringPtr("RunPowerShellScript"),
+			Script:    &[]string{powerShellCommand(kan.PreDrainHook) + "; Write-Output \"" + preDrainHookExitCodePrefix + "$LASTEXITCODE\""},
+		}
+	}
+
+	kan.logger.Infof("Running pre-drain hook on node %s: %s", nodeName, strings.Join(kan.PreDrainHook, " "))
+	ctx, cancel := context.WithTimeout(context.Background(), upgradeHookTimeout)
+	defer cancel()
+
+	var result compute.RunCommandResult
+	if kan.IsVMSS {
+		instanceID, err := kan.scaleSetInstanceID(ctx, vmName)
+		if err != nil {
+			return err
+		}
+		result, err = kan.Client.RunVirtualMachineScaleSetVMCommand(ctx, kan.ResourceGroup, kan.ScaleSetName, instanceID, input)
+		if err != nil {
+			return errors.Wrapf(err, "running pre-drain hook on node %s", nodeName)
+		}
+	} else {
+		result, err = kan.Client.RunVirtualMachineCommand(ctx, kan.ResourceGroup, vmName, input)
+		if err != nil {
+			return errors.Wrapf(err, "running pre-drain hook on node %s", nodeName)
+		}
+	}
+
+	output, exitCode, err := preDrainHookResult(result)
+	if err != nil {
+		return errors.Wrapf(err, "pre-drain hook %s on node %s", kan.PreDrainHook[0], nodeName)
+	}
+	if exitCode != 0 {
+		return errors.Errorf("pre-drain hook %s failed on node %s with exit code %d: %s", kan.PreDrainHook[0], nodeName, exitCode, output)
+	}
+	kan.logger.Infof("pre-drain hook output on node %s: %s", nodeName, output)
+	return nil
+}
+
+// preDrainHookResult returns the output of the hook and its exit code from the run command result
+func preDrainHookResult(result compute.RunCommandResult) (string, int, error) {
+	var lines []string
+	exitCode := -1
+	if result.Value != nil {
+		for _, status := range *result.Value {
+			if status.Message == nil {
+				continue
+			}
+			for _, line := range strings.Split(*status.Message, "\n") {
+				trimmed := strings.TrimSpace(line)
+				if strings.HasPrefix(trimmed, preDrainHookExitCodePrefix) {
+					code, err := strconv.Atoi(strings.TrimPrefix(trimmed, preDrainHookExitCodePrefix))
+					if err != nil {
+						return "", 0, errors.Errorf("unexpected exit code %q", trimmed)
+					}
+					exitCode = code
+					continue
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	output := strings.TrimSpace(strings.Join(lines, "\n"))
+	if exitCode < 0 {
+		return output, 0, errors.Errorf("exit code not found in the run command output: %s", output)
+	}
+	return output, exitCode, nil
+}
+
+// shellCommand quotes each argument of command for a POSIX shell
+func shellCommand(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// powerShellCommand quotes each argument of command for PowerShell, invoking it with the call operator
+func powerShellCommand(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = "'" + strings.Replace(arg, "'", "''", -1) + "'"
+	}
+	return "& " + strings.Join(quoted, " ")
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Pre-drain hook tests", func() {
+	var (
+		kan        *UpgradeAgentNode
+		mockClient *armhelpers.MockAKSEngineClient
+		cordoned   []string
+		vmName     = "k8s-agentpool1-12345678-0"
+	)
+
+	BeforeEach(func() {
+		cordoned = nil
+		mockClient = &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{
+			UpdateNodeFunc: func(node *v1.Node) (*v1.Node, error) {
+				cordoned = append(cordoned, node.Name)
+				return node, nil
+			},
+		}}
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.Client = mockClient
+		kan.cordonDrainTimeout = time.Minute
+		kan.PreDrainHook = []string{"/opt/db/rebalance", "--node", "it's me"}
+	})
+
+	It("Should run the hook on the node before draining it", func() {
+		mockClient.FakeRunCommandOutput = "Enable succeeded:\n[stdout]\nrebalanced\nAKS_ENGINE_PRE_DRAIN_HOOK_EXIT_CODE=0\n[stderr]\n"
+
+		Expect(kan.DeleteNode(&vmName, true)).To(Succeed())
+		Expect(mockClient.RunCommandTargets).To(Equal([]string{vmName}))
+		Expect(cordoned).NotTo(BeEmpty())
+	})
+
+	It("Should not run the hook when the node is not drained", func() {
+		Expect(kan.DeleteNode(&vmName, false)).To(Succeed())
+		Expect(mockClient.RunCommandTargets).To(BeEmpty())
+	})
+
+	It("Should abort the node upgrade when the hook exits with a non-zero code", func() {
+		mockClient.FakeRunCommandOutput = "[stdout]\npartition owner unreachable\nAKS_ENGINE_PRE_DRAIN_HOOK_EXIT_CODE=3\n"
+
+		err := kan.DeleteNode(&vmName, true)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pre-drain hook /opt/db/rebalance failed on node " + vmName + " with exit code 3"))
+		Expect(err.Error()).To(ContainSubstring("partition owner unreachable"))
+		Expect(cordoned).To(BeEmpty())
+	})
+
+	It("Should drain the node when the hook fails and AbortOnPreDrainHookFailure is false", func() {
+		kan.AbortOnPreDrainHookFailure = to.BoolPtr(false)
+		mockClient.FailRunCommand = true
+
+		Expect(kan.DeleteNode(&vmName, true)).To(Succeed())
+		Expect(cordoned).NotTo(BeEmpty())
+	})
+
+	It("Should fail when the exit code of the hook is missing from the output", func() {
+		err := kan.DeleteNode(&vmName, true)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("exit code not found"))
+	})
+
+	It("Should quote the hook arguments", func() {
+		Expect(shellCommand(kan.PreDrainHook)).To(Equal(`'/opt/db/rebalance' '--node' 'it'\''s me'`))
+		Expect(powerShellCommand(kan.PreDrainHook)).To(Equal(`& '/opt/db/rebalance' '--node' 'it''s me'`))
+	})
+})
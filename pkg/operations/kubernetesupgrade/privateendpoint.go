@@ -0,0 +1,258 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+)
+
+const (
+	privateEndpointAPIVersion = "2020-07-01"
+	privateDNSAPIVersion      = "2018-09-01"
+	// DefaultPrivateDNSRecordTTL is the TTL in seconds of the private DNS record of the API server when TTL is zero
+	DefaultPrivateDNSRecordTTL = 300
+	// defaultPrivateEndpointPollInterval is how often the provisioning state of the private endpoint is polled
+	defaultPrivateEndpointPollInterval = 10 * time.Second
+)
+
+// PrivateEndpointConfig describes the private endpoint of the API server provisioned once each master VM is created,
+// and the private DNS record resolving the API server to the private endpoint
+type PrivateEndpointConfig struct {
+	// Name is the name of the private endpoint, created in the resource group of the cluster;
+	// <dns prefix>-apiserver if empty
+	Name string
+	// SubnetID is the resource ID of the subnet the network interface of the private endpoint is created in
+	SubnetID string
+	// PrivateLinkResourceID is the resource ID of the private link service exposing the API server
+	PrivateLinkResourceID string
+	// GroupIDs are the sub-resources of the private link resource the endpoint connects to,
+	// empty for a private link service
+	GroupIDs []string
+	// PrivateDNSZoneID is the resource ID of the private DNS zone the A record of the API server is set in
+	PrivateDNSZoneID string
+	// RecordName is the name of the A record, the DNS prefix of the cluster if empty
+	RecordName string
+	// TTL is the TTL in seconds of the A record, DefaultPrivateDNSRecordTTL if zero
+	TTL int
+}
+
+// PrivateEndpointClient manages private endpoints and the records of private DNS zones
+type PrivateEndpointClient interface {
+	// CreateOrUpdatePrivateEndpoint creates or updates the private endpoint connecting to the private link resource,
+	// waits for it to be provisioned and returns the private IP address of its network interface
+	CreateOrUpdatePrivateEndpoint(ctx context.Context, resourceGroup, name, location, subnetID, privateLinkResourceID string, groupIDs []string) (string, error)
+	// SetPrivateDNSARecord creates or replaces the A record of the private DNS zone with the IP address
+	SetPrivateDNSARecord(ctx context.Context, privateDNSZoneID, recordName, ipAddress string, ttl int) error
+}
+
+// Compiler to verify AzurePrivateEndpointClient implements PrivateEndpointClient
+var _ PrivateEndpointClient = &AzurePrivateEndpointClient{}
+
+// AzurePrivateEndpointClient is a PrivateEndpointClient backed by the Azure Network and Private DNS REST APIs
+type AzurePrivateEndpointClient struct {
+	// BaseURI is the Azure Resource Manager endpoint, e.g. https://management.azure.com
+	BaseURI        string
+	SubscriptionID string
+	Authorizer     autorest.Authorizer
+	HTTPClient     *http.Client
+	// PollInterval is how often the provisioning state of the private endpoint is polled,
+	// defaultPrivateEndpointPollInterval if zero
+	PollInterval time.Duration
+}
+
+// NewAzurePrivateEndpointClient returns an AzurePrivateEndpointClient for the given ARM endpoint and subscription
+func NewAzurePrivateEndpointClient(baseURI, subscriptionID string, authorizer autorest.Authorizer) *AzurePrivateEndpointClient {
+	return &AzurePrivateEndpointClient{
+		BaseURI:        baseURI,
+		SubscriptionID: subscriptionID,
+		Authorizer:     authorizer,
+		HTTPClient:     &http.Client{Timeout: time.Minute},
+	}
+}
+
+type subResource struct {
+	ID string `json:"id"`
+}
+
+type privateLinkServiceConnectionProperties struct {
+	PrivateLinkServiceID string   `json:"privateLinkServiceId"`
+	GroupIDs             []string `json:"groupIds,omitempty"`
+}
+
+type privateLinkServiceConnection struct {
+	Name       string                                 `json:"name"`
+	Properties privateLinkServiceConnectionProperties `json:"properties"`
+}
+
+type privateEndpointProperties struct {
+	Subnet                        subResource                    `json:"subnet"`
+	PrivateLinkServiceConnections []privateLinkServiceConnection `json:"privateLinkServiceConnections"`
+	ProvisioningState             string                         `json:"provisioningState,omitempty"`
+	NetworkInterfaces             []subResource                  `json:"networkInterfaces,omitempty"`
+}
+
+type privateEndpoint struct {
+	Location   string                    `json:"location"`
+	Properties privateEndpointProperties `json:"properties"`
+}
+
+type privateEndpointInterface struct {
+	Properties struct {
+		IPConfigurations []struct {
+			Properties struct {
+				PrivateIPAddress string `json:"privateIPAddress"`
+			} `json:"properties"`
+		} `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+type aRecord struct {
+	IPv4Address string `json:"ipv4Address"`
+}
+
+type privateDNSRecordSetProperties struct {
+	TTL      int       `json:"ttl"`
+	ARecords []aRecord `json:"aRecords"`
+}
+
+type privateDNSRecordSet struct {
+	Properties privateDNSRecordSetProperties `json:"properties"`
+}
+
+func (c *AzurePrivateEndpointClient) resourceURL(resourceID, apiVersion string) string {
+	return fmt.Sprintf("%s%s?api-version=%s", strings.TrimSuffix(c.BaseURI, "/"), resourceID, apiVersion)
+}
+
+// CreateOrUpdatePrivateEndpoint creates or updates the private endpoint connecting to the private link resource,
+// polls it until it is provisioned and returns the private IP address of its network interface
+func (c *AzurePrivateEndpointClient) CreateOrUpdatePrivateEndpoint(ctx context.Context, resourceGroup, name, location, subnetID, privateLinkResourceID string, groupIDs []string) (string, error) {
+	endpointID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/privateEndpoints/%s", c.SubscriptionID, resourceGroup, name)
+	endpoint := privateEndpoint{
+		Location: location,
+		Properties: privateEndpointProperties{
+			Subnet: subResource{ID: subnetID},
+			PrivateLinkServiceConnections: []privateLinkServiceConnection{
+				{
+					Name: name,
+					Properties: privateLinkServiceConnectionProperties{
+						PrivateLinkServiceID: privateLinkResourceID,
+						GroupIDs:             groupIDs,
+					},
+				},
+			},
+		},
+	}
+	if err := sendJSON(c.HTTPClient, c.Authorizer, http.MethodPut, c.resourceURL(endpointID, privateEndpointAPIVersion), endpoint); err != nil {
+		return "", errors.Wrapf(err, "creating private endpoint %s", name)
+	}
+
+	pollInterval := c.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultPrivateEndpointPollInterval
+	}
+	for {
+		var provisioned privateEndpoint
+		if err := doJSON(c.HTTPClient, c.Authorizer, http.MethodGet, c.resourceURL(endpointID, privateEndpointAPIVersion), nil, &provisioned); err != nil {
+			return "", errors.Wrapf(err, "getting private endpoint %s", name)
+		}
+		switch state := provisioned.Properties.ProvisioningState; state {
+		case "Succeeded":
+			if len(provisioned.Properties.NetworkInterfaces) == 0 {
+				return "", errors.Errorf("private endpoint %s has no network interface", name)
+			}
+			return c.privateIPAddress(ctx, provisioned.Properties.NetworkInterfaces[0].ID)
+		case "Failed", "Canceled":
+			return "", errors.Errorf("provisioning private endpoint %s: %s", name, state)
+		}
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrapf(ctx.Err(), "waiting for private endpoint %s to be provisioned", name)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// privateIPAddress returns the private IP address of the network interface of a private endpoint
+func (c *AzurePrivateEndpointClient) privateIPAddress(ctx context.Context, nicID string) (string, error) {
+	var nic privateEndpointInterface
+	if err := doJSON(c.HTTPClient, c.Authorizer, http.MethodGet, c.resourceURL(nicID, privateEndpointAPIVersion), nil, &nic); err != nil {
+		return "", errors.Wrapf(err, "getting network interface %s", nicID)
+	}
+	for _, ipConfiguration := range nic.Properties.IPConfigurations {
+		if ipConfiguration.Properties.PrivateIPAddress != "" {
+			return ipConfiguration.Properties.PrivateIPAddress, nil
+		}
+	}
+	return "", errors.Errorf("network interface %s has no private IP address", nicID)
+}
+
+// SetPrivateDNSARecord creates or replaces the A record of the private DNS zone with the IP address
+func (c *AzurePrivateEndpointClient) SetPrivateDNSARecord(ctx context.Context, privateDNSZoneID, recordName, ipAddress string, ttl int) error {
+	return sendJSON(c.HTTPClient, c.Authorizer, http.MethodPut, c.resourceURL(privateDNSZoneID+"/A/"+recordName, privateDNSAPIVersion), privateDNSRecordSet{
+		Properties: privateDNSRecordSetProperties{
+			TTL:      ttl,
+			ARecords: []aRecord{{IPv4Address: ipAddress}},
+		},
+	})
+}
+
+// validatePrivateEndpointConfig ensures the private endpoint of the API server can be provisioned
+func (kmn *UpgradeMasterNode) validatePrivateEndpointConfig() error {
+	config := kmn.PrivateEndpointConfig
+	if config == nil {
+		return nil
+	}
+	if kmn.PrivateEndpointClient == nil {
+		return errors.New("a private endpoint client is required to provision the private endpoint of the API server")
+	}
+	for _, id := range []string{config.SubnetID, config.PrivateLinkResourceID, config.PrivateDNSZoneID} {
+		if _, err := utils.ResourceGroupName(id); err != nil {
+			return errors.Wrapf(err, "parsing resource ID %q of the private endpoint of the API server", id)
+		}
+	}
+	if config.TTL < 0 {
+		return errors.Errorf("TTL %d of the private DNS record of the API server must not be negative", config.TTL)
+	}
+	return nil
+}
+
+// ProvisionPrivateEndpoint creates or updates the private endpoint of the API server and points the A record
+// of the private DNS zone to its private IP address
+func (kmn *UpgradeMasterNode) ProvisionPrivateEndpoint(ctx context.Context, config *PrivateEndpointConfig) error {
+	if kmn.PrivateEndpointClient == nil {
+		return errors.New("no private endpoint client configured")
+	}
+	name := config.Name
+	if name == "" {
+		name = kmn.UpgradeContainerService.Properties.GetDNSPrefix() + "-apiserver"
+	}
+	recordName := config.RecordName
+	if recordName == "" {
+		recordName = kmn.UpgradeContainerService.Properties.GetDNSPrefix()
+	}
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = DefaultPrivateDNSRecordTTL
+	}
+
+	kmn.logger.Infof("Provisioning private endpoint %s of the API server to %s", name, config.PrivateLinkResourceID)
+	ipAddress, err := kmn.PrivateEndpointClient.CreateOrUpdatePrivateEndpoint(ctx, kmn.ResourceGroup, name, kmn.UpgradeContainerService.Location,
+		config.SubnetID, config.PrivateLinkResourceID, config.GroupIDs)
+	if err != nil {
+		return errors.Wrap(err, "provisioning the private endpoint of the API server")
+	}
+	kmn.logger.Infof("Setting private DNS record %s of zone %s to %s", recordName, config.PrivateDNSZoneID, ipAddress)
+	if err := kmn.PrivateEndpointClient.SetPrivateDNSARecord(ctx, config.PrivateDNSZoneID, recordName, ipAddress, ttl); err != nil {
+		return errors.Wrapf(err, "setting private DNS record %s of the API server", recordName)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const (
+	testPrivateEndpointSubnetID  = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/endpoints"
+	testPrivateLinkServiceID     = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/privateLinkServices/apiserver"
+	testPrivateDNSZoneID         = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/dns/providers/Microsoft.Network/privateDnsZones/privatelink.eastus.cloudapp.azure.com"
+	testPrivateEndpointIPAddress = "10.240.255.10"
+)
+
+type fakePrivateEndpointClient struct {
+	// calls records "endpoint <name> <location>" and "record <name> <ip> <ttl>" in order
+	calls       []string
+	endpointErr error
+	recordErr   error
+}
+
+func (c *fakePrivateEndpointClient) CreateOrUpdatePrivateEndpoint(ctx context.Context, resourceGroup, name, location, subnetID, privateLinkResourceID string, groupIDs []string) (string, error) {
+	c.calls = append(c.calls, "endpoint "+name+" "+location)
+	if c.endpointErr != nil {
+		return "", c.endpointErr
+	}
+	return testPrivateEndpointIPAddress, nil
+}
+
+func (c *fakePrivateEndpointClient) SetPrivateDNSARecord(ctx context.Context, privateDNSZoneID, recordName, ipAddress string, ttl int) error {
+	c.calls = append(c.calls, "record "+recordName+" "+ipAddress+" "+time.Duration(ttl*int(time.Second)).String())
+	return c.recordErr
+}
+
+var _ = Describe("Private endpoint tests", func() {
+	var (
+		endpointClient *fakePrivateEndpointClient
+		kmn            *UpgradeMasterNode
+		dnsPrefix      string
+	)
+
+	BeforeEach(func() {
+		endpointClient = &fakePrivateEndpointClient{}
+		kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+		kmn.PrivateEndpointConfig = &PrivateEndpointConfig{
+			SubnetID:              testPrivateEndpointSubnetID,
+			PrivateLinkResourceID: testPrivateLinkServiceID,
+			PrivateDNSZoneID:      testPrivateDNSZoneID,
+		}
+		kmn.PrivateEndpointClient = endpointClient
+		dnsPrefix = kmn.UpgradeContainerService.Properties.GetDNSPrefix()
+	})
+
+	It("Should provision the private endpoint and its DNS record once the master VM is created", func() {
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(endpointClient.calls).To(Equal([]string{
+			"endpoint " + dnsPrefix + "-apiserver " + kmn.UpgradeContainerService.Location,
+			"record " + dnsPrefix + " " + testPrivateEndpointIPAddress + " 5m0s",
+		}))
+	})
+
+	It("Should use the endpoint name, record name and TTL of the config", func() {
+		kmn.PrivateEndpointConfig.Name = "apiserver-pe"
+		kmn.PrivateEndpointConfig.RecordName = "api"
+		kmn.PrivateEndpointConfig.TTL = 60
+
+		Expect(kmn.ProvisionPrivateEndpoint(context.Background(), kmn.PrivateEndpointConfig)).To(Succeed())
+		Expect(endpointClient.calls).To(Equal([]string{
+			"endpoint apiserver-pe " + kmn.UpgradeContainerService.Location,
+			"record api " + testPrivateEndpointIPAddress + " 1m0s",
+		}))
+	})
+
+	It("Should not provision a private endpoint when the deployment fails", func() {
+		kmn.Client = &armhelpers.MockAKSEngineClient{FailDeployTemplate: true}
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+		Expect(endpointClient.calls).To(BeEmpty())
+	})
+
+	It("Should fail the node upgrade when the private endpoint cannot be created", func() {
+		endpointClient.endpointErr = errors.New("subnet has private endpoint network policies enabled")
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(MatchError("provisioning the private endpoint of the API server: subnet has private endpoint network policies enabled"))
+		Expect(endpointClient.calls).To(HaveLen(1))
+	})
+
+	It("Should fail the node upgrade when the DNS record cannot be set", func() {
+		endpointClient.recordErr = errors.New("zone not found")
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(MatchError("setting private DNS record " + dnsPrefix + " of the API server: zone not found"))
+	})
+
+	It("Should fail preflight without a private endpoint client", func() {
+		kmn.PrivateEndpointClient = nil
+
+		Expect(kmn.Preflight(context.Background())).To(MatchError("a private endpoint client is required to provision the private endpoint of the API server"))
+	})
+
+	It("Should fail preflight with an invalid resource ID", func() {
+		kmn.PrivateEndpointConfig.PrivateDNSZoneID = "privatelink.eastus.cloudapp.azure.com"
+
+		err := kmn.Preflight(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`parsing resource ID "privatelink.eastus.cloudapp.azure.com" of the private endpoint of the API server`))
+	})
+
+	Context("AzurePrivateEndpointClient", func() {
+		var (
+			server    *httptest.Server
+			requests  []string
+			endpoint  privateEndpoint
+			recordSet privateDNSRecordSet
+			gets      int
+			state     string
+			client    *AzurePrivateEndpointClient
+		)
+		endpointPath := "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/privateEndpoints/apiserver-pe"
+		nicPath := "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/networkInterfaces/apiserver-pe.nic"
+
+		BeforeEach(func() {
+			requests = nil
+			endpoint = privateEndpoint{}
+			recordSet = privateDNSRecordSet{}
+			gets = 0
+			state = "Succeeded"
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+				data, _ := ioutil.ReadAll(r.Body)
+				switch {
+				case r.Method == http.MethodPut && r.URL.Path == endpointPath:
+					_ = json.Unmarshal(data, &endpoint)
+					w.WriteHeader(http.StatusCreated)
+				case r.Method == http.MethodGet && r.URL.Path == endpointPath:
+					gets++
+					provisioningState := "Updating"
+					if gets > 1 {
+						provisioningState = state
+					}
+					_, _ = w.Write([]byte(`{"properties":{"provisioningState":"` + provisioningState + `","networkInterfaces":[{"id":"` + nicPath + `"}]}}`))
+				case r.Method == http.MethodGet && r.URL.Path == nicPath:
+					_, _ = w.Write([]byte(`{"properties":{"ipConfigurations":[{"properties":{"privateIPAddress":"` + testPrivateEndpointIPAddress + `"}}]}}`))
+				case r.Method == http.MethodPut:
+					_ = json.Unmarshal(data, &recordSet)
+					w.WriteHeader(http.StatusOK)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			client = NewAzurePrivateEndpointClient(server.URL, "DEC923E3-1EF1-4745-9516-37906D56DEC4", autorest.NullAuthorizer{})
+			client.PollInterval = time.Millisecond
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("Should create the private endpoint and wait for its private IP address", func() {
+			ipAddress, err := client.CreateOrUpdatePrivateEndpoint(context.Background(), "TestRg", "apiserver-pe", "eastus",
+				testPrivateEndpointSubnetID, testPrivateLinkServiceID, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipAddress).To(Equal(testPrivateEndpointIPAddress))
+
+			Expect(requests).To(Equal([]string{
+				"PUT " + endpointPath + "?api-version=" + privateEndpointAPIVersion,
+				"GET " + endpointPath + "?api-version=" + privateEndpointAPIVersion,
+				"GET " + endpointPath + "?api-version=" + privateEndpointAPIVersion,
+				"GET " + nicPath + "?api-version=" + privateEndpointAPIVersion,
+			}))
+			Expect(endpoint.Location).To(Equal("eastus"))
+			Expect(endpoint.Properties.Subnet.ID).To(Equal(testPrivateEndpointSubnetID))
+			Expect(endpoint.Properties.PrivateLinkServiceConnections).To(Equal([]privateLinkServiceConnection{
+				{
+					Name:       "apiserver-pe",
+					Properties: privateLinkServiceConnectionProperties{PrivateLinkServiceID: testPrivateLinkServiceID},
+				},
+			}))
+		})
+
+		It("Should fail when the private endpoint fails to provision", func() {
+			state = "Failed"
+
+			_, err := client.CreateOrUpdatePrivateEndpoint(context.Background(), "TestRg", "apiserver-pe", "eastus",
+				testPrivateEndpointSubnetID, testPrivateLinkServiceID, nil)
+			Expect(err).To(MatchError("provisioning private endpoint apiserver-pe: Failed"))
+		})
+
+		It("Should stop waiting when the context is done", func() {
+			client.PollInterval = time.Hour
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := client.CreateOrUpdatePrivateEndpoint(ctx, "TestRg", "apiserver-pe", "eastus",
+				testPrivateEndpointSubnetID, testPrivateLinkServiceID, nil)
+			Expect(err).To(MatchError("waiting for private endpoint apiserver-pe to be provisioned: context deadline exceeded"))
+		})
+
+		It("Should set the A record of the private DNS zone", func() {
+			Expect(client.SetPrivateDNSARecord(context.Background(), testPrivateDNSZoneID, "testcluster", testPrivateEndpointIPAddress, 300)).To(Succeed())
+
+			Expect(requests).To(Equal([]string{"PUT " + testPrivateDNSZoneID + "/A/testcluster?api-version=" + privateDNSAPIVersion}))
+			Expect(recordSet).To(Equal(privateDNSRecordSet{
+				Properties: privateDNSRecordSetProperties{
+					TTL:      300,
+					ARecords: []aRecord{{IPv4Address: testPrivateEndpointIPAddress}},
+				},
+			}))
+		})
+	})
+})
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// AggregatedValidationError lists the nodes that failed the validation of a ReadinessCheckPool
+type AggregatedValidationError struct {
+	Failures []NodeFailure
+}
+
+func (e *AggregatedValidationError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.NodeName, f.Err))
+	}
+	return fmt.Sprintf("%d nodes failed validation: %s", len(e.Failures), strings.Join(failures, "; "))
+}
+
+// ReadinessCheckPool validates several nodes at the same time, so that waiting for the readiness
+// of a node does not delay the validation of the others
+type ReadinessCheckPool struct {
+	// Workers is the number of nodes validated at the same time, one if below one
+	Workers int
+}
+
+// Validate calls validate for each node, from up to Workers goroutines, and waits for all of them.
+// It returns an AggregatedValidationError listing the failed nodes in the order of nodeNames.
+func (p *ReadinessCheckPool) Validate(nodeNames []string, validate func(nodeName string) error) error {
+	workers := p.Workers
+	if workers > len(nodeNames) {
+		workers = len(nodeNames)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make([]error, len(nodeNames))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = validate(nodeNames[i])
+			}
+		}()
+	}
+	for i := range nodeNames {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failures []NodeFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, NodeFailure{NodeName: nodeNames[i], Err: err})
+		}
+	}
+	if len(failures) > 0 {
+		return &AggregatedValidationError{Failures: failures}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Readiness check pool tests", func() {
+	nodeNames := []string{"k8s-agentpool1-0", "k8s-agentpool1-1", "k8s-agentpool1-2", "k8s-agentpool1-3"}
+
+	It("Should validate every node", func() {
+		var mu sync.Mutex
+		var validated []string
+		pool := &ReadinessCheckPool{Workers: 2}
+
+		err := pool.Validate(nodeNames, func(nodeName string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			validated = append(validated, nodeName)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(validated).To(ConsistOf(nodeNames))
+	})
+
+	It("Should run up to Workers validations at the same time", func() {
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		pool := &ReadinessCheckPool{Workers: 3}
+
+		err := pool.Validate(append(nodeNames, "k8s-agentpool1-4", "k8s-agentpool1-5"), func(nodeName string) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(maxRunning).To(Equal(3))
+	})
+
+	It("Should validate the nodes one after the other without workers", func() {
+		var validated []string
+		pool := &ReadinessCheckPool{}
+
+		err := pool.Validate(nodeNames, func(nodeName string) error {
+			validated = append(validated, nodeName)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(validated).To(Equal(nodeNames))
+	})
+
+	It("Should return all the failed nodes in an AggregatedValidationError", func() {
+		pool := &ReadinessCheckPool{Workers: 4}
+
+		err := pool.Validate(nodeNames, func(nodeName string) error {
+			if nodeName == "k8s-agentpool1-1" || nodeName == "k8s-agentpool1-3" {
+				return errors.New("node was not ready")
+			}
+			return nil
+		})
+		Expect(err).To(HaveOccurred())
+		aggregated, ok := err.(*AggregatedValidationError)
+		Expect(ok).To(BeTrue())
+		Expect(aggregated.Failures).To(HaveLen(2))
+		Expect(aggregated.Failures[0].NodeName).To(Equal("k8s-agentpool1-1"))
+		Expect(aggregated.Failures[1].NodeName).To(Equal("k8s-agentpool1-3"))
+		Expect(err.Error()).To(Equal("2 nodes failed validation: k8s-agentpool1-1: node was not ready; k8s-agentpool1-3: node was not ready"))
+	})
+
+	It("Should validate the nodes created to make up the agent pool count together", func() {
+		cs := api.CreateMockContainerService("testcluster", "", 1, 3, false)
+		reporter := &fakeReporter{}
+		uc := UpgradeCluster{
+			Translator:        &i18n.Translator{},
+			Logger:            log.NewEntry(log.New()),
+			Reporters:         []UpgradeReporter{reporter},
+			ValidationWorkers: 3,
+		}
+		mockClient := armhelpers.MockAKSEngineClient{}
+		uc.Client = &mockClient
+		uc.ClusterTopology = ClusterTopology{}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = cs
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+
+		err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+
+		var phases []UpgradeEventType
+		for _, event := range reporter.events {
+			if event.PoolName == "agentpool1" && (event.Type == NodeCreatingEvent || event.Type == NodeValidatingEvent) {
+				phases = append(phases, event.Type)
+			}
+		}
+		Expect(len(phases)).To(BeNumerically(">=", 4))
+		created := 0
+		for created < len(phases) && phases[created] == NodeCreatingEvent {
+			created++
+		}
+		Expect(created).To(BeNumerically(">", 1))
+		for _, phase := range phases[created : 2*created] {
+			Expect(phase).To(Equal(NodeValidatingEvent))
+		}
+
+		os.RemoveAll("./translations")
+	})
+})
+
+// benchmarkReadinessCheckPool validates 8 nodes, each taking 10ms to become ready, with the given number of workers
+func benchmarkReadinessCheckPool(b *testing.B, workers int) {
+	nodeNames := make([]string, 8)
+	for i := range nodeNames {
+		nodeNames[i] = fmt.Sprintf("k8s-agentpool1-%d", i)
+	}
+	pool := &ReadinessCheckPool{Workers: workers}
+	for i := 0; i < b.N; i++ {
+		_ = pool.Validate(nodeNames, func(nodeName string) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+	}
+}
+
+func BenchmarkReadinessCheckPoolSerial(b *testing.B) {
+	benchmarkReadinessCheckPool(b, 1)
+}
+
+func BenchmarkReadinessCheckPoolParallel(b *testing.B) {
+	benchmarkReadinessCheckPool(b, 8)
+}
@@ -0,0 +1,358 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+const (
+	// upgradeReportFileFormat names the upgrade report written to the output directory
+	upgradeReportFileFormat = "upgrade-report-20060102T150405Z.md"
+	// DefaultUpgradeReportSASExpiryDuration is how long the SAS token of an uploaded upgrade report is valid
+	DefaultUpgradeReportSASExpiryDuration = 7 * 24 * time.Hour
+)
+
+// portalURLs are the Azure Portal endpoints of the clouds not described by a custom cloud profile
+var portalURLs = map[string]string{
+	"AzurePublicCloud":       "https://portal.azure.com",
+	"AzureChinaCloud":        "https://portal.azure.cn",
+	"AzureGermanCloud":       "https://portal.microsoftazure.de",
+	"AzureUSGovernmentCloud": "https://portal.azure.us",
+}
+
+// UpgradeSummary describes the outcome of an upgrade, see UpgradeSummaryRecorder
+type UpgradeSummary struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	FromVersion string
+	ToVersion   string
+	Succeeded   bool
+	// Nodes lists the nodes to upgrade in the order the upgrade reached them, the pending nodes last
+	Nodes []NodeUpgradeSummary
+	// SkippedPools lists the agent pools excluded from the upgrade
+	SkippedPools []string
+	// Deployments lists the ARM deployments of the upgrade
+	Deployments []string
+	// Errors lists the errors of the failed nodes and of the upgrade
+	Errors []string
+}
+
+// NodeUpgradeSummary describes the upgrade of a node
+type NodeUpgradeSummary struct {
+	Name      string
+	Pool      string
+	Phase     NodePhase
+	StartTime time.Time
+	EndTime   time.Time
+	Error     string
+}
+
+// Compiler to verify UpgradeSummaryRecorder implements UpgradeReporter
+var _ UpgradeReporter = &UpgradeSummaryRecorder{}
+
+// UpgradeSummaryRecorder records the upgrade events into an UpgradeSummary
+type UpgradeSummaryRecorder struct {
+	mu      sync.Mutex
+	summary UpgradeSummary
+	nodes   map[string]*NodeUpgradeSummary
+	order   []string
+}
+
+// Report records the phase of the node of the event
+func (r *UpgradeSummaryRecorder) Report(event UpgradeEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event.Type {
+	case UpgradeStartedEvent:
+		r.summary.StartTime = event.Time
+		r.summary.SkippedPools = append([]string(nil), event.SkippedPools...)
+		for _, name := range event.Nodes {
+			r.node(name)
+		}
+	case UpgradeCompletedEvent:
+		r.summary.EndTime, r.summary.Succeeded = event.Time, true
+	case UpgradeFailedEvent:
+		r.summary.EndTime, r.summary.Succeeded = event.Time, false
+		r.summary.Errors = append(r.summary.Errors, event.Message)
+	}
+	phase, ok := nodePhases[event.Type]
+	if !ok || event.NodeName == "" {
+		return nil
+	}
+	n := r.node(event.NodeName)
+	if event.PoolName != "" {
+		n.Pool = event.PoolName
+	}
+	if n.StartTime.IsZero() {
+		n.StartTime = event.Time
+	}
+	n.Phase = phase
+	if phase == NodeDone || phase == NodeFailed || phase == NodeSkipped {
+		n.EndTime = event.Time
+	}
+	if phase == NodeFailed {
+		n.Error = event.Message
+		r.summary.Errors = append(r.summary.Errors, fmt.Sprintf("%s: %s", event.NodeName, event.Message))
+	}
+	return nil
+}
+
+func (r *UpgradeSummaryRecorder) node(name string) *NodeUpgradeSummary {
+	if r.nodes == nil {
+		r.nodes = map[string]*NodeUpgradeSummary{}
+	}
+	n, ok := r.nodes[name]
+	if !ok {
+		n = &NodeUpgradeSummary{Name: name, Phase: NodePending}
+		r.nodes[name] = n
+		r.order = append(r.order, name)
+	}
+	return n
+}
+
+// Summary returns the upgrade summary recorded so far
+func (r *UpgradeSummaryRecorder) Summary() UpgradeSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary := r.summary
+	summary.Errors = append([]string(nil), r.summary.Errors...)
+	summary.Nodes = make([]NodeUpgradeSummary, 0, len(r.order))
+	for _, name := range r.order {
+		summary.Nodes = append(summary.Nodes, *r.nodes[name])
+	}
+	sort.SliceStable(summary.Nodes, func(i, j int) bool {
+		a, b := summary.Nodes[i].StartTime, summary.Nodes[j].StartTime
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return summary
+}
+
+// AgentPools returns the agent pools whose nodes were all upgraded, or skipped to be upgraded manually,
+// and the agent pools with a failed node. The pools with pending nodes are in neither list.
+func (s UpgradeSummary) AgentPools() (upgraded, failed []string) {
+	phases := map[string]NodePhase{}
+	for _, n := range s.Nodes {
+		if n.Pool == "" || n.Pool == MasterPoolName {
+			continue
+		}
+		phase := n.Phase
+		if phase == NodeSkipped {
+			phase = NodeDone
+		}
+		switch current, ok := phases[n.Pool]; {
+		case !ok, phase == NodeFailed, current == NodeDone:
+			phases[n.Pool] = phase
+		}
+	}
+	for pool, phase := range phases {
+		switch phase {
+		case NodeDone:
+			upgraded = append(upgraded, pool)
+		case NodeFailed:
+			failed = append(failed, pool)
+		}
+	}
+	sort.Strings(upgraded)
+	sort.Strings(failed)
+	return upgraded, failed
+}
+
+// GenerateUpgradeReportMarkdown returns a Markdown report of the upgrade: its timeline, the upgraded nodes, the ARM
+// deployments, the errors and links to the resources in the Azure Portal. The upgrade deployments of the resource
+// group started since the upgrade are added to those of summary. The report is written to OutputDirectory if set
+// and uploaded to UpgradeReportBlobURI through TemplateBlobClient if set.
+func (kmn *UpgradeMasterNode) GenerateUpgradeReportMarkdown(ctx context.Context, summary UpgradeSummary) (string, error) {
+	deployments, err := kmn.upgradeDeployments(ctx, summary)
+	if err != nil {
+		kmn.logger.Warnf("Failed to list the upgrade deployments of the report: %v", err)
+	}
+	report := kmn.upgradeReportMarkdown(summary, deployments)
+
+	fileName := summary.StartTime.UTC().Format(upgradeReportFileFormat)
+	if kmn.OutputDirectory != "" {
+		path := filepath.Join(kmn.OutputDirectory, fileName)
+		if err := ioutil.WriteFile(path, []byte(report), 0644); err != nil {
+			return report, errors.Wrapf(err, "writing upgrade report %s", path)
+		}
+		kmn.logger.Infof("Upgrade report written to %s", path)
+	}
+	if kmn.UpgradeReportBlobURI != "" {
+		if kmn.TemplateBlobClient == nil {
+			return report, errors.New("a template blob client is required to upload the upgrade report")
+		}
+		containerURL, err := url.Parse(kmn.UpgradeReportBlobURI)
+		if err != nil {
+			return report, errors.Wrapf(err, "parsing upgrade report blob URI %s", kmn.UpgradeReportBlobURI)
+		}
+		uri, err := kmn.TemplateBlobClient.UploadTemplate(ctx, *containerURL, fileName, []byte(report), DefaultUpgradeReportSASExpiryDuration)
+		if err != nil {
+			return report, errors.Wrap(err, "uploading upgrade report")
+		}
+		kmn.logger.Infof("Upgrade report uploaded to %s", uri)
+	}
+	return report, nil
+}
+
+// upgradeDeployments returns the deployments of summary and the k8s-upgrade-* deployments of the resource group
+// started since the upgrade
+func (kmn *UpgradeMasterNode) upgradeDeployments(ctx context.Context, summary UpgradeSummary) ([]string, error) {
+	deployments := append([]string(nil), summary.Deployments...)
+	seen := map[string]bool{}
+	for _, name := range deployments {
+		seen[name] = true
+	}
+	page, err := kmn.Client.ListDeployments(ctx, kmn.ResourceGroup)
+	if err != nil {
+		return deployments, errors.Wrap(err, "listing deployments")
+	}
+	for ; page.NotDone(); err = page.Next() {
+		if err != nil {
+			return deployments, errors.Wrap(err, "listing deployments")
+		}
+		for _, d := range page.Values() {
+			d := d
+			name := to.String(d.Name)
+			if !strings.HasPrefix(name, upgradeDeploymentPrefix) || seen[name] || deploymentTimestamp(&d).Before(summary.StartTime) {
+				continue
+			}
+			seen[name] = true
+			deployments = append(deployments, name)
+		}
+	}
+	return deployments, nil
+}
+
+func (kmn *UpgradeMasterNode) upgradeReportMarkdown(summary UpgradeSummary, deployments []string) string {
+	var b bytes.Buffer
+	result := "Succeeded"
+	if !summary.Succeeded {
+		result = "Failed"
+	}
+	fmt.Fprintf(&b, "# Upgrade of resource group %s\n\n", kmn.portalLink(kmn.ResourceGroup, ""))
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Result | %s |\n", result)
+	fmt.Fprintf(&b, "| Kubernetes version | %s to %s |\n", summary.FromVersion, summary.ToVersion)
+	fmt.Fprintf(&b, "| Started | %s |\n", reportTime(summary.StartTime))
+	fmt.Fprintf(&b, "| Completed | %s |\n", reportTime(summary.EndTime))
+	fmt.Fprintf(&b, "| Duration | %s |\n", reportDuration(summary.StartTime, summary.EndTime))
+
+	fmt.Fprintf(&b, "\n## Timeline\n\n")
+	fmt.Fprintf(&b, "- %s Upgrade started\n", reportTime(summary.StartTime))
+	type step struct {
+		time    time.Time
+		message string
+	}
+	var steps []step
+	for _, n := range summary.Nodes {
+		if !n.StartTime.IsZero() && n.Phase != NodeSkipped {
+			steps = append(steps, step{n.StartTime, fmt.Sprintf("Node %s started upgrading", n.Name)})
+		}
+		switch n.Phase {
+		case NodeDone:
+			steps = append(steps, step{n.EndTime, fmt.Sprintf("Node %s upgraded", n.Name)})
+		case NodeFailed:
+			steps = append(steps, step{n.EndTime, fmt.Sprintf("Node %s failed", n.Name)})
+		case NodeSkipped:
+			steps = append(steps, step{n.EndTime, fmt.Sprintf("Node %s skipped", n.Name)})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].time.Before(steps[j].time) })
+	for _, s := range steps {
+		fmt.Fprintf(&b, "- %s %s\n", reportTime(s.time), s.message)
+	}
+	if !summary.EndTime.IsZero() {
+		fmt.Fprintf(&b, "- %s Upgrade %s\n", reportTime(summary.EndTime), strings.ToLower(result))
+	}
+
+	fmt.Fprintf(&b, "\n## Nodes\n\n")
+	fmt.Fprintf(&b, "| Node | Pool | Result | Started | Completed | Duration |\n|---|---|---|---|---|---|\n")
+	for _, n := range summary.Nodes {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", kmn.nodePortalLink(n.Name), n.Pool, n.Phase,
+			reportTime(n.StartTime), reportTime(n.EndTime), reportDuration(n.StartTime, n.EndTime))
+	}
+
+	upgraded, failed := summary.AgentPools()
+	fmt.Fprintf(&b, "\n## Agent pools\n\n")
+	fmt.Fprintf(&b, "- Upgraded: %s\n", reportList(upgraded))
+	fmt.Fprintf(&b, "- Failed: %s\n", reportList(failed))
+	fmt.Fprintf(&b, "- Skipped: %s\n", reportList(summary.SkippedPools))
+
+	fmt.Fprintf(&b, "\n## ARM deployments\n\n")
+	if len(deployments) == 0 {
+		fmt.Fprintf(&b, "None\n")
+	}
+	for _, name := range deployments {
+		fmt.Fprintf(&b, "- %s\n", kmn.portalLink(name, "/providers/Microsoft.Resources/deployments/"+name))
+	}
+
+	fmt.Fprintf(&b, "\n## Errors\n\n")
+	if len(summary.Errors) == 0 {
+		fmt.Fprintf(&b, "None\n")
+	}
+	for _, e := range summary.Errors {
+		fmt.Fprintf(&b, "```\n%s\n```\n", e)
+	}
+	return b.String()
+}
+
+// nodePortalLink links a node to its VM, or to its scale set for the scale set nodes named <scale set><6 char instance>
+func (kmn *UpgradeMasterNode) nodePortalLink(name string) string {
+	if len(name) > 6 && strings.HasSuffix(name[:len(name)-6], "-vmss") {
+		return kmn.portalLink(name, "/providers/Microsoft.Compute/virtualMachineScaleSets/"+name[:len(name)-6])
+	}
+	return kmn.portalLink(name, "/providers/Microsoft.Compute/virtualMachines/"+name)
+}
+
+// portalLink returns a Markdown link to the resource of the resource group in the Azure Portal of the cluster cloud
+func (kmn *UpgradeMasterNode) portalLink(text, resourcePath string) string {
+	portal := portalURLs["AzurePublicCloud"]
+	if kmn.UpgradeContainerService != nil {
+		cs := kmn.UpgradeContainerService
+		if cs.Properties != nil && cs.Properties.IsCustomCloudProfile() && cs.Properties.CustomCloudProfile.Environment != nil &&
+			cs.Properties.CustomCloudProfile.Environment.ManagementPortalURL != "" {
+			portal = cs.Properties.CustomCloudProfile.Environment.ManagementPortalURL
+		} else if u, ok := portalURLs[helpers.GetCloudTargetEnv(cs.Location)]; ok {
+			portal = u
+		}
+	}
+	return fmt.Sprintf("[%s](%s/#resource/subscriptions/%s/resourceGroups/%s%s)", text, strings.TrimSuffix(portal, "/"), kmn.SubscriptionID, kmn.ResourceGroup, resourcePath)
+}
+
+func reportTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func reportList(items []string) string {
+	if len(items) == 0 {
+		return "None"
+	}
+	return strings.Join(items, ", ")
+}
+
+func reportDuration(start, end time.Time) string {
+	if start.IsZero() || end.IsZero() {
+		return "-"
+	}
+	return end.Sub(start).Round(time.Second).String()
+}
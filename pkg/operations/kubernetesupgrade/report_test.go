@@ -0,0 +1,163 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("Upgrade report tests", func() {
+	var (
+		start      time.Time
+		recorder   *UpgradeSummaryRecorder
+		mockClient *armhelpers.MockAKSEngineClient
+		kmn        *UpgradeMasterNode
+	)
+
+	deployment := func(name string, started time.Time) resources.DeploymentExtended {
+		return resources.DeploymentExtended{
+			Name: to.StringPtr(name),
+			Properties: &resources.DeploymentPropertiesExtended{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				Timestamp:         &date.Time{Time: started},
+			},
+		}
+	}
+
+	report := func(event UpgradeEvent, after time.Duration) {
+		event.Time = start.Add(after)
+		Expect(recorder.Report(event)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		start = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+		recorder = &UpgradeSummaryRecorder{}
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-master-12345678-0", "k8s-agentpool1-12345678-vmss000001", "k8s-agentpool1-12345678-vmss000002"}, SkippedPools: []string{"gpupool"}}, 0)
+		report(UpgradeEvent{Type: NodeDeletingEvent, PoolName: MasterPoolName, NodeName: "k8s-master-12345678-0"}, time.Second)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: MasterPoolName, NodeName: "k8s-master-12345678-0"}, 5*time.Minute)
+		report(UpgradeEvent{Type: NodeDrainingEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-12345678-vmss000001"}, 6*time.Minute)
+		report(UpgradeEvent{Type: NodeUpgradeFailedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-12345678-vmss000001", Message: "drain timed out"}, 10*time.Minute)
+
+		mockClient = &armhelpers.MockAKSEngineClient{
+			FakeDeployments: []resources.DeploymentExtended{
+				deployment("k8s-upgrade-master-0-20-10-01T12.00.01-42", start.Add(time.Second)),
+				deployment("k8s-upgrade-master-0-20-09-01T12.00.01-41", start.Add(-30*24*time.Hour)),
+				deployment("cluster", start.Add(time.Second)),
+			},
+		}
+		kmn = newTestUpgradeMasterNode(mockClient)
+	})
+
+	It("Should record the outcome of each node in upgrade order", func() {
+		summary := recorder.Summary()
+		Expect(summary.StartTime).To(Equal(start))
+		Expect(summary.Nodes).To(Equal([]NodeUpgradeSummary{
+			{Name: "k8s-master-12345678-0", Pool: MasterPoolName, Phase: NodeDone, StartTime: start.Add(time.Second), EndTime: start.Add(5 * time.Minute)},
+			{Name: "k8s-agentpool1-12345678-vmss000001", Pool: "agentpool1", Phase: NodeFailed, StartTime: start.Add(6 * time.Minute), EndTime: start.Add(10 * time.Minute), Error: "drain timed out"},
+			{Name: "k8s-agentpool1-12345678-vmss000002", Phase: NodePending},
+		}))
+		Expect(summary.Errors).To(Equal([]string{"k8s-agentpool1-12345678-vmss000001: drain timed out"}))
+	})
+
+	It("Should render the timeline, nodes, deployments and errors with portal links", func() {
+		summary := recorder.Summary()
+		summary.EndTime = start.Add(11 * time.Minute)
+		summary.FromVersion, summary.ToVersion = "1.18.8", "1.18.10"
+		summary.Errors = append(summary.Errors, "upgrading agent pool agentpool1")
+
+		markdown, err := kmn.GenerateUpgradeReportMarkdown(context.Background(), summary)
+		Expect(err).NotTo(HaveOccurred())
+		portal := "https://portal.azure.com/#resource/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg"
+		Expect(markdown).To(HavePrefix("# Upgrade of resource group [TestRg](" + portal + ")\n"))
+		Expect(markdown).To(ContainSubstring("| Result | Failed |\n| Kubernetes version | 1.18.8 to 1.18.10 |\n"))
+		Expect(markdown).To(ContainSubstring("| Duration | 11m0s |\n"))
+		Expect(markdown).To(ContainSubstring("" +
+			"- 2020-10-01T12:00:00Z Upgrade started\n" +
+			"- 2020-10-01T12:00:01Z Node k8s-master-12345678-0 started upgrading\n" +
+			"- 2020-10-01T12:05:00Z Node k8s-master-12345678-0 upgraded\n" +
+			"- 2020-10-01T12:06:00Z Node k8s-agentpool1-12345678-vmss000001 started upgrading\n" +
+			"- 2020-10-01T12:10:00Z Node k8s-agentpool1-12345678-vmss000001 failed\n" +
+			"- 2020-10-01T12:11:00Z Upgrade failed\n"))
+		Expect(markdown).To(ContainSubstring("| [k8s-master-12345678-0](" + portal + "/providers/Microsoft.Compute/virtualMachines/k8s-master-12345678-0) | master | Done | 2020-10-01T12:00:01Z | 2020-10-01T12:05:00Z | 4m59s |\n"))
+		Expect(markdown).To(ContainSubstring("| [k8s-agentpool1-12345678-vmss000002](" + portal + "/providers/Microsoft.Compute/virtualMachineScaleSets/k8s-agentpool1-12345678-vmss) |  | Pending | - | - | - |\n"))
+		Expect(markdown).To(ContainSubstring("## ARM deployments\n\n- [k8s-upgrade-master-0-20-10-01T12.00.01-42](" + portal + "/providers/Microsoft.Resources/deployments/k8s-upgrade-master-0-20-10-01T12.00.01-42)\n\n"))
+		Expect(markdown).To(HaveSuffix("## Errors\n\n```\nk8s-agentpool1-12345678-vmss000001: drain timed out\n```\n```\nupgrading agent pool agentpool1\n```\n"))
+	})
+
+	It("Should list the upgraded, failed and skipped agent pools", func() {
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool2", NodeName: "k8s-agentpool2-12345678-0"}, 11*time.Minute)
+		report(UpgradeEvent{Type: NodeSkippedEvent, PoolName: "agentpool2", NodeName: "k8s-agentpool2-12345678-1"}, 11*time.Minute)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool3", NodeName: "k8s-agentpool3-12345678-0"}, 12*time.Minute)
+		report(UpgradeEvent{Type: NodeDrainingEvent, PoolName: "agentpool3", NodeName: "k8s-agentpool3-12345678-1"}, 12*time.Minute)
+		summary := recorder.Summary()
+		Expect(summary.SkippedPools).To(Equal([]string{"gpupool"}))
+
+		upgraded, failed := summary.AgentPools()
+		Expect(upgraded).To(Equal([]string{"agentpool2"}))
+		Expect(failed).To(Equal([]string{"agentpool1"}))
+
+		markdown, err := kmn.GenerateUpgradeReportMarkdown(context.Background(), summary)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(markdown).To(ContainSubstring("## Agent pools\n\n- Upgraded: agentpool2\n- Failed: agentpool1\n- Skipped: gpupool\n\n## ARM deployments"))
+
+		markdown, err = kmn.GenerateUpgradeReportMarkdown(context.Background(), UpgradeSummary{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(markdown).To(ContainSubstring("## Agent pools\n\n- Upgraded: None\n- Failed: None\n- Skipped: None\n"))
+	})
+
+	It("Should link to the portal of the cluster cloud", func() {
+		kmn.UpgradeContainerService.Location = "chinaeast2"
+		Expect(kmn.portalLink("TestRg", "")).To(Equal("[TestRg](https://portal.azure.cn/#resource/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg)"))
+
+		kmn.UpgradeContainerService.Properties.CustomCloudProfile = &api.CustomCloudProfile{
+			Environment: &azure.Environment{ManagementPortalURL: "https://portal.local.azurestack.external/"},
+		}
+		Expect(kmn.portalLink("TestRg", "")).To(Equal("[TestRg](https://portal.local.azurestack.external/#resource/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg)"))
+	})
+
+	It("Should write the report to the output directory and upload it", func() {
+		dir, err := ioutil.TempDir("", "upgrade-report")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		blobClient := &fakeTemplateBlobClient{}
+		kmn.OutputDirectory = dir
+		kmn.TemplateBlobClient = blobClient
+		kmn.UpgradeReportBlobURI = "https://account.blob.core.windows.net/reports"
+
+		markdown, err := kmn.GenerateUpgradeReportMarkdown(context.Background(), recorder.Summary())
+		Expect(err).NotTo(HaveOccurred())
+		written, err := ioutil.ReadFile(filepath.Join(dir, "upgrade-report-20201001T120000Z.md"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(written)).To(Equal(markdown))
+		Expect(blobClient.blobName).To(Equal("upgrade-report-20201001T120000Z.md"))
+		Expect(string(blobClient.template)).To(Equal(markdown))
+		Expect(blobClient.expiry).To(Equal(DefaultUpgradeReportSASExpiryDuration))
+
+		blobClient.err = errors.New("forbidden")
+		_, err = kmn.GenerateUpgradeReportMarkdown(context.Background(), recorder.Summary())
+		Expect(err).To(MatchError("uploading upgrade report: forbidden"))
+	})
+
+	It("Should still render the report when the deployments cannot be listed", func() {
+		mockClient.FailListDeployments = true
+		markdown, err := kmn.GenerateUpgradeReportMarkdown(context.Background(), UpgradeSummary{Deployments: []string{"cluster-masters"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(markdown).To(ContainSubstring("## ARM deployments\n\n- [cluster-masters]("))
+		Expect(markdown).To(ContainSubstring("## Errors\n\nNone\n"))
+	})
+})
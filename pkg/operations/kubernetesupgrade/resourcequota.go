@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// quotaResources lists the resource quota hard limits compared against the cluster allocatable capacity
+var quotaResources = map[v1.ResourceName][]v1.ResourceName{
+	v1.ResourceCPU:    {v1.ResourceCPU, v1.ResourceRequestsCPU, v1.ResourceLimitsCPU},
+	v1.ResourceMemory: {v1.ResourceMemory, v1.ResourceRequestsMemory, v1.ResourceLimitsMemory},
+}
+
+// SyncResourceQuotas checks, once the nodes of poolName were replaced by nodes of a different VM size,
+// whether the CPU or memory hard limits of any namespace ResourceQuota exceed the allocatable capacity
+// of the cluster and logs a warning for each. If AutoAdjustResourceQuotas is set, these limits are scaled
+// by the ratio between the vCPUs and memory of the new and the previous VM size.
+func (ku *Upgrader) SyncResourceQuotas(ctx context.Context, poolName string) error {
+	previousVMSize := ku.previousVMSize(poolName)
+	var vmSize string
+	for _, app := range ku.ClusterTopology.DataModel.Properties.AgentPoolProfiles {
+		if app.Name == poolName {
+			vmSize = app.VMSize
+			break
+		}
+	}
+	if previousVMSize == "" || vmSize == "" || strings.EqualFold(previousVMSize, vmSize) {
+		return nil
+	}
+
+	previous, err := ku.vmSizeResources(ctx, previousVMSize)
+	if err != nil {
+		return err
+	}
+	current, err := ku.vmSizeResources(ctx, vmSize)
+	if err != nil {
+		return err
+	}
+	scaleFactors := map[v1.ResourceName]float64{
+		v1.ResourceCPU:    current.vCPUs / previous.vCPUs,
+		v1.ResourceMemory: current.memoryGB / previous.memoryGB,
+	}
+	ku.logger.Infof("Agent pool %s VM size changed from %s to %s, checking resource quotas", poolName, previousVMSize, vmSize)
+
+	client, err := ku.getKubernetesClient(getResourceTimeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	nodes, err := client.ListNodes()
+	if err != nil {
+		return errors.Wrap(err, "listing nodes")
+	}
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.Quantity{},
+		v1.ResourceMemory: resource.Quantity{},
+	}
+	for _, node := range nodes.Items {
+		addResources(allocatable, node.Status.Allocatable)
+	}
+
+	quotas, err := client.ListResourceQuotas("")
+	if err != nil {
+		return errors.Wrap(err, "listing resource quotas")
+	}
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		overCommitted := false
+		for capacityName, names := range quotaResources {
+			capacity := allocatable[capacityName]
+			for _, name := range names {
+				hard, ok := quota.Spec.Hard[name]
+				if !ok || hard.Cmp(capacity) <= 0 {
+					continue
+				}
+				ku.logger.Warnf("Resource quota %s/%s %s limit %s exceeds the cluster allocatable %s %s",
+					quota.Namespace, quota.Name, name, hard.String(), capacityName, capacity.String())
+				overCommitted = true
+				if ku.AutoAdjustResourceQuotas {
+					quota.Spec.Hard[name] = scaleQuantity(capacityName, hard, scaleFactors[capacityName])
+				}
+			}
+		}
+		if !overCommitted || !ku.AutoAdjustResourceQuotas {
+			continue
+		}
+		ku.logger.Infof("Adjusting resource quota %s/%s to the %s VM size", quota.Namespace, quota.Name, vmSize)
+		if _, err = client.UpdateResourceQuota(quota); err != nil {
+			return errors.Wrapf(err, "updating resource quota %s/%s", quota.Namespace, quota.Name)
+		}
+	}
+	return nil
+}
+
+// previousVMSize returns the VM size of the agent pool nodes before the upgrade,
+// or an empty string if the cluster topology has no node left on it.
+func (ku *Upgrader) previousVMSize(poolName string) string {
+	for _, pool := range ku.ClusterTopology.AgentPools {
+		if pool.Name == nil || *pool.Name != poolName || pool.AgentVMs == nil {
+			continue
+		}
+		for _, vm := range *pool.AgentVMs {
+			if vm.VirtualMachineProperties != nil && vm.HardwareProfile != nil {
+				return string(vm.HardwareProfile.VMSize)
+			}
+		}
+	}
+	for _, vmss := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
+		if vmss.poolName() == poolName {
+			return to.String(vmss.Sku.Name)
+		}
+	}
+	return ""
+}
+
+type vmSizeResources struct {
+	vCPUs    float64
+	memoryGB float64
+}
+
+// vmSizeResources returns the vCPUs and memory capabilities of a VM size in the cluster location
+func (ku *Upgrader) vmSizeResources(ctx context.Context, vmSize string) (vmSizeResources, error) {
+	filter := fmt.Sprintf("location eq '%s'", ku.ClusterTopology.Location)
+	page, err := ku.Client.ListResourceSkus(ctx, filter)
+	if err != nil {
+		return vmSizeResources{}, errors.Wrap(err, "listing resource SKUs")
+	}
+	for page != nil && page.NotDone() {
+		for _, sku := range page.Values() {
+			if !strings.EqualFold(to.String(sku.Name), vmSize) || sku.Capabilities == nil {
+				continue
+			}
+			var r vmSizeResources
+			for _, c := range *sku.Capabilities {
+				value, err := strconv.ParseFloat(to.String(c.Value), 64)
+				if err != nil {
+					continue
+				}
+				switch to.String(c.Name) {
+				case "vCPUs":
+					r.vCPUs = value
+				case "MemoryGB":
+					r.memoryGB = value
+				}
+			}
+			if r.vCPUs > 0 && r.memoryGB > 0 {
+				return r, nil
+			}
+		}
+		if err = page.NextWithContext(ctx); err != nil {
+			return vmSizeResources{}, errors.Wrap(err, "listing resource SKUs")
+		}
+	}
+	return vmSizeResources{}, errors.Errorf("could not find the vCPUs and memory of VM size %s in location %s", vmSize, ku.ClusterTopology.Location)
+}
+
+// scaleQuantity multiplies a quantity by factor, rounding CPU to millicores and memory to bytes
+func scaleQuantity(name v1.ResourceName, q resource.Quantity, factor float64) resource.Quantity {
+	if name == v1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(float64(q.MilliValue())*factor), q.Format)
+	}
+	return *resource.NewQuantity(int64(float64(q.Value())*factor), q.Format)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newTestResourceSku(name, vCPUs, memoryGB string) compute.ResourceSku {
+	return compute.ResourceSku{
+		Name: to.StringPtr(name),
+		Capabilities: &[]compute.ResourceSkuCapabilities{
+			{Name: to.StringPtr("vCPUs"), Value: to.StringPtr(vCPUs)},
+			{Name: to.StringPtr("MemoryGB"), Value: to.StringPtr(memoryGB)},
+		},
+	}
+}
+
+func newTestResourceQuota(namespace, cpu, memory string) v1.ResourceQuota {
+	quota := v1.ResourceQuota{}
+	quota.Name = "compute"
+	quota.Namespace = namespace
+	quota.Spec.Hard = v1.ResourceList{
+		v1.ResourceRequestsCPU:    resource.MustParse(cpu),
+		v1.ResourceRequestsMemory: resource.MustParse(memory),
+	}
+	return quota
+}
+
+func quotaHard(hard v1.ResourceList, name v1.ResourceName) string {
+	q := hard[name]
+	return q.String()
+}
+
+var _ = Describe("Resource quota sync tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kubeClient *armhelpers.MockKubernetesClient
+		u          *Upgrader
+	)
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			NodesList: &v1.NodeList{Items: []v1.Node{
+				newTestCapacityNode("k8s-agentpool1-12345678-0", "2", "7Gi"),
+				newTestCapacityNode("k8s-agentpool1-12345678-1", "2", "7Gi"),
+			}},
+			ResourceQuotaList: &v1.ResourceQuotaList{Items: []v1.ResourceQuota{
+				newTestResourceQuota("team-a", "8", "28Gi"),
+				newTestResourceQuota("team-b", "2", "4Gi"),
+			}},
+		}
+		mockClient = &armhelpers.MockAKSEngineClient{
+			MockKubernetesClient: kubeClient,
+			FakeListResourceSkusResult: func() []compute.ResourceSku {
+				return []compute.ResourceSku{
+					newTestResourceSku("Standard_D4_v2", "8", "28"),
+					newTestResourceSku("Standard_D2_v2", "2", "7"),
+				}
+			},
+		}
+		cs := newTestCRDUpgrader("1.18.8", kubeClient).DataModel
+		u = &Upgrader{}
+		u.Init(&i18n.Translator{}, log.NewEntry(log.New()), ClusterTopology{
+			DataModel: cs,
+			Location:  "westus2",
+			AgentPools: map[string]*AgentPoolTopology{
+				"agentpool1": {
+					Name: to.StringPtr("agentpool1"),
+					AgentVMs: &[]compute.VirtualMachine{{
+						Name: to.StringPtr("k8s-agentpool1-12345678-0"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							HardwareProfile: &compute.HardwareProfile{VMSize: compute.VirtualMachineSizeTypesStandardD4V2},
+						},
+					}},
+				},
+			},
+		}, mockClient, "", nil, nil, TestAKSEngineVersion, false)
+	})
+
+	It("Should only warn about the over-committed quotas by default", func() {
+		Expect(u.SyncResourceQuotas(context.Background(), "agentpool1")).To(Succeed())
+
+		hard := kubeClient.ResourceQuotaList.Items[0].Spec.Hard
+		Expect(quotaHard(hard, v1.ResourceRequestsCPU)).To(Equal("8"))
+		Expect(quotaHard(hard, v1.ResourceRequestsMemory)).To(Equal("28Gi"))
+	})
+
+	It("Should scale the over-committed quotas when AutoAdjustResourceQuotas is set", func() {
+		u.AutoAdjustResourceQuotas = true
+		Expect(u.SyncResourceQuotas(context.Background(), "agentpool1")).To(Succeed())
+
+		hard := kubeClient.ResourceQuotaList.Items[0].Spec.Hard
+		Expect(quotaHard(hard, v1.ResourceRequestsCPU)).To(Equal("2"))
+		Expect(quotaHard(hard, v1.ResourceRequestsMemory)).To(Equal("7Gi"))
+		hard = kubeClient.ResourceQuotaList.Items[1].Spec.Hard
+		Expect(quotaHard(hard, v1.ResourceRequestsCPU)).To(Equal("2"))
+		Expect(quotaHard(hard, v1.ResourceRequestsMemory)).To(Equal("4Gi"))
+	})
+
+	It("Should do nothing when the VM size did not change", func() {
+		u.AutoAdjustResourceQuotas = true
+		u.DataModel.Properties.AgentPoolProfiles[0].VMSize = "Standard_D4_v2"
+		mockClient.FailListResourceSkus = true
+		kubeClient.FailListResourceQuotas = true
+
+		Expect(u.SyncResourceQuotas(context.Background(), "agentpool1")).To(Succeed())
+	})
+
+	It("Should return an error when a VM size is unknown", func() {
+		u.DataModel.Properties.AgentPoolProfiles[0].VMSize = "Standard_D8_v3"
+
+		err := u.SyncResourceQuotas(context.Background(), "agentpool1")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("could not find the vCPUs and memory of VM size Standard_D8_v3 in location westus2"))
+	})
+
+	It("Should return an error when a quota cannot be updated", func() {
+		u.AutoAdjustResourceQuotas = true
+		kubeClient.FailUpdateResourceQuota = true
+
+		err := u.SyncResourceQuotas(context.Background(), "agentpool1")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("updating resource quota team-a/compute"))
+	})
+})
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// ReadExistingResourceTags returns the Azure tags of the VM, e.g. to use them as the ResourceTags
+// of the VM replacing it.
+func (kmn *UpgradeMasterNode) ReadExistingResourceTags(ctx context.Context, vmName string) (map[string]string, error) {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting VM %s", vmName)
+	}
+	tags := map[string]string{}
+	for key, value := range vm.Tags {
+		if value != nil {
+			tags[key] = *value
+		}
+	}
+	return tags, nil
+}
+
+// addResourceTags sets ResourceTags on the master resources of the template. The tags the template already sets,
+// e.g. the orchestrator version, are kept, so that tags read from an old VM do not override them.
+func (kmn *UpgradeMasterNode) addResourceTags() {
+	for _, resourceType := range []string{vmResourceType, nicResourceType, diskResourceType} {
+		for _, resource := range masterResources(kmn.TemplateMap, resourceType) {
+			tags, ok := resource["tags"].(map[string]interface{})
+			if !ok {
+				tags = map[string]interface{}{}
+				resource["tags"] = tags
+			}
+			for key, value := range kmn.ResourceTags {
+				if _, ok := tags[key]; !ok {
+					tags[key] = value
+				}
+			}
+		}
+	}
+}
+
+// tagOSDisk sets ResourceTags on the managed OS disk of the VM, which ARM creates along
+// with the VM without the tags of the template.
+func (kmn *UpgradeMasterNode) tagOSDisk(ctx context.Context, vmName string) error {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return errors.Wrapf(err, "getting VM %s", vmName)
+	}
+	if vm.VirtualMachineProperties == nil || vm.StorageProfile == nil || vm.StorageProfile.OsDisk == nil ||
+		vm.StorageProfile.OsDisk.ManagedDisk == nil || vm.StorageProfile.OsDisk.Name == nil {
+		// unmanaged disks have no tags
+		return nil
+	}
+	diskName := *vm.StorageProfile.OsDisk.Name
+	tags := map[string]*string{}
+	for key, value := range kmn.ResourceTags {
+		tags[key] = to.StringPtr(value)
+	}
+	kmn.logger.Infof("Tagging OS disk %s of VM %s", diskName, vmName)
+	if err := kmn.Client.UpdateManagedDiskTags(ctx, kmn.ResourceGroup, diskName, tags); err != nil {
+		return errors.Wrapf(err, "tagging OS disk %s of VM %s", diskName, vmName)
+	}
+	return nil
+}
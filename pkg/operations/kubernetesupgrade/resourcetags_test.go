@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource tags tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kmn        *UpgradeMasterNode
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		kmn.ResourceTags = map[string]string{"costCenter": "1234", "orchestrator": "Kubernetes:1.16.9"}
+	})
+
+	It("Should tag the master VM and NIC resources only", func() {
+		vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+		vm["tags"] = map[string]interface{}{"orchestrator": "[variables('orchestratorNameVersionTag')]"}
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(vm["tags"]).To(Equal(map[string]interface{}{
+			"costCenter":   "1234",
+			"orchestrator": "[variables('orchestratorNameVersionTag')]",
+		}))
+		Expect(masterResources(kmn.TemplateMap, nicResourceType)[0]["tags"]).To(Equal(map[string]interface{}{
+			"costCenter":   "1234",
+			"orchestrator": "Kubernetes:1.16.9",
+		}))
+		agentVM := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+		Expect(agentVM).NotTo(HaveKey("tags"))
+	})
+
+	It("Should tag the managed OS disk of the new VM", func() {
+		mockClient.FakeGetVirtualMachineOSDisk = &compute.OSDisk{
+			Name:        to.StringPtr("k8s-master-12345678-0_OsDisk_1"),
+			ManagedDisk: &compute.ManagedDiskParameters{},
+		}
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(mockClient.UpdatedManagedDiskTags).To(Equal(map[string]map[string]*string{
+			"k8s-master-12345678-0_OsDisk_1": {
+				"costCenter":   to.StringPtr("1234"),
+				"orchestrator": to.StringPtr("Kubernetes:1.16.9"),
+			},
+		}))
+	})
+
+	It("Should not tag an unmanaged OS disk", func() {
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(mockClient.UpdatedManagedDiskTags).To(BeEmpty())
+	})
+
+	It("Should fail when the OS disk cannot be tagged", func() {
+		mockClient.FakeGetVirtualMachineOSDisk = &compute.OSDisk{
+			Name:        to.StringPtr("k8s-master-12345678-0_OsDisk_1"),
+			ManagedDisk: &compute.ManagedDiskParameters{},
+		}
+		mockClient.FailUpdateManagedDiskTags = true
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("tagging OS disk k8s-master-12345678-0_OsDisk_1"))
+	})
+
+	It("Should leave the template untouched when not set", func() {
+		kmn.ResourceTags = nil
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(masterResources(kmn.TemplateMap, vmResourceType)[0]).NotTo(HaveKey("tags"))
+		Expect(mockClient.UpdatedManagedDiskTags).To(BeEmpty())
+	})
+
+	It("Should read the tags of an existing VM", func() {
+		tags, err := kmn.ReadExistingResourceTags(context.Background(), "k8s-master-12345678-0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(HaveKeyWithValue("poolName", "agentpool1"))
+		Expect(tags).To(HaveKeyWithValue("resourceNameSuffix", "12345678"))
+
+		mockClient.FailGetVirtualMachine = true
+		_, err = kmn.ReadExistingResourceTags(context.Background(), "k8s-master-12345678-0")
+		Expect(err).To(HaveOccurred())
+	})
+})
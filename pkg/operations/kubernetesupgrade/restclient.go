@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+)
+
+// sendJSON sends payload as JSON to url, or no body if payload is nil, authorizing the request when an authorizer is provided.
+// Responses outside of the 2xx range are returned as errors.
+func sendJSON(client *http.Client, authorizer autorest.Authorizer, method, url string, payload interface{}) error {
+	return doJSON(client, authorizer, method, url, payload, nil)
+}
+
+// doJSON sends payload as sendJSON does and decodes the JSON response body into result, unless result is nil.
+func doJSON(client *http.Client, authorizer autorest.Authorizer, method, url string, payload, result interface{}) error {
+	var body io.Reader = http.NoBody
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorizer != nil {
+		if req, err = autorest.Prepare(req, authorizer.WithAuthorization()); err != nil {
+			return err
+		}
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return &statusError{StatusCode: resp.StatusCode, Host: req.URL.Host, Message: string(msg)}
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return errors.Wrapf(err, "decoding the response from %s", req.URL.Host)
+		}
+	}
+	return nil
+}
+
+// statusError is the error of a response outside of the 2xx range
+type statusError struct {
+	StatusCode int
+	Host       string
+	Message    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s: %s", e.StatusCode, e.Host, e.Message)
+}
+
+// isStatusNotFound returns true if err is the error of a 404 Not Found response
+func isStatusNotFound(err error) bool {
+	e, ok := errors.Cause(err).(*statusError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
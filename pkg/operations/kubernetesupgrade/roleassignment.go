@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2015-07-01/authorization"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// roleDefinitionsPath is part of the resource ID of every role definition
+const roleDefinitionsPath = "/providers/microsoft.authorization/roledefinitions/"
+
+// roleAssignmentRetries and roleAssignmentRetryInterval bound the retries of the role assignments of a new
+// master VM, its identity may take a while to replicate in Azure Active Directory
+var (
+	roleAssignmentRetries       = 10
+	roleAssignmentRetryInterval = 10 * time.Second
+)
+
+// RoleAssignment is an Azure RBAC role assigned to the identity of the new master VMs
+type RoleAssignment struct {
+	// RoleDefinitionID is the resource ID of the role definition, e.g.
+	// /subscriptions/<id>/providers/Microsoft.Authorization/roleDefinitions/b24988ac-6180-42a0-ab88-20f7382dd24c
+	RoleDefinitionID string
+	// Scope is the resource ID the role is assigned at, e.g. /subscriptions/<id>/resourceGroups/<name>
+	Scope string
+}
+
+// validateRoleAssignments ensures each role assignment has a role definition ID and a scope
+func (kmn *UpgradeMasterNode) validateRoleAssignments() error {
+	for _, a := range kmn.RoleAssignments {
+		if !strings.Contains(strings.ToLower(a.RoleDefinitionID), roleDefinitionsPath) {
+			return errors.Errorf("%q is not the resource ID of a role definition", a.RoleDefinitionID)
+		}
+		if !strings.HasPrefix(a.Scope, "/") {
+			return errors.Errorf("invalid scope %q of the assignment of role %s, expected a resource ID", a.Scope, a.RoleDefinitionID)
+		}
+	}
+	return nil
+}
+
+// AssignRoles assigns the roles to the system-assigned identity of the master VM vmName
+func (kmn *UpgradeMasterNode) AssignRoles(ctx context.Context, vmName string, assignments []RoleAssignment) error {
+	principalID, err := kmn.vmPrincipalID(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		parameters := authorization.RoleAssignmentCreateParameters{
+			Properties: &authorization.RoleAssignmentProperties{
+				RoleDefinitionID: to.StringPtr(a.RoleDefinitionID),
+				PrincipalID:      to.StringPtr(principalID),
+			},
+		}
+		name := uuid.Must(uuid.NewRandom()).String()
+		for attempt := 1; ; attempt++ {
+			_, err = kmn.Client.CreateRoleAssignment(ctx, a.Scope, name, parameters)
+			if err == nil || strings.Contains(err.Error(), "RoleAssignmentExists") {
+				break
+			}
+			// the identity of a new VM is not known to the authorization API right away
+			if !strings.Contains(err.Error(), "PrincipalNotFound") || attempt >= roleAssignmentRetries {
+				return errors.Wrapf(err, "assigning role %s at scope %s to VM %s", a.RoleDefinitionID, a.Scope, vmName)
+			}
+			kmn.logger.Infof("Identity of VM %s not found yet, retrying the assignment of role %s in %v", vmName, a.RoleDefinitionID, roleAssignmentRetryInterval)
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(ctx.Err(), "assigning role %s at scope %s to VM %s", a.RoleDefinitionID, a.Scope, vmName)
+			case <-time.After(roleAssignmentRetryInterval):
+			}
+		}
+		kmn.logger.Infof("Assigned role %s at scope %s to VM %s", a.RoleDefinitionID, a.Scope, vmName)
+	}
+	return nil
+}
+
+// VerifyRoleAssignments checks that the roles are assigned to the system-assigned identity of the master VM vmName
+func (kmn *UpgradeMasterNode) VerifyRoleAssignments(ctx context.Context, vmName string, assignments []RoleAssignment) error {
+	principalID, err := kmn.vmPrincipalID(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		found := false
+		for page, err := kmn.Client.ListRoleAssignmentsForPrincipal(ctx, a.Scope, principalID); page.NotDone(); err = page.Next() {
+			if err != nil {
+				return errors.Wrapf(err, "listing the role assignments of VM %s at scope %s", vmName, a.Scope)
+			}
+			for _, existing := range page.Values() {
+				if existing.Properties != nil && strings.EqualFold(to.String(existing.Properties.RoleDefinitionID), a.RoleDefinitionID) &&
+					strings.EqualFold(to.String(existing.Properties.Scope), a.Scope) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return errors.Errorf("role %s is not assigned to VM %s at scope %s", a.RoleDefinitionID, vmName, a.Scope)
+		}
+	}
+	return nil
+}
+
+// vmPrincipalID returns the principal ID of the system-assigned identity of the VM vmName
+func (kmn *UpgradeMasterNode) vmPrincipalID(ctx context.Context, vmName string) (string, error) {
+	vm, err := kmn.Client.GetVirtualMachine(ctx, kmn.ResourceGroup, vmName)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting VM %s", vmName)
+	}
+	if vm.Identity == nil || to.String(vm.Identity.PrincipalID) == "" {
+		return "", errors.Errorf("VM %s has no system-assigned identity to assign roles to", vmName)
+	}
+	return *vm.Identity.PrincipalID, nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// minContainerRuntimeVersions lists, for each container runtime, the oldest version validated
+// with the Kubernetes versions from kubernetesVersion on
+var minContainerRuntimeVersions = map[string][]struct {
+	kubernetesVersion string
+	minVersion        string
+}{
+	api.Containerd: {
+		{kubernetesVersion: "1.16.0", minVersion: "1.3.0"},
+		{kubernetesVersion: "1.20.0", minVersion: "1.4.0"},
+	},
+	api.Docker: {
+		{kubernetesVersion: "1.16.0", minVersion: "18.9.0"},
+		{kubernetesVersion: "1.19.0", minVersion: "19.3.0"},
+	},
+}
+
+// RuntimeVersionError is returned when the container runtime of an upgraded node
+// is older than the one required by the target Kubernetes version
+type RuntimeVersionError struct {
+	NodeName          string
+	Runtime           string
+	Version           string
+	MinVersion        string
+	KubernetesVersion string
+}
+
+func (e *RuntimeVersionError) Error() string {
+	return fmt.Sprintf("node %s runs %s %s, Kubernetes %s requires %s %s or later",
+		e.NodeName, e.Runtime, e.Version, e.KubernetesVersion, e.Runtime, e.MinVersion)
+}
+
+// VerifyContainerRuntime checks that the kubelet of nodeName reports a running container runtime, the one
+// configured in the api model, and that its version is compatible with the target Kubernetes version.
+// It returns a RuntimeVersionError if the runtime is too old.
+func (kan *UpgradeAgentNode) VerifyContainerRuntime(ctx context.Context, nodeName string) error {
+	apiserverURL := kan.UpgradeContainerService.Properties.MasterProfile.FQDN
+	client, err := kan.Client.GetKubernetesClient(apiserverURL, kan.kubeConfig, interval, kan.timeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+
+	// the kubelet reports the runtime version once it reached the runtime through the CRI
+	var runtimeVersion string
+	for runtimeVersion == "" {
+		node, err := client.GetNode(nodeName)
+		if err != nil {
+			kan.logger.Infof("Agent node: %s status error: %v", nodeName, err)
+		} else {
+			runtimeVersion = node.Status.NodeInfo.ContainerRuntimeVersion
+		}
+		if runtimeVersion != "" {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("node %s did not report a container runtime version: %v", nodeName, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+
+	// the version is reported as <runtime>://<version>, e.g. containerd://1.4.4+azure
+	parts := strings.SplitN(runtimeVersion, "://", 2)
+	if len(parts) != 2 {
+		return errors.Errorf("node %s reported an unexpected container runtime version %q", nodeName, runtimeVersion)
+	}
+	runtime, version := parts[0], parts[1]
+
+	kubernetesConfig := kan.UpgradeContainerService.Properties.OrchestratorProfile.KubernetesConfig
+	if kubernetesConfig != nil && kubernetesConfig.ContainerRuntime != "" && kubernetesConfig.ContainerRuntime != runtime {
+		return errors.Errorf("node %s runs container runtime %s, %s is configured", nodeName, runtime, kubernetesConfig.ContainerRuntime)
+	}
+
+	kubernetesVersion := kan.UpgradeContainerService.Properties.OrchestratorProfile.OrchestratorVersion
+	var minVersion string
+	for _, v := range minContainerRuntimeVersions[runtime] {
+		if common.IsKubernetesVersionGe(kubernetesVersion, v.kubernetesVersion) {
+			minVersion = v.minVersion
+		}
+	}
+	if minVersion == "" {
+		kan.logger.Infof("Node %s runs %s %s", nodeName, runtime, version)
+		return nil
+	}
+	current, err := semver.ParseTolerant(version)
+	if err != nil {
+		return errors.Wrapf(err, "parsing the %s version %q of node %s", runtime, version, nodeName)
+	}
+	if current.LT(semver.MustParse(minVersion)) {
+		return &RuntimeVersionError{
+			NodeName:          nodeName,
+			Runtime:           runtime,
+			Version:           version,
+			MinVersion:        minVersion,
+			KubernetesVersion: kubernetesVersion,
+		}
+	}
+	kan.logger.Infof("Node %s runs %s %s", nodeName, runtime, version)
+	return nil
+}
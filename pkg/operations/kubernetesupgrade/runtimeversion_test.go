@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("VerifyContainerRuntime", func() {
+	var (
+		kan            *UpgradeAgentNode
+		k8sClient      *armhelpers.MockKubernetesClient
+		runtimeVersion string
+	)
+
+	BeforeEach(func() {
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.UpgradeContainerService.Properties.OrchestratorProfile.OrchestratorVersion = "1.20.5"
+		runtimeVersion = "docker://19.3.14"
+		k8sClient = &armhelpers.MockKubernetesClient{
+			GetNodeFunc: func(name string) (*v1.Node, error) {
+				node := &v1.Node{}
+				node.Name = name
+				node.Status.NodeInfo.ContainerRuntimeVersion = runtimeVersion
+				return node, nil
+			},
+		}
+		kan.Client = &armhelpers.MockAKSEngineClient{MockKubernetesClient: k8sClient}
+	})
+
+	It("should accept a runtime compatible with the target Kubernetes version", func() {
+		Expect(kan.VerifyContainerRuntime(context.Background(), "k8s-agentpool1-12345678-0")).To(Succeed())
+	})
+
+	It("should return a RuntimeVersionError for a runtime older than required", func() {
+		runtimeVersion = "docker://18.9.1"
+		err := kan.VerifyContainerRuntime(context.Background(), "k8s-agentpool1-12345678-0")
+		Expect(err).To(HaveOccurred())
+		versionErr, ok := err.(*RuntimeVersionError)
+		Expect(ok).To(BeTrue())
+		Expect(versionErr.Runtime).To(Equal(api.Docker))
+		Expect(versionErr.MinVersion).To(Equal("19.3.0"))
+		Expect(err.Error()).To(Equal("node k8s-agentpool1-12345678-0 runs docker 18.9.1, Kubernetes 1.20.5 requires docker 19.3.0 or later"))
+	})
+
+	It("should parse containerd versions with a build suffix", func() {
+		kan.UpgradeContainerService.Properties.OrchestratorProfile.KubernetesConfig.ContainerRuntime = api.Containerd
+		runtimeVersion = "containerd://1.4.4+azure"
+		Expect(kan.VerifyContainerRuntime(context.Background(), "k8s-agentpool1-12345678-0")).To(Succeed())
+
+		runtimeVersion = "containerd://1.3.9+azure"
+		_, ok := kan.VerifyContainerRuntime(context.Background(), "k8s-agentpool1-12345678-0").(*RuntimeVersionError)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should fail when the node runs another runtime than configured", func() {
+		runtimeVersion = "containerd://1.4.4"
+		err := kan.VerifyContainerRuntime(context.Background(), "k8s-agentpool1-12345678-0")
+		Expect(err).To(MatchError("node k8s-agentpool1-12345678-0 runs container runtime containerd, docker is configured"))
+	})
+
+	It("should fail when the node does not report a runtime version in time", func() {
+		runtimeVersion = ""
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := kan.VerifyContainerRuntime(ctx, "k8s-agentpool1-12345678-0")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("did not report a container runtime version"))
+	})
+
+	It("should fail when the Kubernetes client cannot be created", func() {
+		kan.Client = &armhelpers.MockAKSEngineClient{FailGetKubernetesClient: true}
+		Expect(kan.VerifyContainerRuntime(context.Background(), "k8s-agentpool1-12345678-0")).To(HaveOccurred())
+	})
+})
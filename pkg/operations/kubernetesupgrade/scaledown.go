@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// checkPodDisruptionBudgets ensures deleting the nodes without draining them takes down no more pods
+// of each pod disruption budget than it currently allows
+func checkPodDisruptionBudgets(client kubernetes.Client, nodeNames []string) error {
+	nodes := map[string]bool{}
+	for _, name := range nodeNames {
+		nodes[strings.ToLower(name)] = true
+	}
+	pods, err := client.ListAllPods()
+	if err != nil {
+		return errors.Wrap(err, "listing pods")
+	}
+	pdbs, err := client.ListPodDisruptionBudgets(metav1.NamespaceAll)
+	if err != nil {
+		return errors.Wrap(err, "listing pod disruption budgets")
+	}
+	for _, pdb := range pdbs.Items {
+		// an empty policy/v1beta1 selector matches no pod
+		if pdb.Spec.Selector == nil || (len(pdb.Spec.Selector.MatchLabels) == 0 && len(pdb.Spec.Selector.MatchExpressions) == 0) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			return errors.Wrapf(err, "parsing the selector of pod disruption budget %s/%s", pdb.Namespace, pdb.Name)
+		}
+		disrupted := 0
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Namespace != pdb.Namespace || !nodes[pod.Spec.NodeName] ||
+				pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			if selector.Matches(labels.Set(pod.Labels)) {
+				disrupted++
+			}
+		}
+		if disrupted > int(pdb.Status.PodDisruptionsAllowed) {
+			return errors.Errorf("deleting nodes %s without draining them would disrupt %d pods of pod disruption budget %s/%s, which allows %d",
+				strings.Join(nodeNames, ", "), disrupted, pdb.Namespace, pdb.Name, pdb.Status.PodDisruptionsAllowed)
+		}
+	}
+	return nil
+}
+
+// scaleDownAgentPool deletes the first PoolUpgradeConfig.ValidationWorkers nodes to upgrade of the availability set
+// agent pool without draining them, once checkPodDisruptionBudgets allows it, and returns how many were deleted.
+// The upgrade then creates them again on the upgraded version in place of the extra node.
+func (ku *Upgrader) scaleDownAgentPool(client kubernetes.Client, upgradeAgentNode *UpgradeAgentNode, poolName string, vms map[int]*vmInfo) (int, error) {
+	count := ku.poolUpgradeConfig(poolName).ValidationWorkers
+	if count < 1 {
+		count = 1
+	}
+	var indexes []int
+	var names []string
+	for _, index := range ku.agentVMUpgradeOrder(client, poolName, vms) {
+		if len(indexes) == count {
+			break
+		}
+		if vms[index].status == vmStatusNotUpgraded {
+			indexes = append(indexes, index)
+			names = append(names, vms[index].name)
+		}
+	}
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+	if err := checkPodDisruptionBudgets(client, names); err != nil {
+		return 0, errors.Wrapf(err, "scaling down agent pool %s", poolName)
+	}
+	ku.logger.Infof("Scaling agent pool %s down by %d nodes before the upgrade: %s", poolName, len(names), strings.Join(names, ", "))
+	for i, index := range indexes {
+		ku.reportNodePhase(NodeDeletingEvent, poolName, names[i])
+		if err := upgradeAgentNode.DeleteNode(&names[i], false); err != nil {
+			return i, errors.Wrapf(err, "scaling down agent pool %s", poolName)
+		}
+		delete(vms, index)
+	}
+	return len(indexes), nil
+}
+
+// drainBeforeDelete reports whether the node is drained before it is deleted. The nodes of the pools skipping
+// the drain are not, nor with ScaleDownBeforeUpgrade are the nodes passing checkPodDisruptionBudgets.
+func (ku *Upgrader) drainBeforeDelete(client kubernetes.Client, poolName, nodeName string) (bool, error) {
+	if ku.poolUpgradeConfig(poolName).SkipDrain {
+		return false, nil
+	}
+	if !ku.ScaleDownBeforeUpgrade {
+		return true, nil
+	}
+	if err := checkPodDisruptionBudgets(client, []string{nodeName}); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Scale down before upgrade tests", func() {
+	var (
+		k8sClient *armhelpers.MockKubernetesClient
+		u         *Upgrader
+		vms       map[int]*vmInfo
+	)
+
+	newPod := func(namespace, nodeName string, labels map[string]string) v1.Pod {
+		pod := v1.Pod{}
+		pod.Namespace = namespace
+		pod.Labels = labels
+		pod.Spec.NodeName = nodeName
+		pod.Status.Phase = v1.PodRunning
+		return pod
+	}
+
+	newPDB := func(namespace, name string, selector *metav1.LabelSelector, allowed int32) policy.PodDisruptionBudget {
+		pdb := policy.PodDisruptionBudget{}
+		pdb.Namespace = namespace
+		pdb.Name = name
+		pdb.Spec.Selector = selector
+		pdb.Status.PodDisruptionsAllowed = allowed
+		return pdb
+	}
+
+	BeforeEach(func() {
+		k8sClient = &armhelpers.MockKubernetesClient{
+			PodsList: &v1.PodList{Items: []v1.Pod{
+				newPod("default", "k8s-agentpool1-12345678-0", map[string]string{"app": "web"}),
+				newPod("default", "k8s-agentpool1-12345678-1", map[string]string{"app": "web"}),
+				newPod("other", "k8s-agentpool1-12345678-1", map[string]string{"app": "web"}),
+				newPod("default", "k8s-agentpool1-12345678-2", map[string]string{"app": "db"}),
+			}},
+			PodDisruptionBudgetList: &policy.PodDisruptionBudgetList{Items: []policy.PodDisruptionBudget{
+				newPDB("default", "web", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}, 1),
+				newPDB("default", "db", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}, 0),
+				newPDB("default", "none", &metav1.LabelSelector{}, 0),
+			}},
+		}
+		u = newTestCRDUpgrader("1.18.8", k8sClient)
+		vms = map[int]*vmInfo{
+			0: {"k8s-agentpool1-12345678-0", vmStatusNotUpgraded},
+			1: {"k8s-agentpool1-12345678-1", vmStatusNotUpgraded},
+			2: {"k8s-agentpool1-12345678-2", vmStatusNotUpgraded},
+			3: {"k8s-agentpool1-12345678-3", vmStatusUpgraded},
+		}
+	})
+
+	It("Should allow deleting the nodes within the pod disruption budgets", func() {
+		Expect(checkPodDisruptionBudgets(k8sClient, []string{"K8S-AGENTPOOL1-12345678-0"})).To(Succeed())
+		Expect(checkPodDisruptionBudgets(k8sClient, []string{"k8s-agentpool1-12345678-1", "k8s-agentpool1-12345678-3"})).To(Succeed())
+	})
+
+	It("Should refuse deleting the nodes beyond the pod disruption budgets", func() {
+		err := checkPodDisruptionBudgets(k8sClient, []string{"k8s-agentpool1-12345678-0", "k8s-agentpool1-12345678-1"})
+		Expect(err).To(MatchError("deleting nodes k8s-agentpool1-12345678-0, k8s-agentpool1-12345678-1 without draining them would disrupt 2 pods of pod disruption budget default/web, which allows 1"))
+
+		err = checkPodDisruptionBudgets(k8sClient, []string{"k8s-agentpool1-12345678-2"})
+		Expect(err).To(MatchError("deleting nodes k8s-agentpool1-12345678-2 without draining them would disrupt 1 pods of pod disruption budget default/db, which allows 0"))
+
+		k8sClient.FailListPodDisruptionBudgets = true
+		Expect(checkPodDisruptionBudgets(k8sClient, nil)).To(MatchError("listing pod disruption budgets: ListPodDisruptionBudgets failed"))
+	})
+
+	It("Should delete the first ValidationWorkers nodes to upgrade of the pool", func() {
+		u.PoolUpgradeConfigs = map[string]PoolUpgradeConfig{"agentpool1": {ValidationWorkers: 2}}
+		node := newTestUpgradeAgentNode("Standard_D2s_v3")
+		node.Client = u.Client
+
+		k8sClient.PodDisruptionBudgetList.Items[0].Status.PodDisruptionsAllowed = 2
+		count, err := u.scaleDownAgentPool(k8sClient, node, "agentpool1", vms)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(2))
+		Expect(vms).NotTo(HaveKey(0))
+		Expect(vms).NotTo(HaveKey(1))
+		Expect(vms).To(HaveKey(2))
+		Expect(vms).To(HaveKey(3))
+	})
+
+	It("Should not scale down the pool beyond the pod disruption budgets", func() {
+		u.PoolUpgradeConfigs = map[string]PoolUpgradeConfig{"agentpool1": {ValidationWorkers: 2}}
+		node := newTestUpgradeAgentNode("Standard_D2s_v3")
+		node.Client = u.Client
+
+		_, err := u.scaleDownAgentPool(k8sClient, node, "agentpool1", vms)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("scaling down agent pool agentpool1: deleting nodes k8s-agentpool1-12345678-0, k8s-agentpool1-12345678-1 without draining them"))
+		Expect(vms).To(HaveLen(4))
+	})
+
+	It("Should delete the nodes without draining them within the pod disruption budgets", func() {
+		drain, err := u.drainBeforeDelete(k8sClient, "agentpool1", "k8s-agentpool1-12345678-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drain).To(BeTrue())
+
+		u.ScaleDownBeforeUpgrade = true
+		drain, err = u.drainBeforeDelete(k8sClient, "agentpool1", "k8s-agentpool1-12345678-0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drain).To(BeFalse())
+		_, err = u.drainBeforeDelete(k8sClient, "agentpool1", "k8s-agentpool1-12345678-2")
+		Expect(err).To(HaveOccurred())
+
+		u.PoolUpgradeConfigs = map[string]PoolUpgradeConfig{"agentpool1": {SkipDrain: true}}
+		drain, err = u.drainBeforeDelete(k8sClient, "agentpool1", "k8s-agentpool1-12345678-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drain).To(BeFalse())
+	})
+})
@@ -0,0 +1,174 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/Azure/aks-engine/pkg/operations"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/pkg/errors"
+)
+
+// createScaleSetNode brings InstanceIDs up to the scale set model, which the upgrade template deployed by
+// upgradeAgentScaleSets updated with the new image reference and Kubernetes version
+func (kan *UpgradeAgentNode) createScaleSetNode(ctx context.Context) error {
+	if len(kan.InstanceIDs) == 0 {
+		return errors.Errorf("Error upgrading VMSS %s: no instance IDs to upgrade", kan.ScaleSetName)
+	}
+	return kan.UpdateVMSSInstances(ctx, kan.ScaleSetName, kan.InstanceIDs)
+}
+
+// UpdateVMSSInstances upgrades the given instances of a VMSS to its latest model
+func (kan *UpgradeAgentNode) UpdateVMSSInstances(ctx context.Context, scaleSetName string, instanceIDs []string) error {
+	kan.logger.Infof("Updating instances %s of VMSS %s to the latest model", strings.Join(instanceIDs, ", "), scaleSetName)
+	ids := compute.VirtualMachineScaleSetVMInstanceRequiredIDs{InstanceIds: &instanceIDs}
+	if err := kan.Client.UpdateVirtualMachineScaleSetVMs(ctx, kan.ResourceGroup, scaleSetName, ids); err != nil {
+		return errors.Wrapf(err, "updating instances of VMSS %s", scaleSetName)
+	}
+	return nil
+}
+
+// deleteScaleSetVM deallocates and then deletes the VMSS instance whose computer name is vmName
+func (kan *UpgradeAgentNode) deleteScaleSetVM(vmName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
+
+	instanceID, err := kan.scaleSetInstanceID(ctx, vmName)
+	if err != nil {
+		return err
+	}
+
+	kan.logger.Infof("Deallocating VM %s in VMSS %s", vmName, kan.ScaleSetName)
+	if err = kan.Client.DeallocateVirtualMachineScaleSetVM(ctx, kan.ResourceGroup, kan.ScaleSetName, instanceID); err != nil {
+		return errors.Wrapf(err, "deallocating VM %s in VMSS %s", vmName, kan.ScaleSetName)
+	}
+
+	kan.logger.Infof("Deleting VM %s in VMSS %s", vmName, kan.ScaleSetName)
+	if err = kan.Client.DeleteVirtualMachineScaleSetVM(ctx, kan.ResourceGroup, kan.ScaleSetName, instanceID); err != nil {
+		return errors.Wrapf(err, "deleting VM %s in VMSS %s", vmName, kan.ScaleSetName)
+	}
+	return nil
+}
+
+// scaleSetInstanceID returns the instance ID of the VMSS VM whose computer name is vmName
+func (kan *UpgradeAgentNode) scaleSetInstanceID(ctx context.Context, vmName string) (string, error) {
+	for page, err := kan.Client.ListVirtualMachineScaleSetVMs(ctx, kan.ResourceGroup, kan.ScaleSetName); page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return "", errors.Wrapf(err, "listing VMs in VMSS %s", kan.ScaleSetName)
+		}
+		for _, vm := range page.Values() {
+			if vm.InstanceID == nil || vm.VirtualMachineScaleSetVMProperties == nil ||
+				vm.VirtualMachineScaleSetVMProperties.OsProfile == nil || vm.VirtualMachineScaleSetVMProperties.OsProfile.ComputerName == nil {
+				continue
+			}
+			if strings.EqualFold(*vm.VirtualMachineScaleSetVMProperties.OsProfile.ComputerName, vmName) {
+				return *vm.InstanceID, nil
+			}
+		}
+	}
+	return "", errors.Errorf("VM %s not found in VMSS %s", vmName, kan.ScaleSetName)
+}
+
+// updateScaleSetVM upgrades a VMSS instance in place instead of replacing it: the node is drained, its instance
+// updated to the latest scale set model, then validated and made schedulable again.
+// As for a new node, Validate deletes the instance if the node is not ready in time.
+func (ku *Upgrader) updateScaleSetVM(ctx context.Context, vmssToUpgrade *AgentPoolScaleSet, vmToUpgrade AgentPoolScaleSetVM) error {
+	start := time.Now()
+	poolName := vmssToUpgrade.poolName()
+	upgradeAgentNode := UpgradeAgentNode{
+		Translator:              ku.Translator,
+		logger:                  ku.logger,
+		UpgradeContainerService: ku.ClusterTopology.DataModel,
+		SubscriptionID:          ku.ClusterTopology.SubscriptionID,
+		ResourceGroup:           ku.ClusterTopology.ResourceGroup,
+		Client:                  ku.Client,
+		kubeConfig:              ku.kubeConfig,
+		timeout:                 defaultTimeout,
+		cordonDrainTimeout:      ku.poolCordonDrainTimeout(poolName),
+		IsVMSS:                  true,
+		ScaleSetName:            vmssToUpgrade.Name,
+		InstanceIDs:             []string{vmToUpgrade.InstanceID},
+	}
+	if ku.stepTimeout != nil {
+		upgradeAgentNode.timeout = *ku.stepTimeout
+	}
+
+	client, err := ku.getKubernetesClient(upgradeAgentNode.cordonDrainTimeout)
+	if err != nil {
+		ku.logger.Errorf("Error getting Kubernetes client: %v", err)
+		return err
+	}
+
+	if !ku.SkipCapacityCheck {
+		if err = checkCapacity(client, strings.ToLower(vmToUpgrade.Name), ku.MinFreeCapacityPercent); err != nil {
+			ku.logger.Errorf("Capacity preflight check failed for VMSS VM %s: %v", vmToUpgrade.Name, err)
+			return err
+		}
+	}
+
+	var drainDuration time.Duration
+	drain, err := ku.drainBeforeDelete(client, poolName, vmToUpgrade.Name)
+	if err != nil {
+		ku.logger.Errorf("Error updating VMSS VM %s without draining it: %v", vmToUpgrade.Name, err)
+		return err
+	}
+	if drain {
+		ku.reportNodePhase(NodeDrainingEvent, poolName, vmToUpgrade.Name)
+		ku.logger.Infof("Draining node %s", vmToUpgrade.Name)
+		drainStart := time.Now()
+		err = operations.SafelyDrainNodeWithMaxEvictionErrors(client, ku.logger, vmToUpgrade.Name,
+			upgradeAgentNode.cordonDrainTimeout, ku.drainGracePeriod(poolName), ku.DrainMaxEvictionErrors)
+		drainDuration = time.Since(drainStart)
+		if err != nil {
+			ku.logger.Errorf("Error draining VM in VMSS: %v", err)
+			// Continue even if there's an error in draining the node.
+		}
+	}
+
+	ku.reportNodePhase(NodeCreatingEvent, poolName, vmToUpgrade.Name)
+	if err = upgradeAgentNode.CreateNode(ctx, poolName, 0); err != nil {
+		ku.logger.Errorf("Error updating VM %s in VMSS %s: %v", vmToUpgrade.Name, vmssToUpgrade.Name, err)
+		return err
+	}
+
+	ku.reportNodePhase(NodeValidatingEvent, poolName, vmToUpgrade.Name)
+	if err = upgradeAgentNode.Validate(&vmToUpgrade.Name); err != nil {
+		ku.logger.Errorf("Error validating updated VM %s in VMSS %s: %v", vmToUpgrade.Name, vmssToUpgrade.Name, err)
+		return err
+	}
+	if drain {
+		if err = uncordonNode(client, vmToUpgrade.Name); err != nil {
+			return err
+		}
+	}
+	ku.logger.Infof("Successfully updated VM %s in VMSS %s", vmToUpgrade.Name, vmssToUpgrade.Name)
+
+	ku.reportEvent(UpgradeEvent{
+		Type:          NodeUpgradedEvent,
+		PoolName:      poolName,
+		NodeName:      vmToUpgrade.Name,
+		Duration:      time.Since(start),
+		DrainDuration: drainDuration,
+	})
+	return nil
+}
+
+// uncordonNode marks the node cordoned by its drain schedulable again
+func uncordonNode(client kubernetes.Client, vmName string) error {
+	nodeName := strings.ToLower(vmName)
+	node, err := client.GetNode(nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "getting node %s", nodeName)
+	}
+	node.Spec.Unschedulable = false
+	if _, err = client.UpdateNode(node); err != nil {
+		return errors.Wrapf(err, "marking node %s schedulable", nodeName)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("VMSS agent node tests", func() {
+	var (
+		kan        *UpgradeAgentNode
+		mockClient *armhelpers.MockAKSEngineClient
+		vmName     = "k8s-agentpool1-12345678-vmss000001"
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+		mockClient.FakeListVirtualMachineScaleSetVMsResult = func() []compute.VirtualMachineScaleSetVM {
+			vm := mockClient.MakeFakeVirtualMachineScaleSetVMWithGivenName("Kubernetes:1.18.8", vmName)
+			instanceID := "1"
+			vm.InstanceID = &instanceID
+			return []compute.VirtualMachineScaleSetVM{vm}
+		}
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.Client = mockClient
+		kan.cordonDrainTimeout = time.Minute
+		kan.IsVMSS = true
+		kan.ScaleSetName = "k8s-agentpool1-12345678-vmss"
+	})
+
+	It("Should update the scale set instances to the latest model", func() {
+		kan.InstanceIDs = []string{"1", "2"}
+
+		Expect(kan.CreateNode(context.Background(), "agentpool1", 0)).To(Succeed())
+		Expect(mockClient.ScaleSetVMOperations).To(Equal([]string{
+			"update:k8s-agentpool1-12345678-vmss/1",
+			"update:k8s-agentpool1-12345678-vmss/2",
+		}))
+		Expect(kan.ParametersMap["agentpool1Count"]).To(Equal(map[string]interface{}{"value": 1}))
+	})
+
+	It("Should fail when there are no instances to update", func() {
+		err := kan.CreateNode(context.Background(), "agentpool1", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("no instance IDs to upgrade"))
+		Expect(mockClient.ScaleSetVMOperations).To(BeEmpty())
+	})
+
+	It("Should fail when the scale set instances cannot be updated", func() {
+		kan.InstanceIDs = []string{"1"}
+		mockClient.FailUpdateVirtualMachineScaleSetVMs = true
+
+		err := kan.CreateNode(context.Background(), "agentpool1", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("updating instances of VMSS k8s-agentpool1-12345678-vmss"))
+	})
+
+	It("Should deallocate and then delete the scale set instance", func() {
+		Expect(kan.DeleteNode(&vmName, true)).To(Succeed())
+		Expect(mockClient.ScaleSetVMOperations).To(Equal([]string{
+			"deallocate:k8s-agentpool1-12345678-vmss/1",
+			"delete:k8s-agentpool1-12345678-vmss/1",
+		}))
+	})
+
+	It("Should not delete the scale set instance when deallocation fails", func() {
+		mockClient.FailDeallocateVirtualMachineScaleSetVM = true
+
+		err := kan.DeleteNode(&vmName, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("deallocating VM " + vmName))
+		Expect(mockClient.ScaleSetVMOperations).To(BeEmpty())
+	})
+
+	It("Should fail when the node is not part of the scale set", func() {
+		otherVM := "k8s-agentpool1-12345678-vmss000009"
+
+		err := kan.DeleteNode(&otherVM, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("VM " + otherVM + " not found in VMSS k8s-agentpool1-12345678-vmss"))
+	})
+})
+
+var _ = Describe("VMSS instance update tests", func() {
+	var (
+		u             *Upgrader
+		kubeClient    *armhelpers.MockKubernetesClient
+		mockClient    *armhelpers.MockAKSEngineClient
+		reporter      *fakeReporter
+		unschedulable map[string]bool
+	)
+
+	BeforeEach(func() {
+		unschedulable = map[string]bool{}
+		kubeClient = &armhelpers.MockKubernetesClient{
+			GetNodeFunc: func(name string) (*v1.Node, error) {
+				node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+				node.Spec.Unschedulable = unschedulable[name]
+				node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+				return node, nil
+			},
+			UpdateNodeFunc: func(node *v1.Node) (*v1.Node, error) {
+				unschedulable[node.Name] = node.Spec.Unschedulable
+				return node, nil
+			},
+		}
+		u = newTestCRDUpgrader("1.18.8", kubeClient)
+		reporter = &fakeReporter{}
+		u.Reporters = []UpgradeReporter{reporter}
+		u.SkipCapacityCheck = true
+		u.UpdateScaleSetInstances = true
+		u.ClusterTopology.ResourceGroup = "TestRg"
+		u.ClusterTopology.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{newTestScaleSet("k8s-agentpool1-12345678-vmss", false, 2)}
+		u.ClusterTopology.AgentPools = map[string]*AgentPoolTopology{"agentpool1": {Name: to.StringPtr("agentpool1")}}
+		mockClient = u.Client.(*armhelpers.MockAKSEngineClient)
+	})
+
+	It("Should update the VMSS instances in place instead of replacing them", func() {
+		Expect(u.upgradeAgentScaleSets(context.Background())).To(Succeed())
+
+		Expect(mockClient.ScaleSetVMOperations).To(Equal([]string{
+			"update:k8s-agentpool1-12345678-vmss/0",
+			"update:k8s-agentpool1-12345678-vmss/1",
+		}))
+		Expect(*u.ClusterTopology.AgentPoolScaleSetsToUpgrade[0].Sku.Capacity).To(Equal(int64(2)))
+		// the nodes were cordoned by their drain and are schedulable again
+		Expect(unschedulable).To(Equal(map[string]bool{
+			"k8s-agentpool1-12345678-vmss000000": false,
+			"k8s-agentpool1-12345678-vmss000001": false,
+		}))
+		var upgraded []string
+		for _, event := range reporter.events {
+			if event.Type == NodeUpgradedEvent {
+				upgraded = append(upgraded, event.NodeName)
+			}
+		}
+		Expect(upgraded).To(Equal([]string{"k8s-agentpool1-12345678-vmss000000", "k8s-agentpool1-12345678-vmss000001"}))
+	})
+
+	It("Should not delete the VMSS instance when its update fails", func() {
+		u.ConsecutiveFailureLimit = 1
+		mockClient.FailUpdateVirtualMachineScaleSetVMs = true
+
+		err := u.upgradeAgentScaleSets(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("updating instances of VMSS k8s-agentpool1-12345678-vmss"))
+		Expect(mockClient.ScaleSetVMOperations).To(BeEmpty())
+	})
+})
@@ -0,0 +1,112 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// validateIgnoreNodesSelector ensures IgnoreNodesWithPodLabelSelector is a valid label selector
+func (ku *Upgrader) validateIgnoreNodesSelector() error {
+	if ku.IgnoreNodesWithPodLabelSelector == "" {
+		return nil
+	}
+	if _, err := labels.Parse(ku.IgnoreNodesWithPodLabelSelector); err != nil {
+		return errors.Wrapf(err, "parsing the pod label selector %q of the nodes to ignore", ku.IgnoreNodesWithPodLabelSelector)
+	}
+	return nil
+}
+
+// nodesToSkip returns the nodes of nodeNames running a pod matching IgnoreNodesWithPodLabelSelector.
+// Each of them is logged, reported and added to SkippedNodes, to be upgraded manually.
+func (ku *Upgrader) nodesToSkip(client kubernetes.Client, poolName string, nodeNames []string) (map[string]bool, error) {
+	if ku.IgnoreNodesWithPodLabelSelector == "" || len(nodeNames) == 0 {
+		return nil, nil
+	}
+	selector, err := labels.Parse(ku.IgnoreNodesWithPodLabelSelector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing the pod label selector %q of the nodes to ignore", ku.IgnoreNodesWithPodLabelSelector)
+	}
+	pods, err := client.ListAllPods()
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing the pods of agent pool %s", poolName)
+	}
+	matching := map[string]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == v1.PodRunning && selector.Matches(labels.Set(pod.Labels)) {
+			matching[pod.Spec.NodeName] = true
+		}
+	}
+
+	skipped := map[string]bool{}
+	for _, name := range nodeNames {
+		if !matching[strings.ToLower(name)] {
+			continue
+		}
+		ku.logger.Warnf("Skipping node %s of agent pool %s, it runs pods matching %s and must be upgraded manually", name, poolName, ku.IgnoreNodesWithPodLabelSelector)
+		skipped[name] = true
+		ku.SkippedNodes = append(ku.SkippedNodes, name)
+		ku.reportNodePhase(NodeSkippedEvent, poolName, name)
+	}
+	return skipped, nil
+}
+
+// skipAgentVMs ignores the availability set agent pool VMs to upgrade running pods matching
+// IgnoreNodesWithPodLabelSelector and returns how many were skipped
+func (ku *Upgrader) skipAgentVMs(client kubernetes.Client, poolName string, vms map[int]*vmInfo) (int, error) {
+	var names []string
+	for _, index := range sortedVMIndexes(vms) {
+		if vms[index].status == vmStatusNotUpgraded {
+			names = append(names, vms[index].name)
+		}
+	}
+	skipped, err := ku.nodesToSkip(client, poolName, names)
+	if err != nil {
+		return 0, err
+	}
+	for _, vm := range vms {
+		if vm.status == vmStatusNotUpgraded && skipped[vm.name] {
+			vm.status = vmStatusIgnored
+		}
+	}
+	return len(skipped), nil
+}
+
+// skipScaleSetVMs removes the VMs running pods matching IgnoreNodesWithPodLabelSelector from the VMs to upgrade
+// of each scale set
+func (ku *Upgrader) skipScaleSetVMs() error {
+	if ku.IgnoreNodesWithPodLabelSelector == "" || len(ku.ClusterTopology.AgentPoolScaleSetsToUpgrade) == 0 {
+		return nil
+	}
+	client, err := ku.getKubernetesClient(10 * time.Second)
+	if err != nil {
+		return err
+	}
+	for i := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
+		vmss := &ku.ClusterTopology.AgentPoolScaleSetsToUpgrade[i]
+		names := make([]string, len(vmss.VMsToUpgrade))
+		for j, vm := range vmss.VMsToUpgrade {
+			names[j] = vm.Name
+		}
+		skipped, err := ku.nodesToSkip(client, vmss.poolName(), names)
+		if err != nil {
+			return err
+		}
+		var vms []AgentPoolScaleSetVM
+		for _, vm := range vmss.VMsToUpgrade {
+			if !skipped[vm.Name] {
+				vms = append(vms, vm)
+			}
+		}
+		vmss.VMsToUpgrade = vms
+	}
+	return nil
+}
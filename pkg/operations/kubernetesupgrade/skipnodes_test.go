@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Skip nodes tests", func() {
+	var (
+		k8sClient *armhelpers.MockKubernetesClient
+		u         *Upgrader
+	)
+
+	newPod := func(nodeName string, phase v1.PodPhase, labels map[string]string) v1.Pod {
+		pod := v1.Pod{}
+		pod.Labels = labels
+		pod.Spec.NodeName = nodeName
+		pod.Status.Phase = phase
+		return pod
+	}
+
+	BeforeEach(func() {
+		k8sClient = &armhelpers.MockKubernetesClient{
+			PodsList: &v1.PodList{Items: []v1.Pod{
+				newPod("k8s-agentpool1-12345678-0", v1.PodRunning, map[string]string{"storage": "local"}),
+				newPod("k8s-agentpool1-12345678-1", v1.PodRunning, map[string]string{"app": "web"}),
+				newPod("k8s-agentpool1-12345678-2", v1.PodSucceeded, map[string]string{"storage": "local"}),
+				newPod("k8s-linux1-12345678-vmss000001", v1.PodRunning, map[string]string{"storage": "local"}),
+			}},
+		}
+		u = newTestCRDUpgrader("1.18.8", k8sClient)
+		u.IgnoreNodesWithPodLabelSelector = "storage=local"
+	})
+
+	It("Should not skip nodes without selector", func() {
+		u.IgnoreNodesWithPodLabelSelector = ""
+		k8sClient.FailListPods = true
+		skipped, err := u.nodesToSkip(k8sClient, "agentpool1", []string{"k8s-agentpool1-12345678-0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(BeEmpty())
+		Expect(u.SkippedNodes).To(BeEmpty())
+	})
+
+	It("Should skip the nodes running matching pods", func() {
+		skipped, err := u.nodesToSkip(k8sClient, "agentpool1", []string{"K8S-AGENTPOOL1-12345678-0", "k8s-agentpool1-12345678-1", "k8s-agentpool1-12345678-2"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(Equal(map[string]bool{"K8S-AGENTPOOL1-12345678-0": true}))
+		Expect(u.SkippedNodes).To(Equal([]string{"K8S-AGENTPOOL1-12345678-0"}))
+	})
+
+	It("Should return the errors listing the pods or parsing the selector", func() {
+		k8sClient.FailListPods = true
+		_, err := u.nodesToSkip(k8sClient, "agentpool1", []string{"k8s-agentpool1-12345678-0"})
+		Expect(err).To(MatchError("listing the pods of agent pool agentpool1: ListAllPods failed"))
+
+		u.IgnoreNodesWithPodLabelSelector = "storage in ("
+		Expect(u.validateIgnoreNodesSelector()).To(HaveOccurred())
+		_, err = u.nodesToSkip(k8sClient, "agentpool1", []string{"k8s-agentpool1-12345678-0"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should ignore the skipped availability set VMs", func() {
+		vms := map[int]*vmInfo{
+			0: {"k8s-agentpool1-12345678-0", vmStatusNotUpgraded},
+			1: {"k8s-agentpool1-12345678-1", vmStatusNotUpgraded},
+			2: {"k8s-agentpool1-12345678-2", vmStatusUpgraded},
+		}
+		skippedCount, err := u.skipAgentVMs(k8sClient, "agentpool1", vms)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skippedCount).To(Equal(1))
+		Expect(vms[0].status).To(Equal(vmStatusIgnored))
+		Expect(vms[1].status).To(Equal(vmStatusNotUpgraded))
+		Expect(vms[2].status).To(Equal(vmStatusUpgraded))
+	})
+
+	It("Should remove the skipped VMs from the scale sets to upgrade", func() {
+		u.ClusterTopology.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{
+			newTestScaleSet("k8s-linux1-12345678-vmss", false, 3),
+		}
+		Expect(u.skipScaleSetVMs()).To(Succeed())
+		Expect(scaleSetVMNames(u.scaleSetVMUpgradeOrder())).To(Equal([]string{
+			"k8s-linux1-12345678-vmss000000",
+			"k8s-linux1-12345678-vmss000002",
+		}))
+		Expect(u.SkippedNodes).To(Equal([]string{"k8s-linux1-12345678-vmss000001"}))
+	})
+})
@@ -0,0 +1,56 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/pkg/errors"
+)
+
+// ValidateSkipPools ensures every agent pool of skipPools is in the cluster definition
+func ValidateSkipPools(skipPools []string, properties *api.Properties) error {
+	pools := map[string]bool{}
+	for _, pool := range properties.AgentPoolProfiles {
+		pools[pool.Name] = true
+	}
+	var unknown []string
+	for _, name := range skipPools {
+		if !pools[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.Errorf("agent pools %s to skip are not in the cluster definition", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// isPoolSkipped returns whether the agent pool is excluded from the upgrade by SkipPools
+func (uc *UpgradeCluster) isPoolSkipped(poolName string) bool {
+	for _, name := range uc.SkipPools {
+		if name == poolName {
+			return true
+		}
+	}
+	return false
+}
+
+// skipPools removes the agent pools of SkipPools from AgentPoolsToUpgrade. Their nodes keep their current
+// Kubernetes version, which is recorded on their agent pool profile unless it sets one already.
+func (uc *UpgradeCluster) skipPools() {
+	for _, pool := range uc.DataModel.Properties.AgentPoolProfiles {
+		if !uc.isPoolSkipped(pool.Name) {
+			continue
+		}
+		uc.Logger.Infof("Skipping agent pool %s, its nodes keep their current Kubernetes version", pool.Name)
+		delete(uc.AgentPoolsToUpgrade, pool.Name)
+		if pool.OrchestratorVersion == "" {
+			pool.OrchestratorVersion = uc.CurrentVersion
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Skip pools tests", func() {
+	var (
+		cs         *api.ContainerService
+		uc         UpgradeCluster
+		mockClient armhelpers.MockAKSEngineClient
+	)
+
+	BeforeEach(func() {
+		mockClient = armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+		cs = api.CreateMockContainerService("testcluster", "", 3, 3, false)
+		agentPool2 := *cs.Properties.AgentPoolProfiles[0]
+		agentPool2.Name = "agentpool2"
+		cs.Properties.AgentPoolProfiles = append(cs.Properties.AgentPoolProfiles, &agentPool2)
+		uc = UpgradeCluster{
+			Translator: &i18n.Translator{},
+			Logger:     log.NewEntry(log.New()),
+		}
+		uc.Client = &mockClient
+		uc.ClusterTopology = ClusterTopology{}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = cs
+		uc.NameSuffix = "12345678"
+		uc.CurrentVersion = "1.17.17"
+		uc.Force = true
+		uc.AgentPoolsToUpgrade = map[string]bool{MasterPoolName: true, "agentpool1": true, "agentpool2": true}
+		uc.SkipPools = []string{"agentpool2"}
+		uc.UpgradeWorkFlow = fakeUpgradeWorkflow{}
+		currentVersion := "Kubernetes:1.17.17"
+		mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+			skipped := mockClient.MakeFakeVirtualMachine("k8s-agentpool2-12345678-0", currentVersion)
+			skipped.Tags["poolName"] = to.StringPtr("agentpool2")
+			return []compute.VirtualMachine{
+				mockClient.MakeFakeVirtualMachine(fmt.Sprintf("%s-12345678-0", common.LegacyControlPlaneVMPrefix), currentVersion),
+				mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", currentVersion),
+				skipped,
+			}
+		}
+	})
+
+	It("Should validate the pools to skip against the cluster definition", func() {
+		Expect(ValidateSkipPools(nil, cs.Properties)).To(Succeed())
+		Expect(ValidateSkipPools([]string{"agentpool2"}, cs.Properties)).To(Succeed())
+		Expect(ValidateSkipPools([]string{"gpupool", "agentpool1", "armpool"}, cs.Properties)).To(
+			MatchError("agent pools armpool, gpupool to skip are not in the cluster definition"))
+	})
+
+	It("Should leave the availability set VMs of the skipped pools out of the upgrade", func() {
+		Expect(uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(*uc.MasterVMs).To(HaveLen(1))
+		Expect(uc.AgentPools).To(HaveLen(1))
+		Expect(*uc.AgentPools["agentpool1"].AgentVMs).To(HaveLen(1))
+		Expect(uc.AgentPoolsToUpgrade).NotTo(HaveKey("agentpool2"))
+		Expect(cs.Properties.AgentPoolProfiles[0].OrchestratorVersion).To(BeEmpty())
+		Expect(cs.Properties.AgentPoolProfiles[1].OrchestratorVersion).To(Equal("1.17.17"))
+	})
+
+	It("Should keep the version set on the profile of a skipped pool", func() {
+		cs.Properties.AgentPoolProfiles[1].OrchestratorVersion = "1.9.6"
+
+		Expect(uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(cs.Properties.AgentPoolProfiles[1].OrchestratorVersion).To(Equal("1.9.6"))
+	})
+
+	It("Should leave the scale sets of the skipped pools out of the upgrade", func() {
+		mockClient.FakeListVirtualMachineScaleSetsResult = func() []compute.VirtualMachineScaleSet {
+			var scaleSets []compute.VirtualMachineScaleSet
+			for _, name := range []string{"k8s-agentpool1-12345678-vmss", "k8s-agentpool2-12345678-vmss"} {
+				scaleSets = append(scaleSets, compute.VirtualMachineScaleSet{
+					Name:                             to.StringPtr(name),
+					Sku:                              &compute.Sku{Capacity: to.Int64Ptr(1)},
+					Location:                         to.StringPtr("eastus"),
+					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{},
+				})
+			}
+			return scaleSets
+		}
+		mockClient.FakeListVirtualMachineScaleSetVMsResult = func() []compute.VirtualMachineScaleSetVM {
+			return []compute.VirtualMachineScaleSetVM{mockClient.MakeFakeVirtualMachineScaleSetVM("Kubernetes:1.17.17")}
+		}
+
+		Expect(uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(uc.AgentPoolScaleSetsToUpgrade).To(HaveLen(1))
+		Expect(uc.AgentPoolScaleSetsToUpgrade[0].Name).To(Equal("k8s-agentpool1-12345678-vmss"))
+	})
+
+	It("Should report the skipped pools when the upgrade starts", func() {
+		reporter := &fakeReporter{}
+		uc.Reporters = []UpgradeReporter{reporter}
+		uc.UpgradeWorkFlow = nil
+
+		Expect(uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(reporter.events[0].Type).To(Equal(UpgradeStartedEvent))
+		Expect(reporter.events[0].SkippedPools).To(Equal([]string{"agentpool2"}))
+	})
+})
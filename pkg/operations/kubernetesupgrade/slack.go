@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Compiler to verify SlackNotifier implements UpgradeReporter
+var _ UpgradeReporter = &SlackNotifier{}
+
+// SlackNotifier posts the upgrade started, node upgraded, failure and completed events to a Slack channel
+// through an incoming webhook. See https://api.slack.com/messaging/webhooks
+type SlackNotifier struct {
+	// SlackWebhookURL is the URL of the incoming webhook
+	SlackWebhookURL string
+	// SlackChannel overrides the channel of the webhook, e.g. #ops, if set
+	SlackChannel string
+	// SlackMentionOnFailure is mentioned in the failure messages, e.g. @oncall, @here or <!subteam^ID>
+	SlackMentionOnFailure string
+	HTTPClient            *http.Client
+}
+
+// NewSlackNotifier returns a SlackNotifier posting to the webhookURL incoming webhook
+func NewSlackNotifier(webhookURL, channel, mentionOnFailure string) *SlackNotifier {
+	return &SlackNotifier{
+		SlackWebhookURL:       webhookURL,
+		SlackChannel:          channel,
+		SlackMentionOnFailure: mentionOnFailure,
+		HTTPClient:            &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+type slackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Report posts a message for the upgrade started, node upgraded, node upgrade failed, upgrade completed
+// and upgrade failed events, mentioning SlackMentionOnFailure on failures. Other event types are ignored.
+func (n *SlackNotifier) Report(event UpgradeEvent) error {
+	var text string
+	switch event.Type {
+	case UpgradeStartedEvent:
+		text = fmt.Sprintf(":rocket: %s, %d nodes to upgrade", escapeSlackText(event.Message), len(event.Nodes))
+	case NodeUpgradedEvent:
+		text = ":white_check_mark: " + escapeSlackText(kubernetesEventMessage(event))
+	case NodeUpgradeFailedEvent:
+		text = n.failureText(fmt.Sprintf("Failed to upgrade node %s of pool %s: %s", event.NodeName, event.PoolName, event.Message))
+	case UpgradeCompletedEvent:
+		text = ":tada: " + escapeSlackText(event.Message)
+		if len(event.SkippedNodes) > 0 {
+			text += escapeSlackText(fmt.Sprintf(", skipped nodes to upgrade manually: %s", strings.Join(event.SkippedNodes, ", ")))
+		}
+	case UpgradeFailedEvent:
+		text = n.failureText("Upgrade failed: " + event.Message)
+	default:
+		return nil
+	}
+	message := slackMessage{
+		Channel: n.SlackChannel,
+		Text:    text,
+	}
+	if err := sendJSON(n.HTTPClient, nil, http.MethodPost, n.SlackWebhookURL, message); err != nil {
+		return errors.Wrapf(err, "posting %s to the Slack webhook", event.Type)
+	}
+	return nil
+}
+
+// failureText returns the text of a failure message, mentioning SlackMentionOnFailure if set
+func (n *SlackNotifier) failureText(message string) string {
+	text := ":x: " + escapeSlackText(message)
+	if n.SlackMentionOnFailure != "" {
+		text = slackMention(n.SlackMentionOnFailure) + " " + text
+	}
+	return text
+}
+
+// slackMention returns the Slack syntax notifying mention: @here, @channel and @everyone become special mentions,
+// other values, e.g. a user group mention <!subteam^ID> or @oncall resolved by the workspace, are kept
+func slackMention(mention string) string {
+	switch strings.ToLower(mention) {
+	case "@here", "@channel", "@everyone":
+		return "<!" + strings.ToLower(strings.TrimPrefix(mention, "@")) + ">"
+	}
+	return mention
+}
+
+// escapeSlackText escapes the control characters of the Slack message formatting
+func escapeSlackText(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
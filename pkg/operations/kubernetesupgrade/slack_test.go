@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Slack notifier tests", func() {
+	var (
+		server       *httptest.Server
+		mu           sync.Mutex
+		contentTypes []string
+		messages     []map[string]interface{}
+		status       int
+		notifier     *SlackNotifier
+	)
+
+	BeforeEach(func() {
+		contentTypes = nil
+		messages = nil
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			body, _ := ioutil.ReadAll(r.Body)
+			var m map[string]interface{}
+			_ = json.Unmarshal(body, &m)
+			contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+			messages = append(messages, m)
+			w.WriteHeader(status)
+		}))
+		notifier = NewSlackNotifier(server.URL+"/services/T000/B000/XXXX", "#ops", "@oncall")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should post the upgrade started, node upgraded and completed events", func() {
+		Expect(notifier.Report(UpgradeEvent{
+			Type:    UpgradeStartedEvent,
+			Message: "Upgrading cluster from Kubernetes 1.18.8 to 1.19.1",
+			Nodes:   []string{"k8s-master-12345678-0", "k8s-agentpool1-12345678-0"},
+		})).To(Succeed())
+		Expect(notifier.Report(UpgradeEvent{
+			Type:     NodeUpgradedEvent,
+			PoolName: "agentpool1",
+			NodeName: "k8s-agentpool1-12345678-0",
+			Duration: 90 * time.Second,
+		})).To(Succeed())
+		Expect(notifier.Report(UpgradeEvent{
+			Type:         UpgradeCompletedEvent,
+			Message:      "Upgraded cluster to Kubernetes 1.19.1",
+			SkippedNodes: []string{"k8s-agentpool1-12345678-1"},
+		})).To(Succeed())
+
+		Expect(contentTypes).To(ConsistOf("application/json", "application/json", "application/json"))
+		Expect(messages).To(Equal([]map[string]interface{}{
+			{"channel": "#ops", "text": ":rocket: Upgrading cluster from Kubernetes 1.18.8 to 1.19.1, 2 nodes to upgrade"},
+			{"channel": "#ops", "text": ":white_check_mark: Upgraded node k8s-agentpool1-12345678-0 of pool agentpool1 in 1m30s"},
+			{"channel": "#ops", "text": ":tada: Upgraded cluster to Kubernetes 1.19.1, skipped nodes to upgrade manually: k8s-agentpool1-12345678-1"},
+		}))
+	})
+
+	It("Should mention SlackMentionOnFailure on failures", func() {
+		Expect(notifier.Report(UpgradeEvent{
+			Type:     NodeUpgradeFailedEvent,
+			PoolName: "agentpool1",
+			NodeName: "k8s-agentpool1-12345678-0",
+			Message:  "drain timed out",
+		})).To(Succeed())
+		notifier.SlackMentionOnFailure = "@here"
+		Expect(notifier.Report(UpgradeEvent{
+			Type:    UpgradeFailedEvent,
+			Message: "error creating <master> & agent nodes",
+		})).To(Succeed())
+
+		Expect(messages).To(HaveLen(2))
+		Expect(messages[0]["text"]).To(Equal("@oncall :x: Failed to upgrade node k8s-agentpool1-12345678-0 of pool agentpool1: drain timed out"))
+		Expect(messages[1]["text"]).To(Equal("<!here> :x: Upgrade failed: error creating &lt;master&gt; &amp; agent nodes"))
+	})
+
+	It("Should use the channel of the webhook without SlackChannel", func() {
+		notifier.SlackChannel = ""
+		notifier.SlackMentionOnFailure = ""
+		Expect(notifier.Report(UpgradeEvent{Type: UpgradeFailedEvent, Message: "timeout"})).To(Succeed())
+
+		Expect(messages).To(Equal([]map[string]interface{}{
+			{"text": ":x: Upgrade failed: timeout"},
+		}))
+	})
+
+	It("Should ignore the node step events", func() {
+		for _, eventType := range []UpgradeEventType{NodeDrainingEvent, NodeDeletingEvent, NodeCreatingEvent, NodeValidatingEvent, NodeSkippedEvent} {
+			Expect(notifier.Report(UpgradeEvent{Type: eventType})).To(Succeed())
+		}
+		Expect(messages).To(BeEmpty())
+	})
+
+	It("Should return an error when Slack rejects the message", func() {
+		status = http.StatusNotFound
+		err := notifier.Report(UpgradeEvent{Type: UpgradeCompletedEvent, Message: "Upgraded cluster to Kubernetes 1.19.1"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("posting UpgradeCompleted to the Slack webhook"))
+		Expect(err.Error()).To(ContainSubstring("404"))
+	})
+})
@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/vlabs"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+)
+
+// StateSync saves the cluster api model to a state store shared with other tools and operators
+type StateSync interface {
+	// Save stores the api model of cs, replacing the previously saved one
+	Save(ctx context.Context, cs *api.ContainerService) error
+}
+
+// Compiler to verify AzureBlobStateSync and LocalFileStateSync implement StateSync
+var _ StateSync = &AzureBlobStateSync{}
+var _ StateSync = &LocalFileStateSync{}
+
+// AzureBlobStateSync is a StateSync saving the api model to an Azure Storage block blob
+type AzureBlobStateSync struct {
+	// BlobURL is the URL of the block blob including a SAS token granting write access,
+	// e.g. https://account.blob.core.windows.net/clusters/apimodel.json?sv=...
+	BlobURL url.URL
+}
+
+// Save uploads the api model of cs to the blob
+func (s *AzureBlobStateSync) Save(ctx context.Context, cs *api.ContainerService) error {
+	b, err := serializeState(cs)
+	if err != nil {
+		return err
+	}
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{})
+	blob := azblob.NewBlockBlobURL(s.BlobURL, p)
+	if _, err = azblob.UploadBufferToBlockBlob(ctx, b, blob, azblob.UploadToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: "application/json"},
+	}); err != nil {
+		return errors.Wrapf(err, "uploading api model to blob %s", s.BlobURL.Path)
+	}
+	return nil
+}
+
+// LocalFileStateSync is a StateSync saving the api model to a local file, e.g. on a shared file system
+type LocalFileStateSync struct {
+	// Path of the api model file, its directory is created if missing
+	Path string
+}
+
+// Save writes the api model of cs to the file
+func (s *LocalFileStateSync) Save(ctx context.Context, cs *api.ContainerService) error {
+	b, err := serializeState(cs)
+	if err != nil {
+		return err
+	}
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{},
+	}
+	dir, file := filepath.Split(s.Path)
+	if err = f.SaveFile(dir, file, b); err != nil {
+		return errors.Wrapf(err, "saving api model to %s", s.Path)
+	}
+	return nil
+}
+
+// serializeState returns the vlabs api model of cs, as saved by aks-engine upgrade once completed
+func serializeState(cs *api.ContainerService) ([]byte, error) {
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{},
+	}
+	b, err := apiloader.SerializeContainerService(cs, vlabs.APIVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing api model")
+	}
+	return b, nil
+}
+
+// SyncState saves the upgraded cluster api model with StateSync, if set
+func (kmn *UpgradeMasterNode) SyncState(ctx context.Context) error {
+	if kmn.StateSync == nil {
+		return nil
+	}
+	return kmn.StateSync.Save(ctx, kmn.UpgradeContainerService)
+}
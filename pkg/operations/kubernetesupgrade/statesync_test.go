@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+type fakeStateSync struct {
+	saved []*api.ContainerService
+	err   error
+}
+
+func (s *fakeStateSync) Save(ctx context.Context, cs *api.ContainerService) error {
+	s.saved = append(s.saved, cs)
+	return s.err
+}
+
+// savedAPIModel returns the api version and DNS prefix of a saved api model
+func savedAPIModel(b []byte) (string, string) {
+	var model struct {
+		APIVersion string `json:"apiVersion"`
+		Properties struct {
+			MasterProfile struct {
+				DNSPrefix string `json:"dnsPrefix"`
+			} `json:"masterProfile"`
+		} `json:"properties"`
+	}
+	Expect(json.Unmarshal(b, &model)).To(Succeed())
+	return model.APIVersion, model.Properties.MasterProfile.DNSPrefix
+}
+
+var _ = Describe("State sync tests", func() {
+	var cs *api.ContainerService
+
+	BeforeEach(func() {
+		cs = api.CreateMockContainerService("testcluster", "1.18.8", 3, 2, false)
+	})
+
+	It("Should save the api model to a local file", func() {
+		dir, err := ioutil.TempDir("", "statesync")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "testcluster", "apimodel.json")
+
+		s := &LocalFileStateSync{Path: path}
+		Expect(s.Save(context.Background(), cs)).To(Succeed())
+
+		b, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		apiVersion, dnsPrefix := savedAPIModel(b)
+		Expect(apiVersion).To(Equal("vlabs"))
+		Expect(dnsPrefix).To(Equal("testmaster"))
+	})
+
+	It("Should upload the api model to a blob", func() {
+		var method, path, contentType string
+		var body []byte
+		var query url.Values
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path, query = r.Method, r.URL.Path, r.URL.Query()
+			contentType = r.Header.Get("x-ms-blob-content-type")
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+		blobURL, err := url.Parse(server.URL + "/clusters/testcluster.json?sv=2019-02-02&sig=secret")
+		Expect(err).NotTo(HaveOccurred())
+
+		s := &AzureBlobStateSync{BlobURL: *blobURL}
+		Expect(s.Save(context.Background(), cs)).To(Succeed())
+
+		Expect(method).To(Equal(http.MethodPut))
+		Expect(path).To(Equal("/clusters/testcluster.json"))
+		Expect(query.Get("sig")).To(Equal("secret"))
+		Expect(contentType).To(Equal("application/json"))
+		apiVersion, dnsPrefix := savedAPIModel(body)
+		Expect(apiVersion).To(Equal("vlabs"))
+		Expect(dnsPrefix).To(Equal("testmaster"))
+	})
+
+	It("Should fail when the blob cannot be written", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+		blobURL, err := url.Parse(server.URL + "/clusters/testcluster.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		s := &AzureBlobStateSync{BlobURL: *blobURL}
+		err = s.Save(context.Background(), cs)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("uploading api model to blob /clusters/testcluster.json"))
+	})
+
+	It("Should not sync without StateSync", func() {
+		kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+		Expect(kmn.SyncState(context.Background())).To(Succeed())
+	})
+
+	It("Should save the state after each master upgrade and ignore save failures", func() {
+		stateSync := &fakeStateSync{err: errors.New("store unavailable")}
+		uc := UpgradeCluster{
+			Translator: &i18n.Translator{},
+			Logger:     log.NewEntry(log.New()),
+			StateSync:  stateSync,
+		}
+
+		mockClient := armhelpers.MockAKSEngineClient{}
+		uc.Client = &mockClient
+
+		uc.ClusterTopology = ClusterTopology{}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = api.CreateMockContainerService("testcluster", "", 3, 1, false)
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+
+		err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stateSync.saved).To(HaveLen(3))
+		Expect(stateSync.saved[0]).To(BeIdenticalTo(uc.DataModel))
+
+		// Clean up
+		os.RemoveAll("./translations")
+	})
+})
@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// azureReservedSubnetIPs is the number of addresses Azure reserves in every subnet
+const azureReservedSubnetIPs = 5
+
+// SubnetIPCapacityCheck returns an error if the subnet has fewer than requiredIPs free IP addresses,
+// the addresses in use being the IP configurations attached to the subnet.
+func (kmn *UpgradeMasterNode) SubnetIPCapacityCheck(ctx context.Context, subnetResourceID string, requiredIPs int) error {
+	parts := strings.Split(subnetResourceID, "/")
+	if len(parts) <= api.DefaultSubnetNameResourceSegmentIndex {
+		return errors.Errorf("unable to parse subnet ID %s", subnetResourceID)
+	}
+	resourceGroup := parts[api.DefaultVnetResourceGroupSegmentIndex]
+	vnetName := parts[api.DefaultVnetNameResourceSegmentIndex]
+	subnetName := parts[api.DefaultSubnetNameResourceSegmentIndex]
+
+	subnet, err := kmn.Client.GetSubnet(ctx, resourceGroup, vnetName, subnetName)
+	if err != nil {
+		return errors.Wrapf(err, "getting subnet %s", subnetResourceID)
+	}
+	if subnet.SubnetPropertiesFormat == nil || subnet.AddressPrefix == nil {
+		return errors.Errorf("subnet %s has no address prefix", subnetName)
+	}
+	_, ipNet, err := net.ParseCIDR(*subnet.AddressPrefix)
+	if err != nil {
+		return errors.Wrapf(err, "parsing address prefix of subnet %s", subnetName)
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones >= 31 {
+		// too large to ever run out of addresses, and would overflow below
+		return nil
+	}
+	allocated := 0
+	if subnet.IPConfigurations != nil {
+		allocated = len(*subnet.IPConfigurations)
+	}
+	free := (1 << uint(bits-ones)) - azureReservedSubnetIPs - allocated
+	if free < requiredIPs {
+		return errors.Errorf("subnet %s (%s) has %d free IP addresses, %d required", subnetName, to.String(subnet.AddressPrefix), free, requiredIPs)
+	}
+	return nil
+}
+
+// requiredSubnetIPs returns how many free IP addresses the master VMs need in the subnet they are attached to
+// by the upgrade. The master VMs replaced in their own subnet get their static IP addresses back and need none,
+// those moved to the replacement subnet or to the subnet of the peered virtual network all need new ones.
+func (kmn *UpgradeMasterNode) requiredSubnetIPs() int {
+	m := kmn.UpgradeContainerService.Properties.MasterProfile
+	if m == nil || (kmn.ReplacementSubnetID == "" && kmn.peeredVNet == "") {
+		return 0
+	}
+	ipAddressCount := m.IPAddressCount
+	if ipAddressCount < 1 {
+		ipAddressCount = 1
+	}
+	return m.Count * ipAddressCount
+}
+
+// masterSubnetID returns the resource ID of the subnet the master VMs are attached to.
+func (kmn *UpgradeMasterNode) masterSubnetID() string {
+	p := kmn.UpgradeContainerService.Properties
+	if p.MasterProfile.IsCustomVNET() {
+		return p.MasterProfile.VnetSubnetID
+	}
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s",
+		kmn.SubscriptionID, kmn.vnetResourceGroup(), p.GetVirtualNetworkName(), p.GetSubnetName())
+}
+
+// vnetResourceGroup returns the resource group of the virtual network of the cluster
+func (kmn *UpgradeMasterNode) vnetResourceGroup() string {
+	if kmn.VNetResourceGroup != "" {
+		return kmn.VNetResourceGroup
+	}
+	if m := kmn.UpgradeContainerService.Properties.MasterProfile; m != nil && m.IsCustomVNET() {
+		if parts := strings.Split(m.VnetSubnetID, "/"); len(parts) > api.DefaultVnetResourceGroupSegmentIndex {
+			return parts[api.DefaultVnetResourceGroupSegmentIndex]
+		}
+	}
+	return kmn.ResourceGroup
+}
+
+// validateReplacementSubnet ensures ReplacementSubnetID is a subnet of the virtual network of the masters
+func (kmn *UpgradeMasterNode) validateReplacementSubnet() error {
+	vnetID, err := subnetVNetID(kmn.ReplacementSubnetID)
+	if err != nil {
+		return err
+	}
+	clusterVNetID, err := subnetVNetID(kmn.masterSubnetID())
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(vnetID, clusterVNetID) {
+		return errors.Errorf("replacement subnet %s is not in the virtual network of the cluster %s", kmn.ReplacementSubnetID, clusterVNetID)
+	}
+	return nil
+}
+
+// subnetVNetID returns the resource ID of the virtual network of the subnet
+func subnetVNetID(subnetResourceID string) (string, error) {
+	parts := strings.Split(subnetResourceID, "/")
+	if len(parts) <= api.DefaultSubnetNameResourceSegmentIndex || !strings.EqualFold(parts[api.DefaultSubnetNameResourceSegmentIndex-1], "subnets") {
+		return "", errors.Errorf("unable to parse subnet ID %s", subnetResourceID)
+	}
+	return strings.Join(parts[:api.DefaultSubnetNameResourceSegmentIndex-1], "/"), nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testSubnetID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/vnetrg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/masters"
+
+func newTestSubnet(prefix string, allocated int) network.Subnet {
+	ipConfigs := make([]network.IPConfiguration, allocated)
+	return network.Subnet{
+		Name: to.StringPtr("masters"),
+		SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+			AddressPrefix:    to.StringPtr(prefix),
+			IPConfigurations: &ipConfigs,
+		},
+	}
+}
+
+var _ = Describe("Subnet IP capacity tests", func() {
+	var mockClient *armhelpers.MockAKSEngineClient
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+	})
+
+	It("Should succeed when the subnet has enough free IPs", func() {
+		mockClient.FakeGetSubnetResult = func() network.Subnet { return newTestSubnet("10.0.0.0/28", 8) }
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.SubnetIPCapacityCheck(context.Background(), testSubnetID, 3)).To(Succeed())
+	})
+
+	It("Should fail when the subnet is running out of IPs", func() {
+		mockClient.FakeGetSubnetResult = func() network.Subnet { return newTestSubnet("10.0.0.0/28", 9) }
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		err := kmn.SubnetIPCapacityCheck(context.Background(), testSubnetID, 3)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("subnet masters (10.0.0.0/28) has 2 free IP addresses, 3 required"))
+	})
+
+	It("Should fail for a malformed subnet ID", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.SubnetIPCapacityCheck(context.Background(), "masters", 1)).NotTo(Succeed())
+	})
+
+	It("Should fail when the subnet cannot be retrieved", func() {
+		mockClient.FailGetSubnet = true
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.SubnetIPCapacityCheck(context.Background(), testSubnetID, 1)).NotTo(Succeed())
+	})
+
+	It("Should not check the master subnet during preflight, the replaced masters keep their IPs", func() {
+		mockClient.FakeGetSubnetResult = func() network.Subnet { return newTestSubnet("10.0.0.0/29", 3) }
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		Expect(kmn.requiredSubnetIPs()).To(Equal(0))
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(mockClient.ResourceGroupCalls).NotTo(ContainElement(HavePrefix("GetSubnet")))
+	})
+
+	It("Should require the IPs of all masters moved to the replacement subnet during preflight", func() {
+		mockClient.FakeGetSubnetResult = func() network.Subnet { return newTestSubnet("10.0.0.0/28", 9) }
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.UpgradeContainerService.Properties.MasterProfile.Count = 3
+		kmn.UpgradeContainerService.Properties.MasterProfile.VnetSubnetID = testSubnetID
+		kmn.ReplacementSubnetID = testSubnetID + "2"
+
+		Expect(kmn.requiredSubnetIPs()).To(Equal(3))
+		err := kmn.Preflight(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("has 2 free IP addresses, 3 required"))
+
+		kmn.UpgradeContainerService.Properties.MasterProfile.IPAddressCount = 2
+		Expect(kmn.requiredSubnetIPs()).To(Equal(6))
+	})
+
+	It("Should check the replacement subnet during preflight", func() {
+		mockClient.FailGetSubnet = true
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.UpgradeContainerService.Properties.MasterProfile.VnetSubnetID = testSubnetID
+		kmn.ReplacementSubnetID = testSubnetID + "2"
+
+		err := kmn.Preflight(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("getting subnet " + testSubnetID + "2"))
+	})
+
+	It("Should use the custom VNET subnet of the master profile", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		clusterID := kmn.UpgradeContainerService.Properties.GetClusterID()
+		Expect(kmn.masterSubnetID()).To(Equal("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/virtualNetworks/k8s-vnet-" + clusterID + "/subnets/k8s-subnet"))
+
+		kmn.UpgradeContainerService.Properties.MasterProfile.VnetSubnetID = testSubnetID
+		Expect(kmn.masterSubnetID()).To(Equal(testSubnetID))
+	})
+
+	It("Should get the master subnet from the VNet resource group", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.VNetResourceGroup = "vnetrg"
+
+		Expect(kmn.masterSubnetID()).To(ContainSubstring("/resourceGroups/vnetrg/providers/Microsoft.Network/virtualNetworks/"))
+	})
+
+	It("Should default the VNet resource group to the one of the custom VNET subnet", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		Expect(kmn.vnetResourceGroup()).To(Equal("TestRg"))
+
+		kmn.UpgradeContainerService.Properties.MasterProfile.VnetSubnetID = testSubnetID
+		Expect(kmn.vnetResourceGroup()).To(Equal("vnetrg"))
+
+		kmn.VNetResourceGroup = "othervnetrg"
+		Expect(kmn.vnetResourceGroup()).To(Equal("othervnetrg"))
+	})
+})
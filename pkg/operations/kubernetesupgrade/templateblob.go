@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+)
+
+// DefaultTemplateBlobSASExpiryDuration is how long the SAS token of an uploaded template is valid
+// when TemplateBlobSASExpiryDuration is zero. ARM only reads the template when the deployment starts.
+const DefaultTemplateBlobSASExpiryDuration = time.Hour
+
+// TemplateBlobClient uploads ARM templates to Azure Storage so that they can be deployed by URI
+type TemplateBlobClient interface {
+	// UploadTemplate uploads template as blobName of the container at containerURL and returns the URI of the blob
+	// including a read-only SAS token valid for expiry
+	UploadTemplate(ctx context.Context, containerURL url.URL, blobName string, template []byte, expiry time.Duration) (string, error)
+}
+
+// Compiler to verify AzureTemplateBlobClient implements TemplateBlobClient
+var _ TemplateBlobClient = &AzureTemplateBlobClient{}
+
+// AzureTemplateBlobClient is a TemplateBlobClient authenticating with a storage account key, which also signs the SAS tokens
+type AzureTemplateBlobClient struct {
+	Credential *azblob.SharedKeyCredential
+}
+
+// NewAzureTemplateBlobClient returns an AzureTemplateBlobClient for the storage account
+func NewAzureTemplateBlobClient(accountName, accountKey string) (*AzureTemplateBlobClient, error) {
+	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating the credential of storage account %s", accountName)
+	}
+	return &AzureTemplateBlobClient{Credential: credential}, nil
+}
+
+// UploadTemplate uploads template as blobName of the container at containerURL and returns the URI of the blob
+// including a read-only SAS token valid for expiry
+func (c *AzureTemplateBlobClient) UploadTemplate(ctx context.Context, containerURL url.URL, blobName string, template []byte, expiry time.Duration) (string, error) {
+	p := azblob.NewPipeline(c.Credential, azblob.PipelineOptions{})
+	blob := azblob.NewContainerURL(containerURL, p).NewBlockBlobURL(blobName)
+	if _, err := azblob.UploadBufferToBlockBlob(ctx, template, blob, azblob.UploadToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: "application/json"},
+	}); err != nil {
+		return "", errors.Wrapf(err, "uploading template to blob %s", blobName)
+	}
+	parts := azblob.NewBlobURLParts(blob.URL())
+	sas, err := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		ExpiryTime:    time.Now().UTC().Add(expiry),
+		ContainerName: parts.ContainerName,
+		BlobName:      parts.BlobName,
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+	}.NewSASQueryParameters(c.Credential)
+	if err != nil {
+		return "", errors.Wrapf(err, "signing the SAS token of blob %s", blobName)
+	}
+	parts.SAS = sas
+	u := parts.URL()
+	return u.String(), nil
+}
+
+// validateTemplateBlob ensures the templates can be uploaded to TemplateBlobURI
+func (kmn *UpgradeMasterNode) validateTemplateBlob() error {
+	if kmn.TemplateBlobURI == "" {
+		return nil
+	}
+	if kmn.TemplateBlobClient == nil {
+		return errors.New("a template blob client is required to deploy the template from a blob")
+	}
+	u, err := url.Parse(kmn.TemplateBlobURI)
+	if err != nil {
+		return errors.Wrapf(err, "parsing template blob URI %s", kmn.TemplateBlobURI)
+	}
+	if u.Scheme != "https" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return errors.Errorf("invalid template blob URI %s, expected the https URL of a blob container", kmn.TemplateBlobURI)
+	}
+	if kmn.TemplateBlobSASExpiryDuration < 0 {
+		return errors.New("the template blob SAS expiry duration must not be negative")
+	}
+	return nil
+}
+
+// uploadTemplate uploads TemplateMap to TemplateBlobURI as <deploymentName>.json and returns the URI to deploy it from
+func (kmn *UpgradeMasterNode) uploadTemplate(ctx context.Context, deploymentName string) (string, error) {
+	if err := kmn.validateTemplateBlob(); err != nil {
+		return "", err
+	}
+	containerURL, _ := url.Parse(kmn.TemplateBlobURI)
+	template, err := json.Marshal(kmn.TemplateMap)
+	if err != nil {
+		return "", errors.Wrap(err, "serializing the ARM template")
+	}
+	expiry := kmn.TemplateBlobSASExpiryDuration
+	if expiry == 0 {
+		expiry = DefaultTemplateBlobSASExpiryDuration
+	}
+	blobName := deploymentName + ".json"
+	templateURI, err := kmn.TemplateBlobClient.UploadTemplate(ctx, *containerURL, blobName, template, expiry)
+	if err != nil {
+		return "", errors.Wrapf(err, "uploading the template of deployment %s", deploymentName)
+	}
+	kmn.logger.Infof("Uploaded the template of deployment %s to blob %s of %s", deploymentName, blobName, containerURL.Host)
+	return templateURI, nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const testTemplateBlobURI = "https://account.blob.core.windows.net/templates"
+
+type fakeTemplateBlobClient struct {
+	containerURL url.URL
+	blobName     string
+	template     []byte
+	expiry       time.Duration
+	err          error
+}
+
+func (c *fakeTemplateBlobClient) UploadTemplate(ctx context.Context, containerURL url.URL, blobName string, template []byte, expiry time.Duration) (string, error) {
+	c.containerURL, c.blobName, c.template, c.expiry = containerURL, blobName, template, expiry
+	if c.err != nil {
+		return "", c.err
+	}
+	return containerURL.String() + "/" + blobName + "?sig=fake", nil
+}
+
+var _ = Describe("Template blob tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		blobClient *fakeTemplateBlobClient
+		kmn        *UpgradeMasterNode
+	)
+
+	BeforeEach(func() {
+		mockClient = &armhelpers.MockAKSEngineClient{}
+		blobClient = &fakeTemplateBlobClient{}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		kmn.TemplateBlobURI = testTemplateBlobURI
+		kmn.TemplateBlobClient = blobClient
+	})
+
+	It("Should deploy the uploaded template by URI", func() {
+		kmn.ExistingDeploymentName = "k8s-upgrade-master"
+
+		Expect(kmn.Preflight(context.Background())).To(Succeed())
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(blobClient.containerURL.String()).To(Equal(testTemplateBlobURI))
+		Expect(blobClient.blobName).To(Equal("k8s-upgrade-master.json"))
+		Expect(blobClient.expiry).To(Equal(DefaultTemplateBlobSASExpiryDuration))
+		var uploaded map[string]interface{}
+		Expect(json.Unmarshal(blobClient.template, &uploaded)).To(Succeed())
+		Expect(uploaded["variables"]).To(HaveKeyWithValue("masterCount", BeNumerically("==", 1)))
+		Expect(mockClient.TemplateLinkURIs).To(Equal([]string{testTemplateBlobURI + "/k8s-upgrade-master.json?sig=fake"}))
+	})
+
+	It("Should deploy the uploaded template by URI when polling the deployment", func() {
+		kmn.DeploymentPollInterval = time.Millisecond
+		kmn.TemplateBlobSASExpiryDuration = 10 * time.Minute
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(blobClient.expiry).To(Equal(10 * time.Minute))
+		Expect(mockClient.TemplateLinkURIs).To(HaveLen(1))
+	})
+
+	It("Should not deploy when the template cannot be uploaded", func() {
+		blobClient.err = errors.New("forbidden")
+
+		err := kmn.CreateNode(context.Background(), "master", 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("uploading the template of deployment"))
+		Expect(mockClient.TemplateLinkURIs).To(BeEmpty())
+		Expect(mockClient.DeploymentModes).To(BeEmpty())
+	})
+
+	It("Should deploy the template inline without TemplateBlobURI", func() {
+		kmn.TemplateBlobURI = ""
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(blobClient.blobName).To(BeEmpty())
+		Expect(mockClient.TemplateLinkURIs).To(BeEmpty())
+	})
+
+	It("Should fail preflight for an invalid configuration", func() {
+		kmn.TemplateBlobClient = nil
+		Expect(kmn.Preflight(context.Background())).To(MatchError("a template blob client is required to deploy the template from a blob"))
+
+		kmn.TemplateBlobClient = blobClient
+		kmn.TemplateBlobURI = "http://account.blob.core.windows.net/templates"
+		Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring("expected the https URL of a blob container")))
+
+		kmn.TemplateBlobURI = testTemplateBlobURI
+		kmn.TemplateBlobSASExpiryDuration = -time.Minute
+		Expect(kmn.Preflight(context.Background())).To(MatchError("the template blob SAS expiry duration must not be negative"))
+	})
+
+	It("Should upload the template and return a read-only SAS URI", func() {
+		var method, path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+		containerURL, err := url.Parse(strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/templates")
+		Expect(err).NotTo(HaveOccurred())
+
+		client, err := NewAzureTemplateBlobClient("account", base64.StdEncoding.EncodeToString([]byte("key")))
+		Expect(err).NotTo(HaveOccurred())
+		templateURI, err := client.UploadTemplate(context.Background(), *containerURL, "k8s-upgrade-master.json", []byte("{}"), time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(method).To(Equal(http.MethodPut))
+		Expect(path).To(Equal("/templates/k8s-upgrade-master.json"))
+		u, err := url.Parse(templateURI)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(u.Path).To(Equal("/templates/k8s-upgrade-master.json"))
+		Expect(u.Query().Get("sp")).To(Equal("r"))
+		Expect(u.Query().Get("spr")).To(Equal("https"))
+		Expect(u.Query().Get("sig")).NotTo(BeEmpty())
+	})
+})
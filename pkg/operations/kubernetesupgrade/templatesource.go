@@ -0,0 +1,162 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTemplateFileName and DefaultParametersFileName are the names of the ARM template
+	// and parameters files written by aks-engine generate and deploy
+	DefaultTemplateFileName   = "azuredeploy.json"
+	DefaultParametersFileName = "azuredeploy.parameters.json"
+
+	storageResource = "https://storage.azure.com/"
+)
+
+// TemplateSource provides the ARM template and parameters deployed to upgrade the cluster nodes
+// instead of the ones generated from the api model
+type TemplateSource interface {
+	// Load returns the ARM template and parameters
+	Load(ctx context.Context) (map[string]interface{}, map[string]interface{}, error)
+}
+
+// Compiler to verify LocalFileTemplateSource and AzureBlobTemplateSource implement TemplateSource
+var _ TemplateSource = &LocalFileTemplateSource{}
+var _ TemplateSource = &AzureBlobTemplateSource{}
+
+// LocalFileTemplateSource is a TemplateSource reading the ARM template and parameters from a local directory,
+// e.g. the output directory of aks-engine generate
+type LocalFileTemplateSource struct {
+	Dir string
+	// TemplateFileName and ParametersFileName default to DefaultTemplateFileName and DefaultParametersFileName
+	TemplateFileName   string
+	ParametersFileName string
+}
+
+// Load reads the ARM template and parameters files
+func (s *LocalFileTemplateSource) Load(ctx context.Context) (map[string]interface{}, map[string]interface{}, error) {
+	templateFileName, parametersFileName := templateFileNames(s.TemplateFileName, s.ParametersFileName)
+	templateJSON, err := ioutil.ReadFile(filepath.Join(s.Dir, templateFileName))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "reading the ARM template")
+	}
+	parametersJSON, err := ioutil.ReadFile(filepath.Join(s.Dir, parametersFileName))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "reading the ARM parameters")
+	}
+	return parseTemplate(templateJSON, parametersJSON)
+}
+
+// AzureBlobTemplateSource is a TemplateSource downloading the ARM template and parameters from an Azure Storage container
+type AzureBlobTemplateSource struct {
+	// ContainerURL is the URL of the blob container, including a SAS token granting read access
+	// unless UseManagedIdentity is set, e.g. https://account.blob.core.windows.net/templates?sv=...
+	ContainerURL url.URL
+	// TemplateBlobName and ParametersBlobName default to DefaultTemplateFileName and DefaultParametersFileName
+	TemplateBlobName   string
+	ParametersBlobName string
+	// UseManagedIdentity authenticates with the managed identity of the VM running the upgrade,
+	// the user-assigned identity ManagedIdentityClientID if set
+	UseManagedIdentity      bool
+	ManagedIdentityClientID string
+}
+
+// Load downloads the ARM template and parameters blobs
+func (s *AzureBlobTemplateSource) Load(ctx context.Context) (map[string]interface{}, map[string]interface{}, error) {
+	credential, err := s.credential(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	container := azblob.NewContainerURL(s.ContainerURL, azblob.NewPipeline(credential, azblob.PipelineOptions{}))
+	templateBlobName, parametersBlobName := templateFileNames(s.TemplateBlobName, s.ParametersBlobName)
+	templateJSON, err := downloadBlob(ctx, container, templateBlobName)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "downloading the ARM template")
+	}
+	parametersJSON, err := downloadBlob(ctx, container, parametersBlobName)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "downloading the ARM parameters")
+	}
+	return parseTemplate(templateJSON, parametersJSON)
+}
+
+// credential returns a managed identity token credential if UseManagedIdentity is set,
+// otherwise an anonymous credential relying on the SAS token of ContainerURL
+func (s *AzureBlobTemplateSource) credential(ctx context.Context) (azblob.Credential, error) {
+	if !s.UseManagedIdentity {
+		return azblob.NewAnonymousCredential(), nil
+	}
+	endpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the managed identity endpoint")
+	}
+	var spt *adal.ServicePrincipalToken
+	if s.ManagedIdentityClientID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(endpoint, storageResource, s.ManagedIdentityClientID)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(endpoint, storageResource)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "creating a managed identity token")
+	}
+	if err = spt.EnsureFreshWithContext(ctx); err != nil {
+		return nil, errors.Wrap(err, "getting a managed identity token")
+	}
+	// the template is downloaded within the token lifetime, no refresh needed
+	return azblob.NewTokenCredential(spt.OAuthToken(), nil), nil
+}
+
+func downloadBlob(ctx context.Context, container azblob.ContainerURL, name string) ([]byte, error) {
+	resp, err := container.NewBlobURL(name).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading blob %s", name)
+	}
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading blob %s", name)
+	}
+	return b, nil
+}
+
+func templateFileNames(templateFileName, parametersFileName string) (string, string) {
+	if templateFileName == "" {
+		templateFileName = DefaultTemplateFileName
+	}
+	if parametersFileName == "" {
+		parametersFileName = DefaultParametersFileName
+	}
+	return templateFileName, parametersFileName
+}
+
+// parseTemplate unmarshals an ARM template and parameters, the parameters may be a deployment
+// parameters file, as written by aks-engine generate, or the parameters themselves
+func parseTemplate(templateJSON, parametersJSON []byte) (map[string]interface{}, map[string]interface{}, error) {
+	var templateMap, parametersMap map[string]interface{}
+	if err := json.Unmarshal(templateJSON, &templateMap); err != nil {
+		return nil, nil, errors.Wrap(err, "unmarshaling the ARM template JSON")
+	}
+	if err := json.Unmarshal(parametersJSON, &parametersMap); err != nil {
+		return nil, nil, errors.Wrap(err, "unmarshaling the ARM parameters JSON")
+	}
+	if _, ok := parametersMap["contentVersion"]; ok {
+		parameters, _ := parametersMap["parameters"].(map[string]interface{})
+		parametersMap = parameters
+	}
+	if _, ok := templateMap["resources"].([]interface{}); !ok {
+		return nil, nil, errors.New("invalid ARM template, expected a resources array")
+	}
+	return templateMap, parametersMap, nil
+}
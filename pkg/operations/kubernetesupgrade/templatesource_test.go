@@ -0,0 +1,111 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const (
+	testTemplateJSON   = `{"resources": [{"type": "Microsoft.Compute/virtualMachines", "name": "master"}]}`
+	testParametersJSON = `{"$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentParameters.json#", "contentVersion": "1.0.0.0", "parameters": {"masterVMSize": {"value": "Standard_D2_v2"}}}`
+)
+
+type fakeTemplateSource struct {
+	templateMap, parametersMap map[string]interface{}
+	err                        error
+}
+
+func (s *fakeTemplateSource) Load(ctx context.Context) (map[string]interface{}, map[string]interface{}, error) {
+	return s.templateMap, s.parametersMap, s.err
+}
+
+var _ = Describe("Template source tests", func() {
+	It("Should read the template and parameters from a local directory", func() {
+		dir, err := ioutil.TempDir("", "templatesource")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(ioutil.WriteFile(filepath.Join(dir, DefaultTemplateFileName), []byte(testTemplateJSON), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, DefaultParametersFileName), []byte(testParametersJSON), 0644)).To(Succeed())
+
+		templateMap, parametersMap, err := (&LocalFileTemplateSource{Dir: dir}).Load(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templateMap["resources"]).To(HaveLen(1))
+		Expect(parametersMap).To(Equal(map[string]interface{}{"masterVMSize": map[string]interface{}{"value": "Standard_D2_v2"}}))
+
+		_, _, err = (&LocalFileTemplateSource{Dir: dir, ParametersFileName: "missing.json"}).Load(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("reading the ARM parameters"))
+	})
+
+	It("Should reject a document that is not an ARM template", func() {
+		dir, err := ioutil.TempDir("", "templatesource")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(ioutil.WriteFile(filepath.Join(dir, DefaultTemplateFileName), []byte(`{"apiVersion": "vlabs"}`), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, DefaultParametersFileName), []byte(testParametersJSON), 0644)).To(Succeed())
+
+		_, _, err = (&LocalFileTemplateSource{Dir: dir}).Load(context.Background())
+		Expect(err).To(MatchError("invalid ARM template, expected a resources array"))
+	})
+
+	It("Should download the template and parameters from a blob container with a SAS token", func() {
+		var paths []string
+		var query url.Values
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths, query = append(paths, r.URL.Path), r.URL.Query()
+			switch r.URL.Path {
+			case "/templates/" + DefaultTemplateFileName:
+				_, _ = w.Write([]byte(testTemplateJSON))
+			case "/templates/" + DefaultParametersFileName:
+				_, _ = w.Write([]byte(testParametersJSON))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		containerURL, err := url.Parse(server.URL + "/templates?sv=2019-02-02&sig=secret")
+		Expect(err).NotTo(HaveOccurred())
+
+		templateMap, parametersMap, err := (&AzureBlobTemplateSource{ContainerURL: *containerURL}).Load(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([]string{"/templates/azuredeploy.json", "/templates/azuredeploy.parameters.json"}))
+		Expect(query.Get("sig")).To(Equal("secret"))
+		Expect(templateMap["resources"]).To(HaveLen(1))
+		Expect(parametersMap).To(HaveKey("masterVMSize"))
+
+		_, _, err = (&AzureBlobTemplateSource{ContainerURL: *containerURL, TemplateBlobName: "missing.json"}).Load(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("downloading the ARM template"))
+	})
+
+	It("Should upgrade with the template of the TemplateSource", func() {
+		u := newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+		source := &fakeTemplateSource{
+			templateMap:   map[string]interface{}{"resources": []interface{}{}},
+			parametersMap: map[string]interface{}{"masterVMSize": map[string]interface{}{"value": "Standard_D2_v2"}},
+		}
+		u.TemplateSource = source
+
+		templateMap, parametersMap, err := u.generateUpgradeTemplate(context.Background(), u.DataModel, TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templateMap).To(Equal(source.templateMap))
+		Expect(parametersMap).To(Equal(source.parametersMap))
+
+		source.err = errors.New("Load failed")
+		_, _, err = u.generateUpgradeTemplate(context.Background(), u.DataModel, TestAKSEngineVersion)
+		Expect(err).To(MatchError("error loading upgrade template: Load failed"))
+	})
+})
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// ultraSSDCapability is the resource SKU capability set to "True" where a VM size supports ultra disks
+const ultraSSDCapability = "UltraSSDAvailable"
+
+// validateUltraDisk ensures the master VM size supports ultra disks in the cluster location, and in zone if
+// the VM is created in one. An empty zone only requires the VM size to support ultra disks in some zone.
+func (kmn *UpgradeMasterNode) validateUltraDisk(ctx context.Context, zone string) error {
+	location := kmn.UpgradeContainerService.Location
+	vmSize := kmn.UpgradeContainerService.Properties.MasterProfile.VMSize
+	page, err := kmn.Client.ListResourceSkus(ctx, fmt.Sprintf("location eq '%s'", location))
+	if err != nil {
+		return errors.Wrap(err, "listing resource SKUs")
+	}
+	var zones []string
+	for page != nil && page.NotDone() {
+		for _, sku := range page.Values() {
+			if !strings.EqualFold(to.String(sku.Name), vmSize) || !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") {
+				continue
+			}
+			if sku.Capabilities != nil && hasSkuCapability(*sku.Capabilities, ultraSSDCapability) {
+				return nil
+			}
+			if sku.LocationInfo == nil {
+				continue
+			}
+			for _, info := range *sku.LocationInfo {
+				if !strings.EqualFold(to.String(info.Location), location) || info.ZoneDetails == nil {
+					continue
+				}
+				for _, details := range *info.ZoneDetails {
+					if details.Name != nil && details.Capabilities != nil && hasSkuCapability(*details.Capabilities, ultraSSDCapability) {
+						zones = append(zones, *details.Name...)
+					}
+				}
+			}
+		}
+		if err = page.NextWithContext(ctx); err != nil {
+			return errors.Wrap(err, "listing resource SKUs")
+		}
+	}
+	if zone == "" {
+		if len(zones) > 0 {
+			return nil
+		}
+		return errors.Errorf("ultra disks are not supported by VM size %s in location %s", vmSize, location)
+	}
+	for _, z := range zones {
+		if z == zone {
+			return nil
+		}
+	}
+	return errors.Errorf("ultra disks are not supported by VM size %s in availability zone %q of location %s, supported zones: %v", vmSize, zone, location, zones)
+}
+
+// hasSkuCapability returns true if the boolean capability name is set to "True"
+func hasSkuCapability(capabilities []compute.ResourceSkuCapabilities, name string) bool {
+	for _, c := range capabilities {
+		if strings.EqualFold(to.String(c.Name), name) && strings.EqualFold(to.String(c.Value), "True") {
+			return true
+		}
+	}
+	return false
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
 	"github.com/Azure/aks-engine/pkg/i18n"
 	"github.com/Azure/aks-engine/pkg/kubernetes"
 	"github.com/Azure/aks-engine/pkg/operations"
@@ -43,6 +44,43 @@ type UpgradeAgentNode struct {
 	kubeConfig              string
 	timeout                 time.Duration
 	cordonDrainTimeout      time.Duration
+	drainGracePeriod        time.Duration
+	// DrainMaxEvictionErrors is the number of pods failing to be evicted that are logged and left behind
+	// while draining the node, the drain is aborted when more pods fail
+	DrainMaxEvictionErrors int
+	// MinFreeCapacityPercent is the share of CPU and memory that must remain free after draining a node
+	MinFreeCapacityPercent float64
+	// GPUExtensionVersion is the NVIDIA GPU driver extension version installed on new GPU nodes, disabled if empty
+	GPUExtensionVersion string
+	// GPUSKUPatternList lists the VM size patterns treated as GPU SKUs, defaults to DefaultGPUSKUPatternList
+	GPUSKUPatternList []string
+	// drainDuration is how long the most recent DeleteNode spent draining
+	drainDuration time.Duration
+	// IsVMSS is set when the node is a VMSS instance, upgraded in place instead of replaced by a new VM,
+	// see Upgrader.UpdateScaleSetInstances
+	IsVMSS bool
+	// ScaleSetName is the name of the VMSS the node belongs to when IsVMSS is set
+	ScaleSetName string
+	// InstanceIDs are the VMSS instances updated to the latest scale set model by CreateNode when IsVMSS is set
+	InstanceIDs []string
+	// PreDrainHook is a command and its arguments run on the node before it is drained
+	PreDrainHook []string
+	// AbortOnPreDrainHookFailure stops the node upgrade when PreDrainHook fails, defaults to DefaultAbortOnPreDrainHookFailure
+	AbortOnPreDrainHookFailure *bool
+	// PostCreateTaintEviction makes Validate taint the new node with UpgradingTaintKey as soon as it registers,
+	// and remove the taint once the node is ready, so that only pods tolerating it land on the node meanwhile
+	PostCreateTaintEviction bool
+	// DiskAttachmentTimeout is how long WaitForDiskAttachment waits for the data disks of the node to be attached
+	DiskAttachmentTimeout time.Duration
+	// InPlaceKubeletUpgrade makes UpgradeKubeletInPlace replace the kubelet binary of the node over SSH
+	// instead of replacing the node by a new VM
+	InPlaceKubeletUpgrade bool
+	// SSHPrivateKeyPath is the private key used to connect to the node when InPlaceKubeletUpgrade is set
+	SSHPrivateKeyPath string
+	// executeRemote runs the kubelet upgrade script on the node, over SSH if nil
+	executeRemote func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error)
+	// validateOnly makes Validate return an error for a node not ready within timeout instead of deleting it
+	validateOnly bool
 }
 
 // DeleteNode takes state/resources of the master/agent node from ListNodeResources
@@ -63,15 +101,29 @@ func (kan *UpgradeAgentNode) DeleteNode(vmName *string, drain bool) error {
 		return err
 	}
 	// Cordon and drain the node
+	kan.drainDuration = 0
 	if drain {
-		err = operations.SafelyDrainNodeWithClient(client, kan.logger, nodeName, kan.cordonDrainTimeout)
+		if err = kan.runPreDrainHook(client, *vmName); err != nil {
+			if kan.abortOnPreDrainHookFailure() {
+				return err
+			}
+			kan.logger.Warningf("Pre-drain hook failed on agent VM %s. Proceeding with drain. Error: %v", *vmName, err)
+		}
+		drainStart := time.Now()
+		err = operations.SafelyDrainNodeWithMaxEvictionErrors(client, kan.logger, nodeName, kan.cordonDrainTimeout, kan.drainGracePeriod, kan.DrainMaxEvictionErrors)
+		kan.drainDuration = time.Since(drainStart)
 		if err != nil {
 			kan.logger.Warningf("Error draining agent VM %s. Proceeding with deletion. Error: %v", *vmName, err)
 			// Proceed with deletion anyways
 		}
 	}
 	// Delete VM in ARM
-	if err = operations.CleanDeleteVirtualMachine(kan.Client, kan.logger, kan.SubscriptionID, kan.ResourceGroup, *vmName); err != nil {
+	if kan.IsVMSS {
+		err = kan.deleteScaleSetVM(*vmName)
+	} else {
+		err = operations.CleanDeleteVirtualMachine(kan.Client, kan.logger, kan.SubscriptionID, kan.ResourceGroup, *vmName)
+	}
+	if err != nil {
 		return err
 	}
 	// Delete VM in api server
@@ -86,6 +138,10 @@ func (kan *UpgradeAgentNode) DeleteNode(vmName *string, drain bool) error {
 
 // CreateNode creates a new master/agent node with the targeted version of Kubernetes
 func (kan *UpgradeAgentNode) CreateNode(ctx context.Context, poolName string, agentNo int) error {
+	if kan.IsVMSS {
+		return kan.createScaleSetNode(ctx)
+	}
+
 	poolCountParameter := kan.ParametersMap[poolName+"Count"].(map[string]interface{})
 	poolCountParameter["value"] = agentNo + 1
 	agentCount := poolCountParameter["value"]
@@ -100,6 +156,10 @@ func (kan *UpgradeAgentNode) CreateNode(ctx context.Context, poolName string, ag
 	deploymentSuffix := random.Int31()
 	deploymentName := fmt.Sprintf("k8s-upgrade-%s-%d-%s-%d", poolName, agentNo, time.Now().Format("06-01-02T15.04.05"), deploymentSuffix)
 
+	if err := kan.customizeTemplate(poolName); err != nil {
+		return err
+	}
+
 	return armhelpers.DeployTemplateSync(kan.Client, kan.logger, kan.ResourceGroup, deploymentName, kan.TemplateMap, kan.ParametersMap)
 }
 
@@ -119,12 +179,16 @@ func (kan *UpgradeAgentNode) Validate(vmName *string) error {
 		return &armhelpers.DeploymentValidationError{Err: err}
 	}
 
+	tainted := false
 	retryTimer := time.NewTimer(time.Millisecond)
 	timeoutTimer := time.NewTimer(kan.timeout)
 	for {
 		select {
 		case <-timeoutTimer.C:
 			retryTimer.Stop()
+			if kan.validateOnly {
+				return &armhelpers.DeploymentValidationError{Err: kan.Translator.Errorf("Node was not ready within %v", kan.timeout)}
+			}
 			err := kan.DeleteNode(vmName, false)
 			if err != nil {
 				kan.logger.Errorf("Error deleting agent VM %s: %v", *vmName, err)
@@ -139,9 +203,21 @@ func (kan *UpgradeAgentNode) Validate(vmName *string) error {
 			} else if kubernetes.IsNodeReady(agentNode) {
 				kan.logger.Infof("Agent node: %s is ready", nodeName)
 				timeoutTimer.Stop()
+				if tainted {
+					if err = removeUpgradingTaint(client, nodeName); err != nil {
+						return &armhelpers.DeploymentValidationError{Err: err}
+					}
+				}
 				return nil
 			} else {
 				kan.logger.Infof("Agent node: %s not ready yet...", nodeName)
+				if kan.PostCreateTaintEviction && !tainted {
+					if err = addUpgradingTaint(client, agentNode); err != nil {
+						kan.logger.Warnf("Error tainting agent node %s until it is ready: %v", nodeName, err)
+					} else {
+						tainted = true
+					}
+				}
 				retryTimer.Reset(retry)
 			}
 		}
@@ -6,7 +6,9 @@ package kubernetesupgrade
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/url"
 	"strings"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	util "k8s.io/client-go/util/retry"
@@ -56,6 +59,16 @@ type AgentPoolScaleSet struct {
 	VMsToUpgrade []AgentPoolScaleSetVM
 }
 
+// poolName returns the name of the agent pool the VMSS belongs to
+func (vmss *AgentPoolScaleSet) poolName() string {
+	if vmss.IsWindows {
+		poolName, _ := utils.WindowsVmssNameParts(vmss.Name)
+		return poolName
+	}
+	poolName, _, _ := utils.VmssNameParts(vmss.Name)
+	return poolName
+}
+
 // AgentPoolScaleSetVM represents a VM in a VMSS
 type AgentPoolScaleSetVM struct {
 	Name       string
@@ -83,6 +96,259 @@ type UpgradeCluster struct {
 	Force              bool
 	ControlPlaneOnly   bool
 	CurrentVersion     string
+	// ProximityPlacementGroupID places upgraded master VMs in the given proximity placement group
+	ProximityPlacementGroupID string
+	// ResourceTags are set on the VM, NIC and disks of the upgraded master VMs
+	ResourceTags map[string]string
+	// VNetResourceGroup is the resource group of the cluster virtual network when it differs from the cluster resource group
+	VNetResourceGroup string
+	// APIModelVersion is the schema version of the loaded api model
+	APIModelVersion string
+	// RequiredSchemaVersion fails the master upgrade preflight unless APIModelVersion is the given schema version
+	RequiredSchemaVersion string
+	// ReplacementSubnetID attaches the NICs of upgraded master VMs to the given subnet of the cluster virtual network
+	ReplacementSubnetID string
+	// VNetPeeringID attaches the NICs of upgraded master VMs to the remote virtual network of the given peering
+	VNetPeeringID string
+	// CorrelationID is the correlation ID of the master VM deployments, a UUID is generated if empty
+	CorrelationID string
+	// VMAPIVersion overrides the ARM API version of the upgraded master VM resources
+	VMAPIVersion string
+	// Reporters optionally receive an event after each node is upgraded, e.g. an AzureMonitorReporter
+	Reporters []UpgradeReporter
+	// PostDeleteWait is how long to wait between deleting and recreating a master VM, defaults to 10 seconds
+	PostDeleteWait *time.Duration
+	// MaintenanceConfigurationID is assigned to each upgraded master VM through MaintenanceClient
+	MaintenanceConfigurationID string
+	MaintenanceClient          MaintenanceClient
+	// PolicyExemptions are created through PolicyExemptionClient while each upgraded master VM is deployed
+	PolicyExemptions      []PolicyExemption
+	PolicyExemptionClient PolicyExemptionClient
+	// PrivateEndpointConfig describes the private endpoint of the API server provisioned through PrivateEndpointClient
+	// once each upgraded master VM is created
+	PrivateEndpointConfig *PrivateEndpointConfig
+	PrivateEndpointClient PrivateEndpointClient
+	// SkipCapacityCheck disables the capacity preflight check run before draining each agent node
+	SkipCapacityCheck bool
+	// MinFreeCapacityPercent is the share of CPU and memory that must remain free after draining an agent node
+	MinFreeCapacityPercent float64
+	// GPUExtensionVersion is the NVIDIA GPU driver extension version installed on new GPU agent nodes
+	GPUExtensionVersion string
+	// GPUSKUPatternList lists the VM size patterns treated as GPU SKUs
+	GPUSKUPatternList []string
+	// DrainGracePeriod is the termination grace period of the pods evicted from agent nodes,
+	// pods use their own if zero
+	DrainGracePeriod time.Duration
+	// DrainMaxEvictionErrors is the number of pod eviction errors ignored while draining an agent node
+	// before the drain is aborted
+	DrainMaxEvictionErrors int
+	// PoolUpgradeConfigs holds per agent pool settings overriding the global ones, keyed by pool name
+	PoolUpgradeConfigs map[string]PoolUpgradeConfig
+	// DedicatedHostGroupID places upgraded master VMs on the hosts of the given dedicated host group
+	DedicatedHostGroupID string
+	// PreUpgradeHook is a command and its arguments run before any node is upgraded, the upgrade aborts if it fails
+	PreUpgradeHook []string
+	// PostUpgradeHook is a command and its arguments run after all nodes are upgraded
+	PostUpgradeHook []string
+	// EmitKubernetesEvents records the upgrade events as Kubernetes events of the kube-system namespace
+	EmitKubernetesEvents bool
+	// PreDrainHook is a command and its arguments run on each availability set agent node before it is drained
+	PreDrainHook []string
+	// AbortOnPreDrainHookFailure stops the node upgrade when PreDrainHook fails, defaults to DefaultAbortOnPreDrainHookFailure
+	AbortOnPreDrainHookFailure *bool
+	// PostCreateTaintEviction taints the new availability set agent nodes with UpgradingTaintKey until they are ready
+	PostCreateTaintEviction bool
+	// OSOnlyUpgrade recreates all nodes on the OS image of this aks-engine version
+	// while keeping their current Kubernetes version
+	OSOnlyUpgrade bool
+	// ValidateExisting only validates that every existing node is ready, without upgrading or changing any node
+	ValidateExisting bool
+	// GenerateTemplateOnly only writes the ARM template and parameters the master VMs would be deployed with to
+	// TemplateOutputDirectory, the upgrade sub-directory of OutputDirectory if empty, without deploying them
+	GenerateTemplateOnly    bool
+	TemplateOutputDirectory string
+	// Operator identifies who runs the upgrade in the aks-engine-upgrade-history config map
+	Operator string
+	// PrivateDNSSuffix overrides the master FQDN suffix used to reach the API server of private clusters
+	PrivateDNSSuffix string
+	// NodeJoinTimeout is how long to wait for an upgraded master node to register with the API server, no limit if zero
+	NodeJoinTimeout time.Duration
+	// WaitForDNSPropagation waits for the hostname of each upgraded master VM to resolve before validating it,
+	// see UpgradeMasterNode.WaitForDNSResolution
+	WaitForDNSPropagation bool
+	DNSPropagationTimeout time.Duration
+	DNSResolverAddress    string
+	DNSQueryTimeout       time.Duration
+	// ExistingDeploymentName is used as the name of the master VM deployments instead of a generated one
+	ExistingDeploymentName string
+	// CloudInitScript is the base64-encoded cloud-init script replacing the custom data of the upgraded master VMs
+	CloudInitScript string
+	// RegenerateCloudConfig regenerates the cloud provider config of the upgraded masters from the api model,
+	// resource group and subscription of the upgrade, e.g. after the resource group or virtual network changed
+	RegenerateCloudConfig bool
+	// ForceImageUpdate recreates all nodes on the latest version of their marketplace OS image,
+	// even if their Kubernetes version and OS image are unchanged
+	ForceImageUpdate bool
+	// OSProfile overrides the OS profile of the upgraded master VMs, reading secrets through SecretResolver
+	OSProfile      *OSProfileConfig
+	SecretResolver SecretResolver
+	// UltraDiskEnabled enables ultra disk compatibility on the upgraded master VMs
+	UltraDiskEnabled bool
+	// OSDiskStorageAccountType is the managed disk type of the OS disk of the upgraded master VMs,
+	// Premium_LRS, StandardSSD_LRS or Standard_LRS; empty keeps the disk type of the template
+	OSDiskStorageAccountType string
+	// OSDiskCachingMode is the caching mode of the OS disk of the upgraded master VMs, None, ReadOnly or ReadWrite;
+	// empty keeps the caching mode of the template
+	OSDiskCachingMode string
+	// DiskEncryptionSetID is the resource ID of the disk encryption set the OS disks of the upgraded master VMs
+	// are encrypted with; empty keeps the template
+	DiskEncryptionSetID string
+	// BootDiagnosticsEnabled enables the boot diagnostics of the upgraded master VMs, stored in the storage account
+	// of BootDiagnosticsStorageAccountURI, or in a managed storage account if empty
+	BootDiagnosticsEnabled           bool
+	BootDiagnosticsStorageAccountURI string
+	// VMPriority is the priority of the upgraded master VMs, Regular, Spot or Low; empty keeps the priority
+	// of the template. EvictionPolicy is the eviction policy of Spot master VMs, Deallocate or Delete.
+	VMPriority     string
+	EvictionPolicy string
+	// AcceleratedNetworking enables Accelerated Networking on the NICs of the upgraded master VMs
+	AcceleratedNetworking bool
+	// ApplicationSecurityGroups are the resource IDs of application security groups the NICs of the upgraded
+	// master VMs are added to
+	ApplicationSecurityGroups []string
+	// ExpectedOutputs maps the outputs of each master VM deployment to their expected value, or a regular expression
+	// enclosed in slashes
+	ExpectedOutputs map[string]string
+	// UpdateKubeconfigInKeyVault stores the kubeconfig in the Key Vault secret KeyVaultID through SecretStore
+	// once all masters are upgraded
+	UpdateKubeconfigInKeyVault bool
+	KeyVaultID                 string
+	SecretStore                SecretStore
+	// SystemAssignedIdentity and UserAssignedIdentities are added to the identities of the upgraded master VMs
+	SystemAssignedIdentity bool
+	UserAssignedIdentities []string
+	// RoleAssignments are assigned to the system-assigned identity of each upgraded master VM
+	RoleAssignments []RoleAssignment
+	// StateSync saves the api model to a remote state store after each master VM is upgraded
+	StateSync StateSync
+	// EtcdBackupContainerURL is the URL of a blob container, including a SAS token granting write access,
+	// the etcd data of each master VM is uploaded to before the VM is deleted
+	EtcdBackupContainerURL *url.URL
+	// FallbackToDataDirectoryBackup also uploads an archive of the etcd data directory of master VMs without a data disk
+	FallbackToDataDirectoryBackup bool
+	// LiveEtcdMemberMigration removes the etcd member of each master VM from the etcd cluster before the VM is
+	// deleted and adds it back once the upgraded VM has joined, keeping the etcd quorum throughout the upgrade
+	LiveEtcdMemberMigration bool
+	// AttachEtcdDataDisk attaches a new etcd data disk of EtcdDataDiskSizeGB, the etcd disk size of the api model
+	// if zero, to the upgraded master VMs and mounts it over SSH
+	AttachEtcdDataDisk bool
+	EtcdDataDiskSizeGB int
+	// SSHPrivateKeyPath is the private key used to connect to the master VMs to back up or migrate etcd,
+	// and to the agent VMs to upgrade their kubelet in place
+	SSHPrivateKeyPath string
+	// IgnoreNodesWithPodLabelSelector skips the upgrade of the agent nodes running a pod matching this label selector,
+	// e.g. pods that cannot be evicted; the skipped nodes are reported on UpgradeCompletedEvent and must be upgraded manually
+	IgnoreNodesWithPodLabelSelector string
+	// SkipPools lists the agent pools excluded from the upgrade by name, their nodes keep their current version
+	SkipPools []string
+	// ScaleDownBeforeUpgrade deletes the first PoolUpgradeConfig.ValidationWorkers nodes of each availability set agent pool
+	// before its upgrade, instead of creating an extra node, and deletes the agent nodes without draining them once the pod
+	// disruption budgets allow it, for workloads tolerating less capacity but not pod eviction
+	ScaleDownBeforeUpgrade bool
+	// NodeOrderingStrategy sets the order in which the nodes of each agent pool are upgraded, e.g. OrderByPodCount;
+	// the nodes are upgraded in node index order if nil
+	NodeOrderingStrategy NodeOrderingStrategy
+	// InPlaceKubeletUpgrade replaces the kubelet binary of the nodes of the Linux availability set agent pools
+	// over SSH instead of replacing the nodes by new VMs, for patch release upgrades only
+	InPlaceKubeletUpgrade bool
+	// UpdateScaleSetInstances upgrades the nodes of the VMSS agent pools by updating their instances to the latest
+	// scale set model one at a time, instead of adding a new instance and deleting the old one
+	UpdateScaleSetInstances bool
+	// CheckCertificateSANs makes the upgrade fail when the API server certificate served after a master VM
+	// is replaced is not issued for the SANs expected from the api model
+	CheckCertificateSANs bool
+	// CheckContainerRuntime makes the upgrade fail when the container runtime of an upgraded agent node
+	// is not running or not compatible with the target Kubernetes version
+	CheckContainerRuntime bool
+	// DiskAttachmentTimeout makes the upgrade wait up to DiskAttachmentTimeout for the data disks of each new
+	// agent node of a pool with data disks to be attached, disabled if zero
+	DiskAttachmentTimeout time.Duration
+	// DeploymentPollInterval and MaxDeploymentPolls make the master VM deployments poll the deployment state,
+	// MaxDeploymentPolls zero polls until the deployment completes
+	DeploymentPollInterval time.Duration
+	MaxDeploymentPolls     int
+	// ExplicitDependencies maps master template resource names to resources they depend on, merged into
+	// their dependsOn arrays
+	ExplicitDependencies map[string][]string
+	// ReuseExistingDeployment waits for the running upgrade deployment of a master VM left by an interrupted
+	// upgrade instead of creating a new deployment
+	ReuseExistingDeployment bool
+	// ValidationWorkers is the number of new agent nodes validated at the same time when several nodes are created
+	// to make up the agent pool count, the nodes are validated one after the other if below two
+	ValidationWorkers int
+	// DeploymentMode is the ARM deployment mode of the master VM deployments, Incremental if empty.
+	// WARNING: Complete mode deletes the resources of the resource group that are not in the upgrade template.
+	DeploymentMode string
+	// WhatIfMode makes RunUpgrade log the changes the master VM deployments would make instead of upgrading the cluster,
+	// see UpgradeMasterNode.WhatIfMode
+	WhatIfMode bool
+	// TemplateBlobURI is the blob container the upgraded master VM templates are uploaded to through TemplateBlobClient
+	// and deployed from, with SAS tokens valid for TemplateBlobSASExpiryDuration
+	TemplateBlobURI               string
+	TemplateBlobSASExpiryDuration time.Duration
+	TemplateBlobClient            TemplateBlobClient
+	// GenerateUpgradeReport writes a Markdown report of the upgrade outcome to OutputDirectory once the upgrade
+	// completed or failed, uploaded to UpgradeReportBlobURI through TemplateBlobClient if set
+	GenerateUpgradeReport bool
+	UpgradeReportBlobURI  string
+	// VerifyCoreDNS makes the upgrade fail when kubernetes.default.svc.cluster.local does not resolve to the
+	// cluster IP of the kubernetes service after a master VM is upgraded
+	VerifyCoreDNS bool
+	// CoreDNSCheckImage is the image of the pod looking up the kubernetes service, DefaultCoreDNSCheckImage if empty
+	CoreDNSCheckImage string
+	// KubeletConfigPatch is merged into the kubelet-config config map once all nodes are upgraded
+	KubeletConfigPatch map[string]interface{}
+	// NodeShutdownGracePeriod is set as the graceful node shutdown grace period of the kubelet-config config map
+	// while each agent node is drained and upgraded, the previous value being restored afterwards; zero leaves it
+	NodeShutdownGracePeriod time.Duration
+	// MixedOSConcurrencyPolicy sets the order in which Linux and Windows agent nodes are upgraded
+	MixedOSConcurrencyPolicy MixedOSConcurrencyPolicy
+	// TemplateSource provides the ARM template and parameters of the upgrade instead of generating them from the api model
+	TemplateSource TemplateSource
+	// KubeConfig is the kubeconfig Verify uses to reach the API server
+	KubeConfig string
+	// VerifyOutput is where Verify writes its report, os.Stdout if nil
+	VerifyOutput io.Writer
+	// AutoAdjustResourceQuotas scales the resource quotas exceeding the cluster capacity once an agent pool changed VM size
+	AutoAdjustResourceQuotas bool
+	// AutoAdjustLimitRanges scales the limit range defaults exceeding the node capacity once an agent pool changed VM size
+	AutoAdjustLimitRanges bool
+	// CleanupAfterUpgrade deletes the upgrade deployments, the upgrade sub-directory of OutputDirectory
+	// and the orphaned NICs and disks of the cluster once the upgrade succeeded
+	CleanupAfterUpgrade bool
+	OutputDirectory     string
+	// NodeGroupSize stages the upgrade in groups of NodeGroupSize nodes, pausing after each group for
+	// NodeGroupPauseAfter, or until PauseCheckFile is created. The nodes are upgraded by pool name and node index.
+	NodeGroupSize       int
+	NodeGroupPauseAfter time.Duration
+	// PauseBetweenNodes pauses the upgrade before the next node when PauseCheckFile exists,
+	// until PauseCheckFile is created again
+	PauseBetweenNodes bool
+	PauseCheckFile    string
+	// CanaryUpgrade upgrades the first node of each pool as a canary, reporting CanaryCompletedEvent once it is upgraded.
+	// With CanaryPauseForConfirmation the upgrade then pauses until CanaryConfirm approves the canary, or,
+	// without CanaryConfirm, until the operator removes the CanaryResumeFile the upgrade writes,
+	// OutputDirectory/CanaryResumeFileName if empty
+	CanaryUpgrade              bool
+	CanaryPauseForConfirmation bool
+	CanaryConfirm              func(ctx context.Context, poolName, nodeName string) (bool, error)
+	CanaryResumeFile           string
+	// ConsecutiveFailureLimit is how many agent nodes in a row may fail to upgrade before the upgrade stops, defaults to 3;
+	// set it to 1 to stop at the first failed node
+	ConsecutiveFailureLimit int
+	// AutoScalerAwareDrain pauses a cluster-autoscaler deployment during the upgrade even if the api model
+	// does not enable the addon, e.g. when the autoscaler was deployed separately
+	AutoScalerAwareDrain bool
 }
 
 // MasterPoolName pool name
@@ -104,10 +370,32 @@ func (uc *UpgradeCluster) UpgradeCluster(az armhelpers.AKSEngineClient, kubeConf
 		kubeClient = k
 	}
 
+	if uc.OSOnlyUpgrade && uc.CurrentVersion != "" {
+		// the nodes are recreated on the new OS image at their current version
+		uc.DataModel.Properties.OrchestratorProfile.OrchestratorVersion = uc.CurrentVersion
+	}
+
+	uc.skipPools()
+
 	if err := uc.setNodesToUpgrade(kubeClient, uc.ResourceGroup); err != nil {
 		return uc.Translator.Errorf("Error while querying ARM for resources: %+v", err)
 	}
 
+	if uc.ValidateExisting {
+		uc.Logger.Info("Validating the existing nodes, no node is upgraded")
+		return uc.getUpgradeWorkflow(kubeConfig, aksEngineVersion).RunUpgrade()
+	}
+
+	if uc.GenerateTemplateOnly {
+		uc.Logger.Info("Generating the upgrade template, no node is upgraded")
+		return uc.getUpgradeWorkflow(kubeConfig, aksEngineVersion).RunUpgrade()
+	}
+
+	if uc.WhatIfMode {
+		uc.Logger.Info("Previewing the master VM deployments, no node is upgraded")
+		return uc.getUpgradeWorkflow(kubeConfig, aksEngineVersion).RunUpgrade()
+	}
+
 	if kubeClient != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Second)
 		defer cancel()
@@ -119,11 +407,13 @@ func (uc *UpgradeCluster) UpgradeCluster(az armhelpers.AKSEngineClient, kubeConf
 	}
 
 	kc := uc.DataModel.Properties.OrchestratorProfile.KubernetesConfig
-	if kc != nil && kc.IsClusterAutoscalerEnabled() && !uc.ControlPlaneOnly {
+	if (uc.AutoScalerAwareDrain || (kc != nil && kc.IsClusterAutoscalerEnabled())) && !uc.ControlPlaneOnly {
 		// pause the cluster-autoscaler before running upgrade and resume it afterward
 		uc.Logger.Info("Pausing cluster autoscaler, replica count: 0")
 		count, err := uc.SetClusterAutoscalerReplicaCount(kubeClient, 0)
-		if err != nil {
+		if apierrors.IsNotFound(err) {
+			uc.Logger.Warnf("Cluster autoscaler is not deployed, upgrading without pausing it")
+		} else if err != nil {
 			uc.Logger.Errorf("Failed to pause cluster-autoscaler: %v", err)
 			if !uc.Force {
 				return err
@@ -145,7 +435,11 @@ func (uc *UpgradeCluster) UpgradeCluster(az armhelpers.AKSEngineClient, kubeConf
 	if uc.ControlPlaneOnly {
 		what = "control plane nodes"
 	}
-	uc.Logger.Infof("Upgrading %s to Kubernetes version %s", what, upgradeVersion)
+	if uc.OSOnlyUpgrade {
+		uc.Logger.Infof("Upgrading the OS image of %s, keeping Kubernetes version %s", what, upgradeVersion)
+	} else {
+		uc.Logger.Infof("Upgrading %s to Kubernetes version %s", what, upgradeVersion)
+	}
 
 	if err := uc.getUpgradeWorkflow(kubeConfig, aksEngineVersion).RunUpgrade(); err != nil {
 		return err
@@ -169,6 +463,9 @@ func (uc *UpgradeCluster) SetClusterAutoscalerReplicaCount(kubeClient kubernetes
 	const namespace, name, retries = "kube-system", "cluster-autoscaler", 10
 	for attempt := 0; attempt < retries; attempt++ {
 		deployment, getErr := kubeClient.GetDeployment(namespace, name)
+		if apierrors.IsNotFound(getErr) {
+			return 0, getErr
+		}
 		err = getErr
 		if getErr == nil {
 			count = *deployment.Spec.Replicas
@@ -195,6 +492,117 @@ func (uc *UpgradeCluster) getUpgradeWorkflow(kubeConfig string, aksEngineVersion
 	u := &Upgrader{}
 	u.Init(uc.Translator, uc.Logger, uc.ClusterTopology, uc.Client, kubeConfig, uc.StepTimeout, uc.CordonDrainTimeout, aksEngineVersion, uc.ControlPlaneOnly)
 	u.CurrentVersion = uc.CurrentVersion
+	u.ProximityPlacementGroupID = uc.ProximityPlacementGroupID
+	u.ResourceTags = uc.ResourceTags
+	u.VNetResourceGroup = uc.VNetResourceGroup
+	u.APIModelVersion = uc.APIModelVersion
+	u.RequiredSchemaVersion = uc.RequiredSchemaVersion
+	u.ReplacementSubnetID = uc.ReplacementSubnetID
+	u.VNetPeeringID = uc.VNetPeeringID
+	u.CorrelationID = uc.CorrelationID
+	u.VMAPIVersion = uc.VMAPIVersion
+	u.PostDeleteWait = uc.PostDeleteWait
+	u.MaintenanceConfigurationID = uc.MaintenanceConfigurationID
+	u.MaintenanceClient = uc.MaintenanceClient
+	u.PolicyExemptions = uc.PolicyExemptions
+	u.PolicyExemptionClient = uc.PolicyExemptionClient
+	u.PrivateEndpointConfig = uc.PrivateEndpointConfig
+	u.PrivateEndpointClient = uc.PrivateEndpointClient
+	u.Reporters = uc.Reporters
+	u.SkipCapacityCheck = uc.SkipCapacityCheck
+	u.MinFreeCapacityPercent = uc.MinFreeCapacityPercent
+	u.GPUExtensionVersion = uc.GPUExtensionVersion
+	u.GPUSKUPatternList = uc.GPUSKUPatternList
+	u.DrainGracePeriod = uc.DrainGracePeriod
+	u.DrainMaxEvictionErrors = uc.DrainMaxEvictionErrors
+	u.PoolUpgradeConfigs = uc.PoolUpgradeConfigs
+	u.DedicatedHostGroupID = uc.DedicatedHostGroupID
+	u.PreUpgradeHook = uc.PreUpgradeHook
+	u.PostUpgradeHook = uc.PostUpgradeHook
+	u.EmitKubernetesEvents = uc.EmitKubernetesEvents
+	u.PreDrainHook = uc.PreDrainHook
+	u.AbortOnPreDrainHookFailure = uc.AbortOnPreDrainHookFailure
+	u.PostCreateTaintEviction = uc.PostCreateTaintEviction
+	u.ValidateExisting = uc.ValidateExisting
+	u.GenerateTemplateOnly = uc.GenerateTemplateOnly
+	u.TemplateOutputDirectory = uc.TemplateOutputDirectory
+	u.Operator = uc.Operator
+	u.PrivateDNSSuffix = uc.PrivateDNSSuffix
+	u.ExistingDeploymentName = uc.ExistingDeploymentName
+	u.NodeJoinTimeout = uc.NodeJoinTimeout
+	u.WaitForDNSPropagation = uc.WaitForDNSPropagation
+	u.DNSPropagationTimeout = uc.DNSPropagationTimeout
+	u.DNSResolverAddress = uc.DNSResolverAddress
+	u.DNSQueryTimeout = uc.DNSQueryTimeout
+	u.CloudInitScript = uc.CloudInitScript
+	u.RegenerateCloudConfig = uc.RegenerateCloudConfig
+	u.ForceImageUpdate = uc.ForceImageUpdate
+	u.OSProfile = uc.OSProfile
+	u.SecretResolver = uc.SecretResolver
+	u.UltraDiskEnabled = uc.UltraDiskEnabled
+	u.OSDiskStorageAccountType = uc.OSDiskStorageAccountType
+	u.OSDiskCachingMode = uc.OSDiskCachingMode
+	u.DiskEncryptionSetID = uc.DiskEncryptionSetID
+	u.BootDiagnosticsEnabled = uc.BootDiagnosticsEnabled
+	u.BootDiagnosticsStorageAccountURI = uc.BootDiagnosticsStorageAccountURI
+	u.VMPriority = uc.VMPriority
+	u.EvictionPolicy = uc.EvictionPolicy
+	u.AcceleratedNetworking = uc.AcceleratedNetworking
+	u.ApplicationSecurityGroups = uc.ApplicationSecurityGroups
+	u.ExpectedOutputs = uc.ExpectedOutputs
+	u.UpdateKubeconfigInKeyVault = uc.UpdateKubeconfigInKeyVault
+	u.KeyVaultID = uc.KeyVaultID
+	u.SecretStore = uc.SecretStore
+	u.SystemAssignedIdentity = uc.SystemAssignedIdentity
+	u.UserAssignedIdentities = uc.UserAssignedIdentities
+	u.RoleAssignments = uc.RoleAssignments
+	u.StateSync = uc.StateSync
+	u.EtcdBackupContainerURL = uc.EtcdBackupContainerURL
+	u.FallbackToDataDirectoryBackup = uc.FallbackToDataDirectoryBackup
+	u.LiveEtcdMemberMigration = uc.LiveEtcdMemberMigration
+	u.AttachEtcdDataDisk = uc.AttachEtcdDataDisk
+	u.EtcdDataDiskSizeGB = uc.EtcdDataDiskSizeGB
+	u.SSHPrivateKeyPath = uc.SSHPrivateKeyPath
+	u.InPlaceKubeletUpgrade = uc.InPlaceKubeletUpgrade
+	u.UpdateScaleSetInstances = uc.UpdateScaleSetInstances
+	u.NodeOrderingStrategy = uc.NodeOrderingStrategy
+	u.IgnoreNodesWithPodLabelSelector = uc.IgnoreNodesWithPodLabelSelector
+	u.SkipPools = uc.SkipPools
+	u.ScaleDownBeforeUpgrade = uc.ScaleDownBeforeUpgrade
+	u.CheckCertificateSANs = uc.CheckCertificateSANs
+	u.CheckContainerRuntime = uc.CheckContainerRuntime
+	u.DiskAttachmentTimeout = uc.DiskAttachmentTimeout
+	u.DeploymentPollInterval = uc.DeploymentPollInterval
+	u.ReuseExistingDeployment = uc.ReuseExistingDeployment
+	u.ExplicitDependencies = uc.ExplicitDependencies
+	u.MaxDeploymentPolls = uc.MaxDeploymentPolls
+	u.ValidationWorkers = uc.ValidationWorkers
+	u.DeploymentMode = uc.DeploymentMode
+	u.WhatIfMode = uc.WhatIfMode
+	u.TemplateBlobURI = uc.TemplateBlobURI
+	u.TemplateBlobSASExpiryDuration = uc.TemplateBlobSASExpiryDuration
+	u.TemplateBlobClient = uc.TemplateBlobClient
+	u.GenerateUpgradeReport = uc.GenerateUpgradeReport
+	u.UpgradeReportBlobURI = uc.UpgradeReportBlobURI
+	u.VerifyCoreDNS = uc.VerifyCoreDNS
+	u.CoreDNSCheckImage = uc.CoreDNSCheckImage
+	u.AutoAdjustResourceQuotas = uc.AutoAdjustResourceQuotas
+	u.AutoAdjustLimitRanges = uc.AutoAdjustLimitRanges
+	u.PauseBetweenNodes = uc.PauseBetweenNodes
+	u.PauseCheckFile = uc.PauseCheckFile
+	u.CanaryUpgrade = uc.CanaryUpgrade
+	u.CanaryPauseForConfirmation = uc.CanaryPauseForConfirmation
+	u.CanaryConfirm = uc.CanaryConfirm
+	u.CanaryResumeFile = uc.CanaryResumeFile
+	u.CleanupAfterUpgrade = uc.CleanupAfterUpgrade
+	u.OutputDirectory = uc.OutputDirectory
+	u.NodeGroupSize = uc.NodeGroupSize
+	u.NodeGroupPauseAfter = uc.NodeGroupPauseAfter
+	u.ConsecutiveFailureLimit = uc.ConsecutiveFailureLimit
+	u.KubeletConfigPatch = uc.KubeletConfigPatch
+	u.NodeShutdownGracePeriod = uc.NodeShutdownGracePeriod
+	u.MixedOSConcurrencyPolicy = uc.MixedOSConcurrencyPolicy
+	u.TemplateSource = uc.TemplateSource
 	return u
 }
 
@@ -235,6 +643,10 @@ func (uc *UpgradeCluster) setNodesToUpgrade(kubeClient kubernetes.Client, resour
 						scaleSetToUpgrade.IsWindows = true
 						uc.Logger.Infof("Set isWindows flag for vmss %s.", *vmScaleSet.Name)
 					}
+					if uc.isPoolSkipped(scaleSetToUpgrade.poolName()) {
+						uc.Logger.Infof("Skipping upgrade of VMSS: %s in pool: %s.", *vmScaleSet.Name, scaleSetToUpgrade.poolName())
+						break
+					}
 					for _, vm := range vmScaleSetVMsPage.Values() {
 						currentVersion := uc.getNodeVersion(kubeClient, strings.ToLower(*vm.VirtualMachineScaleSetVMProperties.OsProfile.ComputerName), vm.Tags, *vm.VirtualMachineScaleSetVMProperties.LatestModelApplied)
 						if uc.Force {
@@ -247,7 +659,7 @@ func (uc *UpgradeCluster) setNodesToUpgrade(kubeClient kubernetes.Client, resour
 							uc.Logger.Infof("Skipping VM: %s for upgrade as the orchestrator version could not be determined.", *vm.Name)
 							continue
 						}
-						if uc.Force || currentVersion != goalVersion {
+						if uc.Force || uc.OSOnlyUpgrade || uc.ForceImageUpdate || uc.ValidateExisting || currentVersion != goalVersion {
 							uc.Logger.Infof(
 								"VM %s in VMSS %s has a current version of %s and a desired version of %s. Upgrading this node.",
 								*vm.Name,
@@ -294,12 +706,19 @@ func (uc *UpgradeCluster) setNodesToUpgrade(kubeClient kubernetes.Client, resour
 					uc.Logger.Infof("Skipping VM: %s for upgrade as the orchestrator version could not be determined.", *vm.Name)
 					continue
 				}
+				// In OS only mode every node is recreated, and in validate only mode every node is validated,
+				// the version compatibility check does not apply.
 				// If the current version is different than the desired version then we add the VM to the list of VMs to upgrade.
-				if currentVersion != goalVersion {
+				if uc.OSOnlyUpgrade || uc.ValidateExisting {
+					uc.addVMToUpgradeSets(vm, currentVersion)
+				} else if currentVersion != goalVersion {
 					if err := uc.upgradable(currentVersion); err != nil {
 						return err
 					}
 					uc.addVMToUpgradeSets(vm, currentVersion)
+				} else if uc.ForceImageUpdate {
+					// the node is recreated on the latest OS image at its current version
+					uc.addVMToUpgradeSets(vm, currentVersion)
 				} else if currentVersion == goalVersion {
 					uc.addVMToFinishedSets(vm, currentVersion)
 				}
@@ -26,7 +26,9 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -93,6 +95,45 @@ var _ = Describe("Upgrade Kubernetes cluster tests", func() {
 		os.RemoveAll("./translations")
 	})
 
+	It("Should report an upgrade event for each upgraded node", func() {
+		cs := api.CreateMockContainerService("testcluster", "", 1, 1, false)
+		reporter := &fakeReporter{}
+		uc := UpgradeCluster{
+			Translator: &i18n.Translator{},
+			Logger:     log.NewEntry(log.New()),
+			Reporters:  []UpgradeReporter{reporter},
+		}
+
+		mockClient := armhelpers.MockAKSEngineClient{}
+		uc.Client = &mockClient
+
+		uc.ClusterTopology = ClusterTopology{}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = cs
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+
+		err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(reporter.events)).To(BeNumerically(">", 2))
+		Expect(reporter.events[0].Type).To(Equal(UpgradeStartedEvent))
+		Expect(reporter.events[0].Nodes).NotTo(BeEmpty())
+		Expect(reporter.events[len(reporter.events)-1].Type).To(Equal(UpgradeCompletedEvent))
+		upgraded := 0
+		for _, event := range reporter.events[1 : len(reporter.events)-1] {
+			Expect(event.NodeName).NotTo(BeEmpty())
+			Expect(event.PoolName).NotTo(BeEmpty())
+			if event.Type == NodeUpgradedEvent {
+				upgraded++
+			}
+		}
+		Expect(upgraded).To(BeNumerically(">", 0))
+
+		// Clean up
+		os.RemoveAll("./translations")
+	})
+
 	It("Should return error message when failing to list VMs during upgrade operation", func() {
 		cs := api.CreateMockContainerService("testcluster", "", 1, 1, false)
 		uc := UpgradeCluster{
@@ -628,6 +669,59 @@ var _ = Describe("Upgrade Kubernetes cluster tests", func() {
 			Expect(*uc.MasterVMs).To(HaveLen(1))
 			Expect(*uc.UpgradedMasterVMs).To(HaveLen(0))
 		})
+		It("Should not skip VMs that are already on the current version when upgrading the OS only", func() {
+			mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+				return []compute.VirtualMachine{
+					mockClient.MakeFakeVirtualMachine(fmt.Sprintf("%s-12345678-0", common.LegacyControlPlaneVMPrefix), "Kubernetes:1.9.10"),
+					mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", "Kubernetes:1.9.10"),
+				}
+			}
+			uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+			uc.OSOnlyUpgrade = true
+			uc.CurrentVersion = "1.9.10"
+
+			err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*uc.MasterVMs).To(HaveLen(1))
+			Expect(*uc.UpgradedMasterVMs).To(HaveLen(0))
+			Expect(*uc.AgentPools["agentpool1"].AgentVMs).To(HaveLen(1))
+		})
+		It("Should not skip VMs that are already on the desired version when forcing an image update", func() {
+			mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+				return []compute.VirtualMachine{
+					mockClient.MakeFakeVirtualMachine(fmt.Sprintf("%s-12345678-0", common.LegacyControlPlaneVMPrefix), "Kubernetes:1.9.10"),
+					mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", "Kubernetes:1.9.10"),
+				}
+			}
+			uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+			uc.ForceImageUpdate = true
+
+			err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*uc.MasterVMs).To(HaveLen(1))
+			Expect(*uc.UpgradedMasterVMs).To(HaveLen(0))
+			Expect(*uc.AgentPools["agentpool1"].AgentVMs).To(HaveLen(1))
+		})
+		It("Should keep the current version and skip the version compatibility check when upgrading the OS only", func() {
+			common.AllKubernetesSupportedVersions = map[string]bool{
+				"1.9.7":  false,
+				"1.9.10": true,
+			}
+			mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+				return []compute.VirtualMachine{
+					mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", "Kubernetes:1.9.7"),
+				}
+			}
+			uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+			uc.OSOnlyUpgrade = true
+			uc.CurrentVersion = "1.9.7"
+			uc.DataModel.Properties.OrchestratorProfile.OrchestratorVersion = "1.9.10"
+
+			err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uc.DataModel.Properties.OrchestratorProfile.OrchestratorVersion).To(Equal("1.9.7"))
+			Expect(*uc.AgentPools["agentpool1"].AgentVMs).To(HaveLen(1))
+		})
 		It("Should leave platform fault domain count nil", func() {
 			cs := api.CreateMockContainerService("testcluster", "", 3, 2, false)
 			cs.Properties.OrchestratorProfile.KubernetesConfig = &api.KubernetesConfig{}
@@ -920,6 +1014,73 @@ var _ = Describe("Upgrade Kubernetes cluster tests", func() {
 		}
 	})
 
+	It("Should pause a cluster-autoscaler missing from the api model with AutoScalerAwareDrain", func() {
+		uc := UpgradeCluster{
+			Translator:           &i18n.Translator{},
+			Logger:               log.NewEntry(log.New()),
+			AutoScalerAwareDrain: true,
+		}
+
+		replicas := int32(2)
+		mockK8sClient := armhelpers.MockKubernetesClient{
+			Deployments: map[string]*appsv1.Deployment{
+				"kube-system/cluster-autoscaler": {
+					ObjectMeta: metav1.ObjectMeta{Name: "cluster-autoscaler", Namespace: "kube-system"},
+					Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				},
+			},
+		}
+		mockClient := armhelpers.MockAKSEngineClient{MockKubernetesClient: &mockK8sClient}
+		uc.Client = &mockClient
+		uc.DataModel = api.CreateMockContainerService("testcluster", "", 3, 2, false)
+
+		logger, hook := logtest.NewNullLogger()
+		uc.Logger.Logger = logger
+		defer hook.Reset()
+		err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*mockK8sClient.Deployments["kube-system/cluster-autoscaler"].Spec.Replicas).To(Equal(int32(2)))
+		var messages []string
+		for _, entry := range hook.Entries {
+			if strings.Contains(strings.ToLower(entry.Message), "cluster autoscaler") {
+				messages = append(messages, entry.Message)
+			}
+		}
+		Expect(messages).To(Equal([]string{
+			"Pausing cluster autoscaler, replica count: 0",
+			"Resuming cluster autoscaler, replica count: 2",
+		}))
+	})
+
+	It("Should upgrade without pausing when AutoScalerAwareDrain is set but no cluster-autoscaler is deployed", func() {
+		uc := UpgradeCluster{
+			Translator:           &i18n.Translator{},
+			Logger:               log.NewEntry(log.New()),
+			AutoScalerAwareDrain: true,
+		}
+
+		mockK8sClient := armhelpers.MockKubernetesClient{
+			Deployments: map[string]*appsv1.Deployment{},
+		}
+		mockClient := armhelpers.MockAKSEngineClient{MockKubernetesClient: &mockK8sClient}
+		uc.Client = &mockClient
+		uc.DataModel = api.CreateMockContainerService("testcluster", "", 3, 2, false)
+
+		logger, hook := logtest.NewNullLogger()
+		uc.Logger.Logger = logger
+		defer hook.Reset()
+		err := uc.UpgradeCluster(&mockClient, "kubeConfig", TestAKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockK8sClient.Deployments).To(BeEmpty())
+		var warned, resumed bool
+		for _, entry := range hook.Entries {
+			warned = warned || entry.Level == log.WarnLevel && entry.Message == "Cluster autoscaler is not deployed, upgrading without pausing it"
+			resumed = resumed || strings.Contains(strings.ToLower(entry.Message), "resuming cluster autoscaler")
+		}
+		Expect(warned).To(BeTrue())
+		Expect(resumed).To(BeFalse())
+	})
+
 	It("Tests SetClusterAutoscalerReplicaCount", func() {
 		uc := UpgradeCluster{
 			Translator: &i18n.Translator{},
@@ -0,0 +1,84 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UpgradeHistoryConfigMapName is the kube-system config map holding one entry per control plane upgrade
+	UpgradeHistoryConfigMapName = "aks-engine-upgrade-history"
+	upgradeHistoryNamespace     = "kube-system"
+	// upgradeHistoryKeyFormat keeps the keys sortable and within the config map key charset
+	upgradeHistoryKeyFormat = "upgrade-20060102T150405Z"
+)
+
+// UpgradeRecord describes a control plane upgrade stored in the upgrade history config map.
+type UpgradeRecord struct {
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	FromVersion string    `json:"fromVersion"`
+	ToVersion   string    `json:"toVersion"`
+	Operator    string    `json:"operator,omitempty"`
+	Deployments []string  `json:"deployments"`
+}
+
+// RecordUpgradeMetadata adds an entry describing this upgrade to the aks-engine-upgrade-history
+// config map of the kube-system namespace, creating the config map if needed.
+func (kmn *UpgradeMasterNode) RecordUpgradeMetadata(ctx context.Context) error {
+	record := UpgradeRecord{
+		StartTime:   kmn.startTime.UTC(),
+		EndTime:     time.Now().UTC(),
+		FromVersion: kmn.CurrentVersion,
+		ToVersion:   kmn.UpgradeContainerService.Properties.OrchestratorProfile.OrchestratorVersion,
+		Operator:    kmn.Operator,
+		Deployments: kmn.deploymentNames,
+	}
+	if record.Deployments == nil {
+		record.Deployments = []string{}
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshaling upgrade record")
+	}
+	key := record.StartTime.Format(upgradeHistoryKeyFormat)
+
+	client, err := kmn.Client.GetKubernetesClient(kmn.masterURL(), kmn.kubeConfig, interval, kmn.timeout)
+	if err != nil {
+		return errors.Wrap(err, "getting a Kubernetes client")
+	}
+	cm, err := client.GetConfigMap(upgradeHistoryNamespace, UpgradeHistoryConfigMapName)
+	if apierrors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      UpgradeHistoryConfigMapName,
+				Namespace: upgradeHistoryNamespace,
+			},
+			Data: map[string]string{key: string(value)},
+		}
+		if _, err = client.CreateConfigMap(cm); err != nil {
+			return errors.Wrapf(err, "creating config map %s/%s", upgradeHistoryNamespace, UpgradeHistoryConfigMapName)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting config map %s/%s", upgradeHistoryNamespace, UpgradeHistoryConfigMapName)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[key] = string(value)
+	if _, err = client.UpdateConfigMap(cm); err != nil {
+		return errors.Wrapf(err, "updating config map %s/%s", upgradeHistoryNamespace, UpgradeHistoryConfigMapName)
+	}
+	return nil
+}
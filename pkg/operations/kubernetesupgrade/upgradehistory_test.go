@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func upgradeRecords(cm *v1.ConfigMap) []UpgradeRecord {
+	records := []UpgradeRecord{}
+	for _, value := range cm.Data {
+		var record UpgradeRecord
+		Expect(json.Unmarshal([]byte(value), &record)).To(Succeed())
+		records = append(records, record)
+	}
+	return records
+}
+
+var _ = Describe("Upgrade history tests", func() {
+	var (
+		mockClient     *armhelpers.MockAKSEngineClient
+		mockKubeClient *armhelpers.MockKubernetesClient
+	)
+
+	BeforeEach(func() {
+		mockKubeClient = &armhelpers.MockKubernetesClient{}
+		mockClient = &armhelpers.MockAKSEngineClient{MockKubernetesClient: mockKubeClient}
+	})
+
+	It("Should create the upgrade history config map", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.CurrentVersion = "1.17.11"
+		kmn.Operator = "jdoe"
+		kmn.startTime = time.Date(2020, 9, 1, 10, 30, 0, 0, time.UTC)
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+		Expect(kmn.RecordUpgradeMetadata(context.Background())).To(Succeed())
+
+		cm := mockKubeClient.ConfigMaps["kube-system/"+UpgradeHistoryConfigMapName]
+		Expect(cm).NotTo(BeNil())
+		Expect(cm.Data).To(HaveKey("upgrade-20200901T103000Z"))
+		records := upgradeRecords(cm)
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].StartTime).To(Equal(kmn.startTime))
+		Expect(records[0].EndTime).To(BeTemporally(">", kmn.startTime))
+		Expect(records[0].FromVersion).To(Equal("1.17.11"))
+		Expect(records[0].ToVersion).To(Equal(kmn.UpgradeContainerService.Properties.OrchestratorProfile.OrchestratorVersion))
+		Expect(records[0].Operator).To(Equal("jdoe"))
+		Expect(records[0].Deployments).To(HaveLen(1))
+		Expect(records[0].Deployments[0]).To(HavePrefix("k8s-upgrade-master-0-"))
+	})
+
+	It("Should keep the previous upgrades in the upgrade history", func() {
+		kmn := newTestUpgradeMasterNode(mockClient)
+		kmn.startTime = time.Date(2020, 9, 1, 10, 30, 0, 0, time.UTC)
+		Expect(kmn.RecordUpgradeMetadata(context.Background())).To(Succeed())
+		kmn.startTime = time.Date(2020, 10, 1, 10, 30, 0, 0, time.UTC)
+		Expect(kmn.RecordUpgradeMetadata(context.Background())).To(Succeed())
+
+		cm := mockKubeClient.ConfigMaps["kube-system/"+UpgradeHistoryConfigMapName]
+		Expect(cm.Data).To(HaveLen(2))
+		Expect(cm.Data).To(HaveKey("upgrade-20201001T103000Z"))
+	})
+
+	It("Should return an error when the config map cannot be updated", func() {
+		mockKubeClient.FailGetConfigMap = true
+		kmn := newTestUpgradeMasterNode(mockClient)
+
+		err := kmn.RecordUpgradeMetadata(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("getting config map kube-system/aks-engine-upgrade-history"))
+	})
+})
@@ -7,14 +7,22 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/helpers/ssh"
 	"github.com/Azure/aks-engine/pkg/i18n"
 	"github.com/Azure/aks-engine/pkg/kubernetes"
 	"github.com/Azure/aks-engine/pkg/operations"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -34,6 +42,185 @@ type UpgradeMasterNode struct {
 	Client                  armhelpers.AKSEngineClient
 	kubeConfig              string
 	timeout                 time.Duration
+	// VNetResourceGroup is the resource group of the virtual network of the cluster when it is not ResourceGroup;
+	// empty uses the resource group of MasterProfile.VnetSubnetID for custom VNETs, and ResourceGroup otherwise
+	VNetResourceGroup string
+	// ProximityPlacementGroupID is the resource ID of the proximity placement group
+	// the upgraded master VMs are placed in; empty leaves the template untouched
+	ProximityPlacementGroupID string
+	// APIModelVersion is the schema version, e.g. vlabs, the api model of UpgradeContainerService was loaded with
+	APIModelVersion string
+	// RequiredSchemaVersion restricts the upgrade to api models of the given schema version; Preflight fails
+	// if APIModelVersion differs. Empty accepts any schema version
+	RequiredSchemaVersion string
+	// ReplacementSubnetID is the resource ID of the subnet the NICs of the new master VMs are attached to,
+	// it must be in the virtual network of the cluster; empty keeps the subnet of the template
+	ReplacementSubnetID string
+	// VNetPeeringID is the resource ID of a peering of the cluster virtual network, the NICs of the new master VMs
+	// are attached to the subnet of the same name in its remote virtual network; the peering must be Connected
+	VNetPeeringID string
+	// CorrelationID is sent as the correlation ID of the ARM deployments creating the master VMs, so that
+	// they can be referenced in Azure support cases; CreateNode generates a UUID if empty
+	CorrelationID string
+	// VMAPIVersion overrides the ARM API version of the master VM resources; empty keeps the template default
+	VMAPIVersion string
+	// PostDeleteWait is how long DeleteNode waits after deleting the VM so that Azure
+	// releases the locks it may still hold on the NIC or disks of the deleted VM
+	PostDeleteWait time.Duration
+	// MaintenanceConfigurationID is the resource ID of the maintenance configuration assigned
+	// to each master VM after it is created; requires MaintenanceClient
+	MaintenanceConfigurationID string
+	MaintenanceClient          MaintenanceClient
+	// PolicyExemptions are created through PolicyExemptionClient before each master VM is deployed,
+	// and removed once the deployment completes
+	PolicyExemptions      []PolicyExemption
+	PolicyExemptionClient PolicyExemptionClient
+	// PrivateEndpointConfig describes the private endpoint of the API server provisioned through PrivateEndpointClient
+	// once each master VM is created, see ProvisionPrivateEndpoint
+	PrivateEndpointConfig *PrivateEndpointConfig
+	PrivateEndpointClient PrivateEndpointClient
+	// DedicatedHostGroupID is the resource ID of the dedicated host group the upgraded master VMs
+	// are placed in; each VM goes to the host of the group with the most capacity left
+	DedicatedHostGroupID string
+	// dedicatedHostID is the host of DedicatedHostGroupID selected for the next master VM
+	dedicatedHostID string
+	// sshPublicKey is the resolved OSProfile SSH public key
+	sshPublicKey string
+	// peeredVNet is the resource ID of the remote virtual network of VNetPeeringID
+	peeredVNet string
+	// PrivateDNSSuffix is the suffix of the private DNS zone resolving the master FQDN of a private cluster;
+	// when set, the Kubernetes client connects to <dnsPrefix>.<PrivateDNSSuffix> instead of MasterProfile.FQDN
+	PrivateDNSSuffix string
+	// CurrentVersion is the Kubernetes version the cluster is upgraded from
+	CurrentVersion string
+	// Operator identifies who runs the upgrade in the upgrade history
+	Operator string
+	// NodeJoinTimeout is how long Validate waits for the node to register with the API server
+	// before returning a NodeJoinTimeoutError; zero only waits for the node to be ready within timeout
+	NodeJoinTimeout time.Duration
+	// WaitForDNSPropagation polls DNS until the hostname of each created master VM resolves before validating it,
+	// for up to DNSPropagationTimeout, DefaultDNSPropagationTimeout if zero. Each query goes to DNSResolverAddress,
+	// <host>:<port>, or to the system resolver if empty, and times out after DNSQueryTimeout, DefaultDNSQueryTimeout if zero.
+	WaitForDNSPropagation bool
+	DNSPropagationTimeout time.Duration
+	DNSResolverAddress    string
+	DNSQueryTimeout       time.Duration
+	// ExistingDeploymentName is used as the name of the ARM deployments creating the master VMs
+	// instead of a generated one, so that tools tracking deployments by name keep working
+	ExistingDeploymentName string
+	// TargetAvailabilityZone is the availability zone of the next master VM created, set to the zone
+	// of the VM it replaces to keep the zone distribution of the masters; empty keeps the template zones
+	TargetAvailabilityZone string
+	// CloudInitScript is the base64-encoded cloud-init script set as the custom data of the new master VMs,
+	// it replaces the custom data generated by aks-engine and must provision the node on its own
+	CloudInitScript string
+	// OSProfile overrides the admin user, SSH public key and password authentication of the new master VMs;
+	// a Key Vault secret URL as SSHPublicKey is read through SecretResolver
+	OSProfile      *OSProfileConfig
+	SecretResolver SecretResolver
+	// UltraDiskEnabled enables ultra disk compatibility on the new master VMs, the VM size must
+	// support ultra disks in the location, and in the availability zone of zonal masters
+	UltraDiskEnabled bool
+	// OSDiskStorageAccountType is the managed disk type of the OS disk of the new master VMs, Premium_LRS,
+	// StandardSSD_LRS or Standard_LRS; Premium_LRS requires a VM size supporting premium storage.
+	// Empty keeps the disk type of the template.
+	OSDiskStorageAccountType string
+	// OSDiskCachingMode is the caching mode of the OS disk of the new master VMs, None, ReadOnly or ReadWrite;
+	// empty keeps the caching mode of the template
+	OSDiskCachingMode string
+	// DiskEncryptionSetID is the resource ID of the disk encryption set the managed OS disks of the new master VMs
+	// are encrypted with, using a customer-managed key; it must be in the cluster region. Empty keeps the template
+	DiskEncryptionSetID string
+	// BootDiagnosticsEnabled enables the boot diagnostics of the new master VMs, stored in the storage account of
+	// BootDiagnosticsStorageAccountURI, its https blob endpoint, or in a managed storage account if empty;
+	// CreateNode fails if they are not enabled on the created VMs
+	BootDiagnosticsEnabled           bool
+	BootDiagnosticsStorageAccountURI string
+	// VMPriority is the priority of the new master VMs, Regular, Spot or Low, the deprecated name of Spot;
+	// empty keeps the priority of the template. EvictionPolicy is the eviction policy of Spot VMs,
+	// Deallocate or Delete, Deallocate if empty. Spot master VMs can be evicted at any time.
+	VMPriority     string
+	EvictionPolicy string
+	// AcceleratedNetworking enables Accelerated Networking on the NICs of the new master VMs,
+	// Preflight warns if the VM size does not support it in the location
+	AcceleratedNetworking bool
+	// ApplicationSecurityGroups are the resource IDs of application security groups the NICs of the new master VMs
+	// are added to; CreateNode fails if the created NICs are not in them
+	ApplicationSecurityGroups []string
+	// ExpectedOutputs maps the outputs of each master VM deployment to their expected value, or a regular expression
+	// the value must match enclosed in slashes; CreateNode returns an OutputMismatchError if the outputs differ
+	ExpectedOutputs map[string]string
+	// UpdateKubeconfigInKeyVault stores the kubeconfig as YAML in the Key Vault secret KeyVaultID, the secret URL
+	// without version, once all masters are upgraded; the secret is written through SecretStore and created if missing
+	UpdateKubeconfigInKeyVault bool
+	KeyVaultID                 string
+	SecretStore                SecretStore
+	// SystemAssignedIdentity and UserAssignedIdentities, the resource IDs of user-assigned identities, are added
+	// to the identities of the new master VMs; CreateNode fails if the created VM does not have them
+	SystemAssignedIdentity bool
+	UserAssignedIdentities []string
+	// RoleAssignments are assigned to the system-assigned identity of each new master VM once created,
+	// e.g. Contributor on the resource group for the cloud provider
+	RoleAssignments []RoleAssignment
+	// ResourceTags are the Azure tags set on the VM, NIC and disks of the new master VMs, in addition to the tags
+	// set by aks-engine; ReadExistingResourceTags returns the tags of an existing VM
+	ResourceTags map[string]string
+	// StateSync saves the api model after each master VM is upgraded, so that other tools see the current cluster state
+	StateSync StateSync
+	// DeploymentPollInterval and MaxDeploymentPolls make CreateNode poll the state of the deployment
+	// instead of waiting with the ARM client defaults; zero MaxDeploymentPolls polls until completion
+	DeploymentPollInterval time.Duration
+	MaxDeploymentPolls     int
+	// ExplicitDependencies maps the name of a resource of the upgrade template, as written in the template,
+	// to resources it depends on, merged into its dependsOn array before the deployment
+	ExplicitDependencies map[string][]string
+	// ReuseExistingDeployment makes CreateNode wait for a running upgrade deployment of the master VM,
+	// e.g. left by an interrupted upgrade, instead of creating a new deployment
+	ReuseExistingDeployment bool
+	// TemplateBlobURI is the URL of a blob container the master VM templates are uploaded to through TemplateBlobClient
+	// and deployed from, for templates over the ARM inline template size limit; empty deploys the templates inline
+	TemplateBlobURI string
+	// TemplateBlobSASExpiryDuration is how long the SAS token of the uploaded templates is valid,
+	// DefaultTemplateBlobSASExpiryDuration if zero
+	TemplateBlobSASExpiryDuration time.Duration
+	TemplateBlobClient            TemplateBlobClient
+	// UpgradeReportBlobURI is the URL of a blob container GenerateUpgradeReportMarkdown uploads the upgrade report to
+	// through TemplateBlobClient, the report is not uploaded if empty
+	UpgradeReportBlobURI string
+	// DeploymentMode is the ARM deployment mode of the master VM deployments, Incremental if empty.
+	// WARNING: Complete mode is destructive, ARM deletes every resource of the resource group that is
+	// not in the upgrade template. Only use it if you know exactly what the template contains.
+	DeploymentMode string
+	// WhatIfMode makes CreateNode log the changes the Incremental deployment of each master VM would make to the
+	// resource group, as predicted by the ARM what-if operation, instead of deploying it
+	WhatIfMode bool
+	// EtcdBackupContainerURL is the URL of a blob container, including a SAS token granting write access, the etcd
+	// data of each master VM is uploaded to by DetachAndSaveEtcdData before the VM is deleted; nil disables the backup
+	EtcdBackupContainerURL *url.URL
+	// FallbackToDataDirectoryBackup also uploads an archive of the etcd data directory of master VMs without a data disk
+	FallbackToDataDirectoryBackup bool
+	// LiveEtcdMemberMigration removes the etcd member of each master VM from the etcd cluster before the VM is
+	// deleted and adds it back once the upgraded VM has joined, keeping the etcd quorum throughout the upgrade
+	LiveEtcdMemberMigration bool
+	// AttachEtcdDataDisk attaches a new managed data disk of EtcdDataDiskSizeGB, the etcd disk size of the api model
+	// if zero, as the lun0 disk of the new master VMs in place of the template one, and mounts it on the etcd data
+	// directory over SSH once the VM is created. The etcd data of the replaced disk is not carried over.
+	AttachEtcdDataDisk bool
+	EtcdDataDiskSizeGB int
+	// removedEtcdMembers are the etcd members removed by RemoveEtcdMember keyed by master VM name
+	removedEtcdMembers map[string]removedEtcdMember
+	// SSHPrivateKeyPath is the private key used to connect to the master VMs to back up or migrate etcd,
+	// and to mount the etcd data disk
+	SSHPrivateKeyPath string
+	// executeRemote and copyFromRemote run the etcd backup, migration and data disk scripts on the master VMs, over SSH if nil
+	executeRemote  func(ctx context.Context, host *ssh.RemoteHost, script string) (string, error)
+	copyFromRemote func(ctx context.Context, host *ssh.RemoteHost, file *ssh.RemoteFile, destinationPath string) (string, error)
+	// OutputDirectory is the local output directory of the cluster, e.g. _output/<dnsPrefix>;
+	// Cleanup removes its UpgradeOutputDirectoryName sub-directory
+	OutputDirectory string
+	// startTime and deploymentNames are recorded in the upgrade history
+	startTime       time.Time
+	deploymentNames []string
 }
 
 // DeleteNode takes state/resources of the master/agent node from ListNodeResources
@@ -41,32 +228,333 @@ type UpgradeMasterNode struct {
 // the node.
 // The 'drain' flag is not used for deleting master nodes.
 func (kmn *UpgradeMasterNode) DeleteNode(vmName *string, drain bool) error {
-	return operations.CleanDeleteVirtualMachine(kmn.Client, kmn.logger, kmn.SubscriptionID, kmn.ResourceGroup, *vmName)
+	if err := operations.CleanDeleteVirtualMachine(kmn.Client, kmn.logger, kmn.SubscriptionID, kmn.ResourceGroup, *vmName); err != nil {
+		return err
+	}
+	if kmn.PostDeleteWait > 0 {
+		// works around Azure eventual consistency, CreateNode may otherwise fail with a conflict
+		kmn.logger.Infof("Waiting %v for Azure to release the resources of VM %s", kmn.PostDeleteWait, *vmName)
+		time.Sleep(kmn.PostDeleteWait)
+	}
+	return nil
 }
 
 // CreateNode creates a new master/agent node with the targeted version of Kubernetes
 func (kmn *UpgradeMasterNode) CreateNode(ctx context.Context, poolName string, masterNo int) error {
-	templateVariables := kmn.TemplateMap["variables"].(map[string]interface{})
-
-	templateVariables["masterOffset"] = masterNo
-	masterOffsetVar := templateVariables["masterOffset"]
-	kmn.logger.Infof("Master offset: %v", masterOffsetVar)
+	if kmn.CorrelationID == "" {
+		kmn.CorrelationID = uuid.Must(uuid.NewRandom()).String()
+	}
+	kmn.logger.Infof("Correlation ID of the deployment of master VM with index %d: %s", masterNo, kmn.CorrelationID)
 
-	templateVariables["masterCount"] = masterNo + 1
-	masterOffset := templateVariables["masterCount"]
-	kmn.logger.Infof("Master pool set count to: %v temporarily during upgrade...", masterOffset)
+	if err := kmn.prepareTemplate(ctx, masterNo); err != nil {
+		return err
+	}
 
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	deploymentSuffix := random.Int31()
 	deploymentName := fmt.Sprintf("k8s-upgrade-master-%d-%s-%d", masterNo, time.Now().Format("06-01-02T15.04.05"), deploymentSuffix)
+	if kmn.ExistingDeploymentName != "" {
+		deploymentName = kmn.ExistingDeploymentName
+		kmn.warnIfDeploymentExists(ctx, deploymentName)
+	}
+	if kmn.WhatIfMode {
+		return kmn.previewDeployment(ctx, deploymentName)
+	}
+
+	reused, err := kmn.waitForRunningDeployment(ctx, masterNo)
+	if err != nil {
+		return err
+	}
+	if reused != "" {
+		deploymentName = reused
+	} else {
+		exemptions, err := kmn.createPolicyExemptions(ctx)
+		if err != nil {
+			return err
+		}
+		err = kmn.deployTemplate(armhelpers.WithCorrelationID(ctx, kmn.CorrelationID), deploymentName)
+		kmn.deletePolicyExemptions(ctx, exemptions)
+		if err != nil {
+			return err
+		}
+	}
+	kmn.deploymentNames = append(kmn.deploymentNames, deploymentName)
+	if len(kmn.ExpectedOutputs) > 0 {
+		if err := kmn.VerifyDeploymentOutputs(ctx, deploymentName); err != nil {
+			return err
+		}
+	}
+
+	vmName := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + strconv.Itoa(masterNo)
+	if kmn.hasIdentity() {
+		if err := kmn.VerifyIdentity(ctx, vmName); err != nil {
+			return err
+		}
+	}
+	if len(kmn.ApplicationSecurityGroups) > 0 {
+		if err := kmn.VerifyApplicationSecurityGroups(ctx, vmName); err != nil {
+			return err
+		}
+	}
+	if kmn.DiskEncryptionSetID != "" {
+		if err := kmn.VerifyDiskEncryptionSet(ctx, vmName); err != nil {
+			return err
+		}
+	}
+	if kmn.BootDiagnosticsEnabled {
+		if err := kmn.VerifyBootDiagnostics(ctx, vmName); err != nil {
+			return err
+		}
+	}
+	if kmn.AttachEtcdDataDisk {
+		if err := kmn.MountEtcdDataDisk(ctx, vmName); err != nil {
+			return err
+		}
+	}
+	if len(kmn.ResourceTags) > 0 {
+		if err := kmn.tagOSDisk(ctx, vmName); err != nil {
+			return err
+		}
+	}
+	if len(kmn.RoleAssignments) > 0 {
+		if err := kmn.AssignRoles(ctx, vmName, kmn.RoleAssignments); err != nil {
+			return err
+		}
+	}
+	if kmn.PrivateEndpointConfig != nil {
+		if err := kmn.ProvisionPrivateEndpoint(ctx, kmn.PrivateEndpointConfig); err != nil {
+			return err
+		}
+	}
+	if kmn.MaintenanceConfigurationID != "" {
+		return kmn.AssignMaintenanceConfiguration(ctx, vmName, kmn.MaintenanceConfigurationID)
+	}
+	return nil
+}
+
+// prepareTemplate sets the master offset and count variables of the upgrade template to deploy the single
+// master VM with index masterNo, and applies the UpgradeMasterNode options to the template
+func (kmn *UpgradeMasterNode) prepareTemplate(ctx context.Context, masterNo int) error {
+	templateVariables := kmn.TemplateMap["variables"].(map[string]interface{})
+
+	masterOffset := masterNo
+	templateVariables["masterOffset"] = masterOffset
+	kmn.logger.Infof("Master offset: %v", masterOffset)
+
+	masterCount := masterNo + 1
+	templateVariables["masterCount"] = masterCount
+	kmn.logger.Infof("Master pool set count to: %v temporarily during upgrade...", masterCount)
+
+	if err := validateMasterOffsetVariables(templateVariables); err != nil {
+		return err
+	}
+
+	if kmn.DedicatedHostGroupID != "" {
+		hostID, err := kmn.selectDedicatedHost(ctx)
+		if err != nil {
+			return err
+		}
+		kmn.logger.Infof("Placing master VM with index %d on dedicated host %s", masterOffset, hostID)
+		kmn.dedicatedHostID = hostID
+	}
+
+	if kmn.OSProfile != nil {
+		if err := kmn.resolveSSHPublicKey(ctx); err != nil {
+			return err
+		}
+	}
+
+	if kmn.VNetPeeringID != "" {
+		vnetID, err := kmn.peeredVNetID(ctx)
+		if err != nil {
+			return err
+		}
+		kmn.peeredVNet = vnetID
+	}
+
+	if kmn.TargetAvailabilityZone != "" {
+		if err := kmn.validateAvailabilityZone(ctx, kmn.TargetAvailabilityZone); err != nil {
+			return err
+		}
+		kmn.logger.Infof("Placing master VM with index %d in availability zone %s", masterOffset, kmn.TargetAvailabilityZone)
+	}
+
+	if kmn.UltraDiskEnabled {
+		if err := kmn.validateUltraDisk(ctx, kmn.TargetAvailabilityZone); err != nil {
+			return err
+		}
+	}
+
+	if err := kmn.customizeTemplate(); err != nil {
+		return err
+	}
+
+	return armhelpers.ValidateDeploymentParameters(kmn.logger, kmn.TemplateMap, kmn.ParametersMap)
+}
+
+// Preflight verifies the upgrade options before any master node is deleted.
+func (kmn *UpgradeMasterNode) Preflight(ctx context.Context) error {
+	if err := kmn.validateSchemaVersion(); err != nil {
+		return err
+	}
+	if kmn.VMAPIVersion != "" {
+		if err := ValidateVMAPIVersion(kmn.VMAPIVersion); err != nil {
+			return err
+		}
+	}
+	if kmn.CloudInitScript != "" {
+		if err := ValidateCloudInitScript(kmn.CloudInitScript); err != nil {
+			return err
+		}
+	}
+	if kmn.DeploymentMode != "" {
+		if err := ValidateDeploymentMode(kmn.DeploymentMode); err != nil {
+			return err
+		}
+	}
+	if kmn.UltraDiskEnabled {
+		if err := kmn.validateUltraDisk(ctx, kmn.TargetAvailabilityZone); err != nil {
+			return err
+		}
+	}
+	if kmn.OSDiskStorageAccountType != "" {
+		if err := kmn.validateOSDiskStorageAccountType(ctx); err != nil {
+			return err
+		}
+	}
+	if kmn.OSDiskCachingMode != "" {
+		if err := ValidateOSDiskCachingMode(kmn.OSDiskCachingMode); err != nil {
+			return err
+		}
+	}
+	if kmn.VMPriority != "" || kmn.EvictionPolicy != "" {
+		if err := ValidateVMPriority(kmn.VMPriority, kmn.EvictionPolicy); err != nil {
+			return err
+		}
+		kmn.warnIfEvictable()
+	}
+	if kmn.AcceleratedNetworking {
+		if err := kmn.checkAcceleratedNetworking(ctx); err != nil {
+			return err
+		}
+	}
+	if err := kmn.validateEtcdDataDisk(); err != nil {
+		return err
+	}
+	if err := kmn.validateBootDiagnostics(); err != nil {
+		return err
+	}
+	if err := kmn.validateDNSPropagation(); err != nil {
+		return err
+	}
+	if err := kmn.validateIdentities(); err != nil {
+		return err
+	}
+	if err := kmn.validateApplicationSecurityGroups(); err != nil {
+		return err
+	}
+	if err := kmn.validateKubeconfigSecret(); err != nil {
+		return err
+	}
+	if err := kmn.validateExpectedOutputs(); err != nil {
+		return err
+	}
+	if err := kmn.validateRoleAssignments(); err != nil {
+		return err
+	}
+	if err := kmn.validateOSProfile(); err != nil {
+		return err
+	}
+	if err := kmn.validatePolicyExemptions(); err != nil {
+		return err
+	}
+	if err := kmn.validatePrivateEndpointConfig(); err != nil {
+		return err
+	}
+	if err := kmn.validateTemplateBlob(); err != nil {
+		return err
+	}
+	if kmn.MaintenanceConfigurationID != "" {
+		if kmn.MaintenanceClient == nil {
+			return errors.New("a maintenance client is required to assign a maintenance configuration")
+		}
+		if _, err := utils.ResourceName(kmn.MaintenanceConfigurationID); err != nil {
+			return errors.Wrapf(err, "parsing maintenance configuration ID %s", kmn.MaintenanceConfigurationID)
+		}
+	}
+	if kmn.DedicatedHostGroupID != "" {
+		if _, err := kmn.selectDedicatedHost(ctx); err != nil {
+			return err
+		}
+	}
+	if kmn.ReplacementSubnetID != "" {
+		if err := kmn.validateReplacementSubnet(); err != nil {
+			return err
+		}
+	}
+	if kmn.VNetPeeringID != "" {
+		if kmn.ReplacementSubnetID != "" {
+			return errors.New("a replacement subnet and a virtual network peering cannot be set together")
+		}
+		vnetID, err := kmn.peeredVNetID(ctx)
+		if err != nil {
+			return err
+		}
+		kmn.peeredVNet = vnetID
+	}
+	if requiredIPs := kmn.requiredSubnetIPs(); requiredIPs > 0 && !kmn.UpgradeContainerService.Properties.IsAzureStackCloud() {
+		subnetID := kmn.ReplacementSubnetID
+		if subnetID == "" {
+			subnetID = kmn.peeredSubnetID()
+		}
+		if err := kmn.SubnetIPCapacityCheck(ctx, subnetID, requiredIPs); err != nil {
+			return err
+		}
+	}
+	if err := kmn.validateDiskEncryptionSet(ctx); err != nil {
+		return err
+	}
+	return kmn.validateProximityPlacementGroup(ctx)
+}
+
+// validateSchemaVersion ensures the api model was loaded with RequiredSchemaVersion, if set.
+func (kmn *UpgradeMasterNode) validateSchemaVersion() error {
+	if kmn.RequiredSchemaVersion == "" {
+		return nil
+	}
+	if kmn.APIModelVersion == "" {
+		return errors.Errorf("the api model schema version is unknown, expected %s", kmn.RequiredSchemaVersion)
+	}
+	if kmn.APIModelVersion != kmn.RequiredSchemaVersion {
+		return errors.Errorf("api model schema version %s does not match the required schema version %s", kmn.APIModelVersion, kmn.RequiredSchemaVersion)
+	}
+	return nil
+}
 
-	_, err := kmn.Client.DeployTemplate(
-		ctx,
-		kmn.ResourceGroup,
-		deploymentName,
-		kmn.TemplateMap,
-		kmn.ParametersMap)
-	return err
+// validateProximityPlacementGroup ensures the proximity placement group exists
+// and lives in the same region as the cluster.
+func (kmn *UpgradeMasterNode) validateProximityPlacementGroup(ctx context.Context) error {
+	if kmn.ProximityPlacementGroupID == "" {
+		return nil
+	}
+	name, err := utils.ResourceName(kmn.ProximityPlacementGroupID)
+	if err != nil {
+		return errors.Wrapf(err, "parsing proximity placement group ID %s", kmn.ProximityPlacementGroupID)
+	}
+	resourceGroup, err := utils.ResourceGroupName(kmn.ProximityPlacementGroupID)
+	if err != nil {
+		return errors.Wrapf(err, "parsing proximity placement group ID %s", kmn.ProximityPlacementGroupID)
+	}
+	ppg, err := kmn.Client.GetProximityPlacementGroup(ctx, resourceGroup, name)
+	if err != nil {
+		return errors.Wrapf(err, "getting proximity placement group %s", kmn.ProximityPlacementGroupID)
+	}
+	location := helpers.NormalizeAzureRegion(kmn.UpgradeContainerService.Location)
+	ppgLocation := helpers.NormalizeAzureRegion(to.String(ppg.Location))
+	if ppgLocation != location {
+		return errors.Errorf("proximity placement group %s is in region %s, expected %s", name, ppgLocation, location)
+	}
+	return nil
 }
 
 // Validate will verify the that master node has been upgraded as expected.
@@ -83,37 +571,108 @@ func (kmn *UpgradeMasterNode) Validate(vmName *string) error {
 		return nil
 	}
 
-	apiserverURL := kmn.UpgradeContainerService.Properties.MasterProfile.FQDN
-
-	client, err := kmn.Client.GetKubernetesClient(apiserverURL, kmn.kubeConfig, interval, kmn.timeout)
+	client, err := kmn.Client.GetKubernetesClient(kmn.masterURL(), kmn.kubeConfig, interval, kmn.timeout)
 	if err != nil {
 		return err
 	}
 
 	ch := make(chan struct{}, 1)
+	joined := make(chan struct{}, 1)
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		for {
+		for hasJoined := false; ; {
 			masterNode, err := client.GetNode(nodeName)
+			if err == nil && !hasJoined {
+				hasJoined = true
+				joined <- struct{}{}
+			}
 			if err != nil {
 				kmn.logger.Infof("Master node: %s status error: %v", nodeName, err)
-				time.Sleep(time.Second * 5)
 			} else if kubernetes.IsNodeReady(masterNode) {
 				kmn.logger.Infof("Master node: %s is ready", nodeName)
 				ch <- struct{}{}
+				return
 			} else {
 				kmn.logger.Infof("Master node: %s not ready yet...", nodeName)
-				time.Sleep(time.Second * 5)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(retry):
 			}
 		}
 	}()
 
+	var joinTimeout <-chan time.Time
+	if kmn.NodeJoinTimeout > 0 {
+		joinTimeout = time.After(kmn.NodeJoinTimeout)
+	}
+	readyTimeout := time.After(kmn.timeout)
 	for {
 		select {
 		case <-ch:
 			return nil
-		case <-time.After(kmn.timeout):
+		case <-joined:
+			joinTimeout = nil
+		case <-joinTimeout:
+			err := &NodeJoinTimeoutError{NodeName: nodeName, Timeout: kmn.NodeJoinTimeout}
+			kmn.logger.Errorf(err.Error())
+			return err
+		case <-readyTimeout:
 			kmn.logger.Errorf("Node was not ready within %v", kmn.timeout)
 			return errors.Errorf("Node was not ready within %v", kmn.timeout)
 		}
 	}
 }
+
+// NodeJoinTimeoutError is returned by Validate when the node did not register with the API server
+// within NodeJoinTimeout, which usually means that the VM failed to provision
+type NodeJoinTimeoutError struct {
+	NodeName string
+	Timeout  time.Duration
+}
+
+// Error implements error interface
+func (e *NodeJoinTimeoutError) Error() string {
+	return fmt.Sprintf("node %s did not register with the API server within %v: the VM most likely failed to provision, "+
+		"check /var/log/cloud-init-output.log and /var/log/azure/cluster-provision.log on the VM over SSH or the serial console, "+
+		"and the status of the VM extensions in the Azure portal", e.NodeName, e.Timeout)
+}
+
+// warnIfDeploymentExists logs a warning if ARM is about to update an existing deployment
+func (kmn *UpgradeMasterNode) warnIfDeploymentExists(ctx context.Context, deploymentName string) {
+	resp, err := kmn.Client.CheckDeploymentExistence(ctx, kmn.ResourceGroup, deploymentName)
+	if err != nil {
+		kmn.logger.Warnf("Failed to check whether deployment %s exists: %v", deploymentName, err)
+		return
+	}
+	if resp.Response != nil && resp.StatusCode == http.StatusNoContent {
+		kmn.logger.Warnf("Deployment %s already exists in resource group %s, ARM will update it", deploymentName, kmn.ResourceGroup)
+	}
+}
+
+// validateMasterOffsetVariables ensures the template deploys the single master VM at index masterOffset,
+// ARM deployments fail without a clear error when masterOffset is not lower than masterCount.
+func validateMasterOffsetVariables(templateVariables map[string]interface{}) error {
+	masterOffset, ok := templateVariables["masterOffset"].(int)
+	if !ok {
+		return errors.Errorf("template variable masterOffset is %v, an integer is expected", templateVariables["masterOffset"])
+	}
+	masterCount, ok := templateVariables["masterCount"].(int)
+	if !ok {
+		return errors.Errorf("template variable masterCount is %v, an integer is expected", templateVariables["masterCount"])
+	}
+	if masterOffset < 0 || masterCount != masterOffset+1 {
+		return errors.Errorf("template variables masterOffset %d and masterCount %d are inconsistent, masterCount must be masterOffset + 1", masterOffset, masterCount)
+	}
+	return nil
+}
+
+// masterURL returns the address of the API server used to create Kubernetes clients.
+func (kmn *UpgradeMasterNode) masterURL() string {
+	if kmn.PrivateDNSSuffix != "" {
+		return kmn.UpgradeContainerService.Properties.GetDNSPrefix() + "." + strings.TrimPrefix(kmn.PrivateDNSSuffix, ".")
+	}
+	return kmn.UpgradeContainerService.Properties.MasterProfile.FQDN
+}
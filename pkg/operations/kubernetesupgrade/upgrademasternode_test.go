@@ -0,0 +1,1438 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	v1 "k8s.io/api/core/v1"
+)
+
+const testProximityPlacementGroupID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/ppgrg/providers/Microsoft.Compute/proximityPlacementGroups/ppg1"
+
+const testDiskEncryptionSetID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/desrg/providers/Microsoft.Compute/diskEncryptionSets/des1"
+
+// newTestMasterTemplate returns a trimmed-down upgrade template containing a master VM,
+// a master NIC and an agent VM.
+func newTestMasterTemplate() map[string]interface{} {
+	return map[string]interface{}{
+		"variables": map[string]interface{}{},
+		"resources": []interface{}{
+			map[string]interface{}{
+				"type": "Microsoft.Compute/virtualMachines",
+				"name": "[concat(variables('masterVMNamePrefix'), copyIndex(variables('masterOffset')))]",
+				"properties": map[string]interface{}{
+					"hardwareProfile": map[string]interface{}{
+						"vmSize": "[parameters('masterVMSize')]",
+					},
+				},
+			},
+			map[string]interface{}{
+				"type": "Microsoft.Network/networkInterfaces",
+				"name": "[concat(variables('masterVMNamePrefix'), 'nic-', copyIndex(variables('masterOffset')))]",
+				"properties": map[string]interface{}{
+					"ipConfigurations": []interface{}{
+						map[string]interface{}{
+							"name": "ipconfig1",
+							"properties": map[string]interface{}{
+								"subnet": map[string]interface{}{
+									"id": "[variables('vnetSubnetID')]",
+								},
+							},
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"type":       "Microsoft.Compute/virtualMachines",
+				"name":       "[concat(variables('agentpool1VMNamePrefix'), copyIndex(variables('agentpool1Offset')))]",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+func newTestUpgradeMasterNode(client armhelpers.AKSEngineClient) *UpgradeMasterNode {
+	return &UpgradeMasterNode{
+		Translator:              &i18n.Translator{},
+		logger:                  log.NewEntry(log.New()),
+		TemplateMap:             newTestMasterTemplate(),
+		ParametersMap:           map[string]interface{}{},
+		UpgradeContainerService: api.CreateMockContainerService("testcluster", "", 3, 1, false),
+		SubscriptionID:          "DEC923E3-1EF1-4745-9516-37906D56DEC4",
+		ResourceGroup:           "TestRg",
+		Client:                  client,
+	}
+}
+
+var _ = Describe("Upgrade master node tests", func() {
+	Context("ProximityPlacementGroupID", func() {
+		It("Should inject the proximity placement group into master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ProximityPlacementGroupID = testProximityPlacementGroupID
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(vms).To(HaveLen(1))
+			Expect(resourceProperties(vms[0])["proximityPlacementGroup"]).To(Equal(map[string]interface{}{
+				"id": testProximityPlacementGroupID,
+			}))
+
+			nics := masterResources(kmn.TemplateMap, nicResourceType)
+			Expect(nics).To(HaveLen(1))
+			Expect(resourceProperties(nics[0])).NotTo(HaveKey("proximityPlacementGroup"))
+
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("proximityPlacementGroup"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(resourceProperties(vms[0])).NotTo(HaveKey("proximityPlacementGroup"))
+		})
+
+		It("Should pass preflight when the proximity placement group is in the cluster region", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ProximityPlacementGroupID = testProximityPlacementGroupID
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+		})
+
+		It("Should fail preflight when the proximity placement group does not exist", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{
+				FailGetProximityPlacementGroup: true,
+			})
+			kmn.ProximityPlacementGroupID = testProximityPlacementGroupID
+
+			err := kmn.Preflight(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("GetProximityPlacementGroup failed"))
+		})
+
+		It("Should fail preflight when the proximity placement group is in another region", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{
+				FakeGetProximityPlacementGroupResult: func() compute.ProximityPlacementGroup {
+					return compute.ProximityPlacementGroup{
+						Name:     to.StringPtr("ppg1"),
+						Location: to.StringPtr("West US 2"),
+					}
+				},
+			})
+			kmn.ProximityPlacementGroupID = testProximityPlacementGroupID
+
+			err := kmn.Preflight(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("proximity placement group ppg1 is in region westus2, expected eastus"))
+		})
+
+		It("Should fail preflight when the proximity placement group ID is malformed", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ProximityPlacementGroupID = "ppg1"
+
+			Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+		})
+	})
+
+	Context("VMAPIVersion", func() {
+		It("Should override the apiVersion of master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.VMAPIVersion = "2020-06-01"
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(vms[0]["apiVersion"]).To(Equal("2020-06-01"))
+			nics := masterResources(kmn.TemplateMap, nicResourceType)
+			Expect(nics[0]).NotTo(HaveKey("apiVersion"))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(agent).NotTo(HaveKey("apiVersion"))
+		})
+
+		It("Should refuse to deploy a malformed API version", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FailDeployTemplate: true}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.VMAPIVersion = "latest"
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid VM API version"))
+			Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+		})
+
+		It("Should validate the API version format", func() {
+			for _, v := range []string{"2019-07-01", "2020-12-01-preview"} {
+				Expect(ValidateVMAPIVersion(v)).To(Succeed(), v)
+			}
+			for _, v := range []string{"", "2019-7-1", "2019-07-01-beta", "v2019-07-01", "[variables('apiVersionCompute')]"} {
+				Expect(ValidateVMAPIVersion(v)).NotTo(Succeed(), v)
+			}
+		})
+	})
+
+	Context("CloudInitScript", func() {
+		cloudConfig := base64.StdEncoding.EncodeToString([]byte("#cloud-config\nruncmd:\n- echo compliant\n"))
+
+		It("Should set the custom data of master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.CloudInitScript = cloudConfig
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			variables := kmn.TemplateMap["variables"].(map[string]interface{})
+			Expect(variables[masterCloudInitScriptVariable]).To(Equal(cloudConfig))
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(resourceProperties(vms[0])["osProfile"]).To(Equal(map[string]interface{}{
+				"customData": "[variables('masterCloudInitScript')]",
+			}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("osProfile"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(kmn.TemplateMap["variables"]).NotTo(HaveKey(masterCloudInitScriptVariable))
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])).NotTo(HaveKey("osProfile"))
+		})
+
+		It("Should refuse to deploy an invalid script", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.CloudInitScript = base64.StdEncoding.EncodeToString([]byte("echo not a script"))
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid cloud-init script"))
+			Expect(kmn.deploymentNames).To(BeEmpty())
+			Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+		})
+
+		It("Should validate the script", func() {
+			for _, script := range []string{"#cloud-config\n", "#!/bin/bash\necho hello\n"} {
+				Expect(ValidateCloudInitScript(base64.StdEncoding.EncodeToString([]byte(script)))).To(Succeed(), script)
+			}
+			Expect(ValidateCloudInitScript("#cloud-config")).To(MatchError(ContainSubstring("decoding base64 cloud-init script")))
+			Expect(ValidateCloudInitScript(base64.StdEncoding.EncodeToString([]byte("cloud-config\n")))).NotTo(Succeed())
+		})
+	})
+
+	Context("PostDeleteWait", func() {
+		It("Should wait after deleting the master VM", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.PostDeleteWait = 100 * time.Millisecond
+
+			start := time.Now()
+			Expect(kmn.DeleteNode(to.StringPtr("k8s-master-12345678-0"), false)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", kmn.PostDeleteWait))
+		})
+
+		It("Should not wait when the VM could not be deleted", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailDeleteVirtualMachine: true})
+			kmn.PostDeleteWait = time.Hour
+
+			Expect(kmn.DeleteNode(to.StringPtr("k8s-master-12345678-0"), false)).NotTo(Succeed())
+		})
+	})
+
+	Context("PrivateDNSSuffix", func() {
+		It("Should use the master FQDN by default", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.UpgradeContainerService.Properties.MasterProfile.FQDN = "testcluster.eastus.cloudapp.azure.com"
+
+			Expect(kmn.masterURL()).To(Equal("testcluster.eastus.cloudapp.azure.com"))
+		})
+
+		It("Should build the master URL from the private DNS suffix", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.UpgradeContainerService.Properties.MasterProfile.FQDN = "testcluster.eastus.cloudapp.azure.com"
+			kmn.UpgradeContainerService.Properties.MasterProfile.DNSPrefix = "TestCluster"
+			kmn.PrivateDNSSuffix = ".k8s.corp.contoso.com"
+
+			Expect(kmn.masterURL()).To(Equal("testcluster.k8s.corp.contoso.com"))
+		})
+	})
+
+	Context("masterOffset and masterCount", func() {
+		It("Should deploy masterOffset + 1 masters for each master index", func() {
+			for masterNo := 0; masterNo <= 4; masterNo++ {
+				kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+				Expect(kmn.CreateNode(context.Background(), "master", masterNo)).To(Succeed())
+
+				templateVariables := kmn.TemplateMap["variables"].(map[string]interface{})
+				Expect(templateVariables["masterOffset"]).To(Equal(masterNo))
+				Expect(templateVariables["masterCount"]).To(Equal(masterNo + 1))
+				Expect(validateMasterOffsetVariables(templateVariables)).To(Succeed())
+			}
+		})
+
+		It("Should refuse to deploy a negative master index", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			err := kmn.CreateNode(context.Background(), "master", -1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("masterOffset -1 and masterCount 0 are inconsistent"))
+		})
+
+		It("Should reject inconsistent template variables", func() {
+			for masterNo := 0; masterNo <= 4; masterNo++ {
+				for _, masterCount := range []int{masterNo, masterNo + 2} {
+					err := validateMasterOffsetVariables(map[string]interface{}{"masterOffset": masterNo, "masterCount": masterCount})
+					Expect(err).To(HaveOccurred())
+				}
+			}
+			Expect(validateMasterOffsetVariables(map[string]interface{}{"masterOffset": "0", "masterCount": 1})).NotTo(Succeed())
+			Expect(validateMasterOffsetVariables(map[string]interface{}{"masterOffset": 0})).NotTo(Succeed())
+		})
+	})
+
+	Context("ExistingDeploymentName", func() {
+		It("Should generate a deployment name by default", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(kmn.deploymentNames).To(HaveLen(1))
+			Expect(kmn.deploymentNames[0]).To(HavePrefix("k8s-upgrade-master-0-"))
+		})
+
+		It("Should deploy with the existing deployment name", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ExistingDeploymentName = "cluster-masters"
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 1)).To(Succeed())
+
+			Expect(kmn.deploymentNames).To(Equal([]string{"cluster-masters", "cluster-masters"}))
+		})
+
+		It("Should warn when the deployment already exists", func() {
+			logger, hook := logtest.NewNullLogger()
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{
+				FakeCheckDeploymentExistenceResult: func(name string) bool { return name == "cluster-masters" },
+			})
+			kmn.logger = log.NewEntry(logger)
+			kmn.ExistingDeploymentName = "cluster-masters"
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			var warnings []string
+			for _, entry := range hook.Entries {
+				if entry.Level == log.WarnLevel {
+					warnings = append(warnings, entry.Message)
+				}
+			}
+			Expect(warnings).To(ConsistOf("Deployment cluster-masters already exists in resource group TestRg, ARM will update it"))
+		})
+
+		It("Should deploy when the deployment existence cannot be checked", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailCheckDeploymentExistence: true})
+			kmn.ExistingDeploymentName = "cluster-masters"
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		})
+	})
+
+	Context("NodeJoinTimeout", func() {
+		var (
+			kubeClient *armhelpers.MockKubernetesClient
+			kmn        *UpgradeMasterNode
+		)
+
+		BeforeEach(func() {
+			kubeClient = &armhelpers.MockKubernetesClient{}
+			kmn = newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient})
+			kmn.timeout = time.Hour
+			kmn.NodeJoinTimeout = 50 * time.Millisecond
+		})
+
+		It("Should succeed when the node joins and is ready", func() {
+			Expect(kmn.Validate(to.StringPtr("k8s-master-12345678-0"))).To(Succeed())
+		})
+
+		It("Should return a NodeJoinTimeoutError when the node never registers", func() {
+			kubeClient.FailGetNode = true
+
+			err := kmn.Validate(to.StringPtr("K8S-MASTER-12345678-0"))
+			Expect(err).To(HaveOccurred())
+			joinErr, ok := err.(*NodeJoinTimeoutError)
+			Expect(ok).To(BeTrue())
+			Expect(joinErr.NodeName).To(Equal("k8s-master-12345678-0"))
+			Expect(err.Error()).To(ContainSubstring("did not register with the API server within 50ms"))
+			Expect(err.Error()).To(ContainSubstring("/var/log/cloud-init-output.log"))
+		})
+
+		It("Should wait for readiness once the node joined", func() {
+			kubeClient.GetNodeFunc = func(name string) (*v1.Node, error) {
+				node := &v1.Node{}
+				node.Name = name
+				return node, nil
+			}
+			kmn.timeout = 200 * time.Millisecond
+
+			err := kmn.Validate(to.StringPtr("k8s-master-12345678-0"))
+			Expect(err).To(HaveOccurred())
+			_, ok := err.(*NodeJoinTimeoutError)
+			Expect(ok).To(BeFalse())
+			Expect(err.Error()).To(Equal("Node was not ready within 200ms"))
+		})
+	})
+
+	Context("TargetAvailabilityZone", func() {
+		zonalSkus := func() []compute.ResourceSku {
+			return []compute.ResourceSku{
+				{
+					Name:         to.StringPtr("Standard_D2_v2"),
+					ResourceType: to.StringPtr("disks"),
+					LocationInfo: &[]compute.ResourceSkuLocationInfo{{Location: to.StringPtr("eastus"), Zones: &[]string{"4"}}},
+				},
+				{
+					Name:         to.StringPtr("Standard_D2_v2"),
+					ResourceType: to.StringPtr("virtualMachines"),
+					LocationInfo: &[]compute.ResourceSkuLocationInfo{{Location: to.StringPtr("eastus"), Zones: &[]string{"1", "2", "3"}}},
+				},
+			}
+		}
+
+		It("Should return the availability zone of the VM", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeGetVirtualMachineZones: []string{"2"}})
+
+			zone, err := kmn.GetVMAvailabilityZone(context.Background(), "k8s-master-12345678-0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(zone).To(Equal("2"))
+		})
+
+		It("Should return an empty zone for a VM without zone", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			zone, err := kmn.GetVMAvailabilityZone(context.Background(), "k8s-master-12345678-0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(zone).To(BeEmpty())
+		})
+
+		It("Should fail when the VM cannot be read", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailGetVirtualMachine: true})
+
+			_, err := kmn.GetVMAvailabilityZone(context.Background(), "k8s-master-12345678-0")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("getting virtual machine k8s-master-12345678-0"))
+		})
+
+		It("Should inject the availability zone into master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: zonalSkus})
+			kmn.TargetAvailabilityZone = "2"
+
+			Expect(kmn.CreateNode(context.Background(), "master", 1)).To(Succeed())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(vms[0]["zones"]).To(Equal([]interface{}{"2"}))
+			Expect(masterResources(kmn.TemplateMap, nicResourceType)[0]).NotTo(HaveKey("zones"))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(agent).NotTo(HaveKey("zones"))
+		})
+
+		It("Should fail when the zone is not supported in the region", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: zonalSkus})
+			kmn.TargetAvailabilityZone = "4"
+
+			err := kmn.CreateNode(context.Background(), "master", 1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`availability zone "4" is not supported by VM size Standard_D2_v2 in location eastus, supported zones: [1 2 3]`))
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+	})
+
+	Context("UltraDiskEnabled", func() {
+		ultraSSDAvailable := &[]compute.ResourceSkuCapabilities{{Name: to.StringPtr("UltraSSDAvailable"), Value: to.StringPtr("True")}}
+		zonalUltraSkus := func() []compute.ResourceSku {
+			return []compute.ResourceSku{{
+				Name:         to.StringPtr("Standard_D2_v2"),
+				ResourceType: to.StringPtr("virtualMachines"),
+				LocationInfo: &[]compute.ResourceSkuLocationInfo{{
+					Location:    to.StringPtr("eastus"),
+					Zones:       &[]string{"1", "2", "3"},
+					ZoneDetails: &[]compute.ResourceSkuZoneDetails{{Name: &[]string{"1", "3"}, Capabilities: ultraSSDAvailable}},
+				}},
+			}}
+		}
+
+		It("Should enable ultra disks on master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: zonalUltraSkus})
+			kmn.UltraDiskEnabled = true
+			kmn.TargetAvailabilityZone = "3"
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(resourceProperties(vms[0])["additionalCapabilities"]).To(Equal(map[string]interface{}{"ultraSSDEnabled": true}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("additionalCapabilities"))
+		})
+
+		It("Should accept a VM size supporting ultra disks in the whole region", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: func() []compute.ResourceSku {
+				return []compute.ResourceSku{{
+					Name:         to.StringPtr("Standard_D2_v2"),
+					ResourceType: to.StringPtr("virtualMachines"),
+					Capabilities: ultraSSDAvailable,
+				}}
+			}})
+			kmn.UltraDiskEnabled = true
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		})
+
+		It("Should fail when the VM size does not support ultra disks", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.UltraDiskEnabled = true
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("ultra disks are not supported by VM size Standard_D2_v2 in location eastus"))
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+
+		It("Should fail when the availability zone does not support ultra disks", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: zonalUltraSkus})
+			kmn.UltraDiskEnabled = true
+			kmn.TargetAvailabilityZone = "2"
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(MatchError(`ultra disks are not supported by VM size Standard_D2_v2 in availability zone "2" of location eastus, supported zones: [1 3]`))
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])).NotTo(HaveKey("additionalCapabilities"))
+		})
+	})
+
+	Context("OSDiskStorageAccountType", func() {
+		premiumSkus := func() []compute.ResourceSku {
+			return []compute.ResourceSku{{
+				Name:         to.StringPtr("Standard_D2_v2"),
+				ResourceType: to.StringPtr("virtualMachines"),
+				Capabilities: &[]compute.ResourceSkuCapabilities{{Name: to.StringPtr("PremiumIO"), Value: to.StringPtr("True")}},
+			}}
+		}
+
+		It("Should set the OS disk type of master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: premiumSkus})
+			kmn.OSDiskStorageAccountType = "Premium_LRS"
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(kmn.TemplateMap["variables"]).To(HaveKeyWithValue("masterOSDiskStorageAccountType", "Premium_LRS"))
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(resourceProperties(vms[0])["storageProfile"]).To(Equal(map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"managedDisk": map[string]interface{}{
+						"storageAccountType": "[variables('masterOSDiskStorageAccountType')]",
+					},
+				},
+			}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("storageProfile"))
+		})
+
+		It("Should keep the other OS disk properties of the template", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.OSDiskStorageAccountType = "StandardSSD_LRS"
+			vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+			resourceProperties(vm)["storageProfile"] = map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"caching":      "ReadWrite",
+					"createOption": "FromImage",
+					"managedDisk":  map[string]interface{}{"storageAccountType": "Standard_LRS"},
+				},
+			}
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(kmn.TemplateMap["variables"]).To(HaveKeyWithValue("masterOSDiskStorageAccountType", "StandardSSD_LRS"))
+			Expect(resourceProperties(vm)["storageProfile"]).To(Equal(map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"caching":      "ReadWrite",
+					"createOption": "FromImage",
+					"managedDisk":  map[string]interface{}{"storageAccountType": "[variables('masterOSDiskStorageAccountType')]"},
+				},
+			}))
+		})
+
+		It("Should fail when the VM size does not support premium storage", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.OSDiskStorageAccountType = "Premium_LRS"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("OS disk storage account type Premium_LRS is not supported by VM size Standard_D2_v2 in location eastus"))
+		})
+
+		It("Should reject the disk types not supported for OS disks", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.OSDiskStorageAccountType = "UltraSSD_LRS"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError(`invalid OS disk storage account type "UltraSSD_LRS", expected Premium_LRS, StandardSSD_LRS or Standard_LRS`))
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(kmn.TemplateMap["variables"]).NotTo(HaveKey("masterOSDiskStorageAccountType"))
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])).NotTo(HaveKey("storageProfile"))
+		})
+	})
+
+	Context("OSDiskCachingMode", func() {
+		It("Should set the OS disk caching of master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.OSDiskCachingMode = "ReadOnly"
+			vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+			resourceProperties(vm)["storageProfile"] = map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"caching":      "ReadWrite",
+					"createOption": "FromImage",
+				},
+			}
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(kmn.TemplateMap["variables"]).To(HaveKeyWithValue("masterOSDiskCachingMode", "ReadOnly"))
+			Expect(resourceProperties(vm)["storageProfile"]).To(Equal(map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"caching":      "[variables('masterOSDiskCachingMode')]",
+					"createOption": "FromImage",
+				},
+			}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("storageProfile"))
+		})
+
+		It("Should set the OS disk caching along with the OS disk type", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.OSDiskCachingMode = "None"
+			kmn.OSDiskStorageAccountType = "Standard_LRS"
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(kmn.TemplateMap["variables"]).To(HaveKeyWithValue("masterOSDiskCachingMode", "None"))
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])["storageProfile"]).To(Equal(map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"caching":     "[variables('masterOSDiskCachingMode')]",
+					"managedDisk": map[string]interface{}{"storageAccountType": "[variables('masterOSDiskStorageAccountType')]"},
+				},
+			}))
+		})
+
+		It("Should reject unknown caching modes", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.OSDiskCachingMode = "WriteOnly"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError(`invalid OS disk caching mode "WriteOnly", expected None, ReadOnly or ReadWrite`))
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(kmn.TemplateMap["variables"]).NotTo(HaveKey("masterOSDiskCachingMode"))
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])).NotTo(HaveKey("storageProfile"))
+		})
+	})
+
+	Context("DiskEncryptionSetID", func() {
+		encryptedOSDisk := func() *compute.OSDisk {
+			return &compute.OSDisk{
+				Name: to.StringPtr("k8s-master-12345678-0_OsDisk_1"),
+				ManagedDisk: &compute.ManagedDiskParameters{
+					DiskEncryptionSet: &compute.DiskEncryptionSetParameters{ID: to.StringPtr(testDiskEncryptionSetID)},
+				},
+			}
+		}
+
+		It("Should encrypt the OS disk of master VM resources only", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeGetVirtualMachineOSDisk: encryptedOSDisk()}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.DiskEncryptionSetID = testDiskEncryptionSetID
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(osDiskProperties(vms[0])["managedDisk"]).To(Equal(map[string]interface{}{
+				"diskEncryptionSet": map[string]interface{}{
+					"id": testDiskEncryptionSetID,
+				},
+			}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("storageProfile"))
+		})
+
+		It("Should fail when the OS disk of the new VM is not encrypted with the disk encryption set", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeGetVirtualMachineOSDisk: &compute.OSDisk{
+				Name:        to.StringPtr("k8s-master-12345678-0_OsDisk_1"),
+				ManagedDisk: &compute.ManagedDiskParameters{},
+			}}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.DiskEncryptionSetID = testDiskEncryptionSetID
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`is encrypted with disk encryption set "", expected ` + testDiskEncryptionSetID))
+		})
+
+		It("Should fail preflight when the disk encryption set does not exist", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{
+				FailGetDiskEncryptionSet: true,
+			})
+			kmn.DiskEncryptionSetID = testDiskEncryptionSetID
+
+			err := kmn.Preflight(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("getting disk encryption set " + testDiskEncryptionSetID))
+		})
+
+		It("Should fail preflight when the disk encryption set is in another region", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{
+				FakeGetDiskEncryptionSetResult: func() compute.DiskEncryptionSet {
+					return compute.DiskEncryptionSet{
+						Name:     to.StringPtr("des1"),
+						Location: to.StringPtr("West US 2"),
+					}
+				},
+			})
+			kmn.DiskEncryptionSetID = testDiskEncryptionSetID
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("disk encryption set des1 is in region westus2, expected eastus"))
+		})
+
+		It("Should fail preflight when the disk encryption set ID is malformed", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.DiskEncryptionSetID = "des1"
+
+			Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+		})
+	})
+
+	Context("BootDiagnostics", func() {
+		storageURI := "https://bootdiagnostics.blob.core.windows.net/"
+
+		It("Should enable the boot diagnostics of master VM resources only", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeGetVirtualMachineDiagnosticsProfile: &compute.DiagnosticsProfile{
+				BootDiagnostics: &compute.BootDiagnostics{Enabled: to.BoolPtr(true), StorageURI: to.StringPtr("https://BootDiagnostics.blob.core.windows.net")},
+			}}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.BootDiagnosticsEnabled = true
+			kmn.BootDiagnosticsStorageAccountURI = storageURI
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			properties := resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])
+			Expect(properties["diagnosticsProfile"]).To(Equal(map[string]interface{}{
+				"bootDiagnostics": map[string]interface{}{
+					"enabled":    true,
+					"storageUri": storageURI,
+				},
+			}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("diagnosticsProfile"))
+		})
+
+		It("Should store the boot diagnostics in a managed storage account by default", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeGetVirtualMachineDiagnosticsProfile: &compute.DiagnosticsProfile{
+				BootDiagnostics: &compute.BootDiagnostics{Enabled: to.BoolPtr(true)},
+			}}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.BootDiagnosticsEnabled = true
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			properties := resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])
+			Expect(properties["diagnosticsProfile"]).To(Equal(map[string]interface{}{
+				"bootDiagnostics": map[string]interface{}{"enabled": true},
+			}))
+		})
+
+		It("Should fail when boot diagnostics are not enabled on the new VM", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.BootDiagnosticsEnabled = true
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("boot diagnostics are not enabled on VM " + kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + "0"))
+		})
+
+		It("Should fail when the boot diagnostics of the new VM are stored in another storage account", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeGetVirtualMachineDiagnosticsProfile: &compute.DiagnosticsProfile{
+				BootDiagnostics: &compute.BootDiagnostics{Enabled: to.BoolPtr(true)},
+			}}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.BootDiagnosticsEnabled = true
+			kmn.BootDiagnosticsStorageAccountURI = storageURI
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`are stored in "", expected ` + storageURI))
+		})
+
+		It("Should fail preflight with an invalid storage account URI", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.BootDiagnosticsEnabled = true
+			kmn.BootDiagnosticsStorageAccountURI = "http://bootdiagnostics.blob.core.windows.net/"
+
+			Expect(kmn.Preflight(context.Background())).NotTo(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+		})
+
+		It("Should fail preflight with a storage account URI but no boot diagnostics", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.BootDiagnosticsStorageAccountURI = storageURI
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("a boot diagnostics storage account URI requires enabling boot diagnostics"))
+		})
+	})
+
+	Context("VMPriority", func() {
+		It("Should set the priority and the default eviction policy of Spot master VM resources only", func() {
+			logger, hook := logtest.NewNullLogger()
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.logger = log.NewEntry(logger)
+			kmn.VMPriority = "Spot"
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			properties := resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])
+			Expect(properties).To(HaveKeyWithValue("priority", "Spot"))
+			Expect(properties).To(HaveKeyWithValue("evictionPolicy", "Deallocate"))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("priority"))
+			var warnings []string
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == log.WarnLevel {
+					warnings = append(warnings, entry.Message)
+				}
+			}
+			Expect(warnings).To(ConsistOf(ContainSubstring("Master VMs of Spot priority can be evicted")))
+		})
+
+		It("Should set the eviction policy of Spot master VMs", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.VMPriority = "Low"
+			kmn.EvictionPolicy = "Delete"
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			properties := resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])
+			Expect(properties).To(HaveKeyWithValue("priority", "Low"))
+			Expect(properties).To(HaveKeyWithValue("evictionPolicy", "Delete"))
+		})
+
+		It("Should remove the eviction policy of the template from Regular master VMs", func() {
+			logger, hook := logtest.NewNullLogger()
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.logger = log.NewEntry(logger)
+			kmn.VMPriority = "Regular"
+			vm := masterResources(kmn.TemplateMap, vmResourceType)[0]
+			resourceProperties(vm)["priority"] = "Spot"
+			resourceProperties(vm)["evictionPolicy"] = "Deallocate"
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(resourceProperties(vm)).To(HaveKeyWithValue("priority", "Regular"))
+			Expect(resourceProperties(vm)).NotTo(HaveKey("evictionPolicy"))
+			for _, entry := range hook.AllEntries() {
+				Expect(entry.Level).NotTo(Equal(log.WarnLevel))
+			}
+		})
+
+		It("Should reject invalid priorities and eviction policies", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.VMPriority = "Premium"
+			Expect(kmn.Preflight(context.Background())).To(MatchError(`invalid VM priority "Premium", expected Regular, Spot or Low`))
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+			Expect(kmn.deploymentNames).To(BeEmpty())
+
+			kmn.VMPriority = "Spot"
+			kmn.EvictionPolicy = "Stop"
+			Expect(kmn.Preflight(context.Background())).To(MatchError(`invalid eviction policy "Stop", expected Deallocate or Delete`))
+
+			kmn.VMPriority = "Regular"
+			kmn.EvictionPolicy = "Delete"
+			Expect(kmn.Preflight(context.Background())).To(MatchError("an eviction policy requires the Spot VM priority"))
+
+			kmn.VMPriority = ""
+			Expect(kmn.Preflight(context.Background())).To(MatchError("an eviction policy requires the Spot VM priority"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			properties := resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])
+			Expect(properties).NotTo(HaveKey("priority"))
+			Expect(properties).NotTo(HaveKey("evictionPolicy"))
+		})
+	})
+
+	Context("AcceleratedNetworking", func() {
+		acceleratedNetworkingSkus := func() []compute.ResourceSku {
+			return []compute.ResourceSku{{
+				Name:         to.StringPtr("Standard_D2_v2"),
+				ResourceType: to.StringPtr("virtualMachines"),
+				Capabilities: &[]compute.ResourceSkuCapabilities{{Name: to.StringPtr("AcceleratedNetworkingEnabled"), Value: to.StringPtr("True")}},
+			}}
+		}
+		warnings := func(hook *logtest.Hook) []string {
+			var messages []string
+			for _, entry := range hook.Entries {
+				if entry.Level == log.WarnLevel {
+					messages = append(messages, entry.Message)
+				}
+			}
+			return messages
+		}
+
+		It("Should enable Accelerated Networking on master NIC resources only", func() {
+			logger, hook := logtest.NewNullLogger()
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeListResourceSkusResult: acceleratedNetworkingSkus})
+			kmn.logger = log.NewEntry(logger)
+			kmn.AcceleratedNetworking = true
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(warnings(hook)).To(BeEmpty())
+			nics := masterResources(kmn.TemplateMap, nicResourceType)
+			Expect(resourceProperties(nics[0])["enableAcceleratedNetworking"]).To(BeTrue())
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])).NotTo(HaveKey("enableAcceleratedNetworking"))
+		})
+
+		It("Should warn when the VM size does not support Accelerated Networking", func() {
+			logger, hook := logtest.NewNullLogger()
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.logger = log.NewEntry(logger)
+			kmn.AcceleratedNetworking = true
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(warnings(hook)).To(ConsistOf("Accelerated Networking is not supported by VM size Standard_D2_v2 in location eastus"))
+		})
+
+		It("Should fail the preflight when the resource SKUs cannot be listed", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailListResourceSkus: true})
+			kmn.AcceleratedNetworking = true
+
+			err := kmn.Preflight(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("listing resource SKUs"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])).NotTo(HaveKey("enableAcceleratedNetworking"))
+		})
+	})
+
+	Context("ApplicationSecurityGroups", func() {
+		const (
+			asg1 = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/applicationSecurityGroups/masters"
+			asg2 = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/netrg/providers/Microsoft.Network/applicationSecurityGroups/apiserver"
+		)
+		masterNIC := func(kmn *UpgradeMasterNode, groups ...string) network.Interface {
+			vmName := kmn.UpgradeContainerService.Properties.GetMasterVMPrefix() + "0"
+			var asgs []network.ApplicationSecurityGroup
+			for _, id := range groups {
+				asgs = append(asgs, network.ApplicationSecurityGroup{ID: to.StringPtr(id)})
+			}
+			return network.Interface{
+				Name: to.StringPtr(vmName + "-nic"),
+				InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+					VirtualMachine: &network.SubResource{ID: to.StringPtr("/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Compute/virtualMachines/" + vmName)},
+					IPConfigurations: &[]network.InterfaceIPConfiguration{{
+						InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+							ApplicationSecurityGroups: &asgs,
+						},
+					}},
+				},
+			}
+		}
+
+		It("Should add the application security groups to the master NIC resources and verify the created NIC", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ApplicationSecurityGroups = []string{asg1, asg2}
+			mockClient.FakeListNetworkInterfacesResult = func() []network.Interface {
+				return []network.Interface{masterNIC(kmn, asg2, strings.ToLower(asg1))}
+			}
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			ipConfigurations := resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])["ipConfigurations"].([]interface{})
+			Expect(resourceProperties(ipConfigurations[0].(map[string]interface{}))["applicationSecurityGroups"]).To(Equal([]interface{}{
+				map[string]interface{}{"id": asg1},
+				map[string]interface{}{"id": asg2},
+			}))
+			Expect(resourceProperties(masterResources(kmn.TemplateMap, vmResourceType)[0])).NotTo(HaveKey("applicationSecurityGroups"))
+		})
+
+		It("Should keep the application security groups of the template", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ApplicationSecurityGroups = []string{asg1, asg2}
+			mockClient.FakeListNetworkInterfacesResult = func() []network.Interface {
+				return []network.Interface{masterNIC(kmn, asg1, asg2)}
+			}
+			ipConfigurations := resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])["ipConfigurations"].([]interface{})
+			resourceProperties(ipConfigurations[0].(map[string]interface{}))["applicationSecurityGroups"] = []interface{}{
+				map[string]interface{}{"id": asg1},
+			}
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(resourceProperties(ipConfigurations[0].(map[string]interface{}))["applicationSecurityGroups"]).To(Equal([]interface{}{
+				map[string]interface{}{"id": asg1},
+				map[string]interface{}{"id": asg2},
+			}))
+		})
+
+		It("Should fail when the created NIC is not in the application security groups", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ApplicationSecurityGroups = []string{asg1, asg2}
+			mockClient.FakeListNetworkInterfacesResult = func() []network.Interface {
+				return []network.Interface{masterNIC(kmn, asg1)}
+			}
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is missing the application security groups " + asg2))
+		})
+
+		It("Should fail when the NIC of the created VM is not found", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ApplicationSecurityGroups = []string{asg1}
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("no network interface of VM"))
+		})
+
+		It("Should fail the preflight for IDs of other resource types", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ApplicationSecurityGroups = []string{testProximityPlacementGroupID}
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError(testProximityPlacementGroupID + " is not the resource ID of an application security group"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			ipConfigurations := resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])["ipConfigurations"].([]interface{})
+			Expect(resourceProperties(ipConfigurations[0].(map[string]interface{}))).NotTo(HaveKey("applicationSecurityGroups"))
+		})
+	})
+
+	Context("RequiredSchemaVersion", func() {
+		It("Should pass preflight when the api model has the required schema version", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.APIModelVersion = "vlabs"
+			kmn.RequiredSchemaVersion = "vlabs"
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+		})
+
+		It("Should fail preflight when the api model has another schema version", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.APIModelVersion = "2017-07-01"
+			kmn.RequiredSchemaVersion = "vlabs"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("api model schema version 2017-07-01 does not match the required schema version vlabs"))
+		})
+
+		It("Should fail preflight when the schema version of the api model is unknown", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.RequiredSchemaVersion = "vlabs"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("the api model schema version is unknown, expected vlabs"))
+		})
+
+		It("Should accept any schema version when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.APIModelVersion = "2017-07-01"
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+		})
+	})
+
+	Context("ReplacementSubnetID", func() {
+		replacementSubnetID := func(kmn *UpgradeMasterNode) string {
+			clusterID := kmn.UpgradeContainerService.Properties.GetClusterID()
+			return "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/virtualNetworks/k8s-vnet-" + clusterID + "/subnets/masters2"
+		}
+
+		It("Should attach the master NICs to the replacement subnet variable", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ReplacementSubnetID = replacementSubnetID(kmn)
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			variables := kmn.TemplateMap["variables"].(map[string]interface{})
+			Expect(variables[masterReplacementSubnetIDVariable]).To(Equal(kmn.ReplacementSubnetID))
+			ipConfigurations := resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])["ipConfigurations"].([]interface{})
+			Expect(resourceProperties(ipConfigurations[0].(map[string]interface{}))["subnet"]).To(Equal(map[string]interface{}{
+				"id": "[variables('masterReplacementSubnetID')]",
+			}))
+		})
+
+		It("Should fail the preflight when the subnet is in another virtual network", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ReplacementSubnetID = testSubnetID
+
+			err := kmn.Preflight(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("replacement subnet " + testSubnetID + " is not in the virtual network of the cluster"))
+		})
+
+		It("Should fail the preflight for a malformed subnet ID", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ReplacementSubnetID = "masters2"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("unable to parse subnet ID masters2"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(kmn.TemplateMap["variables"]).NotTo(HaveKey(masterReplacementSubnetIDVariable))
+			ipConfigurations := resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])["ipConfigurations"].([]interface{})
+			Expect(resourceProperties(ipConfigurations[0].(map[string]interface{}))["subnet"]).To(Equal(map[string]interface{}{
+				"id": "[variables('vnetSubnetID')]",
+			}))
+		})
+	})
+
+	Context("VNetPeeringID", func() {
+		const testPeeredVNetID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/HubRg/providers/Microsoft.Network/virtualNetworks/hub-vnet"
+		vnetPeeringID := func(kmn *UpgradeMasterNode) string {
+			clusterID := kmn.UpgradeContainerService.Properties.GetClusterID()
+			return "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg/providers/Microsoft.Network/virtualNetworks/k8s-vnet-" + clusterID + "/virtualNetworkPeerings/to-hub"
+		}
+
+		It("Should attach the master NICs to the subnet of the peered virtual network", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.VNetPeeringID = vnetPeeringID(kmn)
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(mockClient.ResourceGroupCalls).To(ContainElement("GetSubnet HubRg"))
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			variables := kmn.TemplateMap["variables"].(map[string]interface{})
+			Expect(variables[peeredVNetIDVariable]).To(Equal(testPeeredVNetID))
+			ipConfigurations := resourceProperties(masterResources(kmn.TemplateMap, nicResourceType)[0])["ipConfigurations"].([]interface{})
+			Expect(resourceProperties(ipConfigurations[0].(map[string]interface{}))["subnet"]).To(Equal(map[string]interface{}{
+				"id": "[concat(variables('masterPeeredVNetID'), '/subnets/k8s-subnet')]",
+			}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(resourceProperties(agent)).NotTo(HaveKey("ipConfigurations"))
+		})
+
+		It("Should fail the preflight when the peering is not connected", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			mockClient.FakeGetVirtualNetworkPeeringResult = func() network.VirtualNetworkPeering {
+				return network.VirtualNetworkPeering{
+					VirtualNetworkPeeringPropertiesFormat: &network.VirtualNetworkPeeringPropertiesFormat{
+						PeeringState: network.VirtualNetworkPeeringStateInitiated,
+					},
+				}
+			}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.VNetPeeringID = vnetPeeringID(kmn)
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring(`is in state "Initiated", expected "Connected"`)))
+		})
+
+		It("Should not deploy when the peering cannot be read", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FailGetVirtualNetworkPeering: true}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.VNetPeeringID = vnetPeeringID(kmn)
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(MatchError(ContainSubstring("getting virtual network peering")))
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+
+		It("Should fail the preflight for a peering of another virtual network", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.VNetPeeringID = testPeeredVNetID + "/virtualNetworkPeerings/to-cluster"
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring("is not a peering of the virtual network of the cluster")))
+
+			kmn.VNetPeeringID = "to-hub"
+			Expect(kmn.Preflight(context.Background())).To(MatchError("unable to parse virtual network peering ID to-hub"))
+		})
+
+		It("Should fail the preflight when a replacement subnet is also set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.VNetPeeringID = vnetPeeringID(kmn)
+			kmn.ReplacementSubnetID = strings.Replace(kmn.VNetPeeringID, "virtualNetworkPeerings/to-hub", "subnets/masters2", 1)
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError("a replacement subnet and a virtual network peering cannot be set together"))
+		})
+
+		It("Should leave the template untouched when not set", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(kmn.TemplateMap["variables"]).NotTo(HaveKey(peeredVNetIDVariable))
+		})
+	})
+
+	Context("CorrelationID", func() {
+		It("Should deploy the master VMs with the given correlation ID and log it", func() {
+			logger, hook := logtest.NewNullLogger()
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.logger = log.NewEntry(logger)
+			kmn.CorrelationID = "d5062e45-6e9f-4fd3-a0a0-6b2c56b15757"
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(mockClient.DeploymentCorrelationIDs).To(Equal([]string{"d5062e45-6e9f-4fd3-a0a0-6b2c56b15757"}))
+			Expect(hook.Entries[0].Level).To(Equal(log.InfoLevel))
+			Expect(hook.Entries[0].Message).To(Equal("Correlation ID of the deployment of master VM with index 0: d5062e45-6e9f-4fd3-a0a0-6b2c56b15757"))
+		})
+
+		It("Should generate a correlation ID shared by the master VM deployments", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			kmn := newTestUpgradeMasterNode(mockClient)
+
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 1)).To(Succeed())
+			_, err := uuid.Parse(kmn.CorrelationID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockClient.DeploymentCorrelationIDs).To(Equal([]string{kmn.CorrelationID, kmn.CorrelationID}))
+		})
+	})
+
+	Context("Identity", func() {
+		const testIdentityID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/identities/providers/Microsoft.ManagedIdentity/userAssignedIdentities/masters"
+
+		It("Should assign a system-assigned identity to master VM resources only", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeGetVirtualMachineIdentity: &compute.VirtualMachineIdentity{
+				Type: compute.ResourceIdentityTypeSystemAssigned,
+			}})
+			kmn.SystemAssignedIdentity = true
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			vms := masterResources(kmn.TemplateMap, vmResourceType)
+			Expect(vms[0]["identity"]).To(Equal(map[string]interface{}{"type": "SystemAssigned"}))
+			agent := kmn.TemplateMap["resources"].([]interface{})[2].(map[string]interface{})
+			Expect(agent).NotTo(HaveKey("identity"))
+		})
+
+		It("Should add user-assigned identities to the identity of the template", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeGetVirtualMachineIdentity: &compute.VirtualMachineIdentity{
+				Type: compute.ResourceIdentityTypeSystemAssignedUserAssigned,
+				UserAssignedIdentities: map[string]*compute.VirtualMachineIdentityUserAssignedIdentitiesValue{
+					strings.ToLower(testIdentityID): {},
+				},
+			}})
+			kmn.UserAssignedIdentities = []string{testIdentityID}
+			masterResources(kmn.TemplateMap, vmResourceType)[0]["identity"] = map[string]interface{}{"type": "SystemAssigned"}
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(masterResources(kmn.TemplateMap, vmResourceType)[0]["identity"]).To(Equal(map[string]interface{}{
+				"type":                   "SystemAssigned, UserAssigned",
+				"userAssignedIdentities": map[string]interface{}{testIdentityID: map[string]interface{}{}},
+			}))
+		})
+
+		It("Should fail when the created VM does not have the system-assigned identity", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.SystemAssignedIdentity = true
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HaveSuffix(`has no system-assigned identity, identity type is ""`))
+		})
+
+		It("Should fail when the created VM is missing a user-assigned identity", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FakeGetVirtualMachineIdentity: &compute.VirtualMachineIdentity{
+				Type: compute.ResourceIdentityTypeUserAssigned,
+			}})
+			kmn.UserAssignedIdentities = []string{testIdentityID}
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is missing the user-assigned identities " + testIdentityID))
+		})
+
+		It("Should reject an ID that is not a user-assigned identity", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.UserAssignedIdentities = []string{"/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/identities/providers/Microsoft.Compute/virtualMachines/masters"}
+
+			Expect(kmn.Preflight(context.Background())).To(MatchError(ContainSubstring("is not the resource ID of a user-assigned identity")))
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).NotTo(Succeed())
+			Expect(kmn.deploymentNames).To(BeEmpty())
+		})
+	})
+
+	Context("RoleAssignments", func() {
+		const (
+			testScope            = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/resourceGroups/TestRg"
+			testRoleDefinitionID = "/subscriptions/DEC923E3-1EF1-4745-9516-37906D56DEC4/providers/Microsoft.Authorization/roleDefinitions/b24988ac-6180-42a0-ab88-20f7382dd24c"
+		)
+		var (
+			client *armhelpers.MockAKSEngineClient
+			kmn    *UpgradeMasterNode
+		)
+
+		BeforeEach(func() {
+			client = &armhelpers.MockAKSEngineClient{FakeGetVirtualMachineIdentity: &compute.VirtualMachineIdentity{
+				Type:        compute.ResourceIdentityTypeSystemAssigned,
+				PrincipalID: to.StringPtr("4a1b0a5c-0f2e-4d3b-9af1-3c0e2d1b7a9e"),
+			}}
+			kmn = newTestUpgradeMasterNode(client)
+			kmn.RoleAssignments = []RoleAssignment{{RoleDefinitionID: testRoleDefinitionID, Scope: testScope}}
+		})
+
+		It("Should assign the roles to the identity of the new master VM", func() {
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+
+			Expect(client.FakeRoleAssignments).To(HaveLen(1))
+			properties := client.FakeRoleAssignments[0].Properties
+			Expect(*properties.Scope).To(Equal(testScope))
+			Expect(*properties.RoleDefinitionID).To(Equal(testRoleDefinitionID))
+			Expect(*properties.PrincipalID).To(Equal("4a1b0a5c-0f2e-4d3b-9af1-3c0e2d1b7a9e"))
+			Expect(kmn.VerifyRoleAssignments(context.Background(), "k8s-master-12345678-0", kmn.RoleAssignments)).To(Succeed())
+		})
+
+		It("Should fail the verification of roles that are not assigned", func() {
+			err := kmn.VerifyRoleAssignments(context.Background(), "k8s-master-12345678-0", kmn.RoleAssignments)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("role " + testRoleDefinitionID + " is not assigned to VM k8s-master-12345678-0 at scope " + testScope))
+		})
+
+		It("Should fail when the new master VM has no system-assigned identity", func() {
+			client.FakeGetVirtualMachineIdentity = nil
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HaveSuffix("has no system-assigned identity to assign roles to"))
+			Expect(client.FakeRoleAssignments).To(BeEmpty())
+		})
+
+		It("Should fail when a role cannot be assigned", func() {
+			client.FailCreateRoleAssignment = true
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("assigning role " + testRoleDefinitionID + " at scope " + testScope))
+		})
+
+		It("Should reject invalid role assignments before upgrading", func() {
+			kmn.RoleAssignments = []RoleAssignment{{RoleDefinitionID: "Contributor", Scope: testScope}}
+			Expect(kmn.Preflight(context.Background())).To(MatchError(`"Contributor" is not the resource ID of a role definition`))
+
+			kmn.RoleAssignments = []RoleAssignment{{RoleDefinitionID: testRoleDefinitionID, Scope: "TestRg"}}
+			Expect(kmn.Preflight(context.Background())).To(MatchError(`invalid scope "TestRg" of the assignment of role ` + testRoleDefinitionID + `, expected a resource ID`))
+		})
+	})
+
+	Context("ExpectedOutputs", func() {
+		It("Should verify the outputs of the master VM deployment", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeDeploymentOutputs: map[string]interface{}{
+				"masterIP":    "10.239.255.239",
+				"masterCount": float64(3),
+				"vmType":      "standard",
+			}}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ExpectedOutputs = map[string]string{
+				"masterIP":    `/10\.239\.255\.\d+/`,
+				"masterCount": "3",
+			}
+
+			Expect(kmn.Preflight(context.Background())).To(Succeed())
+			Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		})
+
+		It("Should return an OutputMismatchError for missing or mismatched outputs", func() {
+			mockClient := &armhelpers.MockAKSEngineClient{FakeDeploymentOutputs: map[string]interface{}{
+				"masterIP": "10.240.0.4",
+				"vmType":   "vmss",
+			}}
+			kmn := newTestUpgradeMasterNode(mockClient)
+			kmn.ExpectedOutputs = map[string]string{
+				"masterIP":   `/10\.239\.255\.\d+/`,
+				"vmType":     "standard",
+				"fqdn":       "/.+/",
+				"resourceID": "/subscriptions/sub",
+			}
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			mismatch, ok := err.(*OutputMismatchError)
+			Expect(ok).To(BeTrue())
+			Expect(mismatch.DeploymentName).To(Equal(kmn.deploymentNames[0]))
+			Expect(mismatch.Missing).To(Equal([]string{"fqdn", "resourceID"}))
+			Expect(mismatch.Mismatched).To(Equal(map[string]string{"masterIP": "10.240.0.4", "vmType": "vmss"}))
+		})
+
+		It("Should fail when the deployment outputs cannot be read", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{FailGetDeploymentOutputs: true})
+			kmn.ExpectedOutputs = map[string]string{"masterIP": "10.239.255.239"}
+
+			err := kmn.CreateNode(context.Background(), "master", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("GetDeploymentOutputs failed"))
+		})
+
+		It("Should fail the preflight for an invalid regular expression", func() {
+			kmn := newTestUpgradeMasterNode(&armhelpers.MockAKSEngineClient{})
+			kmn.ExpectedOutputs = map[string]string{"masterIP": "/10.239.(/"}
+
+			err := kmn.Preflight(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("parsing the expected value of deployment output masterIP"))
+		})
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/url"
 	"strings"
 	"time"
 
@@ -42,6 +43,258 @@ type Upgrader struct {
 	AKSEngineVersion   string
 	CurrentVersion     string
 	ControlPlaneOnly   bool
+	// ProximityPlacementGroupID places upgraded master VMs in the given proximity placement group
+	ProximityPlacementGroupID string
+	// ResourceTags are set on the VM, NIC and disks of the upgraded master VMs
+	ResourceTags map[string]string
+	// VNetResourceGroup is the resource group of the cluster virtual network when it differs from the cluster resource group
+	VNetResourceGroup string
+	// APIModelVersion is the schema version of the loaded api model
+	APIModelVersion string
+	// RequiredSchemaVersion fails the master upgrade preflight unless APIModelVersion is the given schema version
+	RequiredSchemaVersion string
+	// ReplacementSubnetID attaches the NICs of upgraded master VMs to the given subnet of the cluster virtual network
+	ReplacementSubnetID string
+	// VNetPeeringID attaches the NICs of upgraded master VMs to the remote virtual network of the given peering
+	VNetPeeringID string
+	// CorrelationID is the correlation ID of the master VM deployments, a UUID is generated if empty
+	CorrelationID string
+	// VMAPIVersion overrides the ARM API version of the upgraded master VM resources
+	VMAPIVersion string
+	// Reporters receive an event after each node is upgraded
+	Reporters []UpgradeReporter
+	// PostDeleteWait is how long to wait between deleting and recreating a master VM, defaults to defaultPostDeleteWait
+	PostDeleteWait *time.Duration
+	// MaintenanceConfigurationID is assigned to each upgraded master VM through MaintenanceClient
+	MaintenanceConfigurationID string
+	MaintenanceClient          MaintenanceClient
+	// PolicyExemptions are created through PolicyExemptionClient while each upgraded master VM is deployed
+	PolicyExemptions      []PolicyExemption
+	PolicyExemptionClient PolicyExemptionClient
+	// PrivateEndpointConfig describes the private endpoint of the API server provisioned through PrivateEndpointClient
+	// once each upgraded master VM is created
+	PrivateEndpointConfig *PrivateEndpointConfig
+	PrivateEndpointClient PrivateEndpointClient
+	// SkipCapacityCheck disables the capacity preflight check run before draining each agent node
+	SkipCapacityCheck bool
+	// MinFreeCapacityPercent is the share of CPU and memory that must remain free after draining an agent node
+	MinFreeCapacityPercent float64
+	// GPUExtensionVersion is the NVIDIA GPU driver extension version installed on new GPU agent nodes
+	GPUExtensionVersion string
+	// GPUSKUPatternList lists the VM size patterns treated as GPU SKUs
+	GPUSKUPatternList []string
+	// DrainGracePeriod is the termination grace period of the pods evicted from agent nodes,
+	// pods use their own if zero
+	DrainGracePeriod time.Duration
+	// DrainMaxEvictionErrors is the number of pod eviction errors ignored while draining an agent node
+	// before the drain is aborted
+	DrainMaxEvictionErrors int
+	// PoolUpgradeConfigs holds per agent pool settings overriding the global ones, keyed by pool name
+	PoolUpgradeConfigs map[string]PoolUpgradeConfig
+	// DedicatedHostGroupID places upgraded master VMs on the hosts of the given dedicated host group
+	DedicatedHostGroupID string
+	// PreUpgradeHook is a command and its arguments run before any node is upgraded, the upgrade aborts if it fails
+	PreUpgradeHook []string
+	// PostUpgradeHook is a command and its arguments run after all nodes are upgraded
+	PostUpgradeHook []string
+	// EmitKubernetesEvents records the upgrade events as Kubernetes events of the kube-system namespace
+	EmitKubernetesEvents bool
+	// PreDrainHook is a command and its arguments run on each availability set agent node before it is drained
+	PreDrainHook []string
+	// AbortOnPreDrainHookFailure stops the node upgrade when PreDrainHook fails, defaults to DefaultAbortOnPreDrainHookFailure
+	AbortOnPreDrainHookFailure *bool
+	// PostCreateTaintEviction taints the new availability set agent nodes with UpgradingTaintKey until they are ready
+	PostCreateTaintEviction bool
+	// ValidateExisting makes RunUpgrade only validate the existing nodes, see ValidateExistingNodes
+	ValidateExisting bool
+	// GenerateTemplateOnly makes RunUpgrade only write the ARM template and parameters of the master VMs
+	// to TemplateOutputDirectory, see GenerateTemplate
+	GenerateTemplateOnly    bool
+	TemplateOutputDirectory string
+	// Operator identifies who runs the upgrade in the aks-engine-upgrade-history config map
+	Operator string
+	// PrivateDNSSuffix overrides the master FQDN suffix used to reach the API server of private clusters
+	PrivateDNSSuffix string
+	// NodeJoinTimeout is how long to wait for an upgraded master node to register with the API server, no limit if zero
+	NodeJoinTimeout time.Duration
+	// WaitForDNSPropagation waits for the hostname of each upgraded master VM to resolve before validating it,
+	// see UpgradeMasterNode.WaitForDNSResolution
+	WaitForDNSPropagation bool
+	DNSPropagationTimeout time.Duration
+	DNSResolverAddress    string
+	DNSQueryTimeout       time.Duration
+	// ExistingDeploymentName is used as the name of the master VM deployments instead of a generated one
+	ExistingDeploymentName string
+	// CloudInitScript is the base64-encoded cloud-init script replacing the custom data of the upgraded master VMs
+	CloudInitScript string
+	// RegenerateCloudConfig regenerates the cloud provider config of the upgraded masters from the api model,
+	// resource group and subscription of the upgrade, e.g. after the resource group or virtual network changed
+	RegenerateCloudConfig bool
+	// ForceImageUpdate sets the OS image version of the upgraded master and Linux agent VMs deployed from a marketplace
+	// image to the latest version of the image, see GetLatestVMImage; latestImageVersions caches them by image
+	ForceImageUpdate    bool
+	latestImageVersions map[string]string
+	// OSProfile overrides the OS profile of the upgraded master VMs, reading secrets through SecretResolver
+	OSProfile      *OSProfileConfig
+	SecretResolver SecretResolver
+	// UltraDiskEnabled enables ultra disk compatibility on the upgraded master VMs
+	UltraDiskEnabled bool
+	// OSDiskStorageAccountType is the managed disk type of the OS disk of the upgraded master VMs,
+	// Premium_LRS, StandardSSD_LRS or Standard_LRS; empty keeps the disk type of the template
+	OSDiskStorageAccountType string
+	// OSDiskCachingMode is the caching mode of the OS disk of the upgraded master VMs, None, ReadOnly or ReadWrite;
+	// empty keeps the caching mode of the template
+	OSDiskCachingMode string
+	// DiskEncryptionSetID is the resource ID of the disk encryption set the OS disks of the upgraded master VMs
+	// are encrypted with; empty keeps the template
+	DiskEncryptionSetID string
+	// BootDiagnosticsEnabled enables the boot diagnostics of the upgraded master VMs, stored in the storage account
+	// of BootDiagnosticsStorageAccountURI, or in a managed storage account if empty
+	BootDiagnosticsEnabled           bool
+	BootDiagnosticsStorageAccountURI string
+	// VMPriority is the priority of the upgraded master VMs, Regular, Spot or Low; empty keeps the priority
+	// of the template. EvictionPolicy is the eviction policy of Spot master VMs, Deallocate or Delete.
+	VMPriority     string
+	EvictionPolicy string
+	// AcceleratedNetworking enables Accelerated Networking on the NICs of the upgraded master VMs
+	AcceleratedNetworking bool
+	// ApplicationSecurityGroups are the resource IDs of application security groups the NICs of the upgraded
+	// master VMs are added to
+	ApplicationSecurityGroups []string
+	// ExpectedOutputs maps the outputs of each master VM deployment to their expected value, or a regular expression
+	// enclosed in slashes
+	ExpectedOutputs map[string]string
+	// UpdateKubeconfigInKeyVault stores the kubeconfig in the Key Vault secret KeyVaultID through SecretStore
+	// once all masters are upgraded
+	UpdateKubeconfigInKeyVault bool
+	KeyVaultID                 string
+	SecretStore                SecretStore
+	// SystemAssignedIdentity and UserAssignedIdentities are added to the identities of the upgraded master VMs
+	SystemAssignedIdentity bool
+	UserAssignedIdentities []string
+	// RoleAssignments are assigned to the system-assigned identity of each upgraded master VM
+	RoleAssignments []RoleAssignment
+	// StateSync saves the api model to a remote state store after each master VM is upgraded
+	StateSync StateSync
+	// EtcdBackupContainerURL is the URL of a blob container, including a SAS token granting write access,
+	// the etcd data of each master VM is uploaded to before the VM is deleted
+	EtcdBackupContainerURL *url.URL
+	// FallbackToDataDirectoryBackup also uploads an archive of the etcd data directory of master VMs without a data disk
+	FallbackToDataDirectoryBackup bool
+	// LiveEtcdMemberMigration removes the etcd member of each master VM from the etcd cluster before the VM is
+	// deleted and adds it back once the upgraded VM has joined, keeping the etcd quorum throughout the upgrade
+	LiveEtcdMemberMigration bool
+	// AttachEtcdDataDisk attaches a new etcd data disk of EtcdDataDiskSizeGB, the etcd disk size of the api model
+	// if zero, to the upgraded master VMs and mounts it over SSH
+	AttachEtcdDataDisk bool
+	EtcdDataDiskSizeGB int
+	// SSHPrivateKeyPath is the private key used to connect to the master VMs to back up or migrate etcd,
+	// and to the agent VMs to upgrade their kubelet in place
+	SSHPrivateKeyPath string
+	// IgnoreNodesWithPodLabelSelector skips the upgrade of the agent nodes running a pod matching this label selector,
+	// e.g. pods that cannot be evicted; the skipped nodes are listed in SkippedNodes and must be upgraded manually
+	IgnoreNodesWithPodLabelSelector string
+	// ScaleDownBeforeUpgrade deletes the first PoolUpgradeConfig.ValidationWorkers nodes of each availability set agent pool
+	// before its upgrade, instead of creating an extra node, and deletes the agent nodes without draining them once the pod
+	// disruption budgets allow it, for workloads tolerating less capacity but not pod eviction
+	ScaleDownBeforeUpgrade bool
+	// SkippedNodes lists the agent nodes skipped by the upgrade because of IgnoreNodesWithPodLabelSelector
+	SkippedNodes []string
+	// SkipPools lists the agent pools excluded from the upgrade, reported on UpgradeStartedEvent
+	SkipPools []string
+	// NodeOrderingStrategy sets the order in which the nodes of each agent pool are upgraded, e.g. OrderByPodCount;
+	// the nodes are upgraded in node index order if nil
+	NodeOrderingStrategy NodeOrderingStrategy
+	// InPlaceKubeletUpgrade replaces the kubelet binary of the nodes of the Linux availability set agent pools
+	// over SSH instead of replacing the nodes by new VMs, for patch release upgrades only
+	InPlaceKubeletUpgrade bool
+	// UpdateScaleSetInstances upgrades the nodes of the VMSS agent pools by updating their instances to the latest
+	// scale set model one at a time, instead of adding a new instance and deleting the old one
+	UpdateScaleSetInstances bool
+	// CheckCertificateSANs makes the upgrade fail when the API server certificate served after a master VM
+	// is replaced is not issued for the SANs expected from the api model
+	CheckCertificateSANs bool
+	// CheckContainerRuntime makes the upgrade fail when the container runtime of an upgraded agent node
+	// is not running or not compatible with the target Kubernetes version
+	CheckContainerRuntime bool
+	// DiskAttachmentTimeout makes the upgrade wait up to DiskAttachmentTimeout for the data disks of each new
+	// agent node of a pool with data disks to be attached, disabled if zero
+	DiskAttachmentTimeout time.Duration
+	// DeploymentPollInterval and MaxDeploymentPolls make the master VM deployments poll the deployment state,
+	// MaxDeploymentPolls zero polls until the deployment completes
+	DeploymentPollInterval time.Duration
+	MaxDeploymentPolls     int
+	// ExplicitDependencies maps master template resource names to resources they depend on, merged into
+	// their dependsOn arrays
+	ExplicitDependencies map[string][]string
+	// ReuseExistingDeployment waits for the running upgrade deployment of a master VM left by an interrupted
+	// upgrade instead of creating a new deployment
+	ReuseExistingDeployment bool
+	// ValidationWorkers is the number of new agent nodes validated at the same time when several nodes are created
+	// to make up the agent pool count, the nodes are validated one after the other if below two
+	ValidationWorkers int
+	// DeploymentMode is the ARM deployment mode of the master VM deployments, Incremental if empty.
+	// WARNING: Complete mode deletes the resources of the resource group that are not in the upgrade template.
+	DeploymentMode string
+	// WhatIfMode makes RunUpgrade log the changes the master VM deployments would make instead of upgrading the cluster,
+	// see UpgradeMasterNode.WhatIfMode
+	WhatIfMode bool
+	// TemplateBlobURI is the blob container the upgraded master VM templates are uploaded to through TemplateBlobClient
+	// and deployed from, with SAS tokens valid for TemplateBlobSASExpiryDuration
+	TemplateBlobURI               string
+	TemplateBlobSASExpiryDuration time.Duration
+	TemplateBlobClient            TemplateBlobClient
+	// GenerateUpgradeReport writes a Markdown report of the upgrade outcome to OutputDirectory once the upgrade
+	// completed or failed, uploaded to UpgradeReportBlobURI through TemplateBlobClient if set
+	GenerateUpgradeReport bool
+	UpgradeReportBlobURI  string
+	// VerifyCoreDNS makes the upgrade fail when kubernetes.default.svc.cluster.local does not resolve to the
+	// cluster IP of the kubernetes service after a master VM is upgraded
+	VerifyCoreDNS bool
+	// CoreDNSCheckImage is the image of the pod looking up the kubernetes service, DefaultCoreDNSCheckImage if empty
+	CoreDNSCheckImage string
+	// KubeletConfigPatch is merged into the kubelet-config config map once all nodes are upgraded
+	KubeletConfigPatch map[string]interface{}
+	// NodeShutdownGracePeriod is set as the graceful node shutdown grace period of the kubelet-config config map
+	// while each agent node is drained and upgraded, the previous value being restored afterwards; zero leaves it
+	NodeShutdownGracePeriod time.Duration
+	// MixedOSConcurrencyPolicy sets the order in which Linux and Windows agent nodes are upgraded
+	MixedOSConcurrencyPolicy MixedOSConcurrencyPolicy
+	// TemplateSource provides the ARM template and parameters of the upgrade instead of generating them from the api model
+	TemplateSource TemplateSource
+	// AutoAdjustResourceQuotas scales the resource quotas exceeding the cluster capacity once an agent pool changed VM size
+	AutoAdjustResourceQuotas bool
+	// AutoAdjustLimitRanges scales the limit range defaults exceeding the node capacity once an agent pool changed VM size
+	AutoAdjustLimitRanges bool
+	// CleanupAfterUpgrade deletes the upgrade deployments, the upgrade sub-directory of OutputDirectory
+	// and the orphaned NICs and disks of the cluster once the upgrade succeeded
+	CleanupAfterUpgrade bool
+	OutputDirectory     string
+	// NodeGroupSize stages the upgrade in groups of NodeGroupSize nodes, pausing after each group for
+	// NodeGroupPauseAfter, or until PauseCheckFile is created. The nodes are upgraded by pool name and node index.
+	NodeGroupSize       int
+	NodeGroupPauseAfter time.Duration
+	nodeGroupNodes      int
+	poolNodeGroupNodes  map[string]int
+	// PauseBetweenNodes pauses the upgrade before the next node when PauseCheckFile exists,
+	// until PauseCheckFile is created again
+	PauseBetweenNodes bool
+	PauseCheckFile    string
+	// CanaryUpgrade upgrades the first node of each pool as a canary, reporting CanaryCompletedEvent once it is upgraded.
+	// With CanaryPauseForConfirmation the upgrade then pauses until CanaryConfirm approves the canary, or,
+	// without CanaryConfirm, until the operator removes the CanaryResumeFile the upgrade writes,
+	// OutputDirectory/CanaryResumeFileName if empty
+	CanaryUpgrade              bool
+	CanaryPauseForConfirmation bool
+	CanaryConfirm              func(ctx context.Context, poolName, nodeName string) (bool, error)
+	CanaryResumeFile           string
+	canaryPools                map[string]bool
+	// ConsecutiveFailureLimit is how many agent nodes in a row may fail to upgrade before the upgrade stops,
+	// defaults to 3; the upgrade goes on with the next node after a failure below the limit, set it to 1 to
+	// stop at the first failed node
+	ConsecutiveFailureLimit int
+	consecutiveFailures     int
+	failedNodes             []NodeFailure
 }
 
 type vmStatus int
@@ -52,6 +305,7 @@ const (
 	nodePropertiesCopyTimeout          = time.Minute * 5
 	getResourceTimeout                 = time.Minute * 1
 	perNodeUpgradeTimeout              = time.Minute * 20
+	defaultPostDeleteWait              = time.Second * 10
 	vmStatusUpgraded          vmStatus = iota
 	vmStatusNotUpgraded
 	vmStatusIgnored
@@ -75,22 +329,166 @@ func (ku *Upgrader) Init(translator *i18n.Translator, logger *logrus.Entry, clus
 	ku.ControlPlaneOnly = controlPlaneOnly
 }
 
-// RunUpgrade runs the upgrade pipeline
+// RunUpgrade runs the upgrade pipeline, or only validates the existing nodes when ValidateExisting is set,
+// or only writes the upgrade template when GenerateTemplateOnly is set
 func (ku *Upgrader) RunUpgrade() error {
+	if ku.ValidateExisting {
+		return ku.ValidateExistingNodes()
+	}
+	if ku.GenerateTemplateOnly {
+		ctx, cancel := ku.upgradeContext(perNodeUpgradeTimeout)
+		defer cancel()
+		return ku.GenerateTemplate(ctx)
+	}
+	if ku.WhatIfMode {
+		ctx, cancel := ku.upgradeContext(perNodeUpgradeTimeout)
+		defer cancel()
+		return ku.WhatIf(ctx)
+	}
+	ku.addKubernetesEventRecorder()
+	var summary *UpgradeSummaryRecorder
+	if ku.GenerateUpgradeReport {
+		summary = &UpgradeSummaryRecorder{}
+		ku.Reporters = append(ku.Reporters, summary)
+	}
+	ku.reportEvent(UpgradeEvent{
+		Type:         UpgradeStartedEvent,
+		Message:      fmt.Sprintf("Upgrading cluster from Kubernetes %s to %s", ku.CurrentVersion, ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion),
+		Nodes:        ku.nodesToUpgrade(),
+		SkippedPools: ku.SkipPools,
+	})
+	err := ku.runUpgrade()
+	if summary != nil {
+		// before the cleanup, which deletes the upgrade deployments
+		ku.writeUpgradeReport(summary, err)
+	}
+	if err != nil {
+		ku.reportEvent(UpgradeEvent{
+			Type:    UpgradeFailedEvent,
+			Message: err.Error(),
+		})
+		return err
+	}
+	if ku.CleanupAfterUpgrade {
+		ku.cleanup()
+	}
+	if len(ku.SkippedNodes) > 0 {
+		ku.logger.Warnf("The upgrade skipped nodes %s, they must be upgraded manually", strings.Join(ku.SkippedNodes, ", "))
+	}
+	if len(ku.SkipPools) > 0 {
+		ku.logger.Infof("The upgrade skipped agent pools %s, their nodes kept their current Kubernetes version", strings.Join(ku.SkipPools, ", "))
+	}
+	ku.reportEvent(UpgradeEvent{
+		Type:         UpgradeCompletedEvent,
+		Message:      fmt.Sprintf("Upgraded cluster to Kubernetes %s", ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion),
+		SkippedNodes: ku.SkippedNodes,
+	})
+	return nil
+}
+
+// writeUpgradeReport writes the Markdown report of the upgrade, a failure is logged as the report is informative
+func (ku *Upgrader) writeUpgradeReport(recorder *UpgradeSummaryRecorder, upgradeErr error) {
+	summary := recorder.Summary()
+	summary.EndTime = time.Now().UTC()
+	summary.FromVersion = ku.CurrentVersion
+	summary.ToVersion = ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion
+	summary.Succeeded = upgradeErr == nil
+	if upgradeErr != nil {
+		summary.Errors = append(summary.Errors, upgradeErr.Error())
+	}
+	kmn := &UpgradeMasterNode{
+		logger:                  ku.logger,
+		UpgradeContainerService: ku.ClusterTopology.DataModel,
+		SubscriptionID:          ku.ClusterTopology.SubscriptionID,
+		ResourceGroup:           ku.ClusterTopology.ResourceGroup,
+		Client:                  ku.Client,
+		OutputDirectory:         ku.OutputDirectory,
+		TemplateBlobClient:      ku.TemplateBlobClient,
+		UpgradeReportBlobURI:    ku.UpgradeReportBlobURI,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
+	if _, err := kmn.GenerateUpgradeReportMarkdown(ctx, summary); err != nil {
+		ku.logger.Warnf("Failed to write the upgrade report: %v", err)
+	}
+}
+
+// cleanup removes the upgrade artifacts, a failure is logged as the upgrade itself succeeded
+func (ku *Upgrader) cleanup() {
+	kmn := &UpgradeMasterNode{
+		Translator:              ku.Translator,
+		logger:                  ku.logger,
+		UpgradeContainerService: ku.ClusterTopology.DataModel,
+		SubscriptionID:          ku.ClusterTopology.SubscriptionID,
+		ResourceGroup:           ku.ClusterTopology.ResourceGroup,
+		VNetResourceGroup:       ku.VNetResourceGroup,
+		Client:                  ku.Client,
+		ExistingDeploymentName:  ku.ExistingDeploymentName,
+		OutputDirectory:         ku.OutputDirectory,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
+	ku.logger.Infof("Cleaning up the upgrade artifacts of resource group %s", kmn.ResourceGroup)
+	if err := kmn.Cleanup(ctx); err != nil {
+		ku.logger.Warnf("Failed to clean up the upgrade artifacts: %v", err)
+	}
+}
+
+func (ku *Upgrader) runUpgrade() error {
+	kubernetesConfig := ku.DataModel.Properties.OrchestratorProfile.KubernetesConfig
+	if kubernetesConfig != nil {
+		if err := NetworkPluginCompatibilityCheck(ku.CurrentVersion, ku.DataModel.Properties.OrchestratorProfile.OrchestratorVersion, kubernetesConfig.NetworkPlugin); err != nil {
+			return err
+		}
+	}
+
+	if err := ku.MixedOSConcurrencyPolicy.Validate(); err != nil {
+		return err
+	}
+
+	if err := ValidatePoolUpgradeConfigs(ku.PoolUpgradeConfigs, ku.DataModel.Properties); err != nil {
+		return err
+	}
+
+	if err := ku.validateInPlaceKubeletUpgrade(); err != nil {
+		return err
+	}
+
+	if err := ku.validateIgnoreNodesSelector(); err != nil {
+		return err
+	}
+
+	if err := ku.validateNodeShutdownGracePeriod(); err != nil {
+		return err
+	}
+
+	if err := ku.runUpgradeHook("pre-upgrade", ku.PreUpgradeHook); err != nil {
+		return err
+	}
+
+	if ku.hasNodeGroups() {
+		ku.sortNodesForGroups()
+	}
+
 	controlPlaneUpgradeTimeout := perNodeUpgradeTimeout
 	if ku.ClusterTopology.DataModel.Properties.MasterProfile.Count > 0 {
 		controlPlaneUpgradeTimeout = perNodeUpgradeTimeout * time.Duration(ku.ClusterTopology.DataModel.Properties.MasterProfile.Count)
 	}
-	ctxControlPlane, cancelControlPlane := context.WithTimeout(context.Background(), controlPlaneUpgradeTimeout)
+	ctxControlPlane, cancelControlPlane := ku.upgradeContext(controlPlaneUpgradeTimeout)
 	defer cancelControlPlane()
 	if err := ku.upgradeMasterNodes(ctxControlPlane); err != nil {
 		return err
 	}
 
+	// CRD migrations are best effort, a failure should not block upgrading the agent nodes
+	if err := ku.UpgradeCRDs(ctxControlPlane); err != nil {
+		ku.logger.Errorf("Error upgrading CustomResourceDefinitions: %v", err)
+	}
+
 	ku.handleUnreconcilableAddons()
 
 	if ku.ControlPlaneOnly {
-		return nil
+		return ku.runUpgradeHook("post-upgrade", ku.PostUpgradeHook)
 	}
 
 	var numNodesToUpgrade int
@@ -101,14 +499,38 @@ func (ku *Upgrader) RunUpgrade() error {
 	if numNodesToUpgrade > 0 {
 		nodesUpgradeTimeout = perNodeUpgradeTimeout * time.Duration(numNodesToUpgrade)
 	}
-	ctxNodes, cancelNodes := context.WithTimeout(context.Background(), nodesUpgradeTimeout)
+	ctxNodes, cancelNodes := ku.upgradeContext(nodesUpgradeTimeout)
 	defer cancelNodes()
 	if err := ku.upgradeAgentScaleSets(ctxNodes); err != nil {
 		return err
 	}
 
 	//This is handling VMAS VMs only, not VMSS
-	return ku.upgradeAgentPools(ctxNodes)
+	if err := ku.upgradeAgentPools(ctxNodes); err != nil {
+		return err
+	}
+
+	if err := ku.nodeFailuresError(); err != nil {
+		return err
+	}
+
+	if err := ku.SyncLimitRanges(ctxNodes); err != nil {
+		ku.logger.Warnf("Failed to sync limit ranges with the agent pools: %v", err)
+	}
+
+	if len(ku.KubeletConfigPatch) > 0 {
+		numNodes := ku.DataModel.Properties.MasterProfile.Count
+		for _, app := range ku.DataModel.Properties.AgentPoolProfiles {
+			numNodes += app.Count
+		}
+		ctxKubelet, cancelKubelet := context.WithTimeout(context.Background(), perNodeUpgradeTimeout*time.Duration(numNodes))
+		defer cancelKubelet()
+		if err := ku.ApplyKubeletConfig(ctxKubelet, ku.KubeletConfigPatch); err != nil {
+			return errors.Wrap(err, "applying the kubelet configuration patch")
+		}
+	}
+
+	return ku.runUpgradeHook("post-upgrade", ku.PostUpgradeHook)
 }
 
 // handleUnreconcilableAddons ensures addon upgrades that addon-manager cannot handle by itself.
@@ -173,62 +595,13 @@ func (ku *Upgrader) upgradeMasterNodes(ctx context.Context) error {
 	if ku.ClusterTopology.DataModel.Properties.MasterProfile == nil {
 		return nil
 	}
-	ku.logger.Infof("Master nodes StorageProfile: %s", ku.ClusterTopology.DataModel.Properties.MasterProfile.StorageProfile)
-	// Upgrade Master VMs
-	templateMap, parametersMap, err := ku.generateUpgradeTemplate(ku.ClusterTopology.DataModel, ku.AKSEngineVersion)
+	upgradeMasterNode, err := ku.newUpgradeMasterNode(ctx)
 	if err != nil {
-		return ku.Translator.Errorf("error generating upgrade template: %s", err.Error())
-	}
-
-	ku.logger.Infof("Prepping master nodes for upgrade...")
-
-	transformer := &transform.Transformer{
-		Translator: ku.Translator,
-	}
-
-	if ku.ClusterTopology.DataModel.Properties.OrchestratorProfile.KubernetesConfig.PrivateJumpboxProvision() {
-		err = transformer.RemoveJumpboxResourcesFromTemplate(ku.logger, templateMap)
-		if err != nil {
-			return ku.Translator.Errorf("error removing jumpbox resources from template: %s", err.Error())
-		}
-	}
-
-	if ku.DataModel.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
-		err = transformer.NormalizeForK8sSLBScalingOrUpgrade(ku.logger, templateMap)
-		if err != nil {
-			return ku.Translator.Errorf("error normalizing upgrade template for SLB: %s", err.Error())
-		}
-	}
-
-	if to.Bool(ku.DataModel.Properties.OrchestratorProfile.KubernetesConfig.EnableEncryptionWithExternalKms) {
-		err = transformer.RemoveKMSResourcesFromTemplate(ku.logger, templateMap)
-		if err != nil {
-			return ku.Translator.Errorf("error removing KMS resources from template: %s", err.Error())
-		}
-	}
-
-	if err = transformer.NormalizeResourcesForK8sMasterUpgrade(ku.logger, templateMap, ku.DataModel.Properties.MasterProfile.IsManagedDisks(), nil); err != nil {
-		ku.logger.Error(err.Error())
 		return err
 	}
 
-	transformer.RemoveImmutableResourceProperties(ku.logger, templateMap)
-
-	upgradeMasterNode := UpgradeMasterNode{
-		Translator: ku.Translator,
-		logger:     ku.logger,
-	}
-	upgradeMasterNode.TemplateMap = templateMap
-	upgradeMasterNode.ParametersMap = parametersMap
-	upgradeMasterNode.UpgradeContainerService = ku.ClusterTopology.DataModel
-	upgradeMasterNode.ResourceGroup = ku.ClusterTopology.ResourceGroup
-	upgradeMasterNode.SubscriptionID = ku.ClusterTopology.SubscriptionID
-	upgradeMasterNode.Client = ku.Client
-	upgradeMasterNode.kubeConfig = ku.kubeConfig
-	if ku.stepTimeout == nil {
-		upgradeMasterNode.timeout = defaultTimeout
-	} else {
-		upgradeMasterNode.timeout = *ku.stepTimeout
+	if err = upgradeMasterNode.Preflight(ctx); err != nil {
+		return ku.Translator.Errorf("master upgrade preflight check failed: %s", err.Error())
 	}
 
 	expectedMasterCount := ku.ClusterTopology.DataModel.Properties.MasterProfile.Count
@@ -280,6 +653,7 @@ func (ku *Upgrader) upgradeMasterNodes(ctx context.Context) error {
 			ku.logger.Infof("Error validating upgraded master VM with index: %d", masterIndexToCreate)
 			return err
 		}
+		ku.syncState(ctx, upgradeMasterNode)
 
 		upgradedMastersIndex[masterIndexToCreate] = true
 	}
@@ -291,38 +665,272 @@ func (ku *Upgrader) upgradeMasterNodes(ctx context.Context) error {
 	}
 
 	for _, vm := range *ku.ClusterTopology.MasterVMs {
+		if err = ku.waitIfPaused(ctx, *vm.Name); err != nil {
+			return err
+		}
+		if err = ku.waitForNodeGroup(ctx, MasterPoolName, *vm.Name); err != nil {
+			return err
+		}
 		ku.logger.Infof("Upgrading Master VM: %s", *vm.Name)
+		start := time.Now()
 
 		masterIndex, _ := utils.GetVMNameIndex(vm.StorageProfile.OsDisk.OsType, *vm.Name)
 
+		if ku.ClusterTopology.DataModel.Properties.MasterProfile.HasAvailabilityZones() {
+			// the zone must be read before the VM is deleted
+			upgradeMasterNode.TargetAvailabilityZone, err = upgradeMasterNode.GetVMAvailabilityZone(ctx, *vm.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err = upgradeMasterNode.DetachAndSaveEtcdData(ctx, *vm.Name); err != nil {
+			ku.logger.Infof("Error saving the etcd data of master VM: %s, err: %v", *vm.Name, err)
+			return err
+		}
+		if err = upgradeMasterNode.RemoveEtcdMember(ctx, *vm.Name); err != nil {
+			ku.logger.Infof("Error removing the etcd member of master VM: %s, err: %v", *vm.Name, err)
+			return err
+		}
+
+		ku.reportNodePhase(NodeDeletingEvent, MasterPoolName, *vm.Name)
 		err = upgradeMasterNode.DeleteNode(vm.Name, false)
 		if err != nil {
 			ku.logger.Infof("Error deleting master VM: %s, err: %v", *vm.Name, err)
 			return err
 		}
 
+		ku.reportNodePhase(NodeCreatingEvent, MasterPoolName, *vm.Name)
 		err = upgradeMasterNode.CreateNode(ctx, "master", masterIndex)
 		if err != nil {
 			ku.logger.Infof("Error creating upgraded master VM: %s", *vm.Name)
 			return err
 		}
 
+		if len(upgradeMasterNode.RoleAssignments) > 0 {
+			if err = upgradeMasterNode.VerifyRoleAssignments(ctx, *vm.Name, upgradeMasterNode.RoleAssignments); err != nil {
+				ku.logger.Infof("Error verifying the role assignments of upgraded master VM: %s", *vm.Name)
+				return err
+			}
+		}
+
+		ku.reportNodePhase(NodeValidatingEvent, MasterPoolName, *vm.Name)
+		if upgradeMasterNode.WaitForDNSPropagation {
+			if err = upgradeMasterNode.WaitForDNSResolution(ctx, *vm.Name); err != nil {
+				ku.logger.Infof("Error resolving the hostname of upgraded master VM: %s", *vm.Name)
+				return err
+			}
+		}
 		err = upgradeMasterNode.Validate(vm.Name)
 		if err != nil {
 			ku.logger.Infof("Error validating upgraded master VM: %s", *vm.Name)
 			return err
 		}
+		if err = upgradeMasterNode.AddEtcdMember(ctx, *vm.Name); err != nil {
+			ku.logger.Infof("Error adding the etcd member of upgraded master VM: %s, err: %v", *vm.Name, err)
+			return err
+		}
+
+		if ku.CheckCertificateSANs {
+			if err = upgradeMasterNode.ValidateCertificateSANs(ctx, upgradeMasterNode.masterURL()); err != nil {
+				ku.logger.Infof("Error validating the API server certificate after upgrading master VM: %s", *vm.Name)
+				return err
+			}
+		}
+		if ku.VerifyCoreDNS {
+			if err = ku.VerifyCoreDNSResolution(ctx); err != nil {
+				ku.logger.Infof("Error verifying CoreDNS resolution after upgrading master VM: %s", *vm.Name)
+				return err
+			}
+		}
+		ku.syncState(ctx, upgradeMasterNode)
+
+		ku.reportEvent(UpgradeEvent{
+			Type:     NodeUpgradedEvent,
+			PoolName: MasterPoolName,
+			NodeName: *vm.Name,
+			Duration: time.Since(start),
+		})
+		if err = ku.completeCanary(ctx, MasterPoolName, *vm.Name); err != nil {
+			return err
+		}
 
 		upgradedMastersIndex[masterIndex] = true
 	}
 
+	if err = upgradeMasterNode.RecordUpgradeMetadata(ctx); err != nil {
+		// the control plane is upgraded at this point, a missing audit entry is not worth failing for
+		ku.logger.Warnf("Failed to record the upgrade in config map %s: %v", UpgradeHistoryConfigMapName, err)
+	}
+
+	if ku.UpdateKubeconfigInKeyVault {
+		if err = upgradeMasterNode.UpdateKubeconfigSecret(ctx); err != nil {
+			return errors.Wrap(err, "updating the kubeconfig in Key Vault")
+		}
+	}
+
+	return nil
+}
+
+// newUpgradeMasterNode generates the upgrade template of the master VMs and returns the UpgradeMasterNode deploying it
+func (ku *Upgrader) newUpgradeMasterNode(ctx context.Context) (*UpgradeMasterNode, error) {
+	ku.logger.Infof("Master nodes StorageProfile: %s", ku.ClusterTopology.DataModel.Properties.MasterProfile.StorageProfile)
+	// Upgrade Master VMs
+	templateMap, parametersMap, err := ku.generateUpgradeTemplate(ctx, ku.ClusterTopology.DataModel, ku.AKSEngineVersion)
+	if err != nil {
+		return nil, ku.Translator.Errorf("error generating upgrade template: %s", err.Error())
+	}
+	if ku.RegenerateCloudConfig {
+		if err = ku.injectCloudProviderConfig(templateMap, parametersMap); err != nil {
+			return nil, errors.Wrap(err, "regenerating the cloud provider config")
+		}
+	}
+
+	ku.logger.Infof("Prepping master nodes for upgrade...")
+
+	transformer := &transform.Transformer{
+		Translator: ku.Translator,
+	}
+
+	if ku.ClusterTopology.DataModel.Properties.OrchestratorProfile.KubernetesConfig.PrivateJumpboxProvision() {
+		err = transformer.RemoveJumpboxResourcesFromTemplate(ku.logger, templateMap)
+		if err != nil {
+			return nil, ku.Translator.Errorf("error removing jumpbox resources from template: %s", err.Error())
+		}
+	}
+
+	if ku.DataModel.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+		err = transformer.NormalizeForK8sSLBScalingOrUpgrade(ku.logger, templateMap)
+		if err != nil {
+			return nil, ku.Translator.Errorf("error normalizing upgrade template for SLB: %s", err.Error())
+		}
+	}
+
+	if to.Bool(ku.DataModel.Properties.OrchestratorProfile.KubernetesConfig.EnableEncryptionWithExternalKms) {
+		err = transformer.RemoveKMSResourcesFromTemplate(ku.logger, templateMap)
+		if err != nil {
+			return nil, ku.Translator.Errorf("error removing KMS resources from template: %s", err.Error())
+		}
+	}
+
+	if err = transformer.NormalizeResourcesForK8sMasterUpgrade(ku.logger, templateMap, ku.DataModel.Properties.MasterProfile.IsManagedDisks(), nil); err != nil {
+		ku.logger.Error(err.Error())
+		return nil, err
+	}
+
+	transformer.RemoveImmutableResourceProperties(ku.logger, templateMap)
+
+	upgradeMasterNode := &UpgradeMasterNode{
+		Translator: ku.Translator,
+		logger:     ku.logger,
+	}
+	upgradeMasterNode.TemplateMap = templateMap
+	upgradeMasterNode.ParametersMap = parametersMap
+	upgradeMasterNode.UpgradeContainerService = ku.ClusterTopology.DataModel
+	upgradeMasterNode.ResourceGroup = ku.ClusterTopology.ResourceGroup
+	upgradeMasterNode.SubscriptionID = ku.ClusterTopology.SubscriptionID
+	upgradeMasterNode.Client = ku.Client
+	upgradeMasterNode.kubeConfig = ku.kubeConfig
+	if ku.stepTimeout == nil {
+		upgradeMasterNode.timeout = defaultTimeout
+	} else {
+		upgradeMasterNode.timeout = *ku.stepTimeout
+	}
+	upgradeMasterNode.ProximityPlacementGroupID = ku.ProximityPlacementGroupID
+	upgradeMasterNode.ResourceTags = ku.ResourceTags
+	upgradeMasterNode.VNetResourceGroup = ku.VNetResourceGroup
+	upgradeMasterNode.APIModelVersion = ku.APIModelVersion
+	upgradeMasterNode.RequiredSchemaVersion = ku.RequiredSchemaVersion
+	upgradeMasterNode.ReplacementSubnetID = ku.ReplacementSubnetID
+	upgradeMasterNode.VNetPeeringID = ku.VNetPeeringID
+	upgradeMasterNode.CorrelationID = ku.CorrelationID
+	upgradeMasterNode.VMAPIVersion = ku.VMAPIVersion
+	upgradeMasterNode.DedicatedHostGroupID = ku.DedicatedHostGroupID
+	upgradeMasterNode.MaintenanceConfigurationID = ku.MaintenanceConfigurationID
+	upgradeMasterNode.MaintenanceClient = ku.MaintenanceClient
+	upgradeMasterNode.PolicyExemptions = ku.PolicyExemptions
+	upgradeMasterNode.PolicyExemptionClient = ku.PolicyExemptionClient
+	upgradeMasterNode.PrivateEndpointConfig = ku.PrivateEndpointConfig
+	upgradeMasterNode.PrivateEndpointClient = ku.PrivateEndpointClient
+	upgradeMasterNode.CurrentVersion = ku.CurrentVersion
+	upgradeMasterNode.Operator = ku.Operator
+	upgradeMasterNode.PrivateDNSSuffix = ku.PrivateDNSSuffix
+	upgradeMasterNode.ExistingDeploymentName = ku.ExistingDeploymentName
+	upgradeMasterNode.NodeJoinTimeout = ku.NodeJoinTimeout
+	upgradeMasterNode.WaitForDNSPropagation = ku.WaitForDNSPropagation
+	upgradeMasterNode.DNSPropagationTimeout = ku.DNSPropagationTimeout
+	upgradeMasterNode.DNSResolverAddress = ku.DNSResolverAddress
+	upgradeMasterNode.DNSQueryTimeout = ku.DNSQueryTimeout
+	upgradeMasterNode.CloudInitScript = ku.CloudInitScript
+	upgradeMasterNode.OSProfile = ku.OSProfile
+	upgradeMasterNode.SecretResolver = ku.SecretResolver
+	upgradeMasterNode.UltraDiskEnabled = ku.UltraDiskEnabled
+	upgradeMasterNode.OSDiskStorageAccountType = ku.OSDiskStorageAccountType
+	upgradeMasterNode.OSDiskCachingMode = ku.OSDiskCachingMode
+	upgradeMasterNode.DiskEncryptionSetID = ku.DiskEncryptionSetID
+	upgradeMasterNode.BootDiagnosticsEnabled = ku.BootDiagnosticsEnabled
+	upgradeMasterNode.BootDiagnosticsStorageAccountURI = ku.BootDiagnosticsStorageAccountURI
+	upgradeMasterNode.VMPriority = ku.VMPriority
+	upgradeMasterNode.EvictionPolicy = ku.EvictionPolicy
+	upgradeMasterNode.AcceleratedNetworking = ku.AcceleratedNetworking
+	upgradeMasterNode.ApplicationSecurityGroups = ku.ApplicationSecurityGroups
+	upgradeMasterNode.ExpectedOutputs = ku.ExpectedOutputs
+	upgradeMasterNode.UpdateKubeconfigInKeyVault = ku.UpdateKubeconfigInKeyVault
+	upgradeMasterNode.KeyVaultID = ku.KeyVaultID
+	upgradeMasterNode.SecretStore = ku.SecretStore
+	upgradeMasterNode.SystemAssignedIdentity = ku.SystemAssignedIdentity
+	upgradeMasterNode.UserAssignedIdentities = ku.UserAssignedIdentities
+	upgradeMasterNode.RoleAssignments = ku.RoleAssignments
+	upgradeMasterNode.StateSync = ku.StateSync
+	upgradeMasterNode.EtcdBackupContainerURL = ku.EtcdBackupContainerURL
+	upgradeMasterNode.FallbackToDataDirectoryBackup = ku.FallbackToDataDirectoryBackup
+	upgradeMasterNode.LiveEtcdMemberMigration = ku.LiveEtcdMemberMigration
+	upgradeMasterNode.AttachEtcdDataDisk = ku.AttachEtcdDataDisk
+	upgradeMasterNode.EtcdDataDiskSizeGB = ku.EtcdDataDiskSizeGB
+	upgradeMasterNode.SSHPrivateKeyPath = ku.SSHPrivateKeyPath
+	upgradeMasterNode.DeploymentPollInterval = ku.DeploymentPollInterval
+	upgradeMasterNode.MaxDeploymentPolls = ku.MaxDeploymentPolls
+	upgradeMasterNode.ReuseExistingDeployment = ku.ReuseExistingDeployment
+	upgradeMasterNode.ExplicitDependencies = ku.ExplicitDependencies
+	upgradeMasterNode.DeploymentMode = ku.DeploymentMode
+	upgradeMasterNode.WhatIfMode = ku.WhatIfMode
+	upgradeMasterNode.TemplateBlobURI = ku.TemplateBlobURI
+	upgradeMasterNode.TemplateBlobSASExpiryDuration = ku.TemplateBlobSASExpiryDuration
+	upgradeMasterNode.TemplateBlobClient = ku.TemplateBlobClient
+	upgradeMasterNode.startTime = time.Now()
+	if ku.PostDeleteWait == nil {
+		upgradeMasterNode.PostDeleteWait = defaultPostDeleteWait
+	} else {
+		upgradeMasterNode.PostDeleteWait = *ku.PostDeleteWait
+	}
+	return upgradeMasterNode, nil
+}
+
+// syncState saves the api model after a master VM upgrade, a failure is logged as the cluster itself is upgraded
+func (ku *Upgrader) syncState(ctx context.Context, upgradeMasterNode *UpgradeMasterNode) {
+	if err := upgradeMasterNode.SyncState(ctx); err != nil {
+		ku.logger.Warnf("Failed to save the cluster state: %v", err)
+	}
+}
+
+// checkContainerRuntime verifies the container runtime of an upgraded agent node if CheckContainerRuntime is set
+func (ku *Upgrader) checkContainerRuntime(ctx context.Context, upgradeAgentNode *UpgradeAgentNode, vmName string) error {
+	if !ku.CheckContainerRuntime {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, upgradeAgentNode.timeout)
+	defer cancel()
+	if err := upgradeAgentNode.VerifyContainerRuntime(ctx, vmName); err != nil {
+		ku.logger.Errorf("Error verifying the container runtime of agent VM %s: %v", vmName, err)
+		return err
+	}
 	return nil
 }
 
 func (ku *Upgrader) upgradeAgentPools(ctx context.Context) error {
-	for _, agentPool := range ku.ClusterTopology.AgentPools {
+	for _, agentPool := range ku.agentPoolUpgradeOrder() {
 		// Upgrade Agent VMs
-		templateMap, parametersMap, err := ku.generateUpgradeTemplate(ku.ClusterTopology.DataModel, ku.AKSEngineVersion)
+		templateMap, parametersMap, err := ku.generateUpgradeTemplate(ctx, ku.ClusterTopology.DataModel, ku.AKSEngineVersion)
 		if err != nil {
 			ku.logger.Errorf("Error generating upgrade template: %v", err)
 			return ku.Translator.Errorf("Error generating upgrade template: %s", err.Error())
@@ -391,11 +999,18 @@ func (ku *Upgrader) upgradeAgentPools(ctx context.Context) error {
 		} else {
 			upgradeAgentNode.timeout = *ku.stepTimeout
 		}
-		if ku.cordonDrainTimeout == nil {
-			upgradeAgentNode.cordonDrainTimeout = defaultCordonDrainTimeout
-		} else {
-			upgradeAgentNode.cordonDrainTimeout = *ku.cordonDrainTimeout
-		}
+		upgradeAgentNode.cordonDrainTimeout = ku.poolCordonDrainTimeout(*agentPool.Name)
+		upgradeAgentNode.MinFreeCapacityPercent = ku.MinFreeCapacityPercent
+		upgradeAgentNode.GPUExtensionVersion = ku.GPUExtensionVersion
+		upgradeAgentNode.GPUSKUPatternList = ku.GPUSKUPatternList
+		upgradeAgentNode.PreDrainHook = ku.PreDrainHook
+		upgradeAgentNode.AbortOnPreDrainHookFailure = ku.AbortOnPreDrainHookFailure
+		upgradeAgentNode.PostCreateTaintEviction = ku.PostCreateTaintEviction
+		upgradeAgentNode.drainGracePeriod = ku.drainGracePeriod(*agentPool.Name)
+		upgradeAgentNode.DrainMaxEvictionErrors = ku.DrainMaxEvictionErrors
+		upgradeAgentNode.DiskAttachmentTimeout = ku.DiskAttachmentTimeout
+		upgradeAgentNode.InPlaceKubeletUpgrade = ku.InPlaceKubeletUpgrade && !agentPoolProfile.IsWindows()
+		upgradeAgentNode.SSHPrivateKeyPath = ku.SSHPrivateKeyPath
 
 		agentVMs := make(map[int]*vmInfo)
 		// Go over upgraded VMs and verify provisioning state
@@ -442,21 +1057,45 @@ func (ku *Upgrader) upgradeAgentPools(ctx context.Context) error {
 		}
 		toBeUpgradedCount := len(*agentPool.AgentVMs)
 
+		client, err := ku.getKubernetesClient(10 * time.Second)
+		if err != nil {
+			ku.logger.Errorf("Error getting Kubernetes client: %v", err)
+			return err
+		}
+
+		// the skipped nodes are left in the pool as they are
+		skippedCount, err := ku.skipAgentVMs(client, *agentPool.Name, agentVMs)
+		if err != nil {
+			return err
+		}
+		toBeUpgradedCount -= skippedCount
+		agentCount -= skippedCount
+
 		ku.logger.Infof("Starting upgrade of %d agent nodes (out of %d) in pool identifier: %s, name: %s...",
 			toBeUpgradedCount, agentCount, *agentPool.Identifier, *agentPool.Name)
 
+		// the nodes deleted by the scale down are created again below, instead of the extra node
+		scaledDownCount := 0
+		if ku.ScaleDownBeforeUpgrade && toBeUpgradedCount > 0 && !upgradeAgentNode.InPlaceKubeletUpgrade {
+			scaledDownCount, err = ku.scaleDownAgentPool(client, &upgradeAgentNode, *agentPool.Name, agentVMs)
+			if err != nil {
+				return err
+			}
+			toBeUpgradedCount -= scaledDownCount
+		}
+
 		// Create missing nodes to match agentCount. This could be due to previous upgrade failure
 		// If there are nodes that need to be upgraded, create one extra node, which will be used to take on the load from upgrading nodes.
-		if toBeUpgradedCount > 0 {
+		// Nodes upgraded in place keep their load.
+		extraNode := toBeUpgradedCount > 0 && !upgradeAgentNode.InPlaceKubeletUpgrade && scaledDownCount == 0
+		if extraNode {
 			agentCount++
 		}
 
 		newCreatedVMs := []string{}
-		client, err := ku.getKubernetesClient(10 * time.Second)
-		if err != nil {
-			ku.logger.Errorf("Error getting Kubernetes client: %v", err)
-			return err
-		}
+		var createdVMs []string
+		// the last node created below is the extra node
+		extraIndex := -1
 
 		for upgradedCount+toBeUpgradedCount < agentCount {
 			agentIndex := getAvailableIndex(agentVMs)
@@ -469,92 +1108,260 @@ func (ku *Upgrader) upgradeAgentPools(ctx context.Context) error {
 			}
 			ku.logger.Infof("Creating new agent node %s (index %d)", vmName, agentIndex)
 
+			ku.reportNodePhase(NodeCreatingEvent, *agentPool.Name, vmName)
 			err = upgradeAgentNode.CreateNode(ctx, *agentPool.Name, agentIndex)
 			if err != nil {
 				ku.logger.Errorf("Error creating agent node %s (index %d): %v", vmName, agentIndex, err)
 				return err
 			}
 
-			err = upgradeAgentNode.Validate(&vmName)
-			if err != nil {
-				ku.logger.Infof("Error validating agent node %s (index %d): %v", vmName, agentIndex, err)
-				return err
+			if ku.validationWorkers(*agentPool.Name) > 1 {
+				// validated with the other new nodes once they are all created
+				createdVMs = append(createdVMs, vmName)
+			} else {
+				ku.reportNodePhase(NodeValidatingEvent, *agentPool.Name, vmName)
+				err = upgradeAgentNode.Validate(&vmName)
+				if err != nil {
+					ku.logger.Infof("Error validating agent node %s (index %d): %v", vmName, agentIndex, err)
+					return err
+				}
+				if err = ku.checkContainerRuntime(ctx, &upgradeAgentNode, vmName); err != nil {
+					return err
+				}
+				if err = ku.waitForDiskAttachment(ctx, &upgradeAgentNode, agentPoolProfile, vmName); err != nil {
+					return err
+				}
+				newCreatedVMs = append(newCreatedVMs, vmName)
 			}
 
-			newCreatedVMs = append(newCreatedVMs, vmName)
 			agentVMs[agentIndex] = &vmInfo{vmName, vmStatusUpgraded}
+			extraIndex = agentIndex
 			upgradedCount++
 		}
 
+		if len(createdVMs) > 0 {
+			for _, vmName := range createdVMs {
+				ku.reportNodePhase(NodeValidatingEvent, *agentPool.Name, vmName)
+			}
+			pool := &ReadinessCheckPool{Workers: ku.validationWorkers(*agentPool.Name)}
+			err = pool.Validate(createdVMs, func(vmName string) error {
+				// each validation gets its own copy, Validate deletes the node on timeout
+				node := upgradeAgentNode
+				if err := node.Validate(&vmName); err != nil {
+					ku.logger.Infof("Error validating agent node %s: %v", vmName, err)
+					return err
+				}
+				if err := ku.checkContainerRuntime(ctx, &node, vmName); err != nil {
+					return err
+				}
+				return ku.waitForDiskAttachment(ctx, &node, agentPoolProfile, vmName)
+			})
+			if err != nil {
+				return err
+			}
+			newCreatedVMs = append(newCreatedVMs, createdVMs...)
+		}
+
 		if toBeUpgradedCount == 0 {
 			ku.logger.Infof("No nodes to upgrade")
 			continue
 		}
 
 		// Upgrade nodes in agent pool
-		upgradedCount = 0
-		for agentIndex, vm := range agentVMs {
+		// the extra node takes the place of the last node to upgrade, or of the first node
+		// deleted by a failed upgrade, surplus is the count of VMs above the pool size
+		surplus := 0
+		if extraNode {
+			surplus = 1
+		}
+		remainingCount := toBeUpgradedCount
+		// the indexes of the VMs deleted by failed node upgrades
+		var failedIndexes []int
+		for _, agentIndex := range ku.agentVMUpgradeOrder(client, *agentPool.Name, agentVMs) {
+			vm := agentVMs[agentIndex]
 			if vm.status != vmStatusNotUpgraded {
 				continue
 			}
-			ku.logger.Infof("Upgrading Agent VM: %s, pool name: %s", vm.name, *agentPool.Name)
-
-			// copy custom properties from old node to new node if the PreserveNodesProperties in AgentPoolProfile is not set to false explicitly.
-			preserveNodesProperties := api.DefaultPreserveNodesProperties
-			if agentPoolProfile != nil && agentPoolProfile.PreserveNodesProperties != nil {
-				preserveNodesProperties = *agentPoolProfile.PreserveNodesProperties
+			remainingCount--
+			vmDeleted := false
+			if err = ku.waitIfPaused(ctx, vm.name); err != nil {
+				return err
+			}
+			if err = ku.waitForNodeGroup(ctx, *agentPool.Name, vm.name); err != nil {
+				return err
 			}
+			ku.logger.Infof("Upgrading Agent VM: %s, pool name: %s", vm.name, *agentPool.Name)
+			start := time.Now()
+
+			// a failed node counts towards the consecutive failures stopping the upgrade
+			upgradeVM := func() error {
+				// copy custom properties from old node to new node if the PreserveNodesProperties in AgentPoolProfile is not set to false explicitly.
+				preserveNodesProperties := api.DefaultPreserveNodesProperties
+				if agentPoolProfile != nil && agentPoolProfile.PreserveNodesProperties != nil {
+					preserveNodesProperties = *agentPoolProfile.PreserveNodesProperties
+				}
 
-			if preserveNodesProperties {
-				if len(newCreatedVMs) > 0 {
-					newNodeName := newCreatedVMs[0]
-					newCreatedVMs = newCreatedVMs[1:]
-					ku.logger.Infof("Copying custom annotations, labels, taints from old node %s to new node %s...", vm.name, newNodeName)
-					err = ku.copyCustomPropertiesToNewNode(client, strings.ToLower(vm.name), newNodeName)
-					if err != nil {
-						ku.logger.Warningf("Failed to copy custom annotations, labels, taints from old node %s to new node %s: %v", vm.name, newNodeName, err)
+				if preserveNodesProperties {
+					if len(newCreatedVMs) > 0 {
+						newNodeName := newCreatedVMs[0]
+						newCreatedVMs = newCreatedVMs[1:]
+						ku.logger.Infof("Copying custom annotations, labels, taints from old node %s to new node %s...", vm.name, newNodeName)
+						err = ku.copyCustomPropertiesToNewNode(client, strings.ToLower(vm.name), newNodeName)
+						if err != nil {
+							ku.logger.Warningf("Failed to copy custom annotations, labels, taints from old node %s to new node %s: %v", vm.name, newNodeName, err)
+						}
 					}
 				}
-			}
-
-			err := upgradeAgentNode.DeleteNode(&vm.name, true)
-			if err != nil {
-				ku.logger.Errorf("Error deleting agent VM %s: %v", vm.name, err)
-				return err
-			}
 
-			vmName, err := utils.GetK8sVMName(ku.DataModel.Properties, agentPoolProfile, agentIndex)
-			if err != nil {
-				ku.logger.Errorf("Error fetching new VM name: %v", err)
-				return err
-			}
+				if !ku.SkipCapacityCheck {
+					if err = upgradeAgentNode.CapacityPreflightCheck(ctx, vm.name); err != nil {
+						ku.logger.Errorf("Capacity preflight check failed for agent VM %s: %v", vm.name, err)
+						return err
+					}
+				}
 
-			// do not create last node in favor of already created extra node.
-			if upgradedCount == toBeUpgradedCount-1 {
-				ku.logger.Infof("Skipping creation of VM %s (index %d)", vmName, agentIndex)
-				delete(agentVMs, agentIndex)
-			} else {
-				err = upgradeAgentNode.CreateNode(ctx, *agentPool.Name, agentIndex)
+				drain, err := ku.drainBeforeDelete(client, *agentPool.Name, vm.name)
 				if err != nil {
-					ku.logger.Errorf("Error creating upgraded agent VM %s: %v", vmName, err)
+					ku.logger.Errorf("Error deleting agent VM %s without draining it: %v", vm.name, err)
 					return err
 				}
+				if drain {
+					ku.reportNodePhase(NodeDrainingEvent, *agentPool.Name, vm.name)
+				} else {
+					ku.reportNodePhase(NodeDeletingEvent, *agentPool.Name, vm.name)
+				}
+				err = upgradeAgentNode.DeleteNode(&vm.name, drain)
+				if err != nil {
+					ku.logger.Errorf("Error deleting agent VM %s: %v", vm.name, err)
+					return err
+				}
+				vmDeleted = true
 
-				err = upgradeAgentNode.Validate(&vmName)
+				vmName, err := utils.GetK8sVMName(ku.DataModel.Properties, agentPoolProfile, agentIndex)
 				if err != nil {
-					ku.logger.Errorf("Error validating upgraded agent VM %s: %v", vmName, err)
+					ku.logger.Errorf("Error fetching new VM name: %v", err)
 					return err
 				}
-				newCreatedVMs = append(newCreatedVMs, vmName)
-				vm.status = vmStatusUpgraded
+
+				// do not create last node in favor of already created extra node.
+				if surplus > 0 && remainingCount == 0 {
+					ku.logger.Infof("Skipping creation of VM %s (index %d)", vmName, agentIndex)
+					delete(agentVMs, agentIndex)
+					vmDeleted = false
+					surplus--
+				} else {
+					ku.reportNodePhase(NodeCreatingEvent, *agentPool.Name, vm.name)
+					err = upgradeAgentNode.CreateNode(ctx, *agentPool.Name, agentIndex)
+					if err != nil {
+						ku.logger.Errorf("Error creating upgraded agent VM %s: %v", vmName, err)
+						return err
+					}
+
+					ku.reportNodePhase(NodeValidatingEvent, *agentPool.Name, vm.name)
+					err = upgradeAgentNode.Validate(&vmName)
+					if err != nil {
+						ku.logger.Errorf("Error validating upgraded agent VM %s: %v", vmName, err)
+						return err
+					}
+					vmDeleted = false
+					if err = ku.checkContainerRuntime(ctx, &upgradeAgentNode, vmName); err != nil {
+						return err
+					}
+					if err = ku.waitForDiskAttachment(ctx, &upgradeAgentNode, agentPoolProfile, vmName); err != nil {
+						return err
+					}
+					newCreatedVMs = append(newCreatedVMs, vmName)
+					vm.status = vmStatusUpgraded
+				}
+				return nil
+			}
+			if upgradeAgentNode.InPlaceKubeletUpgrade {
+				upgradeVM = func() error {
+					ku.reportNodePhase(NodeValidatingEvent, *agentPool.Name, vm.name)
+					if err := upgradeAgentNode.UpgradeKubeletInPlace(ctx, vm.name); err != nil {
+						ku.logger.Errorf("Error upgrading the kubelet of agent VM %s in place: %v", vm.name, err)
+						return err
+					}
+					vm.status = vmStatusUpgraded
+					return nil
+				}
+			}
+			if err = ku.withNodeShutdownGracePeriod(vm.name, upgradeVM); err != nil {
+				if err = ku.nodeUpgradeFailed(vm.name, err); err != nil {
+					return err
+				}
+				if vmDeleted {
+					// the VM was deleted and not created again, the extra node takes its place if still there
+					failedIndexes = append(failedIndexes, agentIndex)
+					surplus--
+				}
+				continue
+			}
+			ku.nodeUpgradeSucceeded()
+
+			ku.reportEvent(UpgradeEvent{
+				Type:          NodeUpgradedEvent,
+				PoolName:      *agentPool.Name,
+				NodeName:      vm.name,
+				Duration:      time.Since(start),
+				DrainDuration: upgradeAgentNode.drainDuration,
+			})
+			if err = ku.completeCanary(ctx, *agentPool.Name, vm.name); err != nil {
+				return err
 			}
-			upgradedCount++
+		}
+
+		if err = ku.restoreAgentPoolSize(ctx, &upgradeAgentNode, agentPoolProfile, agentVMs, surplus, failedIndexes, extraIndex); err != nil {
+			return err
+		}
+
+		if err = ku.SyncResourceQuotas(ctx, *agentPool.Name); err != nil {
+			ku.logger.Warnf("Failed to sync resource quotas with agent pool %s: %v", *agentPool.Name, err)
 		}
 	}
 
 	return nil
 }
 
+// restoreAgentPoolSize leaves the agent pool at its size before the upgrade once its nodes were processed.
+// surplus is the count of VMs above the pool size: the extra node is drained and deleted when the node upgrades
+// that failed did not delete any VM, and the VMs deleted by failed node upgrades the extra node could not
+// take the place of are created again at their indexes in failedIndexes
+func (ku *Upgrader) restoreAgentPoolSize(ctx context.Context, upgradeAgentNode *UpgradeAgentNode, agentPoolProfile *api.AgentPoolProfile, agentVMs map[int]*vmInfo, surplus int, failedIndexes []int, extraIndex int) error {
+	poolName := agentPoolProfile.Name
+	if surplus > 0 && extraIndex >= 0 {
+		extraVM := agentVMs[extraIndex]
+		ku.logger.Infof("Deleting extra agent node %s (index %d) left by the nodes that failed to upgrade in pool %s", extraVM.name, extraIndex, poolName)
+		ku.reportNodePhase(NodeDrainingEvent, poolName, extraVM.name)
+		if err := upgradeAgentNode.DeleteNode(&extraVM.name, true); err != nil {
+			ku.logger.Errorf("Error deleting extra agent VM %s: %v", extraVM.name, err)
+			return err
+		}
+		delete(agentVMs, extraIndex)
+	}
+	for i := 0; i < -surplus && i < len(failedIndexes); i++ {
+		agentIndex := failedIndexes[i]
+		vmName, err := utils.GetK8sVMName(ku.DataModel.Properties, agentPoolProfile, agentIndex)
+		if err != nil {
+			ku.logger.Errorf("Error reconstructing agent VM name with index %d: %v", agentIndex, err)
+			return err
+		}
+		ku.logger.Infof("Creating agent node %s (index %d) again, deleted by its failed upgrade", vmName, agentIndex)
+		ku.reportNodePhase(NodeCreatingEvent, poolName, vmName)
+		if err = upgradeAgentNode.CreateNode(ctx, poolName, agentIndex); err != nil {
+			ku.logger.Errorf("Error creating agent node %s (index %d): %v", vmName, agentIndex, err)
+			return err
+		}
+		ku.reportNodePhase(NodeValidatingEvent, poolName, vmName)
+		if err = upgradeAgentNode.Validate(&vmName); err != nil {
+			ku.logger.Errorf("Error validating agent node %s (index %d): %v", vmName, agentIndex, err)
+			return err
+		}
+		agentVMs[agentIndex] = &vmInfo{vmName, vmStatusUpgraded}
+	}
+	return nil
+}
+
 func (ku *Upgrader) upgradeAgentScaleSets(ctx context.Context) error {
 	agentPoolMap := make(map[string]*api.AgentPoolProfile)
 	for _, app := range ku.ClusterTopology.DataModel.Properties.AgentPoolProfiles {
@@ -565,7 +1372,7 @@ func (ku *Upgrader) upgradeAgentScaleSets(ctx context.Context) error {
 		// need to apply the ARM template with target Kubernetes version to the VMSS first in order that the new VMSS instances
 		// created can get the expected Kubernetes version. Otherwise the new instances created still have old Kubernetes version
 		// if the topology doesn't have master nodes (so there are no ARM deployments in previous upgradeMasterNodes step)
-		templateMap, parametersMap, err := ku.generateUpgradeTemplate(ku.ClusterTopology.DataModel, ku.AKSEngineVersion)
+		templateMap, parametersMap, err := ku.generateUpgradeTemplate(ctx, ku.ClusterTopology.DataModel, ku.AKSEngineVersion)
 		if err != nil {
 			ku.logger.Errorf("error generating upgrade template in upgradeAgentScaleSets: %v", err)
 			return err
@@ -601,6 +1408,9 @@ func (ku *Upgrader) upgradeAgentScaleSets(ctx context.Context) error {
 		deploymentName := fmt.Sprintf("k8s-upgrade-update-vmss-pools-%s-%d", time.Now().Format("06-01-02T15.04.05"), deploymentSuffix)
 
 		ku.logger.Infof("Deploying ARM template to update all VMSS node pools...")
+		if err = armhelpers.ValidateDeploymentParameters(ku.logger, templateMap, parametersMap); err != nil {
+			return err
+		}
 		_, err = ku.Client.DeployTemplate(
 			ctx,
 			ku.ClusterTopology.ResourceGroup,
@@ -617,129 +1427,199 @@ func (ku *Upgrader) upgradeAgentScaleSets(ctx context.Context) error {
 	ku.logger.Infof("Will now perform a rolling upgrade of each VMSS, one node (VM instance) at a time...")
 
 	for _, vmssToUpgrade := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
-		ku.logger.Infof("Upgrading VMSS %s", vmssToUpgrade.Name)
-
 		if len(vmssToUpgrade.VMsToUpgrade) == 0 {
 			ku.logger.Infof("No VMs to upgrade for VMSS %s, skipping", vmssToUpgrade.Name)
-			continue
 		}
+	}
 
-		newCapacity := *vmssToUpgrade.Sku.Capacity + 1
-		ku.logger.Infof(
-			"VMSS %s current capacity is %d and new capacity will be %d while each node is swapped",
-			vmssToUpgrade.Name,
-			*vmssToUpgrade.Sku.Capacity,
-			newCapacity,
-		)
+	if err := ku.skipScaleSetVMs(); err != nil {
+		return err
+	}
+	ku.orderScaleSetVMs()
+	for _, node := range ku.scaleSetVMUpgradeOrder() {
+		vmssToUpgrade := node.vmss
+		if node.first && ku.UpdateScaleSetInstances {
+			ku.logger.Infof("Upgrading VMSS %s, updating each node (VM instance) in place", vmssToUpgrade.Name)
+		} else if node.first {
+			ku.logger.Infof("Upgrading VMSS %s", vmssToUpgrade.Name)
+
+			newCapacity := *vmssToUpgrade.Sku.Capacity + 1
+			ku.logger.Infof(
+				"VMSS %s current capacity is %d and new capacity will be %d while each node is swapped",
+				vmssToUpgrade.Name,
+				*vmssToUpgrade.Sku.Capacity,
+				newCapacity,
+			)
 
-		*vmssToUpgrade.Sku.Capacity = newCapacity
+			*vmssToUpgrade.Sku.Capacity = newCapacity
+		}
 
-		for _, vmToUpgrade := range vmssToUpgrade.VMsToUpgrade {
-			if err := ku.Client.SetVirtualMachineScaleSetCapacity(
-				ctx,
-				ku.ClusterTopology.ResourceGroup,
-				vmssToUpgrade.Name,
-				vmssToUpgrade.Sku,
-				vmssToUpgrade.Location,
-			); err != nil {
-				ku.logger.Errorf("Failure to set capacity for VMSS %s", vmssToUpgrade.Name)
+		if err := ku.waitIfPaused(ctx, node.vm.Name); err != nil {
+			return err
+		}
+		if err := ku.waitForNodeGroup(ctx, vmssToUpgrade.poolName(), node.vm.Name); err != nil {
+			return err
+		}
+		err := ku.withNodeShutdownGracePeriod(node.vm.Name, func() error {
+			if ku.UpdateScaleSetInstances {
+				return ku.updateScaleSetVM(ctx, vmssToUpgrade, node.vm)
+			}
+			return ku.upgradeScaleSetVM(ctx, vmssToUpgrade, node.vm, agentPoolMap)
+		})
+		if err != nil {
+			if err = ku.nodeUpgradeFailed(node.vm.Name, err); err != nil {
 				return err
 			}
+		} else {
+			ku.nodeUpgradeSucceeded()
+			if err := ku.completeCanary(ctx, vmssToUpgrade.poolName(), node.vm.Name); err != nil {
+				return err
+			}
+		}
 
-			ku.logger.Infof("Successfully set capacity for VMSS %s", vmssToUpgrade.Name)
+		if node.last {
+			ku.logger.Infof("Completed upgrading VMSS %s", vmssToUpgrade.Name)
 
-			var cordonDrainTimeout time.Duration
-			if ku.cordonDrainTimeout == nil {
-				cordonDrainTimeout = defaultCordonDrainTimeout
-			} else {
-				cordonDrainTimeout = *ku.cordonDrainTimeout
+			poolName := vmssToUpgrade.poolName()
+			if err := ku.SyncResourceQuotas(ctx, poolName); err != nil {
+				ku.logger.Warnf("Failed to sync resource quotas with agent pool %s: %v", poolName, err)
 			}
+		}
+	}
 
-			// Before we can delete the node we should safely and responsibly drain it
-			client, err := ku.getKubernetesClient(cordonDrainTimeout)
-			if err != nil {
-				ku.logger.Errorf("Error getting Kubernetes client: %v", err)
-				return err
-			}
+	ku.logger.Infoln("Completed upgrading all VMSS")
 
-			ku.logger.Infof("Draining node %s", vmToUpgrade.Name)
-			err = operations.SafelyDrainNodeWithClient(
-				client,
-				ku.logger,
-				vmToUpgrade.Name,
-				cordonDrainTimeout,
-			)
-			if err != nil {
-				ku.logger.Errorf("Error draining VM in VMSS: %v", err)
-				// Continue even if there's an error in draining the node.
-			}
+	return nil
+}
 
-			ku.logger.Infof(
-				"Deleting VM %s in VMSS %s",
-				vmToUpgrade.Name,
-				vmssToUpgrade.Name,
-			)
+// upgradeScaleSetVM replaces a VMSS instance by a new one, created by the capacity increase of the VMSS
+func (ku *Upgrader) upgradeScaleSetVM(ctx context.Context, vmssToUpgrade *AgentPoolScaleSet, vmToUpgrade AgentPoolScaleSetVM, agentPoolMap map[string]*api.AgentPoolProfile) error {
+	start := time.Now()
+	// the new instance replacing the node is created by increasing the capacity
+	ku.reportNodePhase(NodeCreatingEvent, vmssToUpgrade.poolName(), vmToUpgrade.Name)
+	if err := ku.Client.SetVirtualMachineScaleSetCapacity(
+		ctx,
+		ku.ClusterTopology.ResourceGroup,
+		vmssToUpgrade.Name,
+		vmssToUpgrade.Sku,
+		vmssToUpgrade.Location,
+	); err != nil {
+		ku.logger.Errorf("Failure to set capacity for VMSS %s", vmssToUpgrade.Name)
+		return err
+	}
 
-			// copy custom properties from old node to new node if the PreserveNodesProperties in AgentPoolProfile is not set to false explicitly.
-			preserveNodesProperties := api.DefaultPreserveNodesProperties
-			var poolName string
-			if vmssToUpgrade.IsWindows {
-				poolName, _ = utils.WindowsVmssNameParts(vmssToUpgrade.Name)
-			} else {
-				poolName, _, _ = utils.VmssNameParts(vmssToUpgrade.Name)
-			}
-			if agentPool, ok := agentPoolMap[poolName]; ok {
-				if agentPool != nil && agentPool.PreserveNodesProperties != nil {
-					preserveNodesProperties = *agentPool.PreserveNodesProperties
-				}
-			}
+	ku.logger.Infof("Successfully set capacity for VMSS %s", vmssToUpgrade.Name)
 
-			if preserveNodesProperties {
-				newNodeName, err := ku.getLastVMNameInVMSS(ctx, ku.ClusterTopology.ResourceGroup, vmssToUpgrade.Name)
-				if err != nil {
-					return err
-				}
+	poolName := vmssToUpgrade.poolName()
+	cordonDrainTimeout := ku.poolCordonDrainTimeout(poolName)
 
-				ku.logger.Infof("Copying custom annotations, labels, taints from old node %s to new node %s...", vmToUpgrade.Name, newNodeName)
-				err = ku.copyCustomPropertiesToNewNode(client, strings.ToLower(vmToUpgrade.Name), strings.ToLower(newNodeName))
-				if err != nil {
-					ku.logger.Warningf("Failed to copy custom annotations, labels, taints from old node %s to new node %s: %v", vmToUpgrade.Name, newNodeName, err)
-				}
-			}
+	// Before we can delete the node we should safely and responsibly drain it
+	client, err := ku.getKubernetesClient(cordonDrainTimeout)
+	if err != nil {
+		ku.logger.Errorf("Error getting Kubernetes client: %v", err)
+		return err
+	}
 
-			// At this point we have our buffer node that will replace the node to delete
-			// so we can just remove this current node then
-			if err := ku.Client.DeleteVirtualMachineScaleSetVM(
-				ctx,
-				ku.ClusterTopology.ResourceGroup,
-				vmssToUpgrade.Name,
-				vmToUpgrade.InstanceID,
-			); err != nil {
-				ku.logger.Errorf(
-					"Failed to delete VM %s in VMSS %s",
-					vmToUpgrade.Name,
-					vmssToUpgrade.Name)
-				return err
-			}
-			ku.logger.Infof(
-				"Successfully deleted VM %s in VMSS %s",
-				vmToUpgrade.Name,
-				vmssToUpgrade.Name)
+	if !ku.SkipCapacityCheck {
+		if err = checkCapacity(client, strings.ToLower(vmToUpgrade.Name), ku.MinFreeCapacityPercent); err != nil {
+			ku.logger.Errorf("Capacity preflight check failed for VMSS VM %s: %v", vmToUpgrade.Name, err)
+			return err
 		}
-		ku.logger.Infof("Completed upgrading VMSS %s", vmssToUpgrade.Name)
 	}
 
-	ku.logger.Infoln("Completed upgrading all VMSS")
+	var drainDuration time.Duration
+	drain, err := ku.drainBeforeDelete(client, poolName, vmToUpgrade.Name)
+	if err != nil {
+		ku.logger.Errorf("Error deleting VMSS VM %s without draining it: %v", vmToUpgrade.Name, err)
+		return err
+	}
+	if !drain {
+		ku.logger.Infof("Skipping the drain of node %s of pool %s", vmToUpgrade.Name, poolName)
+	} else {
+		ku.reportNodePhase(NodeDrainingEvent, poolName, vmToUpgrade.Name)
+		ku.logger.Infof("Draining node %s", vmToUpgrade.Name)
+		drainStart := time.Now()
+		err = operations.SafelyDrainNodeWithMaxEvictionErrors(
+			client,
+			ku.logger,
+			vmToUpgrade.Name,
+			cordonDrainTimeout,
+			ku.drainGracePeriod(poolName),
+			ku.DrainMaxEvictionErrors,
+		)
+		drainDuration = time.Since(drainStart)
+		if err != nil {
+			ku.logger.Errorf("Error draining VM in VMSS: %v", err)
+			// Continue even if there's an error in draining the node.
+		}
+	}
+
+	ku.logger.Infof(
+		"Deleting VM %s in VMSS %s",
+		vmToUpgrade.Name,
+		vmssToUpgrade.Name,
+	)
+
+	// copy custom properties from old node to new node if the PreserveNodesProperties in AgentPoolProfile is not set to false explicitly.
+	preserveNodesProperties := api.DefaultPreserveNodesProperties
+	if agentPool, ok := agentPoolMap[poolName]; ok {
+		if agentPool != nil && agentPool.PreserveNodesProperties != nil {
+			preserveNodesProperties = *agentPool.PreserveNodesProperties
+		}
+	}
+
+	if preserveNodesProperties {
+		newNodeName, err := ku.getLastVMNameInVMSS(ctx, ku.ClusterTopology.ResourceGroup, vmssToUpgrade.Name)
+		if err != nil {
+			return err
+		}
+
+		ku.logger.Infof("Copying custom annotations, labels, taints from old node %s to new node %s...", vmToUpgrade.Name, newNodeName)
+		err = ku.copyCustomPropertiesToNewNode(client, strings.ToLower(vmToUpgrade.Name), strings.ToLower(newNodeName))
+		if err != nil {
+			ku.logger.Warningf("Failed to copy custom annotations, labels, taints from old node %s to new node %s: %v", vmToUpgrade.Name, newNodeName, err)
+		}
+	}
+
+	// At this point we have our buffer node that will replace the node to delete
+	// so we can just remove this current node then
+	ku.reportNodePhase(NodeDeletingEvent, poolName, vmToUpgrade.Name)
+	if err := ku.Client.DeleteVirtualMachineScaleSetVM(
+		ctx,
+		ku.ClusterTopology.ResourceGroup,
+		vmssToUpgrade.Name,
+		vmToUpgrade.InstanceID,
+	); err != nil {
+		ku.logger.Errorf(
+			"Failed to delete VM %s in VMSS %s",
+			vmToUpgrade.Name,
+			vmssToUpgrade.Name)
+		return err
+	}
+	ku.logger.Infof(
+		"Successfully deleted VM %s in VMSS %s",
+		vmToUpgrade.Name,
+		vmssToUpgrade.Name)
+
+	ku.reportEvent(UpgradeEvent{
+		Type:          NodeUpgradedEvent,
+		PoolName:      poolName,
+		NodeName:      vmToUpgrade.Name,
+		Duration:      time.Since(start),
+		DrainDuration: drainDuration,
+	})
 
 	return nil
 }
 
-func (ku *Upgrader) generateUpgradeTemplate(upgradeContainerService *api.ContainerService, aksEngineVersion string) (map[string]interface{}, map[string]interface{}, error) {
+// generateUpgradeTemplate returns the ARM template and parameters of the upgraded cluster,
+// loaded from TemplateSource if set or generated from the api model
+func (ku *Upgrader) generateUpgradeTemplate(ctx context.Context, upgradeContainerService *api.ContainerService, aksEngineVersion string) (map[string]interface{}, map[string]interface{}, error) {
 	var err error
-	ctx := engine.Context{
+	engineCtx := engine.Context{
 		Translator: ku.Translator,
 	}
-	templateGenerator, err := engine.InitializeTemplateGenerator(ctx)
+	templateGenerator, err := engine.InitializeTemplateGenerator(engineCtx)
 	if err != nil {
 		return nil, nil, ku.Translator.Errorf("failed to initialize template generator: %s", err.Error())
 	}
@@ -754,6 +1634,19 @@ func (ku *Upgrader) generateUpgradeTemplate(upgradeContainerService *api.Contain
 
 	}
 
+	if ku.TemplateSource != nil {
+		templateMap, parametersMap, err := ku.TemplateSource.Load(ctx)
+		if err != nil {
+			return nil, nil, ku.Translator.Errorf("error loading upgrade template: %s", err.Error())
+		}
+		if ku.ForceImageUpdate {
+			if err = ku.updateOSImageVersions(ctx, parametersMap); err != nil {
+				return nil, nil, err
+			}
+		}
+		return templateMap, parametersMap, nil
+	}
+
 	var templateJSON string
 	var parametersJSON string
 	if templateJSON, parametersJSON, err = templateGenerator.GenerateTemplateV2(upgradeContainerService, engine.DefaultGeneratorCode, aksEngineVersion); err != nil {
@@ -776,6 +1669,12 @@ func (ku *Upgrader) generateUpgradeTemplate(upgradeContainerService *api.Contain
 	templateMap := template.(map[string]interface{})
 	parametersMap := parameters.(map[string]interface{})
 
+	if ku.ForceImageUpdate {
+		if err = ku.updateOSImageVersions(ctx, parametersMap); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return templateMap, parametersMap, nil
 }
 
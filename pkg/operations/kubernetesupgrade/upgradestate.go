@@ -0,0 +1,245 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// UpgradeStateVersion is the version of the upgrade state files written by ExportState
+const UpgradeStateVersion = 1
+
+// UpgradeState is the upgrade state exported by ExportState, to resume the upgrade from another machine
+type UpgradeState struct {
+	Version          int    `json:"version"`
+	AKSEngineVersion string `json:"aksEngineVersion"`
+	CurrentVersion   string `json:"currentVersion,omitempty"`
+	ControlPlaneOnly bool   `json:"controlPlaneOnly,omitempty"`
+	SubscriptionID   string `json:"subscriptionId"`
+	Location         string `json:"location,omitempty"`
+	ResourceGroup    string `json:"resourceGroup"`
+	NameSuffix       string `json:"nameSuffix"`
+	// ContainerService is the vlabs api model of the cluster
+	ContainerService json.RawMessage `json:"containerService"`
+	// Template and Parameters are the ARM template and parameters of the upgrade, before they are
+	// customized for the master or agent VMs
+	Template   map[string]interface{} `json:"template"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Cursor     UpgradeCursor          `json:"cursor"`
+}
+
+// UpgradeCursor records which VMs are upgraded and which remain to be upgraded
+type UpgradeCursor struct {
+	MasterVMs                   []UpgradeStateVM                 `json:"masterVMs"`
+	UpgradedMasterVMs           []UpgradeStateVM                 `json:"upgradedMasterVMs"`
+	AgentPoolsToUpgrade         map[string]bool                  `json:"agentPoolsToUpgrade,omitempty"`
+	AgentPools                  map[string]UpgradeStateAgentPool `json:"agentPools,omitempty"`
+	AgentPoolScaleSetsToUpgrade []AgentPoolScaleSet              `json:"agentPoolScaleSetsToUpgrade,omitempty"`
+}
+
+// UpgradeStateAgentPool records the VMs of an availability set agent pool
+type UpgradeStateAgentPool struct {
+	Identifier       string           `json:"identifier"`
+	AgentVMs         []UpgradeStateVM `json:"agentVMs"`
+	UpgradedAgentVMs []UpgradeStateVM `json:"upgradedAgentVMs"`
+}
+
+// UpgradeStateVM is the part of a VM the upgrade needs to find it again
+type UpgradeStateVM struct {
+	Name   string                       `json:"name"`
+	OSType compute.OperatingSystemTypes `json:"osType,omitempty"`
+}
+
+// ExportState writes the api model, the upgrade template and the upgrade cursor to outputPath as JSON.
+// The file includes the secrets of the api model and should be protected accordingly.
+func (ku *Upgrader) ExportState(ctx context.Context, outputPath string) error {
+	state := &UpgradeState{
+		Version:          UpgradeStateVersion,
+		AKSEngineVersion: ku.AKSEngineVersion,
+		CurrentVersion:   ku.CurrentVersion,
+		ControlPlaneOnly: ku.ControlPlaneOnly,
+		SubscriptionID:   ku.SubscriptionID,
+		Location:         ku.Location,
+		ResourceGroup:    ku.ResourceGroup,
+		NameSuffix:       ku.NameSuffix,
+		Cursor: UpgradeCursor{
+			MasterVMs:                   exportVMs(ku.MasterVMs),
+			UpgradedMasterVMs:           exportVMs(ku.UpgradedMasterVMs),
+			AgentPoolsToUpgrade:         ku.AgentPoolsToUpgrade,
+			AgentPoolScaleSetsToUpgrade: ku.AgentPoolScaleSetsToUpgrade,
+		},
+	}
+	if len(ku.AgentPools) > 0 {
+		state.Cursor.AgentPools = map[string]UpgradeStateAgentPool{}
+		for name, pool := range ku.AgentPools {
+			var identifier string
+			if pool.Identifier != nil {
+				identifier = *pool.Identifier
+			}
+			state.Cursor.AgentPools[name] = UpgradeStateAgentPool{
+				Identifier:       identifier,
+				AgentVMs:         exportVMs(pool.AgentVMs),
+				UpgradedAgentVMs: exportVMs(pool.UpgradedAgentVMs),
+			}
+		}
+	}
+
+	var err error
+	if state.Template, state.Parameters, err = ku.generateUpgradeTemplate(ctx, ku.DataModel, ku.AKSEngineVersion); err != nil {
+		return errors.Wrap(err, "generating the upgrade template")
+	}
+	if state.ContainerService, err = serializeState(ku.DataModel); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshaling the upgrade state")
+	}
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{},
+	}
+	dir, file := filepath.Split(outputPath)
+	if err = f.SaveFile(dir, file, b); err != nil {
+		return errors.Wrapf(err, "saving the upgrade state to %s", outputPath)
+	}
+	return nil
+}
+
+// ImportState returns an Upgrader resuming the upgrade state exported to inputPath by ExportState.
+// The Upgrader deploys the exported upgrade template, its Client must be set before running the upgrade.
+func ImportState(inputPath string) (*Upgrader, error) {
+	b, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the upgrade state")
+	}
+	state := &UpgradeState{}
+	if err = json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling the upgrade state")
+	}
+	if state.Version != UpgradeStateVersion {
+		return nil, errors.Errorf("unsupported upgrade state version %d, expected %d", state.Version, UpgradeStateVersion)
+	}
+	translator := &i18n.Translator{}
+	apiloader := &api.Apiloader{
+		Translator: translator,
+	}
+	cs, _, err := apiloader.DeserializeContainerService(state.ContainerService, false, true, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading the api model of the upgrade state")
+	}
+
+	topology := ClusterTopology{
+		DataModel:                   cs,
+		SubscriptionID:              state.SubscriptionID,
+		Location:                    state.Location,
+		ResourceGroup:               state.ResourceGroup,
+		NameSuffix:                  state.NameSuffix,
+		AgentPoolsToUpgrade:         state.Cursor.AgentPoolsToUpgrade,
+		AgentPools:                  make(map[string]*AgentPoolTopology),
+		AgentPoolScaleSetsToUpgrade: state.Cursor.AgentPoolScaleSetsToUpgrade,
+		MasterVMs:                   importVMs(state.Cursor.MasterVMs),
+		UpgradedMasterVMs:           importVMs(state.Cursor.UpgradedMasterVMs),
+	}
+	for name, pool := range state.Cursor.AgentPools {
+		poolName, identifier := name, pool.Identifier
+		topology.AgentPools[name] = &AgentPoolTopology{
+			Identifier:       &identifier,
+			Name:             &poolName,
+			AgentVMs:         importVMs(pool.AgentVMs),
+			UpgradedAgentVMs: importVMs(pool.UpgradedAgentVMs),
+		}
+	}
+
+	u := &Upgrader{}
+	u.Init(translator, log.NewEntry(log.New()), topology, nil, "", nil, nil, state.AKSEngineVersion, state.ControlPlaneOnly)
+	u.CurrentVersion = state.CurrentVersion
+	u.TemplateSource = &stateTemplateSource{template: state.Template, parameters: state.Parameters}
+	return u, nil
+}
+
+// stateTemplateSource is a TemplateSource providing the template of an imported upgrade state
+type stateTemplateSource struct {
+	template   map[string]interface{}
+	parameters map[string]interface{}
+}
+
+// Load returns copies of the template and parameters, the upgrade customizes them for each deployment
+func (s *stateTemplateSource) Load(ctx context.Context) (map[string]interface{}, map[string]interface{}, error) {
+	templateJSON, err := json.Marshal(s.template)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "marshaling the ARM template")
+	}
+	parametersJSON, err := json.Marshal(s.parameters)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "marshaling the ARM parameters")
+	}
+	return parseTemplate(templateJSON, parametersJSON)
+}
+
+func exportVMs(vms *[]compute.VirtualMachine) []UpgradeStateVM {
+	if vms == nil {
+		return nil
+	}
+	exported := make([]UpgradeStateVM, 0, len(*vms))
+	for _, vm := range *vms {
+		state := UpgradeStateVM{}
+		if vm.Name != nil {
+			state.Name = *vm.Name
+		}
+		if vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.OsDisk != nil {
+			state.OSType = vm.StorageProfile.OsDisk.OsType
+		}
+		exported = append(exported, state)
+	}
+	return exported
+}
+
+func importVMs(states []UpgradeStateVM) *[]compute.VirtualMachine {
+	vms := make([]compute.VirtualMachine, 0, len(states))
+	for _, state := range states {
+		name := state.Name
+		vms = append(vms, compute.VirtualMachine{
+			Name: &name,
+			VirtualMachineProperties: &compute.VirtualMachineProperties{
+				StorageProfile: &compute.StorageProfile{
+					OsDisk: &compute.OSDisk{OsType: state.OSType},
+				},
+			},
+		})
+	}
+	return &vms
+}
+
+// ExportState lists the cluster nodes to upgrade, as UpgradeCluster does, and exports the upgrade state
+// of the Upgrader of the cluster to outputPath
+func (uc *UpgradeCluster) ExportState(ctx context.Context, aksEngineVersion, outputPath string) error {
+	uc.MasterVMs = &[]compute.VirtualMachine{}
+	uc.UpgradedMasterVMs = &[]compute.VirtualMachine{}
+	uc.AgentPools = make(map[string]*AgentPoolTopology)
+
+	kubeClient, err := uc.Client.GetKubernetesClient("", uc.KubeConfig, interval, getResourceTimeout)
+	if err != nil {
+		uc.Logger.Warnf("Failed to get a Kubernetes client: %v", err)
+		kubeClient = nil
+	}
+	if err = uc.setNodesToUpgrade(kubeClient, uc.ResourceGroup); err != nil {
+		return errors.Wrap(err, "listing the cluster nodes to upgrade")
+	}
+	u, ok := uc.getUpgradeWorkflow(uc.KubeConfig, aksEngineVersion).(*Upgrader)
+	if !ok {
+		return errors.New("exporting the upgrade state requires the default upgrade workflow")
+	}
+	return u.ExportState(ctx, outputPath)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newTestStateVM(name string, osType compute.OperatingSystemTypes) compute.VirtualMachine {
+	return compute.VirtualMachine{
+		Name: to.StringPtr(name),
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			StorageProfile: &compute.StorageProfile{
+				OsDisk: &compute.OSDisk{OsType: osType},
+			},
+		},
+	}
+}
+
+var _ = Describe("Upgrade state tests", func() {
+	var (
+		u    *Upgrader
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "upgradestate")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "state", "upgrade-state.json")
+
+		u = newTestCRDUpgrader("1.18.8", &armhelpers.MockKubernetesClient{})
+		u.CurrentVersion = "1.17.11"
+		u.SubscriptionID = "00000000-0000-0000-0000-000000000000"
+		u.ResourceGroup = "testcluster-rg"
+		u.NameSuffix = "12345678"
+		u.MasterVMs = &[]compute.VirtualMachine{newTestStateVM("k8s-master-12345678-1", compute.Linux)}
+		u.UpgradedMasterVMs = &[]compute.VirtualMachine{newTestStateVM("k8s-master-12345678-0", compute.Linux)}
+		u.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+		u.AgentPools = map[string]*AgentPoolTopology{
+			"agentpool1": {
+				Identifier:       to.StringPtr("agentpool1"),
+				Name:             to.StringPtr("agentpool1"),
+				AgentVMs:         &[]compute.VirtualMachine{newTestStateVM("k8s-agentpool1-12345678-0", compute.Linux)},
+				UpgradedAgentVMs: &[]compute.VirtualMachine{},
+			},
+		}
+		u.AgentPoolScaleSetsToUpgrade = []AgentPoolScaleSet{{
+			Name:         "k8s-agentpool2-12345678-vmss",
+			Sku:          compute.Sku{Name: to.StringPtr("Standard_D2_v3"), Capacity: to.Int64Ptr(2)},
+			Location:     "westus2",
+			VMsToUpgrade: []AgentPoolScaleSetVM{{Name: "k8s-agentpool2-12345678-vmss000000", InstanceID: "0"}},
+		}}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should import the exported upgrade state", func() {
+		Expect(u.ExportState(context.Background(), path)).To(Succeed())
+
+		imported, err := ImportState(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported.AKSEngineVersion).To(Equal(TestAKSEngineVersion))
+		Expect(imported.CurrentVersion).To(Equal("1.17.11"))
+		Expect(imported.SubscriptionID).To(Equal(u.SubscriptionID))
+		Expect(imported.ResourceGroup).To(Equal("testcluster-rg"))
+		Expect(imported.NameSuffix).To(Equal("12345678"))
+		Expect(*imported.MasterVMs).To(Equal(*u.MasterVMs))
+		Expect(*imported.UpgradedMasterVMs).To(Equal(*u.UpgradedMasterVMs))
+		Expect(imported.AgentPoolsToUpgrade).To(Equal(u.AgentPoolsToUpgrade))
+		Expect(imported.AgentPools).To(Equal(u.AgentPools))
+		Expect(imported.AgentPoolScaleSetsToUpgrade).To(Equal(u.AgentPoolScaleSetsToUpgrade))
+		Expect(imported.DataModel.Properties.OrchestratorProfile.OrchestratorVersion).To(Equal("1.18.8"))
+		Expect(imported.DataModel.Properties.MasterProfile.Count).To(Equal(u.DataModel.Properties.MasterProfile.Count))
+		Expect(imported.DataModel.Properties.AgentPoolProfiles).To(HaveLen(1))
+	})
+
+	It("Should deploy the exported upgrade template once imported", func() {
+		Expect(u.ExportState(context.Background(), path)).To(Succeed())
+		exported, exportedParameters, err := u.generateUpgradeTemplate(context.Background(), u.DataModel, u.AKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+
+		imported, err := ImportState(path)
+		Expect(err).NotTo(HaveOccurred())
+		template, parameters, err := imported.generateUpgradeTemplate(context.Background(), imported.DataModel, imported.AKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template).To(Equal(exported))
+		Expect(parameters).To(Equal(exportedParameters))
+
+		// each deployment customizes its own copy of the template
+		delete(template, "resources")
+		template, _, err = imported.generateUpgradeTemplate(context.Background(), imported.DataModel, imported.AKSEngineVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template).To(HaveKey("resources"))
+	})
+
+	It("Should marshal the upgrade state without loss", func() {
+		Expect(u.ExportState(context.Background(), path)).To(Succeed())
+		b, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		state := &UpgradeState{}
+		Expect(json.Unmarshal(b, state)).To(Succeed())
+		Expect(state.Version).To(Equal(UpgradeStateVersion))
+		Expect(state.Cursor.UpgradedMasterVMs).To(Equal([]UpgradeStateVM{{Name: "k8s-master-12345678-0", OSType: compute.Linux}}))
+		Expect(state.Cursor.AgentPools).To(HaveKeyWithValue("agentpool1", UpgradeStateAgentPool{
+			Identifier:       "agentpool1",
+			AgentVMs:         []UpgradeStateVM{{Name: "k8s-agentpool1-12345678-0", OSType: compute.Linux}},
+			UpgradedAgentVMs: []UpgradeStateVM{},
+		}))
+
+		remarshaled, err := json.MarshalIndent(state, "", "  ")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remarshaled).To(MatchJSON(b))
+	})
+
+	It("Should refuse an unsupported upgrade state version", func() {
+		Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(`{"version": 2}`), 0600)).To(Succeed())
+
+		_, err := ImportState(path)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("unsupported upgrade state version 2, expected 1"))
+	})
+
+	It("Should fail to import a missing upgrade state file", func() {
+		_, err := ImportState(path)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("reading the upgrade state"))
+	})
+})
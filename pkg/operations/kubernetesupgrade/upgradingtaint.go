@@ -0,0 +1,56 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// UpgradingTaintKey is the key of the NoSchedule taint set on new agent nodes until they are ready
+// when PostCreateTaintEviction is set
+const UpgradingTaintKey = "node.aks-engine/upgrading"
+
+var upgradingTaint = v1.Taint{
+	Key:    UpgradingTaintKey,
+	Value:  "true",
+	Effect: v1.TaintEffectNoSchedule,
+}
+
+// addUpgradingTaint sets the upgrading taint on the node, unless it already has it
+func addUpgradingTaint(client kubernetes.Client, node *v1.Node) error {
+	for _, taint := range node.Spec.Taints {
+		if taint.MatchTaint(&upgradingTaint) {
+			return nil
+		}
+	}
+	node.Spec.Taints = append(node.Spec.Taints, upgradingTaint)
+	if _, err := client.UpdateNode(node); err != nil {
+		return errors.Wrapf(err, "adding taint %s to node %s", UpgradingTaintKey, node.Name)
+	}
+	return nil
+}
+
+// removeUpgradingTaint removes the upgrading taint from the node
+func removeUpgradingTaint(client kubernetes.Client, nodeName string) error {
+	node, err := client.GetNode(nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "getting node %s", nodeName)
+	}
+	taints := make([]v1.Taint, 0, len(node.Spec.Taints))
+	for _, taint := range node.Spec.Taints {
+		if !taint.MatchTaint(&upgradingTaint) {
+			taints = append(taints, taint)
+		}
+	}
+	if len(taints) == len(node.Spec.Taints) {
+		return nil
+	}
+	node.Spec.Taints = taints
+	if _, err = client.UpdateNode(node); err != nil {
+		return errors.Wrapf(err, "removing taint %s from node %s", UpgradingTaintKey, nodeName)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Upgrading taint tests", func() {
+	var (
+		kan        *UpgradeAgentNode
+		kubeClient *armhelpers.MockKubernetesClient
+		node       *v1.Node
+		notReady   int
+		updates    [][]v1.Taint
+		vmName     = "k8s-agentpool1-12345678-0"
+	)
+
+	BeforeEach(func() {
+		notReady = 1
+		updates = nil
+		node = &v1.Node{}
+		node.Name = vmName
+		node.Spec.Taints = []v1.Taint{{Key: "node.kubernetes.io/not-ready", Effect: v1.TaintEffectNoSchedule}}
+		kubeClient = &armhelpers.MockKubernetesClient{
+			GetNodeFunc: func(name string) (*v1.Node, error) {
+				n := node.DeepCopy()
+				status := v1.ConditionTrue
+				if notReady > 0 {
+					notReady--
+					status = v1.ConditionFalse
+				}
+				n.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}
+				return n, nil
+			},
+			UpdateNodeFunc: func(n *v1.Node) (*v1.Node, error) {
+				updates = append(updates, n.Spec.Taints)
+				node = n.DeepCopy()
+				return n, nil
+			},
+		}
+		kan = newTestUpgradeAgentNode("Standard_D2_v2")
+		kan.Client = &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}
+		kan.timeout = time.Minute
+		kan.PostCreateTaintEviction = true
+	})
+
+	It("Should taint the node until it is ready", func() {
+		Expect(kan.Validate(&vmName)).To(Succeed())
+
+		Expect(updates).To(HaveLen(2))
+		Expect(updates[0]).To(ContainElement(upgradingTaint))
+		Expect(updates[1]).NotTo(ContainElement(upgradingTaint))
+		Expect(node.Spec.Taints).To(Equal([]v1.Taint{{Key: "node.kubernetes.io/not-ready", Effect: v1.TaintEffectNoSchedule}}))
+	})
+
+	It("Should not taint the node without PostCreateTaintEviction", func() {
+		kan.PostCreateTaintEviction = false
+
+		Expect(kan.Validate(&vmName)).To(Succeed())
+		Expect(updates).To(BeEmpty())
+	})
+
+	It("Should not taint a node that is ready when it registers", func() {
+		notReady = 0
+
+		Expect(kan.Validate(&vmName)).To(Succeed())
+		Expect(updates).To(BeEmpty())
+	})
+
+	It("Should fail the validation when the taint cannot be removed", func() {
+		kubeClient.UpdateNodeFunc = func(n *v1.Node) (*v1.Node, error) {
+			if len(updates) > 0 {
+				return nil, errors.New("conflict")
+			}
+			updates = append(updates, n.Spec.Taints)
+			node = n.DeepCopy()
+			return n, nil
+		}
+
+		err := kan.Validate(&vmName)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("removing taint node.aks-engine/upgrading from node " + vmName))
+	})
+})
@@ -0,0 +1,105 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+)
+
+// NodeValidationError lists the existing nodes that failed validation
+type NodeValidationError struct {
+	Failures []NodeFailure
+	// Validated is the number of nodes validated
+	Validated int
+}
+
+func (e *NodeValidationError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.NodeName, f.Err))
+	}
+	return fmt.Sprintf("%d of %d nodes failed validation: %s", len(e.Failures), e.Validated, strings.Join(failures, "; "))
+}
+
+// ValidateExistingNodes runs Validate on every existing master and agent node, agent nodes excluded when
+// ControlPlaneOnly is set, without deleting or creating any node. The nodes failing validation are logged in
+// a summary and returned as a NodeValidationError.
+func (ku *Upgrader) ValidateExistingNodes() error {
+	timeout := defaultTimeout
+	if ku.stepTimeout != nil {
+		timeout = *ku.stepTimeout
+	}
+	kmn := &UpgradeMasterNode{
+		Translator:              ku.Translator,
+		logger:                  ku.logger,
+		UpgradeContainerService: ku.ClusterTopology.DataModel,
+		SubscriptionID:          ku.ClusterTopology.SubscriptionID,
+		ResourceGroup:           ku.ClusterTopology.ResourceGroup,
+		Client:                  ku.Client,
+		kubeConfig:              ku.kubeConfig,
+		timeout:                 timeout,
+		PrivateDNSSuffix:        ku.PrivateDNSSuffix,
+		NodeJoinTimeout:         ku.NodeJoinTimeout,
+	}
+	kan := &UpgradeAgentNode{
+		Translator:              ku.Translator,
+		logger:                  ku.logger,
+		UpgradeContainerService: ku.ClusterTopology.DataModel,
+		SubscriptionID:          ku.ClusterTopology.SubscriptionID,
+		ResourceGroup:           ku.ClusterTopology.ResourceGroup,
+		Client:                  ku.Client,
+		kubeConfig:              ku.kubeConfig,
+		timeout:                 timeout,
+		validateOnly:            true,
+	}
+
+	var nodes []string
+	var failures []NodeFailure
+	validate := func(node UpgradeNode, vmName string) {
+		nodes = append(nodes, vmName)
+		ku.logger.Infof("Validating existing node %s", vmName)
+		if err := node.Validate(&vmName); err != nil {
+			failures = append(failures, NodeFailure{NodeName: vmName, Err: err})
+		}
+	}
+	if ku.ClusterTopology.DataModel.Properties.MasterProfile != nil {
+		for _, vms := range []*[]compute.VirtualMachine{ku.ClusterTopology.MasterVMs, ku.ClusterTopology.UpgradedMasterVMs} {
+			if vms == nil {
+				continue
+			}
+			for _, vm := range *vms {
+				validate(kmn, *vm.Name)
+			}
+		}
+	}
+	if !ku.ControlPlaneOnly {
+		for _, pool := range ku.ClusterTopology.AgentPools {
+			for _, vms := range []*[]compute.VirtualMachine{pool.AgentVMs, pool.UpgradedAgentVMs} {
+				if vms == nil {
+					continue
+				}
+				for _, vm := range *vms {
+					validate(kan, *vm.Name)
+				}
+			}
+		}
+		for _, vmss := range ku.ClusterTopology.AgentPoolScaleSetsToUpgrade {
+			for _, vm := range vmss.VMsToUpgrade {
+				validate(kan, vm.Name)
+			}
+		}
+	}
+
+	ku.logger.Infof("Validated %d existing nodes, %d failed validation", len(nodes), len(failures))
+	for _, f := range failures {
+		ku.logger.Errorf("Node %s failed validation: %v", f.NodeName, f.Err)
+	}
+	if len(failures) > 0 {
+		return &NodeValidationError{Failures: failures, Validated: len(nodes)}
+	}
+	return nil
+}
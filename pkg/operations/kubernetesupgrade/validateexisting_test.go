@@ -0,0 +1,95 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Validate existing nodes tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kubeClient *armhelpers.MockKubernetesClient
+		uc         *UpgradeCluster
+		validated  []string
+	)
+
+	BeforeEach(func() {
+		validated = nil
+		kubeClient = &armhelpers.MockKubernetesClient{}
+		kubeClient.GetNodeFunc = func(name string) (*v1.Node, error) {
+			validated = append(validated, name)
+			node := &v1.Node{}
+			node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+			return node, nil
+		}
+		mockClient = &armhelpers.MockAKSEngineClient{MockKubernetesClient: kubeClient}
+		mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+			return []compute.VirtualMachine{
+				mockClient.MakeFakeVirtualMachine("k8s-master-12345678-0", "Kubernetes:1.16.9"),
+				mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", "Kubernetes:1.16.9"),
+			}
+		}
+		stepTimeout := 100 * time.Millisecond
+		uc = &UpgradeCluster{
+			Translator:       &i18n.Translator{},
+			Logger:           log.NewEntry(log.New()),
+			Client:           mockClient,
+			StepTimeout:      &stepTimeout,
+			ValidateExisting: true,
+		}
+		uc.DataModel = api.CreateMockContainerService("testcluster", "1.16.9", 1, 1, false)
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+	})
+
+	It("Should validate the nodes already on the desired version without upgrading them", func() {
+		Expect(uc.UpgradeCluster(mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+
+		Expect(validated).To(ContainElement("k8s-master-12345678-0"))
+		Expect(validated).To(ContainElement("k8s-agentpool1-12345678-0"))
+		Expect(mockClient.DeploymentModes).To(BeEmpty())
+		Expect(kubeClient.Events).To(BeEmpty())
+	})
+
+	It("Should report the nodes failing validation without deleting them", func() {
+		kubeClient.GetNodeFunc = func(name string) (*v1.Node, error) {
+			node := &v1.Node{}
+			node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+			if name == "k8s-agentpool1-12345678-0" {
+				node.Status.Conditions[0].Status = v1.ConditionFalse
+			}
+			return node, nil
+		}
+		mockClient.FailDeleteVirtualMachine = true
+
+		err := uc.UpgradeCluster(mockClient, "kubeConfig", TestAKSEngineVersion)
+		Expect(err).To(HaveOccurred())
+		validationErr, ok := err.(*NodeValidationError)
+		Expect(ok).To(BeTrue())
+		Expect(validationErr.Validated).To(Equal(2))
+		Expect(validationErr.Failures).To(HaveLen(1))
+		Expect(validationErr.Failures[0].NodeName).To(Equal("k8s-agentpool1-12345678-0"))
+		Expect(err.Error()).To(Equal("1 of 2 nodes failed validation: k8s-agentpool1-12345678-0: Node was not ready within 100ms"))
+	})
+
+	It("Should only validate the master nodes when ControlPlaneOnly is set", func() {
+		uc.ControlPlaneOnly = true
+
+		Expect(uc.UpgradeCluster(mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(validated).To(ContainElement("k8s-master-12345678-0"))
+		Expect(validated).NotTo(ContainElement("k8s-agentpool1-12345678-0"))
+	})
+})
@@ -0,0 +1,314 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/kubernetes"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// VerifyCheck is the outcome of one of the checks run by Verify
+type VerifyCheck struct {
+	Name     string
+	Duration time.Duration
+	// Skipped is set when the check does not apply to the cluster
+	Skipped bool
+	// Err is nil if the check passed
+	Err error
+}
+
+// VerifyReport holds the outcome of the checks run by Verify
+type VerifyReport struct {
+	Checks []VerifyCheck
+}
+
+// Failed returns the checks that did not pass
+func (r *VerifyReport) Failed() []VerifyCheck {
+	var failed []VerifyCheck
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// Write prints the report as a table with a row per check
+func (r *VerifyReport) Write(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDURATION\tDETAILS")
+	for _, check := range r.Checks {
+		result, details := "PASS", ""
+		if check.Skipped {
+			result = "SKIP"
+		} else if check.Err != nil {
+			result, details = "FAIL", check.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", check.Name, result, check.Duration.Round(time.Millisecond), details)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "%d of %d checks passed\n", len(r.Checks)-len(r.Failed()), len(r.Checks))
+	return err
+}
+
+func (r *VerifyReport) run(name string, check func() error) error {
+	start := time.Now()
+	err := check()
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, Duration: time.Since(start), Err: err})
+	return err
+}
+
+func (r *VerifyReport) skip(name string) {
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, Skipped: true})
+}
+
+// Verify runs the upgrade preflight checks against the cluster without modifying it and writes
+// a report to VerifyOutput, or stdout if nil. It returns an error if any check fails.
+func (uc *UpgradeCluster) Verify(ctx context.Context) error {
+	uc.MasterVMs = &[]compute.VirtualMachine{}
+	uc.UpgradedMasterVMs = &[]compute.VirtualMachine{}
+	uc.AgentPools = make(map[string]*AgentPoolTopology)
+	isAzureStack := uc.DataModel.Properties.IsAzureStackCloud()
+	report := &VerifyReport{}
+
+	kubeClient, kubeErr := uc.Client.GetKubernetesClient("", uc.KubeConfig, interval, getResourceTimeout)
+	if kubeErr != nil {
+		kubeClient = nil
+	}
+
+	topologyErr := report.run("version compatibility", func() error {
+		if err := uc.setNodesToUpgrade(kubeClient, uc.ResourceGroup); err != nil {
+			return errors.Wrap(err, "listing the cluster nodes to upgrade")
+		}
+		return nil
+	})
+
+	_ = report.run("node health", func() error {
+		if kubeErr != nil {
+			return errors.Wrap(kubeErr, "getting a Kubernetes client")
+		}
+		return verifyNodesReady(kubeClient)
+	})
+
+	if topologyErr != nil || isAzureStack || uc.ControlPlaneOnly {
+		report.skip("quota")
+	} else {
+		_ = report.run("quota", func() error {
+			return uc.verifyQuota(ctx)
+		})
+	}
+
+	_ = report.run("control plane preflight", func() error {
+		return uc.verifyMasterPreflight(ctx)
+	})
+
+	if isAzureStack {
+		report.skip("resource locks")
+	} else {
+		_ = report.run("resource locks", func() error {
+			return uc.verifyNoResourceLocks(ctx)
+		})
+	}
+
+	out := uc.VerifyOutput
+	if out == nil {
+		out = os.Stdout
+	}
+	if err := report.Write(out); err != nil {
+		return errors.Wrap(err, "writing the verification report")
+	}
+	if failed := report.Failed(); len(failed) > 0 {
+		names := make([]string, 0, len(failed))
+		for _, check := range failed {
+			names = append(names, check.Name)
+		}
+		return errors.Errorf("%d of %d upgrade checks failed: %s", len(failed), len(report.Checks), strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// verifyNodesReady returns an error listing the nodes that are not ready
+func verifyNodesReady(client kubernetes.Client) error {
+	nodes, err := client.ListNodes()
+	if err != nil {
+		return errors.Wrap(err, "listing nodes")
+	}
+	var notReady []string
+	for i := range nodes.Items {
+		if !kubernetes.IsNodeReady(&nodes.Items[i]) {
+			notReady = append(notReady, nodes.Items[i].Name)
+		}
+	}
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		return errors.Errorf("nodes not ready: %s", strings.Join(notReady, ", "))
+	}
+	return nil
+}
+
+// verifyQuota ensures the regional and VM family vCPU quotas leave room for the extra agent VM
+// created while each agent pool is upgraded. Master VMs are deleted before being recreated.
+func (uc *UpgradeCluster) verifyQuota(ctx context.Context) error {
+	vmSizes := map[string]string{}
+	for _, app := range uc.DataModel.Properties.AgentPoolProfiles {
+		vmSizes[app.Name] = app.VMSize
+	}
+	var pools []string
+	for name, pool := range uc.AgentPools {
+		if pool.AgentVMs != nil && len(*pool.AgentVMs) > 0 {
+			pools = append(pools, name)
+		}
+	}
+	for _, vmss := range uc.AgentPoolScaleSetsToUpgrade {
+		if len(vmss.VMsToUpgrade) > 0 {
+			pools = append(pools, vmss.poolName())
+		}
+	}
+	if len(pools) == 0 {
+		return nil
+	}
+
+	skus, err := uc.listVMSizeSkus(ctx)
+	if err != nil {
+		return err
+	}
+	// the pools are upgraded one at a time, so the largest VM of a family is the most needed at once
+	required := map[string]int64{}
+	for _, pool := range pools {
+		sku, ok := skus[strings.ToLower(vmSizes[pool])]
+		if !ok {
+			return errors.Errorf("could not find VM size %s of agent pool %s in location %s", vmSizes[pool], pool, uc.DataModel.Location)
+		}
+		for _, name := range []string{"cores", sku.family} {
+			if sku.vCPUs > required[name] {
+				required[name] = sku.vCPUs
+			}
+		}
+	}
+
+	usages, err := uc.Client.ListComputeUsages(ctx, uc.DataModel.Location)
+	if err != nil {
+		return errors.Wrap(err, "listing compute usages")
+	}
+	var exceeded []string
+	for _, usage := range usages {
+		if usage.Name == nil || usage.Limit == nil || usage.CurrentValue == nil {
+			continue
+		}
+		name := to.String(usage.Name.Value)
+		need, ok := required[name]
+		if !ok {
+			continue
+		}
+		if available := *usage.Limit - int64(*usage.CurrentValue); available < need {
+			exceeded = append(exceeded, fmt.Sprintf("%s needs %d vCPUs, %d of %d available", name, need, available, *usage.Limit))
+		}
+	}
+	if len(exceeded) > 0 {
+		sort.Strings(exceeded)
+		return errors.Errorf("insufficient quota in location %s: %s", uc.DataModel.Location, strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+type vmSizeSku struct {
+	family string
+	vCPUs  int64
+}
+
+// listVMSizeSkus returns the family and vCPUs of the VM sizes available in the cluster location, keyed by lower case name
+func (uc *UpgradeCluster) listVMSizeSkus(ctx context.Context) (map[string]vmSizeSku, error) {
+	page, err := uc.Client.ListResourceSkus(ctx, fmt.Sprintf("location eq '%s'", uc.DataModel.Location))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing resource SKUs")
+	}
+	skus := map[string]vmSizeSku{}
+	for page != nil && page.NotDone() {
+		for _, sku := range page.Values() {
+			if !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") || sku.Capabilities == nil {
+				continue
+			}
+			for _, c := range *sku.Capabilities {
+				if to.String(c.Name) != "vCPUs" {
+					continue
+				}
+				if vCPUs, err := strconv.ParseInt(to.String(c.Value), 10, 64); err == nil {
+					skus[strings.ToLower(to.String(sku.Name))] = vmSizeSku{family: to.String(sku.Family), vCPUs: vCPUs}
+				}
+			}
+		}
+		if err = page.NextWithContext(ctx); err != nil {
+			return nil, errors.Wrap(err, "listing resource SKUs")
+		}
+	}
+	return skus, nil
+}
+
+// verifyMasterPreflight runs the preflight checks of the master upgrade, e.g. the master subnet capacity
+func (uc *UpgradeCluster) verifyMasterPreflight(ctx context.Context) error {
+	kmn := &UpgradeMasterNode{
+		Translator:                 uc.Translator,
+		logger:                     uc.Logger,
+		UpgradeContainerService:    uc.DataModel,
+		SubscriptionID:             uc.SubscriptionID,
+		ResourceGroup:              uc.ResourceGroup,
+		Client:                     uc.Client,
+		ProximityPlacementGroupID:  uc.ProximityPlacementGroupID,
+		ResourceTags:               uc.ResourceTags,
+		VNetResourceGroup:          uc.VNetResourceGroup,
+		APIModelVersion:            uc.APIModelVersion,
+		RequiredSchemaVersion:      uc.RequiredSchemaVersion,
+		ReplacementSubnetID:        uc.ReplacementSubnetID,
+		VNetPeeringID:              uc.VNetPeeringID,
+		VMAPIVersion:               uc.VMAPIVersion,
+		MaintenanceConfigurationID: uc.MaintenanceConfigurationID,
+		MaintenanceClient:          uc.MaintenanceClient,
+		PolicyExemptions:           uc.PolicyExemptions,
+		PolicyExemptionClient:      uc.PolicyExemptionClient,
+		DedicatedHostGroupID:       uc.DedicatedHostGroupID,
+		CloudInitScript:            uc.CloudInitScript,
+		OSProfile:                  uc.OSProfile,
+		SecretResolver:             uc.SecretResolver,
+		DeploymentMode:             uc.DeploymentMode,
+		TemplateBlobURI:            uc.TemplateBlobURI,
+		TemplateBlobClient:         uc.TemplateBlobClient,
+		UltraDiskEnabled:           uc.UltraDiskEnabled,
+		AcceleratedNetworking:      uc.AcceleratedNetworking,
+		UserAssignedIdentities:     uc.UserAssignedIdentities,
+		RoleAssignments:            uc.RoleAssignments,
+	}
+	return kmn.Preflight(ctx)
+}
+
+// verifyNoResourceLocks returns an error if the resource group or its resources are locked,
+// as a lock prevents deleting or updating the VMs during the upgrade
+func (uc *UpgradeCluster) verifyNoResourceLocks(ctx context.Context) error {
+	locks, err := uc.Client.ListManagementLocks(ctx, uc.ResourceGroup)
+	if err != nil {
+		return errors.Wrapf(err, "listing management locks of resource group %s", uc.ResourceGroup)
+	}
+	if len(locks) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(locks))
+	for _, lock := range locks {
+		names = append(names, fmt.Sprintf("%s (%s)", lock.Name, lock.Properties.Level))
+	}
+	sort.Strings(names)
+	return errors.Errorf("resource group %s has management locks preventing the upgrade: %s", uc.ResourceGroup, strings.Join(names, ", "))
+}
@@ -0,0 +1,159 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+func newTestComputeUsage(name string, current int32, limit int64) compute.Usage {
+	return compute.Usage{
+		Name:         &compute.UsageName{Value: to.StringPtr(name)},
+		CurrentValue: to.Int32Ptr(current),
+		Limit:        to.Int64Ptr(limit),
+	}
+}
+
+func newTestReadyNode(name string, ready bool) v1.Node {
+	status := v1.ConditionTrue
+	if !ready {
+		status = v1.ConditionFalse
+	}
+	node := v1.Node{}
+	node.Name = name
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}
+	return node
+}
+
+var _ = Describe("Upgrade verification tests", func() {
+	var (
+		uc         *UpgradeCluster
+		mockClient *armhelpers.MockAKSEngineClient
+		kubeClient *armhelpers.MockKubernetesClient
+		out        *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		kubeClient = &armhelpers.MockKubernetesClient{
+			NodesList: &v1.NodeList{Items: []v1.Node{
+				newTestReadyNode("k8s-master-12345678-0", true),
+				newTestReadyNode("k8s-agentpool1-12345678-0", true),
+			}},
+		}
+		mockClient = &armhelpers.MockAKSEngineClient{
+			MockKubernetesClient: kubeClient,
+			FakeListResourceSkusResult: func() []compute.ResourceSku {
+				return []compute.ResourceSku{{
+					Name:         to.StringPtr("Standard_D2_v2"),
+					ResourceType: to.StringPtr("virtualMachines"),
+					Family:       to.StringPtr("standardDv2Family"),
+					Capabilities: &[]compute.ResourceSkuCapabilities{{Name: to.StringPtr("vCPUs"), Value: to.StringPtr("2")}},
+				}}
+			},
+			FakeListComputeUsagesResult: []compute.Usage{
+				newTestComputeUsage("cores", 10, 100),
+				newTestComputeUsage("standardDv2Family", 8, 50),
+				newTestComputeUsage("standardDSv3Family", 0, 0),
+			},
+		}
+		out = &bytes.Buffer{}
+		uc = &UpgradeCluster{
+			Translator:   &i18n.Translator{},
+			Logger:       log.NewEntry(log.New()),
+			Client:       mockClient,
+			KubeConfig:   "kubeConfig",
+			VerifyOutput: out,
+		}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = api.CreateMockContainerService("testcluster", "", 1, 1, false)
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+	})
+
+	It("Should pass all checks of a healthy cluster", func() {
+		Expect(uc.Verify(context.Background())).To(Succeed())
+
+		report := out.String()
+		for _, check := range []string{"version compatibility", "node health", "quota", "control plane preflight", "resource locks"} {
+			Expect(report).To(MatchRegexp(`(?m)^` + check + ` +PASS +[0-9.]+[mµn]?s *$`))
+		}
+		Expect(report).To(HavePrefix("CHECK"))
+		Expect(report).To(HaveSuffix("5 of 5 checks passed\n"))
+		Expect(uc.AgentPools).To(HaveKey("agentpool1"))
+	})
+
+	It("Should report each failed check", func() {
+		kubeClient.NodesList.Items[1] = newTestReadyNode("k8s-agentpool1-12345678-0", false)
+		mockClient.FakeListComputeUsagesResult[1] = newTestComputeUsage("standardDv2Family", 49, 50)
+		mockClient.FakeListManagementLocksResult = []armhelpers.ManagementLock{
+			{Name: "nodelete", Properties: armhelpers.ManagementLockProperties{Level: "CanNotDelete"}},
+		}
+
+		err := uc.Verify(context.Background())
+		Expect(err).To(MatchError("3 of 5 upgrade checks failed: node health, quota, resource locks"))
+
+		report := out.String()
+		Expect(report).To(MatchRegexp(`(?m)^version compatibility +PASS`))
+		Expect(report).To(ContainSubstring("nodes not ready: k8s-agentpool1-12345678-0"))
+		Expect(report).To(ContainSubstring("insufficient quota in location eastus: standardDv2Family needs 2 vCPUs, 1 of 50 available"))
+		Expect(report).To(ContainSubstring("resource group TestRg has management locks preventing the upgrade: nodelete (CanNotDelete)"))
+		Expect(report).To(HaveSuffix("2 of 5 checks passed\n"))
+	})
+
+	It("Should fail the node health check when the API server cannot be reached", func() {
+		mockClient.FailGetKubernetesClient = true
+
+		err := uc.Verify(context.Background())
+		Expect(err).To(MatchError("1 of 5 upgrade checks failed: node health"))
+		Expect(out.String()).To(ContainSubstring("getting a Kubernetes client"))
+	})
+
+	It("Should skip the quota check of a control plane only upgrade", func() {
+		uc.ControlPlaneOnly = true
+		mockClient.FailListComputeUsages = true
+
+		Expect(uc.Verify(context.Background())).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`(?m)^quota +SKIP`))
+		Expect(out.String()).To(HaveSuffix("5 of 5 checks passed\n"))
+	})
+
+	It("Should skip the quota check when the nodes to upgrade cannot be listed", func() {
+		mockClient.FailListVirtualMachines = true
+
+		err := uc.Verify(context.Background())
+		Expect(err).To(MatchError("1 of 5 upgrade checks failed: version compatibility"))
+		Expect(out.String()).To(MatchRegexp(`(?m)^quota +SKIP`))
+	})
+
+	It("Should write the duration of each check", func() {
+		report := &VerifyReport{Checks: []VerifyCheck{
+			{Name: "quota", Duration: 1500 * time.Millisecond},
+			{Name: "resource locks", Duration: 20 * time.Millisecond, Err: errors.New("locked")},
+			{Name: "node health", Skipped: true},
+		}}
+		Expect(report.Failed()).To(HaveLen(1))
+
+		out := &bytes.Buffer{}
+		Expect(report.Write(out)).To(Succeed())
+		Expect(out.String()).To(Equal("CHECK           RESULT  DURATION  DETAILS\n" +
+			"quota           PASS    1.5s      \n" +
+			"resource locks  FAIL    20ms      locked\n" +
+			"node health     SKIP    0s        \n" +
+			"2 of 3 checks passed\n"))
+	})
+})
@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/pkg/errors"
+)
+
+// isEvictable returns true if VMs of the priority can be evicted by Azure, i.e. Spot or Low, its deprecated name
+func isEvictable(priority string) bool {
+	return priority == string(compute.Spot) || priority == string(compute.Low)
+}
+
+// ValidateVMPriority checks that priority, if set, is a VM priority, Regular, Spot or Low, and that evictionPolicy,
+// Deallocate or Delete, is only set along with an evictable priority.
+func ValidateVMPriority(priority, evictionPolicy string) error {
+	switch compute.VirtualMachinePriorityTypes(priority) {
+	case "", compute.Regular, compute.Spot, compute.Low:
+	default:
+		return errors.Errorf("invalid VM priority %q, expected %s, %s or %s", priority, compute.Regular, compute.Spot, compute.Low)
+	}
+	if evictionPolicy == "" {
+		return nil
+	}
+	if !isEvictable(priority) {
+		return errors.Errorf("an eviction policy requires the %s VM priority", compute.Spot)
+	}
+	switch compute.VirtualMachineEvictionPolicyTypes(evictionPolicy) {
+	case compute.Deallocate, compute.Delete:
+		return nil
+	}
+	return errors.Errorf("invalid eviction policy %q, expected %s or %s", evictionPolicy, compute.Deallocate, compute.Delete)
+}
+
+// warnIfEvictable logs a warning if the new master VMs can be evicted
+func (kmn *UpgradeMasterNode) warnIfEvictable() {
+	if isEvictable(kmn.VMPriority) {
+		kmn.logger.Warningf("Master VMs of %s priority can be evicted by Azure at any time, taking down their etcd member "+
+			"and possibly the etcd quorum; only use them for clusters that can afford to lose their control plane", kmn.VMPriority)
+	}
+}
+
+// setVMPriority sets the priority of the master VM resource, and the eviction policy of evictable VMs,
+// Deallocate unless EvictionPolicy is set
+func (kmn *UpgradeMasterNode) setVMPriority(vm map[string]interface{}) {
+	properties := resourceProperties(vm)
+	properties["priority"] = kmn.VMPriority
+	if !isEvictable(kmn.VMPriority) {
+		delete(properties, "evictionPolicy")
+		return
+	}
+	evictionPolicy := kmn.EvictionPolicy
+	if evictionPolicy == "" {
+		evictionPolicy = string(compute.Deallocate)
+	}
+	properties["evictionPolicy"] = evictionPolicy
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/pkg/errors"
+)
+
+// peeredVNetIDVariable is the template variable holding the remote virtual network of UpgradeMasterNode.VNetPeeringID
+const peeredVNetIDVariable = "masterPeeredVNetID"
+
+// peeredVNetID returns the resource ID of the remote virtual network of VNetPeeringID,
+// a peering of the cluster virtual network that must be in the Connected state
+func (kmn *UpgradeMasterNode) peeredVNetID(ctx context.Context) (string, error) {
+	parts := strings.Split(kmn.VNetPeeringID, "/")
+	if len(parts) != 11 || !strings.EqualFold(parts[9], "virtualNetworkPeerings") {
+		return "", errors.Errorf("unable to parse virtual network peering ID %s", kmn.VNetPeeringID)
+	}
+	vnetID := strings.Join(parts[:9], "/")
+	clusterVNetID, err := subnetVNetID(kmn.masterSubnetID())
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(vnetID, clusterVNetID) {
+		return "", errors.Errorf("virtual network peering %s is not a peering of the virtual network of the cluster %s", kmn.VNetPeeringID, clusterVNetID)
+	}
+
+	peering, err := kmn.Client.GetVirtualNetworkPeering(ctx, parts[api.DefaultVnetResourceGroupSegmentIndex], parts[api.DefaultVnetNameResourceSegmentIndex], parts[10])
+	if err != nil {
+		return "", errors.Wrapf(err, "getting virtual network peering %s", kmn.VNetPeeringID)
+	}
+	if peering.VirtualNetworkPeeringPropertiesFormat == nil {
+		return "", errors.Errorf("virtual network peering %s has no properties", kmn.VNetPeeringID)
+	}
+	if peering.PeeringState != network.VirtualNetworkPeeringStateConnected {
+		return "", errors.Errorf("virtual network peering %s is in state %q, expected %q", kmn.VNetPeeringID, peering.PeeringState, network.VirtualNetworkPeeringStateConnected)
+	}
+	if peering.RemoteVirtualNetwork == nil || peering.RemoteVirtualNetwork.ID == nil {
+		return "", errors.Errorf("virtual network peering %s has no remote virtual network", kmn.VNetPeeringID)
+	}
+	return *peering.RemoteVirtualNetwork.ID, nil
+}
+
+// peeredSubnetID returns the resource ID of the subnet of the peered virtual network named as the master subnet
+func (kmn *UpgradeMasterNode) peeredSubnetID() string {
+	parts := strings.Split(kmn.masterSubnetID(), "/")
+	return kmn.peeredVNet + "/subnets/" + parts[len(parts)-1]
+}
+
+// setPeeredVNet attaches the master NIC resources to the subnet of the peered virtual network
+func (kmn *UpgradeMasterNode) setPeeredVNet() {
+	parts := strings.Split(kmn.masterSubnetID(), "/")
+	kmn.TemplateMap["variables"].(map[string]interface{})[peeredVNetIDVariable] = kmn.peeredVNet
+	for _, nic := range masterResources(kmn.TemplateMap, nicResourceType) {
+		ipConfigurations, _ := resourceProperties(nic)["ipConfigurations"].([]interface{})
+		for _, ipConfiguration := range ipConfigurations {
+			if ipConfigurationMap, ok := ipConfiguration.(map[string]interface{}); ok {
+				resourceProperties(ipConfigurationMap)["subnet"] = map[string]interface{}{
+					"id": "[concat(variables('" + peeredVNetIDVariable + "'), '/subnets/" + parts[len(parts)-1] + "')]",
+				}
+			}
+		}
+	}
+}
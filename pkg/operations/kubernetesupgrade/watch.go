@@ -0,0 +1,255 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultWatchRefreshInterval is how often the WatchReporter redraws the upgrade status by default
+const DefaultWatchRefreshInterval = 2 * time.Second
+
+// NodePhase is the upgrade step a node is in, as shown by the WatchReporter
+type NodePhase string
+
+// Phases of a node upgrade, Pending until the upgrade reaches the node
+const (
+	NodePending    NodePhase = "Pending"
+	NodeDraining   NodePhase = "Draining"
+	NodeDeleting   NodePhase = "Deleting"
+	NodeCreating   NodePhase = "Creating"
+	NodeValidating NodePhase = "Validating"
+	NodeDone       NodePhase = "Done"
+	NodeFailed     NodePhase = "Failed"
+	NodeSkipped    NodePhase = "Skipped"
+)
+
+var nodePhases = map[UpgradeEventType]NodePhase{
+	NodeDrainingEvent:      NodeDraining,
+	NodeDeletingEvent:      NodeDeleting,
+	NodeCreatingEvent:      NodeCreating,
+	NodeValidatingEvent:    NodeValidating,
+	NodeUpgradedEvent:      NodeDone,
+	NodeUpgradeFailedEvent: NodeFailed,
+	NodeSkippedEvent:       NodeSkipped,
+}
+
+type watchedNode struct {
+	name  string
+	pool  string
+	phase NodePhase
+	// start is set when the node leaves Pending, end when it reaches Done or Failed
+	start time.Time
+	end   time.Time
+	err   string
+}
+
+// WatchReporter renders a table of the nodes being upgraded, their phase and elapsed time,
+// redrawn in place with ANSI escape sequences every RefreshInterval between Start and Stop.
+type WatchReporter struct {
+	Out             io.Writer
+	RefreshInterval time.Duration
+
+	mu        sync.Mutex
+	nodes     map[string]*watchedNode
+	order     []string
+	start     time.Time
+	end       time.Time
+	result    string
+	failure   string
+	lastLines int
+	stop      chan struct{}
+	stopped   chan struct{}
+	// now returns the current time, time.Now if nil
+	now func() time.Time
+}
+
+// NewWatchReporter returns a WatchReporter writing to out
+func NewWatchReporter(out io.Writer) *WatchReporter {
+	return &WatchReporter{
+		Out:             out,
+		RefreshInterval: DefaultWatchRefreshInterval,
+	}
+}
+
+// Report updates the phase of the node of the event
+func (r *WatchReporter) Report(event UpgradeEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.timeNow()
+	switch event.Type {
+	case UpgradeStartedEvent:
+		r.start = now
+		for _, name := range event.Nodes {
+			r.node(name)
+		}
+	case UpgradeCompletedEvent:
+		r.end, r.result = now, "Upgrade completed"
+	case UpgradeFailedEvent:
+		r.end, r.result, r.failure = now, "Upgrade failed", event.Message
+	}
+	phase, ok := nodePhases[event.Type]
+	if !ok || event.NodeName == "" {
+		return nil
+	}
+	n := r.node(event.NodeName)
+	if event.PoolName != "" {
+		n.pool = event.PoolName
+	}
+	if n.start.IsZero() {
+		n.start = now
+	}
+	n.phase = phase
+	if phase == NodeDone || phase == NodeFailed || phase == NodeSkipped {
+		n.end = now
+	}
+	if phase == NodeFailed {
+		n.err = event.Message
+	}
+	return nil
+}
+
+// Start redraws the status table every RefreshInterval until Stop is called
+func (r *WatchReporter) Start() {
+	interval := r.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultWatchRefreshInterval
+	}
+	r.stop = make(chan struct{})
+	r.stopped = make(chan struct{})
+	go func() {
+		defer close(r.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		r.render(false)
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.render(false)
+			}
+		}
+	}()
+}
+
+// Stop stops the refresh and draws the final table followed by a summary of the upgrade
+func (r *WatchReporter) Stop() {
+	if r.stop != nil {
+		close(r.stop)
+		<-r.stopped
+		r.stop = nil
+	}
+	r.render(true)
+}
+
+func (r *WatchReporter) node(name string) *watchedNode {
+	if r.nodes == nil {
+		r.nodes = map[string]*watchedNode{}
+	}
+	n, ok := r.nodes[name]
+	if !ok {
+		n = &watchedNode{name: name, phase: NodePending}
+		r.nodes[name] = n
+		r.order = append(r.order, name)
+	}
+	return n
+}
+
+func (r *WatchReporter) timeNow() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// render draws the table over the previous one, and the summary if final is set
+func (r *WatchReporter) render(final bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.timeNow()
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tPOOL\tPHASE\tELAPSED")
+	finished, failed, skipped := 0, 0, 0
+	for _, name := range r.order {
+		n := r.nodes[name]
+		elapsed := "-"
+		if !n.start.IsZero() {
+			end := n.end
+			if end.IsZero() {
+				end = now
+			}
+			elapsed = end.Sub(n.start).Round(time.Second).String()
+		}
+		switch n.phase {
+		case NodeDone:
+			finished++
+		case NodeFailed:
+			finished++
+			failed++
+		case NodeSkipped:
+			finished++
+			skipped++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.name, n.pool, n.phase, elapsed)
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "Progress: %d/%d nodes (%d%%)\n", finished, len(r.order), progressPercent(finished, len(r.order)))
+
+	if final {
+		end := r.end
+		if end.IsZero() {
+			end = now
+		}
+		var elapsed time.Duration
+		if !r.start.IsZero() {
+			elapsed = end.Sub(r.start).Round(time.Second)
+		}
+		result := r.result
+		if result == "" {
+			result = "Upgrade stopped"
+		}
+		fmt.Fprintf(&buf, "\n%s in %v: %d nodes upgraded, %d failed", result, elapsed, finished-failed-skipped, failed)
+		if skipped > 0 {
+			fmt.Fprintf(&buf, ", %d skipped to upgrade manually", skipped)
+		}
+		fmt.Fprintln(&buf)
+		for _, name := range r.order {
+			switch n := r.nodes[name]; n.phase {
+			case NodeFailed:
+				fmt.Fprintf(&buf, "  %s: %s\n", n.name, n.err)
+			case NodeSkipped:
+				fmt.Fprintf(&buf, "  %s: skipped\n", n.name)
+			}
+		}
+		if r.failure != "" {
+			fmt.Fprintf(&buf, "Error: %s\n", r.failure)
+		}
+	}
+
+	if r.lastLines > 0 {
+		// move the cursor back to the first line of the previous table and clear the screen below
+		fmt.Fprintf(r.Out, "\x1b[%dA\x1b[J", r.lastLines)
+	}
+	_, _ = r.Out.Write(buf.Bytes())
+	r.lastLines = strings.Count(buf.String(), "\n")
+	if final {
+		r.lastLines = 0
+	}
+}
+
+func progressPercent(done, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return done * 100 / total
+}
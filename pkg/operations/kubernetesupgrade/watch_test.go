@@ -0,0 +1,107 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watch reporter tests", func() {
+	var (
+		out      *bytes.Buffer
+		reporter *WatchReporter
+		now      time.Time
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		now = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+		reporter = NewWatchReporter(out)
+		reporter.now = func() time.Time { return now }
+	})
+
+	report := func(event UpgradeEvent, after time.Duration) {
+		now = now.Add(after)
+		Expect(reporter.Report(event)).To(Succeed())
+	}
+
+	It("Should render the phase and elapsed time of each node", func() {
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-master-0", "k8s-agentpool1-0"}}, 0)
+		report(UpgradeEvent{Type: NodeDeletingEvent, PoolName: MasterPoolName, NodeName: "k8s-master-0"}, time.Second)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: MasterPoolName, NodeName: "k8s-master-0"}, 5*time.Minute)
+		report(UpgradeEvent{Type: NodeDrainingEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"}, time.Second)
+		now = now.Add(30 * time.Second)
+
+		reporter.render(false)
+		Expect(out.String()).To(Equal("" +
+			"NODE              POOL        PHASE     ELAPSED\n" +
+			"k8s-master-0      master      Done      5m0s\n" +
+			"k8s-agentpool1-0  agentpool1  Draining  30s\n" +
+			"Progress: 1/2 nodes (50%)\n"))
+	})
+
+	It("Should show the nodes not reached yet as pending", func() {
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-agentpool1-0"}}, 0)
+
+		reporter.render(false)
+		Expect(out.String()).To(ContainSubstring("k8s-agentpool1-0        Pending  -\n"))
+		Expect(out.String()).To(ContainSubstring("Progress: 0/1 nodes (0%)"))
+	})
+
+	It("Should redraw the table in place", func() {
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-agentpool1-0"}}, 0)
+		reporter.render(false)
+		out.Reset()
+
+		reporter.render(false)
+		Expect(out.String()).To(HavePrefix("\x1b[3A\x1b[J"))
+	})
+
+	It("Should leave a summary of the failed upgrade", func() {
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-agentpool1-0", "k8s-agentpool1-1"}}, 0)
+		report(UpgradeEvent{Type: NodeCreatingEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"}, time.Second)
+		report(UpgradeEvent{Type: NodeUpgradeFailedEvent, NodeName: "k8s-agentpool1-0", Message: "node not ready"}, time.Minute)
+		report(UpgradeEvent{Type: UpgradeFailedEvent, Message: "node not ready"}, time.Second)
+
+		reporter.Stop()
+		lines := strings.Split(out.String(), "\n")
+		Expect(lines).To(ContainElement(ContainSubstring("k8s-agentpool1-0  agentpool1  Failed   1m0s")))
+		Expect(lines).To(ContainElement("Upgrade failed in 1m2s: 0 nodes upgraded, 1 failed"))
+		Expect(lines).To(ContainElement("  k8s-agentpool1-0: node not ready"))
+		Expect(lines).To(ContainElement("Error: node not ready"))
+	})
+
+	It("Should list the skipped nodes in the summary", func() {
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-agentpool1-0", "k8s-agentpool1-1"}}, 0)
+		report(UpgradeEvent{Type: NodeSkippedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"}, time.Second)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-1"}, time.Minute)
+		report(UpgradeEvent{Type: UpgradeCompletedEvent, SkippedNodes: []string{"k8s-agentpool1-0"}}, time.Second)
+
+		reporter.Stop()
+		lines := strings.Split(out.String(), "\n")
+		Expect(lines).To(ContainElement("k8s-agentpool1-0  agentpool1  Skipped  0s"))
+		Expect(lines).To(ContainElement("Progress: 2/2 nodes (100%)"))
+		Expect(lines).To(ContainElement("Upgrade completed in 1m2s: 1 nodes upgraded, 0 failed, 1 skipped to upgrade manually"))
+		Expect(lines).To(ContainElement("  k8s-agentpool1-0: skipped"))
+	})
+
+	It("Should refresh the table until stopped", func() {
+		reporter.RefreshInterval = 10 * time.Millisecond
+		report(UpgradeEvent{Type: UpgradeStartedEvent, Nodes: []string{"k8s-agentpool1-0"}}, 0)
+
+		reporter.Start()
+		time.Sleep(50 * time.Millisecond)
+		report(UpgradeEvent{Type: NodeUpgradedEvent, PoolName: "agentpool1", NodeName: "k8s-agentpool1-0"}, time.Minute)
+		report(UpgradeEvent{Type: UpgradeCompletedEvent}, time.Second)
+		reporter.Stop()
+
+		Expect(strings.Count(out.String(), "\x1b[J")).To(BeNumerically(">", 1))
+		Expect(out.String()).To(HaveSuffix("Upgrade completed in 1m1s: 1 nodes upgraded, 0 failed\n"))
+	})
+})
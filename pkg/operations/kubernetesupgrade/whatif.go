@@ -0,0 +1,73 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/pkg/errors"
+)
+
+// previewDeployment logs the changes the deployment of the master VM template would make to the resource group,
+// as predicted by the ARM what-if operation, without deploying it
+func (kmn *UpgradeMasterNode) previewDeployment(ctx context.Context, deploymentName string) error {
+	if kmn.deploymentMode() == resources.Complete {
+		kmn.logger.Warningf("The what-if of %s previews an Incremental deployment, it does not list the resources Complete mode would delete", deploymentName)
+	}
+	changes, err := kmn.Client.WhatIfDeployTemplate(ctx, kmn.ResourceGroup, deploymentName, kmn.TemplateMap, kmn.ParametersMap)
+	if err != nil {
+		return errors.Wrapf(err, "previewing deployment %s", deploymentName)
+	}
+	counts := map[armhelpers.WhatIfChangeType]int{}
+	for _, change := range changes {
+		counts[change.ChangeType]++
+		switch change.ChangeType {
+		case armhelpers.WhatIfCreate, armhelpers.WhatIfDelete:
+			kmn.logger.Infof("What-if: %s %s", change.ChangeType, change.ResourceID)
+		case armhelpers.WhatIfModify:
+			var paths []string
+			for _, delta := range change.Delta {
+				paths = append(paths, delta.Path)
+			}
+			kmn.logger.Infof("What-if: Modify %s (%s)", change.ResourceID, strings.Join(paths, ", "))
+		}
+	}
+	changed := counts[armhelpers.WhatIfCreate] + counts[armhelpers.WhatIfModify] + counts[armhelpers.WhatIfDelete]
+	kmn.logger.Infof("What-if of deployment %s, not deployed: %d resources to create, %d to modify, %d to delete, %d unchanged",
+		deploymentName, counts[armhelpers.WhatIfCreate], counts[armhelpers.WhatIfModify], counts[armhelpers.WhatIfDelete], len(changes)-changed)
+	return nil
+}
+
+// WhatIf logs the changes the deployment of each master VM to upgrade would make, the master VMs are not upgraded
+func (ku *Upgrader) WhatIf(ctx context.Context) error {
+	if ku.ClusterTopology.DataModel.Properties.MasterProfile == nil {
+		return nil
+	}
+	upgradeMasterNode, err := ku.newUpgradeMasterNode(ctx)
+	if err != nil {
+		return err
+	}
+	if err = upgradeMasterNode.Preflight(ctx); err != nil {
+		return ku.Translator.Errorf("master upgrade preflight check failed: %s", err.Error())
+	}
+	upgradedMastersIndex := make(map[int]bool)
+	for _, vm := range *ku.ClusterTopology.UpgradedMasterVMs {
+		masterIndex, _ := utils.GetVMNameIndex(vm.StorageProfile.OsDisk.OsType, *vm.Name)
+		upgradedMastersIndex[masterIndex] = true
+	}
+	for masterIndex := 0; masterIndex < ku.ClusterTopology.DataModel.Properties.MasterProfile.Count; masterIndex++ {
+		if upgradedMastersIndex[masterIndex] {
+			continue
+		}
+		ku.logger.Infof("Previewing the deployment of upgraded master VM with index: %d", masterIndex)
+		if err = upgradeMasterNode.CreateNode(ctx, "master", masterIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+var _ = Describe("What-if mode tests", func() {
+	var (
+		mockClient *armhelpers.MockAKSEngineClient
+		kmn        *UpgradeMasterNode
+		hook       *logtest.Hook
+	)
+
+	BeforeEach(func() {
+		var logger *log.Logger
+		logger, hook = logtest.NewNullLogger()
+		mockClient = &armhelpers.MockAKSEngineClient{
+			FailDeployTemplate: true,
+			FakeWhatIfDeployTemplateResult: []armhelpers.WhatIfChange{
+				{ResourceID: "/vm/k8s-master-12345678-0", ChangeType: armhelpers.WhatIfModify, Delta: []armhelpers.WhatIfPropertyChange{
+					{Path: "properties.hardwareProfile.vmSize", PropertyChangeType: "Modify"},
+					{Path: "tags.orchestrator", PropertyChangeType: "Modify"},
+				}},
+				{ResourceID: "/disk/k8s-master-12345678-0_OsDisk", ChangeType: armhelpers.WhatIfDelete},
+				{ResourceID: "/nic/k8s-master-12345678-nic-0", ChangeType: armhelpers.WhatIfCreate},
+				{ResourceID: "/nsg/k8s-master-12345678-nsg", ChangeType: armhelpers.WhatIfNoChange},
+			},
+		}
+		kmn = newTestUpgradeMasterNode(mockClient)
+		kmn.logger = log.NewEntry(logger)
+		kmn.WhatIfMode = true
+		kmn.ExistingDeploymentName = "cluster-masters"
+	})
+
+	It("Should log the predicted changes of the master VM deployment without deploying it", func() {
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		Expect(mockClient.WhatIfDeploymentNames).To(Equal([]string{"cluster-masters"}))
+		Expect(mockClient.DeploymentModes).To(BeEmpty())
+		Expect(kmn.deploymentNames).To(BeEmpty())
+
+		var messages []string
+		for _, entry := range hook.Entries {
+			messages = append(messages, entry.Message)
+		}
+		Expect(messages).To(ContainElement("What-if: Modify /vm/k8s-master-12345678-0 (properties.hardwareProfile.vmSize, tags.orchestrator)"))
+		Expect(messages).To(ContainElement("What-if: Delete /disk/k8s-master-12345678-0_OsDisk"))
+		Expect(messages).To(ContainElement("What-if: Create /nic/k8s-master-12345678-nic-0"))
+		Expect(messages).NotTo(ContainElement(ContainSubstring("/nsg/")))
+		Expect(messages).To(ContainElement("What-if of deployment cluster-masters, not deployed: 1 resources to create, 1 to modify, 1 to delete, 1 unchanged"))
+	})
+
+	It("Should fail when the what-if operation fails", func() {
+		mockClient.FailWhatIfDeployTemplate = true
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(MatchError("previewing deployment cluster-masters: WhatIfDeployTemplate failed"))
+	})
+
+	It("Should warn that Complete mode deletions are not previewed", func() {
+		kmn.DeploymentMode = "Complete"
+
+		Expect(kmn.CreateNode(context.Background(), "master", 0)).To(Succeed())
+		var warnings []string
+		for _, entry := range hook.Entries {
+			if entry.Level == log.WarnLevel {
+				warnings = append(warnings, entry.Message)
+			}
+		}
+		Expect(warnings).To(ContainElement("The what-if of cluster-masters previews an Incremental deployment, it does not list the resources Complete mode would delete"))
+	})
+
+	It("Should preview the deployment of every master VM without upgrading any node", func() {
+		reporter := &fakeReporter{}
+		mockClient.FailDeleteVirtualMachine = true
+		uc := UpgradeCluster{
+			Translator: &i18n.Translator{},
+			Logger:     log.NewEntry(log.New()),
+			Reporters:  []UpgradeReporter{reporter},
+			WhatIfMode: true,
+		}
+		uc.Client = mockClient
+		uc.ClusterTopology = ClusterTopology{}
+		uc.SubscriptionID = "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = api.CreateMockContainerService("testcluster", "", 3, 1, false)
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{"agentpool1": true}
+
+		Expect(uc.UpgradeCluster(mockClient, "kubeConfig", TestAKSEngineVersion)).To(Succeed())
+		Expect(mockClient.WhatIfDeploymentNames).To(HaveLen(3))
+		Expect(mockClient.DeploymentModes).To(BeEmpty())
+		Expect(reporter.events).To(BeEmpty())
+	})
+})